@@ -0,0 +1,70 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+)
+
+func newAPIKeyCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "api-key",
+		Short: "Manage the API keys of the router's clients",
+	}
+
+	var name string
+	var scopes []string
+	create := &cobra.Command{
+		Use:   "create --name <name> --scope <scope>...",
+		Short: "Generate an API key, printing the key and the apikeys entry to configure",
+		Long: "Generate an API key, printing the key, to give to the client, and the apikeys entry with its hash,\n" +
+			"to add to the configuration. Scopes are " + strings.Join(config.Scopes, ", ") + ".",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if name == "" {
+				return fmt.Errorf("--name is required")
+			}
+			if len(scopes) == 0 {
+				return fmt.Errorf("at least one --scope is required")
+			}
+			for _, scope := range scopes {
+				if !slices.Contains(config.Scopes, scope) {
+					return fmt.Errorf("unknown scope %q: must be one of %s", scope, strings.Join(config.Scopes, ", "))
+				}
+			}
+
+			key, hash, err := config.NewAPIKey()
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(cmd.OutOrStdout(), "# key: %s\napikeys:\n  - name: %s\n    hash: %s\n    scopes: [%s]\n",
+				key, name, hash, strings.Join(scopes, ", "))
+			return err
+		},
+	}
+	create.Flags().StringVar(&name, "name", "", "the name of the key's holder, eg. splunk-prod")
+	create.Flags().StringSliceVar(&scopes, "scope", nil, "a scope of the key; may be repeated")
+	cmd.AddCommand(create)
+
+	return cmd
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/openshift/compliance-audit-router/pkg/audit"
+	"github.com/openshift/compliance-audit-router/pkg/config"
+)
+
+func newAuditCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Inspect the audit log of the router's decisions",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "verify [file]",
+		Short: "Verify the hash chain of the audit log, auditconfig.path by default",
+		Long: "Verify the hash chain of the audit log, auditconfig.path by default, reporting the first record\n" +
+			"that was altered, removed or reordered.",
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := config.AppConfig().AuditConfig.Path
+			if len(args) > 0 {
+				path = args[0]
+			}
+			if path == "" {
+				return errors.New("no audit log to verify: set auditconfig.path or pass the file")
+			}
+
+			file, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+
+			count, err := audit.Verify(file)
+			if err != nil {
+				return fmt.Errorf("%s failed verification after %d valid records: %w", path, count, err)
+			}
+			_, err = fmt.Fprintf(cmd.OutOrStdout(), "%s: %d records verified\n", path, count)
+			return err
+		},
+	})
+
+	return cmd
+}
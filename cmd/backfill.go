@@ -0,0 +1,109 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/listeners"
+	"github.com/openshift/compliance-audit-router/pkg/splunk"
+)
+
+// backfillDateLayout is the layout of the days of the backfill range
+const backfillDateLayout = "2006-01-02"
+
+func newBackfillCommand() *cobra.Command {
+	var start, end, search string
+
+	cmd := &cobra.Command{
+		Use:   "backfill --start <time> --end <time>",
+		Short: "Process the compliance events of a time range, eg. while the router was down",
+		Long: "Run the backfillconfig.search Splunk search over the events from --start to --end and process the\n" +
+			"compliance events of its results, a page at a time, as if their alerts were received.\n" +
+			"Times are days, like 2024-01-31, or RFC 3339 times; an --end day is included. Jira changes are only logged when dry-run is enabled.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if search == "" {
+				search = config.AppConfig().BackfillConfig.Search
+			}
+			if search == "" {
+				return errors.New("no search to backfill: set backfillconfig.search or pass --search")
+			}
+			earliest, err := parseBackfillTime("start", start, false)
+			if err != nil {
+				return err
+			}
+			latest, err := parseBackfillTime("end", end, true)
+			if err != nil {
+				return err
+			}
+			if !earliest.Before(latest) {
+				return fmt.Errorf("--start %s is not before --end %s", start, end)
+			}
+
+			if config.AppConfig().DryRun {
+				log.Println("dry-run enabled, Jira changes will be logged but not made")
+			}
+
+			var results int
+			err = splunk.Server(config.AppConfig().SplunkConfig).Search(cmd.Context(), search, earliest, latest, config.AppConfig().BackfillConfig.PageSize, func(page splunk.Alert) error {
+				if err := listeners.ProcessAlert(cmd.Context(), page); err != nil {
+					return fmt.Errorf("failed processing search results %d to %d: %w", results+1, results+len(page.SearchResults.Results), err)
+				}
+				results += len(page.SearchResults.Results)
+				log.Printf("backfilled %d search results", results)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			_, err = fmt.Fprintf(cmd.OutOrStdout(), "%d search results backfilled from %s to %s\n", results, earliest.Format(time.RFC3339), latest.Format(time.RFC3339))
+			return err
+		},
+	}
+	cmd.Flags().StringVar(&start, "start", "", "the start of the time range, inclusive")
+	cmd.Flags().StringVar(&end, "end", "", "the end of the time range: the last day, or the time, exclusive")
+	cmd.Flags().StringVar(&search, "search", "", "the Splunk search to run instead of backfillconfig.search")
+
+	return cmd
+}
+
+// parseBackfillTime parses the value of the flag as a day, in UTC, or an RFC 3339 time.
+// A day is parsed as the end of the day when it ends the range.
+func parseBackfillTime(flag, value string, end bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, fmt.Errorf("--%s is required", flag)
+	}
+	if t, err := time.Parse(backfillDateLayout, value); err == nil {
+		if end {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("--%s must be a day like 2024-01-31 or an RFC 3339 time: %s", flag, value)
+	}
+	return t, nil
+}
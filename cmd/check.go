@@ -0,0 +1,119 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/identity"
+	"github.com/openshift/compliance-audit-router/pkg/jira"
+	"github.com/openshift/compliance-audit-router/pkg/ldap"
+	"github.com/openshift/compliance-audit-router/pkg/splunk"
+)
+
+// connectionCheck is a live test of a dependency
+type connectionCheck struct {
+	dependency string
+	run        func() error
+}
+
+func newCheckConnectionsCommand() *cobra.Command {
+	var user string
+
+	cmd := &cobra.Command{
+		Use:   "check-connections",
+		Short: "Test the connections and permissions to Splunk, Jira and the identity provider",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return checkConnections(cmd, connectionChecks(user))
+		},
+	}
+	cmd.Flags().StringVar(&user, "user", "", "a user to look up with the identity provider")
+
+	return cmd
+}
+
+// connectionChecks returns the checks of the configured dependencies
+func connectionChecks(user string) []connectionCheck {
+	checks := []connectionCheck{
+		{dependency: "splunk " + config.AppConfig().SplunkConfig.Host, run: splunk.Server(config.AppConfig().SplunkConfig).CheckConnection},
+	}
+
+	checked := map[string]bool{}
+	for _, jiraConfig := range config.AppConfig().RoutedJiraConfigs() {
+		dependency := fmt.Sprintf("jira %s project %s", jiraConfig.Host, jiraConfig.Key)
+		if checked[dependency] {
+			continue
+		}
+		checked[dependency] = true
+
+		jiraConfig := jiraConfig
+		checks = append(checks, connectionCheck{dependency: dependency, run: func() error { return jira.CheckConnection(jiraConfig) }})
+	}
+
+	if config.AppConfig().IdentityProvider() == config.IdentityProviderLDAP {
+		servers := strings.Join(config.AppConfig().LDAPConfig.Servers(), ", ")
+		checks = append(checks, connectionCheck{dependency: "ldap " + servers, run: ldap.CheckConnection})
+	}
+
+	if user != "" {
+		checks = append(checks, connectionCheck{dependency: "identity provider lookup of " + user, run: func() error {
+			provider, err := identity.Default()
+			if err != nil {
+				return err
+			}
+			if provider == nil {
+				return errors.New("no identity provider configured")
+			}
+			_, err = provider.ResolveUser(context.Background(), user)
+			return err
+		}})
+	}
+
+	return checks
+}
+
+// checkConnections runs the checks, printing a table of the results, and fails if any check fails
+func checkConnections(cmd *cobra.Command, checks []connectionCheck) error {
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "DEPENDENCY\tRESULT\tDETAIL")
+
+	failed := 0
+	for _, check := range checks {
+		if err := check.run(); err != nil {
+			failed++
+			fmt.Fprintf(w, "%s\tFAIL\t%s\n", check.dependency, err.Error())
+			continue
+		}
+		fmt.Fprintf(w, "%s\tPASS\t\n", check.dependency)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d connection checks failed", failed, len(checks))
+	}
+	return nil
+}
@@ -17,55 +17,11 @@ limitations under the License.
 package main
 
 import (
-	"fmt"
-	"log"
-	"net/http"
-
-	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
-	"github.com/spf13/viper"
-
-	"github.com/openshift/compliance-audit-router/pkg/config"
-	"github.com/openshift/compliance-audit-router/pkg/listeners"
-
-	"github.com/openshift/compliance-audit-router/pkg/metrics"
+	"os"
 )
 
-func init() {
-	config.LoadConfig()
-}
-
 func main() {
-	log.Printf("using config file: %s", viper.ConfigFileUsed())
-
-	if config.AppConfig.DryRun {
-		log.Printf("dryRun:     %t", config.AppConfig.DryRun)
-	}
-
-	if config.AppConfig.Verbose {
-		log.Printf("verbose:     %t", config.AppConfig.Verbose)
-		log.Printf("ldapEnabled: %t", config.AppConfig.LDAPConfig.Enabled)
-
-		if config.AppConfig.LDAPConfig.Enabled {
-			log.Printf("ldapHost:    %s", config.AppConfig.LDAPConfig.Host)
-		}
-		log.Printf("splunkHost:  %s", config.AppConfig.SplunkConfig.Host)
-		log.Printf("jiraHost:    %s", config.AppConfig.JiraConfig.Host)
+	if err := newRootCommand().Execute(); err != nil {
+		os.Exit(1)
 	}
-
-	var portString = ":" + fmt.Sprint(config.AppConfig.ListenPort)
-
-	r := chi.NewRouter()
-	r.Use(middleware.DefaultLogger)
-
-	log.Printf("initializing routes")
-	listeners.InitRoutes(r)
-
-	// Metrics registration exposes Prometheus metrics on /metrics
-	// NOTE: This does not use a go-chi route but is logged.
-	log.Printf("registering metrics")
-	metrics.RegisterMetrics()
-
-	log.Printf("listening on %s", portString)
-	log.Fatal(http.ListenAndServe(portString, r))
 }
@@ -0,0 +1,85 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/listeners"
+	"github.com/openshift/compliance-audit-router/pkg/splunk"
+)
+
+// savedAlert is a saved Splunk webhook, or the search results of an alert
+type savedAlert struct {
+	splunk.Webhook
+	Results []splunk.SearchResult `json:"results"`
+}
+
+func newReplayCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "replay <file>",
+		Short: "Process an alert from a saved Splunk webhook or search results JSON file",
+		Long: "Process an alert from a saved Splunk webhook or search results JSON file, as if the webhook was received.\n" +
+			"The search results of a webhook are retrieved from Splunk. Jira changes are only logged when dry-run is enabled.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			alert, err := readAlert(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			if config.AppConfig().DryRun {
+				log.Println("dry-run enabled, Jira changes will be logged but not made")
+			}
+			return listeners.ProcessAlert(cmd.Context(), alert)
+		},
+	}
+}
+
+// readAlert reads the alert from the file, retrieving the search results from Splunk for a webhook
+func readAlert(ctx context.Context, file string) (splunk.Alert, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return splunk.Alert{}, err
+	}
+
+	var saved savedAlert
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return splunk.Alert{}, fmt.Errorf("failed decoding %s: %w", file, err)
+	}
+
+	if saved.Results != nil {
+		var searchResults splunk.SearchResults
+		if err := json.Unmarshal(data, &searchResults); err != nil {
+			return splunk.Alert{}, fmt.Errorf("failed decoding %s: %w", file, err)
+		}
+		return splunk.Alert{SearchID: saved.Sid, SearchResults: searchResults}, nil
+	}
+
+	if saved.Sid == "" {
+		return splunk.Alert{}, errors.New(file + " is neither a Splunk webhook with a sid nor search results")
+	}
+	log.Println("retrieving alert from Splunk:", saved.Sid)
+	return splunk.Server(config.AppConfig().SplunkConfig).RetrieveSearchFromAlert(ctx, saved.Sid)
+}
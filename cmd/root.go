@@ -0,0 +1,65 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+)
+
+// newRootCommand returns the compliance-audit-router command. Without a subcommand, it serves.
+func newRootCommand() *cobra.Command {
+	var configFile string
+
+	root := &cobra.Command{
+		Use:   config.Appname,
+		Short: "Route compliance alerts from a SIEM to issues in an issue tracker",
+		Args:  cobra.NoArgs,
+		// Usage is only useful for flag and argument errors, not failures while running
+		SilenceUsage: true,
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			if configFile != "" {
+				viper.SetConfigFile(configFile)
+			}
+			// Flags override the config file and environment
+			for key, flag := range map[string]string{"configtype": "config-type", "configdirs": "config-dir", "listenport": "port", "dryrun": "dry-run", "verbose": "verbose"} {
+				if err := viper.BindPFlag(key, cmd.Flags().Lookup(flag)); err != nil {
+					return err
+				}
+			}
+			config.LoadConfig()
+			return nil
+		},
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return serve()
+		},
+	}
+
+	flags := root.PersistentFlags()
+	flags.StringVar(&configFile, "config", "", "config file (default: "+config.Appname+".yaml, .json or .toml in . or ~/.config/"+config.Appname+")")
+	flags.String("config-type", "", "config file format: yaml, json or toml (default: detected from the config file's extension)")
+	flags.StringSlice("config-dir", nil, "directories of config files merged over the config file in name order, eg. mounted ConfigMap and Secret volumes; may be repeated")
+	flags.Int("port", 8080, "port to listen on")
+	flags.Bool("dry-run", true, "log the Jira changes that would be made instead of making them")
+	flags.Bool("verbose", true, "log verbosely")
+
+	root.AddCommand(newServeCommand(), newCheckConnectionsCommand(), newReplayCommand(), newConfigCommand(), newAuditCommand(), newBackfillCommand(), newSimulateCommand(), newAPIKeyCommand())
+
+	return root
+}
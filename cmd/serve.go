@@ -0,0 +1,171 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/openshift/compliance-audit-router/pkg/audit"
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/identity"
+	"github.com/openshift/compliance-audit-router/pkg/jira"
+	"github.com/openshift/compliance-audit-router/pkg/ldap"
+	"github.com/openshift/compliance-audit-router/pkg/listeners"
+	"github.com/openshift/compliance-audit-router/pkg/scheduler"
+	"github.com/openshift/compliance-audit-router/pkg/systemd"
+
+	"github.com/openshift/compliance-audit-router/pkg/metrics"
+)
+
+func newServeCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "serve",
+		Short: "Receive compliance alerts and Jira webhooks (the default command)",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return serve()
+		},
+	}
+}
+
+// serve runs the router, receiving alerts and Jira webhooks until the process exits
+func serve() error {
+	log.Printf("using config file: %s", viper.ConfigFileUsed())
+
+	config.AppConfig().LogMode()
+
+	if config.AppConfig().Verbose {
+		log.Printf("verbose:     %t", config.AppConfig().Verbose)
+		log.Printf("identityProvider: %s", config.AppConfig().IdentityProvider())
+
+		if config.AppConfig().IdentityProvider() == config.IdentityProviderLDAP {
+			log.Printf("ldapHost:    %s", config.AppConfig().LDAPConfig.Host)
+		}
+		log.Printf("splunkHost:  %s", config.AppConfig().SplunkConfig.Host)
+		log.Printf("jiraHost:    %s", config.AppConfig().JiraConfig.Host)
+	}
+
+	// Build the identity provider, failing fast on invalid credentials rather than at the first alert
+	if _, err := identity.Default(); err != nil {
+		return fmt.Errorf("failed creating the identity provider: %w", err)
+	}
+
+	// Check the Jira projects alerts may be created in, failing fast rather than at the first alert
+	if config.AppConfig().DryRun {
+		log.Printf("dry-run mode: skipping Jira project validation")
+	} else if !jira.ValidateConfig() {
+		log.Fatal("FATAL: Jira project validation failed - exiting")
+	}
+
+	var portString = ":" + fmt.Sprint(config.AppConfig().ListenPort)
+
+	r := chi.NewRouter()
+	r.Use(listeners.AccessLogger)
+
+	// Metrics registration exposes Prometheus metrics on /metrics
+	log.Printf("registering metrics")
+	registry, err := metrics.NewRegistry()
+	if err != nil {
+		return fmt.Errorf("failed registering metrics: %w", err)
+	}
+
+	log.Printf("initializing routes")
+	listeners.InitRoutes(r, registry)
+
+	// Reload the settings that can change at runtime when the config file changes or on SIGHUP
+	config.WatchConfig(func(err error) {
+		if err != nil {
+			metrics.MetricConfigReloads.With(prometheus.Labels{"result": "failure"}).Inc()
+			return
+		}
+		metrics.MetricConfigReloads.With(prometheus.Labels{"result": "success"}).Inc()
+	})
+
+	// Log the internal state on SIGUSR1, for hosts where the status endpoint isn't reachable
+	listeners.WatchStateDumps()
+
+	// Background jobs run until the process exits
+	var jobs []scheduler.Job
+	if config.AppConfig().ReminderConfig.Enabled {
+		jobs = append(jobs, scheduler.Job{Name: "reminders", Interval: config.AppConfig().ReminderConfig.Interval, Run: jira.SendReminders})
+	}
+	if config.AppConfig().AuditConfig.Path != "" && config.AppConfig().RetentionConfig.AuditDays > 0 {
+		jobs = append(jobs, scheduler.Job{Name: "audit-retention", Interval: config.AppConfig().RetentionConfig.Interval, Run: audit.PurgeExpired})
+	}
+	if config.AppConfig().AggregationConfig.Dir != "" {
+		// Events buffered before a restart are flushed once their aggregation window ends
+		listeners.RestoreAggregates()
+	}
+	if config.AppConfig().SpoolConfig.Dir != "" {
+		jobs = append(jobs, scheduler.Job{Name: "spool", Interval: config.AppConfig().SpoolConfig.DrainInterval, Run: listeners.DrainSpool})
+	}
+	if config.AppConfig().OutboxConfig.Dir != "" {
+		// Tickets left unfinished before a restart are finished right away rather than after the first interval
+		go jira.ReconcileOutbox()
+	}
+	// The outbox also holds the repairs of the tickets whose initial comment or transition failed
+	jobs = append(jobs, scheduler.Job{Name: "outbox", Interval: config.AppConfig().OutboxConfig.Interval, Run: jira.ReconcileOutbox})
+	if len(config.AppConfig().DigestConfig.AlertNames) > 0 {
+		if config.AppConfig().DigestConfig.Dir != "" {
+			// Events collected before a restart are sent with the next digest
+			listeners.RestoreDigests()
+		}
+		jobs = append(jobs, scheduler.Job{Name: "digests", Interval: time.Minute, Run: listeners.SendDigests})
+	}
+	if config.AppConfig().IdentityProvider() == config.IdentityProviderLDAP && config.AppConfig().LDAPConfig.IdleTimeout > 0 {
+		jobs = append(jobs, scheduler.Job{Name: "ldap-idle-connections", Interval: config.AppConfig().LDAPConfig.IdleTimeout, Run: ldap.ReapIdleConnections})
+	}
+	if config.AppConfig().HasSecretFiles() {
+		jobs = append(jobs, scheduler.Job{Name: "secret-files", Interval: time.Minute, Run: config.RefreshSecretFiles})
+	}
+	scheduler.Start(make(chan struct{}), jobs...)
+
+	listener, err := net.Listen("tcp", portString)
+	if err != nil {
+		return err
+	}
+	log.Printf("listening on %s", portString)
+
+	// Under a Type=notify systemd service, report readiness once the routes are served, and send watchdog
+	// keepalives while the router answers its liveness endpoint
+	if _, err := systemd.Notify(systemd.Ready); err != nil {
+		log.Printf("failed notifying systemd of readiness: %s", err.Error())
+	}
+	systemd.StartWatchdog(func() bool { return live(portString) }, make(chan struct{}))
+
+	return http.Serve(listener, r)
+}
+
+// live reports whether the router answers its liveness endpoint on the port
+func live(portString string) bool {
+	client := http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get("http://127.0.0.1" + portString + "/healthz")
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
@@ -0,0 +1,337 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+
+	"github.com/spf13/cobra"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/identity"
+	"github.com/openshift/compliance-audit-router/pkg/listeners"
+	"github.com/openshift/compliance-audit-router/pkg/splunk"
+	cartesting "github.com/openshift/compliance-audit-router/pkg/testing"
+)
+
+// simulatedUser is a user of the fake identity provider of a simulation
+type simulatedUser struct {
+	Manager string   `json:"manager"`
+	Groups  []string `json:"groups"`
+}
+
+// simulationReport is what the router did with the fixture alerts of a simulation
+type simulationReport struct {
+	Alerts []simulatedAlert `json:"alerts"`
+}
+
+// simulatedAlert is the outcome of a fixture alert, with the tickets created while processing it
+type simulatedAlert struct {
+	Fixture string            `json:"fixture"`
+	Events  int               `json:"events"`
+	Error   string            `json:"error,omitempty"`
+	Tickets []simulatedTicket `json:"tickets,omitempty"`
+}
+
+// simulatedTicket is a ticket as left by the simulation, with the statuses it was transitioned to
+type simulatedTicket struct {
+	Jira        string   `json:"jira,omitempty"`
+	Key         string   `json:"key"`
+	IssueType   string   `json:"issueType"`
+	Summary     string   `json:"summary"`
+	Assignee    string   `json:"assignee,omitempty"`
+	Labels      []string `json:"labels,omitempty"`
+	Status      string   `json:"status"`
+	Transitions []string `json:"transitions,omitempty"`
+	Description string   `json:"description"`
+	Comments    []string `json:"comments,omitempty"`
+}
+
+func newSimulateCommand() *cobra.Command {
+	var usersFile string
+
+	cmd := &cobra.Command{
+		Use:   "simulate <fixture>...",
+		Short: "Process fixture alerts against fake Splunk, Jira and identity services, and report the tickets",
+		Long: "Process fixture alerts, saved Splunk webhooks with their result or search results JSON files, or directories\n" +
+			"of them, through the full pipeline with the configuration, against fake Splunk and Jira servers and identity\n" +
+			"provider, and print a JSON report of the tickets, comments and transitions made, eg. to diff in CI when the\n" +
+			"configuration changes. Nothing is sent to the configured services, and enrichers and on-call lookups are disabled.",
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			users := map[string]simulatedUser{}
+			if usersFile != "" {
+				data, err := os.ReadFile(usersFile)
+				if err != nil {
+					return err
+				}
+				if err := json.Unmarshal(data, &users); err != nil {
+					return fmt.Errorf("failed decoding %s: %w", usersFile, err)
+				}
+			}
+
+			fixtures, err := fixtureFiles(args)
+			if err != nil {
+				return err
+			}
+
+			report, err := simulate(cmd.Context(), fixtures, users)
+			if err != nil {
+				return err
+			}
+			if err := writeSimulationReport(cmd.OutOrStdout(), report); err != nil {
+				return err
+			}
+
+			var failed int
+			for _, alert := range report.Alerts {
+				if alert.Error != "" {
+					failed++
+				}
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d of %d fixture alerts failed", failed, len(report.Alerts))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&usersFile, "users", "", "a JSON file of the users of the fake identity provider, like {\"sre\": {\"manager\": \"boss\", \"groups\": [\"sre\"]}}; without it, users are not resolved")
+
+	return cmd
+}
+
+// fixtureFiles returns the fixture files, with the JSON files of the directories in name order
+func fixtureFiles(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(arg, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	if len(files) == 0 {
+		return nil, errors.New("no fixture alerts found")
+	}
+	return files, nil
+}
+
+// simulate processes the fixture alerts with the configuration pointed at fake services, in order,
+// and reports the tickets created while processing each
+func simulate(ctx context.Context, fixtures []string, users map[string]simulatedUser) (simulationReport, error) {
+	splunkServer := cartesting.NewSplunk()
+	defer splunkServer.Close()
+
+	// Each Jira instance is faked by its own server, so the report tells the instances apart
+	statuses := simulationStatuses(config.AppConfig())
+	jiraServers := map[string]*cartesting.Jira{}
+	for _, name := range append([]string{""}, jiraInstanceNames(config.AppConfig())...) {
+		jiraServer := cartesting.NewJira()
+		defer jiraServer.Close()
+		jiraServer.AnyUser = true
+		jiraServer.SetStatuses(statuses...)
+		jiraServers[name] = jiraServer
+	}
+
+	identities := cartesting.NewIdentities()
+	for username, user := range users {
+		identities.AddUser(username, user.Manager, user.Groups...)
+	}
+	if len(users) > 0 {
+		identity.SetDefault(identities)
+	} else {
+		identity.SetDefault(nil)
+	}
+
+	config.SetAppConfig(simulationConfig(*config.AppConfig(), splunkServer, jiraServers))
+
+	var report simulationReport
+	created := map[string]int{}
+	for n, fixture := range fixtures {
+		simulated := simulatedAlert{Fixture: fixture}
+
+		alert, err := readFixture(ctx, splunkServer, fixture, fmt.Sprintf("simulation_%d", n+1))
+		if err == nil {
+			simulated.Events = len(alert.Details())
+			err = listeners.ProcessAlert(ctx, alert)
+		}
+		if err != nil {
+			log.Printf("failed processing %s: %v", fixture, err)
+			simulated.Error = err.Error()
+		}
+
+		for _, name := range sortedKeys(jiraServers) {
+			issues := jiraServers[name].Issues()
+			for _, issue := range issues[created[name]:] {
+				simulated.Tickets = append(simulated.Tickets, simulatedTicket{Jira: name, Key: issue.Key})
+			}
+			created[name] = len(issues)
+		}
+		report.Alerts = append(report.Alerts, simulated)
+	}
+
+	// The tickets are reported as left by the whole simulation, as later alerts may update them
+	for _, alert := range report.Alerts {
+		for i, ticket := range alert.Tickets {
+			alert.Tickets[i] = ticketReport(jiraServers[ticket.Jira], ticket.Jira, ticket.Key)
+		}
+	}
+	return report, nil
+}
+
+// readFixture reads the fixture alert, and retrieves its search results from the fake Splunk server like a
+// received alert's. A webhook's search results are its result.
+func readFixture(ctx context.Context, splunkServer *cartesting.Splunk, file, sid string) (splunk.Alert, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return splunk.Alert{}, err
+	}
+
+	var saved savedAlert
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return splunk.Alert{}, fmt.Errorf("failed decoding %s: %w", file, err)
+	}
+
+	results := saved.Results
+	if results == nil {
+		if len(saved.Result) == 0 {
+			return splunk.Alert{}, errors.New(file + " is neither a Splunk webhook with a result nor search results")
+		}
+		results = []splunk.SearchResult{saved.Result}
+	}
+	if saved.Sid != "" {
+		sid = saved.Sid
+	}
+
+	splunkServer.AddResults(sid, results...)
+	return splunk.Server(config.AppConfig().SplunkConfig).RetrieveSearchFromAlert(ctx, sid)
+}
+
+// simulationConfig returns the configuration with Splunk and the Jira instances pointed at the fake servers,
+// Jira changes made rather than logged, and the integrations without fakes disabled. Nothing is recorded on disk.
+func simulationConfig(appConfig config.Config, splunkServer *cartesting.Splunk, jiraServers map[string]*cartesting.Jira) config.Config {
+	fakeSplunk := splunkServer.Config()
+	appConfig.SplunkConfig.Host, appConfig.SplunkConfig.Token, appConfig.SplunkConfig.TokenFile = fakeSplunk.Host, fakeSplunk.Token, ""
+
+	fakeJira := func(jiraConfig config.JiraConfig, jiraServer *cartesting.Jira) config.JiraConfig {
+		fake := jiraServer.Config()
+		jiraConfig.Host, jiraConfig.Token, jiraConfig.TokenFile, jiraConfig.Username = fake.Host, fake.Token, "", ""
+		jiraConfig.AllowInsecure = false
+		return jiraConfig
+	}
+	appConfig.JiraConfig = fakeJira(appConfig.JiraConfig, jiraServers[""])
+	instances := make(map[string]config.JiraConfig, len(appConfig.JiraInstances))
+	for name, instance := range appConfig.JiraInstances {
+		instances[name] = fakeJira(instance, jiraServers[name])
+	}
+	appConfig.JiraInstances = instances
+
+	appConfig.DryRun = false
+	appConfig.Enrichers = nil
+	appConfig.OnCallConfig.Provider = ""
+	appConfig.AuditConfig.Path = ""
+	appConfig.SpoolConfig.Dir = ""
+	appConfig.OutboxConfig.Dir = ""
+	return appConfig
+}
+
+// simulationStatuses returns the workflow of the fake Jira servers: a status for new issues, followed by
+// the statuses of the transitions of all the Jira instances and tenants
+func simulationStatuses(appConfig *config.Config) []string {
+	statuses := []string{"Open"}
+	add := func(transitions map[string]string) {
+		for _, key := range []string{"initial", "sre", "manager"} {
+			if status := transitions[key]; status != "" && !slices.Contains(statuses, status) {
+				statuses = append(statuses, status)
+			}
+		}
+	}
+	add(appConfig.JiraConfig.Transitions)
+	for _, name := range jiraInstanceNames(appConfig) {
+		add(appConfig.JiraInstances[name].Transitions)
+	}
+	for _, name := range sortedKeys(appConfig.Tenants) {
+		add(appConfig.Tenants[name].Transitions)
+	}
+	return statuses
+}
+
+func jiraInstanceNames(appConfig *config.Config) []string {
+	return sortedKeys(appConfig.JiraInstances)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ticketReport returns the ticket as left in the fake Jira server
+func ticketReport(jiraServer *cartesting.Jira, instance, key string) simulatedTicket {
+	ticket := simulatedTicket{Jira: instance, Key: key}
+	for _, issue := range jiraServer.Issues() {
+		if issue.Key != key || issue.Fields == nil {
+			continue
+		}
+		fields := issue.Fields
+		ticket.IssueType = fields.Type.Name
+		ticket.Summary = fields.Summary
+		ticket.Labels = fields.Labels
+		ticket.Description = fields.Description
+		if fields.Status != nil {
+			ticket.Status = fields.Status.Name
+		}
+		if fields.Assignee != nil {
+			ticket.Assignee = fields.Assignee.Name
+			if ticket.Assignee == "" {
+				ticket.Assignee = fields.Assignee.AccountID
+			}
+		}
+	}
+	ticket.Transitions = jiraServer.Transitions(key)
+	ticket.Comments = jiraServer.Comments(key)
+	return ticket
+}
+
+func writeSimulationReport(w io.Writer, report simulationReport) error {
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
@@ -0,0 +1,254 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package audit records the router's decisions, eg. the tickets it creates and approves, in an
+// append-only log. Each record includes the hash of the previous record, so altering, removing or
+// reordering records breaks the chain and is detected by Verify.
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/metrics"
+)
+
+// Actions of the recorded decisions
+const (
+	ActionTicketCreated    = "ticket_created"
+	ActionTicketSkipped    = "ticket_skipped"
+	ActionTransitioned     = "transitioned"
+	ActionAutoApproved     = "auto_approved"
+	ActionReadOnlyResolved = "read_only_resolved"
+	ActionDigestCreated    = "digest_created"
+	ActionEventAppended    = "event_appended"
+	// ActionRecordsPurged starts a log whose older records were purged by the retention policy
+	ActionRecordsPurged = "records_purged"
+	// ActionTemplatesUpdated and ActionTemplatesRolledBack record changes to the templates through the admin API
+	ActionTemplatesUpdated    = "templates_updated"
+	ActionTemplatesRolledBack = "templates_rolled_back"
+	// ActionSettingsUpdated records changes to the dry-run mode and log levels through the admin API
+	ActionSettingsUpdated = "settings_updated"
+)
+
+// anchorDetail is the detail of the records_purged record holding the hash of the last purged record,
+// which the first retained record chains to
+const anchorDetail = "anchor"
+
+// maxRecordSize is the size of the largest record read back from the log
+const maxRecordSize = 1024 * 1024
+
+// Record is a decision of the router
+type Record struct {
+	Time     time.Time         `json:"time"`
+	Action   string            `json:"action"`
+	IssueKey string            `json:"issueKey,omitempty"`
+	Details  map[string]string `json:"details,omitempty"`
+	// DryRun is set for decisions only logged in dry-run mode
+	DryRun bool `json:"dryRun,omitempty"`
+	// PrevHash is the hash of the previous record, empty for the first record
+	PrevHash string `json:"prevHash"`
+	// Hash is the SHA-256 hash of the record without its hash, hex encoded
+	Hash string `json:"hash"`
+}
+
+// chainedHash returns the hash the next record chains to: the record's own hash, or the anchor
+// when the record is the records_purged record starting the log
+func (r Record) chainedHash(first bool) string {
+	if first && r.Action == ActionRecordsPurged {
+		return r.Details[anchorDetail]
+	}
+	return r.Hash
+}
+
+// hash computes the hash of the record, which covers all its fields but the hash itself
+func (r Record) hash() (string, error) {
+	r.Hash = ""
+	b, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Log appends hash chained records to a file, one JSON record per line
+type Log struct {
+	mu       sync.Mutex
+	path     string
+	file     *os.File
+	lastHash string
+}
+
+// Open opens the log file for appending, creating it if needed, and continues the chain of its last record
+func Open(path string) (*Log, error) {
+	lastHash, err := lastHashOf(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return &Log{path: path, file: file, lastHash: lastHash}, nil
+}
+
+// lastHashOf returns the hash of the last record of the log file, or an empty string if there is none
+func lastHashOf(path string) (string, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read audit log: %w", err)
+	}
+	defer file.Close()
+
+	var lastHash string
+	first := true
+	err = scanRecords(file, func(_ int, _ []byte, record Record) error {
+		lastHash = record.chainedHash(first)
+		first = false
+		return nil
+	})
+	return lastHash, err
+}
+
+// Append records the decision, chained to the previous record, and syncs it to disk
+func (l *Log) Append(record Record) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.append(record)
+}
+
+func (l *Log) append(record Record) error {
+	record.PrevHash = l.lastHash
+	hash, err := record.hash()
+	if err != nil {
+		return err
+	}
+	record.Hash = hash
+
+	b, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err := l.file.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit record: %w", err)
+	}
+	if err := l.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync audit log: %w", err)
+	}
+
+	l.lastHash = hash
+	return nil
+}
+
+// Verify checks the hash chain of the log, returning the number of records and an error
+// naming the first record that was altered, removed or reordered. A log whose older records
+// were purged starts with a records_purged record, whose anchor the first retained record chains to.
+func Verify(r io.Reader) (int, error) {
+	var prevHash string
+	count := 0
+	err := scanRecords(r, func(line int, _ []byte, record Record) error {
+		if record.PrevHash != prevHash {
+			return fmt.Errorf("record on line %d does not follow the previous record: the previous record was removed or altered", line)
+		}
+		hash, err := record.hash()
+		if err != nil {
+			return err
+		}
+		if hash != record.Hash {
+			return fmt.Errorf("record on line %d was altered: its hash is %s, want %s", line, record.Hash, hash)
+		}
+		prevHash = record.chainedHash(count == 0)
+		count++
+		return nil
+	})
+	return count, err
+}
+
+// scanRecords decodes each record of the log in order, with its line number and the line itself,
+// which is only valid until fn returns
+func scanRecords(r io.Reader, fn func(line int, raw []byte, record Record) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxRecordSize)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return fmt.Errorf("record on line %d is not valid JSON: %w", line, err)
+		}
+		if err := fn(line, scanner.Bytes(), record); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+var (
+	defaultLogOnce sync.Once
+	defaultLog     *Log
+	defaultLogErr  error
+)
+
+// Default returns the audit log configured in auditconfig.path, or nil when none is configured
+func Default() (*Log, error) {
+	defaultLogOnce.Do(func() {
+		if path := config.AppConfig().AuditConfig.Path; path != "" {
+			defaultLog, defaultLogErr = Open(path)
+		}
+	})
+	return defaultLog, defaultLogErr
+}
+
+// Write appends the decision to the default audit log, if one is configured. Failing to do so is
+// logged and counted rather than failing the decision, which has already been made in Jira.
+func Write(action, issueKey string, details map[string]string) {
+	auditLog, err := Default()
+	if err == nil && auditLog == nil {
+		return
+	}
+	if err == nil {
+		err = auditLog.Append(Record{
+			Time:     time.Now().UTC(),
+			Action:   action,
+			IssueKey: issueKey,
+			Details:  details,
+			DryRun:   config.AppConfig().DryRun,
+		})
+	}
+	if err != nil {
+		log.Printf("audit.Write(): failed to record %s of issue %s: %v\n", action, issueKey, err)
+		metrics.MetricAuditFailures.Inc()
+	}
+}
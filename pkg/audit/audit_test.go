@@ -0,0 +1,76 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	auditLog, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() unexpected error: %v", err)
+	}
+	for _, key := range []string{"CAR-1", "CAR-2"} {
+		if err := auditLog.Append(Record{Time: time.Now().UTC(), Action: ActionTicketCreated, IssueKey: key}); err != nil {
+			t.Fatalf("Append() unexpected error: %v", err)
+		}
+	}
+
+	// Reopening the log continues its chain
+	auditLog, err = Open(path)
+	if err != nil {
+		t.Fatalf("Open() unexpected error reopening the log: %v", err)
+	}
+	if err := auditLog.Append(Record{Time: time.Now().UTC(), Action: ActionAutoApproved, IssueKey: "CAR-1", Details: map[string]string{"changeRecord": "CHG-1"}}); err != nil {
+		t.Fatalf("Append() unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count, err := Verify(bytes.NewReader(data)); err != nil || count != 3 {
+		t.Errorf("Verify() = %d, %v, want 3 records verified", count, err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	tests := []struct {
+		name    string
+		records []string
+		want    string
+	}{
+		{"Altered record", []string{lines[0], strings.Replace(lines[1], "CAR-2", "CAR-3", 1), lines[2]}, "record on line 2 was altered"},
+		{"Removed record", []string{lines[0], lines[2]}, "record on line 2 does not follow the previous record"},
+		{"Reordered records", []string{lines[1], lines[0], lines[2]}, "record on line 1 does not follow the previous record"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Verify(strings.NewReader(strings.Join(tt.records, "\n")))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Verify() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
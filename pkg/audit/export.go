@@ -0,0 +1,119 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// Export formats
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+// csvHeader are the columns of CSV exports
+var csvHeader = []string{"time", "action", "issueKey", "alert", "user", "dryRun", "details", "hash"}
+
+// Filter selects the records of an export. Empty fields match any record.
+type Filter struct {
+	// From and To limit the records to those from From, inclusive, until To, exclusive
+	From time.Time
+	To   time.Time
+	// Actions are the actions of the records, eg. ticket_created
+	Actions []string
+	// Alert, User and IssueKey match the alert name, user and issue key, case-insensitively
+	Alert    string
+	User     string
+	IssueKey string
+}
+
+// Matches reports whether the record is selected by the filter
+func (f Filter) Matches(record Record) bool {
+	return (f.From.IsZero() || !record.Time.Before(f.From)) &&
+		(f.To.IsZero() || record.Time.Before(f.To)) &&
+		(len(f.Actions) == 0 || slices.Contains(f.Actions, record.Action)) &&
+		(f.Alert == "" || strings.EqualFold(f.Alert, record.Details["alert"])) &&
+		(f.User == "" || strings.EqualFold(f.User, record.Details["user"])) &&
+		(f.IssueKey == "" || strings.EqualFold(f.IssueKey, record.IssueKey))
+}
+
+// Export writes the records of the log selected by the filter, as CSV or JSON lines, as they are read
+func Export(r io.Reader, w io.Writer, format string, filter Filter) error {
+	if format == FormatJSONL {
+		encoder := json.NewEncoder(w)
+		return scanRecords(r, func(_ int, _ []byte, record Record) error {
+			if !filter.Matches(record) {
+				return nil
+			}
+			return encoder.Encode(record)
+		})
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+	err := scanRecords(r, func(_ int, _ []byte, record Record) error {
+		if !filter.Matches(record) {
+			return nil
+		}
+		if err := writer.Write([]string{
+			record.Time.Format(time.RFC3339),
+			record.Action,
+			record.IssueKey,
+			record.Details["alert"],
+			record.Details["user"],
+			strconv.FormatBool(record.DryRun),
+			formatDetails(record.Details),
+			record.Hash,
+		}); err != nil {
+			return err
+		}
+		// Flush each record, so the export streams to the client
+		writer.Flush()
+		return writer.Error()
+	})
+	writer.Flush()
+	if err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+// formatDetails renders the details as name=value pairs sorted by name, separated by semicolons
+func formatDetails(details map[string]string) string {
+	names := make([]string, 0, len(details))
+	for name := range details {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, name+"="+details[name])
+	}
+	return strings.Join(pairs, "; ")
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExport(t *testing.T) {
+	day, _ := time.Parse(time.RFC3339, "2024-01-31T10:00:00Z")
+
+	var log bytes.Buffer
+	for i, record := range []Record{
+		{Time: day, Action: ActionTicketCreated, IssueKey: "CAR-1", Details: map[string]string{"alert": "elevation", "user": "sre"}},
+		{Time: day.Add(time.Hour), Action: ActionAutoApproved, IssueKey: "CAR-1", Details: map[string]string{"changeRecord": "CHG-1", "source": "alert"}},
+		{Time: day.Add(48 * time.Hour), Action: ActionTicketCreated, IssueKey: "CAR-2", Details: map[string]string{"alert": "elevation", "user": "other"}},
+	} {
+		record.Hash = string(rune('a' + i))
+		b, err := json.Marshal(record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		log.Write(append(b, '\n'))
+	}
+
+	var out bytes.Buffer
+	filter := Filter{From: day, To: day.Add(24 * time.Hour), IssueKey: "car-1"}
+	if err := Export(strings.NewReader(log.String()), &out, FormatCSV, filter); err != nil {
+		t.Fatalf("Export() unexpected error: %v", err)
+	}
+	want := "time,action,issueKey,alert,user,dryRun,details,hash\n" +
+		"2024-01-31T10:00:00Z,ticket_created,CAR-1,elevation,sre,false,alert=elevation; user=sre,a\n" +
+		"2024-01-31T11:00:00Z,auto_approved,CAR-1,,,false,changeRecord=CHG-1; source=alert,b\n"
+	if out.String() != want {
+		t.Errorf("Export() = %q, want %q", out.String(), want)
+	}
+
+	out.Reset()
+	if err := Export(strings.NewReader(log.String()), &out, FormatJSONL, Filter{Actions: []string{ActionTicketCreated}, User: "other"}); err != nil {
+		t.Fatalf("Export() unexpected error: %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(out.String()), "\n"); len(lines) != 1 || !strings.Contains(lines[0], `"issueKey":"CAR-2"`) {
+		t.Errorf("Export() = %q, want the record of CAR-2", out.String())
+	}
+}
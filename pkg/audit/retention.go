@@ -0,0 +1,136 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/helpers"
+	"github.com/openshift/compliance-audit-router/pkg/metrics"
+)
+
+// Purge removes the records older than the time, keeping the chain of the remaining records verifiable:
+// the log is rewritten to start with a records_purged record anchoring the chain at the last purged record.
+// Records are purged in order, up to the first record that is not older than the time. It returns the
+// number of records purged.
+func (l *Log) Purge(before time.Time) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	lines, records, err := readLog(l.path)
+	if err != nil {
+		return 0, err
+	}
+
+	// A previous purge's records_purged record is replaced by this purge's
+	start := 0
+	if len(records) > 0 && records[0].Action == ActionRecordsPurged {
+		start = 1
+	}
+	retained := start
+	for retained < len(records) && records[retained].Time.Before(before) {
+		retained++
+	}
+	purged := retained - start
+	if purged == 0 {
+		return 0, nil
+	}
+
+	marker := Record{
+		Time:    time.Now().UTC(),
+		Action:  ActionRecordsPurged,
+		Details: map[string]string{anchorDetail: records[retained-1].Hash, "records": strconv.Itoa(purged), "before": before.UTC().Format(time.RFC3339)},
+	}
+	if marker.Hash, err = marker.hash(); err != nil {
+		return 0, err
+	}
+	markerLine, err := json.Marshal(marker)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := l.rewrite(append([][]byte{markerLine}, lines[retained:]...)); err != nil {
+		return 0, err
+	}
+	return purged, nil
+}
+
+// readLog reads the lines of the log file and their records
+func readLog(path string) ([][]byte, []Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	defer file.Close()
+
+	var lines [][]byte
+	var records []Record
+	err = scanRecords(file, func(_ int, raw []byte, record Record) error {
+		lines = append(lines, append([]byte{}, raw...))
+		records = append(records, record)
+		return nil
+	})
+	return lines, records, err
+}
+
+// rewrite replaces the log file with the lines, atomically, and reopens it for appending
+func (l *Log) rewrite(lines [][]byte) error {
+	var data bytes.Buffer
+	for _, line := range lines {
+		data.Write(line)
+		data.WriteByte('\n')
+	}
+	if err := helpers.WriteFileAtomic(l.path, data.Bytes()); err != nil {
+		return fmt.Errorf("failed to replace the audit log: %w", err)
+	}
+
+	file, err := os.OpenFile(l.path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to reopen the audit log: %w", err)
+	}
+	l.file.Close()
+	l.file = file
+	return nil
+}
+
+// PurgeExpired purges the records of the default audit log older than retentionconfig.auditdays.
+// It is run periodically by the scheduler.
+func PurgeExpired() {
+	days := config.AppConfig().RetentionConfig.AuditDays
+	auditLog, err := Default()
+	if err != nil || auditLog == nil || days <= 0 {
+		return
+	}
+
+	purged, err := auditLog.Purge(time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		log.Printf("audit.PurgeExpired(): failed to purge the audit log: %v\n", err)
+		metrics.MetricRetentionPurgeFailures.With(map[string]string{"store": "audit"}).Inc()
+		return
+	}
+	if purged > 0 {
+		log.Printf("audit.PurgeExpired(): purged %d audit records older than %d days", purged, days)
+		metrics.MetricRetentionPurgedRecords.With(map[string]string{"store": "audit"}).Add(float64(purged))
+	}
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPurge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	now := time.Now().UTC()
+
+	auditLog, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() unexpected error: %v", err)
+	}
+	records := []struct {
+		key string
+		age int
+	}{{"CAR-1", 40}, {"CAR-2", 35}, {"CAR-3", 10}}
+	for _, r := range records {
+		if err := auditLog.Append(Record{Time: now.AddDate(0, 0, -r.age), Action: ActionTicketCreated, IssueKey: r.key}); err != nil {
+			t.Fatalf("Append() unexpected error: %v", err)
+		}
+	}
+
+	purged, err := auditLog.Purge(now.AddDate(0, 0, -30))
+	if err != nil || purged != 2 {
+		t.Fatalf("Purge() = %d, %v, want 2 records purged", purged, err)
+	}
+	// Purging again doesn't purge the records_purged record
+	if purged, err := auditLog.Purge(now.AddDate(0, 0, -30)); err != nil || purged != 0 {
+		t.Fatalf("Purge() = %d, %v, want no records purged", purged, err)
+	}
+	if err := auditLog.Append(Record{Time: now, Action: ActionTransitioned, IssueKey: "CAR-3"}); err != nil {
+		t.Fatalf("Append() unexpected error: %v", err)
+	}
+
+	// Reopening the purged log continues its chain
+	auditLog, err = Open(path)
+	if err != nil {
+		t.Fatalf("Open() unexpected error reopening the log: %v", err)
+	}
+	if err := auditLog.Append(Record{Time: now, Action: ActionTicketCreated, IssueKey: "CAR-4"}); err != nil {
+		t.Fatalf("Append() unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count, err := Verify(bytes.NewReader(data)); err != nil || count != 4 {
+		t.Errorf("Verify() = %d, %v, want 4 records verified", count, err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if !strings.Contains(lines[0], ActionRecordsPurged) || strings.Contains(string(data), "CAR-1") {
+		t.Errorf("Purge() did not replace the old records with a records_purged record: %v", lines)
+	}
+	if _, err := Verify(strings.NewReader(strings.Join(lines[1:], "\n"))); err == nil {
+		t.Errorf("Verify() did not detect the removed records_purged record")
+	}
+	if _, err := Verify(strings.NewReader(strings.Join(append([]string{lines[0]}, lines[2:]...), "\n"))); err == nil {
+		t.Errorf("Verify() did not detect the removed first retained record")
+	}
+}
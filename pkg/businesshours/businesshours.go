@@ -0,0 +1,101 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package businesshours measures time in business hours, so idle tickets aren't escalated
+// outside working hours, on weekends or on holidays
+package businesshours
+
+import (
+	"time"
+	// The time zone database is embedded, as minimal container images don't include it
+	_ "time/tzdata"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+)
+
+// Calendar holds the working hours of each working day, in a time zone
+type Calendar struct {
+	config   config.BusinessHoursConfig
+	location *time.Location
+	start    time.Duration
+	end      time.Duration
+	days     map[time.Weekday]bool
+}
+
+// New creates the calendar of the business hours
+func New(businessHours config.BusinessHoursConfig) (*Calendar, error) {
+	location, err := businessHours.Location()
+	if err != nil {
+		return nil, err
+	}
+	start, end, err := businessHours.Hours()
+	if err != nil {
+		return nil, err
+	}
+	weekdays, err := businessHours.Weekdays()
+	if err != nil {
+		return nil, err
+	}
+
+	days := map[time.Weekday]bool{}
+	for _, weekday := range weekdays {
+		days[weekday] = true
+	}
+	return &Calendar{config: businessHours, location: location, start: start, end: end, days: days}, nil
+}
+
+// IsOpen reports whether the time is within business hours
+func (c *Calendar) IsOpen(t time.Time) bool {
+	open, close, ok := c.hours(t)
+	return ok && !t.Before(open) && t.Before(close)
+}
+
+// Elapsed returns the business hours between the times
+func (c *Calendar) Elapsed(from, to time.Time) time.Duration {
+	var elapsed time.Duration
+	for day := from; day.Before(to); day = c.nextDay(day) {
+		open, close, ok := c.hours(day)
+		if !ok {
+			continue
+		}
+		if from.After(open) {
+			open = from
+		}
+		if to.Before(close) {
+			close = to
+		}
+		if close.After(open) {
+			elapsed += close.Sub(open)
+		}
+	}
+	return elapsed
+}
+
+// hours returns when business hours open and close on the day of the time, or false on days off
+func (c *Calendar) hours(t time.Time) (time.Time, time.Time, bool) {
+	local := t.In(c.location)
+	if !c.days[local.Weekday()] || c.config.IsHoliday(local) {
+		return time.Time{}, time.Time{}, false
+	}
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, c.location)
+	return midnight.Add(c.start), midnight.Add(c.end), true
+}
+
+// nextDay returns midnight of the day after the time's, in the calendar's time zone
+func (c *Calendar) nextDay(t time.Time) time.Time {
+	local := t.In(c.location)
+	return time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, c.location)
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package businesshours
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+)
+
+func TestCalendar(t *testing.T) {
+	calendar, err := New(config.BusinessHoursConfig{
+		TimeZone: "Europe/Prague",
+		Start:    "09:00",
+		End:      "17:00",
+		Days:     []string{"Monday", "Tuesday", "Wednesday", "Thursday", "Friday"},
+		Holidays: []string{"2024-12-24"},
+	})
+	if err != nil {
+		t.Fatalf("New() unexpected error: %v", err)
+	}
+
+	parse := func(value string) time.Time {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t.Fatalf("failed to parse %s: %v", value, err)
+		}
+		return parsed
+	}
+
+	tests := []struct {
+		name string
+		from string
+		to   string
+		want time.Duration
+	}{
+		{"Friday night to Saturday morning", "2024-12-13T22:00:00+01:00", "2024-12-14T09:00:00+01:00", 0},
+		{"Friday afternoon to Monday morning", "2024-12-13T15:00:00+01:00", "2024-12-16T10:30:00+01:00", 3*time.Hour + 30*time.Minute},
+		{"Across a holiday", "2024-12-23T16:00:00+01:00", "2024-12-25T10:00:00+01:00", 2 * time.Hour},
+		{"Within a day in another time zone", "2024-12-16T09:00:00Z", "2024-12-16T11:00:00Z", 2 * time.Hour},
+		{"Backwards", "2024-12-16T11:00:00Z", "2024-12-16T09:00:00Z", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := calendar.Elapsed(parse(tt.from), parse(tt.to)); got != tt.want {
+				t.Errorf("Elapsed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if calendar.IsOpen(parse("2024-12-14T10:00:00+01:00")) {
+		t.Errorf("IsOpen() = true on a Saturday")
+	}
+	if calendar.IsOpen(parse("2024-12-24T10:00:00+01:00")) {
+		t.Errorf("IsOpen() = true on a holiday")
+	}
+	if !calendar.IsOpen(parse("2024-12-16T16:59:00+01:00")) {
+		t.Errorf("IsOpen() = false during business hours")
+	}
+}
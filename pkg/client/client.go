@@ -0,0 +1,385 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package client is a Go client of the router's HTTP API, for tools and tests that submit alerts, follow their
+// outcomes in the audit log and manage a running router, rather than handcrafting HTTP requests
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/openshift/compliance-audit-router/pkg/audit"
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/health"
+	"github.com/openshift/compliance-audit-router/pkg/splunk"
+)
+
+// Paths of the router's API
+const (
+	alertPath          = "/api/v1/alert"
+	statusPath         = "/api/v1/status"
+	exportPath         = "/api/v1/export"
+	readyPath          = "/readyz"
+	adminTemplatesPath = "/api/v1/admin/templates"
+	adminRollbackPath  = "/api/v1/admin/templates/rollback"
+	adminSettingsPath  = "/api/v1/admin/settings"
+)
+
+const (
+	// correlationIDHeader is the response header with the ID the router logged the request with
+	correlationIDHeader = "X-Correlation-ID"
+	// adminUserHeader names the user making an admin API change
+	adminUserHeader = "X-Admin-User"
+	// spooledResponse is the response to an alert spooled while Jira is unavailable
+	spooledResponse = "spooled"
+	// defaultTimeout limits the requests of clients without an HTTP client of their own
+	defaultTimeout = time.Minute
+)
+
+// Client sends requests to a router. It is safe for concurrent use.
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	apiKey     string
+	adminToken string
+	adminUser  string
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sends the requests with the HTTP client, eg. one with the TLS settings of the router's ingress
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithAPIKey authenticates the requests with an API key of apikeys, which needs the scopes of the endpoints used.
+// A key with the admin:read and admin:write scopes stands in for the admin token.
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.apiKey = key
+	}
+}
+
+// WithAdminToken authenticates the admin API requests with the bearer token of adminconfig.token
+func WithAdminToken(token string) Option {
+	return func(c *Client) {
+		c.adminToken = token
+	}
+}
+
+// WithAdminUser names the user making the admin API changes, recorded in the router's audit log
+func WithAdminUser(user string) Option {
+	return func(c *Client) {
+		c.adminUser = user
+	}
+}
+
+// New returns a client of the router at the base URL, eg. https://car.example.com
+func New(baseURL string, options ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid router URL %q: %w", baseURL, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("invalid router URL %q: must be an http or https URL", baseURL)
+	}
+
+	c := &Client{baseURL: u, httpClient: &http.Client{Timeout: defaultTimeout}}
+	for _, option := range options {
+		option(c)
+	}
+	return c, nil
+}
+
+// Error is a request the router didn't complete, with the status code and message of its response
+type Error struct {
+	StatusCode int
+	Message    string
+	// CorrelationID is the ID the router logged the request with, for finding its logs
+	CorrelationID string
+}
+
+func (e *Error) Error() string {
+	if e.CorrelationID != "" {
+		return fmt.Sprintf("router responded %d %s: %s (correlation ID %s)", e.StatusCode, http.StatusText(e.StatusCode), e.Message, e.CorrelationID)
+	}
+	return fmt.Sprintf("router responded %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// AlertResult is the outcome of an alert the router accepted
+type AlertResult struct {
+	// CorrelationID is the ID the router logged the alert's processing with
+	CorrelationID string
+	// Spooled is set when Jira was unavailable and the alert was spooled to be processed once it recovers
+	Spooled bool
+}
+
+// SubmitAlert sends the Splunk webhook of an alert to the router, for the tenant unless it is empty, and waits
+// for its compliance events to be processed. Alerts the router fails or rejects, eg. while saturated, are
+// returned as an *Error.
+func (c *Client) SubmitAlert(ctx context.Context, tenant string, webhook splunk.Webhook) (AlertResult, error) {
+	body, err := json.Marshal(webhook)
+	if err != nil {
+		return AlertResult{}, err
+	}
+	return c.submit(ctx, tenant, body)
+}
+
+// Replay submits a saved Splunk webhook, eg. from a file, for the tenant unless it is empty, like SubmitAlert.
+// Saved search results are replayed with the router's replay command instead, as the API only takes webhooks.
+func (c *Client) Replay(ctx context.Context, tenant string, saved io.Reader) (AlertResult, error) {
+	var webhook splunk.Webhook
+	if err := json.NewDecoder(saved).Decode(&webhook); err != nil {
+		return AlertResult{}, fmt.Errorf("failed decoding the saved webhook: %w", err)
+	}
+	if webhook.Sid == "" {
+		return AlertResult{}, fmt.Errorf("the saved webhook has no sid")
+	}
+	return c.SubmitAlert(ctx, tenant, webhook)
+}
+
+func (c *Client) submit(ctx context.Context, tenant string, body []byte) (AlertResult, error) {
+	path := alertPath
+	if tenant != "" {
+		path += "/" + url.PathEscape(tenant)
+	}
+
+	resp, err := c.do(ctx, http.MethodPost, path, nil, body, false)
+	if err != nil {
+		return AlertResult{}, err
+	}
+	defer resp.Body.Close()
+
+	message, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return AlertResult{}, err
+	}
+	return AlertResult{
+		CorrelationID: resp.Header.Get(correlationIDHeader),
+		Spooled:       strings.TrimSpace(string(message)) == spooledResponse,
+	}, nil
+}
+
+// AlertQuery selects the audit log records of alerts; empty fields select all records
+type AlertQuery struct {
+	// Alert, User and IssueKey match the alert name, the SRE's username and the Jira issue key
+	Alert    string
+	User     string
+	IssueKey string
+	// Actions are the recorded actions, eg. audit.ActionTicketCreated
+	Actions []string
+	// From and To limit the records to those recorded in the period
+	From time.Time
+	To   time.Time
+}
+
+// GetAlertStatus returns the audit log records of the alerts selected by the query, oldest first: the tickets
+// created for their compliance events, the events skipped, and the approvals of the tickets
+func (c *Client) GetAlertStatus(ctx context.Context, query AlertQuery) ([]audit.Record, error) {
+	values := url.Values{"format": {audit.FormatJSONL}}
+	for name, value := range map[string]string{"alert": query.Alert, "user": query.User, "issue": query.IssueKey} {
+		if value != "" {
+			values.Set(name, value)
+		}
+	}
+	values["action"] = query.Actions
+	if !query.From.IsZero() {
+		values.Set("from", query.From.Format(time.RFC3339))
+	}
+	if !query.To.IsZero() {
+		values.Set("to", query.To.Format(time.RFC3339))
+	}
+
+	resp, err := c.do(ctx, http.MethodGet, exportPath, values, nil, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var records []audit.Record
+	decoder := json.NewDecoder(resp.Body)
+	for decoder.More() {
+		var record audit.Record
+		if err := decoder.Decode(&record); err != nil {
+			return records, fmt.Errorf("failed decoding the audit log records: %w", err)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// Status is the status of the router and its dependencies
+type Status struct {
+	Mode         string                      `json:"mode"`
+	DryRun       bool                        `json:"dryRun"`
+	Dependencies map[string]DependencyStatus `json:"dependencies"`
+	Queues       QueueStatus                 `json:"queues"`
+}
+
+// DependencyStatus is the health of a dependency, with the state of the circuit breaker of each Jira instance
+type DependencyStatus struct {
+	health.Status
+	CircuitBreakers map[string]string `json:"circuitBreakers,omitempty"`
+}
+
+// QueueStatus is the number of alerts and Jira actions waiting to be processed
+type QueueStatus struct {
+	Alerts           int `json:"alerts"`
+	JiraRetryBacklog int `json:"jiraRetryBacklog"`
+	Spool            int `json:"spool"`
+	Outbox           int `json:"outbox"`
+}
+
+// Status returns the status of the router, its dependencies and its queues
+func (c *Client) Status(ctx context.Context) (Status, error) {
+	var status Status
+	return status, c.getJSON(ctx, statusPath, false, &status)
+}
+
+// Ready returns nil when the router is ready to process alerts, or an *Error explaining why it isn't
+func (c *Client) Ready(ctx context.Context) error {
+	resp, err := c.do(ctx, http.MethodGet, readyPath, nil, nil, false)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// Templates are the active message and summary templates, and the templates they replaced when they were
+// updated through the admin API
+type Templates struct {
+	Active   config.Templates  `json:"active"`
+	Previous *config.Templates `json:"previous,omitempty"`
+}
+
+// Templates returns the active message and summary templates. It requires the admin token.
+func (c *Client) Templates(ctx context.Context) (Templates, error) {
+	var templates Templates
+	return templates, c.getJSON(ctx, adminTemplatesPath, true, &templates)
+}
+
+// UpdateTemplates replaces the message and summary templates that are set, until the router reloads its
+// configuration. It requires the admin token.
+func (c *Client) UpdateTemplates(ctx context.Context, update config.Templates) (Templates, error) {
+	var templates Templates
+	return templates, c.sendJSON(ctx, http.MethodPut, adminTemplatesPath, update, &templates)
+}
+
+// RollbackTemplates restores the templates replaced by the last update. It requires the admin token.
+func (c *Client) RollbackTemplates(ctx context.Context) (Templates, error) {
+	var templates Templates
+	return templates, c.sendJSON(ctx, http.MethodPost, adminRollbackPath, nil, &templates)
+}
+
+// Settings returns the dry-run mode, verbosity and log levels of the router. It requires the admin token.
+func (c *Client) Settings(ctx context.Context) (config.RuntimeSettings, error) {
+	var settings config.RuntimeSettings
+	return settings, c.getJSON(ctx, adminSettingsPath, true, &settings)
+}
+
+// UpdateSettings changes the settings that are set, until the router reloads its configuration, and returns
+// the resulting settings. It requires the admin token.
+func (c *Client) UpdateSettings(ctx context.Context, update config.RuntimeSettings) (config.RuntimeSettings, error) {
+	var settings config.RuntimeSettings
+	return settings, c.sendJSON(ctx, http.MethodPut, adminSettingsPath, update, &settings)
+}
+
+func (c *Client) getJSON(ctx context.Context, path string, admin bool, response interface{}) error {
+	resp, err := c.do(ctx, http.MethodGet, path, nil, nil, admin)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(response)
+}
+
+func (c *Client) sendJSON(ctx context.Context, method, path string, request, response interface{}) error {
+	var body []byte
+	if request != nil {
+		var err error
+		if body, err = json.Marshal(request); err != nil {
+			return err
+		}
+	}
+
+	resp, err := c.do(ctx, method, path, nil, body, true)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(response)
+}
+
+// do sends the request, with the API key, or the admin token and user for admin API requests, and returns the response when it
+// is successful, or an *Error with the router's message
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body []byte, admin bool) (*http.Response, error) {
+	u := *c.baseURL
+	u.Path += path
+	u.RawQuery = query.Encode()
+
+	var reader io.Reader = http.NoBody
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	if admin {
+		switch {
+		case c.adminToken != "":
+			req.Header.Set("Authorization", "Bearer "+c.adminToken)
+		case c.apiKey == "":
+			return nil, fmt.Errorf("the admin API requires an admin token or an API key")
+		}
+		if c.adminUser != "" {
+			req.Header.Set(adminUserHeader, c.adminUser)
+		}
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return nil, &Error{
+		StatusCode:    resp.StatusCode,
+		Message:       strings.TrimSpace(string(message)),
+		CorrelationID: resp.Header.Get(correlationIDHeader),
+	}
+}
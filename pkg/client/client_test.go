@@ -0,0 +1,128 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openshift/compliance-audit-router/pkg/audit"
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/splunk"
+)
+
+func TestSubmitAlert(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var webhook splunk.Webhook
+		_ = json.NewDecoder(r.Body).Decode(&webhook)
+		w.Header().Set(correlationIDHeader, "abc")
+		switch {
+		case r.URL.Path == alertPath+"/team-a" && webhook.Sid == "spooled":
+			_, _ = io.WriteString(w, "spooled")
+		case r.URL.Path == alertPath+"/team-a":
+			_, _ = io.WriteString(w, "ok")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = io.WriteString(w, "Unknown tenant")
+		}
+	}))
+	defer server.Close()
+
+	c, err := New(server.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := c.SubmitAlert(context.Background(), "team-a", splunk.Webhook{Sid: "123"})
+	if err != nil || result.CorrelationID != "abc" || result.Spooled {
+		t.Errorf("SubmitAlert() = %+v, %v, want a processed alert", result, err)
+	}
+
+	result, err = c.Replay(context.Background(), "team-a", strings.NewReader(`{"sid":"spooled"}`))
+	if err != nil || !result.Spooled {
+		t.Errorf("Replay() = %+v, %v, want a spooled alert", result, err)
+	}
+
+	var apiErr *Error
+	_, err = c.SubmitAlert(context.Background(), "team-b", splunk.Webhook{Sid: "123"})
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound || apiErr.Message != "Unknown tenant" || apiErr.CorrelationID != "abc" {
+		t.Errorf("SubmitAlert() error = %v, want the router's 404", err)
+	}
+}
+
+func TestGetAlertStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if r.URL.Path != exportPath || query.Get("format") != audit.FormatJSONL || query.Get("alert") != "elevation" || query.Get("user") != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = io.WriteString(w, `{"action":"ticket_created","issueKey":"CAR-1","details":{"alert":"elevation"}}`+"\n"+
+			`{"action":"transitioned","issueKey":"CAR-1","details":{"status":"Done"}}`+"\n")
+	}))
+	defer server.Close()
+
+	c, _ := New(server.URL)
+	records, err := c.GetAlertStatus(context.Background(), AlertQuery{Alert: "elevation"})
+	if err != nil || len(records) != 2 || records[1].Details["status"] != "Done" {
+		t.Errorf("GetAlertStatus() = %+v, %v, want the ticket's two records", records, err)
+	}
+}
+
+func TestAdminRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" || r.Header.Get(adminUserHeader) != "alice" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var settings config.RuntimeSettings
+		_ = json.NewDecoder(r.Body).Decode(&settings)
+		_ = json.NewEncoder(w).Encode(settings)
+	}))
+	defer server.Close()
+
+	unauthenticated, _ := New(server.URL)
+	if _, err := unauthenticated.Settings(context.Background()); err == nil {
+		t.Errorf("Settings() without an admin token error = nil")
+	}
+
+	c, _ := New(server.URL, WithAdminToken("secret"), WithAdminUser("alice"))
+	dryRun := false
+	settings, err := c.UpdateSettings(context.Background(), config.RuntimeSettings{DryRun: &dryRun})
+	if err != nil || settings.DryRun == nil || *settings.DryRun {
+		t.Errorf("UpdateSettings() = %+v, %v, want dry-run disabled", settings, err)
+	}
+
+	withAPIKey, _ := New(server.URL, WithAPIKey("secret"), WithAdminUser("alice"))
+	if _, err := withAPIKey.Settings(context.Background()); err != nil {
+		t.Errorf("Settings() with an API key error = %v", err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, baseURL := range []string{"", "car.example.com", "ftp://car.example.com"} {
+		if _, err := New(baseURL); err == nil {
+			t.Errorf("New(%q) error = nil", baseURL)
+		}
+	}
+}
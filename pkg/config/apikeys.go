@@ -0,0 +1,132 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"slices"
+)
+
+// The scopes of API keys, each allowing a group of the router's endpoints
+const (
+	// ScopeAlertSubmit allows sending and validating Splunk alert webhooks
+	ScopeAlertSubmit = "alert:submit"
+	// ScopeJiraWebhook allows sending Jira issue webhooks
+	ScopeJiraWebhook = "jira:webhook"
+	// ScopeAuditRead allows exporting the audit log
+	ScopeAuditRead = "audit:read"
+	// ScopeConfigRead allows reading the effective configuration and the status of the dependencies
+	ScopeConfigRead = "config:read"
+	// ScopeAdminRead allows reading the templates and settings of the admin API
+	ScopeAdminRead = "admin:read"
+	// ScopeAdminWrite allows changing the templates and settings through the admin API
+	ScopeAdminWrite = "admin:write"
+)
+
+// Scopes are the scopes API keys may have
+var Scopes = []string{ScopeAlertSubmit, ScopeJiraWebhook, ScopeAuditRead, ScopeConfigRead, ScopeAdminRead, ScopeAdminWrite}
+
+// apiKeyPrefix starts the generated API keys, so leaked keys are recognizable by secret scanners
+const apiKeyPrefix = "car_"
+
+// APIKey authenticates the requests of a client of the router's API, eg. a Splunk instance sending alerts,
+// for its scopes. Only the hash of the key is configured, so the configuration doesn't hold the key itself.
+// Removing a client's key, and reloading the configuration, revokes its access alone.
+type APIKey struct {
+	// Name identifies the key's holder in logs, metrics and the audit log
+	Name string
+	// Hash is the hex-encoded SHA-256 hash of the key
+	Hash string
+	// Scopes are the endpoints the key may be used for, eg. alert:submit
+	Scopes []string
+}
+
+// Allows reports whether the key has the scope
+func (k APIKey) Allows(scope string) bool {
+	return slices.Contains(k.Scopes, scope)
+}
+
+// NewAPIKey generates a random API key, returning the key and the hash to configure
+func NewAPIKey() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("failed generating an API key: %w", err)
+	}
+	key := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return key, HashAPIKey(key), nil
+}
+
+// HashAPIKey returns the hash of the API key, as configured in apikeys
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// APIKeyFor returns the configured API key the bearer token of a request is, if any
+func (a *Config) APIKeyFor(token string) (APIKey, bool) {
+	if token == "" {
+		return APIKey{}, false
+	}
+	sum := sha256.Sum256([]byte(token))
+	for _, key := range a.APIKeys {
+		hash, err := hex.DecodeString(key.Hash)
+		if err == nil && subtle.ConstantTimeCompare(hash, sum[:]) == 1 {
+			return key, true
+		}
+	}
+	return APIKey{}, false
+}
+
+// apiKeysAreValid tests that the API keys have unique names, SHA-256 hashes and known scopes
+func apiKeysAreValid(a *Config) []error {
+	var keyErrors []error
+
+	names := map[string]bool{}
+	hashes := map[string]bool{}
+	for i, key := range a.APIKeys {
+		if key.Name == "" {
+			keyErrors = append(keyErrors, configError{Err: fmt.Sprintf("missing required configuration value: apikeys[%d].name", i)})
+		} else if names[key.Name] {
+			keyErrors = append(keyErrors, configError{Err: fmt.Sprintf("apikeys[%d].name is not unique: %s", i, key.Name)})
+		}
+		names[key.Name] = true
+
+		if hash, err := hex.DecodeString(key.Hash); err != nil || len(hash) != sha256.Size {
+			keyErrors = append(keyErrors, configError{Err: fmt.Sprintf("apikeys[%d].hash must be a hex-encoded SHA-256 hash", i)})
+		} else if hashes[string(hash)] {
+			keyErrors = append(keyErrors, configError{Err: fmt.Sprintf("apikeys[%d].hash is not unique", i)})
+		} else {
+			hashes[string(hash)] = true
+		}
+
+		if len(key.Scopes) == 0 {
+			keyErrors = append(keyErrors, configError{Err: fmt.Sprintf("missing required configuration value: apikeys[%d].scopes", i)})
+		}
+		for _, scope := range key.Scopes {
+			if !slices.Contains(Scopes, scope) {
+				keyErrors = append(keyErrors, configError{Err: fmt.Sprintf("apikeys[%d].scopes has an unknown scope: %s, must be one of %v", i, scope, Scopes)})
+			}
+		}
+	}
+
+	return keyErrors
+}
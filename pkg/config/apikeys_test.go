@@ -0,0 +1,72 @@
+// Copyright 2021-2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAPIKeyFor(t *testing.T) {
+	key, hash, err := NewAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(key, apiKeyPrefix) || hash != HashAPIKey(key) {
+		t.Fatalf("NewAPIKey() = %v, %v, want a prefixed key and its hash", key, hash)
+	}
+
+	config := &Config{APIKeys: []APIKey{
+		{Name: "other", Hash: HashAPIKey("other-key"), Scopes: []string{ScopeAuditRead}},
+		{Name: "splunk", Hash: strings.ToUpper(hash), Scopes: []string{ScopeAlertSubmit}},
+	}}
+
+	got, ok := config.APIKeyFor(key)
+	if !ok || got.Name != "splunk" || !got.Allows(ScopeAlertSubmit) || got.Allows(ScopeAdminWrite) {
+		t.Errorf("APIKeyFor() = %+v, %v, want the splunk key with its scopes", got, ok)
+	}
+	for _, token := range []string{"", "wrong-key", hash} {
+		if got, ok := config.APIKeyFor(token); ok {
+			t.Errorf("APIKeyFor(%q) = %+v, want no key", token, got)
+		}
+	}
+}
+
+func TestAPIKeysAreValid(t *testing.T) {
+	config := &Config{APIKeys: []APIKey{
+		{Name: "splunk", Hash: HashAPIKey("a"), Scopes: []string{ScopeAlertSubmit}},
+		{Name: "splunk", Hash: HashAPIKey("a"), Scopes: []string{"alert:delete"}},
+		{Hash: "not-a-hash"},
+	}}
+
+	got := apiKeysAreValid(config)
+	want := []error{
+		configError{Err: "apikeys[1].name is not unique: splunk"},
+		configError{Err: "apikeys[1].hash is not unique"},
+		configError{Err: "apikeys[1].scopes has an unknown scope: alert:delete, must be one of [alert:submit jira:webhook audit:read config:read admin:read admin:write]"},
+		configError{Err: "missing required configuration value: apikeys[2].name"},
+		configError{Err: "apikeys[2].hash must be a hex-encoded SHA-256 hash"},
+		configError{Err: "missing required configuration value: apikeys[2].scopes"},
+	}
+	if len(got) != len(want) {
+		t.Errorf("apiKeysAreValid() = %v, want %v", got, want)
+	}
+	for _, err := range want {
+		if !slices.Contains(got, err) {
+			t.Errorf("apiKeysAreValid() missing expected error: %+v", err)
+		}
+	}
+}
@@ -18,87 +18,1094 @@ package config
 
 import (
 	"fmt"
-	"html/template"
 	"log"
 	"net/url"
 	"os"
 	"reflect"
+	"regexp"
 	"strings"
+	"sync/atomic"
+	"text/template"
+	"time"
 
+	"github.com/go-ldap/ldap"
 	"github.com/spf13/viper"
+	"golang.org/x/exp/slices"
+
+	"github.com/openshift/compliance-audit-router/pkg/helpers"
 )
 
 // Package config loads configuration details so they can be accessed
 // by other packages
 
 var Appname = "compliance-audit-router"
+
+// identityconfig.provider values
+const (
+	IdentityProviderLDAP   = "ldap"
+	IdentityProviderOkta   = "okta"
+	IdentityProviderAzure  = "azure"
+	IdentityProviderGoogle = "google"
+)
+
+// Modes of operation, see Config.Mode
+const (
+	ModeDryRun     = "dry-run"
+	ModeProduction = "production"
+
+	// productionConfirmation must be set as the productionconfirmation to disable dry-run,
+	// so production mode can't be enabled by flipping dryrun alone
+	productionConfirmation = "create-jira-issues"
+)
+
+// enrichers[].type values
+const (
+	EnricherTypeHTTP = "http"
+)
+
+// oncallconfig.provider values
+const (
+	OnCallProviderPagerDuty = "pagerduty"
+)
+
+// oncallconfig.action values, for the alerts of users on call for an incident on the cluster
+const (
+	// OnCallActionAnnotate notes the incident on the ticket
+	OnCallActionAnnotate = "annotate"
+	// OnCallActionFastTrack notes the incident and moves the ticket straight to the manager's approval
+	OnCallActionFastTrack = "fasttrack"
+	// OnCallActionSkip doesn't create a ticket
+	OnCallActionSkip = "skip"
+)
+
+// logconfig.levels values
+const (
+	LogLevelDebug = "debug"
+	LogLevelInfo  = "info"
+)
+
+// logPackages are the packages whose verbose logging can be set in logconfig.levels
+var logPackages = []string{"listeners", "splunk", "jira", "ldap"}
+
+// transitionKeys are the workflow steps named in jiraconfig.transitions
+var transitionKeys = []string{"initial", "sre", "manager"}
+
 var defaultMessageTemplate = "{{.Username}}\n\n" +
 	"This action requires justification." +
 	"Please provide the justification in the comments section below."
+var defaultSummaryTemplate = "Compliance Alert: SRE Cluster Admin Elevation" +
+	"{{with .User}} by {{.}}{{end}}" +
+	"{{with .ClusterIDs}} on {{range $i, $id := .}}{{if $i}}, {{end}}{{$id}}{{end}}{{end}}"
+var defaultReminderTemplate = "{{.Assignee}}\n\n" +
+	"This compliance ticket has had no activity for {{.IdleFor}} and is still waiting on you. " +
+	"Please provide the requested justification or approval in the comments section below."
+var defaultLDAPUserFilter = "({{.UIDAttribute}}={{.Username}})"
+var defaultLDAPGroupFilter = "(&({{.UIDAttribute}}={{.Username}})(memberOf={{.Group}}))"
+var defaultLDAPMailFilter = "({{.MailAttribute}}={{.Username}})"
+
+// appConfig is the active configuration. A configuration is never changed once it is published, so
+// requests read it without locking while a reload or a runtime change publishes the next one.
+var appConfig atomic.Pointer[Config]
+
+// AppConfig returns the active configuration, which must not be changed. Requests read it once and use
+// that configuration throughout, so a reload doesn't change their settings halfway.
+func AppConfig() *Config {
+	if a := appConfig.Load(); a != nil {
+		return a
+	}
+	return &Config{}
+}
 
-var AppConfig Config
+// SetAppConfig publishes the configuration as the active one. The configuration, including its maps
+// and slices, must not be changed afterwards.
+func SetAppConfig(a Config) {
+	appConfig.Store(&a)
+}
 
 var keys = []string{
 	"splunkconfig.host",
 	"splunkconfig.allowinsecure",
 	"splunkconfig.token",
+	"splunkconfig.tokenfile",
+	"splunkconfig.timeformats",
+	"splunkconfig.maxresultssize",
+	"backfillconfig.search",
+	"backfillconfig.pagesize",
 	"jiraconfig.host",
 	"jiraconfig.token",
+	"jiraconfig.tokenfile",
 	"jiraconfig.allowinsecure",
 	"jiraconfig.username",
 	"jiraconfig.key",
 	"jiraconfig.issuetype",
 	"jiraconfig.transitions",
+	"jiraconfig.components",
+	"jiraconfig.watchmanager",
+	"jiraconfig.requestparticipants",
+	"jiraconfig.documentformat",
+	"jiraconfig.validateonstartup",
+	"jiraconfig.bulkcreate",
+	"jiraconfig.securitylevel",
+	"jiraconfig.epic",
+	"jiraconfig.monthlyepic",
+	"jiraconfig.epiclinkfield",
+	"jiraconfig.epicnamefield",
+	"jiraconfig.minjustificationlength",
+	"jiraconfig.justificationpattern",
+	"jiraconfig.justificationtemplate",
+	"jiraconfig.labelprefix",
+	"jiraconfig.labelseparator",
+	"jiraconfig.ratelimit",
+	"jiraconfig.ratelimitburst",
+	"jiraconfig.maxretries",
+	"jiraconfig.board",
+	"jiraconfig.sprint",
+	"jiraconfig.fields",
+	"jiraconfig.incidentprojects",
+	"jiraconfig.incidentjql",
+	"jiraconfig.incidentwindow",
+	"jiraconfig.incidentlinktype",
+	"jiraconfig.usercachettl",
 	"ldapconfig.host",
+	"ldapconfig.hosts",
+	"ldapconfig.hostselection",
 	"ldapconfig.allowinsecure",
 	"ldapconfig.username",
 	"ldapconfig.password",
+	"ldapconfig.passwordfile",
 	"ldapconfig.searchbase",
 	"ldapconfig.scope",
 	"ldapconfig.attributes",
 	"ldapconfig.enabled",
+	"ldapconfig.userfilter",
+	"ldapconfig.groupfilter",
+	"ldapconfig.mailfilter",
+	"ldapconfig.attributemap.uid",
+	"ldapconfig.attributemap.manager",
+	"ldapconfig.attributemap.mail",
+	"ldapconfig.attributemap.rdn",
+	"ldapconfig.poolsize",
+	"ldapconfig.idletimeout",
+	"ldapconfig.pagesize",
+	"ldapconfig.cachettl",
+	"ldapconfig.cachesize",
+	"identityconfig.provider",
+	"identityconfig.managerchaindepth",
+	"identityconfig.requiredgroup",
+	"identityconfig.nonmemberrouting",
+	"identityconfig.nonmemberassignee",
+	"identityconfig.kerberosrealms",
+	"identityconfig.domainmap",
+	"oktaconfig.orgurl",
+	"oktaconfig.token",
+	"oktaconfig.usernameattribute",
+	"oktaconfig.managerattribute",
+	"azureconfig.tenantid",
+	"azureconfig.clientid",
+	"azureconfig.clientsecret",
+	"azureconfig.domain",
+	"azureconfig.usernameattribute",
+	"azureconfig.authorityurl",
+	"azureconfig.graphurl",
+	"googleconfig.credentialsfile",
+	"googleconfig.subject",
+	"googleconfig.domain",
+	"googleconfig.directoryurl",
+	"configtype",
+	"configdirs",
 	"verbose",
+	"redactfields",
+	"logconfig.levels",
+	"logconfig.sampleevery",
 	"dryrun",
+	"productionconfirmation",
+	"dryrunconfig.transitions",
+	"dryrunconfig.comments",
+	"dryrunconfig.updates",
 	"listenport",
+	"readinesscachettl",
 	"messagetemplate",
+	"messagetemplates",
+	"summarytemplate",
+	"routing",
+	"jirainstances",
+	"tenants",
+	"enrichers",
+	"readonlyconfig.enabled",
+	"readonlyconfig.verbs",
+	"riskconfig.rules",
+	"riskconfig.levels",
+	"riskconfig.field",
+	"changeconfig.pattern",
+	"changeconfig.jira",
+	"changeconfig.approvedstatuses",
+	"oncallconfig.provider",
+	"oncallconfig.url",
+	"oncallconfig.token",
+	"oncallconfig.emaildomain",
+	"oncallconfig.action",
+	"oncallconfig.timeout",
+	"aggregationconfig.window",
+	"aggregationconfig.dir",
+	"dedupconfig.window",
+	"breakerconfig.threshold",
+	"breakerconfig.cooldown",
+	"spoolconfig.dir",
+	"spoolconfig.draininterval",
+	"spoolconfig.maxage",
+	"adminconfig.token",
+	"apikeys",
+	"outboxconfig.dir",
+	"outboxconfig.interval",
+	"floodconfig.maxtickets",
+	"floodconfig.window",
+	"auditconfig.path",
+	"retentionconfig.auditdays",
+	"retentionconfig.interval",
+	"digestconfig.alertnames",
+	"digestconfig.time",
+	"digestconfig.dir",
+	"pipelineconfig.workers",
+	"pipelineconfig.queuesize",
+	"pipelineconfig.jiraparallelism",
+	"pipelineconfig.alerttimeout",
+	"pipelineconfig.splunktimeout",
+	"pipelineconfig.identitytimeout",
+	"pipelineconfig.jiratimeout",
+	"pipelineconfig.unreadyqueuedepth",
+	"pipelineconfig.unreadyretrybacklog",
+	"businesshoursconfig.enabled",
+	"businesshoursconfig.timezone",
+	"businesshoursconfig.start",
+	"businesshoursconfig.end",
+	"businesshoursconfig.days",
+	"businesshoursconfig.holidays",
+	"reminderconfig.enabled",
+	"reminderconfig.interval",
+	"reminderconfig.idlefor",
+	"reminderconfig.maxcount",
+	"reminderconfig.template",
 }
 
 type Config struct {
-	Verbose         bool
-	DryRun          bool
-	ListenPort      int
-	MessageTemplate string
+	Verbose bool
+	// RedactFields are the names of fields masked in verbose logs, in addition to credentials, eg. personal data
+	RedactFields []string
+	LogConfig    LogConfig
+	DryRun       bool
+	ListenPort   int
+	// ReadinessCacheTTL is how long the result of a deep readiness check is reused, 0 to check on every probe
+	ReadinessCacheTTL time.Duration
+	MessageTemplate   string
+	SummaryTemplate   string
+	// MessageTemplates override the MessageTemplate by alert name, eg. to ask different justification questions
+	MessageTemplates []MessageTemplateRule
+
+	// ProductionConfirmation must be "create-jira-issues" when DryRun is false
+	ProductionConfirmation string
+	DryRunConfig           DryRunConfig
+
+	IdentityConfig IdentityConfig
+	LDAPConfig     LDAPConfig
+	OktaConfig     OktaConfig
+	AzureConfig    AzureConfig
+	GoogleConfig   GoogleConfig
+	SplunkConfig   SplunkConfig
+	JiraConfig     JiraConfig
+	ReminderConfig ReminderConfig
+	PipelineConfig PipelineConfig
+
+	AuditConfig         AuditConfig
+	RetentionConfig     RetentionConfig
+	BackfillConfig      BackfillConfig
+	AggregationConfig   AggregationConfig
+	FloodConfig         FloodConfig
+	DedupConfig         DedupConfig
+	BreakerConfig       BreakerConfig
+	SpoolConfig         SpoolConfig
+	OutboxConfig        OutboxConfig
+	AdminConfig         AdminConfig
+	DigestConfig        DigestConfig
+	BusinessHoursConfig BusinessHoursConfig
+
+	// JiraInstances are additional named Jira endpoints that routing rules may select
+	JiraInstances map[string]JiraConfig
+	Routing       []RoutingRule
+
+	// Tenants are the named teams sharing the router, each with its own Jira project, templates and identity settings
+	Tenants map[string]TenantConfig
+
+	// APIKeys authenticate the clients of the router's API, each for its scopes
+	APIKeys []APIKey
+
+	// Enrichers add context to each compliance event before its ticket is created, in order
+	Enrichers []EnricherConfig
+
+	OnCallConfig OnCallConfig
+	ChangeConfig ChangeConfig
+	RiskConfig   RiskConfig
+
+	ReadOnlyConfig ReadOnlyConfig
+}
 
-	LDAPConfig   LDAPConfig
-	SplunkConfig SplunkConfig
-	JiraConfig   JiraConfig
+// LogConfig tunes verbose logging per package, so troubleshooting one integration doesn't flood the logs
+type LogConfig struct {
+	// Levels override Verbose for the packages, eg. {"jira": "debug"}; "debug" logs verbosely and "info" doesn't
+	Levels map[string]string
+	// SampleEvery logs one of every SampleEvery verbose log lines of each package; 0 or 1 logs them all
+	SampleEvery int
+}
+
+// DryRunConfig limits dry-run to some of the Jira changes while DryRun is false, eg. to create issues
+// for real but only log their transitions during a staged rollout
+type DryRunConfig struct {
+	// Transitions logs issue transitions instead of making them
+	Transitions bool
+	// Comments logs comments, including reminders, instead of posting them
+	Comments bool
+	// Updates logs the other changes made around created issues instead of making them:
+	// watchers, monthly epics, sprints, incident links and the incomplete label
+	Updates bool
+}
+
+// IdentityConfig configures how alerting users are resolved to their identity and manager
+type IdentityConfig struct {
+	// Provider is the identity provider used to resolve users. Defaults to "ldap" when LDAPConfig is enabled.
+	Provider string
+
+	// ManagerChainDepth is the number of managers above the direct manager to look up for escalation
+	ManagerChainDepth int
+
+	// RequiredGroup is the group alerting users are expected to be members of, eg. the SRE group
+	RequiredGroup string
+	// NonMemberRouting overrides the Jira settings for alerts from users outside the RequiredGroup,
+	// eg. to create a security review ticket in another project. Its match fields are not used.
+	NonMemberRouting RoutingRule
+	// NonMemberAssignee is the user assigned tickets for users outside the RequiredGroup, instead of the user
+	NonMemberAssignee string
+
+	// KerberosRealms are the realms stripped from Kerberos principals in alerts, eg. EXAMPLE.ORG for user@EXAMPLE.ORG
+	KerberosRealms []string
+	// DomainMap rewrites the domains of email addresses in alerts, eg. from a legacy domain to the directory's.
+	// Mapping a domain to an empty string strips it.
+	DomainMap map[string]string
 }
 
 type LDAPConfig struct {
-	Host          string
+	Host string
+	// Hosts are replicas of Host tried when it is unavailable
+	Hosts []string
+	// HostSelection is the order the hosts are tried in, "ordered" or "roundrobin"
+	HostSelection string
+
 	AllowInsecure bool
 	Username      string
 	Password      string
+	PasswordFile  string
 	SearchBase    string
 	Scope         string
 	Attributes    []string
 	Enabled       bool
+
+	// UserFilter, GroupFilter and MailFilter are the templates of the filters used to search for a user,
+	// to check a user's group membership and to search for a user by email address, rendered with
+	// the FilterData of the ldap package
+	UserFilter   string
+	GroupFilter  string
+	MailFilter   string
+	AttributeMap LDAPAttributeMap
+
+	// PoolSize is the maximum number of connections kept open to the LDAP server
+	PoolSize int
+	// IdleTimeout is how long a pooled connection may be unused before it is closed
+	IdleTimeout time.Duration
+	// PageSize is the number of entries requested per page of search results, 0 to disable paging
+	PageSize int
+
+	// CacheTTL is how long user lookup results are cached, 0 to disable caching
+	CacheTTL  time.Duration
+	CacheSize int
+}
+
+// OktaConfig configures the Okta identity provider
+type OktaConfig struct {
+	OrgURL string
+	// Token is an Okta API token with read access to users and groups
+	Token string
+	// UsernameAttribute and ManagerAttribute are the profile attributes holding the username
+	// and the manager's Okta ID or login
+	UsernameAttribute string
+	ManagerAttribute  string
+}
+
+// AzureConfig configures the Microsoft Graph identity provider, for organizations on Entra ID (Azure AD)
+type AzureConfig struct {
+	TenantID     string
+	ClientID     string
+	ClientSecret string
+	// Domain qualifies usernames without a domain into user principal names
+	Domain string
+	// UsernameAttribute is the user attribute used as the username: userPrincipalName, mail or mailNickname
+	UsernameAttribute string
+
+	// AuthorityURL and GraphURL are the Entra ID and Graph API endpoints, for national clouds
+	AuthorityURL string
+	GraphURL     string
+}
+
+// GoogleConfig configures the Google Workspace identity provider
+type GoogleConfig struct {
+	// CredentialsFile is the path to the key file of a service account with domain-wide delegation
+	CredentialsFile string
+	// Subject is the email address of the administrator the service account acts on behalf of
+	Subject string
+	// Domain qualifies usernames without a domain into email addresses
+	Domain string
+	// DirectoryURL is the Admin SDK endpoint
+	DirectoryURL string
+}
+
+// LDAPAttributeMap names the attributes holding user details in the directory schema
+type LDAPAttributeMap struct {
+	UID     string
+	Manager string
+	Mail    string
+	// RDN are the attributes the username is read from in DNs, tried in order, eg. cn or sAMAccountName
+	// for Active Directory. Defaults to the UID attribute.
+	RDN []string
+}
+
+// RDNAttributes returns the attributes the username is read from in DNs
+func (m LDAPAttributeMap) RDNAttributes() []string {
+	if len(m.RDN) == 0 {
+		return []string{m.UID}
+	}
+	return m.RDN
+}
+
+// Servers returns the LDAP servers in configured order, starting with Host
+func (l LDAPConfig) Servers() []string {
+	var servers []string
+	if l.Host != "" {
+		servers = append(servers, l.Host)
+	}
+	return append(servers, l.Hosts...)
 }
 
 type SplunkConfig struct {
 	Host          string
 	Token         string
+	TokenFile     string
 	AllowInsecure bool
+	// TimeFormats are the Go layouts the timestamps of search results are parsed with, in order,
+	// or "unix" for seconds since the epoch
+	TimeFormats []string
+	// MaxResultsSize is the largest search results response, in bytes, the router reads; 0 for no limit
+	MaxResultsSize int64
 }
 
 type JiraConfig struct {
-	Host          string
-	AllowInsecure bool
-	Token         string
-	Username      string
+	Host              string
+	AllowInsecure     bool
+	Token             string
+	TokenFile         string
+	Username          string
+	Key               string
+	IssueType         string
+	Transitions       map[string]string
+	Components        []string
+	WatchManager      bool
+	DocumentFormat    string
+	ValidateOnStartup bool
+	BulkCreate        bool
+	SecurityLevel     string
+	Epic              string
+	MonthlyEpic       bool
+	EpicLinkField     string
+	EpicNameField     string
+
+	// RequestParticipants adds the manager as a participant of the request as well, for the issues of
+	// Jira Service Management projects, whose customers are notified as participants rather than watchers
+	RequestParticipants bool
+
+	MinJustificationLength int
+	// JustificationPattern is a regular expression the SRE's justification must match, eg. a link to an OHSS ticket
+	JustificationPattern string
+	// JustificationTemplate is the Go template of the comment asking for a proper justification
+	// when the SRE's comment doesn't meet the length or pattern
+	JustificationTemplate string
+
+	// LabelPrefix and LabelSeparator form the labels tracking managed issues,
+	// eg: <prefix>/managed and <prefix>/sre<separator><account ID>
+	LabelPrefix    string
+	LabelSeparator string
+
+	// RateLimit is the maximum number of requests per second sent to the Jira instance, 0 for no limit
+	RateLimit      float64
+	RateLimitBurst int
+	// MaxRetries is the number of times a request rejected with 429 Too Many Requests is retried
+	MaxRetries int
+
+	// Sprint is the ID of the sprint new issues are added to. When it is not set,
+	// new issues are added to the active sprint of the Board, if one is set.
+	Sprint int
+	Board  int
+	// Fields are additional fields set on new issues by field ID, eg. a Jira Service Management request type
+	Fields map[string]interface{}
+
+	// IncidentProjects are the projects searched for open incidents on the alert's clusters,
+	// which new issues are linked to; no projects disables the search
+	IncidentProjects []string
+	// IncidentJQL further restricts the incidents searched for, eg. issuetype = Incident
+	IncidentJQL string
+	// IncidentWindow is how long before and after the alert an incident must have been active
+	IncidentWindow time.Duration
+	// IncidentLinkType is the name of the issue link type new issues are linked to incidents with
+	IncidentLinkType string
+
+	// UserCacheTTL is how long the Jira accounts of the SREs and managers are cached, 0 to disable caching
+	UserCacheTTL time.Duration
+}
+
+// PipelineConfig tunes the throughput of alert processing to the tolerance of the Jira instances
+type PipelineConfig struct {
+	// Workers is the number of alerts processed at once
+	Workers int
+	// QueueSize is the number of alerts waiting for a worker before further alerts are rejected
+	QueueSize int
+	// JiraParallelism is the number of compliance events of an alert processed at once
+	JiraParallelism int
+	// AlertTimeout is how long an alert may take to be processed, including the time waiting
+	// for a worker, before it is cancelled. The timeouts below limit each stage of an alert; 0 disables a timeout.
+	AlertTimeout time.Duration
+	// SplunkTimeout is how long retrieving the search results of an alert from Splunk may take
+	SplunkTimeout time.Duration
+	// IdentityTimeout is how long resolving the user of a compliance event may take
+	IdentityTimeout time.Duration
+	// JiraTimeout is how long creating the Jira ticket of a compliance event may take
+	JiraTimeout time.Duration
+	// UnreadyQueueDepth is the number of alerts waiting for a worker beyond which the router reports it
+	// isn't ready, so new webhooks are sent to other replicas; 0 disables the check
+	UnreadyQueueDepth int
+	// UnreadyRetryBacklog is the number of Jira requests waiting to be retried after being rate limited
+	// beyond which the router reports it isn't ready; 0 disables the check
+	UnreadyRetryBacklog int
+}
+
+// AuditConfig configures the tamper-evident log of the router's decisions
+type AuditConfig struct {
+	// Path is the file the decisions are appended to, or empty to not record them
+	Path string
+}
+
+// RetentionConfig configures how long stored data, which includes personal data such as usernames, is kept
+type RetentionConfig struct {
+	// AuditDays is the number of days audit log records are kept, or 0 to keep them forever
+	AuditDays int
+	// Interval is how often expired records are purged
+	Interval time.Duration
+}
+
+// BackfillConfig configures the Splunk search the backfill command processes the compliance events of
+type BackfillConfig struct {
+	// Search is the Splunk search returning the compliance events, like the search of the alerts
+	Search string
+	// PageSize is the number of search results retrieved and processed at a time, 0 to retrieve them all at once
+	PageSize int
+}
+
+// AggregationConfig configures the aggregation of the compliance events of one troubleshooting session
+// into a single ticket
+type AggregationConfig struct {
+	// Window is how long the events of a user on a cluster are buffered, from the first, before their
+	// combined ticket is created; 0 creates a ticket for each event right away
+	Window time.Duration
+	// Dir is the directory the buffered events are persisted to until their ticket is created, so they
+	// survive a restart; empty keeps them in memory only
+	Dir string
+}
+
+// BreakerConfig configures the circuit breakers of the Jira instances, which fail requests right away
+// while an instance is unavailable
+type BreakerConfig struct {
+	// Threshold is the number of consecutive failed requests to a Jira instance that open its breaker; 0 disables the breakers
+	Threshold int
+	// Cooldown is how long a breaker stays open before a request is sent to check whether the instance recovered
+	Cooldown time.Duration
+}
+
+// SpoolConfig configures the spool alerts are persisted to while Jira is unavailable
+type SpoolConfig struct {
+	// Dir is the directory alerts are spooled to while the Jira circuit breaker is open; empty disables spooling
+	Dir string
+	// DrainInterval is how often the spooled alerts are processed once Jira recovers
+	DrainInterval time.Duration
+	// MaxAge is how long an alert is kept in the spool before it is dropped; 0 keeps it until it is processed
+	MaxAge time.Duration
+}
+
+// AdminConfig configures the admin API, eg. updating the message templates at runtime
+type AdminConfig struct {
+	// Token is the bearer token authenticating requests to the admin API; empty disables the admin API
+	Token string
+}
+
+// OutboxConfig configures the outbox the Jira actions of new tickets are recorded in before they are made,
+// so a ticket left half-finished by a crash is finished once the router restarts
+type OutboxConfig struct {
+	// Dir is the directory the intended actions are recorded in; empty disables the outbox
+	Dir string
+	// Interval is how often the tickets left unfinished are reconciled
+	Interval time.Duration
+}
+
+// DedupConfig configures the suppression of compliance events with the same content, eg. found again by
+// a saved search re-fired over an overlapping time range
+type DedupConfig struct {
+	// Window is how long an event suppresses the later events with the same user, clusters and commands;
+	// 0 disables the suppression
+	Window time.Duration
+}
+
+// FloodConfig caps the tickets created for each user, protecting the SREs from alert storms,
+// eg. caused by automation accounts
+type FloodConfig struct {
+	// MaxTickets is the number of tickets created for a user within the window, beyond which the
+	// user's events are appended to their most recent open ticket; 0 disables the cap
+	MaxTickets int
+	// Window is the sliding window the user's tickets are counted in
+	Window time.Duration
+}
+
+// Layouts of the times of day and dates in the configuration
+const (
+	timeOfDayLayout = "15:04"
+	dateLayout      = "2006-01-02"
+)
+
+// parseTimeOfDay parses a time of day like 09:00 as a duration since midnight
+func parseTimeOfDay(value string) (time.Duration, error) {
+	t, err := time.Parse(timeOfDayLayout, value)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// DigestConfig configures the daily digest tickets of low-risk alerts, whose events are collected
+// all day into a single ticket instead of a ticket each asking for a justification
+type DigestConfig struct {
+	// AlertNames are the names of the alerts collected into digests, matched case-insensitively
+	AlertNames []string
+	// Time is the time of day, in UTC, the digest tickets are created at, eg. 09:00
+	Time string
+	// Dir is the directory the collected events are persisted to until their digest is created, so they
+	// survive a restart; empty keeps them in memory only
+	Dir string
+}
+
+// Includes reports whether the events of the alert are collected into digests
+func (d DigestConfig) Includes(alertName string) bool {
+	for _, name := range d.AlertNames {
+		if strings.EqualFold(name, alertName) {
+			return true
+		}
+	}
+	return false
+}
+
+// TimeOfDay returns the time of day the digest tickets are created at, as a duration since midnight UTC
+func (d DigestConfig) TimeOfDay() (time.Duration, error) {
+	return parseTimeOfDay(d.Time)
+}
+
+// BusinessHoursConfig configures the business hours reminders are timed in, so idle time is only
+// counted, and reminders only posted, during the working hours of working days
+type BusinessHoursConfig struct {
+	Enabled bool
+	// TimeZone is the IANA time zone of the business hours, eg. Europe/Prague
+	TimeZone string
+	// Start and End are the times of day business hours start and end at, eg. 09:00 and 17:00
+	Start string
+	End   string
+	// Days are the working days, eg. Monday
+	Days []string
+	// Holidays are the dates, eg. 2024-12-25, that are not working days
+	Holidays []string
+}
+
+// Location returns the time zone of the business hours
+func (b BusinessHoursConfig) Location() (*time.Location, error) {
+	return time.LoadLocation(b.TimeZone)
+}
+
+// Hours returns the times of day business hours start and end at, as durations since midnight
+func (b BusinessHoursConfig) Hours() (time.Duration, time.Duration, error) {
+	start, err := parseTimeOfDay(b.Start)
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := parseTimeOfDay(b.End)
+	if err != nil {
+		return 0, 0, err
+	}
+	return start, end, nil
+}
+
+// Weekdays returns the working days
+func (b BusinessHoursConfig) Weekdays() ([]time.Weekday, error) {
+	var weekdays []time.Weekday
+	for _, day := range b.Days {
+		weekday, ok := parseWeekday(day)
+		if !ok {
+			return nil, fmt.Errorf("unknown day: %s", day)
+		}
+		weekdays = append(weekdays, weekday)
+	}
+	return weekdays, nil
+}
+
+// IsHoliday reports whether the date of the time, in the business hours' time zone, is a holiday
+func (b BusinessHoursConfig) IsHoliday(t time.Time) bool {
+	return slices.Contains(b.Holidays, t.Format(dateLayout))
+}
+
+// parseWeekday parses the English name of a day of the week, case-insensitively
+func parseWeekday(name string) (time.Weekday, bool) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(day.String(), name) {
+			return day, true
+		}
+	}
+	return 0, false
+}
+
+// ReminderConfig configures the reminder comments posted on idle managed tickets
+type ReminderConfig struct {
+	Enabled bool
+	// Interval is how often idle tickets are checked for
+	Interval time.Duration
+	// IdleFor is how long a ticket must be inactive before a reminder is posted
+	IdleFor  time.Duration
+	MaxCount int
+	Template string
+}
+
+// OnCallConfig configures the on-call lookup, which checks whether alerting users were on call
+// for an active incident on the alert's cluster, so their elevation isn't treated as unexplained
+type OnCallConfig struct {
+	// Provider is the on-call service, eg. "pagerduty", or empty to disable the lookup
+	Provider string
+	// URL is the base URL of the on-call service's API
+	URL   string
+	Token string
+	// EmailDomain is appended to usernames without a domain to find their on-call account by email
+	EmailDomain string
+	// Action is what's done with the alerts of users on call for an incident: "annotate", "fasttrack" or "skip"
+	Action string
+	// Timeout is how long the lookup may take, 0 for no limit
+	Timeout time.Duration
+}
+
+// ReadOnlyConfig configures the automatic resolution of issues for elevation sessions whose
+// elevated commands were all read-only
+type ReadOnlyConfig struct {
+	Enabled bool
+	// Verbs are the read-only verbs, eg. get, list and watch
+	Verbs []string
+}
+
+// RiskConfig configures the scoring of the elevated commands of alerts, so reviewers can triage the riskiest first
+type RiskConfig struct {
+	// Rules score each elevated command, with the first matching rule; the alert's score is the highest
+	Rules []RiskRule
+	// Levels name ranges of scores, setting the priority and a label of the issues in them
+	Levels []RiskLevel
+	// Field is the ID of a number field the score is set in, eg. customfield_10042
+	Field string
+}
+
+// RiskRule scores the elevated commands it matches. Its verbs, resources and namespaces are
+// matched case-insensitively, and empty lists match any.
+type RiskRule struct {
+	Verbs      []string
+	Resources  []string
+	Namespaces []string
+	Score      int
+}
+
+// RiskLevel is the range of scores from its MinScore up to the next level's
+type RiskLevel struct {
+	Name     string
+	MinScore int
+	// Priority is the name of the Jira priority of the issues of the level, if set
+	Priority string
+}
+
+// LevelFor returns the level of the risk score: the level with the highest MinScore at most the score
+func (r RiskConfig) LevelFor(score int) (RiskLevel, bool) {
+	var level RiskLevel
+	found := false
+	for _, l := range r.Levels {
+		if score >= l.MinScore && (!found || l.MinScore > level.MinScore) {
+			level, found = l, true
+		}
+	}
+	return level, found
+}
+
+// ChangeConfig configures the automatic approval of elevations made for approved change records
+type ChangeConfig struct {
+	// Pattern is a regular expression matching the IDs of change records, eg. CHG-[0-9]+; empty disables automatic approval
+	Pattern string
+	// Jira is the name of the jirainstances entry the change records are tracked in, empty for the jiraconfig instance
+	Jira string
+	// ApprovedStatuses are the statuses of approved change records
+	ApprovedStatuses []string
+}
+
+// EnricherConfig configures a source of context added to compliance events before their tickets are created
+type EnricherConfig struct {
+	// Name identifies the enricher in logs and metrics
+	Name string
+	// Type is the kind of enricher, eg. "http"
+	Type string
+
+	// URL is the Go template of the URL an http enricher looks the event up at, rendered with the event
+	URL   string
+	Token string
+	// Fields maps the names of the fields added to the event to the dot separated paths of their
+	// values in the JSON response, eg. region: region.id
+	Fields map[string]string
+	// Timeout is how long a lookup may take, 0 for the default
+	Timeout time.Duration
+}
+
+// MessageTemplateRule overrides the message template for alerts with the given name. The rules are a list
+// rather than a map keyed by alert name, as viper splits keys on dots, which Splunk saved search names may contain.
+type MessageTemplateRule struct {
+	Alert    string
+	Template string
+}
+
+// RoutingRule overrides Jira settings for alerts matching the given alert name and/or group.
+// Empty match fields match any value, and empty override fields keep the JiraConfig value.
+type RoutingRule struct {
+	AlertName string
+	Group     string
+
+	Jira          string
 	Key           string
 	IssueType     string
-	Transitions   map[string]string
+	Components    []string
+	SecurityLevel string
+}
+
+// Matches reports whether the rule applies to an alert with the given name and group
+func (r RoutingRule) Matches(alertName, group string) bool {
+	return (r.AlertName == "" || r.AlertName == alertName) && (r.Group == "" || r.Group == group)
+}
+
+// JiraInstance returns the named Jira instance config, or the default JiraConfig when name is empty.
+// Instances without transitions, rate limits or a user cache TTL use those of the default JiraConfig.
+func (a *Config) JiraInstance(name string) (JiraConfig, bool) {
+	if name == "" {
+		return a.JiraConfig, true
+	}
+
+	// Viper lowercases map keys, so the instance names must be compared lowercased
+	jiraConfig, ok := a.JiraInstances[strings.ToLower(name)]
+	if !ok {
+		return JiraConfig{}, false
+	}
+	if jiraConfig.Transitions == nil {
+		jiraConfig.Transitions = a.JiraConfig.Transitions
+	}
+	if jiraConfig.RateLimit == 0 {
+		jiraConfig.RateLimit = a.JiraConfig.RateLimit
+		jiraConfig.RateLimitBurst = a.JiraConfig.RateLimitBurst
+	}
+	if jiraConfig.MaxRetries == 0 {
+		jiraConfig.MaxRetries = a.JiraConfig.MaxRetries
+	}
+	if jiraConfig.UserCacheTTL == 0 {
+		jiraConfig.UserCacheTTL = a.JiraConfig.UserCacheTTL
+	}
+
+	return jiraConfig, true
+}
+
+// inheritJiraSettings applies the jiraconfig booleans the named Jira instances don't set, as their unset
+// values can't be told apart from false once unmarshalled
+func (a *Config) inheritJiraSettings() {
+	for name, instance := range a.JiraInstances {
+		if !viper.IsSet("jirainstances." + name + ".watchmanager") {
+			instance.WatchManager = a.JiraConfig.WatchManager
+		}
+		if !viper.IsSet("jirainstances." + name + ".validateonstartup") {
+			instance.ValidateOnStartup = a.JiraConfig.ValidateOnStartup
+		}
+		a.JiraInstances[name] = instance
+	}
+}
+
+// JiraConfigFor returns the JiraConfig of the Jira instance selected by the first routing rule
+// matching the alert name and group, with the overrides of that rule applied
+func (a *Config) JiraConfigFor(alertName, group string) JiraConfig {
+	for _, rule := range a.Routing {
+		if rule.Matches(alertName, group) {
+			return a.routedJiraConfig(rule)
+		}
+	}
+
+	return a.JiraConfig
+}
+
+// Mode returns the mode of operation: ModeDryRun, when Jira changes are only logged, or ModeProduction
+func (a *Config) Mode() string {
+	if a.DryRun {
+		return ModeDryRun
+	}
+	return ModeProduction
+}
+
+// DryRunTransitions reports whether issue transitions are logged instead of made
+func (a *Config) DryRunTransitions() bool {
+	return a.DryRun || a.DryRunConfig.Transitions
+}
+
+// DryRunComments reports whether comments are logged instead of posted
+func (a *Config) DryRunComments() bool {
+	return a.DryRun || a.DryRunConfig.Comments
+}
+
+// DryRunUpdates reports whether watchers, epics, sprints, incident links and labels are logged instead of changed
+func (a *Config) DryRunUpdates() bool {
+	return a.DryRun || a.DryRunConfig.Updates
+}
+
+// VerboseFor reports whether the package logs verbosely, from its log level or else the verbose setting
+func (a *Config) VerboseFor(pkg string) bool {
+	for name, level := range a.LogConfig.Levels {
+		if strings.EqualFold(name, pkg) {
+			return strings.EqualFold(level, LogLevelDebug)
+		}
+	}
+	return a.Verbose
+}
+
+// LogMode logs a banner with the mode of operation, so it can't be missed in the logs
+func (a *Config) LogMode() {
+	banner := "DRY-RUN MODE: Jira changes are logged, not made"
+	if !a.DryRun {
+		banner = "PRODUCTION MODE: Jira issues are created and updated"
+
+		var dryRun []string
+		if a.DryRunConfig.Transitions {
+			dryRun = append(dryRun, "transitions")
+		}
+		if a.DryRunConfig.Comments {
+			dryRun = append(dryRun, "comments")
+		}
+		if a.DryRunConfig.Updates {
+			dryRun = append(dryRun, "updates")
+		}
+		if len(dryRun) > 1 {
+			dryRun = []string{strings.Join(dryRun[:len(dryRun)-1], ", "), dryRun[len(dryRun)-1]}
+		}
+		if dryRun != nil {
+			banner += ", but " + strings.Join(dryRun, " and ") + " are logged, not made"
+		}
+	}
+
+	log.Print(strings.Repeat("*", len(banner)+4))
+	log.Printf("* %s *", banner)
+	log.Print(strings.Repeat("*", len(banner)+4))
+}
+
+// MessageTemplateFor returns the message template for alerts with the given name
+func (a *Config) MessageTemplateFor(alertName string) string {
+	for _, rule := range a.MessageTemplates {
+		if alertName != "" && strings.EqualFold(rule.Alert, alertName) {
+			return rule.Template
+		}
+	}
+	return a.MessageTemplate
+}
+
+// IdentityProvider returns the name of the identity provider used to resolve users,
+// or an empty string when users are not resolved
+func (a *Config) IdentityProvider() string {
+	if a.IdentityConfig.Provider == "" && a.LDAPConfig.Enabled {
+		return IdentityProviderLDAP
+	}
+	return a.IdentityConfig.Provider
+}
+
+// MappedDomains returns the email domains of the domain maps of the configuration and its tenants,
+// both rewritten and rewritten to, lowercased. These are the domains of the directory's users.
+func (a *Config) MappedDomains() []string {
+	var domains []string
+	add := func(domainMap map[string]string) {
+		for from, to := range domainMap {
+			for _, domain := range []string{from, to} {
+				if domain = strings.ToLower(domain); domain != "" && !slices.Contains(domains, domain) {
+					domains = append(domains, domain)
+				}
+			}
+		}
+	}
+
+	add(a.IdentityConfig.DomainMap)
+	for _, tenant := range a.Tenants {
+		add(tenant.IdentityConfig.DomainMap)
+	}
+	return domains
+}
+
+// NonMemberJiraConfig returns the JiraConfig for alerts from users outside the required group
+func (a *Config) NonMemberJiraConfig() JiraConfig {
+	return a.routedJiraConfig(a.IdentityConfig.NonMemberRouting)
+}
+
+// RoutedJiraConfigs returns the default JiraConfig followed by the JiraConfig of each routing rule,
+// so every Jira project alerts may be created in can be checked
+func (a *Config) RoutedJiraConfigs() []JiraConfig {
+	jiraConfigs := []JiraConfig{a.JiraConfig}
+	for _, rule := range a.Routing {
+		jiraConfigs = append(jiraConfigs, a.routedJiraConfig(rule))
+	}
+	if a.IdentityProvider() != "" && a.IdentityConfig.RequiredGroup != "" {
+		jiraConfigs = append(jiraConfigs, a.NonMemberJiraConfig())
+	}
+	for name := range a.Tenants {
+		tenantConfig, _ := a.Tenant(name)
+		jiraConfigs = append(jiraConfigs, tenantConfig.RoutedJiraConfigs()...)
+	}
+
+	return jiraConfigs
+}
+
+// routedJiraConfig returns the JiraConfig of the instance selected by the rule, with its overrides applied
+func (a *Config) routedJiraConfig(rule RoutingRule) JiraConfig {
+	jiraConfig := a.JiraConfig
+	if instance, ok := a.JiraInstance(rule.Jira); ok {
+		jiraConfig = instance
+	}
+	if rule.Key != "" {
+		jiraConfig.Key = rule.Key
+	}
+	if rule.IssueType != "" {
+		jiraConfig.IssueType = rule.IssueType
+	}
+	if rule.Components != nil {
+		jiraConfig.Components = rule.Components
+	}
+	if rule.SecurityLevel != "" {
+		jiraConfig.SecurityLevel = rule.SecurityLevel
+	}
+
+	return jiraConfig
 }
 
 // configError defines a custom error so we can compare the errors returned
@@ -111,8 +1118,8 @@ func (ce configError) Error() string {
 }
 
 func filterSensitiveData(k string, v interface{}) string {
-	if strings.Contains(k, "token") {
-		v = "*****"
+	if helpers.IsSensitive(k, viper.GetStringSlice("redactfields")) {
+		v = redacted
 	}
 	return fmt.Sprintf("found key %s: %v", k, v)
 }
@@ -140,8 +1147,10 @@ func LoadConfig() {
 
 	viper.AddConfigPath(".")                          // Look for config in the cwd
 	viper.AddConfigPath(home + "/.config/" + Appname) // Look for config in $HOME/.config/compliance-audit-router
-	viper.SetConfigType("yaml")
-	viper.SetConfigName(Appname)
+	// Setting the config name discards a config file set with the --config flag
+	if viper.ConfigFileUsed() == "" {
+		viper.SetConfigName(Appname)
+	}
 
 	viper.SetEnvKeyReplacer(strings.NewReplacer(`.`, `_`)) // Replace dots from the nested structs with _ when reading from env
 	viper.SetEnvPrefix("CAR")
@@ -153,11 +1162,40 @@ func LoadConfig() {
 
 	viper.AutomaticEnv() // read in environment variables that match
 
+	// The format of the config file is detected from its extension, eg. .yaml, .json or .toml,
+	// unless configtype is set, eg. for a config file without an extension
+	if configType := viper.GetString("configtype"); configType != "" {
+		viper.SetConfigType(configType)
+	}
+
 	viper.SetDefault("MessageTemplate", defaultMessageTemplate)
+	viper.SetDefault("SummaryTemplate", defaultSummaryTemplate)
 	viper.SetDefault("Verbose", true)
+	viper.SetDefault("RedactFields", []string{"username", "user", "email", "mail"})
+	viper.SetDefault("logconfig.sampleevery", 1)
 	viper.SetDefault("DryRun", true)
 	viper.SetDefault("ListenPort", 8080)
+	viper.SetDefault("readinesscachettl", 10*time.Second)
+	viper.SetDefault("splunkconfig.maxresultssize", 64<<20)
 	viper.SetDefault("ldapconfig.enabled", false)
+	viper.SetDefault("oktaconfig.usernameattribute", "login")
+	viper.SetDefault("oktaconfig.managerattribute", "managerId")
+	viper.SetDefault("azureconfig.usernameattribute", "userPrincipalName")
+	viper.SetDefault("azureconfig.authorityurl", "https://login.microsoftonline.com")
+	viper.SetDefault("azureconfig.graphurl", "https://graph.microsoft.com")
+	viper.SetDefault("googleconfig.directoryurl", "https://admin.googleapis.com")
+	viper.SetDefault("ldapconfig.hostselection", "ordered")
+	viper.SetDefault("ldapconfig.userfilter", defaultLDAPUserFilter)
+	viper.SetDefault("ldapconfig.groupfilter", defaultLDAPGroupFilter)
+	viper.SetDefault("ldapconfig.mailfilter", defaultLDAPMailFilter)
+	viper.SetDefault("ldapconfig.attributemap.uid", "uid")
+	viper.SetDefault("ldapconfig.attributemap.manager", "manager")
+	viper.SetDefault("ldapconfig.attributemap.mail", "mail")
+	viper.SetDefault("ldapconfig.poolsize", 5)
+	viper.SetDefault("ldapconfig.idletimeout", "5m")
+	viper.SetDefault("ldapconfig.pagesize", 500)
+	viper.SetDefault("ldapconfig.cachettl", "1h")
+	viper.SetDefault("ldapconfig.cachesize", 1000)
 	viper.SetDefault("jiraconfig.dev", false)
 	viper.SetDefault("jiraconfig.transitions", map[string]string{
 		"initial": "In Progress",
@@ -165,9 +1203,49 @@ func LoadConfig() {
 		"manager": "Done"},
 	)
 	viper.SetDefault("jiraconfig.issuetype", "Task")
+	viper.SetDefault("jiraconfig.usercachettl", "1h")
+	viper.SetDefault("pipelineconfig.workers", 4)
+	viper.SetDefault("pipelineconfig.queuesize", 100)
+	viper.SetDefault("pipelineconfig.jiraparallelism", 1)
+	viper.SetDefault("pipelineconfig.alerttimeout", "5m")
+	viper.SetDefault("pipelineconfig.splunktimeout", "30s")
+	viper.SetDefault("pipelineconfig.identitytimeout", "30s")
+	viper.SetDefault("pipelineconfig.jiratimeout", "2m")
+	viper.SetDefault("digestconfig.time", "00:00")
+	viper.SetDefault("retentionconfig.interval", "1h")
+	viper.SetDefault("backfillconfig.pagesize", 100)
+	viper.SetDefault("floodconfig.window", "1h")
+	viper.SetDefault("breakerconfig.cooldown", "1m")
+	viper.SetDefault("spoolconfig.draininterval", "1m")
+	viper.SetDefault("outboxconfig.interval", "1m")
+	viper.SetDefault("readonlyconfig.enabled", false)
+	viper.SetDefault("readonlyconfig.verbs", []string{"get", "list", "watch"})
+	viper.SetDefault("oncallconfig.url", "https://api.pagerduty.com")
+	viper.SetDefault("oncallconfig.action", OnCallActionAnnotate)
+	viper.SetDefault("oncallconfig.timeout", "10s")
+	viper.SetDefault("businesshoursconfig.enabled", false)
+	viper.SetDefault("businesshoursconfig.timezone", "UTC")
+	viper.SetDefault("businesshoursconfig.start", "09:00")
+	viper.SetDefault("businesshoursconfig.end", "17:00")
+	viper.SetDefault("businesshoursconfig.days", []string{"Monday", "Tuesday", "Wednesday", "Thursday", "Friday"})
+	viper.SetDefault("reminderconfig.enabled", false)
+	viper.SetDefault("reminderconfig.interval", "1h")
+	viper.SetDefault("reminderconfig.idlefor", "24h")
+	viper.SetDefault("reminderconfig.maxcount", 3)
+	viper.SetDefault("reminderconfig.template", defaultReminderTemplate)
+	viper.SetDefault("jiraconfig.watchmanager", true)
+	viper.SetDefault("jiraconfig.documentformat", "wiki")
+	viper.SetDefault("jiraconfig.validateonstartup", true)
+	viper.SetDefault("jiraconfig.labelprefix", "compliance-audit-router")
+	viper.SetDefault("jiraconfig.labelseparator", ":")
+	viper.SetDefault("jiraconfig.ratelimit", 5)
+	viper.SetDefault("jiraconfig.ratelimitburst", 10)
+	viper.SetDefault("jiraconfig.maxretries", 3)
+	viper.SetDefault("jiraconfig.incidentwindow", "24h")
+	viper.SetDefault("jiraconfig.incidentlinktype", "Relates")
 
-	err = viper.ReadInConfig() // Find and read the config file
-	if err != nil {            // Handle errors reading the config file
+	err = readConfig() // Find and read the config file and directories
+	if err != nil {    // Handle errors reading the config file
 		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
 			log.Print("no config file found; using environment variables")
 		} else {
@@ -177,19 +1255,26 @@ func LoadConfig() {
 
 	checkSettings()
 
-	err = viper.Unmarshal(&AppConfig)
+	var loaded Config
+	err = viper.Unmarshal(&loaded)
 	if err != nil {
 		panic(err)
 	}
+	loaded.inheritJiraSettings()
+
+	for _, secretErr := range append(loaded.LoadSecretFiles(), loaded.ResolveSecretReferences()...) {
+		log.Print(secretErr)
+	}
 
-	if !AppConfig.Valid() && !AppConfig.DryRun {
+	if !loaded.Valid() && !loaded.DryRun {
 		// If the config is invalid, log the errors and exit right away
 		log.Fatal("FATAL: configuration invalid - exiting")
-	} else if !AppConfig.Valid() && AppConfig.DryRun {
+	} else if !loaded.Valid() && loaded.DryRun {
 		log.Print("WARN: configuration invalid - continuing in dry-run mode")
 	} else {
 		log.Print("INFO: configuration valid")
 	}
+	SetAppConfig(loaded)
 }
 
 // Valid() wraps the validation functions for the config struct
@@ -201,10 +1286,48 @@ func (a *Config) Valid() bool {
 	var configErrors []error
 
 	validationFunctions := []func(a *Config) []error{
+		productionModeIsConfirmed,
 		fieldsAreNotNil,
 		hostFieldsAreParsable,
 		passwordOrTokenExistIfUsernameProvided,
+		secretReferencesAreResolved,
 		templateCanBeParsed,
+		jiraDocumentFormatIsValid,
+		jiraLabelSchemesAreValid,
+		jiraTransitionsAreValid,
+		jiraRateLimitsAreValid,
+		jiraUserCacheIsValid,
+		jiraSprintIsValid,
+		jiraIncidentWindowIsValid,
+		jiraJustificationIsValid,
+		identityConfigIsValid,
+		ldapConfigIsValid,
+		ldapCacheIsValid,
+		readinessCacheIsValid,
+		pipelineConfigIsValid,
+		logConfigIsValid,
+		routingRulesHaveMatchers,
+		jiraInstancesAreValid,
+		tenantsAreValid,
+		apiKeysAreValid,
+		enrichersAreValid,
+		onCallConfigIsValid,
+		changeConfigIsValid,
+		riskConfigIsValid,
+		readOnlyConfigIsValid,
+		aggregationConfigIsValid,
+		floodConfigIsValid,
+		dedupConfigIsValid,
+		breakerConfigIsValid,
+		spoolConfigIsValid,
+		outboxConfigIsValid,
+		retentionConfigIsValid,
+		backfillConfigIsValid,
+		splunkTimeFormatsAreValid,
+		splunkMaxResultsSizeIsValid,
+		digestConfigIsValid,
+		businessHoursConfigIsValid,
+		reminderConfigIsValid,
 	}
 
 	for _, f := range validationFunctions {
@@ -221,6 +1344,14 @@ func (a *Config) Valid() bool {
 	return true
 }
 
+// productionModeIsConfirmed tests that disabling dry-run is confirmed with the productionconfirmation
+func productionModeIsConfirmed(a *Config) []error {
+	if a.DryRun || a.ProductionConfirmation == productionConfirmation {
+		return nil
+	}
+	return []error{configError{Err: fmt.Sprintf("dryrun is false, but productionconfirmation is not %q", productionConfirmation)}}
+}
+
 // fieldsAreNotNil tests that he required configs values have been set
 func fieldsAreNotNil(a *Config) []error {
 	var nilFieldErrors []error
@@ -255,7 +1386,7 @@ func fieldsAreNotNil(a *Config) []error {
 		},
 	}
 
-	if a.LDAPConfig.Enabled {
+	if a.IdentityProvider() == IdentityProviderLDAP {
 		nilLDAPStringTests := []struct {
 			name  string
 			value string
@@ -307,6 +1438,13 @@ func hostFieldsAreParsable(a *Config) []error {
 			value: a.JiraConfig.Host,
 		},
 	}
+	for n, host := range a.LDAPConfig.Hosts {
+		hostParseTests = append(hostParseTests, struct {
+			name  string
+			value string
+		}{name: fmt.Sprintf("LDAPConfig.Hosts[%d]", n), value: host})
+	}
+
 	for _, i := range hostParseTests {
 		if i.value != "" {
 			u, err := url.Parse(i.value)
@@ -347,14 +1485,816 @@ func passwordOrTokenExistIfUsernameProvided(a *Config) []error {
 	return passwordErrors
 }
 
-// templateCanBeParsed tests that the message template can be parsed or returns an error
+// templateCanBeParsed tests that the message and summary templates can be parsed or returns an error
 func templateCanBeParsed(a *Config) []error {
 	var templateErrors []error
 
-	_, err := template.New("messageTemplate").Parse(a.MessageTemplate)
+	_, err := helpers.ParseTemplate("messageTemplate", a.MessageTemplate)
 	if err != nil {
 		templateErrors = append(templateErrors, configError{Err: fmt.Sprintf("message template failed to parse: %s", err)})
 	}
 
+	for i, rule := range a.MessageTemplates {
+		if rule.Alert == "" {
+			templateErrors = append(templateErrors, configError{Err: fmt.Sprintf("messagetemplates[%d] has no alert", i)})
+		}
+		if _, err := helpers.ParseTemplate("messageTemplate", rule.Template); err != nil {
+			templateErrors = append(templateErrors, configError{Err: fmt.Sprintf("messagetemplates[%d] (%s) failed to parse: %s", i, rule.Alert, err)})
+		}
+	}
+
+	_, err = helpers.ParseTemplate("summaryTemplate", a.SummaryTemplate)
+	if err != nil {
+		templateErrors = append(templateErrors, configError{Err: fmt.Sprintf("summary template failed to parse: %s", err)})
+	}
+
 	return templateErrors
 }
+
+// jiraInstanceConfigs returns the default Jira instance and the named instances, by the key of their settings
+func jiraInstanceConfigs(a *Config) map[string]JiraConfig {
+	instances := map[string]JiraConfig{"jiraconfig": a.JiraConfig}
+	for name, instance := range a.JiraInstances {
+		instances["jirainstances."+name] = instance
+	}
+	return instances
+}
+
+// jiraDocumentFormatIsValid tests that the document format of each Jira instance is one of the supported formats
+func jiraDocumentFormatIsValid(a *Config) []error {
+	var formatErrors []error
+
+	for name, instance := range jiraInstanceConfigs(a) {
+		switch instance.DocumentFormat {
+		case "", "wiki", "adf", "auto":
+		default:
+			formatErrors = append(formatErrors, configError{Err: fmt.Sprintf("%s.documentformat must be one of wiki, adf or auto: %s", name, instance.DocumentFormat)})
+		}
+	}
+
+	return formatErrors
+}
+
+// jiraLabelSchemesAreValid tests that the label prefix and separator of each Jira instance form valid,
+// unambiguous Jira labels. Jira labels can't contain spaces, and the separator must not appear in the prefix,
+// since the label values are found by splitting on the separator.
+func jiraLabelSchemesAreValid(a *Config) []error {
+	var labelErrors []error
+
+	for name, instance := range jiraInstanceConfigs(a) {
+		if strings.ContainsAny(instance.LabelPrefix, " \t\n") {
+			labelErrors = append(labelErrors, configError{Err: fmt.Sprintf("%s.labelprefix must not contain whitespace: %q", name, instance.LabelPrefix)})
+		}
+		if strings.HasSuffix(instance.LabelPrefix, "/") {
+			labelErrors = append(labelErrors, configError{Err: fmt.Sprintf("%s.labelprefix must not end with /: %s", name, instance.LabelPrefix)})
+		}
+		if instance.LabelSeparator == "" {
+			continue
+		}
+		if len([]rune(instance.LabelSeparator)) != 1 || strings.ContainsAny(instance.LabelSeparator, " \t\n/") {
+			labelErrors = append(labelErrors, configError{Err: fmt.Sprintf("%s.labelseparator must be a single character other than whitespace or /: %q", name, instance.LabelSeparator)})
+		} else if strings.Contains(instance.LabelPrefix, instance.LabelSeparator) {
+			labelErrors = append(labelErrors, configError{Err: fmt.Sprintf("%s.labelprefix must not contain the label separator %q: %s", name, instance.LabelSeparator, instance.LabelPrefix)})
+		}
+	}
+
+	return labelErrors
+}
+
+// jiraTransitionsAreValid tests that the transitions of each Jira instance name the status of every step
+// of the workflow, so a missing transition fails at startup rather than at the first ticket.
+// Unknown transition keys are unused, and only warned about.
+func jiraTransitionsAreValid(a *Config) []error {
+	var transitionErrors []error
+
+	instances := map[string]JiraConfig{"jiraconfig": a.JiraConfig}
+	for name, instance := range a.JiraInstances {
+		// Instances without transitions use those of the default JiraConfig
+		if instance.Transitions != nil {
+			instances["jirainstances."+name] = instance
+		}
+	}
+
+	for name, instance := range instances {
+		for _, key := range transitionKeys {
+			if instance.Transitions[key] == "" {
+				transitionErrors = append(transitionErrors, configError{Err: fmt.Sprintf("missing required configuration value: %s.transitions.%s", name, key)})
+			}
+		}
+		for key := range instance.Transitions {
+			if !slices.Contains(transitionKeys, key) {
+				log.Printf("WARN: unknown transition %s.transitions.%s, expected one of %s", name, key, strings.Join(transitionKeys, ", "))
+			}
+		}
+	}
+
+	return transitionErrors
+}
+
+// jiraRateLimitsAreValid tests that the rate limit settings of each Jira instance are not negative
+func jiraRateLimitsAreValid(a *Config) []error {
+	var rateLimitErrors []error
+
+	for name, instance := range jiraInstanceConfigs(a) {
+		if instance.RateLimit < 0 {
+			rateLimitErrors = append(rateLimitErrors, configError{Err: fmt.Sprintf("%s.ratelimit must not be negative: %v", name, instance.RateLimit)})
+		}
+		if instance.RateLimitBurst < 0 {
+			rateLimitErrors = append(rateLimitErrors, configError{Err: fmt.Sprintf("%s.ratelimitburst must not be negative: %v", name, instance.RateLimitBurst)})
+		}
+		if instance.MaxRetries < 0 {
+			rateLimitErrors = append(rateLimitErrors, configError{Err: fmt.Sprintf("%s.maxretries must not be negative: %v", name, instance.MaxRetries)})
+		}
+	}
+
+	return rateLimitErrors
+}
+
+// jiraUserCacheIsValid tests that the user cache TTL of each Jira instance is not negative
+func jiraUserCacheIsValid(a *Config) []error {
+	var cacheErrors []error
+
+	for name, instance := range jiraInstanceConfigs(a) {
+		if instance.UserCacheTTL < 0 {
+			cacheErrors = append(cacheErrors, configError{Err: fmt.Sprintf("%s.usercachettl must not be negative: %v", name, instance.UserCacheTTL)})
+		}
+	}
+
+	return cacheErrors
+}
+
+// jiraSprintIsValid tests that the board and sprint IDs of each Jira instance are not negative
+func jiraSprintIsValid(a *Config) []error {
+	var sprintErrors []error
+
+	for name, instance := range jiraInstanceConfigs(a) {
+		if instance.Board < 0 {
+			sprintErrors = append(sprintErrors, configError{Err: fmt.Sprintf("%s.board must not be negative: %v", name, instance.Board)})
+		}
+		if instance.Sprint < 0 {
+			sprintErrors = append(sprintErrors, configError{Err: fmt.Sprintf("%s.sprint must not be negative: %v", name, instance.Sprint)})
+		}
+	}
+
+	return sprintErrors
+}
+
+// jiraJustificationIsValid tests that the justification patterns compile and the justification templates
+// parse, for the default and the additional Jira instances
+func jiraJustificationIsValid(a *Config) []error {
+	var justificationErrors []error
+
+	check := func(prefix string, jiraConfig JiraConfig) {
+		if _, err := regexp.Compile(jiraConfig.JustificationPattern); err != nil {
+			justificationErrors = append(justificationErrors, configError{Err: fmt.Sprintf("%s.justificationpattern failed to compile: %s", prefix, err)})
+		}
+		if _, err := helpers.ParseTemplate("justificationTemplate", jiraConfig.JustificationTemplate); err != nil {
+			justificationErrors = append(justificationErrors, configError{Err: fmt.Sprintf("%s.justificationtemplate failed to parse: %s", prefix, err)})
+		}
+	}
+
+	check("jiraconfig", a.JiraConfig)
+	for name, instance := range a.JiraInstances {
+		check("jirainstances."+name, instance)
+	}
+
+	return justificationErrors
+}
+
+// jiraIncidentWindowIsValid tests that the window each Jira instance searches for incidents in is not negative
+func jiraIncidentWindowIsValid(a *Config) []error {
+	var incidentErrors []error
+
+	for name, instance := range jiraInstanceConfigs(a) {
+		if instance.IncidentWindow < 0 {
+			incidentErrors = append(incidentErrors, configError{Err: fmt.Sprintf("%s.incidentwindow must not be negative: %v", name, instance.IncidentWindow)})
+		}
+	}
+
+	return incidentErrors
+}
+
+// identityConfigIsValid tests that the identity provider is supported and configured, and the escalation depth is not negative
+func identityConfigIsValid(a *Config) []error {
+	var identityErrors []error
+
+	switch a.IdentityProvider() {
+	case "", IdentityProviderLDAP:
+	case IdentityProviderOkta:
+		identityErrors = append(identityErrors, oktaConfigIsValid(a.OktaConfig)...)
+	case IdentityProviderAzure:
+		identityErrors = append(identityErrors, azureConfigIsValid(a.AzureConfig)...)
+	case IdentityProviderGoogle:
+		identityErrors = append(identityErrors, googleConfigIsValid(a.GoogleConfig)...)
+	default:
+		identityErrors = append(identityErrors, configError{Err: fmt.Sprintf("identityconfig.provider must be one of ldap, okta, azure or google: %s", a.IdentityConfig.Provider)})
+	}
+	if a.IdentityConfig.ManagerChainDepth < 0 {
+		identityErrors = append(identityErrors, configError{Err: fmt.Sprintf("identityconfig.managerchaindepth must not be negative: %v", a.IdentityConfig.ManagerChainDepth)})
+	}
+
+	return identityErrors
+}
+
+// oktaConfigIsValid tests that the Okta settings are set when Okta is the identity provider
+func oktaConfigIsValid(o OktaConfig) []error {
+	var oktaErrors []error
+
+	if o.OrgURL == "" {
+		oktaErrors = append(oktaErrors, configError{Err: "missing required configuration value: oktaconfig.orgurl"})
+	} else if u, err := url.Parse(o.OrgURL); err != nil || u.Scheme != "https" || u.Host == "" {
+		oktaErrors = append(oktaErrors, configError{Err: fmt.Sprintf("oktaconfig.orgurl invalid URL: %s", o.OrgURL)})
+	}
+	if o.Token == "" {
+		oktaErrors = append(oktaErrors, configError{Err: "missing required configuration value: oktaconfig.token"})
+	}
+	if o.UsernameAttribute == "" {
+		oktaErrors = append(oktaErrors, configError{Err: "missing required configuration value: oktaconfig.usernameattribute"})
+	}
+
+	return oktaErrors
+}
+
+// azureConfigIsValid tests that the Azure settings are set when Azure is the identity provider
+func azureConfigIsValid(az AzureConfig) []error {
+	var azureErrors []error
+
+	requiredStringTests := []struct {
+		name  string
+		value string
+	}{
+		{name: "TenantID", value: az.TenantID},
+		{name: "ClientID", value: az.ClientID},
+		{name: "ClientSecret", value: az.ClientSecret},
+	}
+	for _, i := range requiredStringTests {
+		if i.value == "" {
+			azureErrors = append(azureErrors, configError{Err: fmt.Sprintf("missing required configuration value: azureconfig.%s", strings.ToLower(i.name))})
+		}
+	}
+
+	switch az.UsernameAttribute {
+	case "", "userPrincipalName", "mail", "mailNickname":
+	default:
+		azureErrors = append(azureErrors, configError{Err: fmt.Sprintf("azureconfig.usernameattribute must be one of userPrincipalName, mail or mailNickname: %s", az.UsernameAttribute)})
+	}
+
+	for name, value := range map[string]string{"authorityurl": az.AuthorityURL, "graphurl": az.GraphURL} {
+		if u, err := url.Parse(value); err != nil || u.Scheme != "https" || u.Host == "" {
+			azureErrors = append(azureErrors, configError{Err: fmt.Sprintf("azureconfig.%s invalid URL: %s", name, value)})
+		}
+	}
+
+	return azureErrors
+}
+
+// googleConfigIsValid tests that the Google settings are set when Google is the identity provider
+func googleConfigIsValid(g GoogleConfig) []error {
+	var googleErrors []error
+
+	if g.CredentialsFile == "" {
+		googleErrors = append(googleErrors, configError{Err: "missing required configuration value: googleconfig.credentialsfile"})
+	} else if _, err := os.Stat(g.CredentialsFile); err != nil {
+		googleErrors = append(googleErrors, configError{Err: fmt.Sprintf("googleconfig.credentialsfile can't be read: %s", g.CredentialsFile)})
+	}
+	if g.Subject == "" {
+		googleErrors = append(googleErrors, configError{Err: "missing required configuration value: googleconfig.subject"})
+	}
+
+	return googleErrors
+}
+
+// ldapConfigIsValid tests that the LDAP search, server selection and connection pool settings are usable when LDAP is enabled
+func ldapConfigIsValid(a *Config) []error {
+	var ldapErrors []error
+
+	if a.IdentityProvider() != IdentityProviderLDAP {
+		return ldapErrors
+	}
+
+	if err := ldapFilterIsValid(a.LDAPConfig.UserFilter, a.LDAPConfig.AttributeMap); err != nil {
+		ldapErrors = append(ldapErrors, configError{Err: fmt.Sprintf("ldapconfig.userfilter is invalid: %s", err)})
+	}
+	// The group filter is only used to check identityconfig.requiredgroup
+	if a.LDAPConfig.GroupFilter == "" && a.IdentityConfig.RequiredGroup == "" {
+		// nothing to check
+	} else if err := ldapFilterIsValid(a.LDAPConfig.GroupFilter, a.LDAPConfig.AttributeMap); err != nil {
+		ldapErrors = append(ldapErrors, configError{Err: fmt.Sprintf("ldapconfig.groupfilter is invalid: %s", err)})
+	}
+	// The mail filter is only used with the mail attribute
+	if a.LDAPConfig.AttributeMap.Mail == "" {
+		// nothing to check
+	} else if err := ldapFilterIsValid(a.LDAPConfig.MailFilter, a.LDAPConfig.AttributeMap); err != nil {
+		ldapErrors = append(ldapErrors, configError{Err: fmt.Sprintf("ldapconfig.mailfilter is invalid: %s", err)})
+	}
+	if a.LDAPConfig.AttributeMap.UID == "" || a.LDAPConfig.AttributeMap.Manager == "" {
+		ldapErrors = append(ldapErrors, configError{Err: "ldapconfig.attributemap.uid and ldapconfig.attributemap.manager must be set"})
+	}
+	switch a.LDAPConfig.HostSelection {
+	case "", "ordered", "roundrobin":
+	default:
+		ldapErrors = append(ldapErrors, configError{Err: fmt.Sprintf("ldapconfig.hostselection must be one of ordered or roundrobin: %s", a.LDAPConfig.HostSelection)})
+	}
+	if a.LDAPConfig.PoolSize < 1 {
+		ldapErrors = append(ldapErrors, configError{Err: fmt.Sprintf("ldapconfig.poolsize must be at least 1: %v", a.LDAPConfig.PoolSize)})
+	}
+	if a.LDAPConfig.IdleTimeout < 0 {
+		ldapErrors = append(ldapErrors, configError{Err: fmt.Sprintf("ldapconfig.idletimeout must not be negative: %v", a.LDAPConfig.IdleTimeout)})
+	}
+	if a.LDAPConfig.PageSize < 0 {
+		ldapErrors = append(ldapErrors, configError{Err: fmt.Sprintf("ldapconfig.pagesize must not be negative: %v", a.LDAPConfig.PageSize)})
+	}
+
+	return ldapErrors
+}
+
+// ldapFilterData has the same fields as the ldap package's FilterData, to render the filter templates with sample data
+type ldapFilterData struct {
+	Username string
+	Group    string
+
+	UIDAttribute     string
+	ManagerAttribute string
+	MailAttribute    string
+}
+
+// ldapFilterIsValid renders the filter template with sample data and checks that the result is an LDAP filter.
+// This catches templates using unknown fields or rendering unbalanced filters, which would otherwise fail every lookup.
+func ldapFilterIsValid(filterTemplate string, attributeMap LDAPAttributeMap) error {
+	t, err := template.New("filter").Option("missingkey=error").Parse(filterTemplate)
+	if err != nil {
+		return err
+	}
+
+	sample := ldapFilterData{
+		Username:         "user",
+		Group:            "cn=group,dc=example,dc=org",
+		UIDAttribute:     attributeMap.UID,
+		ManagerAttribute: attributeMap.Manager,
+		MailAttribute:    attributeMap.Mail,
+	}
+	var filter strings.Builder
+	if err := t.Execute(&filter, sample); err != nil {
+		return err
+	}
+
+	if _, err := ldap.CompileFilter(filter.String()); err != nil {
+		return fmt.Errorf("%q is not a valid filter: %w", filter.String(), err)
+	}
+	return nil
+}
+
+// pipelineConfigIsValid tests that alerts can be processed with the pipeline settings
+func pipelineConfigIsValid(a *Config) []error {
+	var pipelineErrors []error
+
+	if a.PipelineConfig.Workers < 1 {
+		pipelineErrors = append(pipelineErrors, configError{Err: fmt.Sprintf("pipelineconfig.workers must be at least 1: %v", a.PipelineConfig.Workers)})
+	}
+	if a.PipelineConfig.QueueSize < 0 {
+		pipelineErrors = append(pipelineErrors, configError{Err: fmt.Sprintf("pipelineconfig.queuesize must not be negative: %v", a.PipelineConfig.QueueSize)})
+	}
+	if a.PipelineConfig.JiraParallelism < 1 {
+		pipelineErrors = append(pipelineErrors, configError{Err: fmt.Sprintf("pipelineconfig.jiraparallelism must be at least 1: %v", a.PipelineConfig.JiraParallelism)})
+	}
+	if a.PipelineConfig.UnreadyQueueDepth < 0 {
+		pipelineErrors = append(pipelineErrors, configError{Err: fmt.Sprintf("pipelineconfig.unreadyqueuedepth must not be negative: %v", a.PipelineConfig.UnreadyQueueDepth)})
+	}
+	if a.PipelineConfig.UnreadyRetryBacklog < 0 {
+		pipelineErrors = append(pipelineErrors, configError{Err: fmt.Sprintf("pipelineconfig.unreadyretrybacklog must not be negative: %v", a.PipelineConfig.UnreadyRetryBacklog)})
+	}
+	for _, timeout := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"alerttimeout", a.PipelineConfig.AlertTimeout},
+		{"splunktimeout", a.PipelineConfig.SplunkTimeout},
+		{"identitytimeout", a.PipelineConfig.IdentityTimeout},
+		{"jiratimeout", a.PipelineConfig.JiraTimeout},
+	} {
+		if timeout.value < 0 {
+			pipelineErrors = append(pipelineErrors, configError{Err: fmt.Sprintf("pipelineconfig.%s must not be negative: %v", timeout.name, timeout.value)})
+		}
+	}
+
+	return pipelineErrors
+}
+
+// ldapCacheIsValid tests that the LDAP lookup cache settings are not negative
+func ldapCacheIsValid(a *Config) []error {
+	var cacheErrors []error
+
+	if a.LDAPConfig.CacheTTL < 0 {
+		cacheErrors = append(cacheErrors, configError{Err: fmt.Sprintf("ldapconfig.cachettl must not be negative: %v", a.LDAPConfig.CacheTTL)})
+	}
+	if a.LDAPConfig.CacheSize < 0 {
+		cacheErrors = append(cacheErrors, configError{Err: fmt.Sprintf("ldapconfig.cachesize must not be negative: %v", a.LDAPConfig.CacheSize)})
+	}
+
+	return cacheErrors
+}
+
+// readinessCacheIsValid tests that the readiness check cache TTL is not negative
+func readinessCacheIsValid(a *Config) []error {
+	if a.ReadinessCacheTTL < 0 {
+		return []error{configError{Err: fmt.Sprintf("readinesscachettl must not be negative: %v", a.ReadinessCacheTTL)}}
+	}
+	return nil
+}
+
+// routingRulesHaveMatchers tests that each routing rule matches on an alert name or group,
+// so a rule can't accidentally capture every alert
+func routingRulesHaveMatchers(a *Config) []error {
+	var routingErrors []error
+
+	for i, rule := range a.Routing {
+		if rule.AlertName == "" && rule.Group == "" {
+			routingErrors = append(routingErrors, configError{Err: fmt.Sprintf("routing[%d] must set alertname or group", i)})
+		}
+	}
+
+	return routingErrors
+}
+
+// jiraInstancesAreValid tests that the named Jira instances have the required values set
+// and that routing rules only reference instances that exist
+func jiraInstancesAreValid(a *Config) []error {
+	var instanceErrors []error
+
+	for name, instance := range a.JiraInstances {
+		requiredStringTests := []struct {
+			name  string
+			value string
+		}{
+			{name: "Host", value: instance.Host},
+			{name: "Token", value: instance.Token},
+			{name: "Key", value: instance.Key},
+			{name: "IssueType", value: instance.IssueType},
+		}
+		for _, i := range requiredStringTests {
+			if i.value == "" {
+				instanceErrors = append(instanceErrors, configError{Err: fmt.Sprintf("missing required configuration value: jirainstances.%s.%s", name, strings.ToLower(i.name))})
+			}
+		}
+		if instance.Host != "" {
+			if u, err := url.Parse(instance.Host); err != nil || u.Host == "" {
+				instanceErrors = append(instanceErrors, configError{Err: fmt.Sprintf("jirainstances.%s.host invalid URL: %s", name, instance.Host)})
+			}
+		}
+	}
+
+	for i, rule := range a.Routing {
+		if _, ok := a.JiraInstance(rule.Jira); !ok {
+			instanceErrors = append(instanceErrors, configError{Err: fmt.Sprintf("routing[%d] references unknown jira instance: %s", i, rule.Jira)})
+		}
+	}
+	if _, ok := a.JiraInstance(a.IdentityConfig.NonMemberRouting.Jira); !ok {
+		instanceErrors = append(instanceErrors, configError{Err: fmt.Sprintf("identityconfig.nonmemberrouting references unknown jira instance: %s", a.IdentityConfig.NonMemberRouting.Jira)})
+	}
+
+	return instanceErrors
+}
+
+// enrichersAreValid tests that the enrichers have unique names and a known type, and that
+// the URL templates of http enrichers parse
+func enrichersAreValid(a *Config) []error {
+	var enricherErrors []error
+
+	names := map[string]bool{}
+	for i, enricher := range a.Enrichers {
+		if enricher.Name == "" {
+			enricherErrors = append(enricherErrors, configError{Err: fmt.Sprintf("missing required configuration value: enrichers[%d].name", i)})
+		} else if names[enricher.Name] {
+			enricherErrors = append(enricherErrors, configError{Err: fmt.Sprintf("enrichers[%d].name is not unique: %s", i, enricher.Name)})
+		}
+		names[enricher.Name] = true
+
+		if enricher.Timeout < 0 {
+			enricherErrors = append(enricherErrors, configError{Err: fmt.Sprintf("enrichers[%d].timeout must not be negative: %v", i, enricher.Timeout)})
+		}
+
+		switch enricher.Type {
+		case EnricherTypeHTTP:
+			if enricher.URL == "" {
+				enricherErrors = append(enricherErrors, configError{Err: fmt.Sprintf("missing required configuration value: enrichers[%d].url", i)})
+			} else if _, err := template.New("url").Funcs(helpers.TemplateFuncs()).Parse(enricher.URL); err != nil {
+				enricherErrors = append(enricherErrors, configError{Err: fmt.Sprintf("enrichers[%d].url failed to parse: %s", i, err)})
+			}
+			if len(enricher.Fields) == 0 {
+				enricherErrors = append(enricherErrors, configError{Err: fmt.Sprintf("missing required configuration value: enrichers[%d].fields", i)})
+			}
+		default:
+			enricherErrors = append(enricherErrors, configError{Err: fmt.Sprintf("enrichers[%d].type must be one of %s: %s", i, EnricherTypeHTTP, enricher.Type)})
+		}
+	}
+
+	return enricherErrors
+}
+
+// onCallConfigIsValid tests that the on-call service is known and its settings are set when the lookup is enabled
+func onCallConfigIsValid(a *Config) []error {
+	var onCallErrors []error
+	o := a.OnCallConfig
+
+	if o.Provider == "" {
+		return onCallErrors
+	}
+
+	if o.Provider != OnCallProviderPagerDuty {
+		onCallErrors = append(onCallErrors, configError{Err: fmt.Sprintf("oncallconfig.provider must be %s: %s", OnCallProviderPagerDuty, o.Provider)})
+	}
+	if o.URL == "" {
+		onCallErrors = append(onCallErrors, configError{Err: "missing required configuration value: oncallconfig.url"})
+	} else if _, err := url.ParseRequestURI(o.URL); err != nil {
+		onCallErrors = append(onCallErrors, configError{Err: fmt.Sprintf("oncallconfig.url invalid URL: %s", o.URL)})
+	}
+	if o.Token == "" {
+		onCallErrors = append(onCallErrors, configError{Err: "missing required configuration value: oncallconfig.token"})
+	}
+	switch o.Action {
+	case OnCallActionAnnotate, OnCallActionFastTrack, OnCallActionSkip:
+	default:
+		onCallErrors = append(onCallErrors, configError{Err: fmt.Sprintf("oncallconfig.action must be one of %s, %s or %s: %s", OnCallActionAnnotate, OnCallActionFastTrack, OnCallActionSkip, o.Action)})
+	}
+	if o.Timeout < 0 {
+		onCallErrors = append(onCallErrors, configError{Err: fmt.Sprintf("oncallconfig.timeout must not be negative: %v", o.Timeout)})
+	}
+
+	return onCallErrors
+}
+
+// splunkTimeFormatsAreValid tests that none of the Splunk time formats is empty
+func splunkTimeFormatsAreValid(a *Config) []error {
+	var timeFormatErrors []error
+
+	for i, timeFormat := range a.SplunkConfig.TimeFormats {
+		if strings.TrimSpace(timeFormat) == "" {
+			timeFormatErrors = append(timeFormatErrors, configError{Err: fmt.Sprintf("missing required configuration value: splunkconfig.timeformats[%d]", i)})
+		}
+	}
+
+	return timeFormatErrors
+}
+
+// splunkMaxResultsSizeIsValid tests that the largest search results response is not negative
+func splunkMaxResultsSizeIsValid(a *Config) []error {
+	if a.SplunkConfig.MaxResultsSize < 0 {
+		return []error{configError{Err: fmt.Sprintf("splunkconfig.maxresultssize must not be negative: %v", a.SplunkConfig.MaxResultsSize)}}
+	}
+	return nil
+}
+
+// backfillConfigIsValid tests that the backfill page size is not negative
+func backfillConfigIsValid(a *Config) []error {
+	if a.BackfillConfig.PageSize < 0 {
+		return []error{configError{Err: fmt.Sprintf("backfillconfig.pagesize must not be negative: %v", a.BackfillConfig.PageSize)}}
+	}
+	return nil
+}
+
+// retentionConfigIsValid tests that the retention periods are not negative and the purge interval is positive
+func retentionConfigIsValid(a *Config) []error {
+	var retentionErrors []error
+
+	if a.RetentionConfig.AuditDays < 0 {
+		retentionErrors = append(retentionErrors, configError{Err: fmt.Sprintf("retentionconfig.auditdays must not be negative: %v", a.RetentionConfig.AuditDays)})
+	}
+	if a.RetentionConfig.AuditDays > 0 && a.RetentionConfig.Interval <= 0 {
+		retentionErrors = append(retentionErrors, configError{Err: fmt.Sprintf("retentionconfig.interval must be positive: %v", a.RetentionConfig.Interval)})
+	}
+
+	return retentionErrors
+}
+
+// aggregationConfigIsValid tests that the aggregation window is not negative
+func aggregationConfigIsValid(a *Config) []error {
+	var aggregationErrors []error
+
+	if a.AggregationConfig.Window < 0 {
+		aggregationErrors = append(aggregationErrors, configError{Err: fmt.Sprintf("aggregationconfig.window must not be negative: %v", a.AggregationConfig.Window)})
+	}
+
+	return aggregationErrors
+}
+
+// breakerConfigIsValid tests that the circuit breakers open after a positive number of failures for a positive cooldown
+func breakerConfigIsValid(a *Config) []error {
+	var breakerErrors []error
+
+	if a.BreakerConfig.Threshold < 0 {
+		breakerErrors = append(breakerErrors, configError{Err: fmt.Sprintf("breakerconfig.threshold must not be negative: %v", a.BreakerConfig.Threshold)})
+	}
+	if a.BreakerConfig.Threshold > 0 && a.BreakerConfig.Cooldown <= 0 {
+		breakerErrors = append(breakerErrors, configError{Err: fmt.Sprintf("breakerconfig.cooldown must be positive: %v", a.BreakerConfig.Cooldown)})
+	}
+
+	return breakerErrors
+}
+
+// spoolConfigIsValid tests that the circuit breakers are enabled when alerts are spooled, as they trigger spooling,
+// and that the spooled alerts are not kept for a negative time
+func spoolConfigIsValid(a *Config) []error {
+	var spoolErrors []error
+
+	if a.SpoolConfig.MaxAge < 0 {
+		spoolErrors = append(spoolErrors, configError{Err: fmt.Sprintf("spoolconfig.maxage must not be negative: %v", a.SpoolConfig.MaxAge)})
+	}
+
+	if a.SpoolConfig.Dir != "" {
+		if a.BreakerConfig.Threshold == 0 {
+			spoolErrors = append(spoolErrors, configError{Err: "spoolconfig.dir requires breakerconfig.threshold"})
+		}
+		if a.SpoolConfig.DrainInterval <= 0 {
+			spoolErrors = append(spoolErrors, configError{Err: fmt.Sprintf("spoolconfig.draininterval must be positive: %v", a.SpoolConfig.DrainInterval)})
+		}
+	}
+
+	return spoolErrors
+}
+
+// outboxConfigIsValid tests that the unfinished tickets are reconciled periodically when the outbox is enabled
+func outboxConfigIsValid(a *Config) []error {
+	if a.OutboxConfig.Dir != "" && a.OutboxConfig.Interval <= 0 {
+		return []error{configError{Err: fmt.Sprintf("outboxconfig.interval must be positive: %v", a.OutboxConfig.Interval)}}
+	}
+	return nil
+}
+
+// dedupConfigIsValid tests that the suppression window is not negative
+func dedupConfigIsValid(a *Config) []error {
+	if a.DedupConfig.Window < 0 {
+		return []error{configError{Err: fmt.Sprintf("dedupconfig.window must not be negative: %v", a.DedupConfig.Window)}}
+	}
+	return nil
+}
+
+// floodConfigIsValid tests that the ticket cap is not negative, and its window is positive when it is set
+func floodConfigIsValid(a *Config) []error {
+	var floodErrors []error
+
+	if a.FloodConfig.MaxTickets < 0 {
+		floodErrors = append(floodErrors, configError{Err: fmt.Sprintf("floodconfig.maxtickets must not be negative: %v", a.FloodConfig.MaxTickets)})
+	}
+	if a.FloodConfig.MaxTickets > 0 && a.FloodConfig.Window <= 0 {
+		floodErrors = append(floodErrors, configError{Err: fmt.Sprintf("floodconfig.window must be positive: %v", a.FloodConfig.Window)})
+	}
+
+	return floodErrors
+}
+
+// digestConfigIsValid tests that the digest time is a time of day when digests are enabled
+func digestConfigIsValid(a *Config) []error {
+	var digestErrors []error
+
+	if len(a.DigestConfig.AlertNames) == 0 {
+		return digestErrors
+	}
+	if _, err := a.DigestConfig.TimeOfDay(); err != nil {
+		digestErrors = append(digestErrors, configError{Err: fmt.Sprintf("digestconfig.time must be a time of day like 09:00: %s", a.DigestConfig.Time)})
+	}
+	for i, name := range a.DigestConfig.AlertNames {
+		if name == "" {
+			digestErrors = append(digestErrors, configError{Err: fmt.Sprintf("missing required configuration value: digestconfig.alertnames[%d]", i)})
+		}
+	}
+
+	return digestErrors
+}
+
+// readOnlyConfigIsValid tests that the read-only verbs are set when read-only sessions are resolved automatically
+func readOnlyConfigIsValid(a *Config) []error {
+	var readOnlyErrors []error
+
+	if a.ReadOnlyConfig.Enabled && len(a.ReadOnlyConfig.Verbs) == 0 {
+		readOnlyErrors = append(readOnlyErrors, configError{Err: "missing required configuration value: readonlyconfig.verbs"})
+	}
+
+	return readOnlyErrors
+}
+
+// riskConfigIsValid tests that the risk rules have scores and the risk levels are named uniquely with positive minimum scores
+func riskConfigIsValid(a *Config) []error {
+	var riskErrors []error
+
+	for i, rule := range a.RiskConfig.Rules {
+		if rule.Score < 0 {
+			riskErrors = append(riskErrors, configError{Err: fmt.Sprintf("riskconfig.rules[%d].score must not be negative: %v", i, rule.Score)})
+		}
+	}
+
+	names := map[string]bool{}
+	for i, level := range a.RiskConfig.Levels {
+		if level.Name == "" {
+			riskErrors = append(riskErrors, configError{Err: fmt.Sprintf("missing required configuration value: riskconfig.levels[%d].name", i)})
+		} else if names[level.Name] {
+			riskErrors = append(riskErrors, configError{Err: fmt.Sprintf("riskconfig.levels[%d].name is not unique: %s", i, level.Name)})
+		}
+		names[level.Name] = true
+
+		if level.MinScore < 1 {
+			riskErrors = append(riskErrors, configError{Err: fmt.Sprintf("riskconfig.levels[%d].minscore must be at least 1: %v", i, level.MinScore)})
+		}
+	}
+
+	return riskErrors
+}
+
+// changeConfigIsValid tests that the change record pattern compiles, and that the tracker and
+// approved statuses are set when it is
+func changeConfigIsValid(a *Config) []error {
+	var changeErrors []error
+	c := a.ChangeConfig
+
+	if c.Pattern == "" {
+		return changeErrors
+	}
+
+	if _, err := regexp.Compile(c.Pattern); err != nil {
+		changeErrors = append(changeErrors, configError{Err: fmt.Sprintf("changeconfig.pattern failed to compile: %s", err)})
+	}
+	if _, ok := a.JiraInstance(c.Jira); !ok {
+		changeErrors = append(changeErrors, configError{Err: fmt.Sprintf("changeconfig references unknown jira instance: %s", c.Jira)})
+	}
+	if len(c.ApprovedStatuses) == 0 {
+		changeErrors = append(changeErrors, configError{Err: "missing required configuration value: changeconfig.approvedstatuses"})
+	}
+
+	return changeErrors
+}
+
+// logConfigIsValid tests that the log levels are set for known packages and the sampling is not negative
+func logConfigIsValid(a *Config) []error {
+	var logErrors []error
+
+	for pkg, level := range a.LogConfig.Levels {
+		if !slices.Contains(logPackages, strings.ToLower(pkg)) {
+			logErrors = append(logErrors, configError{Err: fmt.Sprintf("logconfig.levels has an unknown package %s, expected one of %s", pkg, strings.Join(logPackages, ", "))})
+		}
+		if !strings.EqualFold(level, LogLevelDebug) && !strings.EqualFold(level, LogLevelInfo) {
+			logErrors = append(logErrors, configError{Err: fmt.Sprintf("logconfig.levels.%s must be %s or %s: %s", pkg, LogLevelDebug, LogLevelInfo, level)})
+		}
+	}
+	if a.LogConfig.SampleEvery < 0 {
+		logErrors = append(logErrors, configError{Err: fmt.Sprintf("logconfig.sampleevery must not be negative: %v", a.LogConfig.SampleEvery)})
+	}
+
+	return logErrors
+}
+
+// businessHoursConfigIsValid tests that the time zone, hours, days and holidays of the business hours are valid
+func businessHoursConfigIsValid(a *Config) []error {
+	var businessHoursErrors []error
+	businessHours := a.BusinessHoursConfig
+
+	if !businessHours.Enabled {
+		return businessHoursErrors
+	}
+
+	if _, err := businessHours.Location(); err != nil {
+		businessHoursErrors = append(businessHoursErrors, configError{Err: fmt.Sprintf("businesshoursconfig.timezone is not a known time zone: %s", businessHours.TimeZone)})
+	}
+	if start, end, err := businessHours.Hours(); err != nil {
+		businessHoursErrors = append(businessHoursErrors, configError{Err: fmt.Sprintf("businesshoursconfig.start and businesshoursconfig.end must be times of day like 09:00: %s, %s", businessHours.Start, businessHours.End)})
+	} else if start >= end {
+		businessHoursErrors = append(businessHoursErrors, configError{Err: fmt.Sprintf("businesshoursconfig.start must be before businesshoursconfig.end: %s, %s", businessHours.Start, businessHours.End)})
+	}
+	if len(businessHours.Days) == 0 {
+		businessHoursErrors = append(businessHoursErrors, configError{Err: "missing required configuration value: businesshoursconfig.days"})
+	}
+	for i, day := range businessHours.Days {
+		if _, ok := parseWeekday(day); !ok {
+			businessHoursErrors = append(businessHoursErrors, configError{Err: fmt.Sprintf("businesshoursconfig.days[%d] is not a day of the week: %s", i, day)})
+		}
+	}
+	for i, holiday := range businessHours.Holidays {
+		if _, err := time.Parse(dateLayout, holiday); err != nil {
+			businessHoursErrors = append(businessHoursErrors, configError{Err: fmt.Sprintf("businesshoursconfig.holidays[%d] must be a date like 2024-12-25: %s", i, holiday)})
+		}
+	}
+
+	return businessHoursErrors
+}
+
+// reminderConfigIsValid tests that the reminder settings are usable when reminders are enabled
+func reminderConfigIsValid(a *Config) []error {
+	var reminderErrors []error
+
+	if !a.ReminderConfig.Enabled {
+		return reminderErrors
+	}
+
+	if a.ReminderConfig.Interval <= 0 {
+		reminderErrors = append(reminderErrors, configError{Err: fmt.Sprintf("reminderconfig.interval must be positive: %v", a.ReminderConfig.Interval)})
+	}
+	if a.ReminderConfig.IdleFor <= 0 {
+		reminderErrors = append(reminderErrors, configError{Err: fmt.Sprintf("reminderconfig.idlefor must be positive: %v", a.ReminderConfig.IdleFor)})
+	}
+	if a.ReminderConfig.MaxCount < 1 {
+		reminderErrors = append(reminderErrors, configError{Err: fmt.Sprintf("reminderconfig.maxcount must be at least 1: %v", a.ReminderConfig.MaxCount)})
+	}
+	if _, err := helpers.ParseTemplate("reminderTemplate", a.ReminderConfig.Template); err != nil {
+		reminderErrors = append(reminderErrors, configError{Err: fmt.Sprintf("reminder template failed to parse: %s", err)})
+	}
+
+	return reminderErrors
+}
@@ -15,11 +15,63 @@
 package config
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/spf13/viper"
 	"golang.org/x/exp/slices"
 )
 
+func TestProductionModeIsConfirmed(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *Config
+		want   int
+	}{
+		{"Dry-run needs no confirmation", &Config{DryRun: true}, 0},
+		{"Production mode with the confirmation should not fail", &Config{ProductionConfirmation: "create-jira-issues"}, 0},
+		{"Production mode without the confirmation should fail", &Config{}, 1},
+		{"Production mode with the wrong confirmation should fail", &Config{ProductionConfirmation: "yes"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := productionModeIsConfirmed(tt.config); len(got) != tt.want {
+				t.Errorf("productionModeIsConfirmed() = %v, want %v errors", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDryRunScopes(t *testing.T) {
+	tests := []struct {
+		name            string
+		config          *Config
+		wantTransitions bool
+		wantComments    bool
+		wantUpdates     bool
+	}{
+		{"Dry-run covers every change", &Config{DryRun: true}, true, true, true},
+		{"Production mode makes every change", &Config{}, false, false, false},
+		{"Scoped dry-run only covers its changes", &Config{DryRunConfig: DryRunConfig{Transitions: true}}, true, false, false},
+		{"Updates are scoped separately", &Config{DryRunConfig: DryRunConfig{Updates: true}}, false, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.config.DryRunTransitions(); got != tt.wantTransitions {
+				t.Errorf("DryRunTransitions() = %v, want %v", got, tt.wantTransitions)
+			}
+			if got := tt.config.DryRunComments(); got != tt.wantComments {
+				t.Errorf("DryRunComments() = %v, want %v", got, tt.wantComments)
+			}
+			if got := tt.config.DryRunUpdates(); got != tt.wantUpdates {
+				t.Errorf("DryRunUpdates() = %v, want %v", got, tt.wantUpdates)
+			}
+		})
+	}
+}
+
 func TestFieldsAreNotNil(t *testing.T) {
 	tests := []struct {
 		name   string
@@ -203,3 +255,969 @@ func TestEnsurePasswordIfUsernameProvided(t *testing.T) {
 		})
 	}
 }
+
+func TestJiraDocumentFormatIsValid(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *Config
+		want   []error
+	}{
+		{
+			"Supported formats should not fail",
+			&Config{
+				JiraConfig: JiraConfig{
+					DocumentFormat: "adf",
+				},
+			},
+			[]error{},
+		},
+		{
+			"Unsupported formats should fail",
+			&Config{
+				JiraConfig: JiraConfig{
+					DocumentFormat: "markdown",
+				},
+			},
+			[]error{
+				configError{Err: "jiraconfig.documentformat must be one of wiki, adf or auto: markdown"},
+			},
+		},
+		{
+			"Unsupported formats of other Jira instances should fail",
+			&Config{
+				JiraInstances: map[string]JiraConfig{
+					"security": {DocumentFormat: "markdown"},
+				},
+			},
+			[]error{
+				configError{Err: "jirainstances.security.documentformat must be one of wiki, adf or auto: markdown"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := jiraDocumentFormatIsValid(tt.config)
+			var failed bool = false
+			for _, err := range tt.want {
+				if !slices.Contains(got, err) {
+					t.Errorf("jiraDocumentFormatIsValid() missing expected error: %+v", err)
+					failed = true
+				}
+			}
+			// Placing this outside the loop so we don't print the whole list for each individual failure
+			if failed || len(got) != len(tt.want) {
+				t.Errorf("jiraDocumentFormatIsValid() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJiraInstanceSettingsAreValid(t *testing.T) {
+	config := &Config{
+		JiraConfig: JiraConfig{RateLimit: 10},
+		JiraInstances: map[string]JiraConfig{
+			"security": {RateLimit: -1, UserCacheTTL: -1, Sprint: -1, IncidentWindow: -1},
+		},
+	}
+	want := []error{
+		configError{Err: "jirainstances.security.ratelimit must not be negative: -1"},
+		configError{Err: "jirainstances.security.usercachettl must not be negative: -1ns"},
+		configError{Err: "jirainstances.security.sprint must not be negative: -1"},
+		configError{Err: "jirainstances.security.incidentwindow must not be negative: -1ns"},
+	}
+
+	var got []error
+	for _, validator := range []func(*Config) []error{jiraRateLimitsAreValid, jiraUserCacheIsValid, jiraSprintIsValid, jiraIncidentWindowIsValid} {
+		got = append(got, validator(config)...)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestJiraLabelSchemesAreValid(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *Config
+		want   []error
+	}{
+		{
+			"Default label scheme should not fail",
+			&Config{
+				JiraConfig: JiraConfig{LabelPrefix: "compliance-audit-router", LabelSeparator: ":"},
+			},
+			[]error{},
+		},
+		{
+			"Invalid label schemes should fail",
+			&Config{
+				JiraConfig: JiraConfig{LabelPrefix: "car staging", LabelSeparator: "::"},
+				JiraInstances: map[string]JiraConfig{
+					"security": {LabelPrefix: "car:security", LabelSeparator: ":"},
+				},
+			},
+			[]error{
+				configError{Err: `jiraconfig.labelprefix must not contain whitespace: "car staging"`},
+				configError{Err: `jiraconfig.labelseparator must be a single character other than whitespace or /: "::"`},
+				configError{Err: `jirainstances.security.labelprefix must not contain the label separator ":": car:security`},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := jiraLabelSchemesAreValid(tt.config)
+			var failed bool = false
+			for _, err := range tt.want {
+				if !slices.Contains(got, err) {
+					t.Errorf("jiraLabelSchemesAreValid() missing expected error: %+v", err)
+					failed = true
+				}
+			}
+			// Placing this outside the loop so we don't print the whole list for each individual failure
+			if failed || len(got) != len(tt.want) {
+				t.Errorf("jiraLabelSchemesAreValid() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJiraTransitionsAreValid(t *testing.T) {
+	transitions := map[string]string{"initial": "In Progress", "sre": "Pending Approval", "manager": "Done"}
+
+	tests := []struct {
+		name   string
+		config *Config
+		want   []error
+	}{
+		{
+			"Complete transitions should not fail",
+			&Config{
+				JiraConfig:    JiraConfig{Transitions: transitions},
+				JiraInstances: map[string]JiraConfig{"security": {}},
+			},
+			[]error{},
+		},
+		{
+			"Unknown transitions should not fail",
+			&Config{
+				JiraConfig: JiraConfig{Transitions: map[string]string{"initial": "In Progress", "sre": "Pending Approval", "manager": "Done", "closed": "Closed"}},
+			},
+			[]error{},
+		},
+		{
+			"Missing transitions should fail",
+			&Config{
+				JiraConfig: JiraConfig{Transitions: map[string]string{"initial": "In Progress", "sre": ""}},
+				JiraInstances: map[string]JiraConfig{
+					"security": {Transitions: map[string]string{"initial": "Open", "sre": "Review", "manager": "Closed"}},
+					"legacy":   {Transitions: map[string]string{"initial": "Open", "sre": "Review"}},
+				},
+			},
+			[]error{
+				configError{Err: "missing required configuration value: jiraconfig.transitions.sre"},
+				configError{Err: "missing required configuration value: jiraconfig.transitions.manager"},
+				configError{Err: "missing required configuration value: jirainstances.legacy.transitions.manager"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := jiraTransitionsAreValid(tt.config)
+			var failed bool = false
+			for _, err := range tt.want {
+				if !slices.Contains(got, err) {
+					t.Errorf("jiraTransitionsAreValid() missing expected error: %+v", err)
+					failed = true
+				}
+			}
+			// Placing this outside the loop so we don't print the whole list for each individual failure
+			if failed || len(got) != len(tt.want) {
+				t.Errorf("jiraTransitionsAreValid() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJiraConfigFor(t *testing.T) {
+	config := &Config{
+		JiraConfig: JiraConfig{
+			Key:       "DEFAULT",
+			IssueType: "Task",
+		},
+		Routing: []RoutingRule{
+			{AlertName: "BreakGlass", Key: "SEC", IssueType: "Incident"},
+			{Group: "osd-sre", Key: "OHSS"},
+			{AlertName: "BreakGlass", Key: "UNREACHABLE"},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		alertName     string
+		group         string
+		wantKey       string
+		wantIssueType string
+	}{
+		{"No matching rule keeps the defaults", "Other", "other", "DEFAULT", "Task"},
+		{"Alert name match overrides key and issue type", "BreakGlass", "osd-sre", "SEC", "Incident"},
+		{"Group match overrides only the key", "Other", "osd-sre", "OHSS", "Task"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := config.JiraConfigFor(tt.alertName, tt.group)
+			if got.Key != tt.wantKey || got.IssueType != tt.wantIssueType {
+				t.Errorf("JiraConfigFor() = %v/%v, want %v/%v", got.Key, got.IssueType, tt.wantKey, tt.wantIssueType)
+			}
+		})
+	}
+}
+
+func TestInheritJiraSettings(t *testing.T) {
+	defer viper.Reset()
+	viper.Set("jirainstances", map[string]interface{}{
+		"customer": map[string]interface{}{"host": "https://customer.example.org"},
+		"legacy":   map[string]interface{}{"watchmanager": false},
+	})
+
+	config := &Config{
+		JiraConfig: JiraConfig{WatchManager: true, ValidateOnStartup: true},
+		JiraInstances: map[string]JiraConfig{
+			"customer": {Host: "https://customer.example.org"},
+			"legacy":   {},
+		},
+	}
+	config.inheritJiraSettings()
+
+	if customer := config.JiraInstances["customer"]; !customer.WatchManager || !customer.ValidateOnStartup {
+		t.Errorf("inheritJiraSettings() customer = %+v, want the jiraconfig values", customer)
+	}
+	if legacy := config.JiraInstances["legacy"]; legacy.WatchManager || !legacy.ValidateOnStartup {
+		t.Errorf("inheritJiraSettings() legacy = %+v, want watchmanager kept false and validateonstartup inherited", legacy)
+	}
+}
+
+func TestMessageTemplateFor(t *testing.T) {
+	config := &Config{
+		MessageTemplate:  "default",
+		MessageTemplates: []MessageTemplateRule{{Alert: "sshaccess", Template: "ssh"}, {Alert: "compliance.node.debug", Template: "debug"}},
+	}
+
+	tests := map[string]string{
+		"SSHAccess":             "ssh",
+		"Compliance.Node.Debug": "debug",
+		"ClusterAdmin":          "default",
+		"":                      "default",
+	}
+	for alertName, want := range tests {
+		if got := config.MessageTemplateFor(alertName); got != want {
+			t.Errorf("MessageTemplateFor(%q) = %q, want %q", alertName, got, want)
+		}
+	}
+
+	config.MessageTemplates = append(config.MessageTemplates, MessageTemplateRule{Alert: "broken", Template: "{{.Username"})
+	if errs := templateCanBeParsed(config); len(errs) != 1 {
+		t.Errorf("templateCanBeParsed() = %v, want an error for messagetemplates[2]", errs)
+	}
+}
+
+func TestNonMemberJiraConfig(t *testing.T) {
+	config := &Config{
+		JiraConfig: JiraConfig{Key: "DEFAULT", IssueType: "Task"},
+		LDAPConfig: LDAPConfig{Enabled: true},
+		IdentityConfig: IdentityConfig{
+			RequiredGroup:    "cn=sre,ou=groups,dc=example,dc=org",
+			NonMemberRouting: RoutingRule{Key: "SEC"},
+		},
+	}
+
+	got := config.NonMemberJiraConfig()
+	if got.Key != "SEC" || got.IssueType != "Task" {
+		t.Errorf("NonMemberJiraConfig() = %v/%v, want SEC/Task", got.Key, got.IssueType)
+	}
+
+	if routed := config.RoutedJiraConfigs(); len(routed) != 2 || routed[1].Key != "SEC" {
+		t.Errorf("RoutedJiraConfigs() = %v, want the non-member config included", routed)
+	}
+}
+
+func TestRoutingRulesHaveMatchers(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *Config
+		want   []error
+	}{
+		{
+			"Rules with a matcher should not fail",
+			&Config{
+				Routing: []RoutingRule{{AlertName: "BreakGlass", Key: "SEC"}},
+			},
+			[]error{},
+		},
+		{
+			"Rules without a matcher should fail",
+			&Config{
+				Routing: []RoutingRule{{AlertName: "BreakGlass"}, {Key: "SEC"}},
+			},
+			[]error{
+				configError{Err: "routing[1] must set alertname or group"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := routingRulesHaveMatchers(tt.config)
+			var failed bool = false
+			for _, err := range tt.want {
+				if !slices.Contains(got, err) {
+					t.Errorf("routingRulesHaveMatchers() missing expected error: %+v", err)
+					failed = true
+				}
+			}
+			// Placing this outside the loop so we don't print the whole list for each individual failure
+			if failed || len(got) != len(tt.want) {
+				t.Errorf("routingRulesHaveMatchers() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJiraInstancesAreValid(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *Config
+		want   []error
+	}{
+		{
+			"Complete instances referenced by routing rules should not fail",
+			&Config{
+				JiraInstances: map[string]JiraConfig{
+					"customer": {Host: "https://customer.example.org", Token: "token", Key: "CUST", IssueType: "Task"},
+				},
+				Routing: []RoutingRule{{AlertName: "BreakGlass", Jira: "Customer"}},
+			},
+			[]error{},
+		},
+		{
+			"Incomplete instances should fail",
+			&Config{
+				JiraInstances: map[string]JiraConfig{
+					"customer": {Host: "customer.example.org"},
+				},
+			},
+			[]error{
+				configError{Err: "missing required configuration value: jirainstances.customer.token"},
+				configError{Err: "missing required configuration value: jirainstances.customer.key"},
+				configError{Err: "missing required configuration value: jirainstances.customer.issuetype"},
+				configError{Err: "jirainstances.customer.host invalid URL: customer.example.org"},
+			},
+		},
+		{
+			"Routing rules referencing unknown instances should fail",
+			&Config{
+				Routing: []RoutingRule{{AlertName: "BreakGlass", Jira: "missing"}},
+			},
+			[]error{
+				configError{Err: "routing[0] references unknown jira instance: missing"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := jiraInstancesAreValid(tt.config)
+			var failed bool = false
+			for _, err := range tt.want {
+				if !slices.Contains(got, err) {
+					t.Errorf("jiraInstancesAreValid() missing expected error: %+v", err)
+					failed = true
+				}
+			}
+			// Placing this outside the loop so we don't print the whole list for each individual failure
+			if failed || len(got) != len(tt.want) {
+				t.Errorf("jiraInstancesAreValid() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReminderConfigIsValid(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *Config
+		want   []error
+	}{
+		{
+			"Disabled reminders should not fail",
+			&Config{},
+			[]error{},
+		},
+		{
+			"Valid reminders should not fail",
+			&Config{
+				ReminderConfig: ReminderConfig{Enabled: true, Interval: time.Hour, IdleFor: 24 * time.Hour, MaxCount: 3, Template: "{{.Assignee}}"},
+			},
+			[]error{},
+		},
+		{
+			"Invalid reminders should fail",
+			&Config{
+				ReminderConfig: ReminderConfig{Enabled: true, Template: "{{.Assignee"},
+			},
+			[]error{
+				configError{Err: "reminderconfig.interval must be positive: 0s"},
+				configError{Err: "reminderconfig.idlefor must be positive: 0s"},
+				configError{Err: "reminderconfig.maxcount must be at least 1: 0"},
+				configError{Err: "reminder template failed to parse: template: reminderTemplate:1: unclosed action"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := reminderConfigIsValid(tt.config)
+			var failed bool = false
+			for _, err := range tt.want {
+				if !slices.Contains(got, err) {
+					t.Errorf("reminderConfigIsValid() missing expected error: %+v", err)
+					failed = true
+				}
+			}
+			// Placing this outside the loop so we don't print the whole list for each individual failure
+			if failed || len(got) != len(tt.want) {
+				t.Errorf("reminderConfigIsValid() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPipelineConfigIsValid(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *Config
+		want   []error
+	}{
+		{
+			"Default pipeline settings should not fail",
+			&Config{PipelineConfig: PipelineConfig{Workers: 4, QueueSize: 100, JiraParallelism: 1}},
+			[]error{},
+		},
+		{
+			"Pipeline settings without workers should fail",
+			&Config{PipelineConfig: PipelineConfig{QueueSize: -1}},
+			[]error{
+				configError{Err: "pipelineconfig.workers must be at least 1: 0"},
+				configError{Err: "pipelineconfig.queuesize must not be negative: -1"},
+				configError{Err: "pipelineconfig.jiraparallelism must be at least 1: 0"},
+			},
+		},
+		{
+			"Negative pipeline timeouts should fail",
+			&Config{PipelineConfig: PipelineConfig{Workers: 1, JiraParallelism: 1, SplunkTimeout: -time.Second, JiraTimeout: -time.Minute}},
+			[]error{
+				configError{Err: "pipelineconfig.splunktimeout must not be negative: -1s"},
+				configError{Err: "pipelineconfig.jiratimeout must not be negative: -1m0s"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := pipelineConfigIsValid(tt.config)
+			var failed bool = false
+			for _, err := range tt.want {
+				if !slices.Contains(got, err) {
+					t.Errorf("pipelineConfigIsValid() missing expected error: %+v", err)
+					failed = true
+				}
+			}
+			// Placing this outside the loop so we don't print the whole list for each individual failure
+			if failed || len(got) != len(tt.want) {
+				t.Errorf("pipelineConfigIsValid() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogConfigIsValid(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *Config
+		want   []error
+	}{
+		{
+			"Default log settings should not fail",
+			&Config{LogConfig: LogConfig{SampleEvery: 1}},
+			[]error{},
+		},
+		{
+			"Package log levels should not fail",
+			&Config{LogConfig: LogConfig{Levels: map[string]string{"jira": "debug", "splunk": "INFO"}}},
+			[]error{},
+		},
+		{
+			"Unknown packages and levels should fail",
+			&Config{LogConfig: LogConfig{Levels: map[string]string{"okta": "info", "ldap": "trace"}, SampleEvery: -1}},
+			[]error{
+				configError{Err: "logconfig.levels has an unknown package okta, expected one of listeners, splunk, jira, ldap"},
+				configError{Err: "logconfig.levels.ldap must be debug or info: trace"},
+				configError{Err: "logconfig.sampleevery must not be negative: -1"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := logConfigIsValid(tt.config)
+			var failed bool = false
+			for _, err := range tt.want {
+				if !slices.Contains(got, err) {
+					t.Errorf("logConfigIsValid() missing expected error: %+v", err)
+					failed = true
+				}
+			}
+			// Placing this outside the loop so we don't print the whole list for each individual failure
+			if failed || len(got) != len(tt.want) {
+				t.Errorf("logConfigIsValid() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnrichersAreValid(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *Config
+		want   []error
+	}{
+		{
+			"No enrichers should not fail",
+			&Config{},
+			[]error{},
+		},
+		{
+			"An http enricher should not fail",
+			&Config{Enrichers: []EnricherConfig{{Name: "ocm", Type: EnricherTypeHTTP, URL: "https://api.example.com/clusters/{{index .ClusterIDs 0}}", Fields: map[string]string{"owner": "owner.name"}}}},
+			[]error{},
+		},
+		{
+			"Invalid enrichers should fail",
+			&Config{Enrichers: []EnricherConfig{
+				{Name: "ocm", Type: EnricherTypeHTTP, URL: "https://api.example.com/{{.User", Timeout: -1},
+				{Name: "ocm", Type: "grpc"},
+				{Type: EnricherTypeHTTP, Fields: map[string]string{"owner": "owner"}},
+			}},
+			[]error{
+				configError{Err: "enrichers[0].timeout must not be negative: -1ns"},
+				configError{Err: "enrichers[0].url failed to parse: template: url:1: unclosed action"},
+				configError{Err: "missing required configuration value: enrichers[0].fields"},
+				configError{Err: "enrichers[1].name is not unique: ocm"},
+				configError{Err: "enrichers[1].type must be one of http: grpc"},
+				configError{Err: "missing required configuration value: enrichers[2].name"},
+				configError{Err: "missing required configuration value: enrichers[2].url"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := enrichersAreValid(tt.config)
+			var failed bool = false
+			for _, err := range tt.want {
+				if !slices.Contains(got, err) {
+					t.Errorf("enrichersAreValid() missing expected error: %+v", err)
+					failed = true
+				}
+			}
+			// Placing this outside the loop so we don't print the whole list for each individual failure
+			if failed || len(got) != len(tt.want) {
+				t.Errorf("enrichersAreValid() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOnCallConfigIsValid(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *Config
+		want   []error
+	}{
+		{
+			"A disabled on-call lookup should not fail",
+			&Config{},
+			[]error{},
+		},
+		{
+			"A PagerDuty lookup should not fail",
+			&Config{OnCallConfig: OnCallConfig{Provider: OnCallProviderPagerDuty, URL: "https://api.pagerduty.com", Token: "token", Action: OnCallActionFastTrack}},
+			[]error{},
+		},
+		{
+			"An incomplete lookup should fail",
+			&Config{OnCallConfig: OnCallConfig{Provider: "opsgenie", Action: "ignore", Timeout: -1}},
+			[]error{
+				configError{Err: "oncallconfig.provider must be pagerduty: opsgenie"},
+				configError{Err: "missing required configuration value: oncallconfig.url"},
+				configError{Err: "missing required configuration value: oncallconfig.token"},
+				configError{Err: "oncallconfig.action must be one of annotate, fasttrack or skip: ignore"},
+				configError{Err: "oncallconfig.timeout must not be negative: -1ns"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := onCallConfigIsValid(tt.config)
+			var failed bool = false
+			for _, err := range tt.want {
+				if !slices.Contains(got, err) {
+					t.Errorf("onCallConfigIsValid() missing expected error: %+v", err)
+					failed = true
+				}
+			}
+			// Placing this outside the loop so we don't print the whole list for each individual failure
+			if failed || len(got) != len(tt.want) {
+				t.Errorf("onCallConfigIsValid() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRiskConfigIsValid(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *Config
+		want   []error
+	}{
+		{
+			"Rules and levels should not fail",
+			&Config{RiskConfig: RiskConfig{
+				Rules:  []RiskRule{{Verbs: []string{"delete"}, Score: 50}},
+				Levels: []RiskLevel{{Name: "high", MinScore: 50, Priority: "Critical"}},
+			}},
+			[]error{},
+		},
+		{
+			"Invalid rules and levels should fail",
+			&Config{RiskConfig: RiskConfig{
+				Rules:  []RiskRule{{Score: -1}},
+				Levels: []RiskLevel{{Name: "high", MinScore: 50}, {Name: "high"}, {MinScore: 10}},
+			}},
+			[]error{
+				configError{Err: "riskconfig.rules[0].score must not be negative: -1"},
+				configError{Err: "riskconfig.levels[1].name is not unique: high"},
+				configError{Err: "riskconfig.levels[1].minscore must be at least 1: 0"},
+				configError{Err: "missing required configuration value: riskconfig.levels[2].name"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := riskConfigIsValid(tt.config)
+			var failed bool = false
+			for _, err := range tt.want {
+				if !slices.Contains(got, err) {
+					t.Errorf("riskConfigIsValid() missing expected error: %+v", err)
+					failed = true
+				}
+			}
+			// Placing this outside the loop so we don't print the whole list for each individual failure
+			if failed || len(got) != len(tt.want) {
+				t.Errorf("riskConfigIsValid() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDigestConfigIsValid(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *Config
+		want   []error
+	}{
+		{
+			"No alert names should not fail",
+			&Config{DigestConfig: DigestConfig{Time: "noon"}},
+			[]error{},
+		},
+		{
+			"Alert names and a time of day should not fail",
+			&Config{DigestConfig: DigestConfig{AlertNames: []string{"read-only-elevation"}, Time: "09:30"}},
+			[]error{},
+		},
+		{
+			"Invalid time and empty alert name should fail",
+			&Config{DigestConfig: DigestConfig{AlertNames: []string{""}, Time: "noon"}},
+			[]error{
+				configError{Err: "digestconfig.time must be a time of day like 09:00: noon"},
+				configError{Err: "missing required configuration value: digestconfig.alertnames[0]"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := digestConfigIsValid(tt.config)
+			var failed bool = false
+			for _, err := range tt.want {
+				if !slices.Contains(got, err) {
+					t.Errorf("digestConfigIsValid() missing expected error: %+v", err)
+					failed = true
+				}
+			}
+			// Placing this outside the loop so we don't print the whole list for each individual failure
+			if failed || len(got) != len(tt.want) {
+				t.Errorf("digestConfigIsValid() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBusinessHoursConfigIsValid(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *Config
+		want   []error
+	}{
+		{
+			"Disabled business hours should not fail",
+			&Config{BusinessHoursConfig: BusinessHoursConfig{TimeZone: "Nowhere/Special"}},
+			[]error{},
+		},
+		{
+			"Valid business hours should not fail",
+			&Config{BusinessHoursConfig: BusinessHoursConfig{
+				Enabled: true, TimeZone: "America/New_York", Start: "09:00", End: "17:30",
+				Days: []string{"monday", "Friday"}, Holidays: []string{"2024-12-25"},
+			}},
+			[]error{},
+		},
+		{
+			"Invalid business hours should fail",
+			&Config{BusinessHoursConfig: BusinessHoursConfig{
+				Enabled: true, TimeZone: "Nowhere/Special", Start: "17:00", End: "09:00",
+				Days: []string{"Caturday"}, Holidays: []string{"25/12/2024"},
+			}},
+			[]error{
+				configError{Err: "businesshoursconfig.timezone is not a known time zone: Nowhere/Special"},
+				configError{Err: "businesshoursconfig.start must be before businesshoursconfig.end: 17:00, 09:00"},
+				configError{Err: "businesshoursconfig.days[0] is not a day of the week: Caturday"},
+				configError{Err: "businesshoursconfig.holidays[0] must be a date like 2024-12-25: 25/12/2024"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := businessHoursConfigIsValid(tt.config)
+			var failed bool = false
+			for _, err := range tt.want {
+				if !slices.Contains(got, err) {
+					t.Errorf("businessHoursConfigIsValid() missing expected error: %+v", err)
+					failed = true
+				}
+			}
+			// Placing this outside the loop so we don't print the whole list for each individual failure
+			if failed || len(got) != len(tt.want) {
+				t.Errorf("businessHoursConfigIsValid() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRiskLevelFor(t *testing.T) {
+	riskConfig := RiskConfig{Levels: []RiskLevel{{Name: "high", MinScore: 50}, {Name: "medium", MinScore: 10}}}
+
+	tests := []struct {
+		score  int
+		want   string
+		wantOK bool
+	}{
+		{0, "", false},
+		{10, "medium", true},
+		{49, "medium", true},
+		{90, "high", true},
+	}
+	for _, tt := range tests {
+		got, ok := riskConfig.LevelFor(tt.score)
+		if got.Name != tt.want || ok != tt.wantOK {
+			t.Errorf("LevelFor(%v) = %v, %v, want %v, %v", tt.score, got.Name, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestLDAPConfigIsValid(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *Config
+		want   []error
+	}{
+		{
+			"Disabled LDAP should not fail",
+			&Config{},
+			[]error{},
+		},
+		{
+			"Valid settings should not fail",
+			&Config{
+				LDAPConfig: LDAPConfig{
+					Enabled:      true,
+					UserFilter:   "({{.UIDAttribute}}={{.Username}})",
+					AttributeMap: LDAPAttributeMap{UID: "uid", Manager: "manager"},
+					PoolSize:     5,
+					IdleTimeout:  5 * time.Minute,
+				},
+			},
+			[]error{},
+		},
+		{
+			"Filters with unknown fields or unbalanced parentheses should fail",
+			&Config{
+				LDAPConfig: LDAPConfig{
+					Enabled:      true,
+					UserFilter:   "({{.UIDAttribute}}={{.Username}}",
+					GroupFilter:  "(memberOf={{.GroupDN}})",
+					AttributeMap: LDAPAttributeMap{UID: "uid", Manager: "manager"},
+					PoolSize:     5,
+				},
+			},
+			[]error{
+				configError{Err: `ldapconfig.userfilter is invalid: "(uid=user" is not a valid filter: LDAP Result Code 201 "Filter Compile Error": ldap: unexpected end of filter`},
+				configError{Err: `ldapconfig.groupfilter is invalid: template: filter:1:12: executing "filter" at <.GroupDN>: can't evaluate field GroupDN in type config.ldapFilterData`},
+			},
+		},
+		{
+			"Invalid settings should fail",
+			&Config{
+				LDAPConfig: LDAPConfig{Enabled: true, UserFilter: "(uid={{.Username", IdleTimeout: -time.Minute, PageSize: -1},
+			},
+			[]error{
+				configError{Err: "ldapconfig.userfilter is invalid: template: filter:1: unclosed action"},
+				configError{Err: "ldapconfig.attributemap.uid and ldapconfig.attributemap.manager must be set"},
+				configError{Err: "ldapconfig.poolsize must be at least 1: 0"},
+				configError{Err: "ldapconfig.idletimeout must not be negative: -1m0s"},
+				configError{Err: "ldapconfig.pagesize must not be negative: -1"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ldapConfigIsValid(tt.config)
+			var failed bool = false
+			for _, err := range tt.want {
+				if !slices.Contains(got, err) {
+					t.Errorf("ldapConfigIsValid() missing expected error: %+v", err)
+					failed = true
+				}
+			}
+			// Placing this outside the loop so we don't print the whole list for each individual failure
+			if failed || len(got) != len(tt.want) {
+				t.Errorf("ldapConfigIsValid() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIdentityConfigIsValid(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *Config
+		want   []error
+	}{
+		{
+			"No identity provider should not fail",
+			&Config{},
+			[]error{},
+		},
+		{
+			"Enabled LDAP should select the LDAP provider",
+			&Config{
+				LDAPConfig:     LDAPConfig{Enabled: true},
+				IdentityConfig: IdentityConfig{ManagerChainDepth: 2},
+			},
+			[]error{},
+		},
+		{
+			"Okta without its settings should fail",
+			&Config{
+				IdentityConfig: IdentityConfig{Provider: "okta"},
+				OktaConfig:     OktaConfig{OrgURL: "http://example.okta.com", UsernameAttribute: "login"},
+			},
+			[]error{
+				configError{Err: "oktaconfig.orgurl invalid URL: http://example.okta.com"},
+				configError{Err: "missing required configuration value: oktaconfig.token"},
+			},
+		},
+		{
+			"Azure without its settings should fail",
+			&Config{
+				IdentityConfig: IdentityConfig{Provider: "azure"},
+				AzureConfig:    AzureConfig{TenantID: "tenant", AuthorityURL: "https://login.microsoftonline.com", GraphURL: "https://graph.microsoft.com"},
+			},
+			[]error{
+				configError{Err: "missing required configuration value: azureconfig.clientid"},
+				configError{Err: "missing required configuration value: azureconfig.clientsecret"},
+			},
+		},
+		{
+			"Google without its settings should fail",
+			&Config{
+				IdentityConfig: IdentityConfig{Provider: "google"},
+				GoogleConfig:   GoogleConfig{CredentialsFile: "/nonexistent/credentials.json"},
+			},
+			[]error{
+				configError{Err: "googleconfig.credentialsfile can't be read: /nonexistent/credentials.json"},
+				configError{Err: "missing required configuration value: googleconfig.subject"},
+			},
+		},
+		{
+			"Invalid identity settings should fail",
+			&Config{
+				IdentityConfig: IdentityConfig{Provider: "nope", ManagerChainDepth: -1},
+			},
+			[]error{
+				configError{Err: "identityconfig.provider must be one of ldap, okta, azure or google: nope"},
+				configError{Err: "identityconfig.managerchaindepth must not be negative: -1"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := identityConfigIsValid(tt.config)
+			var failed bool = false
+			for _, err := range tt.want {
+				if !slices.Contains(got, err) {
+					t.Errorf("identityConfigIsValid() missing expected error: %+v", err)
+					failed = true
+				}
+			}
+			// Placing this outside the loop so we don't print the whole list for each individual failure
+			if failed || len(got) != len(tt.want) {
+				t.Errorf("identityConfigIsValid() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFormats(t *testing.T) {
+	files := map[string]string{
+		"config.json": `{"listenport": 8081, "jiraconfig": {"key": "OHSS"}}`,
+		"config.toml": "listenport = 8081\n\n[jiraconfig]\nkey = \"OHSS\"\n",
+		// Without an extension, the format is set with configtype
+		"config": "listenport: 8081\njiraconfig:\n  key: OHSS\n",
+	}
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			defer viper.Reset()
+			defer func() { SetAppConfig(Config{}) }()
+
+			file := filepath.Join(t.TempDir(), name)
+			if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+				t.Fatalf("failed to write the config file: %v", err)
+			}
+			viper.SetConfigFile(file)
+			if filepath.Ext(file) == "" {
+				viper.Set("configtype", "yaml")
+			}
+
+			LoadConfig()
+
+			if AppConfig().ListenPort != 8081 || AppConfig().JiraConfig.Key != "OHSS" {
+				t.Errorf("LoadConfig() read listenport %v and jiraconfig.key %q, want 8081 and OHSS", AppConfig().ListenPort, AppConfig().JiraConfig.Key)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+const (
+	// kubernetesDataDir is the symlink Kubernetes atomically replaces to update a mounted ConfigMap or Secret:
+	// the files of the volume are symlinks into it
+	kubernetesDataDir = "..data"
+	// configDirDebounce is how long the watcher waits for the remaining changes of an update before reloading
+	configDirDebounce = time.Second
+)
+
+// configDirs are the directories the config files are read from, eg. mounted ConfigMap and Secret volumes
+func configDirs() []string {
+	return viper.GetStringSlice("configdirs")
+}
+
+// configDirFiles returns the config files of the directory, in name order. Hidden entries, like the
+// timestamped directories of a Kubernetes volume, and files of unsupported formats are ignored.
+func configDirFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if isConfigDirFile(entry.Name()) {
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// configDirExts are the extensions of the config files read from a config directory. Other files,
+// eg. a README or a properties file of another application in the same ConfigMap, are ignored.
+var configDirExts = []string{"yaml", "yml", "json", "toml"}
+
+// isConfigDirFile reports whether the file name is a config file of a config directory
+func isConfigDirFile(name string) bool {
+	return !strings.HasPrefix(name, ".") && slices.Contains(configDirExts, strings.TrimPrefix(filepath.Ext(name), "."))
+}
+
+// mergeConfigDirs merges the config files of the config directories over the config file, in order.
+// The files of a mounted Kubernetes volume are read through its symlinks, so they are the current version.
+func mergeConfigDirs() error {
+	for _, dir := range configDirs() {
+		files, err := configDirFiles(dir)
+		if err != nil {
+			return fmt.Errorf("failed to read config directory %s: %w", dir, err)
+		}
+		for _, file := range files {
+			v := viper.New()
+			v.SetConfigFile(file)
+			if err := v.ReadInConfig(); err != nil {
+				return fmt.Errorf("failed to read %s: %w", file, err)
+			}
+			if err := viper.MergeConfigMap(v.AllSettings()); err != nil {
+				return fmt.Errorf("failed to merge %s: %w", file, err)
+			}
+		}
+	}
+	return nil
+}
+
+// readConfig reads the config file and merges the config directories over it. The config file
+// is optional when config directories are set. The settings read before are replaced rather than
+// merged into, so settings removed from the files since are dropped on reload.
+func readConfig() error {
+	err := viper.ReadInConfig()
+	var notFound viper.ConfigFileNotFoundError
+	if errors.As(err, &notFound) && len(configDirs()) > 0 {
+		// Without a config file, ReadInConfig keeps the settings read before: they are replaced
+		// with an empty configuration, which only the config directories are merged into
+		err = viper.ReadConfig(bytes.NewReader(nil))
+	}
+	if err != nil {
+		return err
+	}
+	return mergeConfigDirs()
+}
+
+// watchConfigDirs calls onChange with the directory once the files of a config directory change.
+// A Kubernetes volume is updated by replacing its ..data symlink, which is watched rather than
+// the symlinks of its files; the several events of an update are reported once.
+func watchConfigDirs(dirs []string, onChange func(dir string)) (*fsnotify.Watcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return nil, fmt.Errorf("failed to watch config directory %s: %w", dir, err)
+		}
+	}
+
+	go func() {
+		timers := map[string]*time.Timer{}
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				name := filepath.Base(event.Name)
+				if name != kubernetesDataDir && !isConfigDirFile(name) {
+					continue
+				}
+				dir := filepath.Dir(event.Name)
+				if timer, ok := timers[dir]; ok {
+					timer.Reset(configDirDebounce)
+					continue
+				}
+				timers[dir] = time.AfterFunc(configDirDebounce, func() { onChange(dir) })
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("failed watching config directories: %v", err)
+			}
+		}
+	}()
+	return watcher, nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2021-2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// writeKubernetesVolume writes the files like Kubernetes updates a mounted ConfigMap or Secret: into a new
+// timestamped directory, atomically replacing the ..data symlink to it, with the files symlinked into ..data
+func writeKubernetesVolume(t *testing.T, dir, version string, files map[string]string) {
+	t.Helper()
+
+	dataDir := filepath.Join(dir, "..2024_01_01_00_00_00."+version)
+	if err := os.Mkdir(dataDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dataDir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(filepath.Join(kubernetesDataDir, name), filepath.Join(dir, name)); err != nil && !os.IsExist(err) {
+			t.Fatal(err)
+		}
+	}
+
+	tmp := filepath.Join(dir, "..data_tmp")
+	if err := os.Symlink(filepath.Base(dataDir), tmp); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, kubernetesDataDir)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConfigDirs(t *testing.T) {
+	defer viper.Reset()
+	defer func() { SetAppConfig(Config{}) }()
+
+	configMap, secret := t.TempDir(), t.TempDir()
+	writeKubernetesVolume(t, configMap, "1", map[string]string{
+		"config.yaml": "listenport: 8081\njiraconfig:\n  key: OHSS\n",
+		"README":      "not a config file",
+		// Another application's properties, which viper could read, but aren't a config file of the router
+		"zz.properties": "listenport=9000\n",
+	})
+	writeKubernetesVolume(t, secret, "1", map[string]string{"credentials.json": `{"jiraconfig": {"token": "secret"}}`})
+
+	viper.Set("configdirs", []string{configMap, secret})
+	LoadConfig()
+
+	if AppConfig().ListenPort != 8081 || AppConfig().JiraConfig.Key != "OHSS" || AppConfig().JiraConfig.Token != "secret" {
+		t.Fatalf("LoadConfig() read listenport %v, jiraconfig.key %q and a token %v, want the config directories' settings",
+			AppConfig().ListenPort, AppConfig().JiraConfig.Key, AppConfig().JiraConfig.Token != "")
+	}
+
+	changed := make(chan string, 1)
+	watcher, err := watchConfigDirs([]string{configMap, secret}, func(dir string) { changed <- dir })
+	if err != nil {
+		t.Fatalf("watchConfigDirs() unexpected error: %v", err)
+	}
+	defer watcher.Close()
+
+	writeKubernetesVolume(t, configMap, "2", map[string]string{"config.yaml": "jiraconfig:\n  key: CAR\n"})
+	select {
+	case dir := <-changed:
+		if dir != configMap {
+			t.Errorf("watchConfigDirs() reported %v changed, want %v", dir, configMap)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("watchConfigDirs() did not report the updated volume")
+	}
+
+	if err := readConfig(); err != nil {
+		t.Fatalf("readConfig() unexpected error: %v", err)
+	}
+	if key := viper.GetString("jiraconfig.key"); key != "CAR" {
+		t.Errorf("readConfig() read jiraconfig.key %q, want the updated CAR", key)
+	}
+	// Without a config file, the settings removed from the directories must not linger
+	if viper.InConfig("listenport") {
+		t.Errorf("readConfig() kept listenport %v, which was removed from the config directory", viper.Get("listenport"))
+	}
+}
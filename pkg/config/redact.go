@@ -0,0 +1,70 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"gopkg.in/yaml.v3"
+
+	"github.com/openshift/compliance-audit-router/pkg/helpers"
+)
+
+// redacted replaces sensitive values in logs and the effective configuration
+const redacted = helpers.Redacted
+
+// Redacted returns a copy of the config with the credentials masked, so the effective
+// configuration can be shown without exposing them. Unset credentials are left empty.
+func (a *Config) Redacted() Config {
+	c := *a
+
+	mask := func(value *string) {
+		if *value != "" {
+			*value = redacted
+		}
+	}
+
+	mask(&c.SplunkConfig.Token)
+	mask(&c.JiraConfig.Token)
+	mask(&c.LDAPConfig.Password)
+	mask(&c.OktaConfig.Token)
+	mask(&c.AzureConfig.ClientSecret)
+	mask(&c.OnCallConfig.Token)
+	mask(&c.AdminConfig.Token)
+
+	if a.JiraInstances != nil {
+		c.JiraInstances = make(map[string]JiraConfig, len(a.JiraInstances))
+		for name, instance := range a.JiraInstances {
+			mask(&instance.Token)
+			c.JiraInstances[name] = instance
+		}
+	}
+
+	if a.Enrichers != nil {
+		c.Enrichers = make([]EnricherConfig, len(a.Enrichers))
+		for i, enricher := range a.Enrichers {
+			mask(&enricher.Token)
+			c.Enrichers[i] = enricher
+		}
+	}
+
+	return c
+}
+
+// RedactedYAML renders the effective configuration, merged from the config file, environment
+// and defaults, as YAML with the credentials masked. The keys are those of the config file.
+func (a *Config) RedactedYAML() ([]byte, error) {
+	return yaml.Marshal(a.Redacted())
+}
@@ -0,0 +1,54 @@
+// Copyright 2021-2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRedacted(t *testing.T) {
+	a := &Config{
+		SplunkConfig:   SplunkConfig{Host: "https://splunk.example.com", Token: "splunk-token"},
+		LDAPConfig:     LDAPConfig{Username: "reader", IdleTimeout: 5 * time.Minute},
+		JiraInstances:  map[string]JiraConfig{"security": {Token: "jira-token", TokenFile: "/secrets/jira"}},
+		ReminderConfig: ReminderConfig{Interval: time.Hour},
+	}
+
+	r := a.Redacted()
+	if r.SplunkConfig.Token != redacted || r.JiraInstances["security"].Token != redacted {
+		t.Errorf("Redacted() did not mask the tokens: %+v", r)
+	}
+	if r.LDAPConfig.Password != "" {
+		t.Errorf("Redacted() masked the unset LDAP password")
+	}
+	if a.SplunkConfig.Token != "splunk-token" || a.JiraInstances["security"].Token != "jira-token" {
+		t.Errorf("Redacted() modified the config")
+	}
+
+	out, err := a.RedactedYAML()
+	if err != nil {
+		t.Fatalf("RedactedYAML() unexpected error: %v", err)
+	}
+	for _, want := range []string{"host: https://splunk.example.com", "tokenfile: /secrets/jira", "idletimeout: 5m0s", "interval: 1h0m0s"} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("RedactedYAML() = %s, want it to contain %q", out, want)
+		}
+	}
+	if strings.Contains(string(out), "splunk-token") || strings.Contains(string(out), "jira-token") {
+		t.Errorf("RedactedYAML() exposed a token: %s", out)
+	}
+}
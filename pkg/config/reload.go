@@ -0,0 +1,149 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+var reloadMu sync.Mutex
+
+// Reload re-reads the configuration and applies the settings that can change without a restart:
+// the templates, routing rules, tenants, Jira transitions, verbose logging, log levels and dry-run mode.
+// The reloaded configuration must be valid, or the current configuration is kept.
+// Other settings, eg. hosts, credentials and identity providers, take effect on restart.
+func Reload() error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	if err := readConfig(); err != nil {
+		return fmt.Errorf("failed to read the configuration: %w", err)
+	}
+
+	var reloaded Config
+	if err := viper.Unmarshal(&reloaded); err != nil {
+		return fmt.Errorf("failed to unmarshal the configuration: %w", err)
+	}
+	reloaded.inheritJiraSettings()
+	for _, secretErr := range append(reloaded.LoadSecretFiles(), reloaded.ResolveSecretReferences()...) {
+		log.Print(secretErr)
+	}
+	if !reloaded.Valid() {
+		return errors.New("reloaded configuration invalid; keeping the current configuration")
+	}
+	current := AppConfig()
+	if err := reloadable(current, &reloaded); err != nil {
+		return err
+	}
+
+	next := applyReloadable(current, &reloaded)
+	SetAppConfig(next)
+	if next.Mode() != current.Mode() || next.DryRunConfig != current.DryRunConfig {
+		next.LogMode()
+	}
+	return nil
+}
+
+// reloadable checks that the reloaded settings can be applied to the current configuration.
+// Routing rules and tenants may only select Jira instances that were configured at startup.
+func reloadable(current, reloaded *Config) error {
+	rules := append([]RoutingRule{reloaded.IdentityConfig.NonMemberRouting}, reloaded.Routing...)
+	for _, tenant := range reloaded.Tenants {
+		rules = append(rules, RoutingRule{Jira: tenant.Jira}, tenant.IdentityConfig.NonMemberRouting)
+	}
+	for _, rule := range rules {
+		if _, ok := current.JiraInstance(rule.Jira); !ok {
+			return fmt.Errorf("reloaded routing selects jira instance %s, which requires a restart", rule.Jira)
+		}
+	}
+	return nil
+}
+
+// applyReloadable returns a copy of the current configuration with the settings that can change without a restart
+// taken from the reloaded one. The current configuration isn't changed, as requests may be reading it.
+func applyReloadable(active, reloaded *Config) Config {
+	current := *active
+	current.Verbose = reloaded.Verbose
+	current.LogConfig = reloaded.LogConfig
+	current.DryRun = reloaded.DryRun
+	current.ProductionConfirmation = reloaded.ProductionConfirmation
+	current.DryRunConfig = reloaded.DryRunConfig
+	current.MessageTemplate = reloaded.MessageTemplate
+	current.MessageTemplates = reloaded.MessageTemplates
+	current.SummaryTemplate = reloaded.SummaryTemplate
+	current.ReminderConfig.Template = reloaded.ReminderConfig.Template
+
+	current.Routing = reloaded.Routing
+	current.IdentityConfig.NonMemberRouting = reloaded.IdentityConfig.NonMemberRouting
+	current.Tenants = reloaded.Tenants
+
+	current.JiraConfig.Transitions = reloaded.JiraConfig.Transitions
+	current.JiraInstances = maps.Clone(active.JiraInstances)
+	for name, instance := range current.JiraInstances {
+		instance.Transitions = reloaded.JiraInstances[name].Transitions
+		current.JiraInstances[name] = instance
+	}
+	return current
+}
+
+// WatchConfig reloads the configuration when the configuration file or directories change or the
+// process receives SIGHUP, calling onReload with the result of each reload
+func WatchConfig(onReload func(error)) {
+	reload := func(reason string) {
+		log.Printf("reloading configuration: %s", reason)
+		err := Reload()
+		if err != nil {
+			log.Printf("failed reloading configuration: %s", err.Error())
+		} else {
+			log.Print("INFO: configuration reloaded")
+		}
+		onReload(err)
+	}
+
+	if viper.ConfigFileUsed() != "" {
+		viper.OnConfigChange(func(e fsnotify.Event) {
+			reload(fmt.Sprintf("%s changed", e.Name))
+		})
+		viper.WatchConfig()
+	}
+
+	if dirs := configDirs(); len(dirs) > 0 {
+		if _, err := watchConfigDirs(dirs, func(dir string) {
+			reload(fmt.Sprintf("%s changed", dir))
+		}); err != nil {
+			log.Printf("failed watching config directories; they are reloaded on SIGHUP only: %v", err)
+		}
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go func() {
+		for range signals {
+			reload("received SIGHUP")
+		}
+	}()
+}
@@ -0,0 +1,67 @@
+// Copyright 2021-2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"testing"
+)
+
+func TestApplyReloadable(t *testing.T) {
+	current := &Config{
+		DryRun:          true,
+		MessageTemplate: "old",
+		SplunkConfig:    SplunkConfig{Host: "https://splunk.example.org"},
+		JiraConfig:      JiraConfig{Host: "https://jira.example.org", Transitions: map[string]string{"initial": "Open"}},
+		JiraInstances:   map[string]JiraConfig{"security": {Host: "https://security.example.org"}},
+	}
+	reloaded := &Config{
+		DryRun:          false,
+		MessageTemplate: "new",
+		SplunkConfig:    SplunkConfig{Host: "https://other.example.org"},
+		JiraConfig:      JiraConfig{Host: "https://other.example.org", Transitions: map[string]string{"initial": "In Progress"}},
+		JiraInstances: map[string]JiraConfig{"security": {
+			Host:        "https://other.example.org",
+			Transitions: map[string]string{"initial": "Triage"},
+		}},
+		Routing: []RoutingRule{{AlertName: "admin", Jira: "security"}},
+	}
+
+	if err := reloadable(current, reloaded); err != nil {
+		t.Fatalf("reloadable() unexpected error: %v", err)
+	}
+	applied := applyReloadable(current, reloaded)
+
+	if applied.DryRun || applied.MessageTemplate != "new" || len(applied.Routing) != 1 {
+		t.Errorf("applyReloadable() did not apply the reloadable settings: %+v", applied)
+	}
+	if applied.JiraConfig.Transitions["initial"] != "In Progress" || applied.JiraInstances["security"].Transitions["initial"] != "Triage" {
+		t.Errorf("applyReloadable() did not apply the transitions")
+	}
+	if applied.SplunkConfig.Host != "https://splunk.example.org" || applied.JiraConfig.Host != "https://jira.example.org" ||
+		applied.JiraInstances["security"].Host != "https://security.example.org" {
+		t.Errorf("applyReloadable() changed settings that require a restart")
+	}
+
+	// Requests may still be reading the current configuration, so it must not change
+	if !current.DryRun || current.MessageTemplate != "old" || current.JiraInstances["security"].Transitions != nil {
+		t.Errorf("applyReloadable() changed the current configuration: %+v", current)
+	}
+
+	// Routing to a Jira instance added since startup requires a restart
+	reloaded.Routing = []RoutingRule{{AlertName: "admin", Jira: "new"}}
+	if err := reloadable(&applied, reloaded); err == nil {
+		t.Errorf("reloadable() expected an error for an unknown jira instance")
+	}
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RuntimeSettings are the settings changed on a running instance through the admin API, eg. during incident debugging.
+// Settings left unset are not changed.
+type RuntimeSettings struct {
+	DryRun  *bool `json:"dryRun,omitempty"`
+	Verbose *bool `json:"verbose,omitempty"`
+	// LogLevels set the log level of the packages, merged into logconfig.levels
+	LogLevels map[string]string `json:"logLevels,omitempty"`
+	// ProductionConfirmation confirms disabling dry-run when productionconfirmation is not set, like it
+	ProductionConfirmation string `json:"productionConfirmation,omitempty"`
+}
+
+// ActiveRuntimeSettings returns the active dry-run mode, verbosity and log levels
+func ActiveRuntimeSettings() RuntimeSettings {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	return runtimeSettings(AppConfig())
+}
+
+// UpdateRuntimeSettings applies the settings once they are valid, returning the changes made by setting, eg.
+// {"dryrun": "true -> false"}. The settings last until the configuration is reloaded or the router restarts.
+func UpdateRuntimeSettings(settings RuntimeSettings) (map[string]string, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	current := AppConfig()
+	updated := *current
+	if settings.DryRun != nil {
+		updated.DryRun = *settings.DryRun
+	}
+	if settings.ProductionConfirmation != "" {
+		updated.ProductionConfirmation = settings.ProductionConfirmation
+	}
+	if settings.Verbose != nil {
+		updated.Verbose = *settings.Verbose
+	}
+	if len(settings.LogLevels) > 0 {
+		updated.LogConfig.Levels = make(map[string]string, len(current.LogConfig.Levels)+len(settings.LogLevels))
+		for pkg, level := range current.LogConfig.Levels {
+			updated.LogConfig.Levels[strings.ToLower(pkg)] = level
+		}
+		for pkg, level := range settings.LogLevels {
+			updated.LogConfig.Levels[strings.ToLower(pkg)] = strings.ToLower(level)
+		}
+	}
+
+	settingErrors := append(productionModeIsConfirmed(&updated), logConfigIsValid(&updated)...)
+	if len(settingErrors) > 0 {
+		return nil, fmt.Errorf("invalid settings: %w", errors.Join(settingErrors...))
+	}
+
+	changes := map[string]string{}
+	change := func(name, from, to string) {
+		if from != to {
+			changes[name] = from + " -> " + to
+		}
+	}
+	change("dryrun", strconv.FormatBool(current.DryRun), strconv.FormatBool(updated.DryRun))
+	change("verbose", strconv.FormatBool(current.Verbose), strconv.FormatBool(updated.Verbose))
+	for pkg, level := range updated.LogConfig.Levels {
+		change("logconfig.levels."+pkg, current.LogConfig.Levels[pkg], level)
+	}
+
+	SetAppConfig(updated)
+	if updated.Mode() != current.Mode() {
+		updated.LogMode()
+	}
+	return changes, nil
+}
+
+func runtimeSettings(a *Config) RuntimeSettings {
+	dryRun, verbose := a.DryRun, a.Verbose
+	levels := make(map[string]string, len(a.LogConfig.Levels))
+	for pkg, level := range a.LogConfig.Levels {
+		levels[pkg] = level
+	}
+	return RuntimeSettings{DryRun: &dryRun, Verbose: &verbose, LogLevels: levels}
+}
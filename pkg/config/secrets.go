@@ -0,0 +1,149 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"strings"
+
+	"github.com/openshift/compliance-audit-router/pkg/secrets"
+)
+
+// LoadSecretFiles reads the credentials configured as files, eg. mounted Kubernetes Secrets,
+// into their settings, so they don't have to be set in the config file or environment.
+// A credential whose file can't be read keeps its current value.
+func (a *Config) LoadSecretFiles() []error {
+	var secretErrors []error
+
+	load := func(key, path string, value *string) {
+		if path == "" {
+			return
+		}
+		secret, err := readSecretFile(path)
+		if err != nil {
+			secretErrors = append(secretErrors, configError{Err: fmt.Sprintf("%s can't be read: %s", key, path)})
+			return
+		}
+		*value = secret
+	}
+
+	load("splunkconfig.tokenfile", a.SplunkConfig.TokenFile, &a.SplunkConfig.Token)
+	load("jiraconfig.tokenfile", a.JiraConfig.TokenFile, &a.JiraConfig.Token)
+	load("ldapconfig.passwordfile", a.LDAPConfig.PasswordFile, &a.LDAPConfig.Password)
+	for name, instance := range a.JiraInstances {
+		load(fmt.Sprintf("jirainstances.%s.tokenfile", name), instance.TokenFile, &instance.Token)
+		a.JiraInstances[name] = instance
+	}
+
+	return secretErrors
+}
+
+// ResolveSecretReferences replaces the credentials set to secret manager references,
+// eg. awssm://name or gcpsm://project/name, with the referenced secrets.
+// A credential whose secret can't be resolved keeps the reference, and fails validation.
+func (a *Config) ResolveSecretReferences() []error {
+	var secretErrors []error
+
+	a.secretReferences(func(key string, value *string) {
+		if !secrets.IsReference(*value) {
+			return
+		}
+		secret, err := secrets.Resolve(*value)
+		if err != nil {
+			secretErrors = append(secretErrors, configError{Err: fmt.Sprintf("%s: %s", key, err.Error())})
+			return
+		}
+		*value = secret
+	})
+
+	return secretErrors
+}
+
+// secretReferences calls visit with the key and value of every credential that may be set
+// to a secret manager reference. Changes made by visit are kept.
+func (a *Config) secretReferences(visit func(key string, value *string)) {
+	visit("splunkconfig.token", &a.SplunkConfig.Token)
+	visit("jiraconfig.token", &a.JiraConfig.Token)
+	visit("ldapconfig.password", &a.LDAPConfig.Password)
+	visit("oktaconfig.token", &a.OktaConfig.Token)
+	visit("azureconfig.clientsecret", &a.AzureConfig.ClientSecret)
+	for name, instance := range a.JiraInstances {
+		token := instance.Token
+		visit(fmt.Sprintf("jirainstances.%s.token", name), &instance.Token)
+		if instance.Token != token {
+			a.JiraInstances[name] = instance
+		}
+	}
+	visit("oncallconfig.token", &a.OnCallConfig.Token)
+	visit("adminconfig.token", &a.AdminConfig.Token)
+	for i := range a.Enrichers {
+		visit(fmt.Sprintf("enrichers[%d].token", i), &a.Enrichers[i].Token)
+	}
+}
+
+// secretReferencesAreResolved tests that no credential is left set to a secret manager reference,
+// which is the case when ResolveSecretReferences failed to resolve it
+func secretReferencesAreResolved(a *Config) []error {
+	var secretErrors []error
+
+	a.secretReferences(func(key string, value *string) {
+		if secrets.IsReference(*value) {
+			secretErrors = append(secretErrors, configError{Err: fmt.Sprintf("%s: secret reference %s could not be resolved", key, *value)})
+		}
+	})
+
+	return secretErrors
+}
+
+// RefreshSecretFiles re-reads the secret files into a copy of the current configuration and publishes it,
+// picking up rotated credentials without a restart
+func RefreshSecretFiles() {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	refreshed := *AppConfig()
+	refreshed.JiraInstances = maps.Clone(refreshed.JiraInstances)
+	for _, err := range refreshed.LoadSecretFiles() {
+		log.Print(err)
+	}
+	SetAppConfig(refreshed)
+}
+
+// HasSecretFiles reports whether any credentials are read from files
+func (a *Config) HasSecretFiles() bool {
+	if a.SplunkConfig.TokenFile != "" || a.JiraConfig.TokenFile != "" || a.LDAPConfig.PasswordFile != "" {
+		return true
+	}
+	for _, instance := range a.JiraInstances {
+		if instance.TokenFile != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func readSecretFile(path string) (string, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	// Secrets written with a trailing newline must not include it in the credential
+	return strings.TrimSpace(string(contents)), nil
+}
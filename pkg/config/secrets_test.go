@@ -0,0 +1,98 @@
+// Copyright 2021-2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+func TestLoadSecretFiles(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte("splunk-token\n"), 0600); err != nil {
+		t.Fatalf("failed to write the token file: %v", err)
+	}
+	missingFile := filepath.Join(dir, "missing")
+
+	a := &Config{
+		SplunkConfig:  SplunkConfig{TokenFile: tokenFile},
+		JiraConfig:    JiraConfig{Token: "current", TokenFile: missingFile},
+		JiraInstances: map[string]JiraConfig{"security": {TokenFile: tokenFile}},
+	}
+	if !a.HasSecretFiles() {
+		t.Errorf("HasSecretFiles() = false, want true")
+	}
+
+	got := a.LoadSecretFiles()
+	if want := (configError{Err: "jiraconfig.tokenfile can't be read: " + missingFile}); len(got) != 1 || !slices.Contains(got, error(want)) {
+		t.Errorf("LoadSecretFiles() = %v, want %v", got, want)
+	}
+	if a.SplunkConfig.Token != "splunk-token" || a.JiraInstances["security"].Token != "splunk-token" {
+		t.Errorf("LoadSecretFiles() did not read the token files: %q, %q", a.SplunkConfig.Token, a.JiraInstances["security"].Token)
+	}
+	if a.JiraConfig.Token != "current" {
+		t.Errorf("LoadSecretFiles() changed the token of an unreadable file: %q", a.JiraConfig.Token)
+	}
+
+	// Rotated secrets are picked up when the files are read again
+	if err := os.WriteFile(tokenFile, []byte("rotated"), 0600); err != nil {
+		t.Fatalf("failed to write the token file: %v", err)
+	}
+	a.LoadSecretFiles()
+	if a.SplunkConfig.Token != "rotated" {
+		t.Errorf("LoadSecretFiles() did not pick up the rotated token: %q", a.SplunkConfig.Token)
+	}
+}
+
+func TestRefreshSecretFiles(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("rotated"), 0600); err != nil {
+		t.Fatalf("failed to write the token file: %v", err)
+	}
+
+	SetAppConfig(Config{JiraInstances: map[string]JiraConfig{"security": {Token: "current", TokenFile: tokenFile}}})
+	defer SetAppConfig(Config{})
+	previous := AppConfig()
+
+	RefreshSecretFiles()
+	if got := AppConfig().JiraInstances["security"].Token; got != "rotated" {
+		t.Errorf("RefreshSecretFiles() published token %q, want rotated", got)
+	}
+	// Requests may still be reading the previous configuration, so it must not change
+	if got := previous.JiraInstances["security"].Token; got != "current" {
+		t.Errorf("RefreshSecretFiles() changed the previous configuration's token to %q", got)
+	}
+}
+
+func TestSecretReferencesAreResolved(t *testing.T) {
+	// An unresolved reference is left in place by ResolveSecretReferences
+	a := &Config{
+		JiraConfig:    JiraConfig{Token: "awssm://compliance/jira"},
+		JiraInstances: map[string]JiraConfig{"security": {Token: "resolved"}},
+	}
+	want := configError{Err: "jiraconfig.token: secret reference awssm://compliance/jira could not be resolved"}
+	if got := secretReferencesAreResolved(a); len(got) != 1 || got[0] != error(want) {
+		t.Errorf("secretReferencesAreResolved() = %v, want %v", got, want)
+	}
+
+	a.JiraConfig.Token = "resolved"
+	if got := secretReferencesAreResolved(a); got != nil {
+		t.Errorf("secretReferencesAreResolved() = %v, want no errors", got)
+	}
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNoPreviousTemplates is returned by RollbackTemplates when the templates were not updated at runtime
+var ErrNoPreviousTemplates = errors.New("no previous templates to roll back to")
+
+// Templates are the message and summary templates of the top level configuration
+type Templates struct {
+	MessageTemplate string `json:"messageTemplate,omitempty"`
+	SummaryTemplate string `json:"summaryTemplate,omitempty"`
+}
+
+// previousTemplates are the templates replaced by the last update, retained for rollback
+var previousTemplates *Templates
+
+// ActiveTemplates returns the active message and summary templates, and the templates they replaced if they
+// were updated at runtime
+func ActiveTemplates() (Templates, *Templates) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	return activeTemplates(AppConfig()), previousTemplates
+}
+
+// UpdateTemplates replaces the active templates with those set, once they parse, retaining the active ones for
+// RollbackTemplates. The templates updated at runtime last until the configuration is reloaded or the router restarts.
+func UpdateTemplates(templates Templates) (Templates, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	current := AppConfig()
+	updated := *current
+	if templates.MessageTemplate != "" {
+		updated.MessageTemplate = templates.MessageTemplate
+	}
+	if templates.SummaryTemplate != "" {
+		updated.SummaryTemplate = templates.SummaryTemplate
+	}
+	if templateErrors := templateCanBeParsed(&updated); len(templateErrors) > 0 {
+		return Templates{}, fmt.Errorf("invalid templates: %w", errors.Join(templateErrors...))
+	}
+
+	previous := activeTemplates(current)
+	previousTemplates = &previous
+	SetAppConfig(updated)
+	return activeTemplates(&updated), nil
+}
+
+// RollbackTemplates restores the templates replaced by the last update. Rolling back again undoes the rollback.
+func RollbackTemplates() (Templates, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	if previousTemplates == nil {
+		return Templates{}, ErrNoPreviousTemplates
+	}
+
+	updated := *AppConfig()
+	current := activeTemplates(&updated)
+	updated.MessageTemplate, updated.SummaryTemplate = previousTemplates.MessageTemplate, previousTemplates.SummaryTemplate
+	SetAppConfig(updated)
+	previousTemplates = &current
+	return activeTemplates(&updated), nil
+}
+
+func activeTemplates(a *Config) Templates {
+	return Templates{MessageTemplate: a.MessageTemplate, SummaryTemplate: a.SummaryTemplate}
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// TenantConfig overrides the settings of the alerts of a team sharing the router.
+// Settings left empty are those of the top level configuration.
+type TenantConfig struct {
+	// SearchNames are the Splunk saved searches the tenant accepts alerts from, or any search when empty
+	SearchNames []string
+
+	// Jira, Key and IssueType select the Jira project the tenant's issues are created in, as for a RoutingRule
+	Jira        string
+	Key         string
+	IssueType   string
+	Transitions map[string]string
+
+	MessageTemplate  string
+	MessageTemplates []MessageTemplateRule
+	SummaryTemplate  string
+
+	// IdentityConfig overrides the identity settings, except the provider, which is shared by all tenants
+	IdentityConfig IdentityConfig
+}
+
+// AllowsSearch reports whether the tenant accepts alerts from the Splunk saved search
+func (t TenantConfig) AllowsSearch(searchName string) bool {
+	return len(t.SearchNames) == 0 || slices.Contains(t.SearchNames, searchName)
+}
+
+// Tenant returns the configuration with the named tenant's settings applied, or the configuration itself
+// when name is empty. The top level routing rules don't apply to tenants, whose issues are all created
+// in the tenant's Jira project.
+func (a *Config) Tenant(name string) (Config, bool) {
+	if name == "" {
+		return *a, true
+	}
+
+	// Viper lowercases map keys, so the tenant names must be compared lowercased
+	tenant, ok := a.Tenants[strings.ToLower(name)]
+	if !ok {
+		return Config{}, false
+	}
+
+	tenantConfig := *a
+	tenantConfig.Tenants = nil
+	tenantConfig.Routing = nil
+
+	tenantConfig.JiraConfig = a.routedJiraConfig(RoutingRule{Jira: tenant.Jira, Key: tenant.Key, IssueType: tenant.IssueType})
+	if tenant.Transitions != nil {
+		tenantConfig.JiraConfig.Transitions = tenant.Transitions
+	}
+
+	if tenant.MessageTemplate != "" {
+		tenantConfig.MessageTemplate = tenant.MessageTemplate
+	}
+	if tenant.MessageTemplates != nil {
+		tenantConfig.MessageTemplates = tenant.MessageTemplates
+	}
+	if tenant.SummaryTemplate != "" {
+		tenantConfig.SummaryTemplate = tenant.SummaryTemplate
+	}
+
+	tenantConfig.IdentityConfig = tenant.identityConfig(a.IdentityConfig)
+
+	return tenantConfig, true
+}
+
+// identityConfig returns the identity settings with the tenant's overrides applied
+func (t TenantConfig) identityConfig(identityConfig IdentityConfig) IdentityConfig {
+	overrides := t.IdentityConfig
+	if overrides.ManagerChainDepth != 0 {
+		identityConfig.ManagerChainDepth = overrides.ManagerChainDepth
+	}
+	if overrides.RequiredGroup != "" {
+		identityConfig.RequiredGroup = overrides.RequiredGroup
+	}
+	if overrides.NonMemberRouting.Jira != "" || overrides.NonMemberRouting.Key != "" || overrides.NonMemberRouting.IssueType != "" ||
+		overrides.NonMemberRouting.Components != nil || overrides.NonMemberRouting.SecurityLevel != "" {
+		identityConfig.NonMemberRouting = overrides.NonMemberRouting
+	}
+	if overrides.NonMemberAssignee != "" {
+		identityConfig.NonMemberAssignee = overrides.NonMemberAssignee
+	}
+	if overrides.KerberosRealms != nil {
+		identityConfig.KerberosRealms = overrides.KerberosRealms
+	}
+	if overrides.DomainMap != nil {
+		identityConfig.DomainMap = overrides.DomainMap
+	}
+	return identityConfig
+}
+
+// tenantsAreValid tests that the tenants select known Jira instances, that their templates parse
+// and that they don't set the shared identity provider
+func tenantsAreValid(a *Config) []error {
+	var tenantErrors []error
+
+	for name, tenant := range a.Tenants {
+		if _, ok := a.JiraInstance(tenant.Jira); !ok {
+			tenantErrors = append(tenantErrors, configError{Err: fmt.Sprintf("tenants.%s references unknown jira instance: %s", name, tenant.Jira)})
+		}
+		if _, ok := a.JiraInstance(tenant.IdentityConfig.NonMemberRouting.Jira); !ok {
+			tenantErrors = append(tenantErrors, configError{Err: fmt.Sprintf("tenants.%s.identityconfig.nonmemberrouting references unknown jira instance: %s", name, tenant.IdentityConfig.NonMemberRouting.Jira)})
+		}
+		if tenant.IdentityConfig.Provider != "" {
+			tenantErrors = append(tenantErrors, configError{Err: fmt.Sprintf("tenants.%s.identityconfig.provider can't be set: the identity provider is shared by all tenants", name)})
+		}
+		if tenant.IdentityConfig.ManagerChainDepth < 0 {
+			tenantErrors = append(tenantErrors, configError{Err: fmt.Sprintf("tenants.%s.identityconfig.managerchaindepth must not be negative: %v", name, tenant.IdentityConfig.ManagerChainDepth)})
+		}
+		for _, key := range transitionKeys {
+			if tenant.Transitions != nil && tenant.Transitions[key] == "" {
+				tenantErrors = append(tenantErrors, configError{Err: fmt.Sprintf("missing required configuration value: tenants.%s.transitions.%s", name, key)})
+			}
+		}
+
+		tenantConfig, _ := a.Tenant(name)
+		for _, err := range templateCanBeParsed(&tenantConfig) {
+			tenantErrors = append(tenantErrors, configError{Err: fmt.Sprintf("tenants.%s: %s", name, err)})
+		}
+	}
+
+	return tenantErrors
+}
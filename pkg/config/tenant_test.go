@@ -0,0 +1,102 @@
+// Copyright 2021-2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTenant(t *testing.T) {
+	config := &Config{
+		MessageTemplate: "default message",
+		SummaryTemplate: "default summary",
+		JiraConfig:      JiraConfig{Host: "https://jira.example.com", Key: "OHSS", IssueType: "Task", Transitions: map[string]string{"initial": "Open"}},
+		JiraInstances:   map[string]JiraConfig{"security": {Host: "https://security.example.com", Key: "SEC", IssueType: "Task"}},
+		IdentityConfig:  IdentityConfig{RequiredGroup: "sre", ManagerChainDepth: 2},
+		Routing:         []RoutingRule{{AlertName: "sshaccess", Key: "SSH"}},
+		Tenants: map[string]TenantConfig{
+			"security": {
+				SearchNames:     []string{"security access"},
+				Jira:            "security",
+				Transitions:     map[string]string{"initial": "New"},
+				SummaryTemplate: "security summary",
+				IdentityConfig:  IdentityConfig{RequiredGroup: "security"},
+			},
+		},
+	}
+
+	if got, ok := config.Tenant(""); !ok || got.JiraConfig.Key != "OHSS" || len(got.Routing) != 1 {
+		t.Errorf("Tenant(\"\") = %+v, want the configuration itself", got)
+	}
+	if _, ok := config.Tenant("unknown"); ok {
+		t.Errorf("Tenant() found an unknown tenant")
+	}
+
+	got, ok := config.Tenant("Security")
+	if !ok {
+		t.Fatalf("Tenant() did not find the tenant case-insensitively")
+	}
+	if got.JiraConfig.Key != "SEC" || got.JiraConfig.Transitions["initial"] != "New" {
+		t.Errorf("Tenant() JiraConfig = %+v, want the tenant's project and transitions", got.JiraConfig)
+	}
+	if got.JiraConfigFor("sshaccess", "").Key != "SEC" {
+		t.Errorf("Tenant() applied the top level routing rules")
+	}
+	if got.MessageTemplate != "default message" || got.SummaryTemplate != "security summary" {
+		t.Errorf("Tenant() templates = %v, %v, want the default message and the tenant's summary", got.MessageTemplate, got.SummaryTemplate)
+	}
+	if got.IdentityConfig.RequiredGroup != "security" || got.IdentityConfig.ManagerChainDepth != 2 {
+		t.Errorf("Tenant() IdentityConfig = %+v, want the tenant's group and the default chain depth", got.IdentityConfig)
+	}
+
+	tenant := config.Tenants["security"]
+	if !tenant.AllowsSearch("security access") || tenant.AllowsSearch("sre access") {
+		t.Errorf("AllowsSearch() did not apply the search allowlist %v", tenant.SearchNames)
+	}
+	if !(TenantConfig{}).AllowsSearch("sre access") {
+		t.Errorf("AllowsSearch() rejected a search without an allowlist")
+	}
+}
+
+func TestTenantsAreValid(t *testing.T) {
+	config := &Config{
+		Tenants: map[string]TenantConfig{
+			"team": {
+				Jira:            "unknown",
+				Transitions:     map[string]string{"initial": "New"},
+				SummaryTemplate: "{{.User",
+				IdentityConfig:  IdentityConfig{Provider: "okta"},
+			},
+		},
+	}
+
+	got := tenantsAreValid(config)
+	want := []error{
+		configError{Err: "tenants.team references unknown jira instance: unknown"},
+		configError{Err: "tenants.team.identityconfig.provider can't be set: the identity provider is shared by all tenants"},
+		configError{Err: "missing required configuration value: tenants.team.transitions.sre"},
+		configError{Err: "missing required configuration value: tenants.team.transitions.manager"},
+	}
+	for _, err := range want {
+		if !slices.Contains(got, err) {
+			t.Errorf("tenantsAreValid() missing expected error: %+v", err)
+		}
+	}
+	// The summary template fails to parse too
+	if len(got) != len(want)+1 {
+		t.Errorf("tenantsAreValid() = %v, want %v and a template error", got, want)
+	}
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package enrich adds context to compliance events before their tickets are created,
+// eg. cluster details from OCM or asset inventory records, from the configured sources
+package enrich
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/metrics"
+	"github.com/openshift/compliance-audit-router/pkg/splunk"
+)
+
+// Enricher adds context to a compliance event, with AlertDetails.Enrich
+type Enricher interface {
+	// Name identifies the enricher in logs and metrics
+	Name() string
+	Enrich(ctx context.Context, alert *splunk.AlertDetails) error
+}
+
+// New returns the enricher with the given configuration
+func New(enricherConfig config.EnricherConfig) (Enricher, error) {
+	switch enricherConfig.Type {
+	case config.EnricherTypeHTTP:
+		return newHTTPEnricher(enricherConfig)
+	default:
+		return nil, fmt.Errorf("unknown enricher type: %s", enricherConfig.Type)
+	}
+}
+
+// Pipeline runs its enrichers over compliance events in order
+type Pipeline []Enricher
+
+// NewPipeline returns the pipeline of the configured enrichers
+func NewPipeline(enricherConfigs []config.EnricherConfig) (Pipeline, error) {
+	var pipeline Pipeline
+	for _, enricherConfig := range enricherConfigs {
+		enricher, err := New(enricherConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed creating enricher %s: %w", enricherConfig.Name, err)
+		}
+		pipeline = append(pipeline, enricher)
+	}
+	return pipeline, nil
+}
+
+// Enrich runs the enrichers over the compliance event. Failing enrichers are logged and skipped,
+// so an unavailable source doesn't keep the ticket from being created.
+func (p Pipeline) Enrich(ctx context.Context, alert *splunk.AlertDetails) {
+	for _, enricher := range p {
+		if err := enricher.Enrich(ctx, alert); err != nil {
+			log.Printf("enrich: %s failed to enrich the alert for %s: %v\n", enricher.Name(), alert.User, err)
+			metrics.MetricEnrichmentFailures.With(map[string]string{"enricher": enricher.Name()}).Inc()
+		}
+	}
+}
+
+var (
+	defaultPipelineOnce sync.Once
+	defaultPipeline     Pipeline
+	defaultPipelineErr  error
+)
+
+// Default returns the pipeline of the enrichers in the configuration
+func Default() (Pipeline, error) {
+	defaultPipelineOnce.Do(func() {
+		defaultPipeline, defaultPipelineErr = NewPipeline(config.AppConfig().Enrichers)
+	})
+	return defaultPipeline, defaultPipelineErr
+}
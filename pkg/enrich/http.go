@@ -0,0 +1,150 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package enrich
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/helpers"
+	"github.com/openshift/compliance-audit-router/pkg/splunk"
+)
+
+// defaultTimeout is how long a lookup may take when the enricher doesn't set a timeout
+const defaultTimeout = 10 * time.Second
+
+// httpEnricher looks compliance events up in a JSON API, eg. OCM or an asset inventory,
+// and adds the configured fields of the response to the event
+type httpEnricher struct {
+	name    string
+	url     *template.Template
+	token   string
+	fields  map[string]string
+	timeout time.Duration
+	client  *http.Client
+}
+
+func newHTTPEnricher(enricherConfig config.EnricherConfig) (*httpEnricher, error) {
+	url, err := template.New(enricherConfig.Name).Funcs(helpers.TemplateFuncs()).Parse(enricherConfig.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the URL template: %w", err)
+	}
+
+	timeout := enricherConfig.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+
+	return &httpEnricher{
+		name:    enricherConfig.Name,
+		url:     url,
+		token:   enricherConfig.Token,
+		fields:  enricherConfig.Fields,
+		timeout: timeout,
+		client:  &http.Client{},
+	}, nil
+}
+
+// Name implements the Enricher interface
+func (e *httpEnricher) Name() string {
+	return e.name
+}
+
+// Enrich implements the Enricher interface. Fields missing from the response are not added.
+func (e *httpEnricher) Enrich(ctx context.Context, alert *splunk.AlertDetails) error {
+	var url strings.Builder
+	if err := e.url.Execute(&url, alert); err != nil {
+		return fmt.Errorf("failed to render the URL: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url.String(), http.NoBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if e.token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", e.token))
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("lookup failed: %s", resp.Status)
+	}
+
+	var body interface{}
+	if err := helpers.DecodeJSONResponseBody(resp, &body); err != nil {
+		return err
+	}
+
+	for field, path := range e.fields {
+		if value, ok := lookup(body, path); ok {
+			alert.Enrich(field, value)
+		}
+	}
+	return nil
+}
+
+// lookup returns the value at the dot separated path in the decoded JSON, eg. "items.0.name",
+// rendered as a string; objects and arrays are rendered as JSON
+func lookup(v interface{}, path string) (string, bool) {
+	for _, key := range strings.Split(path, ".") {
+		switch value := v.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if v, ok = value[key]; !ok {
+				return "", false
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(value) {
+				return "", false
+			}
+			v = value[i]
+		default:
+			return "", false
+		}
+	}
+
+	switch value := v.(type) {
+	case nil:
+		return "", false
+	case string:
+		return value, true
+	case map[string]interface{}, []interface{}:
+		b, err := json.Marshal(value)
+		if err != nil {
+			return "", false
+		}
+		return string(b), true
+	default:
+		return fmt.Sprint(value), true
+	}
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package enrich
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/metrics"
+	"github.com/openshift/compliance-audit-router/pkg/splunk"
+)
+
+func TestHTTPEnricher(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/clusters/abc" || r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"name": "prod-1", "owner": {"teams": ["sre", "platform"]}, "nodes": 3}`))
+	}))
+	defer server.Close()
+
+	enricher, err := New(config.EnricherConfig{
+		Name:   "inventory",
+		Type:   config.EnricherTypeHTTP,
+		URL:    server.URL + "/clusters/{{index .ClusterIDs 0}}",
+		Token:  "secret",
+		Fields: map[string]string{"cluster": "name", "team": "owner.teams.0", "nodes": "nodes", "region": "region"},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	alert := splunk.AlertDetails{User: "sre", ClusterIDs: []string{"abc"}}
+	if err := enricher.Enrich(context.Background(), &alert); err != nil {
+		t.Fatalf("Enrich() error = %v", err)
+	}
+	want := map[string]string{"cluster": "prod-1", "team": "sre", "nodes": "3"}
+	if len(alert.Enrichment) != len(want) {
+		t.Errorf("Enrich() added %v, want %v", alert.Enrichment, want)
+	}
+	for field, value := range want {
+		if alert.Enrichment[field] != value {
+			t.Errorf("Enrich() added %s = %q, want %q", field, alert.Enrichment[field], value)
+		}
+	}
+
+	alert = splunk.AlertDetails{User: "sre", ClusterIDs: []string{"unknown"}}
+	if err := enricher.Enrich(context.Background(), &alert); err == nil {
+		t.Errorf("Enrich() of an unknown cluster expected an error")
+	}
+}
+
+func TestLookup(t *testing.T) {
+	v := map[string]interface{}{
+		"items": []interface{}{map[string]interface{}{"name": "a", "labels": map[string]interface{}{"env": "prod"}}},
+		"empty": nil,
+	}
+	tests := []struct {
+		path   string
+		want   string
+		wantOK bool
+	}{
+		{"items.0.name", "a", true},
+		{"items.0.labels", `{"env":"prod"}`, true},
+		{"items.1.name", "", false},
+		{"items.name", "", false},
+		{"empty", "", false},
+		{"missing", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := lookup(v, tt.path)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("lookup(%q) = %q, %v, want %q, %v", tt.path, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+type failingEnricher struct{}
+
+func (failingEnricher) Name() string { return "failing" }
+
+func (failingEnricher) Enrich(context.Context, *splunk.AlertDetails) error {
+	return errors.New("unavailable")
+}
+
+type staticEnricher struct{}
+
+func (staticEnricher) Name() string { return "static" }
+
+func (staticEnricher) Enrich(_ context.Context, alert *splunk.AlertDetails) error {
+	alert.Enrich("source", "static")
+	return nil
+}
+
+func TestPipelineEnrich(t *testing.T) {
+	failures := metrics.MetricEnrichmentFailures.With(map[string]string{"enricher": "failing"})
+	before := testutil.ToFloat64(failures)
+
+	alert := splunk.AlertDetails{User: "sre"}
+	Pipeline{failingEnricher{}, staticEnricher{}}.Enrich(context.Background(), &alert)
+
+	if alert.Enrichment["source"] != "static" {
+		t.Errorf("Enrich() did not run the enrichers after a failure: %v", alert.Enrichment)
+	}
+	if got := testutil.ToFloat64(failures) - before; got != 1 {
+		t.Errorf("Enrich() counted %v failures, want 1", got)
+	}
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package health tracks the last successful and failed calls to each of the router's dependencies,
+// for the status endpoint operators triage outages with
+package health
+
+import (
+	"sync"
+	"time"
+)
+
+// Dependencies whose calls are tracked
+const (
+	Splunk   = "splunk"
+	Jira     = "jira"
+	Identity = "identity"
+)
+
+// Status is the health of a dependency: when it was last called successfully and when a call last failed
+type Status struct {
+	LastSuccess *time.Time `json:"lastSuccess,omitempty"`
+	LastError   *time.Time `json:"lastError,omitempty"`
+	// Error is the error of the last failed call
+	Error string `json:"error,omitempty"`
+}
+
+var (
+	mu       sync.Mutex
+	statuses = map[string]Status{}
+)
+
+// Record records the result of a call to the dependency
+func Record(dependency string, err error) {
+	now := time.Now().UTC()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	status := statuses[dependency]
+	if err != nil {
+		status.LastError, status.Error = &now, err.Error()
+	} else {
+		status.LastSuccess = &now
+	}
+	statuses[dependency] = status
+}
+
+// Statuses returns the status of each dependency called since the router started
+func Statuses() map[string]Status {
+	mu.Lock()
+	defer mu.Unlock()
+
+	snapshot := make(map[string]Status, len(statuses))
+	for dependency, status := range statuses {
+		snapshot[dependency] = status
+	}
+	return snapshot
+}
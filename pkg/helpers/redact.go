@@ -0,0 +1,123 @@
+//
+// Copyright 2021-2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Redacted replaces sensitive values in logs
+const Redacted = "*****"
+
+// sensitiveKeys are the parts of header, parameter and field names that are always redacted
+var sensitiveKeys = []string{"authorization", "cookie", "token", "secret", "password", "apikey", "api-key"}
+
+// IsSensitive reports whether values with the name, eg. a header or JSON field, must be redacted
+// from logs: names containing a credential keyword, or matching one of the fields case-insensitively
+func IsSensitive(name string, fields []string) bool {
+	lower := strings.ToLower(name)
+	for _, key := range sensitiveKeys {
+		if strings.Contains(lower, key) {
+			return true
+		}
+	}
+	for _, field := range fields {
+		if strings.EqualFold(name, field) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactRequest describes the request for verbose logging, with the sensitive headers
+// and query parameters masked. The body is not included.
+func RedactRequest(r *http.Request, fields []string) string {
+	return fmt.Sprintf("%s %s %s headers: %v", r.Method, redactURL(r.URL, fields), r.Proto, redactHeader(r.Header, fields))
+}
+
+// RedactResponse describes the response for verbose logging, with the sensitive headers masked.
+// The body is not included.
+func RedactResponse(r *http.Response, fields []string) string {
+	return fmt.Sprintf("%s %s headers: %v", r.Proto, r.Status, redactHeader(r.Header, fields))
+}
+
+// RedactJSON renders the value as JSON for verbose logging, with the values of sensitive fields
+// masked at any depth
+func RedactJSON(v interface{}, fields []string) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("<failed to render as JSON: %v>", err)
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		return fmt.Sprintf("<failed to render as JSON: %v>", err)
+	}
+
+	b, err = json.Marshal(redactValue(decoded, fields))
+	if err != nil {
+		return fmt.Sprintf("<failed to render as JSON: %v>", err)
+	}
+	return string(b)
+}
+
+// redactValue masks the values of the sensitive fields of decoded JSON
+func redactValue(v interface{}, fields []string) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for k, fieldValue := range value {
+			if IsSensitive(k, fields) {
+				value[k] = Redacted
+			} else {
+				value[k] = redactValue(fieldValue, fields)
+			}
+		}
+	case []interface{}:
+		for i := range value {
+			value[i] = redactValue(value[i], fields)
+		}
+	}
+	return v
+}
+
+func redactHeader(header http.Header, fields []string) http.Header {
+	redacted := header.Clone()
+	for name := range redacted {
+		if IsSensitive(name, fields) {
+			redacted[name] = []string{Redacted}
+		}
+	}
+	return redacted
+}
+
+func redactURL(u *url.URL, fields []string) string {
+	redacted := *u
+	redacted.User = nil
+
+	query := redacted.Query()
+	for name := range query {
+		if IsSensitive(name, fields) {
+			query[name] = []string{Redacted}
+		}
+	}
+	redacted.RawQuery = query.Encode()
+
+	return redacted.String()
+}
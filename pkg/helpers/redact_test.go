@@ -0,0 +1,58 @@
+// Copyright 2021-2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helpers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRedactRequest(t *testing.T) {
+	req := httptest.NewRequest("POST", "https://car.example.com/api/v1/alert?token=abc&sid=123", nil)
+	req.Header.Set("Authorization", "Bearer secret-token")
+	req.Header.Set("X-User-Email", "sre@example.com")
+	req.Header.Set("Content-Type", "application/json")
+
+	got := RedactRequest(req, []string{"x-user-email"})
+	for _, leaked := range []string{"secret-token", "abc", "sre@example.com"} {
+		if strings.Contains(got, leaked) {
+			t.Errorf("RedactRequest() = %v, leaked %v", got, leaked)
+		}
+	}
+	for _, kept := range []string{"sid=123", "application/json"} {
+		if !strings.Contains(got, kept) {
+			t.Errorf("RedactRequest() = %v, missing %v", got, kept)
+		}
+	}
+}
+
+func TestRedactJSON(t *testing.T) {
+	v := map[string]interface{}{
+		"sid": "123",
+		"result": map[string]interface{}{
+			"UserName":  "sre",
+			"clusterid": "abc",
+			"api_token": "secret",
+		},
+		"results": []interface{}{map[string]interface{}{"username": "sre"}},
+	}
+
+	got := RedactJSON(v, []string{"username"})
+	want := `{"result":{"UserName":"*****","api_token":"*****","clusterid":"abc"},"results":[{"username":"*****"}],"sid":"123"}`
+	if got != want {
+		t.Errorf("RedactJSON() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2021-2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helpers
+
+import (
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+)
+
+// maxParsedTemplates is the number of parsed templates kept; once exceeded, eg. after many template
+// updates, the parsed templates are dropped and parsed again when next used
+const maxParsedTemplates = 256
+
+var (
+	parsedTemplatesMutex sync.Mutex
+	parsedTemplates      = map[string]*template.Template{}
+)
+
+// wikiMarkup escapes the characters with a meaning in Jira wiki markup
+var wikiMarkup = strings.NewReplacer(
+	`\`, `\\`, "{", `\{`, "}", `\}`, "[", `\[`, "]", `\]`, "*", `\*`, "_", `\_`,
+	"+", `\+`, "^", `\^`, "~", `\~`, "|", `\|`, "!", `\!`, "#", `\#`, "-", `\-`, "?", `\?`,
+)
+
+// TemplateFuncs returns the sprig functions available in the message, summary and reminder templates,
+// eg. date, join, default and trim, and wikiescape. The functions reading environment variables are
+// left out, since the environment holds credentials that would otherwise end up in Jira tickets.
+func TemplateFuncs() map[string]interface{} {
+	funcs := sprig.GenericFuncMap()
+	delete(funcs, "env")
+	delete(funcs, "expandenv")
+	funcs["wikiescape"] = WikiEscape
+	return funcs
+}
+
+// WikiEscape escapes Jira wiki markup in the text, so alert fields render as they are rather than as markup
+func WikiEscape(text string) string {
+	return wikiMarkup.Replace(text)
+}
+
+// ParseTemplate parses the text as a template with the TemplateFuncs, once per name and text: the
+// templates are parsed when the configuration is loaded and validated, and the parsed templates are
+// reused to render each ticket. A reloaded or updated template is parsed again as its text changed.
+func ParseTemplate(name, text string) (*template.Template, error) {
+	key := name + "\x00" + text
+
+	parsedTemplatesMutex.Lock()
+	tmpl, ok := parsedTemplates[key]
+	parsedTemplatesMutex.Unlock()
+	if ok {
+		return tmpl, nil
+	}
+
+	tmpl, err := template.New(name).Funcs(TemplateFuncs()).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	parsedTemplatesMutex.Lock()
+	defer parsedTemplatesMutex.Unlock()
+	if len(parsedTemplates) >= maxParsedTemplates {
+		parsedTemplates = map[string]*template.Template{}
+	}
+	parsedTemplates[key] = tmpl
+	return tmpl, nil
+}
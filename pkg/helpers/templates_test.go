@@ -0,0 +1,42 @@
+// Copyright 2021-2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helpers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTemplate(t *testing.T) {
+	first, err := ParseTemplate("summary", "{{ .User | upper }}")
+	if err != nil {
+		t.Fatalf("ParseTemplate() error = %v", err)
+	}
+	if again, _ := ParseTemplate("summary", "{{ .User | upper }}"); again != first {
+		t.Errorf("ParseTemplate() parsed the same template again")
+	}
+	if changed, _ := ParseTemplate("summary", "{{ .User }}"); changed == first {
+		t.Errorf("ParseTemplate() returned the template of another text")
+	}
+
+	var s strings.Builder
+	if err := first.Execute(&s, map[string]string{"User": "sre"}); err != nil || s.String() != "SRE" {
+		t.Errorf("Execute() = %q, %v, want SRE", s.String(), err)
+	}
+
+	if _, err := ParseTemplate("summary", "{{ .User "); err == nil {
+		t.Errorf("ParseTemplate() of an invalid template error = nil")
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2021-2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helpers
+
+import (
+	"crypto/tls"
+	"net/http"
+	"sync"
+)
+
+// maxIdleConnsPerHost is the number of idle connections kept to each host, enough for the
+// events of an alert processed in parallel to reuse their connections
+const maxIdleConnsPerHost = 16
+
+var (
+	transportsMutex sync.Mutex
+	transports      = map[bool]*http.Transport{}
+)
+
+// SharedTransport returns the transport shared by the clients of the API servers with the same
+// TLS verification, so their requests reuse pooled connections rather than each paying for a new
+// connection and TLS handshake. Credentials are added per request or per client, not to the transport.
+func SharedTransport(allowInsecure bool) *http.Transport {
+	transportsMutex.Lock()
+	defer transportsMutex.Unlock()
+
+	if transport, ok := transports[allowInsecure]; ok {
+		return transport
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = maxIdleConnsPerHost
+	if allowInsecure {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	transports[allowInsecure] = transport
+	return transport
+}
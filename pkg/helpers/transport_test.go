@@ -0,0 +1,32 @@
+// Copyright 2021-2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helpers
+
+import "testing"
+
+func TestSharedTransport(t *testing.T) {
+	secure := SharedTransport(false)
+	if SharedTransport(false) != secure {
+		t.Errorf("SharedTransport() returned a new transport for the same settings")
+	}
+	if secure.TLSClientConfig != nil && secure.TLSClientConfig.InsecureSkipVerify {
+		t.Errorf("SharedTransport(false) skips TLS verification")
+	}
+
+	insecure := SharedTransport(true)
+	if insecure == secure || !insecure.TLSClientConfig.InsecureSkipVerify {
+		t.Errorf("SharedTransport(true) verifies TLS certificates")
+	}
+}
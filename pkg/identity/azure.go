@@ -0,0 +1,232 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package identity
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/helpers"
+)
+
+// azureTokenExpiryMargin is how long before its expiry an access token is renewed
+const azureTokenExpiryMargin = time.Minute
+
+// azureProvider resolves users and their manager with the Microsoft Graph API,
+// authenticating as an Entra ID application with the client credentials flow
+type azureProvider struct {
+	config config.AzureConfig
+	client *http.Client
+
+	mu          sync.Mutex
+	accessToken string
+	expires     time.Time
+}
+
+type azureUser struct {
+	ID                string `json:"id"`
+	UserPrincipalName string `json:"userPrincipalName"`
+	Mail              string `json:"mail"`
+	MailNickname      string `json:"mailNickname"`
+}
+
+func newAzureProvider(azureConfig config.AzureConfig) *azureProvider {
+	return &azureProvider{config: azureConfig, client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// ResolveUser implements the Provider interface
+func (a *azureProvider) ResolveUser(ctx context.Context, username string) (Identity, error) {
+	user, err := a.getUser(ctx, username)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	identity := Identity{Username: a.username(user)}
+	if identity.Username == "" {
+		return Identity{}, fmt.Errorf("azure user %s has no %s attribute", username, a.usernameAttribute())
+	}
+
+	var manager azureUser
+	status, err := a.request(ctx, http.MethodGet, fmt.Sprintf("/users/%s/manager", url.PathEscape(user.ID)), nil, &manager)
+	if status == http.StatusNotFound {
+		// Users without a manager
+		return identity, nil
+	}
+	if err != nil {
+		return Identity{}, fmt.Errorf("failed to look up the manager of %s: %w", username, err)
+	}
+	identity.Manager = a.username(manager)
+
+	return identity, nil
+}
+
+// IsGroupMember implements the GroupChecker interface, with the group given as its object ID.
+// Membership is transitive, so members of nested groups are members of the group.
+func (a *azureProvider) IsGroupMember(ctx context.Context, username, group string) (bool, error) {
+	user, err := a.getUser(ctx, username)
+	if err != nil {
+		return false, err
+	}
+
+	var result struct {
+		Value []string `json:"value"`
+	}
+	_, err = a.request(ctx, http.MethodPost, fmt.Sprintf("/users/%s/checkMemberGroups", url.PathEscape(user.ID)),
+		map[string][]string{"groupIds": {group}}, &result)
+	if err != nil {
+		return false, fmt.Errorf("failed to check the group membership of %s: %w", username, err)
+	}
+
+	for _, id := range result.Value {
+		if id == group {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// getUser fetches the user by object ID or user principal name. Usernames without
+// a domain are qualified with the configured domain.
+func (a *azureProvider) getUser(ctx context.Context, username string) (azureUser, error) {
+	if !strings.Contains(username, "@") && a.config.Domain != "" {
+		username = username + "@" + a.config.Domain
+	}
+
+	var user azureUser
+	_, err := a.request(ctx, http.MethodGet, fmt.Sprintf("/users/%s?$select=id,userPrincipalName,mail,mailNickname", url.PathEscape(username)), nil, &user)
+	if err != nil {
+		return azureUser{}, fmt.Errorf("failed to get azure user %s: %w", username, err)
+	}
+	return user, nil
+}
+
+// username returns the configured attribute of the user, used to find their Jira account
+func (a *azureProvider) username(user azureUser) string {
+	switch a.usernameAttribute() {
+	case "mail":
+		return user.Mail
+	case "mailNickname":
+		return user.MailNickname
+	default:
+		return user.UserPrincipalName
+	}
+}
+
+// usernameAttribute returns the name of the attribute usernames are read from
+func (a *azureProvider) usernameAttribute() string {
+	switch a.config.UsernameAttribute {
+	case "mail", "mailNickname":
+		return a.config.UsernameAttribute
+	default:
+		return "userPrincipalName"
+	}
+}
+
+// request sends a Graph API request with the JSON encoded body, and decodes the JSON response into dst.
+// It returns the response status code along with any error.
+func (a *azureProvider) request(ctx context.Context, method, path string, body interface{}, dst interface{}) (int, error) {
+	token, err := a.token(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var reqBody io.Reader = http.NoBody
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reqBody = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(a.config.GraphURL, "/")+"/v1.0"+path, reqBody)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Add("Authorization", "Bearer "+token)
+	req.Header.Add("Accept", "application/json")
+	if body != nil {
+		req.Header.Add("Content-Type", "application/json")
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, fmt.Errorf("graph request failed: %s", resp.Status)
+	}
+	return resp.StatusCode, helpers.DecodeJSONResponseBody(resp, dst)
+}
+
+// token returns an access token for the Graph API, requesting a new one with the client credentials when it expires
+func (a *azureProvider) token(ctx context.Context) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.accessToken != "" && time.Now().Before(a.expires) {
+		return a.accessToken, nil
+	}
+
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {a.config.ClientID},
+		"client_secret": {a.config.ClientSecret},
+		"scope":         {strings.TrimSuffix(a.config.GraphURL, "/") + "/.default"},
+	}
+	tokenURL := fmt.Sprintf("%s/%s/oauth2/v2.0/token", strings.TrimSuffix(a.config.AuthorityURL, "/"), url.PathEscape(a.config.TenantID))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to request an azure access token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to request an azure access token: %s", resp.Status)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := helpers.DecodeJSONResponseBody(resp, &token); err != nil {
+		return "", fmt.Errorf("failed to decode the azure access token: %w", err)
+	}
+
+	a.accessToken = token.AccessToken
+	a.expires = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - azureTokenExpiryMargin)
+
+	return a.accessToken, nil
+}
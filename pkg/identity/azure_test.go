@@ -0,0 +1,98 @@
+// Copyright 2021-2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package identity
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+)
+
+func TestAzureProvider(t *testing.T) {
+	var tokenRequests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/tenant/oauth2/v2.0/token" {
+			tokenRequests++
+			if r.FormValue("grant_type") != "client_credentials" || r.FormValue("client_secret") != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_, _ = w.Write([]byte(`{"access_token":"test-token","expires_in":3600}`))
+			return
+		}
+
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1.0/users/sre@example.org":
+			_, _ = w.Write([]byte(`{"id":"id1","userPrincipalName":"sre@example.org","mailNickname":"sre"}`))
+		case "/v1.0/users/ceo@example.org":
+			_, _ = w.Write([]byte(`{"id":"id3","userPrincipalName":"ceo@example.org","mailNickname":"ceo"}`))
+		case "/v1.0/users/guest@example.org":
+			_, _ = w.Write([]byte(`{"id":"id4","userPrincipalName":"guest@example.org"}`))
+		case "/v1.0/users/id1/manager":
+			_, _ = w.Write([]byte(`{"id":"id2","userPrincipalName":"manager@example.org","mailNickname":"manager"}`))
+		case "/v1.0/users/id1/checkMemberGroups":
+			_, _ = w.Write([]byte(`{"value":["sre-group"]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	provider := newAzureProvider(config.AzureConfig{
+		TenantID:          "tenant",
+		ClientID:          "client",
+		ClientSecret:      "secret",
+		Domain:            "example.org",
+		UsernameAttribute: "mailNickname",
+		AuthorityURL:      server.URL,
+		GraphURL:          server.URL,
+	})
+
+	got, err := provider.ResolveUser(context.Background(), "sre")
+	if err != nil {
+		t.Fatalf("ResolveUser() unexpected error: %v", err)
+	}
+	if want := (Identity{Username: "sre", Manager: "manager"}); got != want {
+		t.Errorf("ResolveUser() = %+v, want %+v", got, want)
+	}
+
+	// Users without a manager resolve with an empty manager
+	got, err = provider.ResolveUser(context.Background(), "ceo@example.org")
+	if err != nil || got != (Identity{Username: "ceo"}) {
+		t.Errorf("ResolveUser() = %+v, %v, want a user without a manager", got, err)
+	}
+
+	// Users without the username attribute can't be resolved, rather than resolving to an empty username
+	if got, err := provider.ResolveUser(context.Background(), "guest"); err == nil {
+		t.Errorf("ResolveUser() = %+v, want an error for a user without a mailNickname", got)
+	}
+
+	member, err := provider.IsGroupMember(context.Background(), "sre", "sre-group")
+	if err != nil || !member {
+		t.Errorf("IsGroupMember() = %v, %v, want true", member, err)
+	}
+
+	if tokenRequests != 1 {
+		t.Errorf("requested %v access tokens, want 1", tokenRequests)
+	}
+}
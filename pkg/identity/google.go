@@ -0,0 +1,249 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package identity
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/helpers"
+)
+
+const (
+	googleDirectoryScopes = "https://www.googleapis.com/auth/admin.directory.user.readonly " +
+		"https://www.googleapis.com/auth/admin.directory.group.member.readonly"
+	googleJWTGrantType = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+	// googleTokenLifetime is the lifetime requested for access tokens, the maximum Google allows
+	googleTokenLifetime = time.Hour
+)
+
+// googleCredentials is the part of a service account key file used to request access tokens
+type googleCredentials struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// googleProvider resolves users and their manager with the Admin SDK Directory API,
+// authenticating as a service account with domain-wide delegation
+type googleProvider struct {
+	config      config.GoogleConfig
+	credentials googleCredentials
+	key         *rsa.PrivateKey
+	client      *http.Client
+
+	mu          sync.Mutex
+	accessToken string
+	expires     time.Time
+}
+
+type googleUser struct {
+	PrimaryEmail string `json:"primaryEmail"`
+	Relations    []struct {
+		Type  string `json:"type"`
+		Value string `json:"value"`
+	} `json:"relations"`
+}
+
+func newGoogleProvider(googleConfig config.GoogleConfig) (*googleProvider, error) {
+	keyFile, err := os.ReadFile(googleConfig.CredentialsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the google credentials file: %w", err)
+	}
+
+	var credentials googleCredentials
+	if err := json.Unmarshal(keyFile, &credentials); err != nil {
+		return nil, fmt.Errorf("failed to parse the google credentials file: %w", err)
+	}
+
+	block, _ := pem.Decode([]byte(credentials.PrivateKey))
+	if block == nil {
+		return nil, errors.New("the google credentials file has no PEM encoded private key")
+	}
+	parsedKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the google service account private key: %w", err)
+	}
+	key, ok := parsedKey.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("the google service account private key is not an RSA key")
+	}
+
+	return &googleProvider{config: googleConfig, credentials: credentials, key: key, client: &http.Client{Timeout: 30 * time.Second}}, nil
+}
+
+// ResolveUser implements the Provider interface. The manager is the user's "manager" relation,
+// which is only returned with the admin view: the domain public view omits the relations.
+func (g *googleProvider) ResolveUser(ctx context.Context, username string) (Identity, error) {
+	var user googleUser
+	err := g.get(ctx, fmt.Sprintf("/admin/directory/v1/users/%s?projection=basic&viewType=admin_view", url.PathEscape(g.userKey(username))), &user)
+	if err != nil {
+		return Identity{}, fmt.Errorf("failed to get google user %s: %w", username, err)
+	}
+
+	identity := Identity{Username: user.PrimaryEmail}
+	for _, relation := range user.Relations {
+		if relation.Type == "manager" {
+			identity.Manager = relation.Value
+			break
+		}
+	}
+
+	return identity, nil
+}
+
+// IsGroupMember implements the GroupChecker interface, with the group given as its email address or ID.
+// Membership is transitive, so members of nested groups are members of the group.
+func (g *googleProvider) IsGroupMember(ctx context.Context, username, group string) (bool, error) {
+	var result struct {
+		IsMember bool `json:"isMember"`
+	}
+	err := g.get(ctx, fmt.Sprintf("/admin/directory/v1/groups/%s/hasMember/%s", url.PathEscape(group), url.PathEscape(g.userKey(username))), &result)
+	if err != nil {
+		return false, fmt.Errorf("failed to check the group membership of %s: %w", username, err)
+	}
+	return result.IsMember, nil
+}
+
+// userKey qualifies usernames without a domain with the configured domain
+func (g *googleProvider) userKey(username string) string {
+	if !strings.Contains(username, "@") && g.config.Domain != "" {
+		return username + "@" + g.config.Domain
+	}
+	return username
+}
+
+// get decodes the JSON response to a Directory API request into dst
+func (g *googleProvider) get(ctx context.Context, path string, dst interface{}) error {
+	token, err := g.token(ctx)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(g.config.DirectoryURL, "/")+path, http.NoBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Authorization", "Bearer "+token)
+	req.Header.Add("Accept", "application/json")
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("directory request failed: %s", resp.Status)
+	}
+	return helpers.DecodeJSONResponseBody(resp, dst)
+}
+
+// token returns an access token for the Directory API, impersonating the configured subject,
+// requesting a new one with a signed JWT assertion when it expires
+func (g *googleProvider) token(ctx context.Context) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	if g.accessToken != "" && now.Before(g.expires) {
+		return g.accessToken, nil
+	}
+
+	assertion, err := g.assertion(now)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type": {googleJWTGrantType},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.credentials.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to request a google access token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to request a google access token: %s", resp.Status)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := helpers.DecodeJSONResponseBody(resp, &token); err != nil {
+		return "", fmt.Errorf("failed to decode the google access token: %w", err)
+	}
+
+	g.accessToken = token.AccessToken
+	g.expires = now.Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+
+	return g.accessToken, nil
+}
+
+// assertion returns the JWT signed with the service account key, requesting the directory scopes
+// on behalf of the subject with domain-wide delegation
+func (g *googleProvider) assertion(now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss":   g.credentials.ClientEmail,
+		"sub":   g.config.Subject,
+		"scope": googleDirectoryScopes,
+		"aud":   g.credentials.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(googleTokenLifetime).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, g.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign the google JWT assertion: %w", err)
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
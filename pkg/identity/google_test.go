@@ -0,0 +1,132 @@
+// Copyright 2021-2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package identity
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+)
+
+func TestGoogleProvider(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate a key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal the key: %v", err)
+	}
+
+	var tokenRequests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/token" {
+			tokenRequests++
+			if r.FormValue("grant_type") != googleJWTGrantType || strings.Count(r.FormValue("assertion"), ".") != 2 {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_, _ = w.Write([]byte(`{"access_token":"test-token","expires_in":3600}`))
+			return
+		}
+
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/admin/directory/v1/users/sre@example.org":
+			// Like the Directory API, the relations are only returned in the admin view
+			if r.URL.Query().Get("viewType") != "admin_view" {
+				_, _ = w.Write([]byte(`{"primaryEmail":"sre@example.org"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"primaryEmail":"sre@example.org","relations":[{"type":"assistant","value":"pa@example.org"},{"type":"manager","value":"manager@example.org"}]}`))
+		case "/admin/directory/v1/users/ceo@example.org":
+			_, _ = w.Write([]byte(`{"primaryEmail":"ceo@example.org"}`))
+		case "/admin/directory/v1/groups/sre@example.org/hasMember/sre@example.org":
+			_, _ = w.Write([]byte(`{"isMember":true}`))
+		case "/admin/directory/v1/groups/sre@example.org/hasMember/ceo@example.org":
+			_, _ = w.Write([]byte(`{"isMember":false}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	credentials, err := json.Marshal(googleCredentials{
+		ClientEmail: "router@project.iam.gserviceaccount.com",
+		PrivateKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		TokenURI:    server.URL + "/token",
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal the credentials: %v", err)
+	}
+	credentialsFile := filepath.Join(t.TempDir(), "credentials.json")
+	if err := os.WriteFile(credentialsFile, credentials, 0600); err != nil {
+		t.Fatalf("failed to write the credentials: %v", err)
+	}
+
+	provider, err := newGoogleProvider(config.GoogleConfig{
+		CredentialsFile: credentialsFile,
+		Subject:         "admin@example.org",
+		Domain:          "example.org",
+		DirectoryURL:    server.URL,
+	})
+	if err != nil {
+		t.Fatalf("newGoogleProvider() unexpected error: %v", err)
+	}
+
+	got, err := provider.ResolveUser(context.Background(), "sre")
+	if err != nil {
+		t.Fatalf("ResolveUser() unexpected error: %v", err)
+	}
+	if want := (Identity{Username: "sre@example.org", Manager: "manager@example.org"}); got != want {
+		t.Errorf("ResolveUser() = %+v, want %+v", got, want)
+	}
+
+	// Users without a manager resolve with an empty manager
+	got, err = provider.ResolveUser(context.Background(), "ceo@example.org")
+	if err != nil || got != (Identity{Username: "ceo@example.org"}) {
+		t.Errorf("ResolveUser() = %+v, %v, want a user without a manager", got, err)
+	}
+
+	if _, err := provider.ResolveUser(context.Background(), "missing"); err == nil {
+		t.Errorf("ResolveUser() expected an error for an unknown user")
+	}
+
+	if member, err := provider.IsGroupMember(context.Background(), "sre", "sre@example.org"); err != nil || !member {
+		t.Errorf("IsGroupMember() = %v, %v, want true", member, err)
+	}
+	if member, err := provider.IsGroupMember(context.Background(), "ceo", "sre@example.org"); err != nil || member {
+		t.Errorf("IsGroupMember() = %v, %v, want false", member, err)
+	}
+
+	if tokenRequests != 1 {
+		t.Errorf("requested %v access tokens, want the token to be reused", tokenRequests)
+	}
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package identity resolves the users named in compliance alerts to their
+// identity and manager, using the configured identity provider
+package identity
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+)
+
+// Identity is a resolved user
+type Identity struct {
+	// Username is the user's username, as used to find their Jira account
+	Username string
+	// Manager is the username of the user's manager, or empty if they have none
+	Manager string
+}
+
+// Provider resolves users to their identity and manager. Lookups are abandoned when the context is done.
+type Provider interface {
+	ResolveUser(ctx context.Context, username string) (Identity, error)
+}
+
+// GroupChecker is implemented by providers that can check group membership
+type GroupChecker interface {
+	IsGroupMember(ctx context.Context, username, group string) (bool, error)
+}
+
+// HealthChecker is implemented by providers that can check that their directory is reachable,
+// for the deep readiness check
+type HealthChecker interface {
+	CheckHealth() error
+}
+
+// New returns the identity provider with the given name, or nil when name is empty
+func New(name string) (Provider, error) {
+	switch name {
+	case "":
+		return nil, nil
+	case config.IdentityProviderLDAP:
+		return ldapProvider{}, nil
+	case config.IdentityProviderOkta:
+		return newOktaProvider(config.AppConfig().OktaConfig), nil
+	case config.IdentityProviderAzure:
+		return newAzureProvider(config.AppConfig().AzureConfig), nil
+	case config.IdentityProviderGoogle:
+		return newGoogleProvider(config.AppConfig().GoogleConfig)
+	default:
+		return nil, fmt.Errorf("unknown identity provider: %s", name)
+	}
+}
+
+var (
+	defaultProviderMu  sync.Mutex
+	defaultProviderSet bool
+	defaultProvider    Provider
+)
+
+// Default returns the configured identity provider, or nil when users are not resolved.
+// The provider is built once; failing to build it is returned, and retried on the next call.
+func Default() (Provider, error) {
+	defaultProviderMu.Lock()
+	defer defaultProviderMu.Unlock()
+
+	if !defaultProviderSet {
+		provider, err := New(config.AppConfig().IdentityProvider())
+		if err != nil {
+			return nil, err
+		}
+		defaultProvider, defaultProviderSet = provider, true
+	}
+	return defaultProvider, nil
+}
+
+// SetDefault replaces the configured identity provider, eg. with a fake in integration tests.
+// It must be called before the alerts are processed.
+func SetDefault(provider Provider) {
+	defaultProviderMu.Lock()
+	defer defaultProviderMu.Unlock()
+
+	defaultProvider, defaultProviderSet = provider, true
+}
+
+// ManagerChain returns the managers above the given manager, up to depth levels,
+// so the workflow can escalate when the direct manager is unavailable. The chain stops
+// early at a user without a manager or who manages themselves.
+func ManagerChain(ctx context.Context, provider Provider, manager string, depth int) ([]string, error) {
+	var chain []string
+	seen := map[string]bool{manager: true}
+
+	for len(chain) < depth {
+		identity, err := provider.ResolveUser(ctx, manager)
+		if err != nil {
+			return chain, fmt.Errorf("failed to look up the manager of %s: %w", manager, err)
+		}
+		if identity.Manager == "" || seen[identity.Manager] {
+			break
+		}
+		chain = append(chain, identity.Manager)
+		seen[identity.Manager] = true
+		manager = identity.Manager
+	}
+
+	return chain, nil
+}
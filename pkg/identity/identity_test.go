@@ -0,0 +1,97 @@
+// Copyright 2021-2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package identity
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+)
+
+// fakeProvider resolves users from a map of user to manager
+type fakeProvider map[string]string
+
+func (f fakeProvider) ResolveUser(_ context.Context, username string) (Identity, error) {
+	manager, ok := f[username]
+	if !ok {
+		return Identity{}, errors.New("user not found")
+	}
+	return Identity{Username: username, Manager: manager}, nil
+}
+
+func TestManagerChain(t *testing.T) {
+	provider := fakeProvider{
+		"manager":  "director",
+		"director": "vp",
+		"vp":       "ceo",
+		"ceo":      "ceo",
+		"orphan":   "missing",
+	}
+
+	tests := []struct {
+		name    string
+		manager string
+		depth   int
+		want    []string
+		wantErr bool
+	}{
+		{"depth limits the chain", "manager", 2, []string{"director", "vp"}, false},
+		{"chain stops at a self-managed user", "vp", 5, []string{"ceo"}, false},
+		{"zero depth", "manager", 0, nil, false},
+		{"lookup failures return the partial chain", "orphan", 3, []string{"missing"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ManagerChain(context.Background(), provider, tt.manager, tt.depth)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ManagerChain() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ManagerChain() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	if p, err := New(""); p != nil || err != nil {
+		t.Errorf("New(\"\") = %v, %v, want nil, nil", p, err)
+	}
+	if _, err := New("nope"); err == nil {
+		t.Errorf("New(\"nope\") expected an error")
+	}
+}
+
+func TestDefaultRetriesFailures(t *testing.T) {
+	defer func() {
+		config.SetAppConfig(config.Config{})
+		SetDefault(nil)
+	}()
+
+	config.SetAppConfig(config.Config{IdentityConfig: config.IdentityConfig{Provider: "nope"}})
+	if _, err := Default(); err == nil {
+		t.Fatalf("Default() expected an error")
+	}
+
+	// The failure isn't cached, so fixing the config fixes the provider
+	config.SetAppConfig(config.Config{IdentityConfig: config.IdentityConfig{Provider: config.IdentityProviderLDAP}})
+	if p, err := Default(); err != nil || p == nil {
+		t.Errorf("Default() = %v, %v, want the LDAP provider", p, err)
+	}
+}
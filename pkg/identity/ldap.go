@@ -0,0 +1,45 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package identity
+
+import (
+	"context"
+
+	"github.com/openshift/compliance-audit-router/pkg/ldap"
+)
+
+// ldapProvider resolves users with the configured LDAP directory
+type ldapProvider struct{}
+
+// ResolveUser implements the Provider interface
+func (ldapProvider) ResolveUser(ctx context.Context, username string) (Identity, error) {
+	ldapUsername, ldapManager, err := ldap.LookupUser(ctx, username)
+	if err != nil {
+		return Identity{}, err
+	}
+	return Identity{Username: ldapUsername, Manager: ldapManager}, nil
+}
+
+// IsGroupMember implements the GroupChecker interface, with the group given as a DN
+func (ldapProvider) IsGroupMember(ctx context.Context, username, group string) (bool, error) {
+	return ldap.IsGroupMember(ctx, username, group)
+}
+
+// CheckHealth implements the HealthChecker interface
+func (ldapProvider) CheckHealth() error {
+	return ldap.CheckHealth()
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package identity
+
+import (
+	"strings"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+)
+
+// NormalizeUsername turns the identifier an alert names a user by into the username looked up
+// with the identity provider. Kerberos principals in the configured realms are reduced to the
+// user's name, and email addresses have their domain rewritten by the domain map.
+func NormalizeUsername(username string, identityConfig config.IdentityConfig) string {
+	username = strings.TrimSpace(username)
+
+	at := strings.LastIndex(username, "@")
+	if at < 0 {
+		return username
+	}
+	name, domain := username[:at], username[at+1:]
+
+	for _, realm := range identityConfig.KerberosRealms {
+		if strings.EqualFold(domain, realm) {
+			// Drop the instance of principals like user/admin@EXAMPLE.ORG
+			if slash := strings.Index(name, "/"); slash >= 0 {
+				name = name[:slash]
+			}
+			return name
+		}
+	}
+
+	// Viper lowercases map keys, and domains are case insensitive
+	for from, to := range identityConfig.DomainMap {
+		if strings.EqualFold(domain, from) {
+			if to == "" {
+				return name
+			}
+			return name + "@" + to
+		}
+	}
+
+	return username
+}
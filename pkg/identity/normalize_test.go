@@ -0,0 +1,52 @@
+// Copyright 2021-2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package identity
+
+import (
+	"testing"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+)
+
+func TestNormalizeUsername(t *testing.T) {
+	identityConfig := config.IdentityConfig{
+		KerberosRealms: []string{"IPA.EXAMPLE.ORG"},
+		DomainMap: map[string]string{
+			"corp.example.com":  "example.org",
+			"users.example.org": "",
+		},
+	}
+
+	tests := []struct {
+		username string
+		want     string
+	}{
+		{"sre", "sre"},
+		{" sre ", "sre"},
+		{"sre@IPA.EXAMPLE.ORG", "sre"},
+		{"sre/admin@IPA.EXAMPLE.ORG", "sre"},
+		{"sre@OTHER.REALM", "sre@OTHER.REALM"},
+		{"sre@Corp.Example.com", "sre@example.org"},
+		{"sre@users.example.org", "sre"},
+		{"sre@example.org", "sre@example.org"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.username, func(t *testing.T) {
+			if got := NormalizeUsername(tt.username, identityConfig); got != tt.want {
+				t.Errorf("NormalizeUsername(%q) = %q, want %q", tt.username, got, tt.want)
+			}
+		})
+	}
+}
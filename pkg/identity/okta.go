@@ -0,0 +1,152 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package identity
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/helpers"
+)
+
+// oktaNextLink matches the next page URL in an Okta Link header
+var oktaNextLink = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// oktaProvider resolves users with the Okta Users API
+type oktaProvider struct {
+	config config.OktaConfig
+	client *http.Client
+}
+
+type oktaUser struct {
+	ID      string                 `json:"id"`
+	Profile map[string]interface{} `json:"profile"`
+}
+
+type oktaGroup struct {
+	ID      string `json:"id"`
+	Profile struct {
+		Name string `json:"name"`
+	} `json:"profile"`
+}
+
+func newOktaProvider(oktaConfig config.OktaConfig) *oktaProvider {
+	return &oktaProvider{config: oktaConfig, client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// ResolveUser implements the Provider interface. The manager attribute of the user's
+// profile may hold the manager's Okta ID or login, and is resolved to their username.
+func (o *oktaProvider) ResolveUser(ctx context.Context, username string) (Identity, error) {
+	user, err := o.getUser(ctx, username)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	identity := Identity{Username: profileString(user.Profile, o.config.UsernameAttribute)}
+	if identity.Username == "" {
+		return Identity{}, fmt.Errorf("okta user %s has no %s profile attribute", username, o.config.UsernameAttribute)
+	}
+
+	managerRef := profileString(user.Profile, o.config.ManagerAttribute)
+	if managerRef == "" {
+		return identity, nil
+	}
+
+	manager, err := o.getUser(ctx, managerRef)
+	if err != nil {
+		return Identity{}, fmt.Errorf("failed to look up the manager of %s: %w", username, err)
+	}
+	identity.Manager = profileString(manager.Profile, o.config.UsernameAttribute)
+
+	return identity, nil
+}
+
+// IsGroupMember implements the GroupChecker interface, with the group given as an Okta group name or ID
+func (o *oktaProvider) IsGroupMember(ctx context.Context, username, group string) (bool, error) {
+	user, err := o.getUser(ctx, username)
+	if err != nil {
+		return false, err
+	}
+
+	next := fmt.Sprintf("%s/api/v1/users/%s/groups", strings.TrimSuffix(o.config.OrgURL, "/"), url.PathEscape(user.ID))
+	for next != "" {
+		var groups []oktaGroup
+		next, err = o.get(ctx, next, &groups)
+		if err != nil {
+			return false, err
+		}
+		for _, g := range groups {
+			if g.ID == group || g.Profile.Name == group {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// getUser fetches the user by Okta ID, login or login shortname
+func (o *oktaProvider) getUser(ctx context.Context, user string) (oktaUser, error) {
+	var u oktaUser
+	_, err := o.get(ctx, fmt.Sprintf("%s/api/v1/users/%s", strings.TrimSuffix(o.config.OrgURL, "/"), url.PathEscape(user)), &u)
+	if err != nil {
+		return oktaUser{}, fmt.Errorf("failed to get okta user %s: %w", user, err)
+	}
+	return u, nil
+}
+
+// get decodes the JSON response to a GET request into dst, and returns the URL of the next page, if any
+func (o *oktaProvider) get(ctx context.Context, requestURL string, dst interface{}) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, http.NoBody)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Add("Authorization", "SSWS "+o.config.Token)
+	req.Header.Add("Accept", "application/json")
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("okta request failed: %s", resp.Status)
+	}
+	if err := helpers.DecodeJSONResponseBody(resp, dst); err != nil {
+		return "", err
+	}
+
+	for _, link := range resp.Header.Values("Link") {
+		if m := oktaNextLink.FindStringSubmatch(link); m != nil {
+			return m[1], nil
+		}
+	}
+	return "", nil
+}
+
+// profileString returns the string value of the profile attribute
+func profileString(profile map[string]interface{}, attribute string) string {
+	value, _ := profile[attribute].(string)
+	return value
+}
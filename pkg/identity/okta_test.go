@@ -0,0 +1,83 @@
+// Copyright 2021-2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package identity
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+)
+
+func newOktaTestServer() *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "SSWS test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/users/sre":
+			_, _ = w.Write([]byte(`{"id":"00u1","profile":{"login":"sre@example.org","managerId":"00u2"}}`))
+		case "/api/v1/users/00u2":
+			_, _ = w.Write([]byte(`{"id":"00u2","profile":{"login":"manager@example.org"}}`))
+		case "/api/v1/users/00u1/groups":
+			if r.URL.Query().Get("after") == "" {
+				w.Header().Set("Link", fmt.Sprintf(`<%s/api/v1/users/00u1/groups?after=1>; rel="next"`, server.URL))
+				_, _ = w.Write([]byte(`[{"id":"00g1","profile":{"name":"Everyone"}}]`))
+				return
+			}
+			_, _ = w.Write([]byte(`[{"id":"00g2","profile":{"name":"SRE"}}]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return server
+}
+
+func TestOktaProvider(t *testing.T) {
+	server := newOktaTestServer()
+	defer server.Close()
+
+	provider := newOktaProvider(config.OktaConfig{
+		OrgURL:            server.URL,
+		Token:             "test-token",
+		UsernameAttribute: "login",
+		ManagerAttribute:  "managerId",
+	})
+
+	got, err := provider.ResolveUser(context.Background(), "sre")
+	if err != nil {
+		t.Fatalf("ResolveUser() unexpected error: %v", err)
+	}
+	if want := (Identity{Username: "sre@example.org", Manager: "manager@example.org"}); got != want {
+		t.Errorf("ResolveUser() = %+v, want %+v", got, want)
+	}
+
+	if _, err := provider.ResolveUser(context.Background(), "nobody"); err == nil {
+		t.Errorf("ResolveUser() expected an error for an unknown user")
+	}
+
+	for group, want := range map[string]bool{"SRE": true, "00g1": true, "Admins": false} {
+		member, err := provider.IsGroupMember(context.Background(), "sre", group)
+		if err != nil || member != want {
+			t.Errorf("IsGroupMember(%q) = %v, %v, want %v", group, member, err, want)
+		}
+	}
+}
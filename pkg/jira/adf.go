@@ -0,0 +1,409 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jira
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/openshift/compliance-audit-router/pkg/config"
+)
+
+// Document formats used for issue descriptions and comments
+const (
+	DocumentFormatWiki = "wiki"
+	DocumentFormatADF  = "adf"
+	DocumentFormatAuto = "auto"
+)
+
+// mentionPattern matches the wiki markup user mentions used in the message template
+var mentionPattern = regexp.MustCompile(`\[~accountid:([^\]]+)\]`)
+
+// boldPattern matches wiki markup bold text, eg. *approved*
+var boldPattern = regexp.MustCompile(`\*([^*\s](?:[^*]*[^*\s])?)\*`)
+
+// listItemPattern matches a wiki markup list item: its markers, * or - for bulleted and # for numbered lists, and text
+var listItemPattern = regexp.MustCompile(`^\s*([*#]+|-)\s+(\S.*)$`)
+
+// codeTag starts and ends a wiki markup code block, eg. {code:yaml}
+const codeTag = "{code"
+
+// adfDocument is the root of an Atlassian Document Format (ADF) document,
+// used by the Jira Cloud v3 API for rich text fields
+type adfDocument struct {
+	Version int       `json:"version"`
+	Type    string    `json:"type"`
+	Content []adfNode `json:"content"`
+}
+
+// adfNode is a block or inline node in an ADF document
+type adfNode struct {
+	Type    string            `json:"type"`
+	Text    string            `json:"text,omitempty"`
+	Attrs   map[string]string `json:"attrs,omitempty"`
+	Marks   []adfMark         `json:"marks,omitempty"`
+	Content []adfNode         `json:"content,omitempty"`
+}
+
+// adfMark formats the text of an ADF text node, eg. as bold
+type adfMark struct {
+	Type string `json:"type"`
+}
+
+// toADF converts wiki markup into an ADF document. Blank lines separate paragraphs, single newlines
+// become hard breaks, and account mentions, bold text, bulleted and numbered lists, tables and {code}
+// blocks are converted into their ADF nodes. Nested lists are flattened, and other markup, eg. links,
+// is kept as plain text.
+func toADF(text string) adfDocument {
+	doc := adfDocument{Version: 1, Type: "doc", Content: []adfNode{}}
+
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); {
+		var node adfNode
+		switch line := strings.TrimSpace(lines[i]); {
+		case line == "":
+			i++
+			continue
+		case strings.HasPrefix(line, codeTag):
+			node, i = codeBlockADF(lines, i)
+		case strings.HasPrefix(line, "|"):
+			node = adfNode{Type: "table"}
+			for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), "|"); i++ {
+				node.Content = append(node.Content, tableRowADF(lines[i]))
+			}
+		case listItemPattern.MatchString(line):
+			node, i = listADF(lines, i)
+		default:
+			node = adfNode{Type: "paragraph"}
+			for start := i; i < len(lines) && (i == start || !startsBlock(lines[i])); i++ {
+				if i > start {
+					node.Content = append(node.Content, adfNode{Type: "hardBreak"})
+				}
+				node.Content = append(node.Content, inlineADF(lines[i])...)
+			}
+		}
+		doc.Content = append(doc.Content, node)
+	}
+
+	return doc
+}
+
+// startsBlock reports whether the line ends a paragraph, being blank or starting a code block, table or list
+func startsBlock(line string) bool {
+	line = strings.TrimSpace(line)
+	return line == "" || strings.HasPrefix(line, codeTag) || strings.HasPrefix(line, "|") || listItemPattern.MatchString(line)
+}
+
+// codeBlockADF converts the {code} block starting at the line into an ADF code block, returning the line
+// after it. Text following the closing tag on its line is left to be converted as the next line.
+func codeBlockADF(lines []string, i int) (adfNode, int) {
+	line := strings.TrimSpace(lines[i])
+	node := adfNode{Type: "codeBlock"}
+
+	// The tag's parameters are the language, eg. {code:yaml}, and options like title=...
+	tagEnd := strings.Index(line, "}")
+	if tagEnd < 0 {
+		tagEnd = len(line) - 1
+	}
+	if params, ok := strings.CutPrefix(line[:tagEnd], codeTag+":"); ok {
+		if language, _, _ := strings.Cut(params, "|"); language != "" && !strings.Contains(language, "=") {
+			node.Attrs = map[string]string{"language": language}
+		}
+	}
+
+	var code []string
+	rest := line[tagEnd+1:]
+	for {
+		if before, after, closed := strings.Cut(rest, "{code}"); closed {
+			code = append(code, before)
+			if strings.TrimSpace(after) != "" {
+				lines[i] = after
+				break
+			}
+			i++
+			break
+		}
+		code = append(code, rest)
+		i++
+		if i == len(lines) {
+			break
+		}
+		rest = lines[i]
+	}
+
+	// The lines of the opening and closing tags aren't part of the code
+	if len(code) > 0 && strings.TrimSpace(code[0]) == "" {
+		code = code[1:]
+	}
+	if len(code) > 0 && strings.TrimSpace(code[len(code)-1]) == "" {
+		code = code[:len(code)-1]
+	}
+	if text := strings.Join(code, "\n"); text != "" {
+		node.Content = []adfNode{{Type: "text", Text: text}}
+	}
+	return node, i
+}
+
+// tableRowADF converts a wiki markup table row into an ADF table row. Cells following || are headers,
+// and \| is a literal | in a cell.
+func tableRowADF(line string) adfNode {
+	line = strings.TrimSpace(line)
+	row := adfNode{Type: "tableRow"}
+
+	var cell strings.Builder
+	header, started := false, false
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case line[i] == '|':
+			if started {
+				row.Content = append(row.Content, tableCellADF(cell.String(), header))
+			}
+			header = i+1 < len(line) && line[i+1] == '|'
+			if header {
+				i++
+			}
+			cell.Reset()
+			started = true
+		default:
+			cell.WriteByte(line[i])
+		}
+	}
+	// Rows usually end with a separator; text after the last one is a cell of its own
+	if strings.TrimSpace(cell.String()) != "" {
+		row.Content = append(row.Content, tableCellADF(cell.String(), header))
+	}
+
+	return row
+}
+
+// tableCellADF converts the text of a table cell into an ADF table cell or header
+func tableCellADF(text string, header bool) adfNode {
+	cellType := "tableCell"
+	if header {
+		cellType = "tableHeader"
+	}
+	return adfNode{Type: cellType, Content: []adfNode{{Type: "paragraph", Content: inlineADF(strings.TrimSpace(text))}}}
+}
+
+// listADF converts the list items starting at the line into an ADF list, returning the line after them.
+// The list ends at the first line that isn't an item of the same kind of list.
+func listADF(lines []string, i int) (adfNode, int) {
+	listType := func(markers string) string {
+		if strings.HasPrefix(markers, "#") {
+			return "orderedList"
+		}
+		return "bulletList"
+	}
+
+	m := listItemPattern.FindStringSubmatch(lines[i])
+	node := adfNode{Type: listType(m[1])}
+	for ; i < len(lines); i++ {
+		m := listItemPattern.FindStringSubmatch(lines[i])
+		if m == nil || listType(m[1]) != node.Type {
+			break
+		}
+		node.Content = append(node.Content, adfNode{Type: "listItem", Content: []adfNode{{Type: "paragraph", Content: inlineADF(m[2])}}})
+	}
+	return node, i
+}
+
+// inlineADF converts a single line of text into ADF text and mention nodes
+func inlineADF(line string) []adfNode {
+	var nodes []adfNode
+
+	last := 0
+	for _, m := range mentionPattern.FindAllStringSubmatchIndex(line, -1) {
+		if m[0] > last {
+			nodes = append(nodes, textADF(line[last:m[0]])...)
+		}
+		nodes = append(nodes, adfNode{Type: "mention", Attrs: map[string]string{"id": line[m[2]:m[3]]}})
+		last = m[1]
+	}
+	if last < len(line) {
+		nodes = append(nodes, textADF(line[last:])...)
+	}
+
+	return nodes
+}
+
+// textADF converts text into ADF text nodes, with the strong mark for bold text. Like Jira, asterisks within
+// words, eg. in wildcards like *.example.com or get*, don't start or end bold text.
+func textADF(text string) []adfNode {
+	var nodes []adfNode
+
+	last := 0
+	for _, m := range boldPattern.FindAllStringSubmatchIndex(text, -1) {
+		if (m[0] > 0 && isWordByte(text[m[0]-1])) || (m[1] < len(text) && isWordByte(text[m[1]])) {
+			continue
+		}
+		if m[0] > last {
+			nodes = append(nodes, adfNode{Type: "text", Text: text[last:m[0]]})
+		}
+		nodes = append(nodes, adfNode{Type: "text", Text: text[m[2]:m[3]], Marks: []adfMark{{Type: "strong"}}})
+		last = m[1]
+	}
+	if last < len(text) {
+		nodes = append(nodes, adfNode{Type: "text", Text: text[last:]})
+	}
+
+	return nodes
+}
+
+// isWordByte reports whether the byte is part of a word, a letter, digit or underscore, or of a multi-byte character
+func isWordByte(b byte) bool {
+	return b == '_' || b >= utf8.RuneSelf || unicode.IsLetter(rune(b)) || unicode.IsDigit(rune(b))
+}
+
+var (
+	detectedADFMutex sync.Mutex
+	detectedADF      = map[string]bool{}
+)
+
+// useADF reports whether descriptions and comments should be sent as ADF.
+// In "auto" mode the Jira deployment type is queried once per instance and cached;
+// Jira Cloud instances use ADF, everything else uses wiki markup.
+func useADF(client *jira.Client, format string) bool {
+	switch format {
+	case DocumentFormatADF:
+		return true
+	case DocumentFormatAuto:
+		if config.AppConfig().DryRun {
+			log.Printf("jira.useADF(): dry-run mode: would have detected the Jira deployment type; using wiki markup")
+			return false
+		}
+		baseURL := client.GetBaseURL()
+		host := baseURL.String()
+
+		detectedADFMutex.Lock()
+		adf, ok := detectedADF[host]
+		detectedADFMutex.Unlock()
+		if ok {
+			return adf
+		}
+
+		// The lock isn't held while Jira is queried, so a slow instance doesn't hold up the others. Concurrent
+		// first calls for an instance may each query it, with the same result.
+		deploymentType, err := getDeploymentType(client)
+		if err != nil {
+			// Don't cache failures, so detection is retried on the next call
+			log.Printf("jira.useADF(): failed to detect Jira deployment type of %v, falling back to wiki markup: %v\n", host, err)
+			return false
+		}
+		adf = deploymentType == "Cloud"
+		detectedADFMutex.Lock()
+		detectedADF[host] = adf
+		detectedADFMutex.Unlock()
+		return adf
+	default:
+		return false
+	}
+}
+
+// getDeploymentType returns the deploymentType reported by the Jira serverInfo endpoint
+func getDeploymentType(client *jira.Client) (string, error) {
+	req, err := client.NewRequest("GET", "rest/api/2/serverInfo", nil)
+	if err != nil {
+		return "", err
+	}
+
+	serverInfo := struct {
+		DeploymentType string `json:"deploymentType"`
+	}{}
+	_, err = client.Do(req, &serverInfo)
+	if err != nil {
+		return "", err
+	}
+
+	return serverInfo.DeploymentType, nil
+}
+
+// createIssue creates the issue with the given description, using the
+// v3 API with an ADF description when the instance requires it
+func createIssue(client *jira.Client, format string, issue *jira.Issue, description string) (*jira.Issue, error) {
+	if !useADF(client, format) {
+		issue.Fields.Description = description
+		created, resp, err := client.Issue.Create(issue)
+		if err != nil {
+			return nil, withStatus(resp, err)
+		}
+		return created, nil
+	}
+
+	fields, err := adfIssueFields(issue, description)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := client.NewRequest("POST", "rest/api/3/issue", map[string]interface{}{"fields": fields})
+	if err != nil {
+		return nil, err
+	}
+
+	created := &jira.Issue{}
+	resp, err := client.Do(req, created)
+	if err != nil {
+		return nil, withStatus(resp, fmt.Errorf("failed to create issue with ADF description: %w", err))
+	}
+
+	return created, nil
+}
+
+// adfIssueFields returns the issue fields with the description as an ADF document.
+// go-jira only knows how to marshal string descriptions, so the fields are
+// marshalled through a map and the ADF document swapped in.
+func adfIssueFields(issue *jira.Issue, description string) (map[string]interface{}, error) {
+	b, err := json.Marshal(issue.Fields)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]interface{}{}
+	if err = json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+	fields["description"] = toADF(description)
+
+	return fields, nil
+}
+
+// addComment adds a comment to the issue, as ADF when the instance requires it
+func addComment(client *jira.Client, format string, issueID string, body string) error {
+	if !useADF(client, format) {
+		_, _, err := client.Issue.AddComment(issueID, &jira.Comment{Body: body})
+		return err
+	}
+
+	req, err := client.NewRequest("POST", fmt.Sprintf("rest/api/3/issue/%s/comment", issueID), struct {
+		Body adfDocument `json:"body"`
+	}{toADF(body)})
+	if err != nil {
+		return err
+	}
+
+	_, err = client.Do(req, nil)
+	return err
+}
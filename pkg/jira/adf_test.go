@@ -0,0 +1,187 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jira
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+func TestToADF(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want adfDocument
+	}{
+		{
+			name: "empty text produces an empty document",
+			text: "",
+			want: adfDocument{Version: 1, Type: "doc", Content: []adfNode{}},
+		},
+		{
+			name: "blank lines separate paragraphs",
+			text: "first\n\nsecond",
+			want: adfDocument{Version: 1, Type: "doc", Content: []adfNode{
+				{Type: "paragraph", Content: []adfNode{{Type: "text", Text: "first"}}},
+				{Type: "paragraph", Content: []adfNode{{Type: "text", Text: "second"}}},
+			}},
+		},
+		{
+			name: "single newlines become hard breaks",
+			text: "first\nsecond",
+			want: adfDocument{Version: 1, Type: "doc", Content: []adfNode{
+				{Type: "paragraph", Content: []adfNode{
+					{Type: "text", Text: "first"},
+					{Type: "hardBreak"},
+					{Type: "text", Text: "second"},
+				}},
+			}},
+		},
+		{
+			name: "account mentions become mention nodes",
+			text: "[~accountid:abc123], please respond",
+			want: adfDocument{Version: 1, Type: "doc", Content: []adfNode{
+				{Type: "paragraph", Content: []adfNode{
+					{Type: "mention", Attrs: map[string]string{"id": "abc123"}},
+					{Type: "text", Text: ", please respond"},
+				}},
+			}},
+		},
+		{
+			name: "bold text is marked strong, but not asterisks within words",
+			text: "*approved* for get* on *.example.com",
+			want: adfDocument{Version: 1, Type: "doc", Content: []adfNode{
+				{Type: "paragraph", Content: []adfNode{
+					{Type: "text", Text: "approved", Marks: []adfMark{{Type: "strong"}}},
+					{Type: "text", Text: " for get* on *.example.com"},
+				}},
+			}},
+		},
+		{
+			name: "list items become lists, ending the paragraph",
+			text: "Commands:\n* oc get pods\n* oc delete pod\n# first\n## nested",
+			want: adfDocument{Version: 1, Type: "doc", Content: []adfNode{
+				{Type: "paragraph", Content: []adfNode{{Type: "text", Text: "Commands:"}}},
+				{Type: "bulletList", Content: []adfNode{
+					{Type: "listItem", Content: []adfNode{{Type: "paragraph", Content: []adfNode{{Type: "text", Text: "oc get pods"}}}}},
+					{Type: "listItem", Content: []adfNode{{Type: "paragraph", Content: []adfNode{{Type: "text", Text: "oc delete pod"}}}}},
+				}},
+				{Type: "orderedList", Content: []adfNode{
+					{Type: "listItem", Content: []adfNode{{Type: "paragraph", Content: []adfNode{{Type: "text", Text: "first"}}}}},
+					{Type: "listItem", Content: []adfNode{{Type: "paragraph", Content: []adfNode{{Type: "text", Text: "nested"}}}}},
+				}},
+			}},
+		},
+		{
+			name: "tables become tables with header cells",
+			text: "||User||Reasons||\n|sre|fix a \\| pipe|\n| |[~accountid:abc123]|",
+			want: adfDocument{Version: 1, Type: "doc", Content: []adfNode{
+				{Type: "table", Content: []adfNode{
+					{Type: "tableRow", Content: []adfNode{
+						{Type: "tableHeader", Content: []adfNode{{Type: "paragraph", Content: []adfNode{{Type: "text", Text: "User"}}}}},
+						{Type: "tableHeader", Content: []adfNode{{Type: "paragraph", Content: []adfNode{{Type: "text", Text: "Reasons"}}}}},
+					}},
+					{Type: "tableRow", Content: []adfNode{
+						{Type: "tableCell", Content: []adfNode{{Type: "paragraph", Content: []adfNode{{Type: "text", Text: "sre"}}}}},
+						{Type: "tableCell", Content: []adfNode{{Type: "paragraph", Content: []adfNode{{Type: "text", Text: "fix a | pipe"}}}}},
+					}},
+					{Type: "tableRow", Content: []adfNode{
+						{Type: "tableCell", Content: []adfNode{{Type: "paragraph"}}},
+						{Type: "tableCell", Content: []adfNode{{Type: "paragraph", Content: []adfNode{{Type: "mention", Attrs: map[string]string{"id": "abc123"}}}}}},
+					}},
+				}},
+			}},
+		},
+		{
+			name: "code blocks keep their text as is",
+			text: "Commands:\n{code:bash}\noc get *\n\n*not bold*\n{code}\nafter",
+			want: adfDocument{Version: 1, Type: "doc", Content: []adfNode{
+				{Type: "paragraph", Content: []adfNode{{Type: "text", Text: "Commands:"}}},
+				{Type: "codeBlock", Attrs: map[string]string{"language": "bash"}, Content: []adfNode{{Type: "text", Text: "oc get *\n\n*not bold*"}}},
+				{Type: "paragraph", Content: []adfNode{{Type: "text", Text: "after"}}},
+			}},
+		},
+		{
+			name: "code on a single line",
+			text: "{code}oc whoami{code} was run",
+			want: adfDocument{Version: 1, Type: "doc", Content: []adfNode{
+				{Type: "codeBlock", Content: []adfNode{{Type: "text", Text: "oc whoami"}}},
+				{Type: "paragraph", Content: []adfNode{{Type: "text", Text: " was run"}}},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := toADF(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("toADF() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUseADFDoesNotBlockOtherInstances(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		_, _ = w.Write([]byte(`{"deploymentType":"Server"}`))
+	}))
+	defer slow.Close()
+	defer close(release)
+	cloud := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"deploymentType":"Cloud"}`))
+	}))
+	defer cloud.Close()
+
+	slowClient, err := NewClient(config.JiraConfig{Host: slow.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cloudClient, err := NewClient(config.JiraConfig{Host: cloud.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go useADF(slowClient, DocumentFormatAuto)
+	<-started
+
+	// The detection of the other instance completes while the slow instance is still being queried
+	detected := make(chan bool)
+	go func() { detected <- useADF(cloudClient, DocumentFormatAuto) }()
+	select {
+	case adf := <-detected:
+		if !adf {
+			t.Errorf("useADF() = false for a Jira Cloud instance")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("useADF() was blocked by the detection of another instance")
+	}
+}
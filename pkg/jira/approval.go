@@ -0,0 +1,188 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jira
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/openshift/compliance-audit-router/pkg/audit"
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/helpers"
+	"github.com/openshift/compliance-audit-router/pkg/metrics"
+	"github.com/openshift/compliance-audit-router/pkg/splunk"
+)
+
+// readOnlyComment explains the automatic resolution of read-only elevation sessions, listing the commands for the audit
+const readOnlyComment = "This elevation session has been resolved automatically, as its elevated commands were all read-only:\n%v"
+
+// defaultJustificationTemplate asks for a proper justification when jiraconfig.justificationtemplate isn't set
+const defaultJustificationTemplate = "{{.Username}}, the justification" +
+	"{{with .MinLength}} must be at least {{.}} characters long{{end}}" +
+	"{{if and .MinLength .Pattern}} and{{end}}" +
+	"{{with .Pattern}} must match {{.}}{{end}}. " +
+	"Please add a comment describing why the elevated access was needed."
+
+// JustificationData is the data available to the justification template
+type JustificationData struct {
+	// Username is a Jira mention of the SRE who commented
+	Username string
+	IssueKey string
+	// MinLength and Pattern are the configured requirements of a justification
+	MinLength int
+	Pattern   string
+}
+
+// approvalRejection enforces the two-step approval: the SRE must justify the elevation with a
+// comment of the minimum length, matching the justification pattern, before the manager can approve it.
+// It returns an explanation to leave on the issue when the comment can't trigger its transition, or an empty string.
+func approvalRejection(jiraConfig config.JiraConfig, issue *jira.Issue, sreId string, step string, comment jira.Comment) (string, error) {
+	switch step {
+	case sreTransitionKey:
+		if !isJustification(jiraConfig, comment.Body) {
+			return renderJustificationRequest(jiraConfig, JustificationData{
+				Username:  fmt.Sprintf("[~accountid:%v]", comment.Author.AccountID),
+				IssueKey:  issue.Key,
+				MinLength: jiraConfig.MinJustificationLength,
+				Pattern:   jiraConfig.JustificationPattern,
+			})
+		}
+	case managerTransitionKey:
+		if issue.Fields.Status == nil || issue.Fields.Status.Name != jiraConfig.Transitions[sreTransitionKey] {
+			return fmt.Sprintf("[~accountid:%v], this issue can't be approved until the engineer has provided their justification "+
+				"and the issue is in the %v status.", comment.Author.AccountID, jiraConfig.Transitions[sreTransitionKey]), nil
+		}
+		if !hasJustification(jiraConfig, issue, sreId) {
+			requirement := fmt.Sprintf("of at least %v characters", jiraConfig.MinJustificationLength)
+			if jiraConfig.JustificationPattern != "" {
+				requirement = fmt.Sprintf("matching %v", jiraConfig.JustificationPattern)
+			}
+			return fmt.Sprintf("[~accountid:%v], this issue can't be approved until the engineer has left a justification "+
+				"%s.", comment.Author.AccountID, requirement), nil
+		}
+	}
+
+	return "", nil
+}
+
+// isJustification reports whether the comment is of the minimum length and matches the justification pattern
+func isJustification(jiraConfig config.JiraConfig, body string) bool {
+	body = strings.TrimSpace(body)
+	if len(body) < jiraConfig.MinJustificationLength {
+		return false
+	}
+	if jiraConfig.JustificationPattern == "" {
+		return true
+	}
+	// The pattern is checked when the configuration is loaded
+	matched, err := regexp.MatchString(jiraConfig.JustificationPattern, body)
+	return err == nil && matched
+}
+
+// hasJustification reports whether the SRE has left a justification on the issue
+func hasJustification(jiraConfig config.JiraConfig, issue *jira.Issue, sreId string) bool {
+	if issue.Fields.Comments == nil {
+		return false
+	}
+
+	for _, c := range issue.Fields.Comments.Comments {
+		if c != nil && c.Author.AccountID == sreId && isJustification(jiraConfig, c.Body) {
+			return true
+		}
+	}
+	return false
+}
+
+// issueComment returns the comment of the issue with the ID
+func issueComment(issue *jira.Issue, id string) (*jira.Comment, bool) {
+	if id == "" || issue.Fields.Comments == nil {
+		return nil, false
+	}
+
+	for _, c := range issue.Fields.Comments.Comments {
+		if c != nil && c.ID == id {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// renderJustificationRequest renders the comment asking the SRE for a proper justification
+func renderJustificationRequest(jiraConfig config.JiraConfig, data JustificationData) (string, error) {
+	justificationTemplate := jiraConfig.JustificationTemplate
+	if justificationTemplate == "" {
+		justificationTemplate = defaultJustificationTemplate
+	}
+
+	tmpl, err := helpers.ParseTemplate("justificationTemplate", justificationTemplate)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse justification template: %w", err)
+	}
+
+	var message bytes.Buffer
+	if err := tmpl.Execute(&message, data); err != nil {
+		return "", fmt.Errorf("failed to apply justification template: %w", err)
+	}
+	return message.String(), nil
+}
+
+// resolveReadOnly comments on the issue that it was resolved as the elevated commands were all read-only,
+// listing them for the audit, and applies the manager transition, on an issue in the sre transition status
+func resolveReadOnly(client *jira.Client, jiraConfig config.JiraConfig, issue *jira.Issue, alert splunk.AlertDetails) error {
+	commands := make([]string, 0, len(alert.ElevatedSummary))
+	for _, command := range alert.ElevatedSummary {
+		commands = append(commands, " - "+command)
+	}
+
+	if err := approve(client, jiraConfig, issue, fmt.Sprintf(readOnlyComment, strings.Join(commands, "\n"))); err != nil {
+		return err
+	}
+
+	log.Printf("jira.resolveReadOnly(): issue %v has been resolved automatically for a read-only elevation session", issue.Key)
+	metrics.MetricJiraReadOnlyResolved.Inc()
+	audit.Write(audit.ActionReadOnlyResolved, issue.Key, map[string]string{"commands": strings.Join(alert.ElevatedSummary, "\n")})
+
+	return nil
+}
+
+// approve leaves the comment explaining the automatic approval on the issue, and applies the
+// manager transition, on an issue in the sre transition status
+func approve(client *jira.Client, jiraConfig config.JiraConfig, issue *jira.Issue, message string) error {
+	if config.AppConfig().DryRunComments() {
+		log.Printf("jira.approve(): dry-run mode: would have added comment to Jira ticket with the following body: %v", message)
+	} else if err := addComment(client, jiraConfig.DocumentFormat, issue.ID, message); err != nil {
+		return fmt.Errorf("failed to comment on the automatic approval of issue %v: %w", issue.Key, err)
+	}
+
+	statusName := jiraConfig.Transitions[managerTransitionKey]
+	statusId, err := transitionID(client, jiraConfig, issue.ID, statusName)
+	if err != nil {
+		return fmt.Errorf("failed to fetch ID for status %v: %w", statusName, err)
+	}
+
+	if config.AppConfig().DryRunTransitions() {
+		log.Printf("jira.approve(): dry-run mode: would have transitioned Jira ticket %v to status %v", issue.Key, statusName)
+	} else if err := doTransition(client, jiraConfig, issue.ID, statusName, statusId); err != nil {
+		return fmt.Errorf("failed to transition issue %v to status %v: %w", issue.Key, statusName, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,194 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jira
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/splunk"
+)
+
+func TestApprovalRejection(t *testing.T) {
+	jiraConfig := config.JiraConfig{
+		Transitions:            map[string]string{"initial": "In Progress", "sre": "Pending Approval", "manager": "Done"},
+		MinJustificationLength: 10,
+	}
+
+	issue := func(status string, comments ...*jira.Comment) *jira.Issue {
+		return &jira.Issue{Fields: &jira.IssueFields{
+			Status:   &jira.Status{Name: status},
+			Comments: &jira.Comments{Comments: comments},
+		}}
+	}
+	sreComment := func(body string) *jira.Comment {
+		return &jira.Comment{Body: body, Author: jira.User{AccountID: "sre"}}
+	}
+	managerComment := jira.Comment{Body: "approved", Author: jira.User{AccountID: "manager"}}
+
+	tests := []struct {
+		name         string
+		issue        *jira.Issue
+		step         string
+		comment      jira.Comment
+		wantRejected bool
+	}{
+		{
+			name:    "SRE justification of the minimum length",
+			issue:   issue("In Progress"),
+			step:    sreTransitionKey,
+			comment: *sreComment("needed to fix the cluster"),
+		},
+		{
+			name:         "SRE justification that is too short",
+			issue:        issue("In Progress"),
+			step:         sreTransitionKey,
+			comment:      *sreComment("fix"),
+			wantRejected: true,
+		},
+		{
+			name:    "manager approval after SRE justification",
+			issue:   issue("Pending Approval", sreComment("needed to fix the cluster")),
+			step:    managerTransitionKey,
+			comment: managerComment,
+		},
+		{
+			name:         "manager approval before SRE transition",
+			issue:        issue("In Progress", sreComment("needed to fix the cluster")),
+			step:         managerTransitionKey,
+			comment:      managerComment,
+			wantRejected: true,
+		},
+		{
+			name:         "manager approval without SRE justification",
+			issue:        issue("Pending Approval", sreComment("fix")),
+			step:         managerTransitionKey,
+			comment:      managerComment,
+			wantRejected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := approvalRejection(jiraConfig, tt.issue, "sre", tt.step, tt.comment)
+			if err != nil {
+				t.Fatalf("approvalRejection() error = %v", err)
+			}
+			if (got != "") != tt.wantRejected {
+				t.Errorf("approvalRejection() = %q, want rejected %v", got, tt.wantRejected)
+			}
+		})
+	}
+}
+
+func TestJustificationPattern(t *testing.T) {
+	jiraConfig := config.JiraConfig{
+		Transitions:           map[string]string{"initial": "In Progress", "sre": "Pending Approval", "manager": "Done"},
+		JustificationPattern:  `OHSS-\d+`,
+		JustificationTemplate: "{{.Username}} please link the OHSS ticket for {{.IssueKey}}, matching {{.Pattern}}",
+	}
+	issue := &jira.Issue{Key: "CAR-1", Fields: &jira.IssueFields{Status: &jira.Status{Name: "In Progress"}}}
+
+	got, err := approvalRejection(jiraConfig, issue, "sre", sreTransitionKey, jira.Comment{Body: "fixing the cluster for OHSS-1234", Author: jira.User{AccountID: "sre"}})
+	if err != nil || got != "" {
+		t.Errorf("approvalRejection() = %q, %v, want no rejection", got, err)
+	}
+
+	got, err = approvalRejection(jiraConfig, issue, "sre", sreTransitionKey, jira.Comment{Body: "fixing the cluster", Author: jira.User{AccountID: "sre"}})
+	want := `[~accountid:sre] please link the OHSS ticket for CAR-1, matching OHSS-\d+`
+	if err != nil || got != want {
+		t.Errorf("approvalRejection() = %q, %v, want %q", got, err, want)
+	}
+}
+
+func TestDefaultJustificationTemplate(t *testing.T) {
+	tests := []struct {
+		data JustificationData
+		want string
+	}{
+		{
+			JustificationData{Username: "[~accountid:sre]", MinLength: 10},
+			"[~accountid:sre], the justification must be at least 10 characters long. Please add a comment describing why the elevated access was needed.",
+		},
+		{
+			JustificationData{Username: "[~accountid:sre]", MinLength: 10, Pattern: "OHSS-[0-9]+"},
+			"[~accountid:sre], the justification must be at least 10 characters long and must match OHSS-[0-9]+. Please add a comment describing why the elevated access was needed.",
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := renderJustificationRequest(config.JiraConfig{}, tt.data)
+		if err != nil || got != tt.want {
+			t.Errorf("renderJustificationRequest() = %q, %v, want %q", got, err, tt.want)
+		}
+	}
+}
+
+func TestResolveReadOnly(t *testing.T) {
+	var comment, transition string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/rest/api/2/issue/1/comment":
+			var c jira.Comment
+			_ = json.NewDecoder(r.Body).Decode(&c)
+			comment = c.Body
+			_, _ = w.Write([]byte(`{"id":"100"}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/rest/api/2/issue/1/transitions":
+			_, _ = w.Write([]byte(`{"transitions":[{"id":"21","name":"Pending Approval"},{"id":"31","name":"Done"}]}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/rest/api/2/issue/1/transitions":
+			var payload struct {
+				Transition struct {
+					ID string `json:"id"`
+				} `json:"transition"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&payload)
+			transition = payload.Transition.ID
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	jiraConfig := config.JiraConfig{
+		Host:           server.URL,
+		DocumentFormat: "wiki",
+		Transitions:    map[string]string{"initial": "In Progress", "sre": "Pending Approval", "manager": "Done"},
+	}
+	client, err := NewClient(jiraConfig)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	alert := splunk.AlertDetails{ElevatedSummary: []string{"GET pods/a (default) 200", "LIST nodes 200"}}
+	if err := resolveReadOnly(client, jiraConfig, &jira.Issue{ID: "1", Key: "CAR-1"}, alert); err != nil {
+		t.Fatalf("resolveReadOnly() error = %v", err)
+	}
+
+	if !strings.Contains(comment, " - GET pods/a (default) 200\n - LIST nodes 200") {
+		t.Errorf("resolveReadOnly() comment = %q, missing the commands", comment)
+	}
+	if transition != "31" {
+		t.Errorf("resolveReadOnly() transition = %q, want the manager transition 31", transition)
+	}
+}
@@ -0,0 +1,192 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jira
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/metrics"
+)
+
+// ErrCircuitOpen is returned for the requests to a Jira instance whose circuit breaker is open
+var ErrCircuitOpen = errors.New("the Jira circuit breaker is open")
+
+// circuitBreaker fails the requests to a Jira instance right away once consecutive requests failed,
+// rather than waiting for each to time out. Once the cooldown has passed, a single request is sent
+// to check whether the instance recovered, closing the breaker when it succeeds.
+type circuitBreaker struct {
+	host      string
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func newCircuitBreaker(host string, threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{host: host, threshold: threshold, cooldown: cooldown}
+}
+
+// isOpen reports whether the breaker is open: it would fail a request sent now
+func (b *circuitBreaker) isOpen(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.threshold > 0 && b.failures >= b.threshold && (now.Before(b.openUntil) || b.probing)
+}
+
+// allow reports whether a request may be sent now, marking it as the probe of an open breaker after its cooldown
+func (b *circuitBreaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.threshold <= 0 || b.failures < b.threshold {
+		return true
+	}
+	if now.Before(b.openUntil) || b.probing {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// record records the result of a request, opening the breaker after threshold consecutive failures
+// and closing it after a success
+func (b *circuitBreaker) record(failed bool, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if !failed {
+		if b.failures >= b.threshold {
+			log.Printf("jira.circuitBreaker(): %v recovered; closing the circuit breaker", b.host)
+			metrics.MetricJiraCircuitOpen.With(map[string]string{"host": b.host}).Set(0)
+		}
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.failures >= b.threshold {
+		if b.failures == b.threshold {
+			log.Printf("jira.circuitBreaker(): %d consecutive requests to %v failed; opening the circuit breaker for %v", b.failures, b.host, b.cooldown)
+		}
+		b.openUntil = now.Add(b.cooldown)
+		metrics.MetricJiraCircuitOpen.With(map[string]string{"host": b.host}).Set(1)
+	}
+}
+
+// cancelled records a request cancelled by its caller, which tells nothing of the instance's availability,
+// so another probe may be sent
+func (b *circuitBreaker) cancelled() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+var (
+	breakersMutex sync.Mutex
+	breakers      = map[string]*circuitBreaker{}
+)
+
+// breakerFor returns the circuit breaker shared by all clients of the Jira instance
+func breakerFor(jiraConfig config.JiraConfig) *circuitBreaker {
+	breakersMutex.Lock()
+	defer breakersMutex.Unlock()
+
+	b, ok := breakers[jiraConfig.Host]
+	if !ok {
+		breakerConfig := config.AppConfig().BreakerConfig
+		b = newCircuitBreaker(jiraConfig.Host, breakerConfig.Threshold, breakerConfig.Cooldown)
+		breakers[jiraConfig.Host] = b
+	}
+	return b
+}
+
+// CircuitOpen reports whether the circuit breaker of the Jira instance is open, failing its requests
+func CircuitOpen(jiraConfig config.JiraConfig) bool {
+	return breakerFor(jiraConfig).isOpen(time.Now())
+}
+
+// OpenCircuits returns the hosts of the Jira instances whose circuit breaker is open, sorted
+func OpenCircuits() []string {
+	var hosts []string
+	for host, open := range CircuitStates() {
+		if open {
+			hosts = append(hosts, host)
+		}
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// CircuitStates returns whether the circuit breaker of each Jira instance called since the router started is open, by host
+func CircuitStates() map[string]bool {
+	breakersMutex.Lock()
+	defer breakersMutex.Unlock()
+
+	now := time.Now()
+	states := make(map[string]bool, len(breakers))
+	for host, b := range breakers {
+		states[host] = b.isOpen(now)
+	}
+	return states
+}
+
+// breakerTransport fails the requests sent through the wrapped transport while the breaker is open.
+// Requests that fail to connect or get a server error count as failures; cancelled requests don't count.
+type breakerTransport struct {
+	transport http.RoundTripper
+	breaker   *circuitBreaker
+}
+
+// RoundTrip implements the http.RoundTripper interface
+func (t *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.breaker.allow(time.Now()) {
+		return nil, ErrCircuitOpen
+	}
+
+	resp, err := t.transport.RoundTrip(req)
+	if err != nil && req.Context().Err() != nil {
+		t.breaker.cancelled()
+		return resp, err
+	}
+	t.breaker.record(err != nil || resp.StatusCode >= http.StatusInternalServerError, time.Now())
+	return resp, err
+}
+
+// withBreaker fails the client's requests while the circuit breaker of the Jira instance is open
+func withBreaker(client *http.Client, jiraConfig config.JiraConfig) *http.Client {
+	if config.AppConfig().BreakerConfig.Threshold <= 0 {
+		return client
+	}
+	transport := client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	client.Transport = &breakerTransport{transport: transport, breaker: breakerFor(jiraConfig)}
+	return client
+}
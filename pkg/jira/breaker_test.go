@@ -0,0 +1,115 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jira
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	b := newCircuitBreaker("jira.example.com", 2, time.Minute)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	b.record(true, now)
+	if !b.allow(now) || b.isOpen(now) {
+		t.Fatalf("circuitBreaker opened before the threshold")
+	}
+	b.record(true, now)
+	if b.allow(now) || !b.isOpen(now) {
+		t.Fatalf("circuitBreaker did not open after the threshold")
+	}
+
+	// After the cooldown, a single probe is allowed
+	later := now.Add(time.Minute)
+	if b.isOpen(later) || !b.allow(later) {
+		t.Fatalf("circuitBreaker did not allow a probe after the cooldown")
+	}
+	if b.allow(later) {
+		t.Errorf("circuitBreaker allowed a second probe")
+	}
+
+	// A failed probe opens the breaker for another cooldown
+	b.record(true, later)
+	if b.allow(later.Add(time.Second)) {
+		t.Errorf("circuitBreaker allowed a request after a failed probe")
+	}
+
+	// A successful probe closes it
+	later = later.Add(time.Minute)
+	if !b.allow(later) {
+		t.Fatalf("circuitBreaker did not allow a probe after the cooldown")
+	}
+	b.record(false, later)
+	if b.isOpen(later) || !b.allow(later) || !b.allow(later) {
+		t.Errorf("circuitBreaker did not close after a successful probe")
+	}
+}
+
+func TestBreakerTransport(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &breakerTransport{
+		transport: http.DefaultTransport,
+		breaker:   newCircuitBreaker(server.URL, 2, time.Minute),
+	}}
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("request %d failed: %v", i, err)
+		}
+		resp.Body.Close()
+	}
+
+	_, err := client.Get(server.URL)
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("request after the threshold = %v, want %v", err, ErrCircuitOpen)
+	}
+	if requests != 2 {
+		t.Errorf("server received %d requests, want 2", requests)
+	}
+}
+
+func TestOpenCircuits(t *testing.T) {
+	breakersMutex.Lock()
+	saved := breakers
+	open := newCircuitBreaker("https://down.example.com", 1, time.Hour)
+	open.record(true, time.Now())
+	breakers = map[string]*circuitBreaker{
+		"https://up.example.com":   newCircuitBreaker("https://up.example.com", 1, time.Hour),
+		"https://down.example.com": open,
+	}
+	breakersMutex.Unlock()
+	defer func() {
+		breakersMutex.Lock()
+		breakers = saved
+		breakersMutex.Unlock()
+	}()
+
+	if got := OpenCircuits(); len(got) != 1 || got[0] != "https://down.example.com" {
+		t.Errorf("OpenCircuits() = %v, want [https://down.example.com]", got)
+	}
+}
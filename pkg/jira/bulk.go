@@ -0,0 +1,144 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jira
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// bulkCreateRequest is the body of a Jira bulk issue create request
+type bulkCreateRequest struct {
+	IssueUpdates []bulkIssueUpdate `json:"issueUpdates"`
+}
+
+type bulkIssueUpdate struct {
+	Fields interface{} `json:"fields"`
+}
+
+// bulkCreateResponse is the response of a Jira bulk issue create request. Issues holds the
+// successfully created issues in request order, and Errors the elements that failed.
+type bulkCreateResponse struct {
+	Issues []jira.Issue `json:"issues"`
+	Errors []struct {
+		Status              int `json:"status"`
+		FailedElementNumber int `json:"failedElementNumber"`
+		ElementErrors       struct {
+			ErrorMessages []string          `json:"errorMessages"`
+			Errors        map[string]string `json:"errors"`
+		} `json:"elementErrors"`
+	} `json:"errors"`
+}
+
+// bulkCreateLimit is the number of issues Jira creates in a single bulk create request
+const bulkCreateLimit = 50
+
+// createIssues creates the prepared issues with the Jira bulk create API, in requests of up to bulkCreateLimit
+// issues. The returned issues and errors are in the same order as the prepared tickets.
+func createIssues(client *jira.Client, format string, tickets []preparedTicket) ([]*jira.Issue, []error) {
+	var createdIssues []*jira.Issue
+	var createErrors []error
+	for start := 0; start < len(tickets); start += bulkCreateLimit {
+		end := min(start+bulkCreateLimit, len(tickets))
+		issues, errs := createIssueChunk(client, format, tickets[start:end])
+		createdIssues = append(createdIssues, issues...)
+		createErrors = append(createErrors, errs...)
+	}
+	return createdIssues, createErrors
+}
+
+// createIssueChunk creates up to bulkCreateLimit prepared issues with a single call to the Jira bulk create API
+func createIssueChunk(client *jira.Client, format string, tickets []preparedTicket) ([]*jira.Issue, []error) {
+	createdIssues := make([]*jira.Issue, len(tickets))
+	createErrors := make([]error, len(tickets))
+
+	adf := useADF(client, format)
+	endpoint := "rest/api/2/issue/bulk"
+	if adf {
+		endpoint = "rest/api/3/issue/bulk"
+	}
+
+	body := bulkCreateRequest{}
+	for _, t := range tickets {
+		if !adf {
+			t.issue.Fields.Description = t.Description
+			body.IssueUpdates = append(body.IssueUpdates, bulkIssueUpdate{Fields: t.issue.Fields})
+			continue
+		}
+
+		fields, err := adfIssueFields(t.issue, t.Description)
+		if err != nil {
+			return createdIssues, fillErrors(createErrors, err)
+		}
+		body.IssueUpdates = append(body.IssueUpdates, bulkIssueUpdate{Fields: fields})
+	}
+
+	req, err := client.NewRequest("POST", endpoint, body)
+	if err != nil {
+		return createdIssues, fillErrors(createErrors, err)
+	}
+
+	// Jira responds with 201 when every issue was created and 400 when some or all
+	// failed, with the per-element errors in the body in both cases
+	resp := bulkCreateResponse{}
+	jiraResp, err := client.Do(req, &resp)
+	if err != nil {
+		// go-jira doesn't decode error responses, so decode the per-element errors here
+		if jiraResp == nil || jiraResp.StatusCode != http.StatusBadRequest {
+			return createdIssues, fillErrors(createErrors, withStatus(jiraResp, fmt.Errorf("bulk create failed: %w", err)))
+		}
+		defer jiraResp.Body.Close()
+		if decodeErr := json.NewDecoder(jiraResp.Body).Decode(&resp); decodeErr != nil {
+			return createdIssues, fillErrors(createErrors, withStatus(jiraResp, fmt.Errorf("bulk create failed: %w", err)))
+		}
+	}
+
+	for _, e := range resp.Errors {
+		if e.FailedElementNumber >= 0 && e.FailedElementNumber < len(createErrors) {
+			createErrors[e.FailedElementNumber] = &statusError{
+				status: e.Status,
+				err:    fmt.Errorf("bulk create failed with status %v: %v %v", e.Status, e.ElementErrors.ErrorMessages, e.ElementErrors.Errors),
+			}
+		}
+	}
+
+	created := 0
+	for i := range tickets {
+		if createErrors[i] != nil {
+			continue
+		}
+		if created >= len(resp.Issues) {
+			createErrors[i] = fmt.Errorf("bulk create response is missing the created issue")
+			continue
+		}
+		createdIssues[i] = &resp.Issues[created]
+		created++
+	}
+
+	return createdIssues, createErrors
+}
+
+// fillErrors sets every error in errs to err
+func fillErrors(errs []error, err error) []error {
+	for i := range errs {
+		errs[i] = err
+	}
+	return errs
+}
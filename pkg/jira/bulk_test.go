@@ -0,0 +1,138 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jira
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/openshift/compliance-audit-router/pkg/config"
+)
+
+func TestCreateIssues(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		response string
+		wantKeys []string
+	}{
+		{
+			name:     "all issues created",
+			status:   http.StatusCreated,
+			response: `{"issues":[{"id":"1","key":"OHSS-1"},{"id":"2","key":"OHSS-2"}],"errors":[]}`,
+			wantKeys: []string{"OHSS-1", "OHSS-2"},
+		},
+		{
+			name:     "partial failure reports the failed element",
+			status:   http.StatusBadRequest,
+			response: `{"issues":[{"id":"2","key":"OHSS-2"}],"errors":[{"status":400,"failedElementNumber":0,"elementErrors":{"errors":{"assignee":"invalid"}}}]}`,
+			wantKeys: []string{"", "OHSS-2"},
+		},
+		{
+			name:     "server errors fail every issue",
+			status:   http.StatusInternalServerError,
+			response: ``,
+			wantKeys: []string{"", ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/rest/api/2/issue/bulk" {
+					t.Errorf("unexpected request path: %v", r.URL.Path)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.response))
+			}))
+			defer server.Close()
+
+			client, err := NewClient(config.JiraConfig{Host: server.URL})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tickets := []preparedTicket{
+				{issue: &jira.Issue{Fields: &jira.IssueFields{}}},
+				{issue: &jira.Issue{Fields: &jira.IssueFields{}}},
+			}
+			issues, errs := createIssues(client, DocumentFormatWiki, tickets)
+
+			for i, wantKey := range tt.wantKeys {
+				if wantKey == "" {
+					if errs[i] == nil {
+						t.Errorf("createIssues() element %v: expected an error", i)
+					}
+					continue
+				}
+				if errs[i] != nil || issues[i] == nil || issues[i].Key != wantKey {
+					t.Errorf("createIssues() element %v = %v, %v, want %v", i, issues[i], errs[i], wantKey)
+				}
+			}
+		})
+	}
+}
+
+func TestCreateIssuesChunks(t *testing.T) {
+	var requestSizes []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			IssueUpdates []json.RawMessage `json:"issueUpdates"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		requestSizes = append(requestSizes, len(body.IssueUpdates))
+
+		resp := bulkCreateResponse{}
+		for range body.IssueUpdates {
+			resp.Issues = append(resp.Issues, jira.Issue{Key: fmt.Sprintf("OHSS-%d", len(resp.Issues))})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(config.JiraConfig{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tickets := make([]preparedTicket, 2*bulkCreateLimit+1)
+	for i := range tickets {
+		tickets[i].issue = &jira.Issue{Fields: &jira.IssueFields{}}
+	}
+	issues, errs := createIssues(client, DocumentFormatWiki, tickets)
+
+	if len(requestSizes) != 3 || requestSizes[0] != bulkCreateLimit || requestSizes[2] != 1 {
+		t.Errorf("createIssues() sent requests of %v issues, want chunks of up to %d", requestSizes, bulkCreateLimit)
+	}
+	if len(issues) != len(tickets) || len(errs) != len(tickets) {
+		t.Fatalf("createIssues() returned %d issues and %d errors, want %d", len(issues), len(errs), len(tickets))
+	}
+	for i := range tickets {
+		if errs[i] != nil || issues[i] == nil {
+			t.Errorf("createIssues() element %v = %v, %v, want a created issue", i, issues[i], errs[i])
+		}
+	}
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jira
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/openshift/compliance-audit-router/pkg/audit"
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/logging"
+	"github.com/openshift/compliance-audit-router/pkg/metrics"
+)
+
+const (
+	// Where the change record of an automatically approved issue was referenced
+	changeSourceAlert   = "alert"
+	changeSourceComment = "comment"
+
+	// maxChangeRecords is the number of change records referenced by a text that are looked up
+	maxChangeRecords = 5
+
+	changeApprovalComment = "This elevation has been approved automatically, as it was made for the approved change record %v."
+)
+
+// approvedChangeRecord returns the first change record referenced by the text that is in an approved status,
+// or an empty string when there is none or automatic approval is disabled. The records are looked up within the context.
+func approvedChangeRecord(ctx context.Context, text string) (string, error) {
+	changeConfig := config.AppConfig().ChangeConfig
+	if changeConfig.Pattern == "" {
+		return "", nil
+	}
+
+	// The pattern is checked when the configuration is loaded
+	pattern, err := regexp.Compile(changeConfig.Pattern)
+	if err != nil {
+		return "", fmt.Errorf("failed to compile the change record pattern: %w", err)
+	}
+	ids := changeRecordIDs(pattern, text)
+	if len(ids) == 0 {
+		return "", nil
+	}
+
+	trackerConfig, ok := config.AppConfig().JiraInstance(changeConfig.Jira)
+	if !ok {
+		return "", fmt.Errorf("unknown jira instance for change records: %s", changeConfig.Jira)
+	}
+	client, err := NewClientContext(ctx, trackerConfig)
+	if err != nil {
+		return "", fmt.Errorf("failed to create a client for the change records: %w", err)
+	}
+
+	for _, id := range ids {
+		record, resp, err := client.Issue.Get(id, &jira.GetQueryOptions{Fields: "status"})
+		if err != nil {
+			// References to records that don't exist are ignored, like any other text
+			if resp != nil && resp.StatusCode == http.StatusNotFound {
+				logging.Debugf(logging.Jira, "jira.approvedChangeRecord(): change record %v does not exist", id)
+				continue
+			}
+			return "", fmt.Errorf("failed to get change record %v: %w", id, err)
+		}
+		if record.Fields != nil && record.Fields.Status != nil && isApprovedStatus(record.Fields.Status.Name, changeConfig.ApprovedStatuses) {
+			return id, nil
+		}
+		logging.Debugf(logging.Jira, "jira.approvedChangeRecord(): change record %v is not approved", id)
+	}
+
+	return "", nil
+}
+
+// changeRecordIDs returns the distinct change record IDs matched by the pattern in the text, up to maxChangeRecords
+func changeRecordIDs(pattern *regexp.Regexp, text string) []string {
+	var ids []string
+	seen := map[string]bool{}
+	for _, id := range pattern.FindAllString(text, -1) {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+		if len(ids) == maxChangeRecords {
+			break
+		}
+	}
+	return ids
+}
+
+// isApprovedStatus reports whether the status is one of the approved statuses, case-insensitively
+func isApprovedStatus(status string, approvedStatuses []string) bool {
+	for _, approved := range approvedStatuses {
+		if strings.EqualFold(status, approved) {
+			return true
+		}
+	}
+	return false
+}
+
+// approveForChange comments on the issue that it was approved for the change record, and applies
+// the manager transition, on an issue in the sre transition status
+func approveForChange(client *jira.Client, jiraConfig config.JiraConfig, issue *jira.Issue, changeRecord string, source string) error {
+	if err := approve(client, jiraConfig, issue, fmt.Sprintf(changeApprovalComment, changeRecord)); err != nil {
+		return err
+	}
+
+	log.Printf("jira.approveForChange(): issue %v has been approved automatically for change record %v", issue.Key, changeRecord)
+	metrics.MetricJiraAutoApprovals.With(map[string]string{"source": source}).Inc()
+	audit.Write(audit.ActionAutoApproved, issue.Key, map[string]string{"changeRecord": changeRecord, "source": source})
+
+	return nil
+}
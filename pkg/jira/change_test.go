@@ -0,0 +1,76 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jira
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+)
+
+func TestApprovedChangeRecord(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/rest/api/2/issue/CHG-1":
+			_, _ = w.Write([]byte(`{"key":"CHG-1","fields":{"status":{"name":"Draft"}}}`))
+		case "/rest/api/2/issue/CHG-2":
+			_, _ = w.Write([]byte(`{"key":"CHG-2","fields":{"status":{"name":"Approved"}}}`))
+		case "/rest/api/2/issue/CHG-4":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	defer func() { config.SetAppConfig(config.Config{}) }()
+
+	tests := []struct {
+		name    string
+		pattern string
+		text    string
+		want    string
+		wantErr bool
+	}{
+		{"automatic approval disabled", "", "for CHG-2", "", false},
+		{"no reference", `CHG-[0-9]+`, "fixing the cluster", "", false},
+		{"approved record after unknown and unapproved records", `CHG-[0-9]+`, "for CHG-3, CHG-1 and CHG-2", "CHG-2", false},
+		{"unapproved record", `CHG-[0-9]+`, "for CHG-1", "", false},
+		{"failed lookup", `CHG-[0-9]+`, "for CHG-4", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.SetAppConfig(config.Config{
+				JiraConfig:   config.JiraConfig{Host: server.URL},
+				ChangeConfig: config.ChangeConfig{Pattern: tt.pattern, ApprovedStatuses: []string{"approved"}},
+			})
+
+			got, err := approvedChangeRecord(context.Background(), tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("approvedChangeRecord() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("approvedChangeRecord() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jira
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/metrics"
+)
+
+const (
+	incompleteLabelName = "incomplete"
+
+	// incompleteCommentPrefix starts the comment marking an issue for manual review, which is not
+	// mistaken for the initial comment when the outbox is reconciled
+	incompleteCommentPrefix = "Processing of this ticket by the compliance audit router is incomplete"
+	incompleteComment       = incompleteCommentPrefix + ": %s. Manual review needed; the router will retry the remaining steps."
+	repairedComment         = "The compliance audit router completed the remaining steps of this ticket."
+)
+
+// incomplete returns the label marking an issue whose initial comment or transitions failed for manual review
+func (l labelScheme) incomplete() string {
+	return l.name(incompleteLabelName)
+}
+
+// compensate marks a created issue whose initial comment or transition failed for manual review, with a comment
+// and the incomplete label, and schedules the repair of the remaining steps from the outbox. Failing to mark
+// the issue is not fatal; the repair is scheduled regardless.
+func compensate(client *jira.Client, jiraConfig config.JiraConfig, ticket preparedTicket, issue *jira.Issue, failedStep string, commented bool, transitioned int) {
+	if config.AppConfig().DryRun {
+		return
+	}
+	metrics.MetricJiraIncompleteTickets.Inc()
+
+	if config.AppConfig().DryRunUpdates() {
+		log.Printf("jira.compensate(): dry-run mode: would have labeled issue %v as incomplete", issue.Key)
+	} else if _, err := client.Issue.UpdateIssue(issue.ID, map[string]interface{}{
+		"update": map[string]interface{}{"labels": []map[string]string{{"add": labelsFor(jiraConfig).incomplete()}}},
+	}); err != nil {
+		log.Printf("jira.compensate(): failed to label issue %v as incomplete: %v\n", issue.Key, err)
+	}
+
+	if config.AppConfig().DryRunComments() {
+		log.Printf("jira.compensate(): dry-run mode: would have marked issue %v for manual review", issue.Key)
+	} else if err := addComment(client, jiraConfig.DocumentFormat, issue.ID, fmt.Sprintf(incompleteComment, failedStep)); err != nil {
+		log.Printf("jira.compensate(): failed to mark issue %v for manual review: %v\n", issue.Key, err)
+	}
+
+	repair := ticket.outbox
+	if repair == nil {
+		repair = scheduleRepair(jiraConfig, ticket, issue, commented, transitioned)
+	}
+	repair.update(func(entry *outboxEntry) { entry.Incomplete = true })
+	log.Printf("jira.compensate(): %v on issue %v; scheduled its repair", failedStep, issue.Key)
+}
+
+// completeRepair removes the incomplete label of a repaired issue and notes the repair. Failing to do so is not fatal;
+// the label can be removed manually.
+func completeRepair(client *jira.Client, jiraConfig config.JiraConfig, issueID string, issueKey string) {
+	if config.AppConfig().DryRunUpdates() {
+		log.Printf("jira.completeRepair(): dry-run mode: would have removed the incomplete label of issue %v", issueKey)
+	} else if _, err := client.Issue.UpdateIssue(issueID, map[string]interface{}{
+		"update": map[string]interface{}{"labels": []map[string]string{{"remove": labelsFor(jiraConfig).incomplete()}}},
+	}); err != nil {
+		log.Printf("jira.completeRepair(): failed to remove the incomplete label of issue %v: %v\n", issueKey, err)
+	}
+	if config.AppConfig().DryRunComments() {
+		return
+	}
+	if err := addComment(client, jiraConfig.DocumentFormat, issueID, repairedComment); err != nil {
+		log.Printf("jira.completeRepair(): failed to note the repair of issue %v: %v\n", issueKey, err)
+	}
+}
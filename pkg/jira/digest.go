@@ -0,0 +1,98 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jira
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/openshift/compliance-audit-router/pkg/audit"
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/splunk"
+)
+
+// digestTimeFormat is the format of the time in digest summaries
+const digestTimeFormat = "2006-01-02 15:04 MST"
+
+// CreateDigest creates a single ticket listing the compliance events of a low-risk alert collected over
+// the day until the given time. Digest tickets are not assigned and don't ask for justifications; they are labelled as
+// digests rather than managed by the router.
+func CreateDigest(client *jira.Client, jiraConfig config.JiraConfig, alertName string, until time.Time, events []splunk.AlertDetails) error {
+	issue := &jira.Issue{
+		Fields: &jira.IssueFields{
+			Type:       jira.IssueType{Name: jiraConfig.IssueType},
+			Project:    jira.Project{Key: jiraConfig.Key},
+			Summary:    fmt.Sprintf("Compliance Alert digest: %s until %s", alertName, until.UTC().Format(digestTimeFormat)),
+			Components: components(jiraConfig.Components),
+			Labels:     []string{labelsFor(jiraConfig).digest()},
+		},
+	}
+
+	if jiraConfig.SecurityLevel != "" {
+		setField(issue, "security", securityLevel(jiraConfig.SecurityLevel))
+	}
+	for key, value := range jiraConfig.Fields {
+		setField(issue, key, value)
+	}
+
+	description := digestDescription(alertName, events)
+
+	if config.AppConfig().DryRun {
+		log.Printf("jira.CreateDigest(): dry-run mode: would have created Jira digest ticket with the following fields and description: %+v, %v", issue, description)
+		return nil
+	}
+
+	created, err := createIssue(client, jiraConfig.DocumentFormat, issue, description)
+	if err != nil {
+		return fmt.Errorf("failed to create digest issue: %w", err)
+	}
+
+	log.Printf("jira.CreateDigest(): created digest issue with key %v for %d %s events", created.Key, len(events), alertName)
+	audit.Write(audit.ActionDigestCreated, created.Key, map[string]string{"alert": alertName, "events": strconv.Itoa(len(events))})
+	return nil
+}
+
+// digestDescription lists the events in a wiki markup table of their time, user, clusters, elevated commands and
+// reasons, which is sent as an ADF table to instances using ADF
+func digestDescription(alertName string, events []splunk.AlertDetails) string {
+	var s strings.Builder
+	fmt.Fprintf(&s, "%d compliance events were received for the alert %s. They are low-risk and don't need individual justifications.\n\n", len(events), alertName)
+	s.WriteString("||Time||User||Clusters||Commands||Reasons||\n")
+	for _, event := range events {
+		fmt.Fprintf(&s, "|%s|%s|%s|%s|%s|\n",
+			digestCell(event.Timestamp.UTC().Format(time.RFC3339)),
+			digestCell(event.User),
+			digestCell(strings.Join(event.ClusterIDs, ", ")),
+			digestCell(strings.Join(event.ElevatedSummary, ", ")),
+			digestCell(strings.Join(event.Reasons, ", ")),
+		)
+	}
+	return s.String()
+}
+
+// digestCell escapes the value for a table cell, which must be a single line without column separators
+func digestCell(value string) string {
+	value = strings.Join(strings.Fields(value), " ")
+	if value == "" {
+		return " "
+	}
+	return strings.ReplaceAll(value, "|", "\\|")
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jira
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/splunk"
+)
+
+func TestDigestDescription(t *testing.T) {
+	timestamp, _ := time.Parse(time.RFC3339, "2021-01-01T09:30:00Z")
+
+	got := digestDescription("read-only-elevation", []splunk.AlertDetails{
+		{
+			User:            "sre",
+			Timestamp:       timestamp,
+			ClusterIDs:      []string{"abc", "def"},
+			ElevatedSummary: []string{"get pods | grep foo", "get nodes"},
+			Reasons:         []string{"OHSS-1\ninvestigating"},
+		},
+		{User: "other", Timestamp: timestamp},
+	})
+
+	want := "2 compliance events were received for the alert read-only-elevation. They are low-risk and don't need individual justifications.\n\n" +
+		"||Time||User||Clusters||Commands||Reasons||\n" +
+		"|2021-01-01T09:30:00Z|sre|abc, def|get pods \\| grep foo, get nodes|OHSS-1 investigating|\n" +
+		"|2021-01-01T09:30:00Z|other| | | |\n"
+	if got != want {
+		t.Errorf("digestDescription() = %q, want %q", got, want)
+	}
+}
+
+func TestCreateDigestADF(t *testing.T) {
+	var created struct {
+		Fields struct {
+			Description adfDocument `json:"description"`
+		} `json:"fields"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodPost || r.URL.Path != "/rest/api/3/issue" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewDecoder(r.Body).Decode(&created)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":"10001","key":"CAR-1"}`))
+	}))
+	defer server.Close()
+
+	jiraConfig := config.JiraConfig{Host: server.URL, Key: "CAR", IssueType: "Task", DocumentFormat: DocumentFormatADF}
+	client, err := NewClient(jiraConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := []splunk.AlertDetails{{User: "sre", ElevatedSummary: []string{"get pods | grep foo"}}, {User: "other"}}
+	if err := CreateDigest(client, jiraConfig, "read-only-elevation", time.Now(), events); err != nil {
+		t.Fatalf("CreateDigest() error = %v", err)
+	}
+
+	// The events are listed in an ADF table rather than as wiki markup
+	content := created.Fields.Description.Content
+	if len(content) != 2 || content[1].Type != "table" {
+		t.Fatalf("CreateDigest() description = %+v, want a paragraph and a table", content)
+	}
+	rows := content[1].Content
+	if len(rows) != len(events)+1 {
+		t.Fatalf("CreateDigest() table has %d rows, want a header and a row per event", len(rows))
+	}
+	if header := rows[0].Content; len(header) != 5 || header[0].Type != "tableHeader" {
+		t.Errorf("CreateDigest() table header = %+v, want 5 header cells", header)
+	}
+	commands := rows[1].Content[3].Content[0].Content
+	if len(commands) != 1 || commands[0].Text != "get pods | grep foo" {
+		t.Errorf("CreateDigest() commands cell = %+v, want the unescaped commands", commands)
+	}
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jira
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/openshift/compliance-audit-router/pkg/config"
+)
+
+const (
+	epicIssueType      = "Epic"
+	monthlyEpicSummary = "Compliance Alerts %s"
+	monthlyEpicPeriod  = "2006-01"
+)
+
+var (
+	monthlyEpicsMutex sync.Mutex
+	// monthlyEpics caches the monthly epic keys by Jira host, project and period
+	monthlyEpics = map[string]string{}
+	// monthlyEpicLocks serialize finding or creating each monthly epic, so concurrent alerts don't create
+	// it twice, without blocking the alerts of other projects and periods on the Jira requests
+	monthlyEpicLocks = map[string]*sync.Mutex{}
+)
+
+// epicFor returns the key of the epic an issue for an alert at time t should be linked to,
+// or an empty string when no epic is configured
+func epicFor(client *jira.Client, jiraConfig config.JiraConfig, t time.Time) (string, error) {
+	if !jiraConfig.MonthlyEpic {
+		return jiraConfig.Epic, nil
+	}
+
+	if t.IsZero() {
+		t = time.Now()
+	}
+	summary := fmt.Sprintf(monthlyEpicSummary, t.UTC().Format(monthlyEpicPeriod))
+
+	if config.AppConfig().DryRunUpdates() {
+		log.Printf("jira.epicFor(): dry-run mode: would have found or created epic %q in project %v", summary, jiraConfig.Key)
+		return "", nil
+	}
+
+	cacheKey := jiraConfig.Host + "/" + jiraConfig.Key + "/" + summary
+	if key, ok := cachedMonthlyEpic(cacheKey); ok {
+		return key, nil
+	}
+
+	lock := monthlyEpicLock(cacheKey)
+	lock.Lock()
+	defer lock.Unlock()
+
+	// The epic may have been found or created while waiting for the lock
+	if key, ok := cachedMonthlyEpic(cacheKey); ok {
+		return key, nil
+	}
+
+	jql := fmt.Sprintf(`project = "%s" AND issuetype = "%s" AND summary ~ "\"%s\""`, jiraConfig.Key, epicIssueType, summary)
+	issues, _, err := client.Issue.Search(jql, &jira.SearchOptions{MaxResults: 10, Fields: []string{"summary"}})
+	if err != nil {
+		return "", fmt.Errorf("failed to search for epic %q: %w", summary, err)
+	}
+
+	// The summary search is a text match, so check for an exact match
+	for _, issue := range issues {
+		if issue.Fields != nil && issue.Fields.Summary == summary {
+			cacheMonthlyEpic(cacheKey, issue.Key)
+			return issue.Key, nil
+		}
+	}
+
+	epic := &jira.Issue{
+		Fields: &jira.IssueFields{
+			Type:    jira.IssueType{Name: epicIssueType},
+			Project: jira.Project{Key: jiraConfig.Key},
+			Summary: summary,
+		},
+	}
+	if jiraConfig.EpicNameField != "" {
+		setField(epic, jiraConfig.EpicNameField, summary)
+	}
+	if jiraConfig.SecurityLevel != "" {
+		setField(epic, "security", securityLevel(jiraConfig.SecurityLevel))
+	}
+
+	created, _, err := client.Issue.Create(epic)
+	if err != nil {
+		return "", fmt.Errorf("failed to create epic %q: %w", summary, err)
+	}
+
+	log.Printf("jira.epicFor(): created epic %v for %q", created.Key, summary)
+	cacheMonthlyEpic(cacheKey, created.Key)
+
+	return created.Key, nil
+}
+
+// cachedMonthlyEpic returns the cached key of the monthly epic
+func cachedMonthlyEpic(cacheKey string) (string, bool) {
+	monthlyEpicsMutex.Lock()
+	defer monthlyEpicsMutex.Unlock()
+
+	key, ok := monthlyEpics[cacheKey]
+	return key, ok
+}
+
+// cacheMonthlyEpic caches the key of the monthly epic
+func cacheMonthlyEpic(cacheKey, key string) {
+	monthlyEpicsMutex.Lock()
+	defer monthlyEpicsMutex.Unlock()
+
+	monthlyEpics[cacheKey] = key
+}
+
+// monthlyEpicLock returns the lock held while finding or creating the monthly epic
+func monthlyEpicLock(cacheKey string) *sync.Mutex {
+	monthlyEpicsMutex.Lock()
+	defer monthlyEpicsMutex.Unlock()
+
+	lock, ok := monthlyEpicLocks[cacheKey]
+	if !ok {
+		lock = &sync.Mutex{}
+		monthlyEpicLocks[cacheKey] = lock
+	}
+	return lock
+}
+
+// linkEpic links the issue to the epic, using the Epic Link custom field on
+// Jira Server and Data Center, or the parent field on Jira Cloud
+func linkEpic(issue *jira.Issue, jiraConfig config.JiraConfig, epicKey string) {
+	if jiraConfig.EpicLinkField != "" {
+		setField(issue, jiraConfig.EpicLinkField, epicKey)
+		return
+	}
+	issue.Fields.Parent = &jira.Parent{Key: epicKey}
+}
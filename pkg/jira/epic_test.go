@@ -0,0 +1,120 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jira
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+)
+
+func TestEpicFor(t *testing.T) {
+	defer func() { monthlyEpics, monthlyEpicLocks = map[string]string{}, map[string]*sync.Mutex{} }()
+	monthlyEpics, monthlyEpicLocks = map[string]string{}, map[string]*sync.Mutex{}
+
+	var mu sync.Mutex
+	var searches int
+	var created []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/rest/api/2/search":
+			searches++
+			// Only the January epic exists, next to an issue whose summary only contains the epic's
+			if strings.Contains(r.URL.Query().Get("jql"), "2024-01") {
+				_, _ = w.Write([]byte(`{"total":2,"issues":[{"key":"CAR-9","fields":{"summary":"Compliance Alerts 2024-01 review"}},` +
+					`{"key":"CAR-1","fields":{"summary":"Compliance Alerts 2024-01"}}]}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"total":0,"issues":[]}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/rest/api/2/issue":
+			var issue struct {
+				Fields map[string]interface{} `json:"fields"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&issue)
+			created = append(created, issue.Fields)
+			w.WriteHeader(http.StatusCreated)
+			_, _ = fmt.Fprintf(w, `{"id":"%d","key":"CAR-%d"}`, 100+len(created), 100+len(created))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	jiraConfig := config.JiraConfig{Host: server.URL, Key: "CAR", MonthlyEpic: true, EpicNameField: "customfield_10011", SecurityLevel: "Compliance"}
+	client, err := NewClient(jiraConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	month := func(m time.Month) time.Time { return time.Date(2024, m, 15, 12, 0, 0, 0, time.UTC) }
+
+	// The existing epic is found by its exact summary
+	if key, err := epicFor(client, jiraConfig, month(time.January)); err != nil || key != "CAR-1" {
+		t.Errorf("epicFor() = %v, %v for an existing epic, want CAR-1", key, err)
+	}
+	if len(created) != 0 {
+		t.Errorf("epicFor() created %v for an existing epic", created)
+	}
+
+	// A missing epic is created, with its Epic Name for Jira Server and the security level of the issues
+	// linked to it, as their summaries are listed on the epic
+	if key, err := epicFor(client, jiraConfig, month(time.February)); err != nil || key != "CAR-101" {
+		t.Errorf("epicFor() = %v, %v for a new epic, want CAR-101", key, err)
+	}
+	if len(created) != 1 || created[0]["summary"] != "Compliance Alerts 2024-02" || created[0]["customfield_10011"] != "Compliance Alerts 2024-02" {
+		t.Fatalf("epicFor() created %v, want the February epic with its Epic Name", created)
+	}
+	if security, _ := created[0]["security"].(map[string]interface{}); security["name"] != "Compliance" {
+		t.Errorf("epicFor() created the epic with security %v, want the Compliance level", created[0]["security"])
+	}
+
+	// Found and created epics are cached
+	searches = 0
+	for _, m := range []time.Month{time.January, time.February} {
+		if _, err := epicFor(client, jiraConfig, month(m)); err != nil {
+			t.Errorf("epicFor() error = %v for a cached epic", err)
+		}
+	}
+	if searches != 0 || len(created) != 1 {
+		t.Errorf("epicFor() searched %d times and created %d epics for cached epics, want none", searches, len(created)-1)
+	}
+
+	// Concurrent alerts create the epic once, even before Jira's search finds it
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if key, err := epicFor(client, jiraConfig, month(time.March)); err != nil || key != "CAR-102" {
+				t.Errorf("epicFor() = %v, %v for a concurrently created epic, want CAR-102", key, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if len(created) != 2 {
+		t.Errorf("epicFor() created %d epics, want the March epic once", len(created)-1)
+	}
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jira
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/openshift/compliance-audit-router/pkg/config"
+)
+
+func TestApprovingManager(t *testing.T) {
+	// Users with a Jira account, by username
+	accounts := map[string]string{"sre": "sre-id", "director": "director-id", "vp": "vp-id"}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if id, ok := accounts[r.URL.Query().Get("query")]; ok {
+			_, _ = fmt.Fprintf(w, `[{"accountId":%q}]`, id)
+			return
+		}
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(config.JiraConfig{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sreUser := &jira.User{AccountID: "sre-id"}
+
+	tests := []struct {
+		name       string
+		manager    string
+		escalation []string
+		want       string
+	}{
+		{"direct manager", "director", []string{"vp"}, "director-id"},
+		{"manager without a Jira account escalates", "manager", []string{"director", "vp"}, "director-id"},
+		{"manager who is the SRE escalates", "sre", []string{"vp"}, "vp-id"},
+		{"manager who is the SRE without escalation", "sre", nil, "sre-id"},
+		{"no manager with a Jira account", "manager", []string{"nobody"}, unknownUser},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := approvingManager(client, config.JiraConfig{Host: server.URL}, sreUser, tt.manager, tt.escalation); got.AccountID != tt.want {
+				t.Errorf("approvingManager() = %v, want %v", got.AccountID, tt.want)
+			}
+		})
+	}
+}
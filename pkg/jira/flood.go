@@ -0,0 +1,65 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jira
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/openshift/compliance-audit-router/pkg/audit"
+	"github.com/openshift/compliance-audit-router/pkg/config"
+)
+
+// ErrNoOpenTicket is returned by AppendToRecentTicket when the user has no open ticket to append the event to
+var ErrNoOpenTicket = errors.New("the user has no open ticket")
+
+// AppendToRecentTicket adds the compliance event of the ticket as a comment on the user's most recently created
+// open ticket in the project, instead of creating a ticket of its own, and returns the key of the issue.
+func AppendToRecentTicket(client *jira.Client, jiraConfig config.JiraConfig, ticket Ticket) (string, error) {
+	sreUser, err := getUserByName(client, jiraConfig, ticket.User)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch SRE's Jira account: %w", err)
+	}
+
+	jql := fmt.Sprintf(`project = "%s" AND labels = "%s" AND statusCategory != Done ORDER BY created DESC`,
+		jiraConfig.Key, labelsFor(jiraConfig).sre(sreUser.AccountID))
+	issues, _, err := client.Issue.Search(jql, &jira.SearchOptions{MaxResults: 1, Fields: []string{"summary"}})
+	if err != nil {
+		return "", fmt.Errorf("failed to search for the user's open tickets: %w", err)
+	}
+	if len(issues) == 0 {
+		return "", ErrNoOpenTicket
+	}
+	issue := issues[0]
+
+	comment := fmt.Sprintf("Another compliance event was received for %s, who is over the limit of %d tickets per %v. "+
+		"It is added to this ticket rather than a ticket of its own:\n\n%s",
+		ticket.User, config.AppConfig().FloodConfig.MaxTickets, config.AppConfig().FloodConfig.Window, ticket.Description)
+	if config.AppConfig().DryRunComments() {
+		log.Printf("jira.AppendToRecentTicket(): dry-run mode: would have added comment to Jira ticket %v with the following body: %v", issue.Key, comment)
+	} else if err := addComment(client, jiraConfig.DocumentFormat, issue.ID, comment); err != nil {
+		return "", fmt.Errorf("failed to add the event to issue %v: %w", issue.Key, err)
+	}
+
+	audit.Write(audit.ActionEventAppended, issue.Key, map[string]string{
+		"alert": ticket.Alert.AlertName,
+		"user":  ticket.User,
+	})
+	return issue.Key, nil
+}
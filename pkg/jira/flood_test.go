@@ -0,0 +1,72 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jira
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+)
+
+func TestAppendToRecentTicket(t *testing.T) {
+	var comment string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/rest/api/2/user/search":
+			_, _ = fmt.Fprintf(w, `[{"accountId":%q}]`, r.URL.Query().Get("query")+"-id")
+		case "/rest/api/2/search":
+			if strings.Contains(r.URL.Query().Get("jql"), `labels = "compliance-audit-router/sre:automation-id"`) {
+				_, _ = w.Write([]byte(`{"issues":[{"id":"10001","key":"CAR-1"}]}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"issues":[]}`))
+		case "/rest/api/2/issue/10001/comment":
+			body, _ := io.ReadAll(r.Body)
+			comment = string(body)
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient(config.JiraConfig{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jiraConfig := config.JiraConfig{Key: "CAR"}
+
+	key, err := AppendToRecentTicket(client, jiraConfig, Ticket{User: "automation", Description: "automation - TestAlert"})
+	if err != nil || key != "CAR-1" {
+		t.Fatalf("AppendToRecentTicket() = %v, %v, want CAR-1", key, err)
+	}
+	if !strings.Contains(comment, "automation - TestAlert") {
+		t.Errorf("AppendToRecentTicket() comment = %v, missing the event", comment)
+	}
+
+	if _, err := AppendToRecentTicket(client, jiraConfig, Ticket{User: "sre"}); !errors.Is(err, ErrNoOpenTicket) {
+		t.Errorf("AppendToRecentTicket() error = %v, want %v", err, ErrNoOpenTicket)
+	}
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jira
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/splunk"
+)
+
+const (
+	defaultIncidentWindow   = 24 * time.Hour
+	defaultIncidentLinkType = "Relates"
+	// maxIncidents is the number of incidents an issue is linked to
+	maxIncidents = 5
+	// jqlTimeFormat is the format of dates and times in JQL
+	jqlTimeFormat = "2006/01/02 15:04"
+)
+
+// incidentsFor searches the incident projects for open incidents naming one of the alert's clusters
+// that were active within the incident window around the alert, most recently updated first
+func incidentsFor(client *jira.Client, jiraConfig config.JiraConfig, alert splunk.AlertDetails) ([]jira.Issue, error) {
+	jql := incidentJQL(jiraConfig, alert)
+	if jql == "" {
+		return nil, nil
+	}
+
+	issues, _, err := client.Issue.Search(jql, &jira.SearchOptions{MaxResults: maxIncidents, Fields: []string{"summary"}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search for incidents: %w", err)
+	}
+	return issues, nil
+}
+
+// incidentJQL returns the JQL search for the incidents of the alert, or an empty string when
+// no incident projects are configured or the alert names no clusters
+func incidentJQL(jiraConfig config.JiraConfig, alert splunk.AlertDetails) string {
+	if len(jiraConfig.IncidentProjects) == 0 || len(alert.ClusterIDs) == 0 {
+		return ""
+	}
+
+	t := alert.Timestamp
+	if t.IsZero() {
+		t = time.Now()
+	}
+	window := jiraConfig.IncidentWindow
+	if window == 0 {
+		window = defaultIncidentWindow
+	}
+
+	projects := make([]string, 0, len(jiraConfig.IncidentProjects))
+	for _, project := range jiraConfig.IncidentProjects {
+		projects = append(projects, jqlString(project))
+	}
+	clusters := make([]string, 0, len(alert.ClusterIDs))
+	for _, id := range alert.ClusterIDs {
+		// Search for the ID as a phrase, so it isn't split into words. Quotes can't be searched for in a phrase.
+		clusters = append(clusters, "text ~ "+jqlString(`"`+strings.ReplaceAll(id, `"`, "")+`"`))
+	}
+
+	jql := fmt.Sprintf(`project in (%s) AND statusCategory != Done AND (%s) AND created <= "%s" AND updated >= "%s"`,
+		strings.Join(projects, ", "), strings.Join(clusters, " OR "),
+		t.Add(window).UTC().Format(jqlTimeFormat), t.Add(-window).UTC().Format(jqlTimeFormat))
+	if jiraConfig.IncidentJQL != "" {
+		jql += " AND (" + jiraConfig.IncidentJQL + ")"
+	}
+	return jql + " ORDER BY updated DESC"
+}
+
+// jqlString quotes the value as a JQL string
+func jqlString(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+// incidentNote describes the incidents in the issue description, for the reviewers
+func incidentNote(incidents []jira.Issue) string {
+	var note strings.Builder
+	note.WriteString("\n\nOpen incidents on the cluster at the time of the alert:\n")
+	for _, incident := range incidents {
+		summary := ""
+		if incident.Fields != nil {
+			summary = incident.Fields.Summary
+		}
+		note.WriteString(fmt.Sprintf("%s: %s\n", incident.Key, summary))
+	}
+	return note.String()
+}
+
+// linkIncidents links the new issue to the incidents. Failing to do so is not fatal;
+// the incidents are also noted in the issue description.
+func linkIncidents(client *jira.Client, jiraConfig config.JiraConfig, createdIssue *jira.Issue, incidents []jira.Issue) {
+	linkType := jiraConfig.IncidentLinkType
+	if linkType == "" {
+		linkType = defaultIncidentLinkType
+	}
+
+	for _, incident := range incidents {
+		if config.AppConfig().DryRunUpdates() {
+			log.Printf("jira.CreateTicket(): dry-run mode: would have linked issue %v to incident %v", createdIssue.Key, incident.Key)
+			continue
+		}
+
+		link := &jira.IssueLink{
+			Type:         jira.IssueLinkType{Name: linkType},
+			InwardIssue:  &jira.Issue{Key: createdIssue.Key},
+			OutwardIssue: &jira.Issue{Key: incident.Key},
+		}
+		if _, err := client.Issue.AddLink(link); err != nil {
+			log.Printf("jira.CreateTicket(): failed to link issue %v to incident %v: %v\n", createdIssue.Key, incident.Key, err)
+		}
+	}
+}
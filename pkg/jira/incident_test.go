@@ -0,0 +1,99 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jira
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/splunk"
+)
+
+func TestIncidentJQL(t *testing.T) {
+	alert := splunk.AlertDetails{
+		Timestamp:  time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC),
+		ClusterIDs: []string{"abc-123", `d"ef`},
+	}
+
+	tests := []struct {
+		name       string
+		jiraConfig config.JiraConfig
+		alert      splunk.AlertDetails
+		want       string
+	}{
+		{
+			name:       "no incident projects",
+			jiraConfig: config.JiraConfig{},
+			alert:      alert,
+			want:       "",
+		},
+		{
+			name:       "no clusters",
+			jiraConfig: config.JiraConfig{IncidentProjects: []string{"OHSS"}},
+			alert:      splunk.AlertDetails{Timestamp: alert.Timestamp},
+			want:       "",
+		},
+		{
+			name:       "incidents around the alert",
+			jiraConfig: config.JiraConfig{IncidentProjects: []string{"OHSS", "INC"}, IncidentWindow: 2 * time.Hour, IncidentJQL: "issuetype = Incident"},
+			alert:      alert,
+			want: `project in ("OHSS", "INC") AND statusCategory != Done AND (text ~ "\"abc-123\"" OR text ~ "\"def\"") ` +
+				`AND created <= "2024/03/01 14:30" AND updated >= "2024/03/01 10:30" AND (issuetype = Incident) ORDER BY updated DESC`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := incidentJQL(tt.jiraConfig, tt.alert); got != tt.want {
+				t.Errorf("incidentJQL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIncidentsFor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path != "/rest/api/2/search" || !strings.Contains(r.URL.Query().Get("jql"), `text ~ "\"abc-123\""`) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"issues":[{"key":"OHSS-1","fields":{"summary":"API down on abc-123"}}]}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(config.JiraConfig{Host: server.URL})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	incidents, err := incidentsFor(client, config.JiraConfig{IncidentProjects: []string{"OHSS"}}, splunk.AlertDetails{ClusterIDs: []string{"abc-123"}})
+	if err != nil {
+		t.Fatalf("incidentsFor() error = %v", err)
+	}
+	if len(incidents) != 1 || incidents[0].Key != "OHSS-1" {
+		t.Fatalf("incidentsFor() = %+v, want OHSS-1", incidents)
+	}
+
+	if note := incidentNote(incidents); !strings.Contains(note, "OHSS-1: API down on abc-123") {
+		t.Errorf("incidentNote() = %v, missing the incident", note)
+	}
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jira
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/openshift/compliance-audit-router/pkg/health"
+	"github.com/openshift/compliance-audit-router/pkg/metrics"
+)
+
+// Jira operations the request metrics are labelled with
+const (
+	operationCreate     = "create"
+	operationGet        = "get"
+	operationUpdate     = "update"
+	operationComment    = "comment"
+	operationTransition = "transition"
+	operationWatcher    = "watcher"
+	operationUserFind   = "user_find"
+	operationSearch     = "search"
+	operationLink       = "link"
+	operationOther      = "other"
+)
+
+// metricsTransport records the duration of each request sent to Jira through the wrapped transport,
+// and counts the requests that failed, by operation
+type metricsTransport struct {
+	transport http.RoundTripper
+}
+
+// RoundTrip implements the http.RoundTripper interface
+func (t *metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	op := operation(req)
+
+	start := time.Now()
+	resp, err := t.transport.RoundTrip(req)
+	metrics.MetricJiraRequestDuration.With(map[string]string{"operation": op}).Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		metrics.MetricJiraRequestErrors.With(map[string]string{"operation": op, "status_class": "error"}).Inc()
+	} else if resp.StatusCode >= http.StatusBadRequest {
+		metrics.MetricJiraRequestErrors.With(map[string]string{"operation": op, "status_class": fmt.Sprintf("%dxx", resp.StatusCode/100)}).Inc()
+	}
+
+	// Requests cancelled by the caller tell nothing of Jira's health; client errors mean it is reachable
+	switch {
+	case err != nil && req.Context().Err() == nil:
+		health.Record(health.Jira, err)
+	case err == nil && resp.StatusCode >= http.StatusInternalServerError:
+		health.Record(health.Jira, fmt.Errorf("%s request to %s failed: %s", op, req.URL.Host, resp.Status))
+	case err == nil:
+		health.Record(health.Jira, nil)
+	}
+	return resp, err
+}
+
+// operation names the Jira operation of a REST API request from its method and path,
+// eg. "comment" for POST rest/api/2/issue/{id}/comment
+func operation(req *http.Request) string {
+	path := req.URL.Path
+	// Request participants are the watchers of Jira Service Management requests
+	if strings.Contains(path, "/rest/servicedeskapi/request/") && strings.HasSuffix(path, "/participant") {
+		return operationWatcher
+	}
+	i := strings.Index(path, "/rest/api/")
+	if i < 0 {
+		return operationOther
+	}
+	// The first part is the API version
+	parts := strings.Split(strings.Trim(path[i+len("/rest/api/"):], "/"), "/")
+	if len(parts) < 2 {
+		return operationOther
+	}
+
+	switch parts[1] {
+	case "issue":
+		switch {
+		case len(parts) == 2 || parts[2] == "bulk":
+			return operationCreate
+		case len(parts) == 3 && req.Method == http.MethodGet:
+			return operationGet
+		case len(parts) == 3:
+			return operationUpdate
+		case parts[3] == "comment":
+			return operationComment
+		case parts[3] == "transitions":
+			return operationTransition
+		case parts[3] == "watchers":
+			return operationWatcher
+		}
+	case "user", "myself":
+		return operationUserFind
+	case "search":
+		return operationSearch
+	case "issueLink":
+		return operationLink
+	}
+	return operationOther
+}
+
+// instrumented records the metrics of the requests sent by the client
+func instrumented(client *http.Client) *http.Client {
+	transport := client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	client.Transport = &metricsTransport{transport: transport}
+	return client
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jira
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openshift/compliance-audit-router/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestOperation(t *testing.T) {
+	tests := []struct {
+		method string
+		target string
+		want   string
+	}{
+		{http.MethodPost, "https://jira.example.com/rest/api/2/issue", operationCreate},
+		{http.MethodPost, "https://jira.example.com/rest/api/3/issue/bulk", operationCreate},
+		{http.MethodGet, "https://jira.example.com/rest/api/2/issue/10001", operationGet},
+		{http.MethodPut, "https://jira.example.com/rest/api/2/issue/10001", operationUpdate},
+		{http.MethodPost, "https://jira.example.com/jira/rest/api/2/issue/10001/comment", operationComment},
+		{http.MethodGet, "https://jira.example.com/rest/api/2/issue/10001/transitions", operationTransition},
+		{http.MethodPost, "https://jira.example.com/rest/api/2/issue/10001/watchers", operationWatcher},
+		{http.MethodPost, "https://jira.example.com/rest/servicedeskapi/request/CAR-1/participant", operationWatcher},
+		{http.MethodGet, "https://jira.example.com/rest/api/2/user/search?username=sre", operationUserFind},
+		{http.MethodGet, "https://jira.example.com/rest/api/2/myself", operationUserFind},
+		{http.MethodPost, "https://jira.example.com/rest/api/2/search", operationSearch},
+		{http.MethodPost, "https://jira.example.com/rest/api/2/issueLink", operationLink},
+		{http.MethodGet, "https://jira.example.com/rest/api/2/serverInfo", operationOther},
+		{http.MethodGet, "https://jira.example.com/rest/agile/1.0/sprint/1", operationOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.target, func(t *testing.T) {
+			if got := operation(httptest.NewRequest(tt.method, tt.target, nil)); got != tt.want {
+				t.Errorf("operation() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMetricsTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	failures := metrics.MetricJiraRequestErrors.With(map[string]string{"operation": operationComment, "status_class": "4xx"})
+	before := testutil.ToFloat64(failures)
+
+	resp, err := instrumented(&http.Client{}).Post(server.URL+"/rest/api/2/issue/10001/comment", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if got := testutil.ToFloat64(failures) - before; got != 1 {
+		t.Errorf("failed comment requests counted = %v, want 1", got)
+	}
+}
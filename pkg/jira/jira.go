@@ -18,162 +18,515 @@ package jira
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"html/template"
 	"log"
 	"net/http"
-	"strings"
+	"strconv"
 
 	"github.com/andygrunwald/go-jira"
+	"github.com/openshift/compliance-audit-router/pkg/audit"
 	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/helpers"
+	"github.com/openshift/compliance-audit-router/pkg/logging"
+	"github.com/openshift/compliance-audit-router/pkg/splunk"
 )
 
 const (
-	managedLabel    = "compliance-audit-router/managed"
-	sreLabelKey     = "compliance-audit-router/sre"
-	managerLabelKey = "compliance-audit-router/manager"
-	sreLabel        = sreLabelKey + ":%v"
-	managerLabel    = managerLabelKey + ":%v"
-	unknownUser     = "unknown"
+	unknownUser = "unknown"
 
 	initialTransitionKey = "initial"
 	sreTransitionKey     = "sre"
 	managerTransitionKey = "manager"
 
+	// ticketSummary is used when the summary template can't be rendered
 	ticketSummary = "Compliance Alert: SRE Cluster Admin Elevation"
+
+	// Jira webhook events handled by the router
+	webhookEventIssueUpdated   = "jira:issue_updated"
+	webhookEventCommentCreated = "comment_created"
 )
 
+// ErrMissingWebhookEvent is returned for Jira webhooks without a webhookEvent
+var ErrMissingWebhookEvent = errors.New("jira webhook is missing the webhookEvent field")
+
+// Ticket describes a compliance ticket to be created
+type Ticket struct {
+	User    string
+	Manager string
+	// Escalation are the managers above Manager, in order, assigned the manager's approval
+	// when Manager is the user or has no Jira account
+	Escalation  []string
+	Description string
+
+	// Alert is the compliance event the ticket is created for.
+	// It is empty for tickets tracking processing errors.
+	Alert splunk.AlertDetails
+
+	// MessageTemplate and SummaryTemplate override the configured templates when set, eg. with a tenant's
+	MessageTemplate string
+	SummaryTemplate string
+
+	// FastTrack moves the new issue on to the sre status after the initial one, awaiting the manager's
+	// approval, eg. for elevation explained by the incident the SRE was on call for
+	FastTrack bool
+
+	// ReadOnly resolves the new issue automatically, with an audit comment, as the elevated commands were all read-only
+	ReadOnly bool
+}
+
+// messageTemplate returns the template of the ticket's initial comment
+func (t Ticket) messageTemplate() string {
+	if t.MessageTemplate != "" {
+		return t.MessageTemplate
+	}
+	return config.AppConfig().MessageTemplateFor(t.Alert.AlertName)
+}
+
+// summaryTemplate returns the template of the ticket's summary
+func (t Ticket) summaryTemplate() string {
+	if t.SummaryTemplate != "" {
+		return t.SummaryTemplate
+	}
+	return config.AppConfig().SummaryTemplate
+}
+
+// TemplateData is the data available to the message template
+type TemplateData struct {
+	// Username is a Jira mention of the assigned SRE
+	Username string
+	// IssueKey is the key of the created Jira issue
+	IssueKey string
+	Alert    splunk.AlertDetails
+}
+
+// Webhook is the JSON structure for a Jira webhook
 type Webhook struct {
-	Issue   jira.Issue
-	Comment jira.Comment
+	WebhookEvent string `json:"webhookEvent"`
+	// User is the user whose action triggered the webhook
+	User      jira.User        `json:"user"`
+	Issue     jira.Issue       `json:"issue"`
+	Comment   jira.Comment     `json:"comment"`
+	Changelog WebhookChangelog `json:"changelog"`
+}
+
+// WebhookChangelog lists the fields changed by the issue update that triggered the webhook
+type WebhookChangelog struct {
+	ID    string                `json:"id"`
+	Items []jira.ChangelogItems `json:"items"`
+}
+
+// Ignored checks whether the webhook can be ignored without fetching the issue from Jira,
+// returning the reason it can be ignored. Webhooks for events the router doesn't handle, or
+// for issues the router doesn't manage, are ignored. Comment events don't include the
+// issue labels, so they are checked against the fetched issue in HandleUpdate instead.
+func (w Webhook) Ignored(jiraConfig config.JiraConfig) (string, bool, error) {
+	switch w.WebhookEvent {
+	case "":
+		return "", false, ErrMissingWebhookEvent
+	case webhookEventIssueUpdated:
+		if w.Issue.Fields != nil && !labelsFor(jiraConfig).isManaged(w.Issue.Fields.Labels) {
+			return "unmanaged_issue", true, nil
+		}
+		return "", false, nil
+	case webhookEventCommentCreated:
+		return "", false, nil
+	default:
+		return "unhandled_event", true, nil
+	}
+}
+
+// StatusChange returns the previous and new status when the webhook was triggered by a status change
+func (w Webhook) StatusChange() (string, string, bool) {
+	for _, item := range w.Changelog.Items {
+		if item.Field == "status" {
+			return item.FromString, item.ToString, true
+		}
+	}
+	return "", "", false
 }
 
+// DefaultClient returns a client for the default Jira instance
 func DefaultClient() (*jira.Client, error) {
+	return NewClient(config.AppConfig().JiraConfig)
+}
+
+// NewClient returns a client for the given Jira instance
+func NewClient(jiraConfig config.JiraConfig) (*jira.Client, error) {
+	return NewClientContext(context.Background(), jiraConfig)
+}
+
+// NewClientContext returns a client for the given Jira instance whose requests, including the
+// time waiting for the rate limiter, are cancelled with the context, and fail right away while
+// the instance's circuit breaker is open
+func NewClientContext(ctx context.Context, jiraConfig config.JiraConfig) (*jira.Client, error) {
+	// The clients of all the instances reuse the pooled connections of the shared transport
+	shared := helpers.SharedTransport(jiraConfig.AllowInsecure)
+
 	var transportClient *http.Client
-	if config.AppConfig.JiraConfig.Username != "" {
-		log.Printf("jira.DefaultClient(): WARNING: Using basic auth for Jira client development\n")
-		transportClient = basicAuthClient(config.AppConfig.JiraConfig.Username, config.AppConfig.JiraConfig.Token)
+	if jiraConfig.Username != "" {
+		log.Printf("jira.NewClient(): WARNING: Using basic auth for Jira client development\n")
+		transportClient = basicAuthClient(jiraConfig.Username, jiraConfig.Token, shared)
 	} else {
-		transportClient = patAuthClient(config.AppConfig.JiraConfig.Token)
+		transportClient = patAuthClient(jiraConfig.Token, shared)
 	}
 
-	return jira.NewClient(transportClient, config.AppConfig.JiraConfig.Host)
+	return jira.NewClient(withContext(ctx, rateLimited(withBreaker(instrumented(transportClient), jiraConfig), jiraConfig)), jiraConfig.Host)
 }
 
-func CreateTicket(userService *jira.UserService, issueService *jira.IssueService, user string, manager string, description string) error {
+// preparedTicket is a ticket with its Jira users resolved and issue fields built, ready to be created
+type preparedTicket struct {
+	Ticket
+	issue       *jira.Issue
+	sreUser     *jira.User
+	managerUser *jira.User
+	// incidents are the open incidents on the alert's clusters the issue is linked to
+	incidents []jira.Issue
+	// changeRecord is the approved change record referenced by the alert the issue is approved for
+	changeRecord string
+	// outbox is the outbox entry recording the ticket's Jira actions, nil when the outbox is disabled
+	outbox *outboxTicket
+}
 
-	if config.AppConfig.DryRun {
-		log.Printf("jira.CreateTicket(): dry-run mode: would have created Jira ticket with user, manager, description: %+v, %+v, %+v", user, manager, description)
-		if config.AppConfig.Verbose {
-			log.Printf("jira.CreateTicket(): dry-run mode: *jira.UserService: %+v", userService)
-			log.Printf("jira.CreateTicket(): dry-run mode: *jira.issueService: %+v", issueService)
+func CreateTicket(ctx context.Context, client *jira.Client, jiraConfig config.JiraConfig, ticket Ticket) error {
+	prepared, err := prepareTicket(ctx, client, jiraConfig, ticket)
+	if err != nil {
+		return err
+	}
+
+	var createdIssue *jira.Issue
+	if config.AppConfig().DryRun {
+		log.Printf("jira.CreateTicket(): dry-run mode: would have created Jira ticket with the following fields and description: %+v, %v", prepared.issue, prepared.Description)
+		createdIssue = &jira.Issue{}
+		createdIssue.Key = "DRY-RUN-0000"
+		err = nil
+	} else {
+		if resumed, err := resumeOutbox(client, jiraConfig, ticket); resumed {
+			return err
+		}
+		if err := recordOutbox(jiraConfig, &prepared); err != nil {
+			return err
+		}
+		defer prepared.outbox.release()
+		createdIssue, err = createIssue(client, jiraConfig.DocumentFormat, prepared.issue, prepared.Description)
+	}
+
+	if err != nil {
+		// Unless Jira rejected the issue, it may have been created anyway, eg. on a timeout, so the entry is
+		// kept for the reconciliation to find the issue by its label, or create it
+		if rejected(err) {
+			prepared.outbox.finish()
+		}
+		return fmt.Errorf("failed to create issue: %w", err)
+	}
+
+	log.Printf("jira.CreateTicket(): created new issue with key %v", createdIssue.Key)
+	prepared.outbox.created(createdIssue)
+
+	return finishTicket(client, jiraConfig, prepared, createdIssue)
+}
+
+// CreateTickets creates the tickets with a single call to the Jira bulk create API, then
+// comments on and transitions each created issue. The returned errors are in the same
+// order as the tickets, with a nil error for each ticket that was successfully created.
+func CreateTickets(ctx context.Context, client *jira.Client, jiraConfig config.JiraConfig, tickets []Ticket) []error {
+	ticketErrors := make([]error, len(tickets))
+
+	var prepared []preparedTicket
+	var preparedIndexes []int
+	for i, ticket := range tickets {
+		p, err := prepareTicket(ctx, client, jiraConfig, ticket)
+		if err != nil {
+			ticketErrors[i] = err
+			continue
 		}
+		prepared = append(prepared, p)
+		preparedIndexes = append(preparedIndexes, i)
 	}
 
-	reporterUser, _, err := userService.GetSelf()
+	if len(prepared) == 0 {
+		return ticketErrors
+	}
+
+	var createdIssues []*jira.Issue
+	var createErrors []error
+	if config.AppConfig().DryRun {
+		log.Printf("jira.CreateTickets(): dry-run mode: would have bulk created %v Jira tickets", len(prepared))
+		for i := range prepared {
+			createdIssues = append(createdIssues, &jira.Issue{Key: fmt.Sprintf("DRY-RUN-%04d", i)})
+			createErrors = append(createErrors, nil)
+		}
+	} else {
+		var recorded []preparedTicket
+		var recordedIndexes []int
+		for n := range prepared {
+			if resumed, err := resumeOutbox(client, jiraConfig, prepared[n].Ticket); resumed {
+				ticketErrors[preparedIndexes[n]] = err
+				continue
+			}
+			if err := recordOutbox(jiraConfig, &prepared[n]); err != nil {
+				ticketErrors[preparedIndexes[n]] = err
+				continue
+			}
+			defer prepared[n].outbox.release()
+			recorded = append(recorded, prepared[n])
+			recordedIndexes = append(recordedIndexes, preparedIndexes[n])
+		}
+		prepared, preparedIndexes = recorded, recordedIndexes
+		if len(prepared) == 0 {
+			return ticketErrors
+		}
+		createdIssues, createErrors = createIssues(client, jiraConfig.DocumentFormat, prepared)
+	}
+
+	for n, p := range prepared {
+		i := preparedIndexes[n]
+		if createErrors[n] != nil {
+			if rejected(createErrors[n]) {
+				p.outbox.finish()
+			}
+			ticketErrors[i] = fmt.Errorf("failed to create issue: %w", createErrors[n])
+			continue
+		}
+
+		log.Printf("jira.CreateTickets(): created new issue with key %v", createdIssues[n].Key)
+		p.outbox.created(createdIssues[n])
+		ticketErrors[i] = finishTicket(client, jiraConfig, p, createdIssues[n])
+	}
+
+	return ticketErrors
+}
+
+// prepareTicket looks up the Jira users for the ticket and builds the issue to be created. The change
+// records referenced by the alert are looked up on their own Jira instance within the context.
+func prepareTicket(ctx context.Context, client *jira.Client, jiraConfig config.JiraConfig, ticket Ticket) (preparedTicket, error) {
+	userService := client.User
+	issueService := client.Issue
+	user, manager, description := ticket.User, ticket.Manager, ticket.Description
+
+	if config.AppConfig().DryRun {
+		log.Printf("jira.CreateTicket(): dry-run mode: would have created Jira ticket with user, manager, description: %+v, %+v, %+v", user, manager, description)
+		logging.Debugf(logging.Jira, "jira.CreateTicket(): dry-run mode: *jira.UserService: %+v", userService)
+		logging.Debugf(logging.Jira, "jira.CreateTicket(): dry-run mode: *jira.issueService: %+v", issueService)
+	}
+
+	reporterUser, err := reporterFor(client, jiraConfig)
 	if err != nil {
-		return fmt.Errorf("failed to get Jira user for reporter: %w", err)
+		return preparedTicket{}, fmt.Errorf("failed to get Jira user for reporter: %w", err)
 	}
 
-	sreUser, err := getUserByName(userService, user)
+	sreUser, err := getUserByName(client, jiraConfig, user)
 	if err != nil {
 		log.Printf("jira.CreateTicket(): failed to fetch SRE's Jira account. The ticket will be created with no assignee and need to be managed manually: %v\n", err)
 		sreUser = &jira.User{AccountID: unknownUser}
 	}
 
-	managerUser, err := getUserByName(userService, manager)
-	if err != nil {
-		log.Printf("jira.CreateTicket(): failed to fetch manager's Jira account: %v\n", err)
-		managerUser = &jira.User{AccountID: unknownUser}
-	}
+	managerUser := approvingManager(client, jiraConfig, sreUser, manager, ticket.Escalation)
 
 	jiraIssue := &jira.Issue{
 		Fields: &jira.IssueFields{
-			Reporter:    reporterUser,
-			Description: description,
-			Type:        jira.IssueType{Name: config.AppConfig.JiraConfig.IssueType},
-			Project:     jira.Project{Key: config.AppConfig.JiraConfig.Key},
-			Summary:     ticketSummary,
+			Reporter:   reporterUser,
+			Type:       jira.IssueType{Name: jiraConfig.IssueType},
+			Project:    jira.Project{Key: jiraConfig.Key},
+			Summary:    summary(ticket.summaryTemplate(), ticket.Alert),
+			Components: components(jiraConfig.Components),
 		},
 	}
 
+	// Restrict the issue to the configured security level, as descriptions may contain sensitive command details
+	if jiraConfig.SecurityLevel != "" {
+		setField(jiraIssue, "security", securityLevel(jiraConfig.SecurityLevel))
+	}
+
+	// Set the additional fields, eg. the Jira Service Management request type that places the issue in a queue
+	for key, value := range jiraConfig.Fields {
+		setField(jiraIssue, key, value)
+	}
+
+	// Link the issue to the epic for the reporting period. Failing to do so is not fatal;
+	// the issue can be linked manually during the audit review.
+	epicKey, err := epicFor(client, jiraConfig, ticket.Alert.Timestamp)
+	if err != nil {
+		log.Printf("jira.CreateTicket(): failed to find the parent epic; the ticket will be created without one: %v\n", err)
+	} else if epicKey != "" {
+		linkEpic(jiraIssue, jiraConfig, epicKey)
+	}
+
+	// Note the open incidents on the alert's clusters, which likely explain the elevation, for the reviewers.
+	// Failing to search for them is not fatal; the reviewers can search for incidents themselves.
+	incidents, err := incidentsFor(client, jiraConfig, ticket.Alert)
+	if err != nil {
+		log.Printf("jira.CreateTicket(): failed to find incidents on the alert's clusters; the ticket will be created without them: %v\n", err)
+	} else if len(incidents) > 0 {
+		ticket.Description += incidentNote(incidents)
+	}
+
 	if sreUser.AccountID != unknownUser {
 		jiraIssue.Fields.Assignee = sreUser
-		jiraIssue.Fields.Labels = []string{managedLabel, fmt.Sprintf(sreLabel, sreUser.AccountID), fmt.Sprintf(managerLabel, managerUser.AccountID)}
+		labels := labelsFor(jiraConfig)
+		jiraIssue.Fields.Labels = []string{labels.managed(), labels.sre(sreUser.AccountID), labels.manager(managerUser.AccountID)}
 	}
 
-	var createdIssue *jira.Issue
-	if config.AppConfig.DryRun {
-		log.Printf("jira.CreateTicket(): dry-run mode: would have created Jira ticket with the following fields: %+v", jiraIssue)
-		createdIssue = &jira.Issue{}
-		createdIssue.Key = "DRY-RUN-0000"
-		err = nil
-	} else {
-		createdIssue, _, err = issueService.Create(jiraIssue)
+	// Set the risk score of the elevated commands and the priority and label of its level, so reviewers
+	// can triage the riskiest elevations first
+	if len(config.AppConfig().RiskConfig.Rules) > 0 && len(ticket.Alert.ElevatedSummary) > 0 {
+		applyRisk(jiraIssue, jiraConfig, config.AppConfig().RiskConfig, ticket.Alert.RiskScore)
 	}
 
+	// Elevations for an approved change record referenced by the alert's reasons are approved automatically.
+	// Failing to look up the record is not fatal; the issue is reviewed as usual.
+	changeRecord, err := approvedChangeRecord(ctx, ticket.Alert.ReasonsText)
 	if err != nil {
-		return fmt.Errorf("failed to create issue: %w", err)
+		log.Printf("jira.CreateTicket(): failed to look up the change records referenced by the alert: %v\n", err)
 	}
 
-	log.Printf("jira.CreateTicket(): created new issue with key %v", createdIssue.Key)
+	return preparedTicket{Ticket: ticket, issue: jiraIssue, sreUser: sreUser, managerUser: managerUser, incidents: incidents, changeRecord: changeRecord}, nil
+}
 
-	messageTemplate, err := template.New("messageTemplate").Parse(config.AppConfig.MessageTemplate)
-	if err != nil {
-		if config.AppConfig.Verbose {
-			log.Printf("jira.CreateTicket(): failed to parse message template from AppConfig; template: %v\n", config.AppConfig.MessageTemplate)
+// applyRisk sets the risk score in the configured field, and the priority and label of the score's level
+func applyRisk(issue *jira.Issue, jiraConfig config.JiraConfig, riskConfig config.RiskConfig, score int) {
+	if riskConfig.Field != "" {
+		setField(issue, riskConfig.Field, score)
+	}
+
+	level, ok := riskConfig.LevelFor(score)
+	if !ok {
+		return
+	}
+	if level.Priority != "" {
+		issue.Fields.Priority = &jira.Priority{Name: level.Priority}
+	}
+	issue.Fields.Labels = append(issue.Fields.Labels, labelsFor(jiraConfig).risk(level.Name))
+}
+
+// finishTicket adds the manager as a watcher, leaves the initial comment and applies the
+// initial transition on a newly created issue
+func finishTicket(client *jira.Client, jiraConfig config.JiraConfig, ticket preparedTicket, createdIssue *jira.Issue) error {
+	issueService := client.Issue
+	sreUser, managerUser := ticket.sreUser, ticket.managerUser
+
+	audit.Write(audit.ActionTicketCreated, createdIssue.Key, map[string]string{
+		"alert":   ticket.Alert.AlertName,
+		"user":    ticket.User,
+		"sre":     sreUser.AccountID,
+		"manager": managerUser.AccountID,
+	})
+
+	// Add the manager as a watcher so they see activity before the workflow reaches them.
+	// Failing to do so is not fatal; the manager is still notified on transition.
+	if jiraConfig.WatchManager && managerUser.AccountID != unknownUser {
+		if config.AppConfig().DryRunUpdates() {
+			log.Printf("jira.CreateTicket(): dry-run mode: would have added manager %v as a watcher", managerUser.AccountID)
+		} else if _, err := issueService.AddWatcher(createdIssue.ID, watcherName(managerUser)); err != nil {
+			log.Printf("jira.CreateTicket(): failed to add manager as a watcher on issue %v: %v\n", createdIssue.Key, err)
+		}
+	}
+	if jiraConfig.RequestParticipants && managerUser.AccountID != unknownUser {
+		if config.AppConfig().DryRunUpdates() {
+			log.Printf("jira.CreateTicket(): dry-run mode: would have added manager %v as a request participant", managerUser.AccountID)
+		} else if err := addRequestParticipant(client, createdIssue.Key, managerUser); err != nil {
+			log.Printf("jira.CreateTicket(): failed to add manager as a request participant on issue %v: %v\n", createdIssue.Key, err)
 		}
-		return fmt.Errorf("failed to parse message template from AppConfig: %w", err)
 	}
 
-	var message bytes.Buffer
-	err = messageTemplate.Execute(&message, map[string]string{"Username": fmt.Sprintf("[~accountid:%v]", sreUser.AccountID)})
-	if err != nil {
-		return fmt.Errorf("failed to apply parsed template to the specified data object: %w", err)
+	// Place the issue on the team's board so it is picked up by their triage flow.
+	// Failing to do so is not fatal; the issue can still be found in the project.
+	if err := addToSprint(client, jiraConfig, createdIssue); err != nil {
+		log.Printf("jira.CreateTicket(): failed to add issue %v to a sprint: %v\n", createdIssue.Key, err)
 	}
 
-	comment := &jira.Comment{Body: message.String()}
+	linkIncidents(client, jiraConfig, createdIssue, ticket.incidents)
 
-	if config.AppConfig.DryRun {
-		log.Printf("jira.CreateTicket(): dry-run mode: would have added comment to Jira ticket with the following body: %+v", comment)
+	message, err := renderMessage(ticket.messageTemplate(), TemplateData{
+		Username: fmt.Sprintf("[~accountid:%v]", sreUser.AccountID),
+		IssueKey: createdIssue.Key,
+		Alert:    ticket.Alert,
+	})
+	if err != nil {
+		return err
+	}
+
+	if config.AppConfig().DryRunComments() {
+		log.Printf("jira.CreateTicket(): dry-run mode: would have added comment to Jira ticket with the following body: %v", message)
 		err = nil
 	} else {
-		_, _, err = issueService.AddComment(createdIssue.ID, comment)
+		err = addComment(client, jiraConfig.DocumentFormat, createdIssue.ID, message)
 	}
 
 	if err != nil {
+		compensate(client, jiraConfig, ticket, createdIssue, "the initial comment failed", false, 0)
 		return fmt.Errorf("issue %v was successfully created but failed to apply initial comment: %w", createdIssue.Key, err)
 	}
 
 	log.Printf("jira.CreateTicket(): initial comment successfully left on issue %v\n", createdIssue.Key)
+	ticket.outbox.commented()
 
-	initialStatusName := config.AppConfig.JiraConfig.Transitions[initialTransitionKey]
-
-	initialStatusId, err := getTransitionId(issueService, createdIssue.ID, initialStatusName)
-	if err != nil {
-		return fmt.Errorf("failed to fetch ID for status %v: %w", initialStatusName, err)
-	}
-
-	if config.AppConfig.DryRun {
-		log.Printf("jira.CreateTicket(): dry-run mode: would have transitioned Jira ticket to status %v", initialStatusName)
-	} else {
-		_, err = issueService.DoTransition(createdIssue.ID, initialStatusId)
+	for transitioned, statusName := range initialStatuses(jiraConfig, ticket) {
+		statusId, err := transitionID(client, jiraConfig, createdIssue.ID, statusName)
 		if err != nil {
-			return fmt.Errorf("failed to transition issue %v to status %v: %w", createdIssue.Key, initialStatusName, err)
+			compensate(client, jiraConfig, ticket, createdIssue, fmt.Sprintf("the transition to status %v failed", statusName), true, transitioned)
+			return fmt.Errorf("failed to fetch ID for status %v: %w", statusName, err)
 		}
+
+		if config.AppConfig().DryRunTransitions() {
+			log.Printf("jira.CreateTicket(): dry-run mode: would have transitioned Jira ticket to status %v", statusName)
+		} else {
+			err = doTransition(client, jiraConfig, createdIssue.ID, statusName, statusId)
+			if err != nil {
+				compensate(client, jiraConfig, ticket, createdIssue, fmt.Sprintf("the transition to status %v failed", statusName), true, transitioned)
+				return fmt.Errorf("failed to transition issue %v to status %v: %w", createdIssue.Key, statusName, err)
+			}
+		}
+
+		log.Printf("jira.CreateTicket(): issue %v has been transitioned to state %v", createdIssue.Key, statusName)
+		ticket.outbox.transitioned()
 	}
+	ticket.outbox.finish()
 
-	log.Printf("jira.CreateTicket(): issue %v has been transitioned to state %v", createdIssue.Key, initialStatusName)
+	if ticket.changeRecord != "" {
+		return approveForChange(client, jiraConfig, createdIssue, ticket.changeRecord, changeSourceAlert)
+	}
+	if ticket.ReadOnly {
+		return resolveReadOnly(client, jiraConfig, createdIssue, ticket.Alert)
+	}
 
 	return nil
 }
 
-func HandleUpdate(issueService *jira.IssueService, webhook Webhook) error {
-	if config.AppConfig.DryRun {
+// renderMessage renders the initial comment of an issue from the message template. Comments are
+// Jira markup rather than HTML, so text/template is used and the template renders as authored.
+func renderMessage(messageTemplate string, data TemplateData) (string, error) {
+	tmpl, err := helpers.ParseTemplate("messageTemplate", messageTemplate)
+	if err != nil {
+		logging.Debugf(logging.Jira, "jira.CreateTicket(): failed to parse message template from AppConfig; template: %v\n", messageTemplate)
+		return "", fmt.Errorf("failed to parse message template from AppConfig: %w", err)
+	}
+
+	var message bytes.Buffer
+	if err := tmpl.Execute(&message, data); err != nil {
+		return "", fmt.Errorf("failed to apply parsed template to the specified data object: %w", err)
+	}
+
+	return message.String(), nil
+}
+
+// HandleUpdate transitions the issue of the webhook in response to a comment by its assignee. The comment's
+// author and body are taken from the issue fetched from Jira, as the webhook payload can't be trusted.
+// The change records referenced by the comment are looked up within the context.
+func HandleUpdate(ctx context.Context, client *jira.Client, jiraConfig config.JiraConfig, webhook Webhook) error {
+	issueService := client.Issue
+
+	// Status changes without a comment were made directly in Jira, or are the result of
+	// the router's own transitions; there is nothing to transition in response to them
+	if from, to, ok := webhook.StatusChange(); ok && webhook.Comment.ID == "" {
+		log.Printf("jira.HandleUpdate(): issue %v transitioned from %v to %v by %v", webhook.Issue.Key, from, to, userName(webhook.User))
+		return nil
+	}
+
+	if config.AppConfig().DryRun {
 		log.Printf("jira.HandleUpdate(): dry-run mode: would have handled Jira webhook with issue, comment: %+v, %+v", webhook.Issue, webhook.Comment)
-		if config.AppConfig.Verbose {
-			log.Printf("jiraHandleUpdate(): dry-run mode: *jira.issueService: %+v", issueService)
-		}
+		logging.Debugf(logging.Jira, "jiraHandleUpdate(): dry-run mode: *jira.issueService: %+v", issueService)
 
 		return nil
 	}
@@ -183,60 +536,213 @@ func HandleUpdate(issueService *jira.IssueService, webhook Webhook) error {
 		return fmt.Errorf("failed to get issue %v from jira webhook: %w", webhook.Issue.Key, err)
 	}
 
-	var sreId string
-	var managerId string
-	for _, label := range webhookIssue.Fields.Labels {
-		if strings.Contains(label, sreLabelKey) {
-			_, sreId, _ = strings.Cut(label, ":")
-		}
-		if strings.Contains(label, managerLabelKey) {
-			_, managerId, _ = strings.Cut(label, ":")
-		}
+	labels := labelsFor(jiraConfig)
+	if !labels.isManaged(webhookIssue.Fields.Labels) {
+		log.Printf("jira.HandleUpdate(): ignoring webhook for unmanaged issue %v", webhookIssue.Key)
+		return nil
+	}
+
+	_, sreId, _ := labels.value(webhookIssue.Fields.Labels, sreLabelName)
+	_, managerId, _ := labels.value(webhookIssue.Fields.Labels, managerLabelName)
+
+	// The comment is looked up on the issue fetched from Jira, so its author and body can't be forged in the
+	// webhook payload; comments that aren't on the issue, eg. since deleted, are ignored
+	comment, ok := issueComment(webhookIssue, webhook.Comment.ID)
+	if !ok {
+		log.Printf("jira.HandleUpdate(): ignoring webhook for comment %v not found on issue %v", webhook.Comment.ID, webhookIssue.Key)
+		return nil
 	}
 
 	// If the comment isn't from the current assignee then we don't need to do anything.
-	if webhook.Comment.Author.AccountID != webhookIssue.Fields.Assignee.AccountID {
+	if webhookIssue.Fields.Assignee == nil || comment.Author.AccountID != webhookIssue.Fields.Assignee.AccountID {
 		return nil
 	}
 
-	var transitionName string
-	if sreId == webhook.Comment.Author.AccountID {
-		transitionName = config.AppConfig.JiraConfig.Transitions[sreTransitionKey]
-	} else if managerId == webhook.Comment.Author.AccountID {
-		transitionName = config.AppConfig.JiraConfig.Transitions[managerTransitionKey]
+	var step string
+	if sreId == comment.Author.AccountID {
+		step = sreTransitionKey
+	} else if managerId == comment.Author.AccountID {
+		step = managerTransitionKey
 	}
+	transitionName := jiraConfig.Transitions[step]
 
-	transitionId, err := getTransitionId(issueService, webhookIssue.ID, transitionName)
+	// Reject out of order approvals with an explanatory comment rather than transitioning
+	rejection, err := approvalRejection(jiraConfig, webhookIssue, sreId, step, *comment)
+	if err != nil {
+		return fmt.Errorf("failed to check the %v transition of issue %v: %w", step, webhookIssue.Key, err)
+	}
+	if rejection != "" {
+		if config.AppConfig().DryRunComments() {
+			log.Printf("jira.HandleUpdate(): dry-run mode: would have rejected %v transition of ticket %v with comment: %v", step, webhookIssue.Key, rejection)
+			return nil
+		}
+		err = addComment(client, jiraConfig.DocumentFormat, webhookIssue.ID, rejection)
+		if err != nil {
+			return fmt.Errorf("failed to comment on out of order approval of issue %v: %w", webhookIssue.Key, err)
+		}
+		log.Printf("jira.HandleUpdate(): rejected %v transition of ticket %v after comment from %v", step, webhookIssue.Key, comment.Author.Name)
+		return nil
+	}
+
+	transitionId, err := transitionID(client, jiraConfig, webhookIssue.ID, transitionName)
 	if err != nil {
 		return fmt.Errorf("failed to get transition ID for status %v on issue %v: %w", transitionName, webhookIssue.Key, err)
 	}
 
-	_, err = issueService.DoTransition(webhookIssue.ID, transitionId)
+	if config.AppConfig().DryRunTransitions() {
+		log.Printf("jira.HandleUpdate(): dry-run mode: would have transitioned ticket %v to status %v after comment from %v", webhookIssue.Key, transitionName, comment.Author.Name)
+		return nil
+	}
+
+	err = doTransition(client, jiraConfig, webhookIssue.ID, transitionName, transitionId)
 	if err != nil {
 		return fmt.Errorf("failed to transition issue %v to status %v: %w", webhookIssue.Key, transitionName, err)
 	}
-	log.Printf("jira.HandleUpdate(): successfully updated ticket %v to status %v after comment from %v", webhookIssue.Key, transitionName, webhook.Comment.Author.Name)
+	log.Printf("jira.HandleUpdate(): successfully updated ticket %v to status %v after comment from %v", webhookIssue.Key, transitionName, comment.Author.Name)
+	audit.Write(audit.ActionTransitioned, webhookIssue.Key, map[string]string{
+		"step":    step,
+		"status":  transitionName,
+		"comment": comment.ID,
+		"by":      comment.Author.AccountID,
+	})
+
+	// Approve the elevation right away when the justification references an approved change record.
+	// Failing to look up the record is not fatal; the manager approves the issue as usual.
+	if step == sreTransitionKey {
+		changeRecord, err := approvedChangeRecord(ctx, comment.Body)
+		if err != nil {
+			log.Printf("jira.HandleUpdate(): failed to look up the change records referenced by the justification on issue %v: %v\n", webhookIssue.Key, err)
+		} else if changeRecord != "" {
+			return approveForChange(client, jiraConfig, webhookIssue, changeRecord, changeSourceComment)
+		}
+	}
 
 	return nil
 }
 
-func basicAuthClient(user, token string) *http.Client {
+func basicAuthClient(user, token string, shared http.RoundTripper) *http.Client {
 	transport := jira.BasicAuthTransport{
-		Username: user,
-		Password: token,
+		Username:  user,
+		Password:  token,
+		Transport: shared,
 	}
 	return transport.Client()
 }
 
-func patAuthClient(token string) *http.Client {
+func patAuthClient(token string, shared http.RoundTripper) *http.Client {
 	transport := jira.PATAuthTransport{
-		Token: token,
+		Token:     token,
+		Transport: shared,
 	}
 	return transport.Client()
 }
 
+// contextTransport sends the requests through the wrapped transport with its context,
+// as the go-jira client methods don't take one
+type contextTransport struct {
+	transport http.RoundTripper
+	ctx       context.Context
+}
+
+// RoundTrip implements the http.RoundTripper interface
+func (t *contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.transport.RoundTrip(req.WithContext(t.ctx))
+}
+
+// withContext wraps the client's transport so its requests are cancelled with the context
+func withContext(ctx context.Context, client *http.Client) *http.Client {
+	client.Transport = &contextTransport{transport: client.Transport, ctx: ctx}
+	return client
+}
+
+// components converts the configured component names into Jira components to be set on new issues
+func components(names []string) []*jira.Component {
+	var c []*jira.Component
+	for _, name := range names {
+		c = append(c, &jira.Component{Name: name})
+	}
+	return c
+}
+
+// userName returns a human readable name for the user for logging
+func userName(user jira.User) string {
+	if user.DisplayName != "" {
+		return user.DisplayName
+	}
+	if user.Name != "" {
+		return user.Name
+	}
+	return user.AccountID
+}
+
+// setField sets a field go-jira has no struct field for on the issue
+func setField(issue *jira.Issue, key string, value interface{}) {
+	if issue.Fields.Unknowns == nil {
+		issue.Fields.Unknowns = map[string]interface{}{}
+	}
+	issue.Fields.Unknowns[key] = value
+}
+
+// securityLevel returns the security level field value for the configured level,
+// which may be given as either the ID or the name of the level
+func securityLevel(level string) map[string]string {
+	if _, err := strconv.Atoi(level); err == nil {
+		return map[string]string{"id": level}
+	}
+	return map[string]string{"name": level}
+}
+
+// watcherName returns the identifier Jira expects when adding a watcher;
+// Jira Cloud uses account IDs, while Jira Server/Data Center uses usernames
+func watcherName(user *jira.User) string {
+	if user.AccountID != "" {
+		return user.AccountID
+	}
+	return user.Name
+}
+
+// addRequestParticipant adds the user as a participant of the Jira Service Management request,
+// by account ID on Jira Cloud or by username on Jira Server/Data Center
+func addRequestParticipant(client *jira.Client, issueKey string, user *jira.User) error {
+	participants := map[string][]string{"accountIds": {user.AccountID}}
+	if user.AccountID == "" {
+		participants = map[string][]string{"usernames": {user.Name}}
+	}
+
+	req, err := client.NewRequest(http.MethodPost, fmt.Sprintf("rest/servicedeskapi/request/%s/participant", issueKey), participants)
+	if err != nil {
+		return err
+	}
+	_, err = client.Do(req, nil)
+	return err
+}
+
+// approvingManager returns the Jira user of the first manager in the escalation chain who has a
+// Jira account and isn't the SRE themselves, starting with the direct manager
+func approvingManager(client *jira.Client, jiraConfig config.JiraConfig, sreUser *jira.User, manager string, escalation []string) *jira.User {
+	candidates := append([]string{manager}, escalation...)
+	for i, name := range candidates {
+		managerUser, err := getUserByName(client, jiraConfig, name)
+		if err != nil {
+			log.Printf("jira.CreateTicket(): failed to fetch manager's Jira account: %v\n", err)
+			continue
+		}
+		// The SRE is only skipped when there is a manager to escalate to
+		if i < len(candidates)-1 && sreUser.AccountID != unknownUser && managerUser.AccountID == sreUser.AccountID {
+			log.Printf("jira.CreateTicket(): manager %v is the SRE; escalating\n", name)
+			continue
+		}
+		if i > 0 {
+			log.Printf("jira.CreateTicket(): escalated manager approval to %v\n", name)
+		}
+		return managerUser
+	}
+
+	return &jira.User{AccountID: unknownUser}
+}
+
 func getTransitionId(issueService *jira.IssueService, issueId string, status string) (string, error) {
-	if config.AppConfig.DryRun {
+	if config.AppConfig().DryRun {
 		log.Printf("jira.GetTransitionId(): dry-run mode: would have fetched transitions for Jira issue %v", issueId)
 		return "dry-run-transition-id", nil
 	}
@@ -246,27 +752,18 @@ func getTransitionId(issueService *jira.IssueService, issueId string, status str
 		return "", err
 	}
 
+	// Transitions are configured by their name, or by the status they lead to when no transition has the name
 	for _, t := range transitions {
 		if t.Name == status {
 			return t.ID, nil
 		}
 	}
-	return "", fmt.Errorf("did not find status %v", status)
-}
-
-func getUserByName(userService *jira.UserService, username string) (*jira.User, error) {
-	if (username == "") && config.AppConfig.Verbose {
-		log.Printf("jira.getUserByName() called with empty username")
-	}
-	users, _, err := userService.Find(username)
-	if err != nil {
-		return nil, err
-	}
-
-	if jiraUserLen := len(users); jiraUserLen != 1 {
-		return nil, fmt.Errorf("error finding user '%v': expected 1 user but found %v", username, jiraUserLen)
+	for _, t := range transitions {
+		if t.To.Name == status {
+			return t.ID, nil
+		}
 	}
-	return &users[0], nil
+	return "", fmt.Errorf("did not find status %v", status)
 }
 
 type UserService interface {
@@ -0,0 +1,484 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jira
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/splunk"
+)
+
+func TestWebhookStatusChange(t *testing.T) {
+	tests := []struct {
+		name     string
+		payload  string
+		wantFrom string
+		wantTo   string
+		wantOk   bool
+	}{
+		{
+			name:     "status change",
+			payload:  `{"webhookEvent":"jira:issue_updated","changelog":{"id":"1","items":[{"field":"assignee"},{"field":"status","fromString":"In Progress","toString":"Done"}]}}`,
+			wantFrom: "In Progress",
+			wantTo:   "Done",
+			wantOk:   true,
+		},
+		{
+			name:    "update without a status change",
+			payload: `{"webhookEvent":"jira:issue_updated","changelog":{"id":"1","items":[{"field":"assignee"}]}}`,
+		},
+		{
+			name:    "comment",
+			payload: `{"webhookEvent":"comment_created","comment":{"id":"1","body":"justification"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			webhook := Webhook{}
+			if err := json.Unmarshal([]byte(tt.payload), &webhook); err != nil {
+				t.Fatal(err)
+			}
+			from, to, ok := webhook.StatusChange()
+			if from != tt.wantFrom || to != tt.wantTo || ok != tt.wantOk {
+				t.Errorf("StatusChange() = %v, %v, %v, want %v, %v, %v", from, to, ok, tt.wantFrom, tt.wantTo, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestWebhookIgnored(t *testing.T) {
+	tests := []struct {
+		name        string
+		payload     string
+		wantReason  string
+		wantIgnored bool
+		wantErr     bool
+	}{
+		{
+			name:    "missing event",
+			payload: `{"issue":{"key":"OHSS-1"}}`,
+			wantErr: true,
+		},
+		{
+			name:        "unhandled event",
+			payload:     `{"webhookEvent":"jira:issue_deleted","issue":{"key":"OHSS-1"}}`,
+			wantReason:  "unhandled_event",
+			wantIgnored: true,
+		},
+		{
+			name:        "update of an unmanaged issue",
+			payload:     `{"webhookEvent":"jira:issue_updated","issue":{"key":"OHSS-1","fields":{"labels":["other"]}}}`,
+			wantReason:  "unmanaged_issue",
+			wantIgnored: true,
+		},
+		{
+			name:    "update of a managed issue",
+			payload: `{"webhookEvent":"jira:issue_updated","issue":{"key":"OHSS-1","fields":{"labels":["compliance-audit-router/managed"]}}}`,
+		},
+		{
+			name:    "comment without labels",
+			payload: `{"webhookEvent":"comment_created","issue":{"key":"OHSS-1","fields":{}},"comment":{"id":"1"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			webhook := Webhook{}
+			if err := json.Unmarshal([]byte(tt.payload), &webhook); err != nil {
+				t.Fatal(err)
+			}
+			reason, ignored, err := webhook.Ignored(config.JiraConfig{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Ignored() error = %v, want error %v", err, tt.wantErr)
+			}
+			if reason != tt.wantReason || ignored != tt.wantIgnored {
+				t.Errorf("Ignored() = %v, %v, want %v, %v", reason, ignored, tt.wantReason, tt.wantIgnored)
+			}
+		})
+	}
+}
+
+func TestNewClientContext(t *testing.T) {
+	// The server never responds, like a Jira instance that is stuck
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	client, err := NewClientContext(ctx, config.JiraConfig{Host: server.URL, Token: "test"})
+	if err != nil {
+		t.Fatalf("NewClientContext() unexpected error: %v", err)
+	}
+	if _, _, err := client.User.GetSelf(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GetSelf() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestHandleUpdateComment(t *testing.T) {
+	labels := labelsFor(config.JiraConfig{})
+	issue := map[string]interface{}{
+		"id":  "10001",
+		"key": "CAR-1",
+		"fields": map[string]interface{}{
+			"labels":   []string{labels.managed(), labels.sre("sre-id")},
+			"assignee": map[string]string{"accountId": "sre-id"},
+			"comment": map[string]interface{}{"comments": []map[string]interface{}{
+				{"id": "1", "author": map[string]string{"accountId": "other-id"}, "body": "needed to fix the cluster"},
+				{"id": "2", "author": map[string]string{"accountId": "sre-id"}, "body": "needed to fix the cluster"},
+			}},
+		},
+	}
+	var transitions int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/rest/api/2/issue/10001":
+			_ = json.NewEncoder(w).Encode(issue)
+		case r.Method == http.MethodGet && r.URL.Path == "/rest/api/2/issue/10001/transitions":
+			_, _ = w.Write([]byte(`{"transitions":[{"id":"21","name":"Justified"}]}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/rest/api/2/issue/10001/transitions":
+			transitions++
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	jiraConfig := config.JiraConfig{Host: server.URL, Transitions: map[string]string{sreTransitionKey: "Justified"}}
+	client, err := NewClient(jiraConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name            string
+		comment         jira.Comment
+		wantTransitions int
+	}{
+		// The webhook claims the assignee wrote the comment, but Jira records another author
+		{"forged author", jira.Comment{ID: "1", Author: jira.User{AccountID: "sre-id"}}, 0},
+		{"comment not on the issue", jira.Comment{ID: "3", Author: jira.User{AccountID: "sre-id"}, Body: "needed to fix the cluster"}, 0},
+		{"assignee comment", jira.Comment{ID: "2"}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transitions = 0
+			webhook := Webhook{WebhookEvent: webhookEventCommentCreated, Issue: jira.Issue{ID: "10001", Key: "CAR-1"}, Comment: tt.comment}
+			if err := HandleUpdate(context.Background(), client, jiraConfig, webhook); err != nil {
+				t.Fatalf("HandleUpdate() error = %v", err)
+			}
+			if transitions != tt.wantTransitions {
+				t.Errorf("HandleUpdate() transitioned the issue %d times, want %d", transitions, tt.wantTransitions)
+			}
+		})
+	}
+}
+
+func TestRenderMessage(t *testing.T) {
+	data := TemplateData{
+		Username: "[~accountid:123]",
+		IssueKey: "OHSS-1",
+		Alert:    splunk.AlertDetails{User: "jdoe", Reasons: []string{"fixing *prod* & <staging>"}},
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{
+			name:     "renders as authored",
+			template: "{{.Username}} {{.IssueKey}}: {{index .Alert.Reasons 0}}",
+			want:     "[~accountid:123] OHSS-1: fixing *prod* & <staging>",
+		},
+		{
+			name:     "wiki markup escaped explicitly",
+			template: "{{.Username}}: {{index .Alert.Reasons 0 | wikiescape}}",
+			want:     `[~accountid:123]: fixing \*prod\* & <staging>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderMessage(tt.template, data)
+			if err != nil {
+				t.Fatalf("renderMessage() unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("renderMessage() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := renderMessage("{{.Username", data); err == nil {
+		t.Errorf("renderMessage() expected an error for an invalid template")
+	}
+}
+
+func TestReminderCount(t *testing.T) {
+	tests := []struct {
+		name      string
+		labels    []string
+		wantLabel string
+		wantCount int
+	}{
+		{"no reminders", []string{"compliance-audit-router/managed"}, "", 0},
+		{"previous reminders", []string{"compliance-audit-router/managed", "compliance-audit-router/reminders:2"}, "compliance-audit-router/reminders:2", 2},
+		{"malformed count", []string{"compliance-audit-router/reminders:two"}, "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			label, count := reminderCount(labelsFor(config.JiraConfig{}), tt.labels)
+			if label != tt.wantLabel || count != tt.wantCount {
+				t.Errorf("reminderCount() = %v, %v, want %v, %v", label, count, tt.wantLabel, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestLabelScheme(t *testing.T) {
+	labels := labelsFor(config.JiraConfig{LabelPrefix: "car-staging", LabelSeparator: "="})
+	issueLabels := []string{labels.managed(), labels.sre("abc123"), labels.manager("def456"), "compliance-audit-router/sre:other"}
+
+	if got := labels.managed(); got != "car-staging/managed" {
+		t.Errorf("managed() = %v, want car-staging/managed", got)
+	}
+	if !labels.isManaged(issueLabels) {
+		t.Errorf("isManaged(%v) = false, want true", issueLabels)
+	}
+	if labelsFor(config.JiraConfig{}).isManaged(issueLabels) {
+		t.Errorf("default scheme isManaged(%v) = true, want false", issueLabels)
+	}
+	if _, sre, _ := labels.value(issueLabels, sreLabelName); sre != "abc123" {
+		t.Errorf("value(sre) = %v, want abc123", sre)
+	}
+	if _, manager, _ := labels.value(issueLabels, managerLabelName); manager != "def456" {
+		t.Errorf("value(manager) = %v, want def456", manager)
+	}
+}
+
+func TestApplyRisk(t *testing.T) {
+	riskConfig := config.RiskConfig{
+		Levels: []config.RiskLevel{{Name: "medium", MinScore: 10}, {Name: "high", MinScore: 50, Priority: "Critical"}},
+		Field:  "customfield_10042",
+	}
+
+	issue := &jira.Issue{Fields: &jira.IssueFields{Labels: []string{"compliance-audit-router/managed"}}}
+	applyRisk(issue, config.JiraConfig{}, riskConfig, 80)
+
+	if issue.Fields.Priority == nil || issue.Fields.Priority.Name != "Critical" {
+		t.Errorf("applyRisk() priority = %+v, want Critical", issue.Fields.Priority)
+	}
+	if got := issue.Fields.Labels; len(got) != 2 || got[1] != "compliance-audit-router/risk:high" {
+		t.Errorf("applyRisk() labels = %v, want the high risk label", got)
+	}
+	if got := issue.Fields.Unknowns["customfield_10042"]; got != 80 {
+		t.Errorf("applyRisk() field = %v, want 80", got)
+	}
+
+	issue = &jira.Issue{Fields: &jira.IssueFields{}}
+	applyRisk(issue, config.JiraConfig{}, riskConfig, 5)
+	if issue.Fields.Priority != nil || len(issue.Fields.Labels) != 0 {
+		t.Errorf("applyRisk() below the lowest level set %+v, %v", issue.Fields.Priority, issue.Fields.Labels)
+	}
+}
+
+// ticketServer is a fake Jira instance CreateTicket creates issue CAR-1 on, recording the fields of the
+// created issue and the bodies of the other requests, by method and path
+type ticketServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	fields   map[string]interface{}
+	requests map[string]string
+}
+
+func newTicketServer(t *testing.T) *ticketServer {
+	s := &ticketServer{requests: map[string]string{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.requests[r.Method+" "+r.URL.Path] = string(bytes.TrimSpace(body))
+
+		switch {
+		case r.URL.Path == "/rest/api/2/myself":
+			_, _ = w.Write([]byte(`{"accountId":"router-id"}`))
+		case r.URL.Path == "/rest/api/2/user/search":
+			// Every username has an account with an ID named after it
+			_, _ = w.Write([]byte(`[{"accountId":"` + r.URL.Query().Get("query") + `-id"}]`))
+		case r.Method == http.MethodPost && r.URL.Path == "/rest/api/2/issue":
+			var issue struct {
+				Fields map[string]interface{} `json:"fields"`
+			}
+			if err := json.Unmarshal(body, &issue); err != nil {
+				t.Errorf("failed to decode the created issue: %v", err)
+			}
+			s.fields = issue.Fields
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"id":"10001","key":"CAR-1"}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/rest/api/2/issue/10001/transitions":
+			_, _ = w.Write([]byte(`{"transitions":[{"id":"11","name":"In Progress"}]}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/rest/api/2/issue/10001/comment":
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"id":"1"}`))
+		case r.Method == http.MethodPost && (r.URL.Path == "/rest/api/2/issue/10001/transitions" ||
+			r.URL.Path == "/rest/api/2/issue/10001/watchers" || r.URL.Path == "/rest/servicedeskapi/request/CAR-1/participant"):
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// request returns the body of the request, and whether it was made
+func (s *ticketServer) request(method, path string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	body, ok := s.requests[method+" "+path]
+	return body, ok
+}
+
+// field returns the field of the created issue
+func (s *ticketServer) field(name string) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fields[name]
+}
+
+// jiraConfig returns the settings of a Jira instance on the server
+func (s *ticketServer) jiraConfig() config.JiraConfig {
+	return config.JiraConfig{Host: s.URL, Key: "CAR", IssueType: "Task", Transitions: map[string]string{initialTransitionKey: "In Progress"}}
+}
+
+func TestCreateTicketWatchers(t *testing.T) {
+	tests := []struct {
+		name                string
+		watchManager        bool
+		requestParticipants bool
+		wantWatcher         string
+		wantParticipant     string
+	}{
+		{name: "watcher", watchManager: true, wantWatcher: `"boss-id"`},
+		{name: "no watcher", watchManager: false},
+		{name: "request participant", requestParticipants: true, wantParticipant: `{"accountIds":["boss-id"]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTicketServer(t)
+			jiraConfig := server.jiraConfig()
+			jiraConfig.WatchManager, jiraConfig.RequestParticipants = tt.watchManager, tt.requestParticipants
+			client, err := NewClient(jiraConfig)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := CreateTicket(context.Background(), client, jiraConfig, Ticket{User: "sre", Manager: "boss"}); err != nil {
+				t.Fatalf("CreateTicket() error = %v", err)
+			}
+			if got, _ := server.request(http.MethodPost, "/rest/api/2/issue/10001/watchers"); got != tt.wantWatcher {
+				t.Errorf("CreateTicket() added watcher %v, want %v", got, tt.wantWatcher)
+			}
+			if got, _ := server.request(http.MethodPost, "/rest/servicedeskapi/request/CAR-1/participant"); got != tt.wantParticipant {
+				t.Errorf("CreateTicket() added participants %v, want %v", got, tt.wantParticipant)
+			}
+		})
+	}
+}
+
+func TestCreateTicketSecurityLevel(t *testing.T) {
+	tests := []struct {
+		name          string
+		securityLevel string
+		want          interface{}
+	}{
+		{"by name", "Compliance", map[string]interface{}{"name": "Compliance"}},
+		{"by ID", "10100", map[string]interface{}{"id": "10100"}},
+		{"not configured", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTicketServer(t)
+			jiraConfig := server.jiraConfig()
+			jiraConfig.SecurityLevel = tt.securityLevel
+			client, err := NewClient(jiraConfig)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := CreateTicket(context.Background(), client, jiraConfig, Ticket{User: "sre", Manager: "boss"}); err != nil {
+				t.Fatalf("CreateTicket() error = %v", err)
+			}
+			if got := server.field("security"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CreateTicket() created the issue with security %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateTicketComponents(t *testing.T) {
+	tests := []struct {
+		name       string
+		components []string
+		want       interface{}
+	}{
+		{"configured", []string{"Compliance", "SRE"}, []interface{}{map[string]interface{}{"name": "Compliance"}, map[string]interface{}{"name": "SRE"}}},
+		{"not configured", nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTicketServer(t)
+			jiraConfig := server.jiraConfig()
+			jiraConfig.Components = tt.components
+			client, err := NewClient(jiraConfig)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := CreateTicket(context.Background(), client, jiraConfig, Ticket{User: "sre", Manager: "boss"}); err != nil {
+				t.Fatalf("CreateTicket() error = %v", err)
+			}
+			if got := server.field("components"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CreateTicket() created the issue with components %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
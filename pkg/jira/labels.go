@@ -0,0 +1,115 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jira
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+)
+
+const (
+	defaultLabelPrefix    = "compliance-audit-router"
+	defaultLabelSeparator = ":"
+
+	managedLabelName   = "managed"
+	sreLabelName       = "sre"
+	managerLabelName   = "manager"
+	reminderLabelName  = "reminders"
+	riskLabelName      = "risk"
+	digestLabelName    = "digest"
+	labelNameSeparator = "/"
+)
+
+// labelScheme builds and parses the labels used to track the issues managed by the router.
+// Labels look like <prefix>/managed and <prefix>/sre<separator><account ID>, so that several
+// router instances, or other tools, can use the same Jira project with different prefixes.
+type labelScheme struct {
+	prefix    string
+	separator string
+}
+
+// labelsFor returns the label scheme of the Jira instance
+func labelsFor(jiraConfig config.JiraConfig) labelScheme {
+	l := labelScheme{prefix: jiraConfig.LabelPrefix, separator: jiraConfig.LabelSeparator}
+	if l.prefix == "" {
+		l.prefix = defaultLabelPrefix
+	}
+	if l.separator == "" {
+		l.separator = defaultLabelSeparator
+	}
+	return l
+}
+
+func (l labelScheme) name(name string) string {
+	return l.prefix + labelNameSeparator + name
+}
+
+func (l labelScheme) withValue(name string, value interface{}) string {
+	return fmt.Sprintf("%s%s%v", l.name(name), l.separator, value)
+}
+
+// managed returns the label marking an issue as managed by the router
+func (l labelScheme) managed() string {
+	return l.name(managedLabelName)
+}
+
+// sre returns the label recording the SRE's account ID
+func (l labelScheme) sre(accountID string) string {
+	return l.withValue(sreLabelName, accountID)
+}
+
+// manager returns the label recording the manager's account ID
+func (l labelScheme) manager(accountID string) string {
+	return l.withValue(managerLabelName, accountID)
+}
+
+// reminders returns the label recording the number of reminders posted on an issue
+func (l labelScheme) reminders(count int) string {
+	return l.withValue(reminderLabelName, count)
+}
+
+// risk returns the label recording the risk level of the issue's elevated commands
+func (l labelScheme) risk(level string) string {
+	return l.withValue(riskLabelName, level)
+}
+
+// digest returns the label marking an issue as a digest of low-risk alerts
+func (l labelScheme) digest() string {
+	return l.name(digestLabelName)
+}
+
+// isManaged reports whether the labels include the managed label
+func (l labelScheme) isManaged(labels []string) bool {
+	for _, label := range labels {
+		if label == l.managed() {
+			return true
+		}
+	}
+	return false
+}
+
+// value returns the label with the given name and its value
+func (l labelScheme) value(labels []string, name string) (string, string, bool) {
+	for _, label := range labels {
+		if value, ok := strings.CutPrefix(label, l.name(name)+l.separator); ok {
+			return label, value, true
+		}
+	}
+	return "", "", false
+}
@@ -0,0 +1,581 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jira
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/google/uuid"
+	"github.com/openshift/compliance-audit-router/pkg/audit"
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/helpers"
+	"github.com/openshift/compliance-audit-router/pkg/metrics"
+)
+
+const outboxLabelName = "outbox"
+
+// outboxEntry records the Jira actions intended for a new ticket, creating the issue, leaving the initial
+// comment and applying the initial transitions, and which of them were made. It is recorded before the
+// issue is created and removed once all the actions are made, so the actions of a ticket left unfinished,
+// eg. by a crash between creating the issue and commenting on it, are made when the outbox is reconciled.
+type outboxEntry struct {
+	// Key is the idempotency key of the ticket, also set as a label on the issue so it can be found
+	// when the router crashed before recording its creation
+	Key      string    `json:"key"`
+	Recorded time.Time `json:"recorded"`
+	Host     string    `json:"host"`
+	Project  string    `json:"project"`
+
+	Issue       *jira.Issue  `json:"issue"`
+	Description string       `json:"description"`
+	Template    string       `json:"template"`
+	Data        TemplateData `json:"data"`
+	Statuses    []string     `json:"statuses"`
+
+	IssueID      string `json:"issueId,omitempty"`
+	IssueKey     string `json:"issueKey,omitempty"`
+	Commented    bool   `json:"commented,omitempty"`
+	Transitioned int    `json:"transitioned,omitempty"`
+	// Incomplete is set once the issue was marked for manual review after one of the actions failed
+	Incomplete bool `json:"incomplete,omitempty"`
+}
+
+// outbox is a directory of entries, one file per ticket. Without a directory, the entries are kept in memory;
+// only the repairs of the tickets whose actions failed are recorded then.
+type outbox struct {
+	dir    string
+	memory map[string]outboxEntry
+
+	// inflight are the keys of the entries being worked on by this process, creating or reconciling their ticket
+	mu       sync.Mutex
+	inflight map[string]bool
+}
+
+var (
+	defaultOutboxOnce sync.Once
+	defaultOutbox     *outbox
+)
+
+// outboxFor returns the outbox of outboxconfig.dir, or the in-memory outbox when it is not set,
+// or nil in dry-run mode, where no action is made
+func outboxFor() *outbox {
+	if config.AppConfig().DryRun {
+		return nil
+	}
+	defaultOutboxOnce.Do(func() {
+		defaultOutbox = &outbox{memory: map[string]outboxEntry{}, inflight: map[string]bool{}}
+		if dir := config.AppConfig().OutboxConfig.Dir; dir != "" {
+			if err := os.MkdirAll(dir, 0o700); err != nil {
+				log.Printf("jira.outboxFor(): failed to create outbox directory; keeping the outbox in memory: %v\n", err)
+				return
+			}
+			defaultOutbox.dir = dir
+			if entries, err := defaultOutbox.entries(); err == nil {
+				metrics.MetricOutboxPending.Set(float64(len(entries)))
+			}
+		}
+	})
+	return defaultOutbox
+}
+
+func (o *outbox) path(key string) string {
+	return filepath.Join(o.dir, key+".json")
+}
+
+// save writes the entry atomically, replacing the previous version, so a crash doesn't leave a partial entry
+func (o *outbox) save(entry *outboxEntry) error {
+	if o.dir == "" {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		o.memory[entry.Key] = *entry
+		return nil
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	return helpers.WriteFileAtomic(o.path(entry.Key), data)
+}
+
+// load returns the entry of the key, when one is recorded
+func (o *outbox) load(key string) (outboxEntry, bool) {
+	if o.dir == "" {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		entry, ok := o.memory[key]
+		return entry, ok
+	}
+
+	data, err := os.ReadFile(o.path(key))
+	if err != nil {
+		return outboxEntry{}, false
+	}
+	var entry outboxEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		log.Printf("jira.outbox(): ignoring outbox entry %v that is not valid JSON: %v\n", key, err)
+		return outboxEntry{}, false
+	}
+	return entry, true
+}
+
+// remove removes the entry once all its actions are made, or Jira rejected the creation of its ticket
+func (o *outbox) remove(key string) {
+	if o.dir == "" {
+		o.mu.Lock()
+		delete(o.memory, key)
+		o.mu.Unlock()
+		metrics.MetricOutboxPending.Dec()
+		return
+	}
+
+	if err := os.Remove(o.path(key)); err != nil {
+		log.Printf("jira.outbox(): failed to remove outbox entry %v: %v\n", key, err)
+		return
+	}
+	metrics.MetricOutboxPending.Dec()
+}
+
+// entries returns the entries in the order they were recorded
+func (o *outbox) entries() ([]outboxEntry, error) {
+	var entries []outboxEntry
+	if o.dir == "" {
+		o.mu.Lock()
+		for _, entry := range o.memory {
+			entries = append(entries, entry)
+		}
+		o.mu.Unlock()
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].Recorded.Before(entries[j].Recorded) })
+		return entries, nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(o.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var entry outboxEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			log.Printf("jira.outbox(): skipping outbox entry %v that is not valid JSON: %v\n", file, err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Recorded.Before(entries[j].Recorded) })
+	return entries, nil
+}
+
+func (o *outbox) setInflight(key string, inflight bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if inflight {
+		o.inflight[key] = true
+	} else {
+		delete(o.inflight, key)
+	}
+}
+
+// claim marks the entry as being worked on by this process, reporting false when it already is
+func (o *outbox) claim(key string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.inflight[key] {
+		return false
+	}
+	o.inflight[key] = true
+	return true
+}
+
+// OutboxPending returns the number of tickets whose actions recorded in the outbox are not all made
+func OutboxPending() (int, error) {
+	o := outboxFor()
+	if o == nil {
+		return 0, nil
+	}
+	entries, err := o.entries()
+	return len(entries), err
+}
+
+// outboxTicket is the outbox entry of a ticket being created; its methods do nothing when the outbox is disabled
+type outboxTicket struct {
+	outbox *outbox
+	entry  outboxEntry
+}
+
+// recordOutbox records the Jira actions intended for the prepared ticket in the outbox before the issue is created,
+// labelling the issue with the entry's idempotency key
+func recordOutbox(jiraConfig config.JiraConfig, ticket *preparedTicket) error {
+	o := outboxFor()
+	if o == nil || o.dir == "" {
+		return nil
+	}
+
+	entry := newOutboxEntry(jiraConfig, *ticket)
+	key := entry.Key
+	ticket.issue.Fields.Labels = append(ticket.issue.Fields.Labels, labelsFor(jiraConfig).withValue(outboxLabelName, key))
+
+	if !o.claim(key) {
+		return fmt.Errorf("the ticket is already being created")
+	}
+	if err := o.save(&entry); err != nil {
+		o.setInflight(key, false)
+		return fmt.Errorf("failed to record the ticket in the outbox: %w", err)
+	}
+	metrics.MetricOutboxPending.Inc()
+
+	ticket.outbox = &outboxTicket{outbox: o, entry: entry}
+	return nil
+}
+
+// resumeOutbox finishes the ticket from its outbox entry when one is left from an earlier attempt, eg. a retry of
+// an alert whose ticket failed with a timeout, finding the issue by its label rather than creating it again.
+// It reports whether the ticket was recorded in the outbox, with the error of finishing it.
+func resumeOutbox(client *jira.Client, jiraConfig config.JiraConfig, ticket Ticket) (bool, error) {
+	o := outboxFor()
+	if o == nil || o.dir == "" || ticket.Alert.SearchID == "" {
+		return false, nil
+	}
+
+	key := outboxKey(jiraConfig, ticket)
+	entry, ok := o.load(key)
+	if !ok {
+		return false, nil
+	}
+	if !o.claim(key) {
+		return true, fmt.Errorf("the ticket is already being created")
+	}
+	defer o.setInflight(key, false)
+
+	log.Printf("jira.CreateTicket(): finishing the ticket recorded in outbox entry %v", key)
+	if err := reconcileTicket(client, jiraConfig, o, &entry); err != nil {
+		return true, fmt.Errorf("failed to finish the ticket recorded in the outbox: %w", err)
+	}
+	o.remove(key)
+	return true, nil
+}
+
+// scheduleRepair records the remaining actions of a created issue whose ticket wasn't recorded in the outbox,
+// to be made when the outbox is reconciled
+func scheduleRepair(jiraConfig config.JiraConfig, ticket preparedTicket, issue *jira.Issue, commented bool, transitioned int) *outboxTicket {
+	o := outboxFor()
+	if o == nil {
+		return nil
+	}
+
+	entry := newOutboxEntry(jiraConfig, ticket)
+	entry.IssueID, entry.IssueKey = issue.ID, issue.Key
+	entry.Commented, entry.Transitioned = commented, transitioned
+	if err := o.save(&entry); err != nil {
+		log.Printf("jira.CreateTicket(): failed to schedule the repair of issue %v: %v\n", issue.Key, err)
+		return nil
+	}
+	metrics.MetricOutboxPending.Inc()
+	return &outboxTicket{outbox: o, entry: entry}
+}
+
+// outboxKey returns the idempotency key of the ticket, derived from the search ID of its alert and the content
+// of its compliance event, so a retry of the alert finds the entry of its earlier attempt. Tickets not created
+// for an alert's event, eg. those tracking processing errors, get a random key.
+func outboxKey(jiraConfig config.JiraConfig, ticket Ticket) string {
+	if ticket.Alert.SearchID == "" {
+		return uuid.New().String()
+	}
+
+	sum := sha256.New()
+	for _, value := range []string{jiraConfig.Host, jiraConfig.Key, ticket.Alert.SearchID, ticket.Alert.ContentHash()} {
+		sum.Write([]byte(value))
+		sum.Write([]byte{0})
+	}
+	return hex.EncodeToString(sum.Sum(nil))[:32]
+}
+
+// newOutboxEntry returns the entry of the prepared ticket's intended actions
+func newOutboxEntry(jiraConfig config.JiraConfig, ticket preparedTicket) outboxEntry {
+	return outboxEntry{
+		Key:         outboxKey(jiraConfig, ticket.Ticket),
+		Recorded:    time.Now().UTC(),
+		Host:        jiraConfig.Host,
+		Project:     jiraConfig.Key,
+		Issue:       ticket.issue,
+		Description: ticket.Description,
+		Template:    ticket.messageTemplate(),
+		Data:        TemplateData{Username: fmt.Sprintf("[~accountid:%v]", ticket.sreUser.AccountID), Alert: ticket.Alert},
+		Statuses:    initialStatuses(jiraConfig, ticket),
+	}
+}
+
+// update records the progress of the ticket's actions. Failing to do so is not fatal: the actions made
+// are found on the issue when the outbox is reconciled.
+func (t *outboxTicket) update(apply func(entry *outboxEntry)) {
+	if t == nil {
+		return
+	}
+	apply(&t.entry)
+	if err := t.outbox.save(&t.entry); err != nil {
+		log.Printf("jira.CreateTicket(): failed to record the progress of issue %v in the outbox: %v\n", t.entry.IssueKey, err)
+	}
+}
+
+// created records the creation of the issue
+func (t *outboxTicket) created(issue *jira.Issue) {
+	t.update(func(entry *outboxEntry) { entry.IssueID, entry.IssueKey = issue.ID, issue.Key })
+}
+
+// commented records the initial comment on the issue
+func (t *outboxTicket) commented() {
+	t.update(func(entry *outboxEntry) { entry.Commented = true })
+}
+
+// transitioned records an initial transition of the issue
+func (t *outboxTicket) transitioned() {
+	t.update(func(entry *outboxEntry) { entry.Transitioned++ })
+}
+
+// finish removes the entry once all the actions are made, or Jira rejected the issue and the error is
+// returned to the caller
+func (t *outboxTicket) finish() {
+	if t == nil {
+		return
+	}
+	t.outbox.remove(t.entry.Key)
+}
+
+// release hands the entry over to the reconciliation once this process stops working on the ticket
+func (t *outboxTicket) release() {
+	if t == nil {
+		return
+	}
+	t.outbox.setInflight(t.entry.Key, false)
+}
+
+// initialStatuses returns the names of the statuses a new issue is transitioned to, in order
+func initialStatuses(jiraConfig config.JiraConfig, ticket preparedTicket) []string {
+	statuses := []string{jiraConfig.Transitions[initialTransitionKey]}
+	if ticket.FastTrack || ticket.changeRecord != "" || ticket.ReadOnly {
+		statuses = append(statuses, jiraConfig.Transitions[sreTransitionKey])
+	}
+	return statuses
+}
+
+var reconcileMutex sync.Mutex
+
+// ReconcileOutbox makes the actions recorded in the outbox of the tickets left unfinished, eg. by a crash.
+// Each action is checked against the issue before it is made, so an action made before the crash but not
+// recorded isn't made twice. Tickets that fail to be reconciled are retried on the next run.
+func ReconcileOutbox() {
+	reconcileMutex.Lock()
+	defer reconcileMutex.Unlock()
+
+	o := outboxFor()
+	if o == nil {
+		return
+	}
+	entries, err := o.entries()
+	if err != nil {
+		log.Printf("jira.ReconcileOutbox(): failed to read the outbox: %v\n", err)
+		return
+	}
+
+	for _, entry := range entries {
+		if !o.claim(entry.Key) {
+			continue
+		}
+		reconcileEntry(o, entry)
+		o.setInflight(entry.Key, false)
+	}
+}
+
+// reconcileEntry makes the actions of the entry claimed by the reconciliation, removing it once they are all made
+func reconcileEntry(o *outbox, entry outboxEntry) {
+	jiraConfig, ok := outboxJiraConfig(entry)
+	if !ok {
+		log.Printf("jira.ReconcileOutbox(): no Jira instance is configured for project %v on %v; keeping outbox entry %v\n", entry.Project, entry.Host, entry.Key)
+		return
+	}
+	client, err := NewClient(jiraConfig)
+	if err != nil {
+		log.Printf("jira.ReconcileOutbox(): failed to create Jira client: %v\n", err)
+		return
+	}
+
+	if err := reconcileTicket(client, jiraConfig, o, &entry); err != nil {
+		log.Printf("jira.ReconcileOutbox(): failed to reconcile outbox entry %v: %v\n", entry.Key, err)
+		metrics.MetricOutboxReconciled.With(map[string]string{"result": "failed"}).Inc()
+		return
+	}
+	log.Printf("jira.ReconcileOutbox(): finished issue %v from the outbox", entry.IssueKey)
+	metrics.MetricOutboxReconciled.With(map[string]string{"result": "finished"}).Inc()
+	o.remove(entry.Key)
+}
+
+// outboxJiraConfig returns the configured Jira instance of the entry's project
+func outboxJiraConfig(entry outboxEntry) (config.JiraConfig, bool) {
+	for _, jiraConfig := range config.AppConfig().RoutedJiraConfigs() {
+		if jiraConfig.Host == entry.Host && jiraConfig.Key == entry.Project {
+			return jiraConfig, true
+		}
+	}
+	return config.JiraConfig{}, false
+}
+
+// reconcileTicket makes the entry's actions that aren't found on the issue, recording the progress
+func reconcileTicket(client *jira.Client, jiraConfig config.JiraConfig, o *outbox, entry *outboxEntry) error {
+	if entry.IssueKey == "" {
+		// Jira's search index is eventually consistent, so an issue created moments before a crash may not be
+		// found yet, and is created again
+		label := labelsFor(jiraConfig).withValue(outboxLabelName, entry.Key)
+		issues, _, err := client.Issue.Search(fmt.Sprintf(`project = "%s" AND labels = "%s"`, entry.Project, label), &jira.SearchOptions{MaxResults: 1, Fields: []string{"summary"}})
+		if err != nil {
+			return fmt.Errorf("failed to search for the issue: %w", err)
+		}
+
+		var issue *jira.Issue
+		if len(issues) > 0 {
+			issue = &issues[0]
+		} else {
+			issue, err = createIssue(client, jiraConfig.DocumentFormat, entry.Issue, entry.Description)
+			if err != nil {
+				return fmt.Errorf("failed to create issue: %w", err)
+			}
+			audit.Write(audit.ActionTicketCreated, issue.Key, map[string]string{"alert": entry.Data.Alert.AlertName, "user": entry.Data.Alert.User})
+		}
+		entry.IssueID, entry.IssueKey = issue.ID, issue.Key
+		if err := o.save(entry); err != nil {
+			return err
+		}
+	}
+
+	issue, _, err := client.Issue.Get(entry.IssueID, &jira.GetQueryOptions{Fields: "status,comment"})
+	if err != nil {
+		return fmt.Errorf("failed to get issue %v: %w", entry.IssueKey, err)
+	}
+
+	if !entry.Commented && !config.AppConfig().DryRunComments() && !commentedBy(issue, entry.Issue.Fields.Reporter) {
+		data := entry.Data
+		data.IssueKey = entry.IssueKey
+		message, err := renderMessage(entry.Template, data)
+		if err != nil {
+			return err
+		}
+		if err := addComment(client, jiraConfig.DocumentFormat, entry.IssueID, message); err != nil {
+			return fmt.Errorf("failed to apply initial comment: %w", err)
+		}
+		entry.Commented = true
+		if err := o.save(entry); err != nil {
+			return err
+		}
+	}
+
+	// Transitions applied before the crash but not recorded are found from the issue's status
+	if issue.Fields != nil && issue.Fields.Status != nil {
+		for i := len(entry.Statuses) - 1; i >= entry.Transitioned; i-- {
+			if entry.Statuses[i] == issue.Fields.Status.Name {
+				entry.Transitioned = i + 1
+				break
+			}
+		}
+	}
+	for entry.Transitioned < len(entry.Statuses) && !config.AppConfig().DryRunTransitions() {
+		statusName := entry.Statuses[entry.Transitioned]
+		statusId, err := transitionID(client, jiraConfig, entry.IssueID, statusName)
+		if err != nil {
+			return fmt.Errorf("failed to fetch ID for status %v: %w", statusName, err)
+		}
+		if err := doTransition(client, jiraConfig, entry.IssueID, statusName, statusId); err != nil {
+			return fmt.Errorf("failed to transition issue %v to status %v: %w", entry.IssueKey, statusName, err)
+		}
+		entry.Transitioned++
+		if err := o.save(entry); err != nil {
+			return err
+		}
+	}
+
+	if entry.Incomplete {
+		completeRepair(client, jiraConfig, entry.IssueID, entry.IssueKey)
+	}
+	return nil
+}
+
+// commentedBy reports whether the issue has a comment by the user, the router's account that left the initial comment
+func commentedBy(issue *jira.Issue, user *jira.User) bool {
+	if issue.Fields == nil || issue.Fields.Comments == nil || user == nil {
+		return false
+	}
+	for _, comment := range issue.Fields.Comments.Comments {
+		if comment == nil || strings.HasPrefix(comment.Body, incompleteCommentPrefix) {
+			continue
+		}
+		if (user.AccountID != "" && comment.Author.AccountID == user.AccountID) || (user.AccountID == "" && comment.Author.Name == user.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// statusError is the error of a Jira request with the status Jira responded with
+type statusError struct {
+	status int
+	err    error
+}
+
+func (e *statusError) Error() string {
+	return e.err.Error()
+}
+
+func (e *statusError) Unwrap() error {
+	return e.err
+}
+
+// withStatus adds the status of the response to the error, when Jira responded
+func withStatus(resp *jira.Response, err error) error {
+	if resp == nil || resp.Response == nil {
+		return err
+	}
+	return &statusError{status: resp.StatusCode, err: err}
+}
+
+// rejected reports whether Jira definitely rejected the request with a client error, so it fails the same way
+// when retried. On timeouts, connection failures and server errors the issue may have been created anyway.
+func rejected(err error) bool {
+	var statusErr *statusError
+	if !errors.As(err, &statusErr) {
+		return false
+	}
+	return statusErr.status >= http.StatusBadRequest && statusErr.status < http.StatusInternalServerError &&
+		statusErr.status != http.StatusRequestTimeout && statusErr.status != http.StatusTooManyRequests
+}
@@ -0,0 +1,230 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jira
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/splunk"
+)
+
+func TestReconcileTicket(t *testing.T) {
+	var searches, comments []string
+	var transitions []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/rest/api/2/search":
+			searches = append(searches, r.URL.Query().Get("jql"))
+			_, _ = w.Write([]byte(`{"issues":[{"id":"10001","key":"CAR-1"}]}`))
+		case "/rest/api/2/issue/10001":
+			// The issue was created and transitioned to its initial status, but not commented on, before the crash
+			_, _ = w.Write([]byte(`{"id":"10001","key":"CAR-1","fields":{"status":{"name":"Pending"},"comment":{"comments":[]}}}`))
+		case "/rest/api/2/issue/10001/comment":
+			body, _ := io.ReadAll(r.Body)
+			comments = append(comments, string(body))
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{}`))
+		case "/rest/api/2/issue/10001/transitions":
+			if r.Method == http.MethodPost {
+				body, _ := io.ReadAll(r.Body)
+				transitions = append(transitions, string(body))
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			_, _ = w.Write([]byte(`{"transitions":[{"id":"11","name":"Pending"},{"id":"21","name":"SRE Review"}]}`))
+		default:
+			t.Errorf("unexpected request %v %v", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient(config.JiraConfig{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jiraConfig := config.JiraConfig{Host: server.URL, Key: "CAR"}
+
+	o := &outbox{dir: t.TempDir(), inflight: map[string]bool{}}
+	entry := outboxEntry{
+		Key:      "abc",
+		Recorded: time.Now(),
+		Host:     server.URL,
+		Project:  "CAR",
+		Issue:    &jira.Issue{Fields: &jira.IssueFields{Reporter: &jira.User{AccountID: "router"}}},
+		Template: "Please justify {{.IssueKey}}",
+		Statuses: []string{"Pending", "SRE Review"},
+	}
+	if err := o.save(&entry); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := reconcileTicket(client, jiraConfig, o, &entry); err != nil {
+		t.Fatalf("reconcileTicket() error = %v", err)
+	}
+
+	if len(searches) != 1 || !strings.Contains(searches[0], `labels = "compliance-audit-router/outbox:abc"`) {
+		t.Errorf("reconcileTicket() searches = %v, want a search for the outbox label", searches)
+	}
+	if len(comments) != 1 || !strings.Contains(comments[0], "Please justify CAR-1") {
+		t.Errorf("reconcileTicket() comments = %v, want the initial comment", comments)
+	}
+	if len(transitions) != 1 || !strings.Contains(transitions[0], `"21"`) {
+		t.Errorf("reconcileTicket() transitions = %v, want only the transition to SRE Review", transitions)
+	}
+
+	data, err := os.ReadFile(o.path("abc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved outboxEntry
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved.IssueKey != "CAR-1" || !saved.Commented || saved.Transitioned != 2 {
+		t.Errorf("reconcileTicket() recorded %+v, want all the actions made", saved)
+	}
+}
+
+func TestCommentedBy(t *testing.T) {
+	issue := &jira.Issue{Fields: &jira.IssueFields{Comments: &jira.Comments{Comments: []*jira.Comment{
+		{Author: jira.User{AccountID: "sre"}},
+		{Author: jira.User{Name: "router"}},
+		{Author: jira.User{AccountID: "router"}, Body: incompleteCommentPrefix + ": the initial comment failed."},
+	}}}}
+
+	if commentedBy(issue, &jira.User{AccountID: "router"}) {
+		t.Errorf("commentedBy() found a comment by an account without comments")
+	}
+	if !commentedBy(issue, &jira.User{AccountID: "sre"}) || !commentedBy(issue, &jira.User{Name: "router"}) {
+		t.Errorf("commentedBy() did not find the comments")
+	}
+}
+
+func TestCompensate(t *testing.T) {
+	var labelUpdates, comments []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		body, _ := io.ReadAll(r.Body)
+		switch r.URL.Path {
+		case "/rest/api/2/issue/10001":
+			labelUpdates = append(labelUpdates, string(body))
+			w.WriteHeader(http.StatusNoContent)
+		case "/rest/api/2/issue/10001/comment":
+			comments = append(comments, string(body))
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			t.Errorf("unexpected request %v %v", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient(config.JiraConfig{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jiraConfig := config.JiraConfig{Host: server.URL, Key: "CAR", Transitions: map[string]string{initialTransitionKey: "Pending"}}
+	ticket := preparedTicket{issue: &jira.Issue{Fields: &jira.IssueFields{}}, sreUser: &jira.User{AccountID: "sre"}}
+
+	compensate(client, jiraConfig, ticket, &jira.Issue{ID: "10001", Key: "CAR-1"}, "the transition to status Pending failed", true, 0)
+
+	if len(labelUpdates) != 1 || !strings.Contains(labelUpdates[0], `"add":"compliance-audit-router/incomplete"`) {
+		t.Errorf("compensate() label updates = %v, want the incomplete label added", labelUpdates)
+	}
+	if len(comments) != 1 || !strings.Contains(comments[0], "the transition to status Pending failed. Manual review needed") {
+		t.Errorf("compensate() comments = %v, want the manual review comment", comments)
+	}
+
+	entries, err := outboxFor().entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var repair *outboxEntry
+	for i := range entries {
+		if entries[i].IssueKey == "CAR-1" {
+			repair = &entries[i]
+		}
+	}
+	if repair == nil || !repair.Incomplete || !repair.Commented || repair.Transitioned != 0 || len(repair.Statuses) != 1 {
+		t.Errorf("compensate() scheduled repair %+v, want the transition to Pending", repair)
+	}
+	if repair != nil {
+		outboxFor().remove(repair.Key)
+	}
+}
+
+func TestOutboxKey(t *testing.T) {
+	jiraConfig := config.JiraConfig{Host: "https://jira.example.com", Key: "CAR"}
+	event := splunk.AlertDetails{SearchID: "sid-1", User: "sre", ClusterIDs: []string{"a", "b"}}
+	reordered := splunk.AlertDetails{SearchID: "sid-1", User: "sre", ClusterIDs: []string{"b", "a"}}
+
+	key := outboxKey(jiraConfig, Ticket{Alert: event})
+	if got := outboxKey(jiraConfig, Ticket{Alert: reordered}); got != key {
+		t.Errorf("outboxKey() = %v for a retry of the event, want %v", got, key)
+	}
+
+	otherSearch := event
+	otherSearch.SearchID = "sid-2"
+	otherUser := event
+	otherUser.User = "other"
+	for _, ticket := range []Ticket{{Alert: otherSearch}, {Alert: otherUser}} {
+		if got := outboxKey(jiraConfig, ticket); got == key {
+			t.Errorf("outboxKey(%+v) = %v, the key of another event", ticket.Alert, got)
+		}
+	}
+
+	// Tickets not created for an alert's event get their own key
+	if outboxKey(jiraConfig, Ticket{}) == outboxKey(jiraConfig, Ticket{}) {
+		t.Errorf("outboxKey() returned the same key for tickets without an alert")
+	}
+}
+
+func TestRejected(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"bad request", &statusError{status: http.StatusBadRequest, err: errors.New("invalid field")}, true},
+		{"wrapped forbidden", fmt.Errorf("failed: %w", &statusError{status: http.StatusForbidden, err: errors.New("forbidden")}), true},
+		{"request timeout", &statusError{status: http.StatusRequestTimeout, err: errors.New("timeout")}, false},
+		{"rate limited", &statusError{status: http.StatusTooManyRequests, err: errors.New("slow down")}, false},
+		{"server error", &statusError{status: http.StatusBadGateway, err: errors.New("bad gateway")}, false},
+		{"no response", errors.New("context deadline exceeded"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rejected(tt.err); got != tt.want {
+				t.Errorf("rejected() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
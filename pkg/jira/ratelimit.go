@@ -0,0 +1,229 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jira
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/metrics"
+)
+
+const (
+	// defaultRetryAfter is the initial wait after a 429 response without a Retry-After header,
+	// doubled on each retry
+	defaultRetryAfter = time.Second
+	// maxRetryAfter caps the wait after a 429 response, so a bogus Retry-After can't stall the router
+	maxRetryAfter = 5 * time.Minute
+)
+
+// rateLimiter is a token bucket limiting the rate of requests to a Jira instance.
+// Requests wait for a token rather than failing, so bursts of alerts are queued.
+// The limiter can also be paused, when Jira responds with 429 Too Many Requests.
+type rateLimiter struct {
+	mu          sync.Mutex
+	interval    time.Duration
+	burst       float64
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+}
+
+func newRateLimiter(requestsPerSecond float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	l := &rateLimiter{burst: float64(burst), tokens: float64(burst), last: time.Now()}
+	if requestsPerSecond > 0 {
+		l.interval = time.Duration(float64(time.Second) / requestsPerSecond)
+	}
+	return l
+}
+
+// reserve takes a token if one is available, or returns how long to wait before trying again
+func (l *rateLimiter) reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Before(l.pausedUntil) {
+		return l.pausedUntil.Sub(now)
+	}
+	if l.interval == 0 {
+		return 0
+	}
+
+	l.tokens += float64(now.Sub(l.last)) / float64(l.interval)
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+	return time.Duration((1 - l.tokens) * float64(l.interval))
+}
+
+// wait blocks until a request may be sent, or the request is cancelled
+func (l *rateLimiter) wait(req *http.Request) error {
+	for {
+		d := l.reserve(time.Now())
+		if d <= 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(d)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// pause stops all requests to the instance until the given time
+func (l *rateLimiter) pause(until time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// retryBacklog is the number of requests waiting to be retried after being rate limited, across the Jira instances
+var retryBacklog atomic.Int64
+
+// RetryBacklog returns the number of Jira requests waiting to be retried after being rate limited
+func RetryBacklog() int {
+	return int(retryBacklog.Load())
+}
+
+var (
+	rateLimitersMutex sync.Mutex
+	rateLimiters      = map[string]*rateLimiter{}
+)
+
+// rateLimiterFor returns the rate limiter shared by all clients of the Jira instance,
+// as the router creates a new client for each alert
+func rateLimiterFor(jiraConfig config.JiraConfig) *rateLimiter {
+	rateLimitersMutex.Lock()
+	defer rateLimitersMutex.Unlock()
+
+	l, ok := rateLimiters[jiraConfig.Host]
+	if !ok {
+		l = newRateLimiter(jiraConfig.RateLimit, jiraConfig.RateLimitBurst)
+		rateLimiters[jiraConfig.Host] = l
+	}
+	return l
+}
+
+// rateLimitTransport rate limits the requests sent through the wrapped transport and
+// retries requests that Jira rejected with 429 Too Many Requests after the Retry-After delay
+type rateLimitTransport struct {
+	transport  http.RoundTripper
+	limiter    *rateLimiter
+	maxRetries int
+}
+
+// RoundTrip implements the http.RoundTripper interface
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		err := t.limiter.wait(req)
+		if attempt > 0 {
+			retryBacklog.Add(-1)
+			metrics.MetricJiraRetryBacklog.Dec()
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// Round trippers must not modify the request, so retries are sent as clones with a new body
+		attemptReq := req
+		if attempt > 0 {
+			attemptReq = req.Clone(req.Context())
+			if req.Body != nil {
+				if req.GetBody == nil {
+					return nil, fmt.Errorf("cannot retry rate limited request to %v: request body can't be replayed", req.URL)
+				}
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				attemptReq.Body = body
+			}
+		}
+
+		resp, err := t.transport.RoundTrip(attemptReq)
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests || attempt >= t.maxRetries {
+			return resp, err
+		}
+
+		wait := retryAfter(resp.Header.Get("Retry-After"), attempt, time.Now())
+		log.Printf("jira.RoundTrip(): rate limited by Jira, retrying %v %v in %v\n", req.Method, req.URL.Path, wait)
+
+		// Drain the body so the connection can be reused
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		t.limiter.pause(time.Now().Add(wait))
+		retryBacklog.Add(1)
+		metrics.MetricJiraRetryBacklog.Inc()
+	}
+}
+
+// retryAfter returns how long to wait before retrying, from a Retry-After header in either
+// seconds or HTTP date format, or an exponential backoff when the header is missing or invalid
+func retryAfter(header string, attempt int, now time.Time) time.Duration {
+	wait := defaultRetryAfter << attempt
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		wait = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(header); err == nil {
+		wait = date.Sub(now)
+	}
+
+	if wait < 0 {
+		wait = 0
+	}
+	if wait > maxRetryAfter {
+		wait = maxRetryAfter
+	}
+	return wait
+}
+
+// rateLimited wraps the client's transport with the shared rate limiter of the Jira instance
+func rateLimited(client *http.Client, jiraConfig config.JiraConfig) *http.Client {
+	transport := client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	client.Transport = &rateLimitTransport{
+		transport:  transport,
+		limiter:    rateLimiterFor(jiraConfig),
+		maxRetries: jiraConfig.MaxRetries,
+	}
+	return client
+}
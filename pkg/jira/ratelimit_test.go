@@ -0,0 +1,119 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jira
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		header  string
+		attempt int
+		want    time.Duration
+	}{
+		{"seconds", "7", 0, 7 * time.Second},
+		{"http date", now.Add(30 * time.Second).Format(http.TimeFormat), 0, 30 * time.Second},
+		{"date in the past", now.Add(-time.Minute).Format(http.TimeFormat), 0, 0},
+		{"missing header backs off", "", 2, 4 * time.Second},
+		{"capped", "86400", 0, maxRetryAfter},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryAfter(tt.header, tt.attempt, now); got != tt.want {
+				t.Errorf("retryAfter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimiterReserve(t *testing.T) {
+	l := newRateLimiter(10, 2)
+	now := l.last
+
+	for i := 0; i < 2; i++ {
+		if d := l.reserve(now); d != 0 {
+			t.Fatalf("reserve() within burst = %v, want 0", d)
+		}
+	}
+	if d := l.reserve(now); d != 100*time.Millisecond {
+		t.Errorf("reserve() after burst = %v, want 100ms", d)
+	}
+	if d := l.reserve(now.Add(100 * time.Millisecond)); d != 0 {
+		t.Errorf("reserve() after refill = %v, want 0", d)
+	}
+
+	l.pause(now.Add(time.Second))
+	if d := l.reserve(now.Add(500 * time.Millisecond)); d != 500*time.Millisecond {
+		t.Errorf("reserve() while paused = %v, want 500ms", d)
+	}
+}
+
+func TestRateLimitTransportRetries(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if len(bodies) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	client := rateLimited(&http.Client{}, config.JiraConfig{Host: server.URL, MaxRetries: 1})
+	req, err := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(`{"fields":{}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := req.Body
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	// The retry is sent as a clone of the caller's request
+	if req.Body != body {
+		t.Errorf("the request body was replaced by the retry")
+	}
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("status = %v, want %v", resp.StatusCode, http.StatusCreated)
+	}
+	if len(bodies) != 2 || bodies[1] != `{"fields":{}}` {
+		t.Errorf("request bodies = %v, want the body sent twice", bodies)
+	}
+	if backlog := testutil.ToFloat64(metrics.MetricJiraRetryBacklog); backlog != 0 {
+		t.Errorf("retry backlog = %v after the retry, want 0", backlog)
+	}
+}
@@ -0,0 +1,175 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jira
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strconv"
+	"text/template"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/google/uuid"
+	"github.com/openshift/compliance-audit-router/pkg/businesshours"
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/helpers"
+	"github.com/openshift/compliance-audit-router/pkg/metrics"
+)
+
+// ReminderData is the data available to the reminder template
+type ReminderData struct {
+	// Assignee is a Jira mention of the current assignee
+	Assignee string
+	IssueKey string
+	// IdleFor is how long the issue has been inactive
+	IdleFor time.Duration
+	// Count is the number of this reminder, starting at 1
+	Count int
+}
+
+// SendReminders posts a reminder comment mentioning the assignee on every managed issue that has
+// been inactive for the configured duration, up to the configured number of reminders per issue.
+// With business hours enabled, only business hours count as inactive and reminders are only posted
+// during business hours. It is run periodically by the scheduler.
+func SendReminders() {
+	reminderConfig := config.AppConfig().ReminderConfig
+	labels := map[string]string{"uuid": uuid.New().String(), "process": "SendReminders"}
+	now := time.Now()
+
+	reminderTemplate, err := helpers.ParseTemplate("reminderTemplate", reminderConfig.Template)
+	if err != nil {
+		log.Printf("jira.SendReminders(): failed to parse reminder template: %v\n", err)
+		return
+	}
+
+	var calendar *businesshours.Calendar
+	if config.AppConfig().BusinessHoursConfig.Enabled {
+		calendar, err = businesshours.New(config.AppConfig().BusinessHoursConfig)
+		if err != nil {
+			log.Printf("jira.SendReminders(): failed to load the business hours: %v\n", err)
+			return
+		}
+		if !calendar.IsOpen(now) {
+			log.Printf("jira.SendReminders(): outside business hours; not posting reminders")
+			return
+		}
+	}
+
+	checked := map[string]bool{}
+	for _, jiraConfig := range config.AppConfig().RoutedJiraConfigs() {
+		id := jiraConfig.Host + "/" + jiraConfig.Key
+		if checked[id] {
+			continue
+		}
+		checked[id] = true
+
+		client, err := NewClient(jiraConfig)
+		if err != nil {
+			log.Printf("jira.SendReminders(): failed creating Jira client for %v: %v\n", jiraConfig.Host, err)
+			metrics.MetricJiraClientCreateFailures.With(labels).Inc()
+			continue
+		}
+
+		jql := fmt.Sprintf(`project = "%s" AND labels = "%s" AND statusCategory != Done AND updated <= "-%dm"`,
+			jiraConfig.Key, labelsFor(jiraConfig).managed(), int(reminderConfig.IdleFor.Minutes()))
+		err = client.Issue.SearchPages(jql, &jira.SearchOptions{Fields: []string{"assignee", "labels", "updated"}}, func(issue jira.Issue) error {
+			if !idleFor(calendar, issue, reminderConfig.IdleFor, now) {
+				return nil
+			}
+			if err := sendReminder(client, jiraConfig, reminderConfig, reminderTemplate, issue); err != nil {
+				log.Printf("jira.SendReminders(): failed to remind assignee of issue %v: %v\n", issue.Key, err)
+				metrics.MetricJiraReminderFailures.With(labels).Inc()
+				return nil
+			}
+			metrics.MetricJiraRemindersSent.With(labels).Inc()
+			return nil
+		})
+		if err != nil {
+			log.Printf("jira.SendReminders(): failed to search for idle issues in project %v: %v\n", jiraConfig.Key, err)
+			metrics.MetricJiraReminderFailures.With(labels).Inc()
+		}
+	}
+}
+
+// sendReminder posts the next reminder on the issue, unless it is unassigned or has had the
+// maximum number of reminders, and records the reminder count in the issue labels
+func sendReminder(client *jira.Client, jiraConfig config.JiraConfig, reminderConfig config.ReminderConfig, reminderTemplate *template.Template, issue jira.Issue) error {
+	if issue.Fields == nil || issue.Fields.Assignee == nil {
+		return nil
+	}
+
+	labels := labelsFor(jiraConfig)
+	countLabel, count := reminderCount(labels, issue.Fields.Labels)
+	if count >= reminderConfig.MaxCount {
+		return nil
+	}
+	count++
+
+	var message bytes.Buffer
+	err := reminderTemplate.Execute(&message, ReminderData{
+		Assignee: fmt.Sprintf("[~accountid:%v]", issue.Fields.Assignee.AccountID),
+		IssueKey: issue.Key,
+		IdleFor:  reminderConfig.IdleFor,
+		Count:    count,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to apply reminder template: %w", err)
+	}
+
+	if config.AppConfig().DryRunComments() {
+		log.Printf("jira.sendReminder(): dry-run mode: would have posted reminder %v on issue %v: %v", count, issue.Key, message.String())
+		return nil
+	}
+
+	if err = addComment(client, jiraConfig.DocumentFormat, issue.ID, message.String()); err != nil {
+		return err
+	}
+
+	labelUpdates := []map[string]string{{"add": labels.reminders(count)}}
+	if countLabel != "" {
+		labelUpdates = append(labelUpdates, map[string]string{"remove": countLabel})
+	}
+	if _, err = client.Issue.UpdateIssue(issue.ID, map[string]interface{}{
+		"update": map[string]interface{}{"labels": labelUpdates},
+	}); err != nil {
+		return fmt.Errorf("reminder %v was posted but failed to update the reminder count label: %w", count, err)
+	}
+
+	log.Printf("jira.sendReminder(): posted reminder %v on issue %v", count, issue.Key)
+	return nil
+}
+
+// idleFor reports whether the issue has been inactive for the duration, counting only business hours
+// when a calendar is given. The search only returns issues inactive for the duration, counting all hours.
+func idleFor(calendar *businesshours.Calendar, issue jira.Issue, idle time.Duration, now time.Time) bool {
+	if calendar == nil || issue.Fields == nil {
+		return true
+	}
+	return calendar.Elapsed(time.Time(issue.Fields.Updated), now) >= idle
+}
+
+// reminderCount returns the reminder count label and the number of reminders it records
+func reminderCount(scheme labelScheme, labels []string) (string, int) {
+	if label, value, ok := scheme.value(labels, reminderLabelName); ok {
+		if count, err := strconv.Atoi(value); err == nil {
+			return label, count
+		}
+	}
+	return "", 0
+}
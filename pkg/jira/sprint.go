@@ -0,0 +1,75 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jira
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/openshift/compliance-audit-router/pkg/config"
+)
+
+const activeSprintState = "active"
+
+// sprintFor returns the ID of the sprint new issues should be added to: the configured sprint,
+// or the active sprint of the configured board. It returns 0 when neither is configured.
+func sprintFor(client *jira.Client, jiraConfig config.JiraConfig) (int, error) {
+	if jiraConfig.Sprint != 0 {
+		return jiraConfig.Sprint, nil
+	}
+	if jiraConfig.Board == 0 {
+		return 0, nil
+	}
+
+	sprints, _, err := client.Board.GetAllSprintsWithOptions(jiraConfig.Board, &jira.GetAllSprintsOptions{State: activeSprintState})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get active sprints of board %v: %w", jiraConfig.Board, err)
+	}
+	if len(sprints.Values) == 0 {
+		return 0, fmt.Errorf("board %v has no active sprint", jiraConfig.Board)
+	}
+
+	// Boards with parallel sprints may have several active sprints; use the earliest one
+	return sprints.Values[0].ID, nil
+}
+
+// addToSprint adds the created issue to the configured sprint, or the active sprint of the configured board
+func addToSprint(client *jira.Client, jiraConfig config.JiraConfig, createdIssue *jira.Issue) error {
+	if jiraConfig.Sprint == 0 && jiraConfig.Board == 0 {
+		return nil
+	}
+
+	if config.AppConfig().DryRunUpdates() {
+		log.Printf("jira.addToSprint(): dry-run mode: would have added issue %v to sprint %v of board %v", createdIssue.Key, jiraConfig.Sprint, jiraConfig.Board)
+		return nil
+	}
+
+	sprintID, err := sprintFor(client, jiraConfig)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.Sprint.MoveIssuesToSprint(sprintID, []string{createdIssue.Key})
+	if err != nil {
+		return fmt.Errorf("failed to add issue %v to sprint %v: %w", createdIssue.Key, sprintID, err)
+	}
+
+	log.Printf("jira.addToSprint(): added issue %v to sprint %v", createdIssue.Key, sprintID)
+
+	return nil
+}
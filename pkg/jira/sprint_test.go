@@ -0,0 +1,92 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jira
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+)
+
+func TestSprintFor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("state") != activeSprintState {
+			t.Errorf("unexpected sprint state filter: %v", r.URL.Query().Get("state"))
+		}
+		switch r.URL.Path {
+		case "/rest/agile/1.0/board/1/sprint":
+			_, _ = w.Write([]byte(`{"values":[{"id":42,"state":"active"},{"id":43,"state":"active"}]}`))
+		case "/rest/agile/1.0/board/2/sprint":
+			_, _ = w.Write([]byte(`{"values":[]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		jiraConfig config.JiraConfig
+		want       int
+		wantErr    bool
+	}{
+		{
+			name:       "no board or sprint",
+			jiraConfig: config.JiraConfig{Host: server.URL},
+			want:       0,
+		},
+		{
+			name:       "configured sprint takes precedence",
+			jiraConfig: config.JiraConfig{Host: server.URL, Board: 1, Sprint: 7},
+			want:       7,
+		},
+		{
+			name:       "active sprint of the board",
+			jiraConfig: config.JiraConfig{Host: server.URL, Board: 1},
+			want:       42,
+		},
+		{
+			name:       "board without an active sprint",
+			jiraConfig: config.JiraConfig{Host: server.URL, Board: 2},
+			wantErr:    true,
+		},
+		{
+			name:       "unknown board",
+			jiraConfig: config.JiraConfig{Host: server.URL, Board: 3},
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewClient(tt.jiraConfig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := sprintFor(client, tt.jiraConfig)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("sprintFor() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("sprintFor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jira
+
+import (
+	"log"
+	"strings"
+
+	"github.com/openshift/compliance-audit-router/pkg/helpers"
+	"github.com/openshift/compliance-audit-router/pkg/splunk"
+)
+
+// maxSummaryLength is the maximum length of a Jira issue summary
+const maxSummaryLength = 255
+
+// summary renders the issue summary from the summary template and the alert details.
+// Summaries are plain text, so text/template is used rather than html/template, which
+// would escape characters such as & in the alert fields.
+func summary(summaryTemplate string, alert splunk.AlertDetails) string {
+	tmpl, err := helpers.ParseTemplate("summaryTemplate", summaryTemplate)
+	if err != nil {
+		log.Printf("jira.summary(): failed to parse summary template, using the default summary: %v\n", err)
+		return ticketSummary
+	}
+
+	var s strings.Builder
+	if err := tmpl.Execute(&s, alert); err != nil {
+		log.Printf("jira.summary(): failed to render summary template, using the default summary: %v\n", err)
+		return ticketSummary
+	}
+
+	// Jira summaries must be a single line
+	rendered := strings.Join(strings.Fields(s.String()), " ")
+	if rendered == "" {
+		return ticketSummary
+	}
+	if runes := []rune(rendered); len(runes) > maxSummaryLength {
+		rendered = string(runes[:maxSummaryLength-3]) + "..."
+	}
+
+	return rendered
+}
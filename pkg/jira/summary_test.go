@@ -0,0 +1,85 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jira
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/openshift/compliance-audit-router/pkg/splunk"
+)
+
+func TestSummary(t *testing.T) {
+	const defaultTemplate = "Compliance Alert: SRE Cluster Admin Elevation" +
+		"{{with .User}} by {{.}}{{end}}" +
+		"{{with .ClusterIDs}} on {{range $i, $id := .}}{{if $i}}, {{end}}{{$id}}{{end}}{{end}}"
+
+	tests := []struct {
+		name     string
+		template string
+		alert    splunk.AlertDetails
+		want     string
+	}{
+		{
+			name:     "alert details",
+			template: defaultTemplate,
+			alert:    splunk.AlertDetails{User: "jdoe", ClusterIDs: []string{"abc", "def"}},
+			want:     "Compliance Alert: SRE Cluster Admin Elevation by jdoe on abc, def",
+		},
+		{
+			name:     "error ticket without alert details",
+			template: defaultTemplate,
+			want:     "Compliance Alert: SRE Cluster Admin Elevation",
+		},
+		{
+			name:     "multi-line template",
+			template: "{{.AlertName}}\n{{.Group}} & co",
+			alert:    splunk.AlertDetails{AlertName: "BreakGlass", Group: "sre"},
+			want:     "BreakGlass sre & co",
+		},
+		{
+			name:     "sprig functions",
+			template: `{{.AlertName | upper}} by {{.User | default "unknown"}} on {{join ", " .ClusterIDs}}`,
+			alert:    splunk.AlertDetails{AlertName: "BreakGlass", ClusterIDs: []string{"abc", "def"}},
+			want:     "BREAKGLASS by unknown on abc, def",
+		},
+		{
+			name:     "environment functions are unavailable",
+			template: `{{env "CAR_JIRACONFIG_TOKEN"}}`,
+			want:     ticketSummary,
+		},
+		{
+			name:     "invalid template",
+			template: "{{.Missing",
+			want:     ticketSummary,
+		},
+		{
+			name:     "long summary",
+			template: "{{.ClusterText}}",
+			alert:    splunk.AlertDetails{ClusterText: strings.Repeat("x", 300)},
+			want:     strings.Repeat("x", 252) + "...",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := summary(tt.template, tt.alert); got != tt.want {
+				t.Errorf("summary() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
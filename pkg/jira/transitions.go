@@ -0,0 +1,81 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jira
+
+import (
+	"sync"
+
+	"github.com/andygrunwald/go-jira"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+)
+
+var (
+	transitionIDsMutex sync.Mutex
+	// transitionIDs are the IDs of the transitions to each status, by Jira instance, project and issue type
+	transitionIDs = map[string]string{}
+)
+
+func transitionKey(jiraConfig config.JiraConfig, status string) string {
+	return jiraConfig.Host + "\x00" + jiraConfig.Key + "\x00" + jiraConfig.IssueType + "\x00" + status
+}
+
+// transitionID returns the ID of the transition to the status, looking up the transitions of the issue
+// once per project and issue type. The transition IDs of a workflow are the same for all its issues.
+func transitionID(client *jira.Client, jiraConfig config.JiraConfig, issueId string, status string) (string, error) {
+	if config.AppConfig().DryRun {
+		return getTransitionId(client.Issue, issueId, status)
+	}
+
+	key := transitionKey(jiraConfig, status)
+	transitionIDsMutex.Lock()
+	id, ok := transitionIDs[key]
+	transitionIDsMutex.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	id, err := getTransitionId(client.Issue, issueId, status)
+	if err != nil {
+		return "", err
+	}
+
+	transitionIDsMutex.Lock()
+	transitionIDs[key] = id
+	transitionIDsMutex.Unlock()
+	return id, nil
+}
+
+// doTransition applies the transition to the status on the issue. When Jira rejects a cached transition ID,
+// eg. because the workflow changed, the ID is looked up again and the transition retried with the new ID.
+func doTransition(client *jira.Client, jiraConfig config.JiraConfig, issueId string, status string, id string) error {
+	_, err := client.Issue.DoTransition(issueId, id)
+	if err == nil {
+		return nil
+	}
+
+	transitionIDsMutex.Lock()
+	delete(transitionIDs, transitionKey(jiraConfig, status))
+	transitionIDsMutex.Unlock()
+
+	current, lookupErr := transitionID(client, jiraConfig, issueId, status)
+	if lookupErr != nil || current == id {
+		return err
+	}
+	_, err = client.Issue.DoTransition(issueId, current)
+	return err
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jira
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+)
+
+func TestTransitionIDsAreCached(t *testing.T) {
+	var lookups int32
+	// The ID of the transition to Done changes, eg. after the workflow is edited
+	doneID := "31"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/transitions"):
+			atomic.AddInt32(&lookups, 1)
+			_, _ = w.Write([]byte(`{"transitions":[{"id":"` + doneID + `","name":"Done"}]}`))
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/transitions"):
+			var body struct {
+				Transition struct {
+					ID string `json:"id"`
+				} `json:"transition"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if body.Transition.ID != doneID {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	jiraConfig := config.JiraConfig{Host: server.URL, Key: "CAR", IssueType: "Task"}
+	client, err := NewClient(jiraConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, issue := range []string{"1", "2"} {
+		id, err := transitionID(client, jiraConfig, issue, "Done")
+		if err != nil {
+			t.Fatalf("transitionID() error = %v", err)
+		}
+		if err := doTransition(client, jiraConfig, issue, "Done", id); err != nil {
+			t.Fatalf("doTransition() error = %v", err)
+		}
+	}
+	if lookups != 1 {
+		t.Errorf("the transitions were looked up %d times, want once", lookups)
+	}
+
+	// A rejected cached ID is looked up again
+	doneID = "41"
+	id, _ := transitionID(client, jiraConfig, "3", "Done")
+	if err := doTransition(client, jiraConfig, "3", "Done", id); err != nil {
+		t.Errorf("doTransition() with a changed transition ID error = %v", err)
+	}
+	if id, _ := transitionID(client, jiraConfig, "4", "Done"); id != "41" {
+		t.Errorf("transitionID() = %v after the ID changed, want 41", id)
+	}
+}
+
+func TestGetTransitionIdPrefersNames(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		// Reopening leads to the status another transition is named after
+		_, _ = w.Write([]byte(`{"transitions":[{"id":"11","name":"Reopen","to":{"name":"In Progress"}},` +
+			`{"id":"21","name":"In Progress","to":{"name":"Started"}},{"id":"31","name":"Approve","to":{"name":"Done"}}]}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(config.JiraConfig{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		status string
+		want   string
+	}{
+		{"In Progress", "21"},
+		{"Done", "31"},
+	}
+	for _, tt := range tests {
+		if got, err := getTransitionId(client.Issue, "1", tt.status); err != nil || got != tt.want {
+			t.Errorf("getTransitionId(%v) = %v, %v, want %v", tt.status, got, err, tt.want)
+		}
+	}
+	if _, err := getTransitionId(client.Issue, "1", "Closed"); err == nil {
+		t.Errorf("getTransitionId() expected an error for a status without a transition")
+	}
+}
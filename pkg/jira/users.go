@@ -0,0 +1,144 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jira
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/logging"
+	"github.com/openshift/compliance-audit-router/pkg/metrics"
+)
+
+// maxCachedUsers is the number of Jira accounts cached; when the cache is full, the entry closest to
+// expiring is evicted
+const maxCachedUsers = 1000
+
+// credentials identifies the account the router authenticates to a Jira instance as
+type credentials struct {
+	host     string
+	username string
+	token    string
+}
+
+// cachedUser is the Jira account of a username, found on a Jira instance
+type cachedUser struct {
+	user    jira.User
+	expires time.Time
+}
+
+var (
+	usersMutex sync.Mutex
+	// reporters are the accounts the router authenticates as, which report its tickets. They are kept until
+	// the process exits; rotated credentials are looked up again.
+	reporters = map[credentials]jira.User{}
+	// users are the accounts of the usernames found on each Jira instance, by host and username
+	users = map[string]cachedUser{}
+)
+
+// reporterFor returns the Jira user the client authenticates as, looking it up on the instance once
+func reporterFor(client *jira.Client, jiraConfig config.JiraConfig) (*jira.User, error) {
+	key := credentials{host: jiraConfig.Host, username: jiraConfig.Username, token: jiraConfig.Token}
+
+	usersMutex.Lock()
+	reporter, ok := reporters[key]
+	usersMutex.Unlock()
+	if ok {
+		return &reporter, nil
+	}
+
+	self, _, err := client.User.GetSelf()
+	if err != nil {
+		return nil, err
+	}
+	rememberReporter(jiraConfig, self)
+	return self, nil
+}
+
+// rememberReporter caches the Jira user the router authenticates to the instance as
+func rememberReporter(jiraConfig config.JiraConfig, self *jira.User) {
+	usersMutex.Lock()
+	defer usersMutex.Unlock()
+
+	reporters[credentials{host: jiraConfig.Host, username: jiraConfig.Username, token: jiraConfig.Token}] = *self
+}
+
+// getUserByName returns the only Jira user found for the username, caching it for jiraconfig.usercachettl.
+// Users that aren't found, or that are found more than once, are looked up again every time.
+func getUserByName(client *jira.Client, jiraConfig config.JiraConfig, username string) (*jira.User, error) {
+	if username == "" {
+		logging.Debugf(logging.Jira, "jira.getUserByName() called with empty username")
+	}
+
+	key := jiraConfig.Host + "\x00" + username
+	now := time.Now()
+	if jiraConfig.UserCacheTTL > 0 {
+		usersMutex.Lock()
+		cached, ok := users[key]
+		if ok && !now.Before(cached.expires) {
+			delete(users, key)
+			ok = false
+		}
+		usersMutex.Unlock()
+		if ok {
+			metrics.MetricJiraUserCacheHits.Inc()
+			return &cached.user, nil
+		}
+		metrics.MetricJiraUserCacheMisses.Inc()
+	}
+
+	found, _, err := client.User.Find(username)
+	if err != nil {
+		return nil, err
+	}
+	if jiraUserLen := len(found); jiraUserLen != 1 {
+		return nil, fmt.Errorf("error finding user '%v': expected 1 user but found %v", username, jiraUserLen)
+	}
+
+	if jiraConfig.UserCacheTTL > 0 {
+		usersMutex.Lock()
+		if _, ok := users[key]; !ok && len(users) >= maxCachedUsers {
+			evictUsers(now)
+		}
+		users[key] = cachedUser{user: found[0], expires: now.Add(jiraConfig.UserCacheTTL)}
+		usersMutex.Unlock()
+	}
+	return &found[0], nil
+}
+
+// evictUsers removes the expired users from the cache, then the user closest to expiring
+// when the cache is still full. usersMutex must be held.
+func evictUsers(now time.Time) {
+	var oldest string
+	for key, cached := range users {
+		if !now.Before(cached.expires) {
+			delete(users, key)
+			continue
+		}
+		if oldest == "" || cached.expires.Before(users[oldest].expires) {
+			oldest = key
+		}
+	}
+
+	if len(users) >= maxCachedUsers {
+		delete(users, oldest)
+	}
+}
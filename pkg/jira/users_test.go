@@ -0,0 +1,73 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jira
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+)
+
+func TestUserLookupsAreCached(t *testing.T) {
+	var selfRequests, findRequests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/rest/api/2/myself":
+			atomic.AddInt32(&selfRequests, 1)
+			_, _ = w.Write([]byte(`{"accountId":"router-id"}`))
+		case "/rest/api/2/user/search":
+			atomic.AddInt32(&findRequests, 1)
+			if r.URL.Query().Get("query") == "sre" {
+				_, _ = w.Write([]byte(`[{"accountId":"sre-id"}]`))
+				return
+			}
+			_, _ = w.Write([]byte(`[]`))
+		}
+	}))
+	defer server.Close()
+
+	jiraConfig := config.JiraConfig{Host: server.URL, Token: "token", UserCacheTTL: time.Hour}
+	client, err := NewClient(jiraConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if reporter, err := reporterFor(client, jiraConfig); err != nil || reporter.AccountID != "router-id" {
+			t.Fatalf("reporterFor() = %v, %v, want router-id", reporter, err)
+		}
+		if user, err := getUserByName(client, jiraConfig, "sre"); err != nil || user.AccountID != "sre-id" {
+			t.Fatalf("getUserByName() = %v, %v, want sre-id", user, err)
+		}
+		if _, err := getUserByName(client, jiraConfig, "nobody"); err == nil {
+			t.Fatalf("getUserByName() found a user without a Jira account")
+		}
+	}
+
+	if selfRequests != 1 {
+		t.Errorf("the reporter was looked up %d times, want once", selfRequests)
+	}
+	// The user without an account is searched for every time
+	if findRequests != 3 {
+		t.Errorf("users were searched for %d times, want 3", findRequests)
+	}
+}
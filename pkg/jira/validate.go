@@ -0,0 +1,240 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jira
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/openshift/compliance-audit-router/pkg/config"
+)
+
+// projectStatuses is the response of the Jira project statuses endpoint,
+// listing the workflow statuses available to each issue type of a project
+type projectStatuses []struct {
+	Name     string `json:"name"`
+	Statuses []struct {
+		Name string `json:"name"`
+	} `json:"statuses"`
+}
+
+// workflowSchemes is the response of the Jira workflow scheme project associations endpoint, selecting the
+// workflow of each issue type of a project
+type workflowSchemes struct {
+	Values []struct {
+		WorkflowScheme struct {
+			DefaultWorkflow   string            `json:"defaultWorkflow"`
+			IssueTypeMappings map[string]string `json:"issueTypeMappings"`
+		} `json:"workflowScheme"`
+	} `json:"values"`
+}
+
+// workflows is the response of the Jira workflow search endpoint, with the transitions of each workflow
+// and the statuses they lead to
+type workflows struct {
+	Values []struct {
+		Transitions []struct {
+			Name string `json:"name"`
+			To   string `json:"to"`
+		} `json:"transitions"`
+		Statuses []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"statuses"`
+	} `json:"values"`
+}
+
+// ValidateConfig checks that the project, issue type and transitions of every Jira project
+// alerts may be routed to exist, so misconfiguration is found at startup rather than at the
+// first alert. All errors are logged together, like config.Valid().
+func ValidateConfig() bool {
+	var validationErrors []error
+
+	validated := map[string]bool{}
+	for _, jiraConfig := range config.AppConfig().RoutedJiraConfigs() {
+		// Tenants may configure their own transitions for the same project
+		id := fmt.Sprintf("%s/%s/%s/%v", jiraConfig.Host, jiraConfig.Key, jiraConfig.IssueType, jiraConfig.Transitions)
+		if !jiraConfig.ValidateOnStartup || validated[id] {
+			continue
+		}
+		validated[id] = true
+
+		client, err := NewClient(jiraConfig)
+		if err != nil {
+			validationErrors = append(validationErrors, fmt.Errorf("failed creating Jira client for %v: %w", jiraConfig.Host, err))
+			continue
+		}
+
+		validationErrors = append(validationErrors, validateProject(client, jiraConfig)...)
+
+		// The reporter of the tickets is looked up once, rather than with each ticket
+		if _, err := reporterFor(client, jiraConfig); err != nil {
+			log.Printf("WARNING: failed looking up the Jira reporter on %v; it is looked up with the first ticket: %v", jiraConfig.Host, err)
+		}
+	}
+
+	for _, e := range validationErrors {
+		log.Print(e)
+	}
+
+	return validationErrors == nil
+}
+
+// validateProject checks the project key, issue type and transition names of a single JiraConfig
+func validateProject(client *jira.Client, jiraConfig config.JiraConfig) []error {
+	var projectErrors []error
+
+	project, _, err := client.Project.Get(jiraConfig.Key)
+	if err != nil {
+		return append(projectErrors, fmt.Errorf("jira project %v not found on %v: %w", jiraConfig.Key, jiraConfig.Host, err))
+	}
+
+	issueTypeFound, issueTypeID := false, ""
+	for _, issueType := range project.IssueTypes {
+		if issueType.Name == jiraConfig.IssueType {
+			issueTypeFound, issueTypeID = true, issueType.ID
+			break
+		}
+	}
+	if !issueTypeFound {
+		return append(projectErrors, fmt.Errorf("jira issue type %v not found in project %v on %v", jiraConfig.IssueType, jiraConfig.Key, jiraConfig.Host))
+	}
+
+	available, err := workflowTransitions(client, project.ID, issueTypeID)
+	if err != nil {
+		// The workflow APIs are only available on Jira Cloud; Jira Server only lists the project's statuses
+		log.Printf("WARNING: failed to fetch the %v workflow of jira project %v on %v; checking the transitions against its statuses: %v", jiraConfig.IssueType, jiraConfig.Key, jiraConfig.Host, err)
+		available, err = issueTypeStatuses(client, jiraConfig)
+		if err != nil {
+			return append(projectErrors, err)
+		}
+	}
+
+	for _, key := range []string{initialTransitionKey, sreTransitionKey, managerTransitionKey} {
+		name := jiraConfig.Transitions[key]
+		if !available[name] {
+			projectErrors = append(projectErrors, fmt.Errorf("jira transition %v (%v) not found in the %v workflow of project %v on %v", key, name, jiraConfig.IssueType, jiraConfig.Key, jiraConfig.Host))
+		}
+	}
+
+	return projectErrors
+}
+
+// workflowTransitions returns the names of the transitions of the issue type's workflow, and of the statuses
+// they lead to, as issues are transitioned by either
+func workflowTransitions(client *jira.Client, projectID, issueTypeID string) (map[string]bool, error) {
+	req, err := client.NewRequest("GET", "rest/api/2/workflowscheme/project?projectId="+url.QueryEscape(projectID), nil)
+	if err != nil {
+		return nil, err
+	}
+	schemes := workflowSchemes{}
+	if _, err := client.Do(req, &schemes); err != nil {
+		return nil, fmt.Errorf("failed to fetch the workflow scheme: %w", err)
+	}
+	if len(schemes.Values) == 0 {
+		return nil, fmt.Errorf("no workflow scheme is associated with the project")
+	}
+	scheme := schemes.Values[0].WorkflowScheme
+	workflowName, ok := scheme.IssueTypeMappings[issueTypeID]
+	if !ok {
+		workflowName = scheme.DefaultWorkflow
+	}
+
+	req, err = client.NewRequest("GET", "rest/api/2/workflow/search?expand=transitions,statuses&workflowName="+url.QueryEscape(workflowName), nil)
+	if err != nil {
+		return nil, err
+	}
+	found := workflows{}
+	if _, err := client.Do(req, &found); err != nil {
+		return nil, fmt.Errorf("failed to fetch workflow %v: %w", workflowName, err)
+	}
+	if len(found.Values) == 0 {
+		return nil, fmt.Errorf("workflow %v not found", workflowName)
+	}
+
+	workflow := found.Values[0]
+	statusNames := map[string]string{}
+	for _, status := range workflow.Statuses {
+		statusNames[status.ID] = status.Name
+	}
+	available := map[string]bool{}
+	for _, transition := range workflow.Transitions {
+		available[transition.Name] = true
+		if name, ok := statusNames[transition.To]; ok {
+			available[name] = true
+		}
+	}
+	return available, nil
+}
+
+// issueTypeStatuses returns the names of the statuses of the project's workflow for the issue type
+func issueTypeStatuses(client *jira.Client, jiraConfig config.JiraConfig) (map[string]bool, error) {
+	req, err := client.NewRequest("GET", fmt.Sprintf("rest/api/2/project/%s/statuses", jiraConfig.Key), nil)
+	if err != nil {
+		return nil, err
+	}
+	statuses := projectStatuses{}
+	if _, err := client.Do(req, &statuses); err != nil {
+		return nil, fmt.Errorf("failed to fetch statuses of jira project %v on %v: %w", jiraConfig.Key, jiraConfig.Host, err)
+	}
+
+	available := map[string]bool{}
+	for _, issueType := range statuses {
+		if issueType.Name != jiraConfig.IssueType {
+			continue
+		}
+		for _, status := range issueType.Statuses {
+			available[status.Name] = true
+		}
+	}
+	return available, nil
+}
+
+// CheckConnection checks that the Jira API accepts the configured credentials and that
+// the user may create issues in the project
+func CheckConnection(jiraConfig config.JiraConfig) error {
+	client, err := NewClient(jiraConfig)
+	if err != nil {
+		return fmt.Errorf("failed creating Jira client: %w", err)
+	}
+
+	self, _, err := client.User.GetSelf()
+	if err != nil {
+		return fmt.Errorf("failed to get the authenticated user: %w", err)
+	}
+	rememberReporter(jiraConfig, self)
+
+	req, err := client.NewRequest("GET", fmt.Sprintf("rest/api/2/mypermissions?projectKey=%s&permissions=CREATE_ISSUES", jiraConfig.Key), nil)
+	if err != nil {
+		return err
+	}
+	var permissions struct {
+		Permissions map[string]struct {
+			HavePermission bool `json:"havePermission"`
+		} `json:"permissions"`
+	}
+	if _, err := client.Do(req, &permissions); err != nil {
+		return fmt.Errorf("failed to get the permissions of %s in project %s: %w", self.Name, jiraConfig.Key, err)
+	}
+	if !permissions.Permissions["CREATE_ISSUES"].HavePermission {
+		return fmt.Errorf("%s may not create issues in project %s", self.Name, jiraConfig.Key)
+	}
+
+	return nil
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jira
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+)
+
+const (
+	testProjectResponse  = `{"key":"OHSS","issueTypes":[{"name":"Task"}]}`
+	testStatusesResponse = `[{"name":"Task","statuses":[{"name":"In Progress"},{"name":"Pending Approval"},{"name":"Done"}]}]`
+)
+
+func TestValidateProject(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/rest/api/2/project/OHSS":
+			_, _ = w.Write([]byte(testProjectResponse))
+		case "/rest/api/2/project/OHSS/statuses":
+			_, _ = w.Write([]byte(testStatusesResponse))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	transitions := map[string]string{"initial": "In Progress", "sre": "Pending Approval", "manager": "Done"}
+
+	tests := []struct {
+		name       string
+		jiraConfig config.JiraConfig
+		wantErrs   int
+	}{
+		{
+			name:       "valid project, issue type and transitions",
+			jiraConfig: config.JiraConfig{Host: server.URL, Key: "OHSS", IssueType: "Task", Transitions: transitions},
+			wantErrs:   0,
+		},
+		{
+			name:       "unknown project",
+			jiraConfig: config.JiraConfig{Host: server.URL, Key: "NOPE", IssueType: "Task", Transitions: transitions},
+			wantErrs:   1,
+		},
+		{
+			name:       "unknown issue type",
+			jiraConfig: config.JiraConfig{Host: server.URL, Key: "OHSS", IssueType: "Bug", Transitions: transitions},
+			wantErrs:   1,
+		},
+		{
+			name:       "unknown transitions",
+			jiraConfig: config.JiraConfig{Host: server.URL, Key: "OHSS", IssueType: "Task", Transitions: map[string]string{"initial": "In Progress"}},
+			wantErrs:   2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewClient(tt.jiraConfig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := validateProject(client, tt.jiraConfig); len(got) != tt.wantErrs {
+				t.Errorf("validateProject() = %v, want %v errors", got, tt.wantErrs)
+			}
+		})
+	}
+}
+
+func TestValidateProjectWorkflow(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/rest/api/2/project/OHSS":
+			_, _ = w.Write([]byte(`{"id":"100","key":"OHSS","issueTypes":[{"id":"7","name":"Task"}]}`))
+		case "/rest/api/2/project/OHSS/statuses":
+			_, _ = w.Write([]byte(testStatusesResponse))
+		case "/rest/api/2/workflowscheme/project":
+			if r.URL.Query().Get("projectId") != "100" {
+				t.Errorf("unexpected workflow scheme query %v", r.URL.RawQuery)
+			}
+			_, _ = w.Write([]byte(`{"values":[{"workflowScheme":{"defaultWorkflow":"jira","issueTypeMappings":{"7":"SRE"}}}]}`))
+		case "/rest/api/2/workflow/search":
+			if r.URL.Query().Get("workflowName") != "SRE" {
+				t.Errorf("unexpected workflow query %v", r.URL.RawQuery)
+			}
+			// Done is a status of the project, but no transition of the workflow leads to it
+			_, _ = w.Write([]byte(`{"values":[{"transitions":[{"name":"Start","to":"3"},{"name":"Request Approval","to":"4"}],` +
+				`"statuses":[{"id":"3","name":"In Progress"},{"id":"4","name":"Pending Approval"},{"id":"5","name":"Done"}]}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient(config.JiraConfig{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		transitions map[string]string
+		wantErrs    int
+	}{
+		{"transitions by target status or name", map[string]string{"initial": "In Progress", "sre": "Request Approval", "manager": "Pending Approval"}, 0},
+		{"status without a transition to it", map[string]string{"initial": "In Progress", "sre": "Pending Approval", "manager": "Done"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jiraConfig := config.JiraConfig{Host: server.URL, Key: "OHSS", IssueType: "Task", Transitions: tt.transitions}
+			if got := validateProject(client, jiraConfig); len(got) != tt.wantErrs {
+				t.Errorf("validateProject() = %v, want %v errors", got, tt.wantErrs)
+			}
+		})
+	}
+}
+
+func TestCheckConnection(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/rest/api/2/myself":
+			_, _ = w.Write([]byte(`{"name":"car-bot"}`))
+		case r.URL.Path == "/rest/api/2/mypermissions" && r.URL.Query().Get("projectKey") == "OHSS":
+			_, _ = w.Write([]byte(`{"permissions":{"CREATE_ISSUES":{"havePermission":true}}}`))
+		case r.URL.Path == "/rest/api/2/mypermissions":
+			_, _ = w.Write([]byte(`{"permissions":{"CREATE_ISSUES":{"havePermission":false}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	if err := CheckConnection(config.JiraConfig{Host: server.URL, Key: "OHSS"}); err != nil {
+		t.Errorf("CheckConnection() unexpected error: %v", err)
+	}
+	if err := CheckConnection(config.JiraConfig{Host: server.URL, Key: "OTHER"}); err == nil {
+		t.Errorf("CheckConnection() expected an error without the create issues permission")
+	}
+}
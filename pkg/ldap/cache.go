@@ -0,0 +1,131 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ldap
+
+import (
+	"sync"
+	"time"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+)
+
+// cachedUser is the result of a successful user lookup
+type cachedUser struct {
+	username string
+	manager  string
+	expires  time.Time
+}
+
+// cache holds user lookup results for a limited time, as a user's manager rarely changes
+// and repeated alerts for the same user would otherwise each query the directory
+type cache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	maxSize int
+	entries map[string]cachedUser
+	hits    uint64
+	misses  uint64
+}
+
+// CacheStats is the size of the user lookup cache and the number of lookups answered from it
+type CacheStats struct {
+	Entries int    `json:"entries"`
+	Hits    uint64 `json:"hits"`
+	Misses  uint64 `json:"misses"`
+}
+
+func newCache(ttl time.Duration, maxSize int) *cache {
+	return &cache{ttl: ttl, maxSize: maxSize, entries: map[string]cachedUser{}}
+}
+
+// get returns the cached lookup result for the user, if there is one that hasn't expired
+func (c *cache) get(user string, now time.Time) (string, string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[user]
+	if !ok {
+		c.misses++
+		return "", "", false
+	}
+	if !now.Before(entry.expires) {
+		delete(c.entries, user)
+		c.misses++
+		return "", "", false
+	}
+	c.hits++
+	return entry.username, entry.manager, true
+}
+
+func (c *cache) stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return CacheStats{Entries: len(c.entries), Hits: c.hits, Misses: c.misses}
+}
+
+// set caches the lookup result for the user. When the cache is full, expired entries are
+// removed, then the entry closest to expiring, which is the oldest entry.
+func (c *cache) set(user, username, manager string, now time.Time) {
+	if c.ttl <= 0 || c.maxSize <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[user]; !ok && len(c.entries) >= c.maxSize {
+		c.evict(now)
+	}
+	c.entries[user] = cachedUser{username: username, manager: manager, expires: now.Add(c.ttl)}
+}
+
+func (c *cache) evict(now time.Time) {
+	var oldest string
+	for user, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, user)
+			continue
+		}
+		if oldest == "" || entry.expires.Before(c.entries[oldest].expires) {
+			oldest = user
+		}
+	}
+
+	if len(c.entries) >= c.maxSize {
+		delete(c.entries, oldest)
+	}
+}
+
+var (
+	defaultCacheOnce sync.Once
+	defaultCache     *cache
+)
+
+// lookupCache returns the cache of user lookup results
+func lookupCache() *cache {
+	defaultCacheOnce.Do(func() {
+		ldapConfig := config.AppConfig().LDAPConfig
+		defaultCache = newCache(ldapConfig.CacheTTL, ldapConfig.CacheSize)
+	})
+	return defaultCache
+}
+
+// LookupCacheStats returns the statistics of the user lookup cache since the router started
+func LookupCacheStats() CacheStats {
+	return lookupCache().stats()
+}
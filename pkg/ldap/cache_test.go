@@ -0,0 +1,58 @@
+// Copyright 2021-2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ldap
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	now := time.Now()
+
+	c := newCache(time.Hour, 2)
+	c.set("alice", "alice", "carol", now)
+	c.set("bob", "bob", "carol", now.Add(time.Minute))
+
+	if username, manager, ok := c.get("alice", now.Add(30*time.Minute)); !ok || username != "alice" || manager != "carol" {
+		t.Errorf("get() = %v, %v, %v, want alice, carol, true", username, manager, ok)
+	}
+	if _, _, ok := c.get("alice", now.Add(2*time.Hour)); ok {
+		t.Errorf("get() returned an expired entry")
+	}
+
+	// Adding to a full cache evicts the oldest entry
+	c.set("alice", "alice", "carol", now)
+	c.set("dave", "dave", "carol", now.Add(2*time.Minute))
+	if _, _, ok := c.get("alice", now.Add(3*time.Minute)); ok {
+		t.Errorf("set() did not evict the oldest entry")
+	}
+	if _, _, ok := c.get("bob", now.Add(3*time.Minute)); !ok {
+		t.Errorf("set() evicted a newer entry")
+	}
+
+	if stats := c.stats(); stats != (CacheStats{Entries: 2, Hits: 2, Misses: 2}) {
+		t.Errorf("stats() = %+v, want 2 entries, 2 hits and 2 misses", stats)
+	}
+}
+
+func TestCacheDisabled(t *testing.T) {
+	c := newCache(0, 10)
+	c.set("alice", "alice", "carol", time.Now())
+
+	if _, _, ok := c.get("alice", time.Now()); ok {
+		t.Errorf("get() returned an entry from a disabled cache")
+	}
+}
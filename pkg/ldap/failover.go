@@ -0,0 +1,107 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ldap
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	HostSelectionOrdered    = "ordered"
+	HostSelectionRoundRobin = "roundrobin"
+
+	// serverRetryAfter is how long a server that failed is skipped for, while other servers are available
+	serverRetryAfter = time.Minute
+)
+
+// serverList tracks the health of the configured LDAP servers, so connections are
+// opened to a healthy server when a directory replica is unavailable
+type serverList struct {
+	mu         sync.Mutex
+	hosts      []string
+	roundRobin bool
+	next       int
+	downUntil  map[string]time.Time
+}
+
+func newServerList(hosts []string, selection string) *serverList {
+	return &serverList{hosts: hosts, roundRobin: selection == HostSelectionRoundRobin, downUntil: map[string]time.Time{}}
+}
+
+// order returns the hosts in the order they should be tried: the healthy hosts, in configured
+// order or starting from the next host in turn, followed by the failed hosts as a last resort
+func (s *serverList) order(now time.Time) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	start := 0
+	if s.roundRobin && len(s.hosts) > 0 {
+		start = s.next % len(s.hosts)
+		s.next++
+	}
+
+	var healthy, down []string
+	for i := range s.hosts {
+		host := s.hosts[(start+i)%len(s.hosts)]
+		if now.Before(s.downUntil[host]) {
+			down = append(down, host)
+		} else {
+			healthy = append(healthy, host)
+		}
+	}
+	return append(healthy, down...)
+}
+
+// failed marks the host as down, so it is skipped while other hosts are available
+func (s *serverList) failed(host string, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.downUntil[host] = now.Add(serverRetryAfter)
+}
+
+// succeeded marks the host as healthy
+func (s *serverList) succeeded(host string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.downUntil, host)
+}
+
+// dial opens a connection to the first host that accepts one
+func (s *serverList) dial(dialHost func(host string) (conn, error)) (conn, error) {
+	var lastErr error
+	for _, host := range s.order(time.Now()) {
+		c, err := dialHost(host)
+		if err != nil {
+			log.Printf("ldap.dial(): failed to connect to LDAP server %v: %v", host, err)
+			s.failed(host, time.Now())
+			lastErr = err
+			continue
+		}
+		s.succeeded(host)
+		return c, nil
+	}
+
+	if lastErr == nil {
+		return nil, fmt.Errorf("no LDAP servers configured")
+	}
+	return nil, fmt.Errorf("failed to connect to any LDAP server: %w", lastErr)
+}
@@ -0,0 +1,66 @@
+// Copyright 2021-2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ldap
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestServerListOrder(t *testing.T) {
+	now := time.Now()
+	hosts := []string{"ldaps://a", "ldaps://b", "ldaps://c"}
+
+	ordered := newServerList(hosts, HostSelectionOrdered)
+	ordered.failed("ldaps://a", now)
+	if got, want := ordered.order(now), []string{"ldaps://b", "ldaps://c", "ldaps://a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("order() = %v, want %v", got, want)
+	}
+	if got := ordered.order(now.Add(2 * serverRetryAfter)); !reflect.DeepEqual(got, hosts) {
+		t.Errorf("order() after retry delay = %v, want %v", got, hosts)
+	}
+
+	roundRobin := newServerList(hosts, HostSelectionRoundRobin)
+	roundRobin.order(now)
+	if got, want := roundRobin.order(now), []string{"ldaps://b", "ldaps://c", "ldaps://a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("order() = %v, want %v", got, want)
+	}
+}
+
+func TestServerListDial(t *testing.T) {
+	servers := newServerList([]string{"ldaps://down", "ldaps://up"}, HostSelectionOrdered)
+
+	var tried []string
+	dialHost := func(host string) (conn, error) {
+		tried = append(tried, host)
+		if host == "ldaps://down" {
+			return nil, errors.New("connection refused")
+		}
+		return &fakeConn{}, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := servers.dial(dialHost); err != nil {
+			t.Fatalf("dial() unexpected error: %v", err)
+		}
+	}
+
+	// The failed server is skipped on the second dial
+	if want := []string{"ldaps://down", "ldaps://up", "ldaps://up"}; !reflect.DeepEqual(tried, want) {
+		t.Errorf("dial() tried %v, want %v", tried, want)
+	}
+}
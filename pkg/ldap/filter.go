@@ -0,0 +1,82 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ldap
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	"github.com/go-ldap/ldap"
+	"github.com/openshift/compliance-audit-router/pkg/config"
+)
+
+// FilterData is the data available to the search filter templates.
+// Username and Group are escaped for use in a filter. The config package validates the
+// templates with the same fields, in ldapFilterData.
+type FilterData struct {
+	Username string
+	Group    string
+
+	UIDAttribute     string
+	ManagerAttribute string
+	MailAttribute    string
+}
+
+// filterData returns the filter template data for the user and group
+func filterData(attributeMap config.LDAPAttributeMap, username, group string) FilterData {
+	return FilterData{
+		Username:         ldap.EscapeFilter(username),
+		Group:            ldap.EscapeFilter(group),
+		UIDAttribute:     attributeMap.UID,
+		ManagerAttribute: attributeMap.Manager,
+		MailAttribute:    attributeMap.Mail,
+	}
+}
+
+// renderFilter renders the search filter template with the data
+func renderFilter(filterTemplate string, data FilterData) (string, error) {
+	t, err := template.New("filter").Option("missingkey=error").Parse(filterTemplate)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse filter template: %w", err)
+	}
+
+	var filter bytes.Buffer
+	if err := t.Execute(&filter, data); err != nil {
+		return "", fmt.Errorf("failed to render filter template: %w", err)
+	}
+
+	return filter.String(), nil
+}
+
+// searchAttributes returns the configured attributes, adding the mapped attributes needed to read a user's entry
+func searchAttributes(attributes []string, attributeMap config.LDAPAttributeMap) []string {
+	searched := append([]string{}, attributes...)
+	for _, mapped := range []string{attributeMap.UID, attributeMap.Manager} {
+		found := false
+		for _, a := range searched {
+			if a == mapped {
+				found = true
+				break
+			}
+		}
+		if !found {
+			searched = append(searched, mapped)
+		}
+	}
+	return searched
+}
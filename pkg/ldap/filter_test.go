@@ -0,0 +1,79 @@
+// Copyright 2021-2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ldap
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+)
+
+func TestRenderFilter(t *testing.T) {
+	attributeMap := config.LDAPAttributeMap{UID: "sAMAccountName", Manager: "manager", Mail: "mail"}
+
+	tests := []struct {
+		name     string
+		template string
+		username string
+		group    string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "mapped uid attribute",
+			template: "({{.UIDAttribute}}={{.Username}})",
+			username: "avulaj",
+			want:     "(sAMAccountName=avulaj)",
+		},
+		{
+			name:     "username or mail",
+			template: "(|({{.UIDAttribute}}={{.Username}})({{.MailAttribute}}={{.Username}}))",
+			username: "avulaj@example.org",
+			want:     "(|(sAMAccountName=avulaj@example.org)(mail=avulaj@example.org))",
+		},
+		{
+			name:     "values are escaped",
+			template: "(&({{.UIDAttribute}}={{.Username}})(memberOf={{.Group}}))",
+			username: "*)(uid=*",
+			group:    "cn=sre",
+			want:     `(&(sAMAccountName=\2a\29\28uid=\2a)(memberOf=cn=sre))`,
+		},
+		{
+			name:     "unknown field",
+			template: "({{.Nope}})",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderFilter(tt.template, filterData(attributeMap, tt.username, tt.group))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("renderFilter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("renderFilter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchAttributes(t *testing.T) {
+	got := searchAttributes([]string{"manager", "alternateID"}, config.LDAPAttributeMap{UID: "uid", Manager: "manager"})
+	if want := []string{"manager", "alternateID", "uid"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("searchAttributes() = %v, want %v", got, want)
+	}
+}
@@ -17,12 +17,21 @@ limitations under the License.
 package ldap
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"time"
+
 	"github.com/go-ldap/ldap"
 	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/logging"
+	"github.com/openshift/compliance-audit-router/pkg/metrics"
 )
 
+var errUserNotFound = errors.New("user not found")
+
 type ConnectionLayer interface {
 	Close()
 	SimpleBind(ldapAddr string) (*ldap.Conn, error)
@@ -57,70 +66,155 @@ func (l *DataAccessLayer) Close() {
 //
 //}
 
-// LookupUser performs an LDAP query to find the user's supplemental ID and manager information
-func LookupUser(username string) (string, string, error) {
+// LookupUser finds the user's supplemental ID and manager information, from the lookup cache
+// or with an LDAP query, which is abandoned when the context is done
+func LookupUser(ctx context.Context, username string) (string, string, error) {
+	if ldapUsername, ldapManager, ok := lookupCache().get(username, time.Now()); ok {
+		metrics.MetricLDAPCacheHits.Inc()
+		return ldapUsername, ldapManager, nil
+	}
+	metrics.MetricLDAPCacheMisses.Inc()
 
-	conn, err := ldap.DialURL(config.AppConfig.LDAPConfig.Host)
-	defer conn.Close()
+	ldapUsername, ldapManager, err := lookupUser(ctx, username)
 	if err != nil {
 		return "", "", err
 	}
 
-	if config.AppConfig.LDAPConfig.Username != "" {
-		_, err = conn.SimpleBind(&ldap.SimpleBindRequest{
-			Username: config.AppConfig.LDAPConfig.Username,
-			Password: config.AppConfig.LDAPConfig.Password,
-		})
-	} else {
-		err = conn.UnauthenticatedBind("")
+	lookupCache().set(username, ldapUsername, ldapManager, time.Now())
+
+	return ldapUsername, ldapManager, nil
+}
+
+// lookupUser performs an LDAP query to find the user's supplemental ID and manager information.
+// Users identified by an email address are searched for with the mail filter first. Only addresses
+// in the domains of identityconfig.domainmap fall back to the user filter with their local part,
+// so an address in another domain doesn't resolve to the directory user of the same name.
+func lookupUser(ctx context.Context, username string) (string, string, error) {
+	ldapConfig := config.AppConfig().LDAPConfig
+	attributeMap := ldapConfig.AttributeMap
+
+	var entry *ldap.Entry
+	var err error
+
+	if at := strings.LastIndex(username, "@"); at >= 0 && attributeMap.Mail != "" {
+		entry, err = searchUser(ctx, ldapConfig.MailFilter, username)
+		if err != nil && !errors.Is(err, errUserNotFound) {
+			return "", "", err
+		}
+		if entry == nil && !slices.Contains(config.AppConfig().MappedDomains(), strings.ToLower(username[at+1:])) {
+			return "", "", fmt.Errorf("no user has the email address %s, whose domain isn't in identityconfig.domainmap: %w", username, err)
+		}
+		username = username[:at]
+	}
+
+	if entry == nil {
+		entry, err = searchUser(ctx, ldapConfig.UserFilter, username)
+		if err != nil {
+			return "", "", err
+		}
 	}
+
+	// Prefer the UID attribute, for directories whose DNs are not built from it
+	ldapUsername := entry.GetAttributeValue(attributeMap.UID)
+	if ldapUsername == "" {
+		ldapUsername, err = getUID(entry.DN, attributeMap.RDNAttributes())
+		if err != nil {
+			return "", "", errors.New("could not parse ldap username")
+		}
+	}
+	ldapManager, err := getUID(entry.GetAttributeValue(attributeMap.Manager), attributeMap.RDNAttributes())
 	if err != nil {
-		return "", "", err
+		return "", "", errors.New("could not parse manager's ldap username")
 	}
 
-	searchRequest := ldap.NewSearchRequest(config.AppConfig.LDAPConfig.SearchBase,
-		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
-		"(uid="+username+")", config.AppConfig.LDAPConfig.Attributes, nil)
+	return ldapUsername, ldapManager, nil
+}
 
-	result, err := conn.Search(searchRequest)
+// searchUser returns the single entry found by the filter template for the username
+func searchUser(ctx context.Context, filterTemplate, username string) (*ldap.Entry, error) {
+	ldapConfig := config.AppConfig().LDAPConfig
+
+	filter, err := renderFilter(filterTemplate, filterData(ldapConfig.AttributeMap, username, ""))
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 
-	var ldapUsername string
-	var ldapManager string
+	searchRequest := ldap.NewSearchRequest(ldapConfig.SearchBase,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		filter, searchAttributes(ldapConfig.Attributes, ldapConfig.AttributeMap), nil)
+	logging.Debugf(logging.LDAP, "ldap.searchUser(): searching %s with filter %s", ldapConfig.SearchBase, filter)
+
+	result, err := connectionPool().search(ctx, searchRequest)
+	if err != nil {
+		return nil, err
+	}
 
 	if len(result.Entries) == 0 {
-		return "", "", errors.New("user not found")
+		return nil, errUserNotFound
 	} else if len(result.Entries) > 1 {
-		return "", "", errors.New("multiple ldap entries found, please check your ldap config")
-	} else {
-		entry := result.Entries[0]
+		return nil, errors.New("multiple ldap entries found, please check your ldap config")
+	}
+	return result.Entries[0], nil
+}
 
-		ldapUsername, err = getUID(entry.DN)
-		if err != nil {
-			return "", "", errors.New("could not parse ldap username")
-		}
-		ldapManager, err = getUID(entry.GetAttributeValue("manager"))
-		if err != nil {
-			return "", "", errors.New("could not parse manager's ldap username")
-		}
+// CheckConnection binds to the first available LDAP server with a new connection and searches
+// for the search base, checking the credentials and that the search base can be read
+func CheckConnection() error {
+	ldapConfig := config.AppConfig().LDAPConfig
+
+	c, err := newServerList(ldapConfig.Servers(), ldapConfig.HostSelection).dial(dialAndBind)
+	if err != nil {
+		return err
 	}
+	defer c.Close()
 
-	return ldapUsername, ldapManager, nil
+	searchRequest := ldap.NewSearchRequest(ldapConfig.SearchBase,
+		ldap.ScopeBaseObject, ldap.NeverDerefAliases, 1, 10, false,
+		"(objectClass=*)", []string{"1.1"}, nil)
+	if _, err := c.Search(searchRequest); err != nil {
+		return fmt.Errorf("failed to search %s: %w", ldapConfig.SearchBase, err)
+	}
+	return nil
+}
+
+// IsGroupMember checks whether the user is a member of the group with the given DN,
+// using the group filter, by default the memberOf attribute of the user's entry
+func IsGroupMember(ctx context.Context, username, groupDN string) (bool, error) {
+	ldapConfig := config.AppConfig().LDAPConfig
+
+	filter, err := renderFilter(ldapConfig.GroupFilter, filterData(ldapConfig.AttributeMap, username, groupDN))
+	if err != nil {
+		return false, err
+	}
+
+	searchRequest := ldap.NewSearchRequest(ldapConfig.SearchBase,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		filter, []string{"dn"}, nil)
+	logging.Debugf(logging.LDAP, "ldap.IsGroupMember(): searching %s with filter %s", ldapConfig.SearchBase, filter)
+
+	result, err := connectionPool().search(ctx, searchRequest)
+	if err != nil {
+		return false, err
+	}
+
+	return len(result.Entries) > 0, nil
 }
 
-func getUID(dn string) (string, error) {
+// getUID returns the value of the first of the RDN attributes found in the DN.
+// Attribute types are matched case-insensitively, as Active Directory writes them in upper case.
+func getUID(dn string, rdnAttributes []string) (string, error) {
 	parsedDN, err := ldap.ParseDN(dn)
 	if err != nil {
 		return "", errors.New(fmt.Sprintf("error parsing dn: %v", err))
 	}
-	for _, rdn := range parsedDN.RDNs {
-		for _, attribute := range rdn.Attributes {
-			if attribute.Type == "uid" {
-				return attribute.Value, nil
+	for _, rdnAttribute := range rdnAttributes {
+		for _, rdn := range parsedDN.RDNs {
+			for _, attribute := range rdn.Attributes {
+				if strings.EqualFold(attribute.Type, rdnAttribute) {
+					return attribute.Value, nil
+				}
 			}
 		}
 	}
-	return "", errors.New("no uid field found for given ldap string")
+	return "", fmt.Errorf("no %s field found for given ldap string", strings.Join(rdnAttributes, " or "))
 }
@@ -15,10 +15,14 @@
 package ldap
 
 import (
+	"context"
 	"io"
 	"log"
 	"os"
 	"testing"
+
+	"github.com/go-ldap/ldap"
+	"github.com/openshift/compliance-audit-router/pkg/config"
 )
 
 // Silence logto
@@ -31,6 +35,7 @@ func TestGetUID(t *testing.T) {
 	tests := []struct {
 		name           string
 		input          string
+		attributes     []string
 		expectedResult string
 		expectedError  string
 	}{
@@ -53,11 +58,27 @@ func TestGetUID(t *testing.T) {
 			name:          "empty input",
 			expectedError: "no uid field found for given ldap string",
 		},
+		{
+			name:           "active directory dn",
+			input:          "CN=avulaj,OU=Users,DC=redhat,DC=com",
+			attributes:     []string{"sAMAccountName", "cn"},
+			expectedResult: "avulaj",
+		},
+		{
+			name:          "no configured attribute present",
+			input:         "ou=users,dc=redhat,dc=com",
+			attributes:    []string{"sAMAccountName", "cn"},
+			expectedError: "no sAMAccountName or cn field found for given ldap string",
+		},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			result, err := getUID(tc.input)
+			attributes := tc.attributes
+			if attributes == nil {
+				attributes = []string{"uid"}
+			}
+			result, err := getUID(tc.input, attributes)
 			if tc.expectedError != "" && err.Error() != tc.expectedError {
 				t.Fatalf("Did not receive the expected error.\nExpected: %v\nActual: %v", tc.expectedError, err.Error())
 			}
@@ -67,3 +88,60 @@ func TestGetUID(t *testing.T) {
 		})
 	}
 }
+
+// directoryConn answers searches with the entries of the search's filter
+type directoryConn map[string][]*ldap.Entry
+
+func (d directoryConn) Search(searchRequest *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	return &ldap.SearchResult{Entries: d[searchRequest.Filter]}, nil
+}
+
+func (d directoryConn) SearchWithPaging(searchRequest *ldap.SearchRequest, _ uint32) (*ldap.SearchResult, error) {
+	return d.Search(searchRequest)
+}
+
+func (directoryConn) Close() {}
+
+func TestLookupUserByEmail(t *testing.T) {
+	config.SetAppConfig(config.Config{
+		IdentityConfig: config.IdentityConfig{DomainMap: map[string]string{"corp.example.com": "example.org"}},
+		LDAPConfig: config.LDAPConfig{
+			UserFilter:   "(uid={{.Username}})",
+			MailFilter:   "(|(mail={{.Username}})(mailAlternateAddress={{.Username}}))",
+			AttributeMap: config.LDAPAttributeMap{UID: "uid", Manager: "manager", Mail: "mail"},
+		},
+	})
+	defer func() { config.SetAppConfig(config.Config{}) }()
+
+	entry := func(uid string) []*ldap.Entry {
+		return []*ldap.Entry{ldap.NewEntry("uid="+uid+",dc=example,dc=org", map[string][]string{
+			"uid":     {uid},
+			"manager": {"uid=boss,dc=example,dc=org"},
+		})}
+	}
+	directory := directoryConn{
+		"(|(mail=alias@example.net)(mailAlternateAddress=alias@example.net))": entry("avulaj"),
+		"(uid=avulaj)": entry("avulaj"),
+	}
+	defaultPoolOnce.Do(func() {})
+	defaultPool = newPool(1, 0, 0, func() (conn, error) { return directory, nil })
+
+	tests := []struct {
+		name     string
+		username string
+		want     string
+		wantErr  bool
+	}{
+		{"address found with the mail filter", "alias@example.net", "avulaj", false},
+		{"local part of an address in a mapped domain", "avulaj@example.org", "avulaj", false},
+		{"local part of an address in another domain", "avulaj@attacker.example", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, manager, err := lookupUser(context.Background(), tt.username)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("lookupUser(%q) = %q, %q, %v, want %q", tt.username, got, manager, err, tt.want)
+			}
+		})
+	}
+}
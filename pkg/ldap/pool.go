@@ -0,0 +1,280 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ldap
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/go-ldap/ldap"
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// healthCheckAfter is how long a connection may be idle before it is checked before reuse
+	healthCheckAfter = 30 * time.Second
+)
+
+// conn is the part of an LDAP connection used by the pool
+type conn interface {
+	Search(*ldap.SearchRequest) (*ldap.SearchResult, error)
+	SearchWithPaging(*ldap.SearchRequest, uint32) (*ldap.SearchResult, error)
+	Close()
+}
+
+// idleConn is a bound connection waiting in the pool
+type idleConn struct {
+	conn
+	since time.Time
+}
+
+// pool keeps bound LDAP connections open between lookups, so bursts of alerts don't
+// exhaust the directory's connections or pay the TLS handshake and bind for each event
+type pool struct {
+	mu   sync.Mutex
+	idle []idleConn
+	// slots limits the number of open connections; lookups wait for a free slot
+	slots chan struct{}
+
+	idleTimeout time.Duration
+	// pageSize is the number of entries requested per page of search results, 0 to disable paging
+	pageSize uint32
+	// dial opens and binds a new connection
+	dial func() (conn, error)
+}
+
+func newPool(size int, idleTimeout time.Duration, pageSize int, dial func() (conn, error)) *pool {
+	if size < 1 {
+		size = 1
+	}
+	if pageSize < 0 {
+		pageSize = 0
+	}
+	return &pool{slots: make(chan struct{}, size), idleTimeout: idleTimeout, pageSize: uint32(pageSize), dial: dial}
+}
+
+// search runs the search request on a pooled connection. When the connection turns out
+// to be broken, it is replaced by a newly dialled and bound connection and the search retried once.
+// When the context is done first, the connection is closed to abandon the search.
+func (p *pool) search(ctx context.Context, searchRequest *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-p.slots }()
+
+	c, reused, err := p.get(time.Now())
+	if err != nil {
+		metrics.MetricLDAPPoolHealthy.Set(0)
+		return nil, err
+	}
+
+	result, err := p.run(ctx, c, searchRequest)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		c.Close()
+		return nil, ctxErr
+	}
+	if err != nil && reused && ldap.IsErrorWithCode(err, ldap.ErrorNetwork) {
+		log.Printf("ldap.search(): pooled connection failed, rebinding: %v", err)
+		c.Close()
+		c, err = p.dial()
+		if err != nil {
+			metrics.MetricLDAPPoolHealthy.Set(0)
+			return nil, err
+		}
+		result, err = p.run(ctx, c, searchRequest)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			c.Close()
+			return nil, ctxErr
+		}
+	}
+
+	if err != nil && ldap.IsErrorWithCode(err, ldap.ErrorNetwork) {
+		metrics.MetricLDAPPoolHealthy.Set(0)
+		c.Close()
+		return nil, err
+	}
+
+	metrics.MetricLDAPPoolHealthy.Set(1)
+	p.put(c, time.Now())
+	return result, err
+}
+
+// searchResult is the outcome of a search run on a connection
+type searchResult struct {
+	result *ldap.SearchResult
+	err    error
+}
+
+// run runs the search request on the connection, collecting all pages of results when paging is enabled.
+// The LDAP client can't cancel a search, so when the context is done first the connection is closed,
+// which fails the search, and the connection must not be reused.
+func (p *pool) run(ctx context.Context, c conn, searchRequest *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	timer := prometheus.NewTimer(metrics.MetricLDAPSearchDuration)
+	defer timer.ObserveDuration()
+
+	done := make(chan searchResult, 1)
+	go func() {
+		var r searchResult
+		if p.pageSize == 0 {
+			r.result, r.err = c.Search(searchRequest)
+		} else {
+			r.result, r.err = c.SearchWithPaging(searchRequest, p.pageSize)
+		}
+		done <- r
+	}()
+
+	select {
+	case r := <-done:
+		return r.result, r.err
+	case <-ctx.Done():
+		c.Close()
+		// Wait for the failed search, so its goroutine doesn't outlive the request
+		<-done
+		return nil, ctx.Err()
+	}
+}
+
+// get returns an idle connection if a healthy one is available, or dials a new one.
+// It reports whether the connection was reused from the pool.
+func (p *pool) get(now time.Time) (conn, bool, error) {
+	for {
+		p.mu.Lock()
+		if len(p.idle) == 0 {
+			p.mu.Unlock()
+			break
+		}
+		// Reuse the most recently used connection, leaving older ones to expire
+		c := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		p.mu.Unlock()
+
+		if p.expired(c, now) {
+			c.Close()
+			continue
+		}
+		if now.Sub(c.since) > healthCheckAfter && !healthy(c) {
+			log.Printf("ldap.get(): discarding pooled connection that failed its health check")
+			c.Close()
+			continue
+		}
+		return c.conn, true, nil
+	}
+
+	c, err := p.dial()
+	return c, false, err
+}
+
+// put returns a connection to the pool
+func (p *pool) put(c conn, now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.idle = append(p.idle, idleConn{conn: c, since: now})
+}
+
+// reap closes the connections that have been idle for longer than the idle timeout
+func (p *pool) reap(now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var idle []idleConn
+	for _, c := range p.idle {
+		if p.expired(c, now) {
+			c.Close()
+			continue
+		}
+		idle = append(idle, c)
+	}
+	p.idle = idle
+}
+
+func (p *pool) expired(c idleConn, now time.Time) bool {
+	return p.idleTimeout > 0 && now.Sub(c.since) > p.idleTimeout
+}
+
+// healthy checks that the connection is still usable by reading the root DSE
+func healthy(c conn) bool {
+	_, err := c.Search(rootDSESearch())
+	return err == nil
+}
+
+// rootDSESearch returns a search request for the root DSE, which every server answers without a bind
+func rootDSESearch() *ldap.SearchRequest {
+	return ldap.NewSearchRequest("", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 1, 5, false,
+		"(objectClass=*)", []string{"1.1"}, nil)
+}
+
+// dialAndBind opens a connection to the LDAP server and binds to it
+func dialAndBind(host string) (conn, error) {
+	ldapConfig := config.AppConfig().LDAPConfig
+
+	c, err := ldap.DialURL(host)
+	if err != nil {
+		return nil, err
+	}
+
+	timer := prometheus.NewTimer(metrics.MetricLDAPBindDuration)
+	if ldapConfig.Username != "" {
+		_, err = c.SimpleBind(&ldap.SimpleBindRequest{
+			Username: ldapConfig.Username,
+			Password: ldapConfig.Password,
+		})
+	} else {
+		err = c.UnauthenticatedBind("")
+	}
+	timer.ObserveDuration()
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+var (
+	defaultPoolOnce sync.Once
+	defaultPool     *pool
+)
+
+// connectionPool returns the pool of connections to the configured LDAP servers
+func connectionPool() *pool {
+	defaultPoolOnce.Do(func() {
+		ldapConfig := config.AppConfig().LDAPConfig
+		servers := newServerList(ldapConfig.Servers(), ldapConfig.HostSelection)
+		defaultPool = newPool(ldapConfig.PoolSize, ldapConfig.IdleTimeout, ldapConfig.PageSize, func() (conn, error) {
+			return servers.dial(dialAndBind)
+		})
+	})
+	return defaultPool
+}
+
+// ReapIdleConnections closes the pooled LDAP connections that have been idle for longer than the idle timeout
+func ReapIdleConnections() {
+	connectionPool().reap(time.Now())
+}
+
+// CheckHealth checks that a working connection to an LDAP server can be taken from the pool
+func CheckHealth() error {
+	_, err := connectionPool().search(context.Background(), rootDSESearch())
+	return err
+}
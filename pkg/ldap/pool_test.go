@@ -0,0 +1,154 @@
+// Copyright 2021-2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ldap
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap"
+)
+
+// fakeConn is a connection whose searches fail with a network error once broken.
+// Searches on a stuck connection wait until it is closed.
+type fakeConn struct {
+	broken bool
+	closed bool
+	stuck  chan struct{}
+	// pageSize is the page size of the last paged search
+	pageSize uint32
+}
+
+func (c *fakeConn) Search(*ldap.SearchRequest) (*ldap.SearchResult, error) {
+	if c.stuck != nil {
+		<-c.stuck
+		return nil, ldap.NewError(ldap.ErrorNetwork, nil)
+	}
+	if c.broken {
+		return nil, ldap.NewError(ldap.ErrorNetwork, nil)
+	}
+	return &ldap.SearchResult{}, nil
+}
+
+func (c *fakeConn) SearchWithPaging(searchRequest *ldap.SearchRequest, pagingSize uint32) (*ldap.SearchResult, error) {
+	c.pageSize = pagingSize
+	return c.Search(searchRequest)
+}
+
+func (c *fakeConn) Close() {
+	if c.stuck != nil && !c.closed {
+		close(c.stuck)
+	}
+	c.closed = true
+}
+
+func TestPoolReusesConnections(t *testing.T) {
+	var dialled []*fakeConn
+	p := newPool(2, time.Minute, 0, func() (conn, error) {
+		c := &fakeConn{}
+		dialled = append(dialled, c)
+		return c, nil
+	})
+
+	for i := 0; i < 3; i++ {
+		if _, err := p.search(context.Background(), &ldap.SearchRequest{}); err != nil {
+			t.Fatalf("search() unexpected error: %v", err)
+		}
+	}
+	if len(dialled) != 1 {
+		t.Errorf("search() dialled %v connections, want 1", len(dialled))
+	}
+
+	// A broken connection is replaced and the search retried
+	dialled[0].broken = true
+	if _, err := p.search(context.Background(), &ldap.SearchRequest{}); err != nil {
+		t.Fatalf("search() unexpected error after rebind: %v", err)
+	}
+	if len(dialled) != 2 || !dialled[0].closed {
+		t.Errorf("search() did not replace the broken connection: dialled %v, closed %v", len(dialled), dialled[0].closed)
+	}
+}
+
+func TestPoolReap(t *testing.T) {
+	now := time.Now()
+	fresh, stale := &fakeConn{}, &fakeConn{}
+
+	p := newPool(2, time.Minute, 0, nil)
+	p.put(stale, now.Add(-2*time.Minute))
+	p.put(fresh, now)
+	p.reap(now)
+
+	if !stale.closed || fresh.closed {
+		t.Errorf("reap() closed stale %v, fresh %v, want true, false", stale.closed, fresh.closed)
+	}
+	if len(p.idle) != 1 {
+		t.Errorf("reap() left %v idle connections, want 1", len(p.idle))
+	}
+}
+
+func TestPoolGetSkipsUnhealthyConnections(t *testing.T) {
+	now := time.Now()
+	broken := &fakeConn{broken: true}
+
+	var dialled int
+	p := newPool(2, 0, 0, func() (conn, error) {
+		dialled++
+		return &fakeConn{}, nil
+	})
+	p.put(broken, now.Add(-time.Hour))
+
+	_, reused, err := p.get(now)
+	if err != nil {
+		t.Fatalf("get() unexpected error: %v", err)
+	}
+	if reused || dialled != 1 || !broken.closed {
+		t.Errorf("get() reused %v, dialled %v, closed broken %v, want false, 1, true", reused, dialled, broken.closed)
+	}
+}
+
+func TestPoolPagedSearch(t *testing.T) {
+	c := &fakeConn{}
+	p := newPool(1, 0, 100, func() (conn, error) { return c, nil })
+
+	if _, err := p.search(context.Background(), &ldap.SearchRequest{}); err != nil {
+		t.Fatalf("search() unexpected error: %v", err)
+	}
+	if c.pageSize != 100 {
+		t.Errorf("search() used page size %v, want 100", c.pageSize)
+	}
+}
+
+func TestPoolSearchDeadline(t *testing.T) {
+	c := &fakeConn{stuck: make(chan struct{})}
+	p := newPool(1, 0, 0, func() (conn, error) { return c, nil })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := p.search(ctx, &ldap.SearchRequest{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("search() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if !c.closed || len(p.idle) != 0 {
+		t.Errorf("search() closed %v, left %v idle connections, want the abandoned connection closed and not reused", c.closed, len(p.idle))
+	}
+
+	// The slot of the abandoned search is freed for the next search
+	c = &fakeConn{}
+	if _, err := p.search(context.Background(), &ldap.SearchRequest{}); err != nil {
+		t.Errorf("search() unexpected error after a deadline: %v", err)
+	}
+}
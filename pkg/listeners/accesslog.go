@@ -0,0 +1,75 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package listeners
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// AccessLogger is middleware logging each request as a line of JSON to stdout,
+// so the access logs can be ingested back into Splunk
+func AccessLogger(next http.Handler) http.Handler {
+	return newAccessLogger(os.Stdout)(next)
+}
+
+// newAccessLogger returns middleware logging each request as a line of JSON to w
+func newAccessLogger(w io.Writer) func(http.Handler) http.Handler {
+	logger := slog.New(slog.NewJSONHandler(w, nil))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			defer func() {
+				status := ww.Status()
+				// Handlers that only write a body respond with 200 OK
+				if status == 0 {
+					status = http.StatusOK
+				}
+				logger.LogAttrs(context.Background(), slog.LevelInfo, "request",
+					slog.String("method", r.Method),
+					slog.String("path", r.URL.Path),
+					slog.Int("status", status),
+					slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+					slog.Int("bytes", ww.BytesWritten()),
+					slog.String("remote_ip", remoteIP(r)),
+					slog.String("correlation_id", ww.Header().Get(correlationIDHeader)),
+				)
+			}()
+
+			next.ServeHTTP(ww, r)
+		})
+	}
+}
+
+// remoteIP returns the IP address of the client, without the port
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package listeners
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAccessLogger(t *testing.T) {
+	var out bytes.Buffer
+	handler := newAccessLogger(&out)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set(correlationIDHeader, "test-uuid")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte("bad request"))
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/alert", nil)
+	req.RemoteAddr = "192.0.2.1:12345"
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+		t.Fatalf("access log is not JSON: %v: %s", err, out.String())
+	}
+
+	want := map[string]interface{}{
+		"msg":            "request",
+		"method":         http.MethodPost,
+		"path":           "/api/v1/alert",
+		"status":         float64(http.StatusBadRequest),
+		"bytes":          float64(len("bad request")),
+		"remote_ip":      "192.0.2.1",
+		"correlation_id": "test-uuid",
+	}
+	for key, value := range want {
+		if entry[key] != value {
+			t.Errorf("access log %s = %v, want %v", key, entry[key], value)
+		}
+	}
+	if _, ok := entry["duration_ms"].(float64); !ok {
+		t.Errorf("access log has no duration: %s", out.String())
+	}
+}
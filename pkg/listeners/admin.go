@@ -0,0 +1,205 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package listeners
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/openshift/compliance-audit-router/pkg/audit"
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/helpers"
+	"github.com/openshift/compliance-audit-router/pkg/metrics"
+)
+
+const (
+	adminTemplatesPath = "/api/v1/admin/templates"
+	adminRollbackPath  = adminTemplatesPath + "/rollback"
+	adminSettingsPath  = "/api/v1/admin/settings"
+
+	// adminUserHeader names the person making an admin API request. Anyone holding the admin token can set it
+	// to any name, so it is recorded in the audit log as unverified, next to the authenticated actor.
+	adminUserHeader = "X-Admin-User"
+)
+
+// templatesResponse is the active templates and the templates they replaced, if updated at runtime
+type templatesResponse struct {
+	Active   config.Templates  `json:"active"`
+	Previous *config.Templates `json:"previous,omitempty"`
+}
+
+// adminAudit records a change made through the admin API in the audit log, with the authenticated actor
+// and the unverified user named by the adminUserHeader
+func adminAudit(r *http.Request, action string, details map[string]string) {
+	details["changed_by"] = adminActor(r)
+	if user := r.Header.Get(adminUserHeader); user != "" {
+		details["unverified_user"] = user
+	}
+	details["remote_ip"] = remoteIP(r)
+	audit.Write(action, "", details)
+}
+
+// adminActor returns the authenticated actor of an admin API request: the name of its API key,
+// or adminTokenKey for the shared admin token
+func adminActor(r *http.Request) string {
+	if name := apiKeyName(r); name != "" {
+		return name
+	}
+	return adminTokenKey
+}
+
+// TemplatesHandler replies with the active message and summary templates, and the templates they replaced
+func TemplatesHandler(w http.ResponseWriter, r *http.Request) {
+	var p = processInfo{process: "TemplatesHandler"}
+	if !authorized(w, r, p, config.ScopeAdminRead) {
+		return
+	}
+
+	active, previous := config.ActiveTemplates()
+	writeAdminJSON(w, templatesResponse{Active: active, Previous: previous}, p)
+}
+
+// UpdateTemplatesHandler validates the message and summary templates of the request and makes them the active
+// templates, so wording changes don't require a deploy. The replaced templates are retained for rollback.
+// Templates left empty are not changed.
+func UpdateTemplatesHandler(w http.ResponseWriter, r *http.Request) {
+	var p = processInfo{process: "UpdateTemplatesHandler"}
+	if !authorized(w, r, p, config.ScopeAdminWrite) {
+		return
+	}
+
+	var templates config.Templates
+	if err := decodeRequestBody(w, r, adminTemplatesPath, &templates); err != nil {
+		var mr *helpers.MalformedRequest
+		if errors.As(err, &mr) {
+			setResponse(w, statusInfo{code: mr.Status, msg: []string{mr.Msg}}, p)
+		} else {
+			log.Printf("failed decoding the templates: %s\n", err.Error())
+			setResponse(w, status500, p)
+		}
+		return
+	}
+	if templates.MessageTemplate == "" && templates.SummaryTemplate == "" {
+		setResponse(w, statusInfo{code: http.StatusBadRequest, msg: []string{"messageTemplate or summaryTemplate is required"}}, p)
+		return
+	}
+
+	active, err := config.UpdateTemplates(templates)
+	if err != nil {
+		setResponse(w, statusInfo{code: http.StatusBadRequest, msg: []string{err.Error()}}, p)
+		return
+	}
+	log.Printf("INFO: templates updated through the admin API")
+	adminAudit(r, audit.ActionTemplatesUpdated, map[string]string{
+		"message_template": active.MessageTemplate,
+		"summary_template": active.SummaryTemplate,
+	})
+
+	_, previous := config.ActiveTemplates()
+	writeAdminJSON(w, templatesResponse{Active: active, Previous: previous}, p)
+}
+
+// RollbackTemplatesHandler restores the templates replaced by the last update
+func RollbackTemplatesHandler(w http.ResponseWriter, r *http.Request) {
+	var p = processInfo{process: "RollbackTemplatesHandler"}
+	if !authorized(w, r, p, config.ScopeAdminWrite) {
+		return
+	}
+
+	active, err := config.RollbackTemplates()
+	if errors.Is(err, config.ErrNoPreviousTemplates) {
+		setResponse(w, statusInfo{code: http.StatusConflict, msg: []string{err.Error()}}, p)
+		return
+	}
+	if err != nil {
+		log.Printf("failed rolling back the templates: %s\n", err.Error())
+		setResponse(w, status500, p)
+		return
+	}
+	log.Printf("INFO: templates rolled back through the admin API")
+	adminAudit(r, audit.ActionTemplatesRolledBack, map[string]string{
+		"message_template": active.MessageTemplate,
+		"summary_template": active.SummaryTemplate,
+	})
+
+	_, previous := config.ActiveTemplates()
+	writeAdminJSON(w, templatesResponse{Active: active, Previous: previous}, p)
+}
+
+// SettingsHandler replies with the active dry-run mode, verbosity and log levels
+func SettingsHandler(w http.ResponseWriter, r *http.Request) {
+	var p = processInfo{process: "SettingsHandler"}
+	if !authorized(w, r, p, config.ScopeAdminRead) {
+		return
+	}
+
+	writeAdminJSON(w, config.ActiveRuntimeSettings(), p)
+}
+
+// UpdateSettingsHandler changes the dry-run mode, verbosity and log levels of the running instance, eg. to debug
+// an incident. Disabling dry-run requires the production confirmation, unless it is configured. The changes are
+// logged and recorded in the audit log with the actor of the request.
+func UpdateSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	var p = processInfo{process: "UpdateSettingsHandler"}
+	if !authorized(w, r, p, config.ScopeAdminWrite) {
+		return
+	}
+
+	var settings config.RuntimeSettings
+	if err := decodeRequestBody(w, r, adminSettingsPath, &settings); err != nil {
+		var mr *helpers.MalformedRequest
+		if errors.As(err, &mr) {
+			setResponse(w, statusInfo{code: mr.Status, msg: []string{mr.Msg}}, p)
+		} else {
+			log.Printf("failed decoding the settings: %s\n", err.Error())
+			setResponse(w, status500, p)
+		}
+		return
+	}
+
+	changes, err := config.UpdateRuntimeSettings(settings)
+	if err != nil {
+		setResponse(w, statusInfo{code: http.StatusBadRequest, msg: []string{err.Error()}}, p)
+		return
+	}
+	if len(changes) > 0 {
+		log.Printf("INFO: settings changed through the admin API by %s (unverified user %q): %v", adminActor(r), r.Header.Get(adminUserHeader), changes)
+		adminAudit(r, audit.ActionSettingsUpdated, changes)
+	}
+
+	writeAdminJSON(w, config.ActiveRuntimeSettings(), p)
+}
+
+// writeAdminJSON replies with the response of an admin API request as JSON
+func writeAdminJSON(w http.ResponseWriter, response interface{}, p processInfo) {
+	body, err := json.Marshal(response)
+	if err != nil {
+		log.Printf("failed rendering the response: %s\n", err.Error())
+		setResponse(w, status500, p)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+
+	labels := p.LabelInput()
+	labels["code"] = http.StatusText(http.StatusOK)
+	metrics.MetricHTTPResponses.With(labels).Inc()
+}
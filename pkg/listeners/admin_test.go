@@ -0,0 +1,144 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package listeners
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+)
+
+func TestTemplatesHandlers(t *testing.T) {
+	config.SetAppConfig(config.Config{MessageTemplate: "{{.Username}} please justify", SummaryTemplate: "Compliance Alert"})
+	defer func() { config.SetAppConfig(config.Config{}) }()
+
+	request := func(handler http.HandlerFunc, method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		recorder := httptest.NewRecorder()
+		handler(recorder, req)
+		return recorder
+	}
+
+	if got := request(TemplatesHandler, http.MethodGet, adminTemplatesPath, "", "").Code; got != http.StatusNotFound {
+		t.Errorf("handler returned %v without an admin token configured, want %v", got, http.StatusNotFound)
+	}
+
+	withToken := *config.AppConfig()
+	withToken.AdminConfig.Token = "admin-token"
+	config.SetAppConfig(withToken)
+	if got := request(UpdateTemplatesHandler, http.MethodPut, adminTemplatesPath, "wrong-token", `{"summaryTemplate":"changed"}`).Code; got != http.StatusUnauthorized {
+		t.Errorf("handler returned %v with a wrong token, want %v", got, http.StatusUnauthorized)
+	}
+
+	recorder := request(UpdateTemplatesHandler, http.MethodPut, adminTemplatesPath, "admin-token", `{"messageTemplate":"{{.Username"}`)
+	if recorder.Code != http.StatusBadRequest || !strings.Contains(recorder.Body.String(), "message template failed to parse") {
+		t.Errorf("handler returned %v %v for an invalid template, want %v", recorder.Code, recorder.Body.String(), http.StatusBadRequest)
+	}
+	if config.AppConfig().MessageTemplate != "{{.Username}} please justify" {
+		t.Errorf("handler activated an invalid template: %v", config.AppConfig().MessageTemplate)
+	}
+
+	recorder = request(UpdateTemplatesHandler, http.MethodPut, adminTemplatesPath, "admin-token", `{"messageTemplate":"{{.Username}} please explain"}`)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("handler returned %v %v, want %v", recorder.Code, recorder.Body.String(), http.StatusOK)
+	}
+	var got templatesResponse
+	if err := json.Unmarshal(recorder.Body.Bytes(), &got); err != nil {
+		t.Fatalf("handler returned invalid JSON: %v", err)
+	}
+	want := config.Templates{MessageTemplate: "{{.Username}} please explain", SummaryTemplate: "Compliance Alert"}
+	if got.Active != want || got.Previous == nil || got.Previous.MessageTemplate != "{{.Username}} please justify" {
+		t.Errorf("handler returned %+v, want the updated templates and the previous ones", got)
+	}
+	if config.AppConfig().MessageTemplate != want.MessageTemplate || config.AppConfig().SummaryTemplate != want.SummaryTemplate {
+		t.Errorf("handler did not activate the templates: %+v", config.AppConfig())
+	}
+
+	if recorder = request(RollbackTemplatesHandler, http.MethodPost, adminRollbackPath, "admin-token", ""); recorder.Code != http.StatusOK {
+		t.Fatalf("rollback returned %v %v, want %v", recorder.Code, recorder.Body.String(), http.StatusOK)
+	}
+	if config.AppConfig().MessageTemplate != "{{.Username}} please justify" {
+		t.Errorf("rollback did not restore the previous template: %v", config.AppConfig().MessageTemplate)
+	}
+}
+
+func TestSettingsHandlers(t *testing.T) {
+	config.SetAppConfig(config.Config{DryRun: true, AdminConfig: config.AdminConfig{Token: "admin-token"}})
+	defer func() { config.SetAppConfig(config.Config{}) }()
+
+	update := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, adminSettingsPath, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer admin-token")
+		req.Header.Set(adminUserHeader, "oncall@example.com")
+		recorder := httptest.NewRecorder()
+		UpdateSettingsHandler(recorder, req)
+		return recorder
+	}
+
+	if recorder := update(`{"dryRun":false}`); recorder.Code != http.StatusBadRequest || !strings.Contains(recorder.Body.String(), "productionconfirmation") {
+		t.Errorf("handler returned %v %v disabling dry-run without confirmation, want %v", recorder.Code, recorder.Body.String(), http.StatusBadRequest)
+	}
+	if recorder := update(`{"logLevels":{"jira":"trace"}}`); recorder.Code != http.StatusBadRequest {
+		t.Errorf("handler returned %v for an invalid log level, want %v", recorder.Code, http.StatusBadRequest)
+	}
+	if !config.AppConfig().DryRun || config.AppConfig().VerboseFor("jira") {
+		t.Fatalf("handler applied invalid settings: %+v", config.AppConfig())
+	}
+
+	recorder := update(`{"dryRun":false,"productionConfirmation":"create-jira-issues","logLevels":{"jira":"debug"}}`)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("handler returned %v %v, want %v", recorder.Code, recorder.Body.String(), http.StatusOK)
+	}
+	var got config.RuntimeSettings
+	if err := json.Unmarshal(recorder.Body.Bytes(), &got); err != nil {
+		t.Fatalf("handler returned invalid JSON: %v", err)
+	}
+	if got.DryRun == nil || *got.DryRun || got.LogLevels["jira"] != "debug" {
+		t.Errorf("handler returned %+v, want the updated settings", got)
+	}
+	if config.AppConfig().DryRun || !config.AppConfig().VerboseFor("jira") || config.AppConfig().VerboseFor("splunk") {
+		t.Errorf("handler did not apply the settings: %+v", config.AppConfig())
+	}
+}
+
+func TestAdminActor(t *testing.T) {
+	config.SetAppConfig(config.Config{
+		AdminConfig: config.AdminConfig{Token: "admin-token"},
+		APIKeys:     []config.APIKey{{Name: "oncall", Hash: config.HashAPIKey("oncall-key"), Scopes: []string{config.ScopeAdminWrite}}},
+	})
+	defer func() { config.SetAppConfig(config.Config{}) }()
+
+	// The actor is the authenticated key, whatever the caller claims to be in the header
+	tests := map[string]string{"admin-token": adminTokenKey, "oncall-key": "oncall"}
+	for token, want := range tests {
+		req := httptest.NewRequest(http.MethodPut, adminSettingsPath, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set(adminUserHeader, "someone-else")
+		if got := adminActor(req); got != want {
+			t.Errorf("adminActor() with %s = %q, want %q", token, got, want)
+		}
+	}
+}
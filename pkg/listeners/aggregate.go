@@ -0,0 +1,285 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package listeners
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/jira"
+	"github.com/openshift/compliance-audit-router/pkg/metrics"
+	"github.com/openshift/compliance-audit-router/pkg/splunk"
+)
+
+// aggregationKey identifies the troubleshooting session a compliance event belongs to:
+// the events of one user on the same clusters, for the same tenant
+type aggregationKey struct {
+	tenant   string
+	user     string
+	clusters string
+}
+
+func newAggregationKey(tenant string, complianceEvent splunk.AlertDetails) aggregationKey {
+	clusters := append([]string{}, complianceEvent.ClusterIDs...)
+	sort.Strings(clusters)
+	return aggregationKey{
+		tenant:   strings.ToLower(tenant),
+		user:     strings.ToLower(complianceEvent.User),
+		clusters: strings.Join(clusters, ","),
+	}
+}
+
+// fileName is the name of the state file of the session's window started at the time
+func (k aggregationKey) fileName(started time.Time) string {
+	sum := sha256.Sum256([]byte(k.tenant + "\x00" + k.user + "\x00" + k.clusters))
+	return fmt.Sprintf("%020d-%s", started.UnixNano(), hex.EncodeToString(sum[:8]))
+}
+
+// pendingAggregate holds the compliance events of a session buffered in the aggregation window
+type pendingAggregate struct {
+	tenantConfig config.Config
+	p            processInfo
+	events       []splunk.AlertDetails
+	// started is when the session's window started
+	started time.Time
+}
+
+// persistedAggregate is the state file of a session's buffered events, restored when the router restarts
+type persistedAggregate struct {
+	Tenant  string                `json:"tenant"`
+	UUID    string                `json:"uuid"`
+	Started time.Time             `json:"started"`
+	Events  []splunk.AlertDetails `json:"events"`
+}
+
+// aggregator buffers compliance events for the aggregation window, starting from the first event
+// of each session, and then flushes the session's events together. With a directory, the buffered
+// events are persisted to it until their ticket is created, so they are restored after a restart;
+// otherwise they are lost if the process exits before their window ends. Sessions that fail to be
+// flushed are buffered again for another window.
+type aggregator struct {
+	window time.Duration
+	dir    string
+	flush  func(pendingAggregate) error
+
+	mu      sync.Mutex
+	pending map[aggregationKey]*pendingAggregate
+}
+
+func newAggregator(window time.Duration, dir string, flush func(pendingAggregate) error) *aggregator {
+	return &aggregator{window: window, dir: dir, flush: flush, pending: map[aggregationKey]*pendingAggregate{}}
+}
+
+// add buffers the compliance event with the other events of its session, starting the session's
+// aggregation window if it is the first
+func (a *aggregator) add(tenantConfig config.Config, complianceEvent splunk.AlertDetails, p processInfo) {
+	key := newAggregationKey(p.tenant, complianceEvent)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	pending, ok := a.pending[key]
+	if !ok {
+		pending = &pendingAggregate{tenantConfig: tenantConfig, p: p, started: time.Now()}
+		a.pending[key] = pending
+		time.AfterFunc(a.window, func() { a.expire(key) })
+	}
+	pending.events = append(pending.events, complianceEvent)
+	metrics.MetricAggregationPendingEvents.Inc()
+	a.save(key, pending)
+}
+
+// expire removes the session's events from the buffer once its window ends, and flushes them.
+// The session's state file is kept until its ticket is created.
+func (a *aggregator) expire(key aggregationKey) {
+	a.mu.Lock()
+	pending, ok := a.pending[key]
+	delete(a.pending, key)
+	a.mu.Unlock()
+
+	if !ok {
+		return
+	}
+	metrics.MetricAggregationPendingEvents.Sub(float64(len(pending.events)))
+	if err := a.flush(*pending); err != nil {
+		a.requeue(key, *pending)
+		return
+	}
+	a.remove(key, pending.started)
+}
+
+// requeue buffers the events of a session that failed to be flushed again, with the events received for
+// the session meanwhile or for another window
+func (a *aggregator) requeue(key aggregationKey, failed pendingAggregate) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	pending, ok := a.pending[key]
+	if !ok {
+		pending = &pendingAggregate{tenantConfig: failed.tenantConfig, p: failed.p, started: time.Now()}
+		a.pending[key] = pending
+		time.AfterFunc(a.window, func() { a.expire(key) })
+	}
+	pending.events = append(failed.events, pending.events...)
+	metrics.MetricAggregationPendingEvents.Add(float64(len(failed.events)))
+	a.save(key, pending)
+	a.remove(key, failed.started)
+}
+
+// save persists the session's buffered events, when the aggregator has a directory. On failure, the
+// events are still flushed unless the process exits before.
+func (a *aggregator) save(key aggregationKey, pending *pendingAggregate) {
+	if a.dir == "" {
+		return
+	}
+	state := persistedAggregate{Tenant: pending.p.tenant, UUID: pending.p.uuid, Started: pending.started, Events: pending.events}
+	if err := writeState(a.dir, key.fileName(pending.started), state); err != nil {
+		log.Printf("%s failed persisting aggregated compliance events: %s\n", pending.p.uuid, err.Error())
+	}
+}
+
+// remove removes the state file of the session's window started at the time
+func (a *aggregator) remove(key aggregationKey, started time.Time) {
+	if a.dir == "" {
+		return
+	}
+	if err := removeState(a.dir, key.fileName(started)); err != nil {
+		log.Printf("failed removing aggregated compliance events: %s\n", err.Error())
+	}
+}
+
+// restore buffers the sessions persisted to the aggregator's directory, eg. before a restart, flushing each
+// once its window ends. Sessions of tenants no longer configured are kept in the directory for an operator.
+func (a *aggregator) restore(tenantConfig func(tenant string) (config.Config, bool), now time.Time) error {
+	if a.dir == "" {
+		return nil
+	}
+	return readStates(a.dir, func(name string, data []byte) error {
+		var state persistedAggregate
+		if err := json.Unmarshal(data, &state); err != nil {
+			return err
+		}
+		if len(state.Events) == 0 {
+			return removeState(a.dir, name)
+		}
+		tenant, ok := tenantConfig(state.Tenant)
+		if !ok {
+			return fmt.Errorf("unknown tenant %s", state.Tenant)
+		}
+
+		key := newAggregationKey(state.Tenant, state.Events[0])
+		a.mu.Lock()
+		defer a.mu.Unlock()
+
+		pending, ok := a.pending[key]
+		if ok {
+			// The session was persisted twice, eg. when the process exited while requeueing it
+			pending.events = append(pending.events, state.Events...)
+			metrics.MetricAggregationPendingEvents.Add(float64(len(state.Events)))
+			a.save(key, pending)
+			return removeState(a.dir, name)
+		}
+		pending = &pendingAggregate{
+			tenantConfig: tenant,
+			p:            processInfo{uuid: state.UUID, process: "RestoreAggregates", tenant: state.Tenant, aggregate: true},
+			events:       state.Events,
+			started:      state.Started,
+		}
+		a.pending[key] = pending
+		metrics.MetricAggregationPendingEvents.Add(float64(len(state.Events)))
+		if name != key.fileName(state.Started) {
+			a.save(key, pending)
+			if err := removeState(a.dir, name); err != nil {
+				return err
+			}
+		}
+		time.AfterFunc(state.Started.Add(a.window).Sub(now), func() { a.expire(key) })
+		return nil
+	})
+}
+
+// buffered returns the number of compliance events waiting for their aggregation window to end
+func (a *aggregator) buffered() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var events int
+	for _, pending := range a.pending {
+		events += len(pending.events)
+	}
+	return events
+}
+
+// flushAggregate creates a single Jira ticket for the buffered compliance events of a session,
+// within the alert deadline
+func flushAggregate(pending pendingAggregate) error {
+	p := pending.p
+	p.aggregate = false
+
+	ctx, cancel := stageContext(context.Background(), stageAlert, config.AppConfig().PipelineConfig.AlertTimeout)
+	defer cancel()
+
+	complianceEvent := splunk.MergeAlertDetails(pending.events)
+	log.Printf("%s creating a ticket for %d aggregated compliance events of %s on %s\n", p.uuid, len(pending.events), complianceEvent.User, complianceEvent.ClusterText)
+
+	jiraClient, err := jira.NewClientContext(ctx, pending.tenantConfig.JiraConfig)
+	if err != nil {
+		log.Printf("%s failed creating Jira client: %s\n", p.uuid, err.Error())
+		metrics.MetricJiraClientCreateFailures.With(p.LabelInput()).Inc()
+		metrics.MetricAggregationTickets.With(map[string]string{"result": "failed"}).Inc()
+		return err
+	}
+
+	if _, err := processEvents(ctx, &pending.tenantConfig, jiraClient, []splunk.AlertDetails{complianceEvent}, p); err != nil {
+		log.Printf("%s failed processing aggregated compliance events; buffering them again: %s\n", p.uuid, err.Error())
+		metrics.MetricAggregationTickets.With(map[string]string{"result": "failed"}).Inc()
+		return err
+	}
+	metrics.MetricAggregationTickets.With(map[string]string{"result": "created"}).Inc()
+	return nil
+}
+
+var (
+	defaultAggregatorOnce sync.Once
+	defaultAggregator     *aggregator
+)
+
+// eventAggregator returns the aggregator compliance events received from Splunk are buffered in
+func eventAggregator() *aggregator {
+	defaultAggregatorOnce.Do(func() {
+		aggregationConfig := config.AppConfig().AggregationConfig
+		defaultAggregator = newAggregator(aggregationConfig.Window, aggregationConfig.Dir, flushAggregate)
+	})
+	return defaultAggregator
+}
+
+// RestoreAggregates buffers the compliance events persisted to aggregationconfig.dir before a restart,
+// creating their tickets once their aggregation window ends
+func RestoreAggregates() {
+	if err := eventAggregator().restore(config.AppConfig().Tenant, time.Now()); err != nil {
+		log.Printf("listeners.RestoreAggregates(): %s\n", err.Error())
+	}
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package listeners
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/splunk"
+)
+
+func TestAggregator(t *testing.T) {
+	flushed := make(chan pendingAggregate, 2)
+	a := newAggregator(20*time.Millisecond, "", func(pending pendingAggregate) error { flushed <- pending; return nil })
+
+	p := processInfo{uuid: "123", tenant: "acme", aggregate: true}
+	a.add(config.Config{}, splunk.AlertDetails{User: "sre", ClusterIDs: []string{"b", "a"}}, p)
+	a.add(config.Config{}, splunk.AlertDetails{User: "SRE", ClusterIDs: []string{"a", "b"}}, p)
+	a.add(config.Config{}, splunk.AlertDetails{User: "sre", ClusterIDs: []string{"c"}}, p)
+
+	counts := map[int]int{}
+	for i := 0; i < 2; i++ {
+		select {
+		case pending := <-flushed:
+			counts[len(pending.events)]++
+		case <-time.After(time.Second):
+			t.Fatalf("aggregator did not flush the sessions after their window")
+		}
+	}
+	if counts[2] != 1 || counts[1] != 1 {
+		t.Errorf("aggregator flushed sessions with %v events, want one session of 2 and one of 1", counts)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.pending) != 0 {
+		t.Errorf("aggregator kept %d sessions after flushing them", len(a.pending))
+	}
+}
+
+func TestAggregatorRequeue(t *testing.T) {
+	dir := t.TempDir()
+	flushed := make(chan pendingAggregate, 2)
+	attempts := 0
+	a := newAggregator(20*time.Millisecond, dir, func(pending pendingAggregate) error {
+		attempts++
+		if attempts == 1 {
+			return errors.New("jira unavailable")
+		}
+		flushed <- pending
+		return nil
+	})
+
+	a.add(config.Config{}, splunk.AlertDetails{User: "sre", ClusterIDs: []string{"a"}}, processInfo{tenant: "acme"})
+
+	select {
+	case pending := <-flushed:
+		if len(pending.events) != 1 {
+			t.Errorf("aggregator flushed %d events after a failed flush, want 1", len(pending.events))
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("aggregator did not flush the session again after a failed flush")
+	}
+
+	// The state file is removed once the session's ticket is created
+	deadline := time.Now().Add(time.Second)
+	for {
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(files) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("aggregator kept %d state files after flushing the session", len(files))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestAggregatorRestore(t *testing.T) {
+	dir := t.TempDir()
+	window := time.Hour
+	persisted := newAggregator(window, dir, func(pendingAggregate) error { return nil })
+	persisted.add(config.Config{}, splunk.AlertDetails{User: "sre", ClusterIDs: []string{"a"}}, processInfo{uuid: "123", tenant: "acme"})
+	persisted.add(config.Config{}, splunk.AlertDetails{User: "sre", ClusterIDs: []string{"a"}}, processInfo{uuid: "123", tenant: "acme"})
+	persisted.add(config.Config{}, splunk.AlertDetails{User: "sre", ClusterIDs: []string{"b"}}, processInfo{tenant: "gone"})
+
+	// After a restart, the sessions are restored and flushed once their window ends
+	flushed := make(chan pendingAggregate, 2)
+	restored := newAggregator(window, dir, func(pending pendingAggregate) error { flushed <- pending; return nil })
+	tenants := func(tenant string) (config.Config, bool) { return config.Config{}, tenant == "acme" }
+	if err := restored.restore(tenants, time.Now().Add(window)); err != nil {
+		t.Fatalf("restore() error = %v", err)
+	}
+
+	select {
+	case pending := <-flushed:
+		if len(pending.events) != 2 || pending.p.tenant != "acme" || pending.p.uuid != "123" {
+			t.Errorf("restored session = %+v, want the 2 events of tenant acme", pending)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("restored session was not flushed once its window ended")
+	}
+
+	// The session of the unknown tenant is kept for an operator
+	time.Sleep(20 * time.Millisecond)
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("aggregator directory has %d files after the restore, want the unknown tenant's session", len(files))
+	}
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package listeners
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/metrics"
+)
+
+// adminTokenKey names the adminconfig.token in the API key metrics
+const adminTokenKey = "adminconfig.token"
+
+// openScopes are the only scopes whose endpoints are open without API keys, so Splunk and Jira can send their
+// webhooks to a router without keys. The endpoints of the other scopes fail closed: they accept the admin token,
+// and are disabled without it or API keys with their scope.
+var openScopes = []string{config.ScopeAlertSubmit, config.ScopeJiraWebhook}
+
+// tokenParameter is the query parameter of the API key for the Jira webhooks, as Jira can't send headers with them
+const tokenParameter = "token"
+
+// requireScope wraps the handler of a listener, only calling it for requests that may use the endpoints of the scope
+func requireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if authorized(w, r, processInfo{process: "requireScope"}, scope) {
+			next(w, r)
+		}
+	}
+}
+
+// authorized checks that the request's token is an API key with the scope or, for the scopes other than
+// the open scopes, the admin token. Without API keys, the endpoints of the open scopes are open. It replies with a
+// 401 Unauthorized when the token is missing or unknown, a 403 Forbidden when the API key lacks the scope, or a
+// 404 Not Found when the endpoint is disabled, without an admin token or API keys with the scope.
+func authorized(w http.ResponseWriter, r *http.Request, p processInfo, scope string) bool {
+	token := bearerToken(r)
+	if token == "" && scope == config.ScopeJiraWebhook {
+		token = r.URL.Query().Get(tokenParameter)
+	}
+	open := slices.Contains(openScopes, scope)
+
+	if key, ok := config.AppConfig().APIKeyFor(token); ok {
+		if key.Allows(scope) {
+			recordAPIKeyRequest(key.Name, scope, "allowed")
+			return true
+		}
+		log.Printf("rejected API key %s without the %s scope for %s\n", key.Name, scope, r.URL.Path)
+		recordAPIKeyRequest(key.Name, scope, "forbidden")
+		setResponse(w, statusInfo{code: http.StatusForbidden, msg: []string{"Forbidden: the API key lacks the " + scope + " scope"}}, p)
+		return false
+	}
+
+	adminToken := config.AppConfig().AdminConfig.Token
+	if !open && adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1 {
+		recordAPIKeyRequest(adminTokenKey, scope, "allowed")
+		return true
+	}
+
+	switch {
+	case open && len(config.AppConfig().APIKeys) == 0:
+		return true
+	case !open && adminToken == "" && !keysConfiguredFor(scope):
+		setResponse(w, statusInfo{code: http.StatusNotFound, msg: []string{"The endpoint is disabled"}}, p)
+		return false
+	}
+
+	log.Printf("rejected unauthorized request to %s\n", r.URL.Path)
+	recordAPIKeyRequest("", scope, "unauthorized")
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	setResponse(w, statusInfo{code: http.StatusUnauthorized, msg: []string{"Unauthorized"}}, p)
+	return false
+}
+
+// keysConfiguredFor reports whether any API key has the scope or, for the admin API, any admin scope
+func keysConfiguredFor(scope string) bool {
+	scopes := []string{scope}
+	if scope == config.ScopeAdminRead || scope == config.ScopeAdminWrite {
+		scopes = []string{config.ScopeAdminRead, config.ScopeAdminWrite}
+	}
+	return slices.ContainsFunc(config.AppConfig().APIKeys, func(key config.APIKey) bool {
+		return slices.ContainsFunc(scopes, key.Allows)
+	})
+}
+
+// bearerToken returns the bearer token of the request's Authorization header, or an empty string
+func bearerToken(r *http.Request) string {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return ""
+	}
+	return token
+}
+
+// apiKeyName returns the name of the API key authenticating the request, or an empty string
+func apiKeyName(r *http.Request) string {
+	key, _ := config.AppConfig().APIKeyFor(bearerToken(r))
+	return key.Name
+}
+
+func recordAPIKeyRequest(key, scope, result string) {
+	metrics.MetricAPIKeyRequests.With(map[string]string{"key": key, "scope": scope, "result": result}).Inc()
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package listeners
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+)
+
+func TestAPIKeyScopes(t *testing.T) {
+	router := chi.NewRouter()
+	InitRoutes(router)
+
+	request := func(method, path, token, body string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+	defer func() { config.SetAppConfig(config.Config{}) }()
+	// Webhooks for events the router doesn't handle are acknowledged without calling Jira
+	jiraWebhook := `{"webhookEvent":"jira:issue_deleted"}`
+
+	// Without API keys, the alert and Jira webhook endpoints are open, and the configuration, status, audit log
+	// export and admin API are disabled
+	config.SetAppConfig(config.Config{})
+	if got := request(http.MethodPost, validatePath, "", `{"sid":"scheduler_1"}`); got != http.StatusOK {
+		t.Errorf("alert without API keys returned %v, want %v", got, http.StatusOK)
+	}
+	if got := request(http.MethodPost, jiraWebhookPath, "", jiraWebhook); got != http.StatusOK {
+		t.Errorf("jira webhook without API keys returned %v, want %v", got, http.StatusOK)
+	}
+	if got := request(http.MethodGet, statusPath, "", ""); got != http.StatusNotFound {
+		t.Errorf("status without API keys returned %v, want %v", got, http.StatusNotFound)
+	}
+	if got := request(http.MethodGet, "/api/v1/config", "", ""); got != http.StatusNotFound {
+		t.Errorf("config without API keys returned %v, want %v", got, http.StatusNotFound)
+	}
+	if got := request(http.MethodGet, exportPath, "", ""); got != http.StatusNotFound {
+		t.Errorf("export without API keys returned %v, want %v", got, http.StatusNotFound)
+	}
+	if got := request(http.MethodGet, adminSettingsPath, "", ""); got != http.StatusNotFound {
+		t.Errorf("admin API without API keys returned %v, want %v", got, http.StatusNotFound)
+	}
+
+	// With only the admin token, the configuration and audit log export require it
+	config.SetAppConfig(config.Config{AdminConfig: config.AdminConfig{Token: "admin-token"}})
+	if got := request(http.MethodGet, "/api/v1/config", "", ""); got != http.StatusUnauthorized {
+		t.Errorf("config without the admin token returned %v, want %v", got, http.StatusUnauthorized)
+	}
+	if got := request(http.MethodGet, "/api/v1/config", "admin-token", ""); got != http.StatusOK {
+		t.Errorf("config with the admin token returned %v, want %v", got, http.StatusOK)
+	}
+	if got := request(http.MethodGet, exportPath, "", ""); got != http.StatusUnauthorized {
+		t.Errorf("export without the admin token returned %v, want %v", got, http.StatusUnauthorized)
+	}
+
+	// Keys for sending alerts don't enable the read endpoints
+	config.SetAppConfig(config.Config{APIKeys: []config.APIKey{{Name: "splunk", Hash: config.HashAPIKey("splunk-key"), Scopes: []string{config.ScopeAlertSubmit}}}})
+	for _, path := range []string{"/api/v1/config", exportPath} {
+		if got := request(http.MethodGet, path, "", ""); got != http.StatusNotFound {
+			t.Errorf("%s without keys with its scope returned %v, want %v", path, got, http.StatusNotFound)
+		}
+	}
+
+	config.SetAppConfig(config.Config{
+		AdminConfig: config.AdminConfig{Token: "admin-token"},
+		APIKeys: []config.APIKey{
+			{Name: "dashboard", Hash: config.HashAPIKey("dashboard-key"), Scopes: []string{config.ScopeConfigRead, config.ScopeAdminRead}},
+			{Name: "splunk", Hash: config.HashAPIKey("splunk-key"), Scopes: []string{config.ScopeAlertSubmit}},
+			{Name: "jira", Hash: config.HashAPIKey("jira-key"), Scopes: []string{config.ScopeJiraWebhook}},
+		},
+	})
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   string
+		want   int
+	}{
+		{"config without a key", http.MethodGet, "/api/v1/config", "", "", http.StatusUnauthorized},
+		{"config with an unknown key", http.MethodGet, "/api/v1/config", "unknown-key", "", http.StatusUnauthorized},
+		{"config with a key without the scope", http.MethodGet, "/api/v1/config", "splunk-key", "", http.StatusForbidden},
+		{"config with the scope", http.MethodGet, "/api/v1/config", "dashboard-key", "", http.StatusOK},
+		{"alert with a key without the scope", http.MethodPost, validatePath, "dashboard-key", `{"sid":"scheduler_1"}`, http.StatusForbidden},
+		{"alert with the scope", http.MethodPost, validatePath, "splunk-key", `{"sid":"scheduler_1"}`, http.StatusOK},
+		{"admin read with the scope", http.MethodGet, adminSettingsPath, "dashboard-key", "", http.StatusOK},
+		{"admin write without the scope", http.MethodPut, adminSettingsPath, "dashboard-key", `{"verbose":true}`, http.StatusForbidden},
+		{"admin write with the admin token", http.MethodPut, adminTemplatesPath, "admin-token", `{"summaryTemplate":"Compliance Alert"}`, http.StatusOK},
+		{"config with the admin token", http.MethodGet, "/api/v1/config", "admin-token", "", http.StatusOK},
+		{"export without a key", http.MethodGet, exportPath, "", "", http.StatusUnauthorized},
+		{"export with a key without the scope", http.MethodGet, exportPath, "dashboard-key", "", http.StatusForbidden},
+		{"the admin token is not an API key", http.MethodPost, validatePath, "admin-token", `{"sid":"scheduler_1"}`, http.StatusUnauthorized},
+		{"jira webhook without a key", http.MethodPost, jiraWebhookPath, "", jiraWebhook, http.StatusUnauthorized},
+		{"jira webhook with a key without the scope", http.MethodPost, jiraWebhookPath, "splunk-key", jiraWebhook, http.StatusForbidden},
+		{"jira webhook with the scope", http.MethodPost, jiraWebhookPath, "jira-key", jiraWebhook, http.StatusOK},
+		{"jira webhook with the key as a parameter", http.MethodPost, jiraWebhookPath + "?token=jira-key", "", jiraWebhook, http.StatusOK},
+		{"alert with the key as a parameter", http.MethodPost, validatePath + "?token=splunk-key", "", `{"sid":"scheduler_1"}`, http.StatusUnauthorized},
+		{"status with a key without the scope", http.MethodGet, statusPath, "jira-key", "", http.StatusForbidden},
+		{"status with the scope", http.MethodGet, statusPath, "dashboard-key", "", http.StatusOK},
+		{"health checks are open", http.MethodGet, "/healthz", "", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := request(tt.method, tt.path, tt.token, tt.body); got != tt.want {
+				t.Errorf("%s %s returned %v, want %v", tt.method, tt.path, got, tt.want)
+			}
+		})
+	}
+}
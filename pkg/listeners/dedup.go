@@ -0,0 +1,92 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package listeners
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openshift/compliance-audit-router/pkg/metrics"
+	"github.com/openshift/compliance-audit-router/pkg/splunk"
+)
+
+// deduplicator remembers the content of the compliance events processed within the suppression window, so the
+// events found again by a saved search re-fired over an overlapping time range don't create a second ticket.
+// The events are remembered in memory only, so they are forgotten when the process restarts.
+type deduplicator struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+func newDeduplicator() *deduplicator {
+	return &deduplicator{seen: map[string]time.Time{}}
+}
+
+// dedupKey identifies the content of the tenant's compliance event
+func dedupKey(tenant string, complianceEvent splunk.AlertDetails) string {
+	return strings.ToLower(tenant) + "/" + complianceEvent.ContentHash()
+}
+
+// filter returns the events whose content wasn't seen within the window before now, remembering them as seen now
+func (d *deduplicator) filter(tenant string, events []splunk.AlertDetails, window time.Duration, now time.Time) []splunk.AlertDetails {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for key, seen := range d.seen {
+		if now.Sub(seen) >= window {
+			delete(d.seen, key)
+		}
+	}
+
+	var unseen []splunk.AlertDetails
+	for _, complianceEvent := range events {
+		key := dedupKey(tenant, complianceEvent)
+		if _, ok := d.seen[key]; ok {
+			log.Printf("suppressing duplicate compliance event of %s on %s", complianceEvent.User, strings.Join(complianceEvent.ClusterIDs, ", "))
+			metrics.MetricDuplicateEvents.Inc()
+			continue
+		}
+		d.seen[key] = now
+		unseen = append(unseen, complianceEvent)
+	}
+	return unseen
+}
+
+// forget forgets the events, eg. when they failed to be processed, so they aren't suppressed when retried
+func (d *deduplicator) forget(tenant string, events []splunk.AlertDetails) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, complianceEvent := range events {
+		delete(d.seen, dedupKey(tenant, complianceEvent))
+	}
+}
+
+var (
+	deduplicatorOnce sync.Once
+	dedup            *deduplicator
+)
+
+// eventDeduplicator returns the deduplicator of the compliance events
+func eventDeduplicator() *deduplicator {
+	deduplicatorOnce.Do(func() {
+		dedup = newDeduplicator()
+	})
+	return dedup
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package listeners
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openshift/compliance-audit-router/pkg/splunk"
+)
+
+func TestDeduplicator(t *testing.T) {
+	d := newDeduplicator()
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	session := splunk.AlertDetails{User: "sre", ClusterIDs: []string{"abc"}, ElevatedSummary: []string{"delete pod"}}
+	other := splunk.AlertDetails{User: "sre", ClusterIDs: []string{"def"}, ElevatedSummary: []string{"delete pod"}}
+
+	tests := []struct {
+		name   string
+		tenant string
+		events []splunk.AlertDetails
+		after  time.Duration
+		want   int
+	}{
+		{"First search", "", []splunk.AlertDetails{session}, 0, 1},
+		{"Overlapping search", "", []splunk.AlertDetails{session, other}, 10 * time.Minute, 1},
+		{"Duplicates in one search", "", []splunk.AlertDetails{session, session}, 20 * time.Minute, 0},
+		{"Other tenant", "tenant-b", []splunk.AlertDetails{session}, 20 * time.Minute, 1},
+		{"After the window", "", []splunk.AlertDetails{session}, time.Hour, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := d.filter(tt.tenant, tt.events, time.Hour, start.Add(tt.after)); len(got) != tt.want {
+				t.Errorf("filter() = %v, want %d events", got, tt.want)
+			}
+		})
+	}
+
+	// Forgotten events, eg. of a failed alert, are processed again when retried
+	d.forget("", []splunk.AlertDetails{other})
+	if got := d.filter("", []splunk.AlertDetails{other}, time.Hour, start.Add(time.Hour)); len(got) != 1 {
+		t.Errorf("filter() = %v, want the forgotten event", got)
+	}
+}
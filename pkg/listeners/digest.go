@@ -0,0 +1,281 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package listeners
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/jira"
+	"github.com/openshift/compliance-audit-router/pkg/metrics"
+	"github.com/openshift/compliance-audit-router/pkg/splunk"
+)
+
+// digestKey identifies the digest a compliance event is collected into: one per alert and tenant
+type digestKey struct {
+	tenant    string
+	alertName string
+}
+
+// fileName is the name of the state file of the digest started at the time
+func (k digestKey) fileName(started time.Time) string {
+	sum := sha256.Sum256([]byte(k.tenant + "\x00" + k.alertName))
+	return fmt.Sprintf("%020d-%s", started.UnixNano(), hex.EncodeToString(sum[:8]))
+}
+
+// pendingDigest holds the compliance events collected for a digest ticket
+type pendingDigest struct {
+	tenant       string
+	tenantConfig config.Config
+	alertName    string
+	events       []splunk.AlertDetails
+	// started is when the first event was collected into the digest
+	started time.Time
+}
+
+// persistedDigest is the state file of a digest's collected events, restored when the router restarts
+type persistedDigest struct {
+	Tenant    string                `json:"tenant"`
+	AlertName string                `json:"alertName"`
+	Started   time.Time             `json:"started"`
+	Events    []splunk.AlertDetails `json:"events"`
+}
+
+// digester collects the compliance events of low-risk alerts until the daily digest time. With a directory,
+// the collected events are persisted to it until their digest ticket is created, so they are restored after
+// a restart; otherwise they are lost if the process exits before the digest time.
+type digester struct {
+	dir string
+
+	mu      sync.Mutex
+	pending map[digestKey]*pendingDigest
+	// lastRun is when the digests were last taken, or when the digester was created
+	lastRun time.Time
+}
+
+func newDigester(now time.Time, dir string) *digester {
+	return &digester{dir: dir, pending: map[digestKey]*pendingDigest{}, lastRun: now}
+}
+
+// add collects the compliance event into the digest of its alert
+func (d *digester) add(tenantConfig config.Config, complianceEvent splunk.AlertDetails, p processInfo) {
+	key := digestKey{tenant: strings.ToLower(p.tenant), alertName: strings.ToLower(complianceEvent.AlertName)}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	pending, ok := d.pending[key]
+	if !ok {
+		pending = &pendingDigest{tenant: p.tenant, tenantConfig: tenantConfig, alertName: complianceEvent.AlertName, started: time.Now()}
+		d.pending[key] = pending
+	}
+	pending.events = append(pending.events, complianceEvent)
+	metrics.MetricDigestPendingEvents.Inc()
+	d.save(key, pending)
+}
+
+// take returns the collected digests and starts collecting new ones, once the time of day has passed
+// since they were last taken; until then it returns nothing. The state files of the digests taken are
+// kept until they are sent.
+func (d *digester) take(now time.Time, at time.Duration) []pendingDigest {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	scheduled := now.UTC().Truncate(24 * time.Hour).Add(at)
+	if scheduled.After(now) {
+		scheduled = scheduled.Add(-24 * time.Hour)
+	}
+	if !d.lastRun.Before(scheduled) {
+		return nil
+	}
+	d.lastRun = now
+
+	var digests []pendingDigest
+	for key, pending := range d.pending {
+		digests = append(digests, *pending)
+		metrics.MetricDigestPendingEvents.Sub(float64(len(pending.events)))
+		delete(d.pending, key)
+	}
+	return digests
+}
+
+// sent removes the state file of the digest once its ticket is created
+func (d *digester) sent(digest pendingDigest) {
+	d.remove(digestKey{tenant: strings.ToLower(digest.tenant), alertName: strings.ToLower(digest.alertName)}, digest.started)
+}
+
+// restore collects the events of a digest that failed to be created again, for the next digest
+func (d *digester) restore(digest pendingDigest) {
+	key := digestKey{tenant: strings.ToLower(digest.tenant), alertName: strings.ToLower(digest.alertName)}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	pending, ok := d.pending[key]
+	if !ok {
+		pending = &pendingDigest{tenant: digest.tenant, tenantConfig: digest.tenantConfig, alertName: digest.alertName, started: digest.started}
+		d.pending[key] = pending
+	}
+	pending.events = append(digest.events, pending.events...)
+	metrics.MetricDigestPendingEvents.Add(float64(len(digest.events)))
+	if pending.started != digest.started {
+		d.save(key, pending)
+		d.remove(key, digest.started)
+	}
+}
+
+// save persists the digest's collected events, when the digester has a directory. On failure, the
+// events are still sent unless the process exits before.
+func (d *digester) save(key digestKey, pending *pendingDigest) {
+	if d.dir == "" {
+		return
+	}
+	state := persistedDigest{Tenant: pending.tenant, AlertName: pending.alertName, Started: pending.started, Events: pending.events}
+	if err := writeState(d.dir, key.fileName(pending.started), state); err != nil {
+		log.Printf("failed persisting the %s digest: %s\n", pending.alertName, err.Error())
+	}
+}
+
+// remove removes the state file of the digest started at the time
+func (d *digester) remove(key digestKey, started time.Time) {
+	if d.dir == "" {
+		return
+	}
+	if err := removeState(d.dir, key.fileName(started)); err != nil {
+		log.Printf("failed removing the digest's collected events: %s\n", err.Error())
+	}
+}
+
+// load collects the digests persisted to the digester's directory, eg. before a restart. Digests started
+// before the last digest time are sent on the next run. Digests of tenants no longer configured are kept
+// in the directory for an operator.
+func (d *digester) load(tenantConfig func(tenant string) (config.Config, bool)) error {
+	if d.dir == "" {
+		return nil
+	}
+	return readStates(d.dir, func(name string, data []byte) error {
+		var state persistedDigest
+		if err := json.Unmarshal(data, &state); err != nil {
+			return err
+		}
+		if len(state.Events) == 0 {
+			return removeState(d.dir, name)
+		}
+		tenant, ok := tenantConfig(state.Tenant)
+		if !ok {
+			return fmt.Errorf("unknown tenant %s", state.Tenant)
+		}
+
+		d.mu.Lock()
+		defer d.mu.Unlock()
+
+		key := digestKey{tenant: strings.ToLower(state.Tenant), alertName: strings.ToLower(state.AlertName)}
+		pending, ok := d.pending[key]
+		if ok {
+			// The digest was persisted twice, eg. when the process exited while restoring it
+			pending.events = append(pending.events, state.Events...)
+			metrics.MetricDigestPendingEvents.Add(float64(len(state.Events)))
+			d.save(key, pending)
+			return removeState(d.dir, name)
+		}
+		d.pending[key] = &pendingDigest{tenant: state.Tenant, tenantConfig: tenant, alertName: state.AlertName, events: state.Events, started: state.Started}
+		metrics.MetricDigestPendingEvents.Add(float64(len(state.Events)))
+		if state.Started.Before(d.lastRun) {
+			d.lastRun = state.Started
+		}
+		return nil
+	})
+}
+
+// buffered returns the number of compliance events waiting for the digest time
+func (d *digester) buffered() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var events int
+	for _, digest := range d.pending {
+		events += len(digest.events)
+	}
+	return events
+}
+
+var (
+	defaultDigesterOnce sync.Once
+	defaultDigester     *digester
+)
+
+// eventDigester returns the digester compliance events of low-risk alerts are collected in
+func eventDigester() *digester {
+	defaultDigesterOnce.Do(func() {
+		defaultDigester = newDigester(time.Now(), config.AppConfig().DigestConfig.Dir)
+	})
+	return defaultDigester
+}
+
+// RestoreDigests collects the compliance events persisted to digestconfig.dir before a restart
+// into their digests
+func RestoreDigests() {
+	if err := eventDigester().load(config.AppConfig().Tenant); err != nil {
+		log.Printf("listeners.RestoreDigests(): %s\n", err.Error())
+	}
+}
+
+// SendDigests creates the digest tickets of the collected compliance events once the daily digest time
+// has passed. It is meant to be run periodically, eg. every minute, by the scheduler. Digests that fail
+// to be created are retried with the next day's.
+func SendDigests() {
+	at, err := config.AppConfig().DigestConfig.TimeOfDay()
+	if err != nil {
+		log.Printf("listeners.SendDigests(): invalid digest time: %v\n", err)
+		return
+	}
+
+	now := time.Now()
+	for _, digest := range eventDigester().take(now, at) {
+		if err := sendDigest(digest, now); err != nil {
+			log.Printf("listeners.SendDigests(): failed creating the %s digest: %v\n", digest.alertName, err)
+			metrics.MetricDigestTickets.With(map[string]string{"result": "failed"}).Inc()
+			eventDigester().restore(digest)
+			continue
+		}
+		eventDigester().sent(digest)
+		metrics.MetricDigestTickets.With(map[string]string{"result": "created"}).Inc()
+	}
+}
+
+// sendDigest creates the digest ticket within the Jira deadline, on the Jira instance the routing rules
+// select for the alert
+func sendDigest(digest pendingDigest, now time.Time) error {
+	ctx, cancel := stageContext(context.Background(), stageJira, config.AppConfig().PipelineConfig.JiraTimeout)
+	defer cancel()
+
+	jiraConfig := digest.tenantConfig.JiraConfigFor(digest.alertName, "")
+	client, err := jira.NewClientContext(ctx, jiraConfig)
+	if err != nil {
+		metrics.MetricJiraClientCreateFailures.With(processInfo{process: "SendDigests"}.LabelInput()).Inc()
+		return err
+	}
+	return jira.CreateDigest(client, jiraConfig, digest.alertName, now, digest.events)
+}
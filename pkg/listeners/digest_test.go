@@ -0,0 +1,101 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package listeners
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/splunk"
+)
+
+func TestDigester(t *testing.T) {
+	start, _ := time.Parse(time.RFC3339, "2021-01-01T10:00:00Z")
+	at := 9 * time.Hour
+	d := newDigester(start, "")
+
+	p := processInfo{tenant: "acme"}
+	d.add(config.Config{}, splunk.AlertDetails{AlertName: "read-only", User: "sre"}, p)
+	d.add(config.Config{}, splunk.AlertDetails{AlertName: "Read-Only", User: "other"}, p)
+	d.add(config.Config{}, splunk.AlertDetails{AlertName: "read-only", User: "sre"}, processInfo{tenant: "other"})
+
+	// The digest time has already passed today when the digester starts
+	if got := d.take(start.Add(time.Hour), at); len(got) != 0 {
+		t.Errorf("take() = %v before the next digest time, want none", got)
+	}
+
+	got := d.take(start.Add(23*time.Hour), at)
+	if len(got) != 2 {
+		t.Fatalf("take() returned %d digests at the digest time, want one for each tenant", len(got))
+	}
+	for _, digest := range got {
+		if want := map[string]int{"acme": 2, "other": 1}[digest.tenant]; len(digest.events) != want {
+			t.Errorf("take() returned %d events for tenant %s, want %d", len(digest.events), digest.tenant, want)
+		}
+	}
+
+	if got := d.take(start.Add(24*time.Hour), at); len(got) != 0 {
+		t.Errorf("take() = %v twice on the same day, want none", got)
+	}
+}
+
+func TestDigesterRestore(t *testing.T) {
+	dir := t.TempDir()
+	start, _ := time.Parse(time.RFC3339, "2021-01-01T10:00:00Z")
+	at := 9 * time.Hour
+
+	collected := newDigester(start, dir)
+	collected.add(config.Config{}, splunk.AlertDetails{AlertName: "read-only", User: "sre"}, processInfo{tenant: "acme"})
+	collected.add(config.Config{}, splunk.AlertDetails{AlertName: "read-only", User: "other"}, processInfo{tenant: "acme"})
+	collected.add(config.Config{}, splunk.AlertDetails{AlertName: "read-only", User: "sre"}, processInfo{tenant: "gone"})
+
+	// After a restart past the digest time, the restored digests keep their events and are sent on the next run
+	tenants := func(tenant string) (config.Config, bool) { return config.Config{}, tenant == "acme" }
+	restored := newDigester(time.Now().Add(48*time.Hour), dir)
+	if err := restored.load(tenants); err != nil {
+		t.Fatalf("load() error = %v", err)
+	}
+	if got := restored.buffered(); got != 2 {
+		t.Fatalf("buffered() = %d after restoring the digests, want the 2 events of tenant acme", got)
+	}
+	digests := restored.take(time.Now().Add(72*time.Hour), at)
+	if len(digests) != 1 || len(digests[0].events) != 2 || digests[0].tenant != "acme" {
+		t.Fatalf("take() = %+v, want the restored digest of tenant acme", digests)
+	}
+
+	// A digest that failed to be sent is kept, and removed once sent
+	restored.restore(digests[0])
+	again := newDigester(time.Now(), dir)
+	if err := again.load(tenants); err != nil {
+		t.Fatalf("load() error = %v", err)
+	}
+	if got := again.buffered(); got != 2 {
+		t.Errorf("buffered() = %d after restoring a digest that failed to be sent, want 2", got)
+	}
+	again.sent(digests[0])
+
+	// The digest of the unknown tenant is kept for an operator
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("digest directory has %d files, want the unknown tenant's digest", len(files))
+	}
+}
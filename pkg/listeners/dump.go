@@ -0,0 +1,81 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package listeners
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/ldap"
+)
+
+// stateDump is the internal state of the router logged on SIGUSR1, for operators without the status endpoint
+type stateDump struct {
+	routerStatus
+	// Buffered is the number of compliance events held in memory by the aggregation windows and the digests
+	Buffered bufferedEvents `json:"buffered"`
+	// LDAPCache is the user lookup cache, when users are looked up in LDAP
+	LDAPCache *ldap.CacheStats `json:"ldapCache,omitempty"`
+}
+
+// bufferedEvents is the number of compliance events held in memory, which are lost if the process exits
+type bufferedEvents struct {
+	Aggregation int `json:"aggregation"`
+	Digest      int `json:"digest"`
+}
+
+// currentState collects the internal state of the router
+func currentState() stateDump {
+	state := stateDump{
+		routerStatus: currentStatus(),
+		Buffered: bufferedEvents{
+			Aggregation: eventAggregator().buffered(),
+			Digest:      eventDigester().buffered(),
+		},
+	}
+	if config.AppConfig().IdentityProvider() == config.IdentityProviderLDAP {
+		cacheStats := ldap.LookupCacheStats()
+		state.LDAPCache = &cacheStats
+	}
+	return state
+}
+
+// DumpState logs the internal state of the router: the queue depths, the Jira circuit breakers, the health of the
+// dependencies, the buffered compliance events and the lookup cache statistics
+func DumpState() {
+	body, err := json.Marshal(currentState())
+	if err != nil {
+		log.Printf("failed rendering the state dump: %s\n", err.Error())
+		return
+	}
+	log.Printf("INFO: state dump: %s", body)
+}
+
+// WatchStateDumps logs the internal state of the router each time the process receives SIGUSR1
+func WatchStateDumps() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1)
+	go func() {
+		for range signals {
+			DumpState()
+		}
+	}()
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package listeners
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+)
+
+func TestDumpState(t *testing.T) {
+	var out bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&out)
+
+	config.SetAppConfig(config.Config{DryRun: true})
+	defer func() { config.SetAppConfig(config.Config{}) }()
+
+	DumpState()
+
+	_, body, ok := strings.Cut(strings.TrimSpace(out.String()), "state dump: ")
+	if !ok {
+		t.Fatalf("DumpState() logged %q, want a state dump", out.String())
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal([]byte(body), &got); err != nil {
+		t.Fatalf("DumpState() logged invalid JSON %q: %v", body, err)
+	}
+	for _, key := range []string{"mode", "dependencies", "queues", "buffered"} {
+		if _, ok := got[key]; !ok {
+			t.Errorf("DumpState() = %v, missing %v", got, key)
+		}
+	}
+	if _, ok := got["ldapCache"]; ok {
+		t.Errorf("DumpState() = %v, includes the LDAP cache without LDAP", got)
+	}
+}
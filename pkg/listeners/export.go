@@ -0,0 +1,133 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package listeners
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/openshift/compliance-audit-router/pkg/audit"
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/metrics"
+)
+
+const exportPath = "/api/v1/export"
+
+// exportDateLayout is the layout of dates in the export query parameters
+const exportDateLayout = "2006-01-02"
+
+// ExportHandler streams the audit log records of processed alerts and their ticket outcomes, selected by
+// the query parameters, as CSV or JSON lines, as evidence for auditors without Splunk or Jira access
+func ExportHandler(w http.ResponseWriter, r *http.Request) {
+	var p = processInfo{process: "ExportHandler"}
+
+	path := config.AppConfig().AuditConfig.Path
+	if path == "" {
+		setResponse(w, statusInfo{code: http.StatusNotFound, msg: []string{"No audit log is configured"}}, p)
+		return
+	}
+
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = audit.FormatCSV
+	}
+	if format != audit.FormatCSV && format != audit.FormatJSONL {
+		setResponse(w, statusInfo{code: http.StatusBadRequest, msg: []string{"format must be csv or jsonl"}}, p)
+		return
+	}
+
+	filter, err := exportFilter(query)
+	if err != nil {
+		setResponse(w, statusInfo{code: http.StatusBadRequest, msg: []string{err.Error()}}, p)
+		return
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		setResponse(w, statusInfo{code: http.StatusNotFound, msg: []string{"The audit log has no records yet"}}, p)
+		return
+	}
+	if err != nil {
+		log.Printf("failed opening the audit log: %s\n", err.Error())
+		setResponse(w, status500, p)
+		return
+	}
+	defer file.Close()
+
+	contentType := "text/csv"
+	if format == audit.FormatJSONL {
+		contentType = "application/x-ndjson"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "compliance-audit-router-export."+format))
+	w.WriteHeader(http.StatusOK)
+
+	labels := p.LabelInput()
+	labels["code"] = http.StatusText(http.StatusOK)
+	metrics.MetricHTTPResponses.With(labels).Inc()
+
+	// The status has been sent, so a failure midway can only be logged; the export ends early
+	if err := audit.Export(file, w, format, filter); err != nil {
+		log.Printf("failed exporting the audit log: %s\n", err.Error())
+	}
+}
+
+// exportFilter parses the filter of an export from the query parameters: from and to, as dates like
+// 2024-01-31, inclusive, or RFC 3339 times, action, which may be repeated, alert, user and issue
+func exportFilter(query url.Values) (audit.Filter, error) {
+	filter := audit.Filter{
+		Actions:  query["action"],
+		Alert:    query.Get("alert"),
+		User:     query.Get("user"),
+		IssueKey: query.Get("issue"),
+	}
+
+	if from := query.Get("from"); from != "" {
+		t, _, err := parseExportTime(from)
+		if err != nil {
+			return audit.Filter{}, fmt.Errorf("from must be a date like 2024-01-31 or an RFC 3339 time: %s", from)
+		}
+		filter.From = t
+	}
+	if to := query.Get("to"); to != "" {
+		t, isDate, err := parseExportTime(to)
+		if err != nil {
+			return audit.Filter{}, fmt.Errorf("to must be a date like 2024-01-31 or an RFC 3339 time: %s", to)
+		}
+		// A date includes the whole day
+		if isDate {
+			t = t.Add(24 * time.Hour)
+		}
+		filter.To = t
+	}
+
+	return filter, nil
+}
+
+// parseExportTime parses a date, in UTC, or an RFC 3339 time, reporting whether it was a date
+func parseExportTime(value string) (time.Time, bool, error) {
+	if t, err := time.Parse(exportDateLayout, value); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	return t, false, err
+}
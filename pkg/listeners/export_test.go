@@ -0,0 +1,87 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package listeners
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openshift/compliance-audit-router/pkg/audit"
+	"github.com/openshift/compliance-audit-router/pkg/config"
+)
+
+func TestExportFilter(t *testing.T) {
+	query, _ := url.ParseQuery("from=2024-01-01&to=2024-01-31&action=ticket_created&action=auto_approved&user=sre")
+	got, err := exportFilter(query)
+	if err != nil {
+		t.Fatalf("exportFilter() unexpected error: %v", err)
+	}
+	if want := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC); !got.From.Equal(want) {
+		t.Errorf("exportFilter() from = %v, want %v", got.From, want)
+	}
+	// The to date includes the whole day
+	if want := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC); !got.To.Equal(want) {
+		t.Errorf("exportFilter() to = %v, want %v", got.To, want)
+	}
+	if len(got.Actions) != 2 || got.User != "sre" {
+		t.Errorf("exportFilter() = %+v, want both actions and the user", got)
+	}
+
+	if _, err := exportFilter(url.Values{"from": {"last week"}}); err == nil {
+		t.Errorf("exportFilter() expected an error for an invalid from date")
+	}
+}
+
+func TestExportHandler(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	config.SetAppConfig(config.Config{AuditConfig: config.AuditConfig{Path: path}})
+	defer func() { config.SetAppConfig(config.Config{}) }()
+
+	auditLog, err := audit.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := auditLog.Append(audit.Record{Time: time.Now().UTC(), Action: audit.ActionTicketCreated, IssueKey: "CAR-1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		target string
+		code   int
+		want   string
+	}{
+		{exportPath, http.StatusOK, "ticket_created,CAR-1"},
+		{exportPath + "?format=jsonl&issue=CAR-1", http.StatusOK, `"issueKey":"CAR-1"`},
+		{exportPath + "?format=xml", http.StatusBadRequest, "format must be csv or jsonl"},
+	}
+	for _, tt := range tests {
+		recorder := httptest.NewRecorder()
+		ExportHandler(recorder, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+		if recorder.Code != tt.code {
+			t.Errorf("%s returned wrong status code: got %v want %v", tt.target, recorder.Code, tt.code)
+		}
+		if body := recorder.Body.String(); !strings.Contains(body, tt.want) {
+			t.Errorf("%s returned %v, want it to contain %v", tt.target, body, tt.want)
+		}
+	}
+}
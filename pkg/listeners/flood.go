@@ -0,0 +1,116 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package listeners
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/jira"
+	"github.com/openshift/compliance-audit-router/pkg/metrics"
+)
+
+// ticketLimiter counts the tickets created for each user in a sliding window. The counts are held
+// in memory only, so they start over when the process restarts.
+type ticketLimiter struct {
+	mu      sync.Mutex
+	created map[string][]time.Time
+}
+
+func newTicketLimiter() *ticketLimiter {
+	return &ticketLimiter{created: map[string][]time.Time{}}
+}
+
+// allow reports whether fewer than max tickets were created for the user within the window before now,
+// and if so counts a ticket created now
+func (l *ticketLimiter) allow(user string, max int, window time.Duration, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	user = strings.ToLower(user)
+	var recent []time.Time
+	for _, t := range l.created[user] {
+		if now.Sub(t) < window {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= max {
+		l.created[user] = recent
+		return false
+	}
+	l.created[user] = append(recent, now)
+	return true
+}
+
+var (
+	limiterOnce sync.Once
+	limiter     *ticketLimiter
+)
+
+// userTicketLimiter returns the limiter of the tickets created for each user
+func userTicketLimiter() *ticketLimiter {
+	limiterOnce.Do(func() {
+		limiter = newTicketLimiter()
+	})
+	return limiter
+}
+
+// overTicketLimit reports whether the user is over the cap on their tickets, counting the ticket otherwise
+func overTicketLimit(user string) bool {
+	floodConfig := config.AppConfig().FloodConfig
+	if floodConfig.MaxTickets <= 0 {
+		return false
+	}
+	return !userTicketLimiter().allow(user, floodConfig.MaxTickets, floodConfig.Window, time.Now())
+}
+
+// appendToRecentTicket appends the event of the ticket to the user's most recent open ticket, for users over
+// the cap on their tickets. It reports whether the event was appended; when the user has no open ticket,
+// the ticket is to be created as usual.
+func appendToRecentTicket(ctx context.Context, jiraConfig config.JiraConfig, ticket jira.Ticket, p processInfo) (bool, error) {
+	jiraCtx, jiraCancel := stageContext(ctx, stageJira, config.AppConfig().PipelineConfig.JiraTimeout)
+	defer jiraCancel()
+
+	client, err := jira.NewClientContext(jiraCtx, jiraConfig)
+	if err != nil {
+		p.logf("failed creating Jira client for %s: %s\n", jiraConfig.Host, err.Error())
+		metrics.MetricJiraClientCreateFailures.With(p.LabelInput()).Inc()
+		metrics.MetricFloodEvents.With(map[string]string{"result": "failed"}).Inc()
+		return false, err
+	}
+
+	key, err := jira.AppendToRecentTicket(client, jiraConfig, ticket)
+	if errors.Is(err, jira.ErrNoOpenTicket) {
+		p.logf("user %s is over the ticket limit but has no open ticket; creating a ticket", ticket.User)
+		metrics.MetricFloodEvents.With(map[string]string{"result": "created"}).Inc()
+		return false, nil
+	}
+	if err != nil {
+		recordDeadline(jiraCtx)
+		p.logf("failed appending the event to the recent ticket of %s: %s", ticket.User, err.Error())
+		metrics.MetricFloodEvents.With(map[string]string{"result": "failed"}).Inc()
+		return false, err
+	}
+
+	p.logf("user %s is over the ticket limit; appended the event to issue %s", ticket.User, key)
+	metrics.MetricFloodEvents.With(map[string]string{"result": "appended"}).Inc()
+	return true, nil
+}
@@ -0,0 +1,49 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package listeners
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTicketLimiter(t *testing.T) {
+	limiter := newTicketLimiter()
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		user  string
+		after time.Duration
+		want  bool
+	}{
+		{"First ticket", "automation", 0, true},
+		{"Second ticket", "Automation", time.Minute, true},
+		{"Over the cap", "automation", 2 * time.Minute, false},
+		{"Other user", "sre", 2 * time.Minute, true},
+		{"Still over the cap", "automation", 59 * time.Minute, false},
+		{"First ticket left the window", "automation", time.Hour, true},
+		{"Over the cap again", "automation", time.Hour + time.Second, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := limiter.allow(tt.user, 2, time.Hour, start.Add(tt.after)); got != tt.want {
+				t.Errorf("allow(%v) = %v, want %v", tt.user, got, tt.want)
+			}
+		})
+	}
+}
@@ -17,21 +17,33 @@ limitations under the License.
 package listeners
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
+	gojira "github.com/andygrunwald/go-jira"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"github.com/openshift/compliance-audit-router/pkg/audit"
 	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/enrich"
+	"github.com/openshift/compliance-audit-router/pkg/health"
 	"github.com/openshift/compliance-audit-router/pkg/helpers"
+	"github.com/openshift/compliance-audit-router/pkg/identity"
 	"github.com/openshift/compliance-audit-router/pkg/jira"
-	"github.com/openshift/compliance-audit-router/pkg/ldap"
+	"github.com/openshift/compliance-audit-router/pkg/logging"
 	"github.com/openshift/compliance-audit-router/pkg/metrics"
+	"github.com/openshift/compliance-audit-router/pkg/oncall"
+	"github.com/openshift/compliance-audit-router/pkg/risk"
 	"github.com/openshift/compliance-audit-router/pkg/splunk"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
@@ -40,15 +52,40 @@ import (
 // data from. All error messages should be logged to the application log.
 const genericErrorMsg = "The request could not be completed. Please contact the system administrator."
 
+// correlationIDHeader is the response header holding the UUID the request was logged with,
+// for callers to quote when asking why a request didn't have the expected result
+const correlationIDHeader = "X-Correlation-ID"
+
+// Paths of the webhook endpoints, whose request bodies are validated against the OpenAPI specification
+const (
+	alertPath       = "/api/v1/alert"
+	jiraWebhookPath = "/api/v1/jira_webhook"
+)
+
 type Listener struct {
 	Path        string
 	Methods     []string
 	HandlerFunc http.HandlerFunc
+	// Scope is the API key scope the requests need, if any. The admin API's handlers check their scopes themselves.
+	Scope string
 }
 
 type processInfo struct {
 	uuid    string
 	process string
+	// tenant is the tenant the alert is processed for
+	tenant string
+	// aggregate collects the compliance events of low-risk alerts into the daily digest, and buffers the
+	// others in the aggregation window when one is configured, instead of creating their tickets right away
+	aggregate bool
+}
+
+// logf logs the message prefixed with the UUID of the request, for tracing the processing of concurrent requests
+func (p processInfo) logf(format string, v ...interface{}) {
+	if p.uuid != "" {
+		format = p.uuid + " " + format
+	}
+	log.Printf(format, v...)
 }
 
 func (p processInfo) LabelInput() map[string]string {
@@ -63,13 +100,17 @@ type statusInfo struct {
 var (
 	status500 = statusInfo{code: http.StatusInternalServerError}
 	status200 = statusInfo{code: http.StatusOK}
+	// statusJiraUnavailable fails an alert right away while the circuit breaker of its Jira instance is open, for Splunk to retry
+	statusJiraUnavailable = statusInfo{code: http.StatusServiceUnavailable, msg: []string{"jira unavailable, try again later"}}
+	// statusSpooled acknowledges an alert spooled to be processed once Jira recovers
+	statusSpooled = statusInfo{code: http.StatusOK, msg: []string{"spooled"}}
 )
 
 var Listeners = []Listener{
 	{
 		Path:        "/readyz",
 		Methods:     []string{http.MethodGet},
-		HandlerFunc: RespondOKHandler,
+		HandlerFunc: ReadyHandler,
 	},
 	{
 		Path:        "/healthz",
@@ -77,26 +118,105 @@ var Listeners = []Listener{
 		HandlerFunc: RespondOKHandler,
 	},
 	{
-		Path:        "/api/v1/alert",
+		Path:        alertPath,
 		Methods:     []string{http.MethodPost},
 		HandlerFunc: ProcessAlertHandler,
+		Scope:       config.ScopeAlertSubmit,
 	},
 	{
-		Path:        "/api/v1/jira_webhook",
+		Path:        validatePath,
+		Methods:     []string{http.MethodPost},
+		HandlerFunc: ValidateAlertHandler,
+		Scope:       config.ScopeAlertSubmit,
+	},
+	{
+		Path:        alertPath + "/{tenant}",
+		Methods:     []string{http.MethodPost},
+		HandlerFunc: ProcessAlertHandler,
+		Scope:       config.ScopeAlertSubmit,
+	},
+	{
+		Path:        jiraWebhookPath,
 		Methods:     []string{http.MethodPost},
 		HandlerFunc: ProcessJiraWebhook,
+		Scope:       config.ScopeJiraWebhook,
+	},
+	{
+		Path:        "/api/v1/config",
+		Methods:     []string{http.MethodGet},
+		HandlerFunc: ConfigHandler,
+		Scope:       config.ScopeConfigRead,
+	},
+	{
+		Path:        statusPath,
+		Methods:     []string{http.MethodGet},
+		HandlerFunc: StatusHandler,
+		Scope:       config.ScopeConfigRead,
+	},
+	{
+		Path:        exportPath,
+		Methods:     []string{http.MethodGet},
+		HandlerFunc: ExportHandler,
+		Scope:       config.ScopeAuditRead,
+	},
+	{
+		Path:        adminTemplatesPath,
+		Methods:     []string{http.MethodGet},
+		HandlerFunc: TemplatesHandler,
+	},
+	{
+		Path:        adminTemplatesPath,
+		Methods:     []string{http.MethodPut},
+		HandlerFunc: UpdateTemplatesHandler,
+	},
+	{
+		Path:        adminRollbackPath,
+		Methods:     []string{http.MethodPost},
+		HandlerFunc: RollbackTemplatesHandler,
+	},
+	{
+		Path:        adminSettingsPath,
+		Methods:     []string{http.MethodGet},
+		HandlerFunc: SettingsHandler,
+	},
+	{
+		Path:        adminSettingsPath,
+		Methods:     []string{http.MethodPut},
+		HandlerFunc: UpdateSettingsHandler,
+	},
+	{
+		Path:        "/openapi.json",
+		Methods:     []string{http.MethodGet},
+		HandlerFunc: OpenAPIHandler,
 	},
 }
 
-// InitRoutes initializes routes from the defined Listeners
-func InitRoutes(router *chi.Mux) {
+// InitRoutes initializes routes from the defined Listeners, requiring the API key scopes of the listeners,
+// with the metrics of the registries on /metrics, or those of the global default registry when there are none
+func InitRoutes(router *chi.Mux, registries ...*prometheus.Registry) {
 	for _, listener := range Listeners {
+		handler := listener.HandlerFunc
+		if listener.Scope != "" {
+			handler = requireScope(listener.Scope, handler)
+		}
 		for _, method := range listener.Methods {
-			router.Method(method, listener.Path, listener.HandlerFunc)
+			router.Method(method, listener.Path, handler)
 		}
 	}
 	// Add the Prometheus metrics endpoint
-	router.Method(http.MethodGet, "/metrics", promhttp.Handler())
+	router.Method(http.MethodGet, "/metrics", metricsHandler(registries))
+}
+
+// metricsHandler serves the metrics of the registries, or of the global default registry when there are none
+func metricsHandler(registries []*prometheus.Registry) http.Handler {
+	if len(registries) == 0 {
+		return promhttp.Handler()
+	}
+	gatherers := make([]prometheus.Gatherer, 0, len(registries)-1)
+	for _, registry := range registries[1:] {
+		gatherers = append(gatherers, registry)
+	}
+	return metrics.Handler(registries[0], gatherers...)
 }
 
 // RespondOKHandler replies with a 200 OK and "OK" text to any request, for health checks
@@ -104,11 +224,62 @@ func RespondOKHandler(w http.ResponseWriter, _ *http.Request) {
 	setResponse(w, status200, processInfo{process: "RespondOKHandler"})
 }
 
+// ReadyHandler replies with a 200 OK and the mode of operation, eg. "ok, dry-run mode", followed by the Jira instances
+// whose circuit breaker is open, or 503 Service Unavailable while the alert queue or the Jira retry backlog exceeds its threshold. With the deep query parameter set to true,
+// it also checks that the identity provider's directory is reachable, replying 503 Service Unavailable if not.
+// The result of the deep check is reused for readinesscachettl, so frequent probes don't each query the directory.
+func ReadyHandler(w http.ResponseWriter, r *http.Request) {
+	var p = processInfo{process: "ReadyHandler"}
+	appConfig := config.AppConfig()
+
+	pipelineConfig := appConfig.PipelineConfig
+	if pipelineConfig.UnreadyQueueDepth > 0 || pipelineConfig.UnreadyRetryBacklog > 0 {
+		if err := checkSaturation(pipelineConfig, alertPipeline().queued(), jira.RetryBacklog()); err != nil {
+			log.Printf("readiness check failed: %s\n", err.Error())
+			setResponse(w, statusInfo{code: http.StatusServiceUnavailable, msg: []string{"saturated", err.Error()}}, p)
+			return
+		}
+	}
+
+	if r.URL.Query().Get("deep") == "true" {
+		if err := deepReadiness.check(appConfig.ReadinessCacheTTL, time.Now(), checkDependencies); err != nil {
+			log.Printf("deep readiness check failed: %s\n", err.Error())
+			setResponse(w, statusInfo{code: http.StatusServiceUnavailable, msg: []string{"identity provider unavailable"}}, p)
+			return
+		}
+	}
+
+	msg := []string{"ok", appConfig.Mode() + " mode"}
+	msg = append(msg, circuitStatus(jira.OpenCircuits(), appConfig.SpoolConfig.Dir != "")...)
+	setResponse(w, statusInfo{code: http.StatusOK, msg: msg}, p)
+}
+
+// ConfigHandler replies with the effective configuration as YAML, with the credentials masked
+func ConfigHandler(w http.ResponseWriter, _ *http.Request) {
+	var p = processInfo{process: "ConfigHandler"}
+
+	body, err := config.AppConfig().RedactedYAML()
+	if err != nil {
+		log.Printf("failed rendering the effective configuration: %s\n", err.Error())
+		setResponse(w, status500, p)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/yaml")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+
+	labels := p.LabelInput()
+	labels["code"] = http.StatusText(http.StatusOK)
+	metrics.MetricHTTPResponses.With(labels).Inc()
+}
+
 // ProcessAlertHandler is the main logic processing alerts received from Splunk
 func ProcessAlertHandler(w http.ResponseWriter, r *http.Request) {
-	if config.AppConfig.Verbose {
-		log.Printf("listeners.ProcessAlertHandler(): received http request: %+v", r)
-	}
+	// The alert is processed with the configuration active when it was received, even if it is reloaded meanwhile
+	appConfig := config.AppConfig()
+
+	logging.Debugf(logging.Listeners, "listeners.ProcessAlertHandler(): received http request: %s", helpers.RedactRequest(r, appConfig.RedactFields))
 
 	// Assign a UUID to the event and set process info for metrics/logging
 	var p processInfo = processInfo{
@@ -116,65 +287,106 @@ func ProcessAlertHandler(w http.ResponseWriter, r *http.Request) {
 		process: "ProcessAlertHandler",
 	}
 
-	// Prefix log messages with uuid plus a space for easier tracing
-	log.SetPrefix(p.uuid + " ")
-	defer log.SetPrefix("")
-
 	// Process the Received Webhook
 	metrics.MetricSplunkWebhookReceived.With(p.LabelInput()).Inc()
 
 	var webhook splunk.Webhook
 
-	decodeJSONerr := helpers.DecodeJSONRequestBody(w, r, &webhook)
+	decodeJSONerr := decodeRequestBody(w, r, alertPath, &webhook)
 	if decodeJSONerr != nil {
 		var mr *helpers.MalformedRequest
 		if errors.As(decodeJSONerr, &mr) {
 			ple := p.LabelInput()
 			ple["error_type"] = "malformed_request"
 			metrics.MetricSplunkWebhookProcessFailures.With(ple).Inc()
-			log.Printf("received malformed request: %s\n", mr.Msg)
+			p.logf("received malformed request: %s\n", mr.Msg)
 			// This is a client error, so we return the status code and message
 			setResponse(w, statusInfo{code: mr.Status, msg: []string{mr.Msg}}, p)
 		} else {
 			ple := p.LabelInput()
 			ple["error_type"] = "unknown"
 			metrics.MetricSplunkWebhookProcessFailures.With(ple).Inc()
-			log.Printf("failed decoding JSON request body: %s\n", decodeJSONerr.Error())
+			p.logf("failed decoding JSON request body: %s\n", decodeJSONerr.Error())
 			setResponse(w, status500, p)
 		}
 		return
 	}
 
-	if config.AppConfig.Verbose {
-		log.Printf("listeners.ProcessAlertHandler(): JSON data decoded to &splunk.Webhook : %+v", webhook)
+	logging.Debugf(logging.Listeners, "listeners.ProcessAlertHandler(): JSON data decoded to &splunk.Webhook : %s", helpers.RedactJSON(webhook, appConfig.RedactFields))
+
+	// The alert is processed with the settings of its tenant, if any
+	tenant := alertTenant(r, webhook)
+	tenantConfig, ok := appConfig.Tenant(tenant)
+	if !ok {
+		p.logf("received alert %s for unknown tenant: %s\n", webhook.Sid, tenant)
+		ple := p.LabelInput()
+		ple["error_type"] = "unknown_tenant"
+		metrics.MetricSplunkWebhookProcessFailures.With(ple).Inc()
+		setResponse(w, statusInfo{code: http.StatusNotFound, msg: []string{"Unknown tenant"}}, p)
+		return
 	}
+	if !appConfig.Tenants[strings.ToLower(tenant)].AllowsSearch(webhook.SearchName) {
+		p.logf("rejecting alert %s: tenant %s doesn't accept alerts from search %s\n", webhook.Sid, tenant, webhook.SearchName)
+		ple := p.LabelInput()
+		ple["error_type"] = "search_not_allowed"
+		metrics.MetricSplunkWebhookProcessFailures.With(ple).Inc()
+		setResponse(w, statusInfo{code: http.StatusForbidden, msg: []string{"Search not allowed for tenant"}}, p)
+		return
+	}
+	p.tenant, p.aggregate = tenant, true
+
+	// Processing the alert is cancelled when Splunk disconnects or the alert's deadline passes,
+	// so a stuck dependency frees the worker
+	ctx, cancel := stageContext(r.Context(), stageAlert, appConfig.PipelineConfig.AlertTimeout)
+	defer cancel()
+
+	// Wait for a worker, rejecting the alert when too many alerts are already waiting
+	if err := alertPipeline().acquire(ctx); err != nil {
+		ple := p.LabelInput()
+		if errors.Is(err, errQueueFull) {
+			ple["error_type"] = "queue_full"
+			p.logf("rejecting alert %s: the processing queue is full\n", webhook.Sid)
+		} else {
+			ple["error_type"] = "cancelled"
+			recordDeadline(ctx)
+			p.logf("rejecting alert %s: cancelled while waiting for a worker: %s\n", webhook.Sid, context.Cause(ctx))
+		}
+		metrics.MetricSplunkWebhookProcessFailures.With(ple).Inc()
+		setResponse(w, statusInfo{code: http.StatusServiceUnavailable, msg: []string{"too many alerts queued, try again later"}}, p)
+		return
+	}
+	defer alertPipeline().release()
 
 	// Create a Jira client
 	// This may be used to create issues on failures, too
-	jiraClient, jiraClientErr := jira.DefaultClient()
+	jiraClient, jiraClientErr := jira.NewClientContext(ctx, tenantConfig.JiraConfig)
 	if jiraClientErr != nil {
-		log.Printf("failed creating Jira client: %s\n", jiraClientErr.Error())
+		p.logf("failed creating Jira client: %s\n", jiraClientErr.Error())
 		metrics.MetricJiraClientCreateFailures.With(p.LabelInput()).Inc()
 		setResponse(w, status500, p)
 		return
 	}
 
 	// Retrieve search results from webhook
-	log.Println("retrieving alert from Splunk:", webhook.Sid)
+	p.logf("retrieving alert from Splunk: %v\n", webhook.Sid)
 	metrics.MetricSplunkAlertSIDReceived.With(p.LabelInput()).Inc()
 
+	splunkCtx, splunkCancel := stageContext(ctx, stageSplunk, appConfig.PipelineConfig.SplunkTimeout)
+	defer splunkCancel()
+
 	var searchResults splunk.Alert
-	searchResults, searchErr := splunk.Server(config.AppConfig.SplunkConfig).RetrieveSearchFromAlert(webhook.Sid)
+	searchResults, searchErr := splunk.Server(tenantConfig.SplunkConfig).RetrieveSearchFromAlert(splunkCtx, webhook.Sid)
 
 	if searchErr != nil {
-		log.Printf("error retrieving search results from Splunk: %s", searchErr.Error())
+		recordDeadline(splunkCtx)
+		p.logf("error retrieving search results from Splunk: %s", searchErr.Error())
 		ple := p.LabelInput()
 		ple["error_type"] = "retrieval_error"
 		metrics.MetricSplunkSearchResultQueryFailures.With(ple).Inc()
 
 		alertJson, jsonErr := json.MarshalIndent(webhook, "", "  ")
 		if jsonErr != nil {
-			log.Printf("error marshalling webhook data to JSON: %s", jsonErr.Error())
+			p.logf("error marshalling webhook data to JSON: %s", jsonErr.Error())
 		}
 
 		ticketDetails := fmt.Sprintf(
@@ -193,10 +405,9 @@ func ProcessAlertHandler(w http.ResponseWriter, r *http.Request) {
 					"The error was: %s\n", jsonErr.Error())
 		}
 
-		createErr := jira.CreateTicket(jiraClient.User, jiraClient.Issue, "", "", ticketDetails)
+		createErr := jira.CreateTicket(ctx, jiraClient, tenantConfig.JiraConfig, jira.Ticket{Description: ticketDetails, SummaryTemplate: tenantConfig.SummaryTemplate})
 		if createErr != nil {
-			log.Printf("failed creating Jira ticket: %s", createErr.Error())
-			metrics.MetricJiraIssueCreateFailures.With(p.LabelInput()).Inc()
+			p.logf("failed creating Jira ticket: %s", createErr.Error())
 			setResponse(w, status500, p)
 			return
 		}
@@ -209,61 +420,455 @@ func ProcessAlertHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Process each result in the alert search results
-	for _, complianceEvent := range searchResults.Details() {
-		log.Println(complianceEvent)
-		metrics.MetricComplianceEventsFound.With(p.LabelInput()).Inc()
-
-		var user string = complianceEvent.User
-		var manager string = ""
-
-		// If LDAP is enabled, look up the user and manager
-		// This may be deprecated in the future
-		if config.AppConfig.LDAPConfig.Enabled {
-			var ldapErr error
-			user, manager, ldapErr = ldap.LookupUser(complianceEvent.User)
-			if ldapErr != nil {
-				log.Printf("failed ldap lookup: %s\n", ldapErr.Error())
-				metrics.MetricLDAPLookupFailures.With(p.LabelInput()).Inc()
-
-				ticketDetails := fmt.Sprintf(
-					"A Compliance Alert was received from Splunk, but the user details could not be retrieved from LDAP."+
-						"Please review and assign accordingly:\n"+
-						"Compliance Data: %+v\n"+
-						"\nError: %s\n", complianceEvent, ldapErr.Error(),
-				)
-
-				createErr := jira.CreateTicket(jiraClient.User, jiraClient.Issue, "", "", ticketDetails)
-				if createErr != nil {
-					log.Printf("failed creating Jira ticket: %s", createErr.Error())
-					metrics.MetricJiraIssueCreateFailures.With(p.LabelInput()).Inc()
-					setResponse(w, status500, p)
-					return
-				}
-				// Increment the metric for Jira issues created to track errors
-				metrics.MetricJiraErrorIssuesCreated.With(p.LabelInput()).Inc()
-
-				// Return a 500 for any error case
-				setResponse(w, status500, p)
-				return
-			}
+	// While Jira is unavailable, the alert is spooled to disk and processed once it recovers, or failed right away
+	if jira.CircuitOpen(tenantConfig.JiraConfig) {
+		if spoolEvents(p.tenant, searchResults, nil) {
+			setResponse(w, statusSpooled, p)
+			return
 		}
+		setResponse(w, statusJiraUnavailable, p)
+		return
+	}
 
-		// Create a Jira issue for the compliance event
-		jiraCreateErr := jira.CreateTicket(jiraClient.User, jiraClient.Issue, user, manager, complianceEvent.Body())
-		if jiraCreateErr != nil {
-			log.Printf("failed creating Jira ticket: %s", jiraCreateErr.Error())
-			metrics.MetricJiraIssueCreateFailures.With(p.LabelInput()).Inc()
-			setResponse(w, status500, p)
+	if unprocessed, err := processAlert(ctx, &tenantConfig, jiraClient, searchResults, p); err != nil {
+		// Only the events left unprocessed are spooled, so the tickets already created aren't created again
+		if len(unprocessed) > 0 && jira.CircuitOpen(tenantConfig.JiraConfig) && spoolEvents(p.tenant, searchResults, unprocessed) {
+			setResponse(w, statusSpooled, p)
 			return
 		}
+		setResponse(w, status500, p)
+		return
 	}
 
 	// Everything worked!
-	metrics.MetricComplianceEventsProcessed.With(p.LabelInput()).Inc()
 	setResponse(w, status200, p)
 }
 
+// tenantField is the field of an alert's search result selecting its tenant, when the path doesn't
+const tenantField = "tenant"
+
+// alertTenant returns the tenant of the alert, from the /api/v1/alert/{tenant} path or else the tenant
+// field of the alert's search result, or an empty string for alerts processed with the top level settings
+func alertTenant(r *http.Request, webhook splunk.Webhook) string {
+	if tenant := chi.URLParam(r, "tenant"); tenant != "" {
+		return tenant
+	}
+	tenant, _ := webhook.Result[tenantField].(string)
+	return tenant
+}
+
+// ProcessAlert creates the Jira tickets for the compliance events of an alert's search results,
+// like ProcessAlertHandler, eg. to replay a saved alert. Processing is cancelled with the context.
+func ProcessAlert(ctx context.Context, searchResults splunk.Alert) error {
+	p := processInfo{
+		uuid:    uuid.New().String(),
+		process: "ProcessAlert",
+	}
+
+	appConfig := config.AppConfig()
+	ctx, cancel := stageContext(ctx, stageAlert, appConfig.PipelineConfig.AlertTimeout)
+	defer cancel()
+
+	jiraClient, err := jira.NewClientContext(ctx, appConfig.JiraConfig)
+	if err != nil {
+		metrics.MetricJiraClientCreateFailures.With(p.LabelInput()).Inc()
+		return fmt.Errorf("failed creating Jira client: %w", err)
+	}
+
+	_, err = processAlert(ctx, appConfig, jiraClient, searchResults, p)
+	return err
+}
+
+// processAlert resolves the users of the compliance events in the search results and creates their Jira tickets
+// with the settings of the alert's tenant, or collects the events into the daily digest or aggregation window when enabled.
+// Duplicates of the events processed within the suppression window are dropped; the events of a failed alert left
+// unprocessed are forgotten, so they are processed again when the alert is retried. When it fails, it returns the
+// events left unprocessed, whose tickets weren't created.
+func processAlert(ctx context.Context, tenantConfig *config.Config, jiraClient *gojira.Client, searchResults splunk.Alert, p processInfo) ([]splunk.AlertDetails, error) {
+	events := searchResults.Details()
+	for i := range events {
+		events[i].SearchID = searchResults.SearchID
+	}
+
+	// Events with the same content as an event processed within the suppression window are duplicates
+	dedupWindow := tenantConfig.DedupConfig.Window
+	if dedupWindow > 0 {
+		events = eventDeduplicator().filter(p.tenant, events, dedupWindow, time.Now())
+	}
+
+	if p.aggregate {
+		events = collectEvents(*tenantConfig, events, p)
+	}
+
+	if len(events) > 0 {
+		if unprocessed, err := processEvents(ctx, tenantConfig, jiraClient, events, p); err != nil {
+			// Only the events left unprocessed are retried when some of the events failed
+			if dedupWindow > 0 {
+				eventDeduplicator().forget(p.tenant, unprocessed)
+			}
+			return unprocessed, err
+		}
+	}
+
+	metrics.MetricComplianceEventsProcessed.With(p.LabelInput()).Inc()
+	return nil, nil
+}
+
+// collectEvents collects the events of low-risk alerts into the daily digest, and buffers the others in the
+// aggregation window when one is configured. It returns the events whose tickets are to be created right away.
+func collectEvents(tenantConfig config.Config, events []splunk.AlertDetails, p processInfo) []splunk.AlertDetails {
+	var remaining []splunk.AlertDetails
+	for _, complianceEvent := range events {
+		switch {
+		case tenantConfig.DigestConfig.Includes(complianceEvent.AlertName):
+			eventDigester().add(tenantConfig, complianceEvent, p)
+		case tenantConfig.AggregationConfig.Window > 0:
+			eventAggregator().add(tenantConfig, complianceEvent, p)
+		default:
+			remaining = append(remaining, complianceEvent)
+		}
+	}
+	return remaining
+}
+
+// errBulkCreate is returned when bulk creating some of the tickets of an alert failed
+var errBulkCreate = errors.New("failed bulk creating Jira tickets")
+
+// processEvents resolves the users of the compliance events and creates their Jira tickets with the settings
+// of the tenant. Once the context is done, no further events are started and the events in progress are cancelled.
+// When it fails, it returns the events left unprocessed: those that failed, weren't started, or whose bulk created
+// ticket failed.
+func processEvents(ctx context.Context, tenantConfig *config.Config, jiraClient *gojira.Client, events []splunk.AlertDetails, p processInfo) ([]splunk.AlertDetails, error) {
+	// The identity provider resolves the users of the compliance events, when one is configured
+	provider, providerErr := identity.Default()
+	if providerErr != nil {
+		p.logf("failed creating identity provider: %s\n", providerErr.Error())
+		return events, providerErr
+	}
+
+	// The enrichers add context to the compliance events before their tickets are created
+	enrichers, enrichersErr := enrich.Default()
+	if enrichersErr != nil {
+		p.logf("failed creating enrichers: %s\n", enrichersErr.Error())
+		return events, enrichersErr
+	}
+
+	// The on-call lookup checks whether the alerting users were responding to an incident, when configured
+	checker, checkerErr := oncall.Default()
+	if checkerErr != nil {
+		p.logf("failed creating on-call checker: %s\n", checkerErr.Error())
+		return events, checkerErr
+	}
+
+	// Tickets for Jira projects with bulk creation enabled are collected here
+	// and created together once all the compliance events are processed
+	var bulkTickets []bulkTicketBatch
+
+	// Process each result in the alert search results, up to jiraparallelism at once
+	var mu sync.Mutex
+	failed := forEachEvent(ctx, events, eventParallelism(), func(complianceEvent splunk.AlertDetails) error {
+		metrics.MetricPipelineEventsInProgress.Inc()
+		batch, err := processEvent(ctx, tenantConfig, jiraClient, provider, enrichers, checker, complianceEvent, p)
+		metrics.MetricPipelineEventsInProgress.Dec()
+		if err != nil {
+			return err
+		}
+
+		if batch != nil {
+			batch.events = []splunk.AlertDetails{complianceEvent}
+			mu.Lock()
+			bulkTickets = addToBatch(bulkTickets, *batch)
+			mu.Unlock()
+		}
+		return nil
+	})
+	var unprocessed []splunk.AlertDetails
+	if failed != nil {
+		unprocessed = failed.unprocessed
+	}
+	if ctx.Err() != nil && (failed == nil || len(failed.errs) == 0) {
+		recordDeadline(ctx)
+		cancelErr := fmt.Errorf("alert processing cancelled: %w", context.Cause(ctx))
+		p.logf("%v\n", cancelErr)
+		for _, batch := range bulkTickets {
+			unprocessed = append(unprocessed, batch.events...)
+		}
+		return unprocessed, cancelErr
+	}
+
+	// Bulk create the collected tickets, reporting the result of each compliance event. The tickets of the
+	// events processed before another event failed are created too, as those events won't be retried.
+	var bulkFailed []splunk.AlertDetails
+	for _, batch := range bulkTickets {
+		bulkFailed = append(bulkFailed, createBatch(ctx, tenantConfig, batch, p)...)
+	}
+	if len(bulkFailed) > 0 {
+		unprocessed = append(unprocessed, bulkFailed...)
+		if failed != nil {
+			return unprocessed, fmt.Errorf("%w: %w", errBulkCreate, failed)
+		}
+		return unprocessed, errBulkCreate
+	}
+	if failed != nil {
+		p.logf("%s\n", failed.Error())
+		return unprocessed, failed
+	}
+
+	return nil, nil
+}
+
+// createBatch bulk creates the batch of tickets within the Jira deadline, reporting the result of each
+// compliance event. It returns the events whose tickets failed to be created.
+func createBatch(ctx context.Context, tenantConfig *config.Config, batch bulkTicketBatch, p processInfo) []splunk.AlertDetails {
+	jiraCtx, jiraCancel := stageContext(ctx, stageJira, tenantConfig.PipelineConfig.JiraTimeout)
+	defer jiraCancel()
+
+	client, err := jira.NewClientContext(jiraCtx, batch.jiraConfig)
+	if err != nil {
+		p.logf("failed creating Jira client for %s: %s\n", batch.jiraConfig.Host, err.Error())
+		metrics.MetricJiraClientCreateFailures.With(p.LabelInput()).Inc()
+		return batch.events
+	}
+
+	var failed []splunk.AlertDetails
+	for i, createErr := range jira.CreateTickets(jiraCtx, client, batch.jiraConfig, batch.tickets) {
+		event := batch.tickets[i].Alert
+		if createErr != nil {
+			p.logf("failed creating Jira ticket for %s on %s: %s", event.User, event.ClusterText, createErr.Error())
+			failed = append(failed, batch.events[i])
+			continue
+		}
+		p.logf("created Jira ticket for %s on %s", event.User, event.ClusterText)
+	}
+	if len(failed) > 0 {
+		recordDeadline(jiraCtx)
+	}
+	return failed
+}
+
+// processEvent enriches the compliance event, resolves its user and creates its Jira ticket. Tickets for Jira
+// projects with bulk creation enabled are returned in a batch to be created with the other tickets instead.
+// The identity lookups and the Jira ticket creation are each cancelled after their stage's timeout.
+func processEvent(ctx context.Context, tenantConfig *config.Config, jiraClient *gojira.Client, provider identity.Provider, enrichers enrich.Pipeline, checker oncall.Checker, complianceEvent splunk.AlertDetails, p processInfo) (*bulkTicketBatch, error) {
+	identityConfig := tenantConfig.IdentityConfig
+
+	p.logf("%v\n", complianceEvent)
+	metrics.MetricComplianceEventsFound.With(p.LabelInput()).Inc()
+
+	enrichers.Enrich(ctx, &complianceEvent)
+	complianceEvent.RiskScore = risk.Score(complianceEvent.ElevatedSummary, tenantConfig.RiskConfig.Rules)
+
+	// Splunk may report the user by email address or Kerberos principal
+	var user string = identity.NormalizeUsername(complianceEvent.User, identityConfig)
+	var manager string = ""
+
+	// Elevation by a user on call for an active incident on the cluster is expected, and handled as configured
+	onCallAction := tenantConfig.OnCallConfig.Action
+	incident := activeIncident(ctx, checker, tenantConfig.OnCallConfig.Timeout, user, complianceEvent)
+	if incident != nil {
+		metrics.MetricOnCallAlerts.With(map[string]string{"action": onCallAction}).Inc()
+		if onCallAction == config.OnCallActionSkip {
+			p.logf("user %s is on call for incident %s on the cluster; not creating a ticket", user, incident.URL)
+			audit.Write(audit.ActionTicketSkipped, "", map[string]string{
+				"alert":    complianceEvent.AlertName,
+				"user":     user,
+				"clusters": strings.Join(complianceEvent.ClusterIDs, ", "),
+				"incident": incident.URL,
+			})
+			return nil, nil
+		}
+	}
+
+	identityCtx, identityCancel := stageContext(ctx, stageIdentity, tenantConfig.PipelineConfig.IdentityTimeout)
+	defer identityCancel()
+
+	// If an identity provider is configured, look up the user and manager
+	if provider != nil {
+		identityUser, lookupErr := provider.ResolveUser(identityCtx, user)
+		if identityCtx.Err() == nil {
+			health.Record(health.Identity, lookupErr)
+		}
+		if lookupErr != nil {
+			recordDeadline(identityCtx)
+			p.logf("failed identity lookup: %s\n", lookupErr.Error())
+			metrics.MetricLDAPLookupFailures.With(p.LabelInput()).Inc()
+
+			ticketDetails := fmt.Sprintf(
+				"A Compliance Alert was received from Splunk, but the user details could not be retrieved from the identity provider."+
+					"Please review and assign accordingly:\n"+
+					"Compliance Data: %+v\n"+
+					"\nError: %s\n", complianceEvent, lookupErr.Error(),
+			)
+
+			createErr := jira.CreateTicket(ctx, jiraClient, tenantConfig.JiraConfig, jira.Ticket{Description: ticketDetails, SummaryTemplate: tenantConfig.SummaryTemplate})
+			if createErr != nil {
+				p.logf("failed creating Jira ticket: %s", createErr.Error())
+				return nil, createErr
+			}
+			// Increment the metric for Jira issues created to track errors
+			metrics.MetricJiraErrorIssuesCreated.With(p.LabelInput()).Inc()
+
+			// Fail the alert for any error case
+			return nil, fmt.Errorf("failed identity lookup: %w", lookupErr)
+		}
+		user, manager = identityUser.Username, identityUser.Manager
+	}
+
+	// Look up the managers above the direct manager, for escalation when the direct manager
+	// is the user or has no Jira account. Failing to do so is not fatal.
+	var escalation []string
+	if provider != nil && identityConfig.ManagerChainDepth > 0 && manager != "" {
+		var chainErr error
+		escalation, chainErr = identity.ManagerChain(identityCtx, provider, manager, identityConfig.ManagerChainDepth)
+		if chainErr != nil {
+			p.logf("failed manager chain lookup: %s\n", chainErr.Error())
+			metrics.MetricLDAPLookupFailures.With(p.LabelInput()).Inc()
+		}
+	}
+
+	// Create a Jira issue for the compliance event, on the Jira instance selected by the routing rules
+	eventJiraConfig := tenantConfig.JiraConfigFor(complianceEvent.AlertName, complianceEvent.Group)
+	description := complianceEvent.Body()
+
+	// Alerts from users outside the required group are routed for security review. Failing to check the
+	// membership fails the event, to be retried with the alert, rather than routing it on a guess.
+	securityReview := false
+	if provider != nil && identityConfig.RequiredGroup != "" {
+		member, groupErr := isGroupMember(identityCtx, provider, user, identityConfig.RequiredGroup)
+		if groupErr != nil {
+			recordDeadline(identityCtx)
+			p.logf("failed group membership check: %s\n", groupErr.Error())
+			metrics.MetricLDAPLookupFailures.With(p.LabelInput()).Inc()
+			return nil, fmt.Errorf("failed group membership check for %s: %w", user, groupErr)
+		}
+		if !member {
+			securityReview = true
+			p.logf("user %s is not a member of %s; routing alert for security review", user, identityConfig.RequiredGroup)
+			metrics.MetricNonMemberAlerts.With(p.LabelInput()).Inc()
+			eventJiraConfig = tenantConfig.NonMemberJiraConfig()
+			description = fmt.Sprintf("The user %s could not be verified as a member of the required group %s. "+
+				"Please review this alert for unexpected access.\n\n%s", user, identityConfig.RequiredGroup, description)
+			if identityConfig.NonMemberAssignee != "" {
+				user, manager, escalation = identityConfig.NonMemberAssignee, "", nil
+			}
+		}
+	}
+
+	// The lookup failures above are not fatal, but an alert cancelled meanwhile
+	// is failed rather than routed without the lookups
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if incident != nil {
+		description = fmt.Sprintf("The user %s was on call for the active incident %q (%s) on the cluster when this alert was raised.\n\n%s",
+			user, incident.Title, incident.URL, description)
+	}
+
+	// Read-only sessions are resolved automatically, unless they need a security review
+	readOnlyConfig := tenantConfig.ReadOnlyConfig
+	readOnly := readOnlyConfig.Enabled && !securityReview && risk.IsReadOnly(complianceEvent.ElevatedSummary, readOnlyConfig.Verbs)
+
+	ticket := jira.Ticket{
+		User:            user,
+		Manager:         manager,
+		Escalation:      escalation,
+		Description:     description,
+		Alert:           complianceEvent,
+		MessageTemplate: tenantConfig.MessageTemplateFor(complianceEvent.AlertName),
+		SummaryTemplate: tenantConfig.SummaryTemplate,
+		FastTrack:       incident != nil && onCallAction == config.OnCallActionFastTrack,
+		ReadOnly:        readOnly,
+	}
+
+	// Beyond the cap on the user's tickets, eg. in an alert storm caused by an automation account, the event
+	// is appended to the user's most recent open ticket. Security reviews are always ticketed.
+	if !securityReview && overTicketLimit(user) {
+		appended, err := appendToRecentTicket(ctx, eventJiraConfig, ticket, p)
+		if err != nil || appended {
+			return nil, err
+		}
+	}
+
+	if eventJiraConfig.BulkCreate {
+		return &bulkTicketBatch{jiraConfig: eventJiraConfig, tickets: []jira.Ticket{ticket}}, nil
+	}
+
+	jiraCtx, jiraCancel := stageContext(ctx, stageJira, tenantConfig.PipelineConfig.JiraTimeout)
+	defer jiraCancel()
+
+	eventJiraClient, jiraClientErr := jira.NewClientContext(jiraCtx, eventJiraConfig)
+	if jiraClientErr != nil {
+		p.logf("failed creating Jira client for %s: %s\n", eventJiraConfig.Host, jiraClientErr.Error())
+		metrics.MetricJiraClientCreateFailures.With(p.LabelInput()).Inc()
+		return nil, jiraClientErr
+	}
+
+	jiraCreateErr := jira.CreateTicket(jiraCtx, eventJiraClient, eventJiraConfig, ticket)
+	if jiraCreateErr != nil {
+		recordDeadline(jiraCtx)
+		p.logf("failed creating Jira ticket: %s", jiraCreateErr.Error())
+		return nil, jiraCreateErr
+	}
+
+	return nil, nil
+}
+
+// activeIncident returns the active incident on the event's clusters the user is on call for, if any.
+// Failing to look it up is not fatal; the event is processed as if the user weren't on call.
+func activeIncident(ctx context.Context, checker oncall.Checker, timeout time.Duration, user string, complianceEvent splunk.AlertDetails) *oncall.Incident {
+	if checker == nil {
+		return nil
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	incident, err := checker.ActiveIncident(ctx, user, complianceEvent.ClusterIDs)
+	if err != nil {
+		log.Printf("failed on-call lookup: %s\n", err.Error())
+		metrics.MetricOnCallLookupFailures.Inc()
+		return nil
+	}
+	return incident
+}
+
+// isGroupMember checks the user's membership of the group, if the identity provider supports it
+func isGroupMember(ctx context.Context, provider identity.Provider, user, group string) (bool, error) {
+	checker, ok := provider.(identity.GroupChecker)
+	if !ok {
+		return false, fmt.Errorf("the identity provider does not support group membership checks")
+	}
+	return checker.IsGroupMember(ctx, user, group)
+}
+
+// bulkTicketBatch is a set of tickets to be bulk created in the same Jira project
+type bulkTicketBatch struct {
+	jiraConfig config.JiraConfig
+	tickets    []jira.Ticket
+	// events are the compliance events of the tickets, as received, to retry those whose ticket fails
+	events []splunk.AlertDetails
+}
+
+// addToBatch adds the tickets of the event's batch to the batch for their Jira project, starting a new batch if needed.
+// Tickets routed with different components or security levels are created in separate batches, with their own settings.
+func addToBatch(batches []bulkTicketBatch, added bulkTicketBatch) []bulkTicketBatch {
+	jiraConfig := added.jiraConfig
+	for i, batch := range batches {
+		if batch.jiraConfig.Host == jiraConfig.Host && batch.jiraConfig.Key == jiraConfig.Key && batch.jiraConfig.IssueType == jiraConfig.IssueType &&
+			slices.Equal(batch.jiraConfig.Components, jiraConfig.Components) && batch.jiraConfig.SecurityLevel == jiraConfig.SecurityLevel {
+			batches[i].tickets = append(batches[i].tickets, added.tickets...)
+			batches[i].events = append(batches[i].events, added.events...)
+			return batches
+		}
+	}
+
+	return append(batches, added)
+}
+
 func ProcessJiraWebhook(w http.ResponseWriter, r *http.Request) {
 	p := processInfo{
 		uuid:    uuid.New().String(),
@@ -272,14 +877,14 @@ func ProcessJiraWebhook(w http.ResponseWriter, r *http.Request) {
 	pl := p.LabelInput()
 
 	webhook := jira.Webhook{}
-	err := helpers.DecodeJSONRequestBody(w, r, &webhook)
+	err := decodeRequestBody(w, r, jiraWebhookPath, &webhook)
 	if err != nil {
 		var mr *helpers.MalformedRequest
 		var si statusInfo
 		if errors.As(err, &mr) {
 			ple := p.LabelInput()
 			ple["error_type"] = "malformed_request"
-			log.Printf("received malformed request: %s\n", mr.Msg)
+			p.logf("received malformed request: %s\n", mr.Msg)
 			metrics.MetricJiraWebhookProcessFailures.With(ple).Inc()
 			// This is a client error, so we return the status code and message
 			si.code = mr.Status
@@ -287,7 +892,7 @@ func ProcessJiraWebhook(w http.ResponseWriter, r *http.Request) {
 		} else {
 			ple := p.LabelInput()
 			ple["error_type"] = "unknown"
-			log.Printf("failed decoding JSON request body: %s\n", err.Error())
+			p.logf("failed decoding JSON request body: %s\n", err.Error())
 			metrics.MetricJiraWebhookProcessFailures.With(ple).Inc()
 			si.code = http.StatusInternalServerError
 			si.msg = []string{genericErrorMsg}
@@ -296,21 +901,80 @@ func ProcessJiraWebhook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	client, err := jira.DefaultClient()
+	metrics.MetricJiraWebhookReceived.With(pl).Inc()
+
+	// Webhooks for a tenant's issues identify the tenant with the "tenant" query parameter,
+	// and webhooks from other named Jira instances the instance with the "instance" query parameter
+	appConfig := config.AppConfig()
+	instance := r.URL.Query().Get("instance")
+	jiraConfig, ok := appConfig.JiraInstance(instance)
+	if tenant := r.URL.Query().Get("tenant"); tenant != "" {
+		tenantConfig, tenantOK := appConfig.Tenant(tenant)
+		if !tenantOK {
+			p.logf("received Jira webhook for unknown tenant: %s\n", tenant)
+			ple := p.LabelInput()
+			ple["error_type"] = "unknown_tenant"
+			metrics.MetricJiraWebhookProcessFailures.With(ple).Inc()
+			setResponse(w, statusInfo{code: http.StatusBadRequest, msg: []string{"Unknown tenant"}}, p)
+			return
+		}
+		jiraConfig, ok = tenantConfig.JiraConfig, true
+	}
+	if !ok {
+		p.logf("received Jira webhook for unknown instance: %s\n", instance)
+		ple := p.LabelInput()
+		ple["error_type"] = "unknown_instance"
+		metrics.MetricJiraWebhookProcessFailures.With(ple).Inc()
+		setResponse(w, statusInfo{code: http.StatusBadRequest, msg: []string{"Unknown Jira instance"}}, p)
+		return
+	}
+
+	// Respond to webhooks the router doesn't need to act on without fetching the issue from Jira
+	reason, ignored, err := webhook.Ignored(jiraConfig)
 	if err != nil {
-		log.Print(err)
+		p.logf("received invalid Jira webhook: %s\n", err.Error())
+		ple := p.LabelInput()
+		ple["error_type"] = "invalid_event"
+		metrics.MetricJiraWebhookProcessFailures.With(ple).Inc()
+		setResponse(w, statusInfo{code: http.StatusBadRequest, msg: []string{err.Error()}}, p)
+		return
+	}
+	if ignored {
+		logging.Debugf(logging.Listeners, "ignoring Jira webhook %s for issue %s: %s\n", webhook.WebhookEvent, webhook.Issue.Key, reason)
+		pil := p.LabelInput()
+		pil["reason"] = reason
+		metrics.MetricJiraWebhookIgnored.With(pil).Inc()
+		setResponse(w, status200, p)
+		return
+	}
+
+	if _, to, ok := webhook.StatusChange(); ok {
+		psl := p.LabelInput()
+		psl["status"] = to
+		metrics.MetricJiraIssueStatusChanges.With(psl).Inc()
+	}
+
+	// Jira retries webhooks that fail, so a stuck Jira instance fails the webhook rather than holding the request
+	ctx, cancel := stageContext(r.Context(), stageJira, appConfig.PipelineConfig.JiraTimeout)
+	defer cancel()
+
+	client, err := jira.NewClientContext(ctx, jiraConfig)
+	if err != nil {
+		p.logf("%v\n", err)
 		metrics.MetricJiraClientCreateFailures.With(pl).Inc()
 		setResponse(w, status500, p)
+		return
 	}
 
-	err = jira.HandleUpdate(client.Issue, webhook)
+	err = jira.HandleUpdate(ctx, client, jiraConfig, webhook)
 	if err != nil {
-		log.Print(err)
+		p.logf("%v\n", err)
 		metrics.MetricJiraIssueUpdateFailures.With(pl).Inc()
 		setResponse(w, status500, p)
 		return
 	}
 
+	w.Header().Set(correlationIDHeader, p.uuid)
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -325,6 +989,9 @@ func setResponse(w http.ResponseWriter, status statusInfo, info processInfo) {
 	switch status.code {
 	case http.StatusOK:
 		body = "ok"
+		if status.msg != nil {
+			body = strings.Join(status.msg, ", ")
+		}
 	case http.StatusInternalServerError:
 		// Set a generic error message for InternalServerErrors to avoid accidentally exposing data
 		body = genericErrorMsg
@@ -337,10 +1004,14 @@ func setResponse(w http.ResponseWriter, status statusInfo, info processInfo) {
 	}
 
 	headers["Content-Type"] = "text/plain; charset=utf-8"
-	w.WriteHeader(status.code)
+	if info.uuid != "" {
+		headers[correlationIDHeader] = info.uuid
+	}
+	// Headers must be set before the status code is written
 	for k, v := range headers {
 		w.Header().Set(k, v)
 	}
+	w.WriteHeader(status.code)
 	_, _ = w.Write([]byte(body))
 
 	metrics.MetricHTTPResponses.With(metricsLabels).Inc()
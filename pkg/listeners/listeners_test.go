@@ -17,6 +17,9 @@ limitations under the License.
 package listeners
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
@@ -27,6 +30,12 @@ import (
 	"testing"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/identity"
+	"github.com/openshift/compliance-audit-router/pkg/jira"
+	"github.com/openshift/compliance-audit-router/pkg/splunk"
 )
 
 func TestMain(m *testing.M) {
@@ -47,12 +56,12 @@ func TestInitRoutes(t *testing.T) {
 	r := chi.NewRouter()
 	InitRoutes(r)
 
-	expectedRouteLen := 5
+	expectedRouteLen := 14
 	if routeLen := len(r.Routes()); routeLen != expectedRouteLen {
 		t.Errorf("Error initializing routes. Expected %v but got %v.", expectedRouteLen, routeLen)
 	}
 
-	paths := []string{"/readyz", "/healthz", "/api/v1/alert", "/api/v1/jira_webhook", "/metrics"}
+	paths := []string{"/readyz", "/healthz", "/api/v1/alert", "/api/v1/alert/validate", "/api/v1/alert/{tenant}", "/api/v1/jira_webhook", "/api/v1/config", "/api/v1/status", "/api/v1/export", "/api/v1/admin/templates", "/api/v1/admin/templates/rollback", "/api/v1/admin/settings", "/openapi.json", "/metrics"}
 
 	for _, route := range r.Routes() {
 		found := false
@@ -81,6 +90,40 @@ func TestRespondOKHandler(t *testing.T) {
 	}
 }
 
+func TestReadyHandler(t *testing.T) {
+	// Without an identity provider, the deep check has nothing to check
+	for _, target := range []string{"/readyz", "/readyz?deep=true"} {
+		recorder := httptest.NewRecorder()
+		ReadyHandler(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+
+		if status := recorder.Code; status != http.StatusOK {
+			t.Errorf("%s returned wrong status code: got %v want %v", target, status, http.StatusOK)
+		}
+		if body := recorder.Body.String(); body != "ok, production mode" {
+			t.Errorf("%s returned wrong body: got %v want %v", target, body, "ok, production mode")
+		}
+	}
+}
+
+func TestConfigHandler(t *testing.T) {
+	config.SetAppConfig(config.Config{ListenPort: 8080, JiraConfig: config.JiraConfig{Host: "https://jira.example.com", Token: "secret-token"}})
+	defer func() { config.SetAppConfig(config.Config{}) }()
+
+	recorder := httptest.NewRecorder()
+	ConfigHandler(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/config", nil))
+
+	if status := recorder.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	body := recorder.Body.String()
+	if strings.Contains(body, "secret-token") {
+		t.Errorf("handler exposed the Jira token: %v", body)
+	}
+	if !strings.Contains(body, "listenport: 8080") || !strings.Contains(body, "host: https://jira.example.com") {
+		t.Errorf("handler did not render the configuration: %v", body)
+	}
+}
+
 func TestProcessAlertHandler(t *testing.T) {
 	// Example webhook payloads that might be received from the
 	// alerting system (ie: Splunk)
@@ -129,6 +172,62 @@ func TestProcessAlertHandler(t *testing.T) {
 				t.Errorf("handler returned unexpected body: got %v, want %v",
 					body, tt.body)
 			}
+
+			// Test that the event UUID is returned for the caller to quote
+			if _, err := uuid.Parse(recorder.Header().Get(correlationIDHeader)); err != nil {
+				t.Errorf("handler returned invalid %s header: %v", correlationIDHeader, err)
+			}
+		})
+	}
+}
+
+func TestProcessAlertHandlerTenants(t *testing.T) {
+	config.SetAppConfig(config.Config{Tenants: map[string]config.TenantConfig{"security": {SearchNames: []string{"security access"}}}})
+	defer func() { config.SetAppConfig(config.Config{}) }()
+
+	tests := []struct {
+		name   string
+		target string
+		body   string
+		status int
+		want   string
+	}{
+		{
+			name:   "unknown tenant in the path should fail",
+			target: "/api/v1/alert/unknown",
+			body:   `{"sid":"1","search_name":"security access"}`,
+			status: http.StatusNotFound,
+			want:   "Unknown tenant",
+		},
+		{
+			name:   "unknown tenant in the result should fail",
+			target: "/api/v1/alert",
+			body:   `{"sid":"1","search_name":"security access","result":{"tenant":"unknown"}}`,
+			status: http.StatusNotFound,
+			want:   "Unknown tenant",
+		},
+		{
+			name:   "search outside the tenant's allowlist should fail",
+			target: "/api/v1/alert/security",
+			body:   `{"sid":"1","search_name":"sre access"}`,
+			status: http.StatusForbidden,
+			want:   "Search not allowed for tenant",
+		},
+	}
+
+	router := chi.NewRouter()
+	InitRoutes(router)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(tt.body)))
+
+			if status := recorder.Code; status != tt.status {
+				t.Errorf("handler returned wrong status code: got %v, want %v", status, tt.status)
+			}
+			if body := strings.TrimSpace(recorder.Body.String()); body != tt.want {
+				t.Errorf("handler returned unexpected body: got %v, want %v", body, tt.want)
+			}
 		})
 	}
 }
@@ -148,6 +247,20 @@ func TestProcessJiraWebhook(t *testing.T) {
 			contentType:         "text/plain; charset=utf-8",
 			expectedBody:        "Request body must not be empty",
 		},
+		{
+			name:                "webhook without an event should fail",
+			incomingWebhookBody: `{"issue":{"key":"OHSS-1"}}`,
+			status:              http.StatusBadRequest,
+			contentType:         "text/plain; charset=utf-8",
+			expectedBody:        "Request body does not match the API specification: the webhookEvent field is required",
+		},
+		{
+			name:                "webhook for an unmanaged issue should be ignored",
+			incomingWebhookBody: `{"webhookEvent":"jira:issue_updated","issue":{"key":"OHSS-1","fields":{"labels":[]}}}`,
+			status:              http.StatusOK,
+			contentType:         "text/plain; charset=utf-8",
+			expectedBody:        "ok",
+		},
 	}
 
 	for _, tt := range tests {
@@ -182,3 +295,78 @@ func TestProcessJiraWebhook(t *testing.T) {
 		})
 	}
 }
+
+func TestProcessInfoLogf(t *testing.T) {
+	var logs strings.Builder
+	log.SetOutput(&logs)
+	defer log.SetOutput(io.Discard)
+
+	processInfo{uuid: "3f1c"}.logf("retrieving alert from Splunk: %v\n", "scheduler_1")
+	processInfo{}.logf("reloaded configuration\n")
+
+	// The messages are prefixed with the UUID of their request without changing the logger's prefix,
+	// which is shared by the concurrent requests
+	if !strings.Contains(logs.String(), "3f1c retrieving alert from Splunk: scheduler_1\n") || strings.Contains(logs.String(), "3f1c reloaded") {
+		t.Errorf("logf() logged %q", logs.String())
+	}
+	if prefix := log.Prefix(); prefix != "" {
+		t.Errorf("logf() set the log prefix to %q", prefix)
+	}
+}
+
+func TestAddToBatch(t *testing.T) {
+	project := config.JiraConfig{Host: "https://jira.example.com", Key: "OHSS", IssueType: "Task"}
+	secured := project
+	secured.SecurityLevel = "Restricted"
+	component := project
+	component.Components = []string{"SRE"}
+
+	var batches []bulkTicketBatch
+	for i, jiraConfig := range []config.JiraConfig{project, secured, component, project, component} {
+		added := bulkTicketBatch{jiraConfig: jiraConfig, tickets: []jira.Ticket{{User: fmt.Sprint(i)}}, events: []splunk.AlertDetails{{}}}
+		batches = addToBatch(batches, added)
+	}
+
+	if len(batches) != 3 {
+		t.Fatalf("addToBatch() made %d batches, want one for each security level and components", len(batches))
+	}
+	for _, batch := range batches {
+		if len(batch.tickets) != len(batch.events) {
+			t.Errorf("addToBatch() batch has %d tickets and %d events", len(batch.tickets), len(batch.events))
+		}
+	}
+	if len(batches[0].tickets) != 2 || len(batches[1].tickets) != 1 || len(batches[2].tickets) != 2 {
+		t.Errorf("addToBatch() batched %d, %d and %d tickets, want 2, 1 and 2", len(batches[0].tickets), len(batches[1].tickets), len(batches[2].tickets))
+	}
+}
+
+// failingGroupProvider resolves every user, but fails to check their group membership. It cancels the
+// lookup once the user is resolved, so that the lookup isn't recorded in the shared identity health.
+type failingGroupProvider struct {
+	cancel context.CancelFunc
+}
+
+func (p failingGroupProvider) ResolveUser(_ context.Context, username string) (identity.Identity, error) {
+	p.cancel()
+	return identity.Identity{Username: username, Manager: "boss"}, nil
+}
+
+func (failingGroupProvider) IsGroupMember(context.Context, string, string) (bool, error) {
+	return false, errors.New("directory unavailable")
+}
+
+func TestProcessEventGroupCheckFailure(t *testing.T) {
+	tenantConfig := &config.Config{IdentityConfig: config.IdentityConfig{RequiredGroup: "sre"}}
+	complianceEvent := splunk.AlertDetails{AlertName: "BreakGlass", User: "sre-user", Group: "sre", ClusterIDs: []string{"cluster"}}
+
+	// The event fails, to be retried, rather than being routed for security review without Jira being called
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	batch, err := processEvent(ctx, tenantConfig, nil, failingGroupProvider{cancel: cancel}, nil, nil, complianceEvent, processInfo{uuid: "123"})
+	if err == nil || !strings.Contains(err.Error(), "directory unavailable") {
+		t.Errorf("processEvent() error = %v, want the group membership check error", err)
+	}
+	if batch != nil {
+		t.Errorf("processEvent() = %+v, want no ticket", batch)
+	}
+}
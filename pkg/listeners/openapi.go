@@ -0,0 +1,272 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package listeners
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/openshift/compliance-audit-router/pkg/helpers"
+	"github.com/openshift/compliance-audit-router/pkg/metrics"
+)
+
+// openAPISpec is the OpenAPI 3 specification of the endpoints, served at /openapi.json.
+// The request bodies are validated against its schemas.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// openAPIDocument is the part of the OpenAPI specification needed to validate request bodies
+type openAPIDocument struct {
+	Paths map[string]map[string]struct {
+		RequestBody *struct {
+			Content map[string]struct {
+				Schema *schema `json:"schema"`
+			} `json:"content"`
+		} `json:"requestBody"`
+	} `json:"paths"`
+	Components struct {
+		Schemas map[string]*schema `json:"schemas"`
+	} `json:"components"`
+}
+
+// schema is the subset of the OpenAPI schema object the request bodies are validated with
+type schema struct {
+	Ref        string             `json:"$ref"`
+	Type       string             `json:"type"`
+	Nullable   bool               `json:"nullable"`
+	Required   []string           `json:"required"`
+	Properties map[string]*schema `json:"properties"`
+	Items      *schema            `json:"items"`
+	MinLength  int                `json:"minLength"`
+}
+
+var (
+	requestSchemasOnce sync.Once
+	requestSchemas     map[string]*schema
+	requestSchemasErr  error
+)
+
+// requestSchema returns the schema of the JSON request body of POST requests to the path,
+// or nil if the specification doesn't define one
+func requestSchema(path string) (*schema, error) {
+	requestSchemasOnce.Do(func() {
+		requestSchemas, requestSchemasErr = parseRequestSchemas(openAPISpec)
+	})
+	return requestSchemas[path], requestSchemasErr
+}
+
+// parseRequestSchemas parses the JSON request body schemas of the POST operations of the specification by path,
+// with their references to the component schemas resolved
+func parseRequestSchemas(spec []byte) (map[string]*schema, error) {
+	var doc openAPIDocument
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		return nil, fmt.Errorf("failed parsing the OpenAPI specification: %w", err)
+	}
+
+	schemas := map[string]*schema{}
+	for path, operations := range doc.Paths {
+		post, ok := operations["post"]
+		if !ok || post.RequestBody == nil {
+			continue
+		}
+		s := post.RequestBody.Content["application/json"].Schema
+		if s == nil {
+			continue
+		}
+		resolved, err := s.resolve(doc.Components.Schemas, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed parsing the OpenAPI specification of %s: %w", path, err)
+		}
+		schemas[path] = resolved
+	}
+	return schemas, nil
+}
+
+// maxSchemaDepth limits the nesting of schemas, so a reference cycle can't recurse forever
+const maxSchemaDepth = 32
+
+// resolve returns the schema with its references to the component schemas replaced by the schemas
+func (s *schema) resolve(components map[string]*schema, depth int) (*schema, error) {
+	if depth > maxSchemaDepth {
+		return nil, fmt.Errorf("schema nested more than %d levels deep", maxSchemaDepth)
+	}
+
+	if s.Ref != "" {
+		name, ok := strings.CutPrefix(s.Ref, "#/components/schemas/")
+		component, found := components[name]
+		if !ok || !found {
+			return nil, fmt.Errorf("unknown schema reference %s", s.Ref)
+		}
+		return component.resolve(components, depth+1)
+	}
+
+	resolved := *s
+	if s.Properties != nil {
+		resolved.Properties = make(map[string]*schema, len(s.Properties))
+		for name, property := range s.Properties {
+			p, err := property.resolve(components, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			resolved.Properties[name] = p
+		}
+	}
+	if s.Items != nil {
+		items, err := s.Items.resolve(components, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		resolved.Items = items
+	}
+	return &resolved, nil
+}
+
+// validate checks the decoded JSON value against the schema, returning an error naming the first
+// field that doesn't match
+func (s *schema) validate(v interface{}, field string) error {
+	if v == nil {
+		if s.Nullable {
+			return nil
+		}
+		return fmt.Errorf("%s must not be null", fieldName(field))
+	}
+
+	switch s.Type {
+	case "object":
+		object, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s must be an object", fieldName(field))
+		}
+		for _, name := range s.Required {
+			if _, ok := object[name]; !ok {
+				return fmt.Errorf("%s is required", fieldName(joinField(field, name)))
+			}
+		}
+		// Check the properties in order, so the same body always reports the same field
+		names := make([]string, 0, len(s.Properties))
+		for name := range s.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if value, ok := object[name]; ok {
+				if err := s.Properties[name].validate(value, joinField(field, name)); err != nil {
+					return err
+				}
+			}
+		}
+	case "array":
+		array, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s must be an array", fieldName(field))
+		}
+		if s.Items != nil {
+			for i, item := range array {
+				if err := s.Items.validate(item, fmt.Sprintf("%s[%d]", field, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s must be a string", fieldName(field))
+		}
+		if len(str) < s.MinLength {
+			return fmt.Errorf("%s must not be empty", fieldName(field))
+		}
+	case "integer":
+		number, ok := v.(float64)
+		if !ok || number != math.Trunc(number) {
+			return fmt.Errorf("%s must be an integer", fieldName(field))
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			return fmt.Errorf("%s must be a number", fieldName(field))
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s must be a boolean", fieldName(field))
+		}
+	}
+	return nil
+}
+
+func joinField(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
+
+func fieldName(field string) string {
+	if field == "" {
+		return "the request body"
+	}
+	return "the " + field + " field"
+}
+
+// decodeRequestBody decodes the JSON request body into dst like helpers.DecodeJSONRequestBody, then validates
+// it against the request schema of the path in the OpenAPI specification. Bodies that don't match are
+// rejected with a helpers.MalformedRequest naming the field.
+func decodeRequestBody(w http.ResponseWriter, r *http.Request, path string, dst interface{}) error {
+	var body bytes.Buffer
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.TeeReader(r.Body, &body), r.Body}
+
+	if err := helpers.DecodeJSONRequestBody(w, r, dst); err != nil {
+		return err
+	}
+
+	s, err := requestSchema(path)
+	if err != nil || s == nil {
+		return err
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(body.Bytes(), &decoded); err != nil {
+		return err
+	}
+	if err := s.validate(decoded, ""); err != nil {
+		return &helpers.MalformedRequest{Status: http.StatusBadRequest, Msg: fmt.Sprintf("Request body does not match the API specification: %s", err.Error())}
+	}
+	return nil
+}
+
+// OpenAPIHandler replies with the OpenAPI specification of the endpoints
+func OpenAPIHandler(w http.ResponseWriter, _ *http.Request) {
+	var p = processInfo{process: "OpenAPIHandler"}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(openAPISpec)
+
+	labels := p.LabelInput()
+	labels["code"] = http.StatusText(http.StatusOK)
+	metrics.MetricHTTPResponses.With(labels).Inc()
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package listeners
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openshift/compliance-audit-router/pkg/helpers"
+	"github.com/openshift/compliance-audit-router/pkg/splunk"
+)
+
+func TestOpenAPIHandler(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	OpenAPIHandler(recorder, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+
+	if contentType := recorder.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("handler returned wrong Content-Type: got %v want %v", contentType, "application/json")
+	}
+
+	var spec struct {
+		Paths map[string]interface{} `json:"paths"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("handler returned invalid JSON: %v", err)
+	}
+	// Every route must be documented
+	for _, listener := range Listeners {
+		if _, ok := spec.Paths[listener.Path]; !ok {
+			t.Errorf("the OpenAPI specification is missing %s", listener.Path)
+		}
+	}
+}
+
+func TestDecodeRequestBody(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		body string
+		want string
+	}{
+		{
+			name: "valid alert",
+			path: alertPath,
+			body: `{"sid":"scheduler_1","result":{"username":"sre"}}`,
+		},
+		{
+			name: "alert without a search ID",
+			path: alertPath,
+			body: `{"search_name":"test"}`,
+			want: "Request body does not match the API specification: the sid field is required",
+		},
+		{
+			name: "alert with an empty search ID",
+			path: alertPath,
+			body: `{"sid":""}`,
+			want: "Request body does not match the API specification: the sid field must not be empty",
+		},
+		{
+			name: "jira webhook with labels of the wrong type",
+			path: jiraWebhookPath,
+			body: `{"webhookEvent":"jira:issue_updated","issue":{"fields":{"labels":["car",1]}}}`,
+			want: "Request body does not match the API specification: the issue.fields.labels[1] field must be a string",
+		},
+		{
+			name: "jira webhook with null fields",
+			path: jiraWebhookPath,
+			body: `{"webhookEvent":"comment_created","issue":{"key":"OHSS-1","fields":null},"changelog":null}`,
+		},
+		{
+			name: "badly formed JSON is reported by the decoder",
+			path: alertPath,
+			body: `{"sid":`,
+			want: "Request body contains badly-formed JSON",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			err := decodeRequestBody(httptest.NewRecorder(), req, tt.path, &map[string]interface{}{})
+
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("decodeRequestBody() unexpected error: %v", err)
+				}
+				return
+			}
+			var mr *helpers.MalformedRequest
+			if !errors.As(err, &mr) || mr.Status != http.StatusBadRequest || mr.Msg != tt.want {
+				t.Errorf("decodeRequestBody() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeRequestBodyDecodes(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, alertPath, strings.NewReader(`{"sid":"scheduler_1"}`))
+
+	var webhook splunk.Webhook
+	if err := decodeRequestBody(httptest.NewRecorder(), req, alertPath, &webhook); err != nil {
+		t.Fatal(err)
+	}
+	if webhook.Sid != "scheduler_1" {
+		t.Errorf("decodeRequestBody() decoded sid = %v, want %v", webhook.Sid, "scheduler_1")
+	}
+}
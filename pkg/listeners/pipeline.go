@@ -0,0 +1,213 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package listeners
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/metrics"
+	"github.com/openshift/compliance-audit-router/pkg/splunk"
+)
+
+// errQueueFull is returned when an alert arrives while the queue is full
+var errQueueFull = errors.New("the processing queue is full")
+
+// pipeline limits the number of alerts processed at once, so bursts of alerts don't overwhelm
+// the Jira instances. Alerts beyond the workers wait in a queue of limited depth, and alerts
+// arriving while the queue is full are rejected.
+type pipeline struct {
+	// admitted holds a slot for each alert being processed or queued
+	admitted chan struct{}
+	// workers holds a slot for each alert being processed
+	workers chan struct{}
+}
+
+func newPipeline(workers, queueSize int) *pipeline {
+	if workers < 1 {
+		workers = 1
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+	metrics.MetricPipelineWorkers.Set(float64(workers))
+	return &pipeline{admitted: make(chan struct{}, workers+queueSize), workers: make(chan struct{}, workers)}
+}
+
+// acquire waits for a free worker, and returns errQueueFull without waiting when the queue is full,
+// or the context's error when it is done first. Each successful acquire must be followed by a release.
+func (p *pipeline) acquire(ctx context.Context) error {
+	select {
+	case p.admitted <- struct{}{}:
+	default:
+		metrics.MetricPipelineAlertsRejected.Inc()
+		return errQueueFull
+	}
+
+	metrics.MetricPipelineQueueDepth.Inc()
+	defer metrics.MetricPipelineQueueDepth.Dec()
+
+	select {
+	case p.workers <- struct{}{}:
+	case <-ctx.Done():
+		<-p.admitted
+		return ctx.Err()
+	}
+	metrics.MetricPipelineWorkersBusy.Inc()
+	return nil
+}
+
+// queued returns the number of alerts waiting for a worker
+func (p *pipeline) queued() int {
+	if queued := len(p.admitted) - len(p.workers); queued > 0 {
+		return queued
+	}
+	return 0
+}
+
+// release frees the worker of an alert that has been processed
+func (p *pipeline) release() {
+	metrics.MetricPipelineWorkersBusy.Dec()
+	<-p.workers
+	<-p.admitted
+}
+
+// eventParallelism returns the number of compliance events of an alert processed at once
+func eventParallelism() int {
+	if parallelism := config.AppConfig().PipelineConfig.JiraParallelism; parallelism > 1 {
+		return parallelism
+	}
+	return 1
+}
+
+// eventsError is the failure of some of the compliance events of an alert. The events that failed, and those
+// not started, are left unprocessed; the others were processed and must not be retried.
+type eventsError struct {
+	total       int
+	errs        []error
+	unprocessed []splunk.AlertDetails
+}
+
+func (e *eventsError) Error() string {
+	messages := make([]string, 0, len(e.errs))
+	for _, err := range e.errs {
+		messages = append(messages, err.Error())
+	}
+	return fmt.Sprintf("%d of %d compliance events failed: %s", len(e.errs), e.total, strings.Join(messages, "; "))
+}
+
+func (e *eventsError) Unwrap() []error {
+	return e.errs
+}
+
+// forEachEvent processes the compliance events, up to parallelism at once, and returns the errors of the failed
+// events together. With a parallelism of 1 the events are processed in order and processing stops at the first
+// failed event; otherwise a failed event doesn't stop the others. Once the context is done, no further events
+// are started.
+func forEachEvent(ctx context.Context, events []splunk.AlertDetails, parallelism int, process func(splunk.AlertDetails) error) *eventsError {
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		failed = eventsError{total: len(events)}
+	)
+	parallel := make(chan struct{}, parallelism)
+	for i, complianceEvent := range events {
+		parallel <- struct{}{}
+		mu.Lock()
+		stop := parallelism == 1 && len(failed.errs) > 0
+		mu.Unlock()
+		if stop || ctx.Err() != nil {
+			<-parallel
+			mu.Lock()
+			failed.unprocessed = append(failed.unprocessed, events[i:]...)
+			mu.Unlock()
+			break
+		}
+
+		wg.Add(1)
+		go func(complianceEvent splunk.AlertDetails) {
+			defer wg.Done()
+			defer func() { <-parallel }()
+
+			if err := process(complianceEvent); err != nil {
+				mu.Lock()
+				failed.errs = append(failed.errs, err)
+				failed.unprocessed = append(failed.unprocessed, complianceEvent)
+				mu.Unlock()
+			}
+		}(complianceEvent)
+	}
+	wg.Wait()
+
+	if len(failed.unprocessed) == 0 {
+		return nil
+	}
+	return &failed
+}
+
+// Stages of processing an alert, each cancelled after its own timeout
+const (
+	stageAlert    = "alert"
+	stageSplunk   = "splunk"
+	stageIdentity = "identity"
+	stageJira     = "jira"
+)
+
+// stageDeadlineError is the cause of a context cancelled by the deadline of a stage
+type stageDeadlineError struct {
+	stage string
+}
+
+func (e stageDeadlineError) Error() string {
+	return fmt.Sprintf("%s deadline exceeded", e.stage)
+}
+
+// stageContext returns a context for a stage of processing an alert, cancelled after the timeout, if any
+func stageContext(ctx context.Context, stage string, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeoutCause(ctx, timeout, stageDeadlineError{stage: stage})
+	}
+	return context.WithCancel(ctx)
+}
+
+// recordDeadline counts the stage whose deadline cancelled the context, if any
+func recordDeadline(ctx context.Context) {
+	var deadline stageDeadlineError
+	if errors.As(context.Cause(ctx), &deadline) {
+		metrics.MetricPipelineDeadlinesExceeded.With(map[string]string{"stage": deadline.stage}).Inc()
+	}
+}
+
+var (
+	defaultPipelineOnce sync.Once
+	defaultPipeline     *pipeline
+)
+
+// alertPipeline returns the pipeline alerts received from Splunk are processed in. It is created with the
+// workers and queue size of the first alert's configuration; they take effect on restart, not on reload.
+func alertPipeline() *pipeline {
+	defaultPipelineOnce.Do(func() {
+		pipelineConfig := config.AppConfig().PipelineConfig
+		defaultPipeline = newPipeline(pipelineConfig.Workers, pipelineConfig.QueueSize)
+	})
+	return defaultPipeline
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package listeners
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/openshift/compliance-audit-router/pkg/splunk"
+)
+
+func TestPipeline(t *testing.T) {
+	p := newPipeline(1, 1)
+
+	if err := p.acquire(context.Background()); err != nil {
+		t.Fatalf("acquire() unexpected error with a free worker: %v", err)
+	}
+
+	// The second alert waits in the queue for the worker
+	acquired := make(chan error)
+	go func() { acquired <- p.acquire(context.Background()) }()
+	time.Sleep(10 * time.Millisecond)
+	if queued := p.queued(); queued != 1 {
+		t.Errorf("queued() = %d with an alert waiting for the worker, want 1", queued)
+	}
+
+	// A third alert finds the queue full
+	if err := p.acquire(context.Background()); !errors.Is(err, errQueueFull) {
+		t.Errorf("acquire() error = %v with a full queue, want %v", err, errQueueFull)
+	}
+
+	p.release()
+	if err := <-acquired; err != nil {
+		t.Errorf("acquire() unexpected error for a queued alert: %v", err)
+	}
+	p.release()
+
+	if err := p.acquire(context.Background()); err != nil {
+		t.Errorf("acquire() unexpected error after the alerts were released: %v", err)
+	}
+	p.release()
+}
+
+func TestPipelineAcquireDeadline(t *testing.T) {
+	p := newPipeline(1, 1)
+	if err := p.acquire(context.Background()); err != nil {
+		t.Fatalf("acquire() unexpected error with a free worker: %v", err)
+	}
+
+	// An alert whose deadline passes while queued gives up its place in the queue
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := p.acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("acquire() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	acquired := make(chan error)
+	go func() { acquired <- p.acquire(context.Background()) }()
+	time.Sleep(10 * time.Millisecond)
+
+	p.release()
+	if err := <-acquired; err != nil {
+		t.Errorf("acquire() unexpected error after a queued alert gave up: %v", err)
+	}
+	p.release()
+}
+
+func TestForEachEvent(t *testing.T) {
+	events := []splunk.AlertDetails{{User: "alice"}, {User: "bob"}, {User: "carol"}}
+	failBob := func(complianceEvent splunk.AlertDetails) error {
+		if complianceEvent.User == "bob" {
+			return errors.New("unknown user bob")
+		}
+		return nil
+	}
+
+	// In order, processing stops at the failed event
+	failed := forEachEvent(context.Background(), events, 1, failBob)
+	if failed == nil || len(failed.errs) != 1 || len(failed.unprocessed) != 2 {
+		t.Fatalf("forEachEvent() with a parallelism of 1 = %+v, want bob failed and carol unprocessed", failed)
+	}
+
+	// In parallel, the other events are processed
+	failed = forEachEvent(context.Background(), events, 2, failBob)
+	if failed == nil || len(failed.unprocessed) != 1 || failed.unprocessed[0].User != "bob" {
+		t.Fatalf("forEachEvent() with a parallelism of 2 = %+v, want only bob unprocessed", failed)
+	}
+	if want := "1 of 3 compliance events failed: unknown user bob"; failed.Error() != want {
+		t.Errorf("Error() = %q, want %q", failed.Error(), want)
+	}
+
+	if failed := forEachEvent(context.Background(), events, 2, func(splunk.AlertDetails) error { return nil }); failed != nil {
+		t.Errorf("forEachEvent() = %+v, want nil when all the events are processed", failed)
+	}
+
+	// No events are started once the context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	failed = forEachEvent(ctx, events, 2, func(splunk.AlertDetails) error { return nil })
+	if failed == nil || len(failed.errs) != 0 || len(failed.unprocessed) != 3 {
+		t.Errorf("forEachEvent() with a done context = %+v, want all the events unprocessed", failed)
+	}
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package listeners
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/health"
+	"github.com/openshift/compliance-audit-router/pkg/identity"
+)
+
+// deepReadiness caches the result of the deep readiness check between probes
+var deepReadiness readinessCache
+
+// readinessCache reuses the result of a dependency check until it is older than the TTL
+type readinessCache struct {
+	mu      sync.Mutex
+	checked time.Time
+	err     error
+}
+
+// check returns the cached result of probe if it is younger than ttl, otherwise it probes again.
+// Concurrent callers wait for a single probe rather than each querying the dependencies.
+func (c *readinessCache) check(ttl time.Duration, now time.Time, probe func() error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if ttl > 0 && !c.checked.IsZero() && now.Sub(c.checked) < ttl {
+		return c.err
+	}
+
+	c.err = probe()
+	c.checked = now
+	return c.err
+}
+
+// checkSaturation checks that the alerts waiting for a worker and the Jira requests waiting to be retried
+// don't exceed their thresholds, so load balancers steer new webhooks to replicas that can process them sooner
+func checkSaturation(pipelineConfig config.PipelineConfig, queued, retryBacklog int) error {
+	if threshold := pipelineConfig.UnreadyQueueDepth; threshold > 0 && queued > threshold {
+		return fmt.Errorf("%d alerts queued, over the threshold of %d", queued, threshold)
+	}
+	if threshold := pipelineConfig.UnreadyRetryBacklog; threshold > 0 && retryBacklog > threshold {
+		return fmt.Errorf("%d Jira requests waiting to be retried, over the threshold of %d", retryBacklog, threshold)
+	}
+	return nil
+}
+
+// circuitStatus describes the Jira instances whose circuit breaker is open for the readiness response.
+// An open breaker doesn't make the replica unready: every replica shares the Jira instance, so the
+// alerts are spooled or failed fast for Splunk to retry wherever they are received.
+func circuitStatus(openHosts []string, spooling bool) []string {
+	if len(openHosts) == 0 {
+		return nil
+	}
+	status := []string{fmt.Sprintf("jira circuit open (%s)", strings.Join(openHosts, " "))}
+	if spooling {
+		status = append(status, "spooling alerts")
+	}
+	return status
+}
+
+// checkDependencies checks that the identity provider's directory is reachable, if the provider can tell
+func checkDependencies() error {
+	provider, err := identity.Default()
+	if err != nil {
+		return err
+	}
+	if checker, ok := provider.(identity.HealthChecker); ok {
+		err := checker.CheckHealth()
+		health.Record(health.Identity, err)
+		return err
+	}
+	return nil
+}
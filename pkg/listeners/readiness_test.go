@@ -0,0 +1,95 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package listeners
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+)
+
+func TestReadinessCache(t *testing.T) {
+	var c readinessCache
+	var probes int
+	probe := func() error {
+		probes++
+		return errors.New("unreachable")
+	}
+
+	now := time.Now()
+	for _, at := range []time.Time{now, now.Add(5 * time.Second)} {
+		if err := c.check(10*time.Second, at, probe); err == nil {
+			t.Errorf("check() returned no error from a failed probe")
+		}
+	}
+	if probes != 1 {
+		t.Errorf("check() probed %d times within the TTL, want 1", probes)
+	}
+
+	_ = c.check(10*time.Second, now.Add(10*time.Second), probe)
+	_ = c.check(0, now.Add(10*time.Second), probe)
+	if probes != 3 {
+		t.Errorf("check() probed %d times after the TTL and without caching, want 3", probes)
+	}
+}
+
+func TestCheckSaturation(t *testing.T) {
+	thresholds := config.PipelineConfig{UnreadyQueueDepth: 10, UnreadyRetryBacklog: 5}
+
+	tests := []struct {
+		name           string
+		pipelineConfig config.PipelineConfig
+		queued         int
+		retryBacklog   int
+		wantErr        bool
+	}{
+		{"Below the thresholds", thresholds, 10, 5, false},
+		{"Queue over its threshold", thresholds, 11, 0, true},
+		{"Retry backlog over its threshold", thresholds, 0, 6, true},
+		{"Thresholds disabled", config.PipelineConfig{}, 100, 100, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkSaturation(tt.pipelineConfig, tt.queued, tt.retryBacklog); (err != nil) != tt.wantErr {
+				t.Errorf("checkSaturation() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCircuitStatus(t *testing.T) {
+	tests := []struct {
+		name      string
+		openHosts []string
+		spooling  bool
+		want      []string
+	}{
+		{"No open circuit", nil, true, nil},
+		{"Open circuits", []string{"https://a.example.com", "https://b.example.com"}, false, []string{"jira circuit open (https://a.example.com https://b.example.com)"}},
+		{"Open circuit while spooling", []string{"https://a.example.com"}, true, []string{"jira circuit open (https://a.example.com)", "spooling alerts"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := circuitStatus(tt.openHosts, tt.spooling); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("circuitStatus() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package listeners
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/jira"
+	"github.com/openshift/compliance-audit-router/pkg/metrics"
+	"github.com/openshift/compliance-audit-router/pkg/splunk"
+	"github.com/openshift/compliance-audit-router/pkg/spool"
+)
+
+// spoolEvents spools the events of the alert to be processed once Jira recovers, when spooling is enabled.
+// Nil events spool the whole alert, before any of its events were processed. It reports whether they were spooled.
+func spoolEvents(tenant string, searchResults splunk.Alert, events []splunk.AlertDetails) bool {
+	alertSpool, err := spool.Default()
+	if err != nil {
+		log.Printf("failed opening the spool: %s\n", err.Error())
+		return false
+	}
+	if alertSpool == nil {
+		return false
+	}
+
+	if err := alertSpool.Add(spool.Entry{Spooled: time.Now().UTC(), Tenant: tenant, Alert: searchResults, Events: events}); err != nil {
+		log.Printf("failed spooling alert %s: %s\n", searchResults.SearchID, err.Error())
+		metrics.MetricSpooledAlerts.With(map[string]string{"operation": "failed"}).Inc()
+		return false
+	}
+	log.Printf("Jira is unavailable; spooled alert %s to be processed once it recovers", searchResults.SearchID)
+	metrics.MetricSpooledAlerts.With(map[string]string{"operation": "spooled"}).Inc()
+	return true
+}
+
+// drainMu keeps drains from overlapping, so a spooled alert is processed once
+var drainMu sync.Mutex
+
+// DrainSpool processes the spooled alerts in the order they were spooled, removing each once processed.
+// It stops at the first alert whose Jira instance is still unavailable or that fails, to retry it on the
+// next drain; unreadable alerts and alerts of unknown tenants are kept in the spool for an operator.
+// Alerts spooled longer than spoolconfig.maxage ago are dropped instead, whether or not they can be processed.
+// It is run periodically by the scheduler.
+func DrainSpool() {
+	drainMu.Lock()
+	defer drainMu.Unlock()
+
+	alertSpool, err := spool.Default()
+	if err != nil || alertSpool == nil {
+		return
+	}
+	names, err := alertSpool.Names()
+	if err != nil {
+		log.Printf("listeners.DrainSpool(): %s\n", err.Error())
+		return
+	}
+
+	maxAge := config.AppConfig().SpoolConfig.MaxAge
+	for _, name := range names {
+		if spooled, ok := spool.SpooledAt(name); ok && maxAge > 0 && time.Since(spooled) > maxAge {
+			log.Printf("listeners.DrainSpool(): dropping alert %s spooled at %s, older than spoolconfig.maxage\n", name, spooled.Format(time.RFC3339))
+			if err := alertSpool.Remove(name); err != nil {
+				log.Printf("listeners.DrainSpool(): failed removing expired alert %s from the spool: %s\n", name, err.Error())
+				continue
+			}
+			metrics.MetricSpooledAlerts.With(map[string]string{"operation": "expired"}).Inc()
+			continue
+		}
+
+		entry, err := alertSpool.Read(name)
+		if err != nil {
+			log.Printf("listeners.DrainSpool(): failed reading spooled alert %s: %s\n", name, err.Error())
+			metrics.MetricSpooledAlerts.With(map[string]string{"operation": "failed"}).Inc()
+			continue
+		}
+		tenantConfig, ok := config.AppConfig().Tenant(entry.Tenant)
+		if !ok {
+			log.Printf("listeners.DrainSpool(): spooled alert %s is for unknown tenant %s; keeping it in the spool\n", entry.Alert.SearchID, entry.Tenant)
+			continue
+		}
+		if !drainEntry(alertSpool, name, &tenantConfig, entry) {
+			return
+		}
+		if err := alertSpool.Remove(name); err != nil {
+			log.Printf("listeners.DrainSpool(): failed removing processed alert %s from the spool: %s\n", name, err.Error())
+			return
+		}
+		metrics.MetricSpooledAlerts.With(map[string]string{"operation": "drained"}).Inc()
+	}
+}
+
+// drainEntry processes a spooled alert with the settings of its tenant. It reports whether the alert was processed;
+// when it fails partway, the entry is rewritten with the events left unprocessed, so the tickets created aren't
+// created again on the next drain.
+func drainEntry(alertSpool *spool.Spool, name string, tenantConfig *config.Config, entry spool.Entry) bool {
+	if jira.CircuitOpen(tenantConfig.JiraConfig) {
+		return false
+	}
+
+	p := processInfo{
+		uuid:      uuid.New().String(),
+		process:   "DrainSpool",
+		tenant:    entry.Tenant,
+		aggregate: true,
+	}
+	p.logf("processing alert %s spooled at %s", entry.Alert.SearchID, entry.Spooled.Format(time.RFC3339))
+
+	ctx, cancel := stageContext(context.Background(), stageAlert, config.AppConfig().PipelineConfig.AlertTimeout)
+	defer cancel()
+
+	jiraClient, err := jira.NewClientContext(ctx, tenantConfig.JiraConfig)
+	if err != nil {
+		p.logf("failed creating Jira client: %s\n", err.Error())
+		metrics.MetricJiraClientCreateFailures.With(p.LabelInput()).Inc()
+		return false
+	}
+	var unprocessed []splunk.AlertDetails
+	if entry.Events != nil {
+		// The events of a partly processed alert were deduplicated and collected when it was received
+		unprocessed, err = processEvents(ctx, tenantConfig, jiraClient, entry.Events, p)
+	} else {
+		unprocessed, err = processAlert(ctx, tenantConfig, jiraClient, entry.Alert, p)
+	}
+	if err != nil {
+		p.logf("listeners.DrainSpool(): failed processing spooled alert %s: %s\n", entry.Alert.SearchID, err.Error())
+		metrics.MetricSpooledAlerts.With(map[string]string{"operation": "failed"}).Inc()
+		if len(unprocessed) > 0 {
+			entry.Events = unprocessed
+			if err := alertSpool.Replace(name, entry); err != nil {
+				p.logf("listeners.DrainSpool(): failed rewriting spooled alert %s: %s\n", name, err.Error())
+			}
+		}
+		return false
+	}
+	return true
+}
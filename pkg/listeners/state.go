@@ -0,0 +1,70 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package listeners
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/openshift/compliance-audit-router/pkg/helpers"
+)
+
+const stateExt = ".json"
+
+// writeState writes the value as JSON to the named file of the directory, creating the directory if needed.
+// The file is written atomically, so a crash doesn't leave a partial file.
+func writeState(dir, name string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	return helpers.WriteFileAtomic(filepath.Join(dir, name+stateExt), data)
+}
+
+// readStates calls read with the name and content of each state file of the directory, in name order.
+// Files being written are skipped, as are the files read fails for, which are kept for an operator.
+func readStates(dir string, read func(name string, data []byte) error) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*"+stateExt))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		name := filepath.Base(file)
+		if err := read(name[:len(name)-len(stateExt)], data); err != nil {
+			log.Printf("skipping state file %s: %s\n", file, err.Error())
+		}
+	}
+	return nil
+}
+
+// removeState removes the named state file of the directory, if it exists
+func removeState(dir, name string) error {
+	if err := os.Remove(filepath.Join(dir, name+stateExt)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package listeners
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/health"
+	"github.com/openshift/compliance-audit-router/pkg/jira"
+	"github.com/openshift/compliance-audit-router/pkg/metrics"
+	"github.com/openshift/compliance-audit-router/pkg/spool"
+)
+
+const statusPath = "/api/v1/status"
+
+// routerStatus is the detailed status of the router and its dependencies
+type routerStatus struct {
+	Mode         string                      `json:"mode"`
+	DryRun       bool                        `json:"dryRun"`
+	Dependencies map[string]dependencyStatus `json:"dependencies"`
+	Queues       queueStatus                 `json:"queues"`
+}
+
+// dependencyStatus is the health of a dependency, with the state of the circuit breaker of each Jira instance
+type dependencyStatus struct {
+	health.Status
+	// CircuitBreakers is "open" or "closed" for each Jira instance called since the router started, by host
+	CircuitBreakers map[string]string `json:"circuitBreakers,omitempty"`
+}
+
+// queueStatus is the number of alerts and Jira actions waiting to be processed
+type queueStatus struct {
+	Alerts           int `json:"alerts"`
+	JiraRetryBacklog int `json:"jiraRetryBacklog"`
+	Spool            int `json:"spool"`
+	Outbox           int `json:"outbox"`
+}
+
+// StatusHandler replies with the health of each dependency, its last successful and failed calls and the state of
+// the Jira circuit breakers, with the queue depths and the mode of operation, as JSON for dashboards and operators
+func StatusHandler(w http.ResponseWriter, _ *http.Request) {
+	var p = processInfo{process: "StatusHandler"}
+
+	body, err := json.Marshal(currentStatus())
+	if err != nil {
+		log.Printf("failed rendering the status: %s\n", err.Error())
+		setResponse(w, status500, p)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+
+	labels := p.LabelInput()
+	labels["code"] = http.StatusText(http.StatusOK)
+	metrics.MetricHTTPResponses.With(labels).Inc()
+}
+
+// currentStatus collects the status of the router. The dependencies are listed even before they are called.
+func currentStatus() routerStatus {
+	status := routerStatus{
+		Mode:         config.AppConfig().Mode(),
+		DryRun:       config.AppConfig().DryRun,
+		Dependencies: map[string]dependencyStatus{health.Splunk: {}, health.Jira: {}},
+		Queues: queueStatus{
+			Alerts:           alertPipeline().queued(),
+			JiraRetryBacklog: jira.RetryBacklog(),
+		},
+	}
+	if config.AppConfig().IdentityProvider() != "" {
+		status.Dependencies[health.Identity] = dependencyStatus{}
+	}
+	for dependency, dependencyHealth := range health.Statuses() {
+		status.Dependencies[dependency] = dependencyStatus{Status: dependencyHealth}
+	}
+
+	jiraStatus := status.Dependencies[health.Jira]
+	for host, open := range jira.CircuitStates() {
+		if jiraStatus.CircuitBreakers == nil {
+			jiraStatus.CircuitBreakers = map[string]string{}
+		}
+		jiraStatus.CircuitBreakers[host] = "closed"
+		if open {
+			jiraStatus.CircuitBreakers[host] = "open"
+		}
+	}
+	status.Dependencies[health.Jira] = jiraStatus
+
+	if alertSpool, err := spool.Default(); err == nil && alertSpool != nil {
+		if names, err := alertSpool.Names(); err == nil {
+			status.Queues.Spool = len(names)
+		}
+	}
+	if pending, err := jira.OutboxPending(); err == nil {
+		status.Queues.Outbox = pending
+	}
+
+	return status
+}
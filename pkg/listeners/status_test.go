@@ -0,0 +1,64 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package listeners
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/health"
+)
+
+func TestStatusHandler(t *testing.T) {
+	config.SetAppConfig(config.Config{DryRun: true})
+	defer func() { config.SetAppConfig(config.Config{}) }()
+
+	health.Record(health.Splunk, nil)
+	health.Record(health.Jira, nil)
+	health.Record(health.Jira, errors.New("connection refused"))
+
+	recorder := httptest.NewRecorder()
+	StatusHandler(recorder, httptest.NewRequest(http.MethodGet, statusPath, nil))
+
+	if status := recorder.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	if contentType := recorder.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("handler returned wrong Content-Type: got %v want %v", contentType, "application/json")
+	}
+
+	var got routerStatus
+	if err := json.Unmarshal(recorder.Body.Bytes(), &got); err != nil {
+		t.Fatalf("handler returned invalid JSON: %v", err)
+	}
+	if !got.DryRun || got.Mode != config.ModeDryRun {
+		t.Errorf("handler returned mode %v, dry-run %v, want dry-run mode", got.Mode, got.DryRun)
+	}
+	if splunkStatus := got.Dependencies[health.Splunk]; splunkStatus.LastSuccess == nil || splunkStatus.LastError != nil {
+		t.Errorf("handler returned Splunk status %+v, want a success only", splunkStatus)
+	}
+	if jiraStatus := got.Dependencies[health.Jira]; jiraStatus.LastSuccess == nil || jiraStatus.LastError == nil || jiraStatus.Error != "connection refused" {
+		t.Errorf("handler returned Jira status %+v, want the last error", jiraStatus)
+	}
+	if _, ok := got.Dependencies[health.Identity]; ok {
+		t.Errorf("handler returned the status of an identity provider that isn't configured")
+	}
+}
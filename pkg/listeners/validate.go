@@ -0,0 +1,145 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package listeners
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/helpers"
+	"github.com/openshift/compliance-audit-router/pkg/risk"
+	"github.com/openshift/compliance-audit-router/pkg/splunk"
+)
+
+// validatePath validates alerts like alertPath, without processing them. Being a static path, it shadows
+// the alertPath of a tenant named validate, so the tenant is selected with the tenant query parameter.
+const validatePath = alertPath + "/validate"
+
+// What the router would do with a compliance event of an alert
+const (
+	dispositionInvalid   = "invalid"
+	dispositionDigest    = "digest"
+	dispositionAggregate = "aggregate"
+	dispositionTicket    = "ticket"
+)
+
+// alertValidation is what the router would do with an alert. The status and message are those
+// the alert endpoint would reply with when it rejects the alert.
+type alertValidation struct {
+	Tenant        string            `json:"tenant"`
+	Accepted      bool              `json:"accepted"`
+	Status        int               `json:"status,omitempty"`
+	Message       string            `json:"message,omitempty"`
+	ResultsSource string            `json:"resultsSource,omitempty"`
+	Events        []eventValidation `json:"events,omitempty"`
+}
+
+// eventValidation is the compliance event mapped from a search result, and how it would be handled
+type eventValidation struct {
+	Details     splunk.AlertDetails `json:"details"`
+	Valid       bool                `json:"valid"`
+	Disposition string              `json:"disposition"`
+	Jira        *jiraRoute          `json:"jira,omitempty"`
+}
+
+// jiraRoute is the Jira project the ticket of a compliance event would be created in
+type jiraRoute struct {
+	Host      string `json:"host"`
+	Project   string `json:"project"`
+	IssueType string `json:"issueType"`
+}
+
+// ValidateAlertHandler decodes and validates a Splunk alert webhook, and replies with what the router would do
+// with it: whether the alert is accepted and, for the inline result, the mapped compliance event and its routing.
+// Neither Splunk nor Jira are contacted, and nothing is recorded.
+func ValidateAlertHandler(w http.ResponseWriter, r *http.Request) {
+	var p = processInfo{process: "ValidateAlertHandler"}
+
+	var webhook splunk.Webhook
+	if err := decodeRequestBody(w, r, validatePath, &webhook); err != nil {
+		var mr *helpers.MalformedRequest
+		if errors.As(err, &mr) {
+			setResponse(w, statusInfo{code: mr.Status, msg: []string{mr.Msg}}, p)
+		} else {
+			log.Printf("failed decoding JSON request body: %s\n", err.Error())
+			setResponse(w, status500, p)
+		}
+		return
+	}
+
+	tenant := r.URL.Query().Get("tenant")
+	if tenant == "" {
+		tenant = alertTenant(r, webhook)
+	}
+	writeAdminJSON(w, validateAlert(tenant, webhook), p)
+}
+
+// validateAlert returns what ProcessAlertHandler would do with the alert of the tenant, with the settings
+// that don't depend on Splunk, Jira or the identity provider
+func validateAlert(tenant string, webhook splunk.Webhook) alertValidation {
+	validation := alertValidation{Tenant: tenant}
+
+	appConfig := config.AppConfig()
+	tenantConfig, ok := appConfig.Tenant(tenant)
+	if !ok {
+		validation.Status, validation.Message = http.StatusNotFound, "Unknown tenant"
+		return validation
+	}
+	if !appConfig.Tenants[strings.ToLower(tenant)].AllowsSearch(webhook.SearchName) {
+		validation.Status, validation.Message = http.StatusForbidden, "Search not allowed for tenant"
+		return validation
+	}
+	validation.Accepted = true
+
+	// Without an inline result, the search results would be retrieved from Splunk
+	if len(webhook.Result) == 0 {
+		validation.ResultsSource = "splunk"
+		return validation
+	}
+	validation.ResultsSource = "inline"
+
+	complianceEvent := splunk.NewAlertDetails(webhook.Result)
+	complianceEvent.RiskScore = risk.Score(complianceEvent.ElevatedSummary, tenantConfig.RiskConfig.Rules)
+	validation.Events = []eventValidation{validateEvent(&tenantConfig, complianceEvent)}
+	return validation
+}
+
+// validateEvent returns how the compliance event would be handled, like collectEvents and processEvent
+func validateEvent(tenantConfig *config.Config, complianceEvent splunk.AlertDetails) eventValidation {
+	event := eventValidation{Details: complianceEvent, Valid: complianceEvent.Valid()}
+
+	// Digests collect the events of all the groups, so they are routed by the alert name only
+	group := complianceEvent.Group
+	switch {
+	case !event.Valid:
+		event.Disposition = dispositionInvalid
+		return event
+	case tenantConfig.DigestConfig.Includes(complianceEvent.AlertName):
+		event.Disposition, group = dispositionDigest, ""
+	case tenantConfig.AggregationConfig.Window > 0:
+		event.Disposition = dispositionAggregate
+	default:
+		event.Disposition = dispositionTicket
+	}
+
+	jiraConfig := tenantConfig.JiraConfigFor(complianceEvent.AlertName, group)
+	event.Jira = &jiraRoute{Host: jiraConfig.Host, Project: jiraConfig.Key, IssueType: jiraConfig.IssueType}
+	return event
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package listeners
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/splunk"
+)
+
+func TestValidateAlertHandler(t *testing.T) {
+	config.SetAppConfig(config.Config{
+		JiraConfig:   config.JiraConfig{Host: "https://jira.example.com", Key: "OHSS", IssueType: "Task"},
+		Routing:      []config.RoutingRule{{Group: "sre-platform", Key: "SREP"}},
+		DigestConfig: config.DigestConfig{AlertNames: []string{"low risk"}},
+		Tenants: map[string]config.TenantConfig{
+			"security": {SearchNames: []string{"security access"}, Key: "SEC"},
+		},
+	})
+	defer func() { config.SetAppConfig(config.Config{}) }()
+
+	const result = `"result":{"alertname":"%s","username":"sre","group":"sre-platform","clusterid":"abc"}`
+	tests := []struct {
+		name string
+		path string
+		body string
+		want alertValidation
+	}{
+		{
+			name: "alert routed by the routing rules",
+			path: validatePath,
+			body: `{"sid":"scheduler_1",` + strings.Replace(result, "%s", "elevated access", 1) + `}`,
+			want: alertValidation{Accepted: true, ResultsSource: "inline", Events: []eventValidation{
+				{Valid: true, Disposition: dispositionTicket, Jira: &jiraRoute{Host: "https://jira.example.com", Project: "SREP", IssueType: "Task"}},
+			}},
+		},
+		{
+			name: "digest alerts are routed by the alert name only",
+			path: validatePath,
+			body: `{"sid":"scheduler_1",` + strings.Replace(result, "%s", "low risk", 1) + `}`,
+			want: alertValidation{Accepted: true, ResultsSource: "inline", Events: []eventValidation{
+				{Valid: true, Disposition: dispositionDigest, Jira: &jiraRoute{Host: "https://jira.example.com", Project: "OHSS", IssueType: "Task"}},
+			}},
+		},
+		{
+			name: "result missing the fields of a compliance event",
+			path: validatePath,
+			body: `{"sid":"scheduler_1","result":{"username":"sre"}}`,
+			want: alertValidation{Accepted: true, ResultsSource: "inline", Events: []eventValidation{{Disposition: dispositionInvalid}}},
+		},
+		{
+			name: "results retrieved from Splunk",
+			path: validatePath,
+			body: `{"sid":"scheduler_1"}`,
+			want: alertValidation{Accepted: true, ResultsSource: "splunk"},
+		},
+		{
+			name: "tenant project",
+			path: validatePath + "?tenant=security",
+			body: `{"sid":"scheduler_1","search_name":"security access",` + strings.Replace(result, "%s", "elevated access", 1) + `}`,
+			want: alertValidation{Tenant: "security", Accepted: true, ResultsSource: "inline", Events: []eventValidation{
+				{Valid: true, Disposition: dispositionTicket, Jira: &jiraRoute{Host: "https://jira.example.com", Project: "SEC", IssueType: "Task"}},
+			}},
+		},
+		{
+			name: "search not allowed for the tenant of the result",
+			path: validatePath,
+			body: `{"sid":"scheduler_1","search_name":"other","result":{"tenant":"security"}}`,
+			want: alertValidation{Tenant: "security", Status: http.StatusForbidden, Message: "Search not allowed for tenant"},
+		},
+		{
+			name: "unknown tenant",
+			path: validatePath + "?tenant=unknown",
+			body: `{"sid":"scheduler_1"}`,
+			want: alertValidation{Tenant: "unknown", Status: http.StatusNotFound, Message: "Unknown tenant"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			ValidateAlertHandler(recorder, req)
+
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("handler returned %v %v, want %v", recorder.Code, recorder.Body.String(), http.StatusOK)
+			}
+			var got alertValidation
+			if err := json.Unmarshal(recorder.Body.Bytes(), &got); err != nil {
+				t.Fatalf("handler returned invalid JSON: %v", err)
+			}
+			if got.Tenant != tt.want.Tenant || got.Accepted != tt.want.Accepted || got.Status != tt.want.Status ||
+				got.Message != tt.want.Message || got.ResultsSource != tt.want.ResultsSource || len(got.Events) != len(tt.want.Events) {
+				t.Fatalf("handler returned %+v, want %+v", got, tt.want)
+			}
+			for i, event := range got.Events {
+				want := tt.want.Events[i]
+				if event.Valid != want.Valid || event.Disposition != want.Disposition || (event.Jira == nil) != (want.Jira == nil) ||
+					(event.Jira != nil && *event.Jira != *want.Jira) {
+					t.Errorf("handler returned event %+v %+v, want %+v %+v", event, event.Jira, want, want.Jira)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateAlertHandlerMalformed(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, validatePath, strings.NewReader(`{"search_name":"test"}`))
+	req.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	ValidateAlertHandler(recorder, req)
+
+	if recorder.Code != http.StatusBadRequest || !strings.Contains(recorder.Body.String(), "the sid field is required") {
+		t.Errorf("handler returned %v %v, want %v", recorder.Code, recorder.Body.String(), http.StatusBadRequest)
+	}
+}
+
+func TestValidateAlertMapsDetails(t *testing.T) {
+	config.SetAppConfig(config.Config{})
+	defer func() { config.SetAppConfig(config.Config{}) }()
+
+	var webhook splunk.Webhook
+	if err := json.Unmarshal([]byte(`{"sid":"scheduler_1","result":{"alertname":"elevated access","username":"sre","group":"sre","clusterid":"abc","custom":"value"}}`), &webhook); err != nil {
+		t.Fatal(err)
+	}
+
+	got := validateAlert("", webhook)
+	if len(got.Events) != 1 {
+		t.Fatalf("validateAlert() = %+v, want one event", got)
+	}
+	details := got.Events[0].Details
+	if details.AlertName != "elevated access" || details.User != "sre" || len(details.ClusterIDs) != 1 || details.Extra["custom"] != "value" {
+		t.Errorf("validateAlert() mapped %+v", details)
+	}
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package logging writes the verbose log lines of each package at the package's log level,
+// sampling them so verbose troubleshooting of one integration doesn't flood the logs
+package logging
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+)
+
+// Packages whose log level can be set in logconfig.levels
+const (
+	Listeners = "listeners"
+	Splunk    = "splunk"
+	Jira      = "jira"
+	LDAP      = "ldap"
+)
+
+var (
+	countersMutex sync.Mutex
+	counters      = map[string]*uint64{}
+)
+
+// Debugf logs the message like log.Printf when the package logs verbosely,
+// sampling one of every logconfig.sampleevery messages of the package
+func Debugf(pkg string, format string, v ...interface{}) {
+	if !config.AppConfig().VerboseFor(pkg) || !sampled(pkg, config.AppConfig().LogConfig.SampleEvery) {
+		return
+	}
+	_ = log.Output(2, fmt.Sprintf(format, v...))
+}
+
+// sampled reports whether the package's next verbose message is logged: the first of every n messages
+func sampled(pkg string, n int) bool {
+	if n <= 1 {
+		return true
+	}
+
+	countersMutex.Lock()
+	counter, ok := counters[pkg]
+	if !ok {
+		counter = new(uint64)
+		counters[pkg] = counter
+	}
+	countersMutex.Unlock()
+
+	return (atomic.AddUint64(counter, 1)-1)%uint64(n) == 0
+}
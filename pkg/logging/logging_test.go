@@ -0,0 +1,51 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logging
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+)
+
+func TestDebugf(t *testing.T) {
+	var out bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&out)
+
+	config.SetAppConfig(config.Config{
+		Verbose:   false,
+		LogConfig: config.LogConfig{Levels: map[string]string{Jira: config.LogLevelDebug}, SampleEvery: 2},
+	})
+	defer func() { config.SetAppConfig(config.Config{}) }()
+
+	for i := 0; i < 4; i++ {
+		Debugf(Jira, "jira line %d", i)
+		Debugf(Splunk, "splunk line %d", i)
+	}
+
+	got := out.String()
+	if strings.Contains(got, "splunk") {
+		t.Errorf("Debugf() logged for a package at the info level: %v", got)
+	}
+	if strings.Count(got, "jira line") != 2 || !strings.Contains(got, "jira line 0") || !strings.Contains(got, "jira line 2") {
+		t.Errorf("Debugf() did not log one of every 2 lines: %v", got)
+	}
+}
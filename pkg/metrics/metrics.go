@@ -15,7 +15,11 @@
 package metrics
 
 import (
+	"net/http"
+
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 var (
@@ -53,6 +57,21 @@ var (
 		ConstLabels: CARPrometheusLabels},
 		[]string{"error_type", "uuid", "process"},
 	)
+	// MetricSplunkRequestDuration is the time taken by requests to the Splunk API
+	MetricSplunkRequestDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:        "compliance_audit_router_splunk_request_duration_seconds",
+		Help:        "Time taken by requests to the Splunk API",
+		ConstLabels: CARPrometheusLabels,
+		Buckets:     prometheus.DefBuckets},
+	)
+	// MetricSplunkRequests is the number of requests to the Splunk API, with the class of the status code
+	// of the response, eg. 2xx, or error when no response was received
+	MetricSplunkRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:        "compliance_audit_router_splunk_requests",
+		Help:        "Number of requests to the Splunk API with the class of the response status code as a label",
+		ConstLabels: CARPrometheusLabels},
+		[]string{"status_class"},
+	)
 
 	// COMPLIANCE EVENT PROCESSING
 
@@ -72,6 +91,97 @@ var (
 		[]string{"uuid", "process"},
 	)
 
+	// MetricAggregationPendingEvents is the number of compliance events buffered in the aggregation window
+	MetricAggregationPendingEvents = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name:        "compliance_audit_router_aggregation_pending_events",
+		Help:        "Number of compliance events buffered in the aggregation window",
+		ConstLabels: CARPrometheusLabels},
+	)
+	// MetricAggregationTickets is the number of combined tickets for the events of an aggregation window,
+	// with the result (created or failed) as a label
+	MetricAggregationTickets = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:        "compliance_audit_router_aggregation_tickets",
+		Help:        "Number of combined tickets for the compliance events of an aggregation window with the result as a label",
+		ConstLabels: CARPrometheusLabels},
+		[]string{"result"},
+	)
+
+	// MetricDuplicateEvents is the number of compliance events suppressed as duplicates of an earlier event
+	MetricDuplicateEvents = prometheus.NewCounter(prometheus.CounterOpts{
+		Name:        "compliance_audit_router_duplicate_events",
+		Help:        "Number of compliance events suppressed as duplicates of an earlier event",
+		ConstLabels: CARPrometheusLabels},
+	)
+
+	// MetricFloodEvents is the number of compliance events of users over their ticket cap,
+	// with the result (appended, created when the user has no open ticket, or failed) as a label
+	MetricFloodEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:        "compliance_audit_router_flood_events",
+		Help:        "Number of compliance events of users over their ticket cap with the result as a label",
+		ConstLabels: CARPrometheusLabels},
+		[]string{"result"},
+	)
+
+	// MetricDigestPendingEvents is the number of compliance events collected for the next digest tickets
+	MetricDigestPendingEvents = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name:        "compliance_audit_router_digest_pending_events",
+		Help:        "Number of compliance events collected for the next digest tickets",
+		ConstLabels: CARPrometheusLabels},
+	)
+	// MetricDigestTickets is the number of digest tickets, with the result (created or failed) as a label
+	MetricDigestTickets = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:        "compliance_audit_router_digest_tickets",
+		Help:        "Number of digest tickets with the result as a label",
+		ConstLabels: CARPrometheusLabels},
+		[]string{"result"},
+	)
+
+	// MetricAuditFailures is the number of decisions that failed to be recorded in the audit log
+	MetricAuditFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Name:        "compliance_audit_router_audit_failures",
+		Help:        "Number of decisions that failed to be recorded in the audit log",
+		ConstLabels: CARPrometheusLabels},
+	)
+
+	// MetricRetentionPurgedRecords is the number of stored records purged by the retention policy,
+	// with the store as a label
+	MetricRetentionPurgedRecords = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:        "compliance_audit_router_retention_purged_records",
+		Help:        "Number of stored records purged by the retention policy with the store as a label",
+		ConstLabels: CARPrometheusLabels},
+		[]string{"store"},
+	)
+	// MetricRetentionPurgeFailures is the number of failed purges, with the store as a label
+	MetricRetentionPurgeFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:        "compliance_audit_router_retention_purge_failures",
+		Help:        "Number of failed purges of stored records with the store as a label",
+		ConstLabels: CARPrometheusLabels},
+		[]string{"store"},
+	)
+
+	// MetricEnrichmentFailures is the number of failures adding context to compliance events, with the enricher as a label
+	MetricEnrichmentFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:        "compliance_audit_router_enrichment_failures",
+		Help:        "Number of failures adding context to compliance events with the enricher as a label",
+		ConstLabels: CARPrometheusLabels},
+		[]string{"enricher"},
+	)
+
+	// MetricOnCallAlerts is the number of compliance events from users on call for an active incident on the cluster,
+	// with the configured action as a label
+	MetricOnCallAlerts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:        "compliance_audit_router_oncall_alerts",
+		Help:        "Number of compliance events from users on call for an active incident on the cluster with the action as a label",
+		ConstLabels: CARPrometheusLabels},
+		[]string{"action"},
+	)
+	// MetricOnCallLookupFailures is the number of failed on-call lookups
+	MetricOnCallLookupFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Name:        "compliance_audit_router_oncall_lookup_failures",
+		Help:        "Number of failed on-call lookups",
+		ConstLabels: CARPrometheusLabels},
+	)
+
 	// JIRA ISSUE CREATION FOR EVENTS
 
 	// MetricJiraClientCreateFailures is the number of failures to create a Jira client
@@ -95,12 +205,22 @@ var (
 		ConstLabels: CARPrometheusLabels},
 		[]string{"uuid", "process"},
 	)
-	// MetricJiraIssueCreateFailures is the number of compliance events that failed to be created as Jira issues
-	MetricJiraIssueCreateFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Name:        "compliance_audit_router_jira_issue_create_failures",
-		Help:        "Number of Jira issues that failed to be created",
+	// MetricJiraRequestDuration is the time taken by requests to the Jira API, with the operation as a label,
+	// eg. create, comment, transition or user_find
+	MetricJiraRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:        "compliance_audit_router_jira_request_duration_seconds",
+		Help:        "Time taken by requests to the Jira API with the operation as a label",
+		ConstLabels: CARPrometheusLabels,
+		Buckets:     prometheus.DefBuckets},
+		[]string{"operation"},
+	)
+	// MetricJiraRequestErrors is the number of requests to the Jira API that failed, with the operation
+	// and the class of the response status code, eg. 4xx, or error when no response was received
+	MetricJiraRequestErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:        "compliance_audit_router_jira_request_errors",
+		Help:        "Number of failed requests to the Jira API with the operation and the class of the response status code as labels",
 		ConstLabels: CARPrometheusLabels},
-		[]string{"uuid", "process"},
+		[]string{"operation", "status_class"},
 	)
 
 	// JIRA WEBHOOK PROCESSING
@@ -120,6 +240,14 @@ var (
 		ConstLabels: CARPrometheusLabels},
 		[]string{"error_type", "uuid", "process"},
 	)
+	// MetricJiraWebhookIgnored is the number of Jira notification webhooks ignored without processing,
+	// because they are for events not handled by the router or for issues not managed by the router
+	MetricJiraWebhookIgnored = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:        "compliance_audit_router_jira_webhook_ignored",
+		Help:        "Number of Jira notification webhooks ignored without processing",
+		ConstLabels: CARPrometheusLabels},
+		[]string{"reason", "uuid", "process"},
+	)
 	// MetricJiraIssueUpdateFailures is the number of failures updating issues based on received webhook events
 	MetricJiraIssueUpdateFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name:        "compliance_audit_router_jira_issue_update_failures",
@@ -128,16 +256,203 @@ var (
 		[]string{"uuid", "process"},
 	)
 
+	// MetricJiraIssueStatusChanges is the number of issue status changes received in Jira webhooks,
+	// including transitions made by the router and those made directly in Jira
+	MetricJiraIssueStatusChanges = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:        "compliance_audit_router_jira_issue_status_changes",
+		Help:        "Number of Jira issue status changes received in Jira webhooks",
+		ConstLabels: CARPrometheusLabels},
+		[]string{"status", "uuid", "process"},
+	)
+
+	// MetricJiraRemindersSent is the number of reminder comments posted on idle issues
+	MetricJiraRemindersSent = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:        "compliance_audit_router_jira_reminders_sent",
+		Help:        "Number of reminder comments posted on idle Jira issues",
+		ConstLabels: CARPrometheusLabels},
+		[]string{"uuid", "process"},
+	)
+	// MetricJiraRetryBacklog is the number of Jira requests waiting to be retried after being rate limited
+	MetricJiraRetryBacklog = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name:        "compliance_audit_router_jira_retry_backlog",
+		Help:        "Number of Jira requests waiting to be retried after being rate limited",
+		ConstLabels: CARPrometheusLabels},
+	)
+	// MetricJiraCircuitOpen is 1 while the circuit breaker of a Jira instance is open, with the host as a label
+	MetricJiraCircuitOpen = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name:        "compliance_audit_router_jira_circuit_open",
+		Help:        "Whether the circuit breaker of a Jira instance is open with the host as a label",
+		ConstLabels: CARPrometheusLabels},
+		[]string{"host"},
+	)
+	// MetricSpoolDepth is the number of alerts spooled to disk waiting for Jira to recover
+	MetricSpoolDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name:        "compliance_audit_router_spool_depth",
+		Help:        "Number of alerts spooled to disk waiting for Jira to recover",
+		ConstLabels: CARPrometheusLabels},
+	)
+	// MetricSpooledAlerts is the number of alerts spooled to disk and drained from the spool,
+	// with the operation (spooled, drained, failed or expired) as a label
+	MetricSpooledAlerts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:        "compliance_audit_router_spooled_alerts",
+		Help:        "Number of alerts spooled to disk and drained from the spool with the operation as a label",
+		ConstLabels: CARPrometheusLabels},
+		[]string{"operation"},
+	)
+	// MetricJiraIncompleteTickets is the number of created issues marked for manual review after their initial
+	// comment or transition failed
+	MetricJiraIncompleteTickets = prometheus.NewCounter(prometheus.CounterOpts{
+		Name:        "compliance_audit_router_jira_incomplete_tickets",
+		Help:        "Number of created issues marked for manual review after their initial comment or transition failed",
+		ConstLabels: CARPrometheusLabels},
+	)
+	// MetricOutboxPending is the number of new tickets whose Jira actions recorded in the outbox are not all made
+	MetricOutboxPending = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name:        "compliance_audit_router_outbox_pending",
+		Help:        "Number of new tickets whose Jira actions recorded in the outbox are not all made",
+		ConstLabels: CARPrometheusLabels},
+	)
+	// MetricOutboxReconciled is the number of unfinished tickets reconciled from the outbox,
+	// with the result (finished or failed) as a label
+	MetricOutboxReconciled = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:        "compliance_audit_router_outbox_reconciled",
+		Help:        "Number of unfinished tickets reconciled from the outbox with the result as a label",
+		ConstLabels: CARPrometheusLabels},
+		[]string{"result"},
+	)
+	// MetricJiraAutoApprovals is the number of issues approved automatically for an approved change record,
+	// with where the record was referenced, the alert or the justification comment, as a label
+	MetricJiraAutoApprovals = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:        "compliance_audit_router_jira_auto_approvals",
+		Help:        "Number of Jira issues approved automatically for an approved change record with the source of the reference as a label",
+		ConstLabels: CARPrometheusLabels},
+		[]string{"source"},
+	)
+	// MetricJiraReadOnlyResolved is the number of issues resolved automatically as all their elevated commands were read-only
+	MetricJiraReadOnlyResolved = prometheus.NewCounter(prometheus.CounterOpts{
+		Name:        "compliance_audit_router_jira_read_only_resolved",
+		Help:        "Number of Jira issues resolved automatically as all their elevated commands were read-only",
+		ConstLabels: CARPrometheusLabels},
+	)
+	// MetricJiraReminderFailures is the number of failures searching for or reminding idle issues
+	MetricJiraReminderFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:        "compliance_audit_router_jira_reminder_failures",
+		Help:        "Number of failures searching for or posting reminders on idle Jira issues",
+		ConstLabels: CARPrometheusLabels},
+		[]string{"uuid", "process"},
+	)
+
 	// LDAP LOOKUP PROCESSING
 
-	// MetricLDAPLookupFailures is the number of LDAP lookups that failed
-	// Other LDAP metrics are not currently tracked, as they are not integral to the process
+	// MetricLDAPLookupFailures is the number of identity provider lookups that failed.
+	// It keeps its LDAP name, as LDAP was the only identity provider when it was added.
 	MetricLDAPLookupFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name:        "compliance_audit_router_ldap_lookup_failures",
 		Help:        "Number of LDAP lookups that failed",
 		ConstLabels: CARPrometheusLabels},
 		[]string{"uuid", "process"},
 	)
+	// MetricNonMemberAlerts is the number of compliance events from users outside the required group
+	MetricNonMemberAlerts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:        "compliance_audit_router_non_member_alerts",
+		Help:        "Number of compliance events from users outside the required group",
+		ConstLabels: CARPrometheusLabels},
+		[]string{"uuid", "process"},
+	)
+	// MetricLDAPCacheHits is the number of user lookups answered from the lookup cache
+	MetricLDAPCacheHits = prometheus.NewCounter(prometheus.CounterOpts{
+		Name:        "compliance_audit_router_ldap_cache_hits",
+		Help:        "Number of LDAP user lookups answered from the lookup cache",
+		ConstLabels: CARPrometheusLabels},
+	)
+	// MetricLDAPCacheMisses is the number of user lookups not found in the lookup cache, which query LDAP
+	MetricLDAPCacheMisses = prometheus.NewCounter(prometheus.CounterOpts{
+		Name:        "compliance_audit_router_ldap_cache_misses",
+		Help:        "Number of LDAP user lookups not found in the lookup cache",
+		ConstLabels: CARPrometheusLabels},
+	)
+	// MetricJiraUserCacheHits is the number of Jira user lookups answered from the user cache
+	MetricJiraUserCacheHits = prometheus.NewCounter(prometheus.CounterOpts{
+		Name:        "compliance_audit_router_jira_user_cache_hits",
+		Help:        "Number of Jira user lookups answered from the user cache",
+		ConstLabels: CARPrometheusLabels},
+	)
+	// MetricJiraUserCacheMisses is the number of Jira user lookups not found in the user cache, which query Jira
+	MetricJiraUserCacheMisses = prometheus.NewCounter(prometheus.CounterOpts{
+		Name:        "compliance_audit_router_jira_user_cache_misses",
+		Help:        "Number of Jira user lookups not found in the user cache",
+		ConstLabels: CARPrometheusLabels},
+	)
+	// MetricLDAPBindDuration is the time taken to bind new connections to the LDAP server
+	MetricLDAPBindDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:        "compliance_audit_router_ldap_bind_duration_seconds",
+		Help:        "Time taken to bind new connections to the LDAP server",
+		ConstLabels: CARPrometheusLabels,
+		Buckets:     prometheus.DefBuckets},
+	)
+	// MetricLDAPSearchDuration is the time taken by LDAP searches, including all pages of paged searches
+	MetricLDAPSearchDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:        "compliance_audit_router_ldap_search_duration_seconds",
+		Help:        "Time taken by LDAP searches",
+		ConstLabels: CARPrometheusLabels,
+		Buckets:     prometheus.DefBuckets},
+	)
+	// MetricLDAPPoolHealthy is 1 when the last LDAP search could get a working connection, and 0 when it couldn't
+	MetricLDAPPoolHealthy = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name:        "compliance_audit_router_ldap_pool_healthy",
+		Help:        "Whether the LDAP connection pool could get a working connection for the last search",
+		ConstLabels: CARPrometheusLabels},
+	)
+
+	// ALERT PROCESSING PIPELINE
+
+	// MetricPipelineWorkers is the number of alerts that can be processed at once
+	MetricPipelineWorkers = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name:        "compliance_audit_router_pipeline_workers",
+		Help:        "Number of alerts that can be processed at once",
+		ConstLabels: CARPrometheusLabels},
+	)
+	// MetricPipelineWorkersBusy is the number of alerts being processed
+	MetricPipelineWorkersBusy = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name:        "compliance_audit_router_pipeline_workers_busy",
+		Help:        "Number of alerts being processed",
+		ConstLabels: CARPrometheusLabels},
+	)
+	// MetricPipelineQueueDepth is the number of alerts waiting for a worker
+	MetricPipelineQueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name:        "compliance_audit_router_pipeline_queue_depth",
+		Help:        "Number of alerts waiting for a worker",
+		ConstLabels: CARPrometheusLabels},
+	)
+	// MetricPipelineAlertsRejected is the number of alerts rejected because the queue was full
+	MetricPipelineAlertsRejected = prometheus.NewCounter(prometheus.CounterOpts{
+		Name:        "compliance_audit_router_pipeline_alerts_rejected",
+		Help:        "Number of alerts rejected because the queue was full",
+		ConstLabels: CARPrometheusLabels},
+	)
+	// MetricPipelineEventsInProgress is the number of compliance events being processed, across all alerts
+	MetricPipelineEventsInProgress = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name:        "compliance_audit_router_pipeline_events_in_progress",
+		Help:        "Number of compliance events being processed",
+		ConstLabels: CARPrometheusLabels},
+	)
+	// MetricPipelineDeadlinesExceeded is the number of alerts cancelled for exceeding a deadline, with the stage as a label
+	MetricPipelineDeadlinesExceeded = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:        "compliance_audit_router_pipeline_deadlines_exceeded",
+		Help:        "Number of alerts cancelled for exceeding a deadline with the stage as a label",
+		ConstLabels: CARPrometheusLabels},
+		[]string{"stage"},
+	)
+
+	// CONFIGURATION
+
+	// MetricConfigReloads is the number of configuration reloads, with whether they succeeded as a label
+	MetricConfigReloads = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:        "compliance_audit_router_config_reloads",
+		Help:        "Number of configuration reloads with the result as a label",
+		ConstLabels: CARPrometheusLabels},
+		[]string{"result"},
+	)
 
 	// HTTP RESPONSES TO CLIENTS
 
@@ -149,27 +464,117 @@ var (
 		[]string{"code", "uuid", "process"},
 	)
 
+	// MetricAPIKeyRequests is the number of requests authenticated with API keys, with the key's name, the scope
+	// and whether the request was allowed as labels. Requests with unknown keys have an empty key label.
+	MetricAPIKeyRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:        "compliance_audit_router_api_key_requests",
+		Help:        "Number of requests authenticated with API keys with the key, scope and result as labels",
+		ConstLabels: CARPrometheusLabels},
+		[]string{"key", "scope", "result"},
+	)
+
 	MetricsList = []prometheus.Collector{
 		MetricSplunkWebhookReceived,
 		MetricSplunkWebhookProcessFailures,
 		MetricSplunkAlertSIDReceived,
 		MetricSplunkSearchResultQueryFailures,
+		MetricSplunkRequestDuration,
+		MetricSplunkRequests,
 		MetricComplianceEventsFound,
 		MetricComplianceEventsProcessed,
+		MetricAggregationPendingEvents,
+		MetricAggregationTickets,
+		MetricDuplicateEvents,
+		MetricFloodEvents,
+		MetricDigestPendingEvents,
+		MetricDigestTickets,
+		MetricAuditFailures,
+		MetricRetentionPurgedRecords,
+		MetricRetentionPurgeFailures,
+		MetricEnrichmentFailures,
+		MetricOnCallAlerts,
+		MetricOnCallLookupFailures,
 		MetricJiraClientCreateFailures,
 		MetricJiraIssueCreated,
 		MetricJiraErrorIssuesCreated,
-		MetricJiraIssueCreateFailures,
+		MetricJiraRequestDuration,
+		MetricJiraRequestErrors,
 		MetricJiraWebhookReceived,
 		MetricJiraWebhookProcessFailures,
+		MetricJiraWebhookIgnored,
 		MetricJiraIssueUpdateFailures,
+		MetricJiraIssueStatusChanges,
+		MetricJiraRemindersSent,
+		MetricJiraReminderFailures,
+		MetricJiraAutoApprovals,
+		MetricJiraReadOnlyResolved,
+		MetricJiraRetryBacklog,
+		MetricJiraCircuitOpen,
+		MetricSpoolDepth,
+		MetricSpooledAlerts,
+		MetricJiraIncompleteTickets,
+		MetricOutboxPending,
+		MetricOutboxReconciled,
 		MetricLDAPLookupFailures,
+		MetricNonMemberAlerts,
+		MetricLDAPCacheHits,
+		MetricLDAPCacheMisses,
+		MetricJiraUserCacheHits,
+		MetricJiraUserCacheMisses,
+		MetricLDAPBindDuration,
+		MetricLDAPSearchDuration,
+		MetricLDAPPoolHealthy,
+		MetricPipelineWorkers,
+		MetricPipelineWorkersBusy,
+		MetricPipelineQueueDepth,
+		MetricPipelineAlertsRejected,
+		MetricPipelineEventsInProgress,
+		MetricPipelineDeadlinesExceeded,
+		MetricConfigReloads,
 		MetricHTTPResponses,
+		MetricAPIKeyRequests,
 	}
 )
 
-func RegisterMetrics() {
+// Register registers the router's metrics with the registerer
+func Register(registerer prometheus.Registerer) error {
 	for _, metric := range MetricsList {
-		prometheus.MustRegister(metric)
+		if err := registerer.Register(metric); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewRegistry returns a new registry, rather than the global default registry, with the router's metrics
+// and the Go runtime and process metrics, eg. for tests or a program embedding the router.
+// The router's metrics are the package's collectors, so every registry reports the same values.
+func NewRegistry() (*prometheus.Registry, error) {
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(collectors.NewGoCollector()); err != nil {
+		return nil, err
+	}
+	if err := registry.Register(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{})); err != nil {
+		return nil, err
+	}
+	if err := Register(registry); err != nil {
+		return nil, err
+	}
+	return registry, nil
+}
+
+// Handler serves the metrics of the registry together with those of the other gatherers, eg. the registries
+// of the program embedding the router. The requests to the handler are counted in the registry.
+func Handler(registry *prometheus.Registry, gatherers ...prometheus.Gatherer) http.Handler {
+	gatherers = append([]prometheus.Gatherer{registry}, gatherers...)
+	return promhttp.InstrumentMetricHandler(registry, promhttp.HandlerFor(prometheus.Gatherers(gatherers), promhttp.HandlerOpts{}))
+}
+
+// RegisterMetrics registers the router's metrics with the global default registry.
+//
+// Deprecated: use NewRegistry, or Register with a registry of its own.
+func RegisterMetrics() {
+	if err := Register(prometheus.DefaultRegisterer); err != nil {
+		panic(err)
 	}
 }
@@ -0,0 +1,49 @@
+// Copyright 2021-2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestNewRegistry(t *testing.T) {
+	// More than one registry can be created, eg. by tests, though they share the package's collectors
+	first, err := NewRegistry()
+	if err != nil {
+		t.Fatalf("NewRegistry() error = %v", err)
+	}
+	if _, err := NewRegistry(); err != nil {
+		t.Fatalf("NewRegistry() error = %v for a second registry", err)
+	}
+
+	embedder := prometheus.NewRegistry()
+	embedder.MustRegister(prometheus.NewCounter(prometheus.CounterOpts{Name: "embedder_requests"}))
+	MetricConfigReloads.With(prometheus.Labels{"result": "success"}).Inc()
+
+	recorder := httptest.NewRecorder()
+	Handler(first, embedder).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	body := recorder.Body.String()
+	for _, name := range []string{"compliance_audit_router_config_reloads", "go_goroutines", "embedder_requests"} {
+		if !strings.Contains(body, name) {
+			t.Errorf("Handler() response is missing %v", name)
+		}
+	}
+}
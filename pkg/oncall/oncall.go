@@ -0,0 +1,67 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package oncall checks whether alerting users were on call for an active incident on the
+// alert's cluster, so elevation during incident response isn't treated as unexplained
+package oncall
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+)
+
+// Incident is an active incident the alerting user is on call for
+type Incident struct {
+	ID    string
+	Title string
+	// URL is the incident's page in the on-call service
+	URL string
+}
+
+// Checker looks up the active incident on one of the clusters that the user is on call for.
+// Lookups are abandoned when the context is done.
+type Checker interface {
+	ActiveIncident(ctx context.Context, user string, clusterIDs []string) (*Incident, error)
+}
+
+// New returns the checker of the configured on-call service, or nil when the lookup is disabled
+func New(onCallConfig config.OnCallConfig) (Checker, error) {
+	switch onCallConfig.Provider {
+	case "":
+		return nil, nil
+	case config.OnCallProviderPagerDuty:
+		return newPagerDutyChecker(onCallConfig), nil
+	default:
+		return nil, fmt.Errorf("unknown on-call provider: %s", onCallConfig.Provider)
+	}
+}
+
+var (
+	defaultCheckerOnce sync.Once
+	defaultChecker     Checker
+	defaultCheckerErr  error
+)
+
+// Default returns the configured checker, or nil when the lookup is disabled
+func Default() (Checker, error) {
+	defaultCheckerOnce.Do(func() {
+		defaultChecker, defaultCheckerErr = New(config.AppConfig().OnCallConfig)
+	})
+	return defaultChecker, defaultCheckerErr
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oncall
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/helpers"
+)
+
+// pagerDutyPageSize is the number of incidents requested per page
+const pagerDutyPageSize = 100
+
+// pagerDutyChecker looks up incidents with the PagerDuty REST API
+type pagerDutyChecker struct {
+	config config.OnCallConfig
+	client *http.Client
+}
+
+type pagerDutyUser struct {
+	ID    string `json:"id"`
+	Email string `json:"email"`
+}
+
+type pagerDutyIncident struct {
+	ID      string `json:"id"`
+	Title   string `json:"title"`
+	HTMLURL string `json:"html_url"`
+}
+
+func newPagerDutyChecker(onCallConfig config.OnCallConfig) *pagerDutyChecker {
+	return &pagerDutyChecker{config: onCallConfig, client: &http.Client{}}
+}
+
+// ActiveIncident implements the Checker interface. The user must be on call, and an incident assigned
+// to them that is triggered or acknowledged must name one of the clusters in its title.
+func (p *pagerDutyChecker) ActiveIncident(ctx context.Context, user string, clusterIDs []string) (*Incident, error) {
+	if len(clusterIDs) == 0 {
+		return nil, nil
+	}
+
+	userID, err := p.findUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	var oncalls struct {
+		OnCalls []struct{} `json:"oncalls"`
+	}
+	query := url.Values{"user_ids[]": {userID}, "earliest": {"true"}}
+	if err := p.get(ctx, "/oncalls", query, &oncalls); err != nil {
+		return nil, fmt.Errorf("failed to get the on-call shifts of %s: %w", user, err)
+	}
+	if len(oncalls.OnCalls) == 0 {
+		return nil, nil
+	}
+
+	for offset := 0; ; offset += pagerDutyPageSize {
+		var incidents struct {
+			Incidents []pagerDutyIncident `json:"incidents"`
+			More      bool                `json:"more"`
+		}
+		query := url.Values{
+			"user_ids[]": {userID},
+			"statuses[]": {"triggered", "acknowledged"},
+			"limit":      {strconv.Itoa(pagerDutyPageSize)},
+			"offset":     {strconv.Itoa(offset)},
+		}
+		if err := p.get(ctx, "/incidents", query, &incidents); err != nil {
+			return nil, fmt.Errorf("failed to get the incidents of %s: %w", user, err)
+		}
+
+		for _, incident := range incidents.Incidents {
+			if mentionsCluster(incident.Title, clusterIDs) {
+				return &Incident{ID: incident.ID, Title: incident.Title, URL: incident.HTMLURL}, nil
+			}
+		}
+		if !incidents.More {
+			return nil, nil
+		}
+	}
+}
+
+// findUser returns the PagerDuty ID of the user with the username's email address
+func (p *pagerDutyChecker) findUser(ctx context.Context, username string) (string, error) {
+	email := username
+	if !strings.Contains(email, "@") && p.config.EmailDomain != "" {
+		email = email + "@" + p.config.EmailDomain
+	}
+
+	var users struct {
+		Users []pagerDutyUser `json:"users"`
+	}
+	if err := p.get(ctx, "/users", url.Values{"query": {email}}, &users); err != nil {
+		return "", fmt.Errorf("failed to find the pagerduty user %s: %w", email, err)
+	}
+	for _, u := range users.Users {
+		if strings.EqualFold(u.Email, email) {
+			return u.ID, nil
+		}
+	}
+	return "", fmt.Errorf("no pagerduty user has the email address %s", email)
+}
+
+// get decodes the JSON response to a GET request for the API path into dst
+func (p *pagerDutyChecker) get(ctx context.Context, path string, query url.Values, dst interface{}) error {
+	requestURL := strings.TrimSuffix(p.config.URL, "/") + path + "?" + query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, http.NoBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Authorization", "Token token="+p.config.Token)
+	req.Header.Add("Accept", "application/vnd.pagerduty+json;version=2")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("pagerduty request failed: %s", resp.Status)
+	}
+	return helpers.DecodeJSONResponseBody(resp, dst)
+}
+
+// mentionsCluster reports whether the incident title names one of the clusters
+func mentionsCluster(title string, clusterIDs []string) bool {
+	title = strings.ToLower(title)
+	for _, id := range clusterIDs {
+		if id != "" && strings.Contains(title, strings.ToLower(id)) {
+			return true
+		}
+	}
+	return false
+}
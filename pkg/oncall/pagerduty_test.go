@@ -0,0 +1,94 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package oncall
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+)
+
+func newPagerDutyTestServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Token token=secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		query := r.URL.Query()
+		switch r.URL.Path {
+		case "/users":
+			_, _ = w.Write([]byte(`{"users": [{"id": "PSRE", "email": "sre@example.com"}, {"id": "PIDLE", "email": "idle@example.com"}]}`))
+		case "/oncalls":
+			if query.Get("user_ids[]") == "PSRE" {
+				_, _ = w.Write([]byte(`{"oncalls": [{"escalation_level": 1}]}`))
+			} else {
+				_, _ = w.Write([]byte(`{"oncalls": []}`))
+			}
+		case "/incidents":
+			if query.Get("offset") == "0" {
+				_, _ = w.Write([]byte(`{"incidents": [{"id": "Q1", "title": "etcd is down on other-cluster", "html_url": "https://pd.example.com/incidents/Q1"}], "more": true}`))
+			} else {
+				_, _ = w.Write([]byte(`{"incidents": [{"id": "Q2", "title": "API unavailable on ABC123", "html_url": "https://pd.example.com/incidents/Q2"}], "more": false}`))
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestPagerDutyActiveIncident(t *testing.T) {
+	server := newPagerDutyTestServer()
+	defer server.Close()
+
+	checker, err := New(config.OnCallConfig{Provider: config.OnCallProviderPagerDuty, URL: server.URL, Token: "secret", EmailDomain: "example.com"})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		user       string
+		clusterIDs []string
+		want       string
+		wantErr    bool
+	}{
+		{"On call for an incident on the cluster", "sre", []string{"abc123"}, "Q2", false},
+		{"On call without an incident on the cluster", "sre@example.com", []string{"def456"}, "", false},
+		{"Not on call", "idle", []string{"abc123"}, "", false},
+		{"No clusters", "sre", nil, "", false},
+		{"Unknown user", "nobody", []string{"abc123"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			incident, err := checker.ActiveIncident(context.Background(), tt.user, tt.clusterIDs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ActiveIncident() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var got string
+			if incident != nil {
+				got = incident.ID
+			}
+			if got != tt.want {
+				t.Errorf("ActiveIncident() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package risk scores the elevated commands of compliance events against the configured rules,
+// so reviewers can triage the riskiest elevations first
+package risk
+
+import (
+	"strings"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+)
+
+// Command is an elevated command of a compliance event
+type Command struct {
+	Verb      string
+	Resource  string
+	Namespace string
+}
+
+// ParseCommand parses an entry of the elevated summary,
+// eg. "DELETE secrets/serving-cert (openshift-cluster-version) 200"
+func ParseCommand(entry string) Command {
+	var c Command
+
+	fields := strings.Fields(entry)
+	if len(fields) > 0 {
+		c.Verb = fields[0]
+	}
+	if len(fields) > 1 && !strings.HasPrefix(fields[1], "(") {
+		c.Resource, _, _ = strings.Cut(fields[1], "/")
+	}
+	if open := strings.Index(entry, "("); open >= 0 {
+		if end := strings.Index(entry[open:], ")"); end > 0 {
+			c.Namespace = entry[open+1 : open+end]
+		}
+	}
+
+	return c
+}
+
+// Score returns the score of the riskiest of the elevated commands, scoring each with the
+// first rule matching it, or 0 when no rule matches any
+func Score(elevatedSummary []string, rules []config.RiskRule) int {
+	score := 0
+	for _, entry := range elevatedSummary {
+		command := ParseCommand(entry)
+		for _, rule := range rules {
+			if matches(rule, command) {
+				if rule.Score > score {
+					score = rule.Score
+				}
+				break
+			}
+		}
+	}
+	return score
+}
+
+// IsReadOnly reports whether the elevated commands were all read-only, ie. their verbs are all
+// one of the read-only verbs, case-insensitively. No commands are not read-only, as there is nothing to judge.
+func IsReadOnly(elevatedSummary []string, readOnlyVerbs []string) bool {
+	if len(elevatedSummary) == 0 || len(readOnlyVerbs) == 0 {
+		return false
+	}
+	for _, entry := range elevatedSummary {
+		if !matchesAny(readOnlyVerbs, ParseCommand(entry).Verb) {
+			return false
+		}
+	}
+	return true
+}
+
+// matches reports whether the rule matches the command
+func matches(rule config.RiskRule, command Command) bool {
+	return matchesAny(rule.Verbs, command.Verb) &&
+		matchesAny(rule.Resources, command.Resource) &&
+		matchesAny(rule.Namespaces, command.Namespace)
+}
+
+// matchesAny reports whether the value is one of the values, case-insensitively, or the values are empty
+func matchesAny(values []string, value string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
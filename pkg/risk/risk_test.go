@@ -0,0 +1,89 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package risk
+
+import (
+	"testing"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+)
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		entry string
+		want  Command
+	}{
+		{"DELETE secrets/serving-cert (openshift-cluster-version) 200", Command{Verb: "DELETE", Resource: "secrets", Namespace: "openshift-cluster-version"}},
+		{"GET pods 200", Command{Verb: "GET", Resource: "pods"}},
+		{"LIST (default)", Command{Verb: "LIST", Namespace: "default"}},
+		{"", Command{}},
+	}
+	for _, tt := range tests {
+		if got := ParseCommand(tt.entry); got != tt.want {
+			t.Errorf("ParseCommand(%q) = %+v, want %+v", tt.entry, got, tt.want)
+		}
+	}
+}
+
+func TestScore(t *testing.T) {
+	rules := []config.RiskRule{
+		{Verbs: []string{"delete", "patch"}, Resources: []string{"secrets"}, Score: 80},
+		{Verbs: []string{"delete"}, Namespaces: []string{"openshift-etcd"}, Score: 90},
+		{Verbs: []string{"get", "list"}, Score: 1},
+		{Score: 20},
+	}
+
+	tests := []struct {
+		name            string
+		elevatedSummary []string
+		want            int
+	}{
+		{"no commands", nil, 0},
+		{"reads", []string{"GET pods/a (default) 200", "LIST nodes 200"}, 1},
+		{"riskiest command", []string{"GET pods/a (default) 200", "PATCH secrets/b (default) 200"}, 80},
+		{"first matching rule applies", []string{"DELETE secrets/c (openshift-etcd) 200"}, 80},
+		{"catch-all rule", []string{"CREATE pods/d (default) 201"}, 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Score(tt.elevatedSummary, rules); got != tt.want {
+				t.Errorf("Score() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsReadOnly(t *testing.T) {
+	verbs := []string{"get", "list", "watch"}
+
+	tests := []struct {
+		name            string
+		elevatedSummary []string
+		want            bool
+	}{
+		{"no commands", nil, false},
+		{"reads", []string{"GET pods/a (default) 200", "WATCH events (default) 200"}, true},
+		{"a write", []string{"GET pods/a (default) 200", "DELETE pods/a (default) 200"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsReadOnly(tt.elevatedSummary, verbs); got != tt.want {
+				t.Errorf("IsReadOnly() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2021-2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scheduler
+
+// Package scheduler runs background jobs, such as ticket reminders, on a fixed interval
+
+import (
+	"log"
+	"time"
+)
+
+// Job is a function run periodically by the scheduler
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func()
+}
+
+// Start runs each job in its own goroutine every job interval until stop is closed.
+// A job is never run concurrently with itself; a run taking longer than the
+// interval delays the next run.
+func Start(stop <-chan struct{}, jobs ...Job) {
+	for _, job := range jobs {
+		if job.Interval <= 0 {
+			log.Printf("scheduler.Start(): not scheduling job %s with invalid interval %v", job.Name, job.Interval)
+			continue
+		}
+
+		log.Printf("scheduler.Start(): scheduling job %s every %v", job.Name, job.Interval)
+		go run(stop, job)
+	}
+}
+
+func run(stop <-chan struct{}, job Job) {
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			runJob(job)
+		}
+	}
+}
+
+// runJob runs the job once, recovering from panics so a failing job doesn't stop the service
+func runJob(job Job) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("scheduler.runJob(): job %s panicked: %v", job.Name, r)
+		}
+	}()
+
+	job.Run()
+}
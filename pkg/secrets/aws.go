@@ -0,0 +1,382 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secrets
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/openshift/compliance-audit-router/pkg/helpers"
+)
+
+// awsEndpoint returns the Secrets Manager endpoint of the region
+var awsEndpoint = func(region string) string {
+	return fmt.Sprintf("https://secretsmanager.%s.amazonaws.com", region)
+}
+
+var (
+	// awsSTSEndpoint returns the STS endpoint of the region, or the global endpoint
+	awsSTSEndpoint = func(region string) string {
+		if region == "" {
+			return "https://sts.amazonaws.com"
+		}
+		return fmt.Sprintf("https://sts.%s.amazonaws.com", region)
+	}
+	// awsContainerCredentialsHost serves the credentials of ECS tasks, at AWS_CONTAINER_CREDENTIALS_RELATIVE_URI
+	awsContainerCredentialsHost = "http://169.254.170.2"
+	// awsInstanceMetadataURL is the EC2 instance metadata service, serving the credentials of the instance role
+	awsInstanceMetadataURL = "http://169.254.169.254"
+)
+
+// awsMetadataClient calls the link-local credential endpoints, which either answer right away or aren't there
+var awsMetadataClient = &http.Client{Timeout: 5 * time.Second}
+
+// awsCredentials are the credentials requests are signed with
+type awsCredentials struct {
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+}
+
+// resolveAWS returns the string value of the Secrets Manager secret with the given name or ARN,
+// authenticating with the first credentials found by awsDefaultCredentials, in the region of
+// the ARN or the AWS_* environment variables
+func resolveAWS(secretID string) (string, error) {
+	region := awsRegion(secretID)
+	if region == "" {
+		return "", errors.New("AWS_REGION must be set, or the secret referenced by ARN")
+	}
+
+	credentials, err := awsDefaultCredentials(region)
+	if err != nil {
+		return "", err
+	}
+
+	body, err := json.Marshal(map[string]string{"SecretId": secretID})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest(http.MethodPost, awsEndpoint(region)+"/", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	signAWSRequest(req, body, credentials, region, "secretsmanager", time.Now())
+
+	var secret struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := do(req, &secret); err != nil {
+		return "", err
+	}
+	if secret.SecretString == "" {
+		return "", errors.New("secret has no string value")
+	}
+	return secret.SecretString, nil
+}
+
+// awsRegion returns the region of the secret's ARN, or the region of the environment
+func awsRegion(secretID string) string {
+	// arn:aws:secretsmanager:<region>:<account>:secret:<name>
+	if parts := strings.Split(secretID, ":"); len(parts) > 3 && parts[0] == "arn" {
+		return parts[3]
+	}
+	if region := os.Getenv("AWS_REGION"); region != "" {
+		return region
+	}
+	return os.Getenv("AWS_DEFAULT_REGION")
+}
+
+// awsDefaultCredentials returns the credentials of the default AWS credential chain, in order:
+// the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables,
+// the web identity token of AWS_WEB_IDENTITY_TOKEN_FILE exchanged for AWS_ROLE_ARN (IRSA and EKS Pod Identity),
+// the ECS task role of AWS_CONTAINER_CREDENTIALS_RELATIVE_URI or AWS_CONTAINER_CREDENTIALS_FULL_URI,
+// and finally the EC2 instance role from the instance metadata service.
+func awsDefaultCredentials(region string) (awsCredentials, error) {
+	if accessKeyID, secretAccessKey := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"); accessKeyID != "" && secretAccessKey != "" {
+		return awsCredentials{accessKeyID: accessKeyID, secretAccessKey: secretAccessKey, sessionToken: os.Getenv("AWS_SESSION_TOKEN")}, nil
+	}
+
+	if tokenFile, roleARN := os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"), os.Getenv("AWS_ROLE_ARN"); tokenFile != "" && roleARN != "" {
+		credentials, err := awsWebIdentityCredentials(region, tokenFile, roleARN)
+		if err != nil {
+			return awsCredentials{}, fmt.Errorf("failed to assume %s with the web identity token: %w", roleARN, err)
+		}
+		return credentials, nil
+	}
+
+	if os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI") != "" || os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI") != "" {
+		credentials, err := awsContainerCredentials()
+		if err != nil {
+			return awsCredentials{}, fmt.Errorf("failed to get the container credentials: %w", err)
+		}
+		return credentials, nil
+	}
+
+	if strings.EqualFold(os.Getenv("AWS_EC2_METADATA_DISABLED"), "true") {
+		return awsCredentials{}, errors.New("no AWS credentials found in the environment, and the instance metadata service is disabled")
+	}
+	credentials, err := awsInstanceCredentials()
+	if err != nil {
+		return awsCredentials{}, fmt.Errorf("no AWS credentials found in the environment, and failed to get the instance role credentials: %w", err)
+	}
+	return credentials, nil
+}
+
+// awsWebIdentityCredentials exchanges the web identity token, eg. a projected service account token,
+// for the credentials of the role with STS AssumeRoleWithWebIdentity, which requires no signature
+func awsWebIdentityCredentials(region, tokenFile, roleARN string) (awsCredentials, error) {
+	token, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	sessionName := os.Getenv("AWS_ROLE_SESSION_NAME")
+	if sessionName == "" {
+		sessionName = "compliance-audit-router"
+	}
+
+	query := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {roleARN},
+		"RoleSessionName":  {sessionName},
+		"WebIdentityToken": {strings.TrimSpace(string(token))},
+	}
+	req, err := http.NewRequest(http.MethodPost, awsSTSEndpoint(region)+"/", strings.NewReader(query.Encode()))
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return awsCredentials{}, fmt.Errorf("request failed: %s", resp.Status)
+	}
+
+	var result struct {
+		Credentials struct {
+			AccessKeyID     string `xml:"AccessKeyId"`
+			SecretAccessKey string `xml:"SecretAccessKey"`
+			SessionToken    string `xml:"SessionToken"`
+		} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return awsCredentials{}, fmt.Errorf("failed to decode the STS response: %w", err)
+	}
+	return awsCredentials{
+		accessKeyID:     result.Credentials.AccessKeyID,
+		secretAccessKey: result.Credentials.SecretAccessKey,
+		sessionToken:    result.Credentials.SessionToken,
+	}, nil
+}
+
+// awsMetadataCredentials are the credentials returned by the ECS and EC2 credential endpoints
+type awsMetadataCredentials struct {
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	Token           string `json:"Token"`
+}
+
+func (c awsMetadataCredentials) credentials() awsCredentials {
+	return awsCredentials{accessKeyID: c.AccessKeyID, secretAccessKey: c.SecretAccessKey, sessionToken: c.Token}
+}
+
+// awsContainerCredentials returns the credentials of the ECS task role, or of the full URI's endpoint
+// authorized with AWS_CONTAINER_AUTHORIZATION_TOKEN or AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE
+func awsContainerCredentials() (awsCredentials, error) {
+	endpoint := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+	if relativeURI := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); relativeURI != "" {
+		endpoint = awsContainerCredentialsHost + relativeURI
+	}
+	req, err := http.NewRequest(http.MethodGet, endpoint, http.NoBody)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+
+	authorization := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN")
+	if tokenFile := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE"); tokenFile != "" {
+		token, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return awsCredentials{}, err
+		}
+		authorization = strings.TrimSpace(string(token))
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	var credentials awsMetadataCredentials
+	if err := awsMetadataDo(req, &credentials); err != nil {
+		return awsCredentials{}, err
+	}
+	return credentials.credentials(), nil
+}
+
+// awsInstanceCredentials returns the credentials of the EC2 instance role, with an IMDSv2 session token
+func awsInstanceCredentials() (awsCredentials, error) {
+	req, err := http.NewRequest(http.MethodPut, awsInstanceMetadataURL+"/latest/api/token", http.NoBody)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "60")
+	token, err := awsMetadataText(req)
+	if err != nil {
+		return awsCredentials{}, fmt.Errorf("failed to get a metadata session token: %w", err)
+	}
+
+	credentialsURL := awsInstanceMetadataURL + "/latest/meta-data/iam/security-credentials/"
+	req, err = http.NewRequest(http.MethodGet, credentialsURL, http.NoBody)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token", token)
+	role, err := awsMetadataText(req)
+	if err != nil {
+		return awsCredentials{}, fmt.Errorf("failed to get the instance role: %w", err)
+	}
+	// The instance profile has a single role
+	role, _, _ = strings.Cut(role, "\n")
+	if role == "" {
+		return awsCredentials{}, errors.New("the instance has no role")
+	}
+
+	req, err = http.NewRequest(http.MethodGet, credentialsURL+url.PathEscape(role), http.NoBody)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token", token)
+	var credentials awsMetadataCredentials
+	if err := awsMetadataDo(req, &credentials); err != nil {
+		return awsCredentials{}, err
+	}
+	return credentials.credentials(), nil
+}
+
+// awsMetadataDo sends the request to a credential endpoint, decoding the JSON response into dst
+func awsMetadataDo(req *http.Request, dst interface{}) error {
+	resp, err := awsMetadataClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("request failed: %s", resp.Status)
+	}
+	return helpers.DecodeJSONResponseBody(resp, dst)
+}
+
+// awsMetadataText sends the request to the instance metadata service, returning the text response
+func awsMetadataText(req *http.Request) (string, error) {
+	resp, err := awsMetadataClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("request failed: %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// signAWSRequest signs the request with AWS Signature Version 4. The host and every header of the request are signed.
+func signAWSRequest(req *http.Request, body []byte, credentials awsCredentials, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if credentials.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", credentials.sessionToken)
+	}
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	signedHeaders := []string{"host"}
+	for name := range req.Header {
+		signedHeaders = append(signedHeaders, strings.ToLower(name))
+	}
+	sort.Strings(signedHeaders)
+	var canonicalHeaders strings.Builder
+	for _, h := range signedHeaders {
+		value := strings.Join(req.Header.Values(h), ",")
+		if h == "host" {
+			value = host
+		}
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", h, strings.TrimSpace(value))
+	}
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		strings.ReplaceAll(req.URL.Query().Encode(), "+", "%20"),
+		canonicalHeaders.String(),
+		strings.Join(signedHeaders, ";"),
+		sha256Hex(body),
+	}, "\n")
+
+	scope := strings.Join([]string{date, region, service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+credentials.secretAccessKey), date)
+	for _, part := range []string{region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		credentials.accessKeyID, scope, strings.Join(signedHeaders, ";"), signature))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package secrets
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var (
+	gcpSecretManagerURL = "https://secretmanager.googleapis.com"
+	// gcpTokenURL is the metadata server endpoint returning the access token of the instance's service account
+	gcpTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+)
+
+// resolveGCP returns the value of the Secret Manager secret referenced as project/name, or
+// project/name/version for a version other than the latest, authenticating as the service
+// account of the instance or workload with the metadata server. Unlike the Google client libraries, it doesn't
+// read a service account key from GOOGLE_APPLICATION_CREDENTIALS, so it only works on GCE, GKE and Cloud Run.
+func resolveGCP(reference string) (string, error) {
+	parts := strings.Split(reference, "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return "", fmt.Errorf("reference must be project/name or project/name/version: %s", reference)
+	}
+	version := "latest"
+	if len(parts) == 3 {
+		version = parts[2]
+	}
+
+	token, err := gcpAccessToken()
+	if err != nil {
+		return "", fmt.Errorf("failed to get an access token from the metadata server: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/v1/projects/%s/secrets/%s/versions/%s:access",
+		gcpSecretManagerURL, parts[0], parts[1], version), http.NoBody)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	var secret struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+	if err := do(req, &secret); err != nil {
+		return "", err
+	}
+
+	data, err := base64.StdEncoding.DecodeString(secret.Payload.Data)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode the secret payload: %w", err)
+	}
+	return string(data), nil
+}
+
+func gcpAccessToken() (string, error) {
+	req, err := http.NewRequest(http.MethodGet, gcpTokenURL, http.NoBody)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := do(req, &token); err != nil {
+		return "", err
+	}
+	return token.AccessToken, nil
+}
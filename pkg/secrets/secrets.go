@@ -0,0 +1,90 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package secrets resolves references to secrets held in cloud secret managers,
+// so credentials don't have to be set in the config file or environment
+package secrets
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/openshift/compliance-audit-router/pkg/helpers"
+)
+
+const (
+	// AWSPrefix references an AWS Secrets Manager secret by name or ARN, eg. awssm://compliance/jira-token
+	AWSPrefix = "awssm://"
+	// GCPPrefix references a GCP Secret Manager secret, eg. gcpsm://my-project/jira-token
+	GCPPrefix = "gcpsm://"
+)
+
+var client = &http.Client{Timeout: 30 * time.Second}
+
+// IsReference reports whether the value is a secret manager reference
+func IsReference(value string) bool {
+	return strings.HasPrefix(value, AWSPrefix) || strings.HasPrefix(value, GCPPrefix)
+}
+
+// Resolve returns the secret referenced by value, or value itself when it is not a reference.
+// A reference ending with #key selects the key from a secret holding a JSON object.
+func Resolve(value string) (string, error) {
+	var secret string
+	var err error
+
+	reference, key, _ := strings.Cut(value, "#")
+	switch {
+	case strings.HasPrefix(reference, AWSPrefix):
+		secret, err = resolveAWS(strings.TrimPrefix(reference, AWSPrefix))
+	case strings.HasPrefix(reference, GCPPrefix):
+		secret, err = resolveGCP(strings.TrimPrefix(reference, GCPPrefix))
+	default:
+		return value, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve secret %s: %w", reference, err)
+	}
+
+	if key == "" {
+		return secret, nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(secret), &fields); err != nil {
+		return "", fmt.Errorf("secret %s is not a JSON object: %w", reference, err)
+	}
+	field, ok := fields[key].(string)
+	if !ok {
+		return "", fmt.Errorf("secret %s has no string key %s", reference, key)
+	}
+	return field, nil
+}
+
+// do sends the request, decoding the JSON response into dst
+func do(req *http.Request, dst interface{}) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("request failed: %s", resp.Status)
+	}
+	return helpers.DecodeJSONResponseBody(resp, dst)
+}
@@ -0,0 +1,236 @@
+// Copyright 2021-2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secrets
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolvePlainValue(t *testing.T) {
+	if got, err := Resolve("plain-token"); err != nil || got != "plain-token" {
+		t.Errorf("Resolve() = %v, %v, want the value itself", got, err)
+	}
+}
+
+func TestResolveAWS(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Target") != "secretsmanager.GetSecretValue" ||
+			!strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") ||
+			!strings.Contains(r.Header.Get("Authorization"), "/us-east-1/secretsmanager/aws4_request") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		var request struct {
+			SecretId string
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.SecretId != "compliance/jira" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`{"SecretString":"{\"token\":\"jira-token\"}"}`))
+	}))
+	defer server.Close()
+
+	defaultEndpoint := awsEndpoint
+	defer func() { awsEndpoint = defaultEndpoint }()
+	awsEndpoint = func(string) string { return server.URL }
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_REGION", "us-east-1")
+
+	if got, err := Resolve("awssm://compliance/jira#token"); err != nil || got != "jira-token" {
+		t.Errorf("Resolve() = %v, %v, want jira-token", got, err)
+	}
+	if _, err := Resolve("awssm://compliance/jira#missing"); err == nil {
+		t.Errorf("Resolve() expected an error for a missing key")
+	}
+}
+
+func TestSignAWSRequest(t *testing.T) {
+	// The requests, credentials and signatures of AWS's Signature Version 4 test suite
+	credentials := awsCredentials{accessKeyID: "AKIDEXAMPLE", secretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	signed := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		want        string
+	}{
+		{
+			name:   "get-vanilla",
+			method: http.MethodGet,
+			want:   "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			name:   "post-vanilla",
+			method: http.MethodPost,
+			want:   "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5da7c1a2acd57cee7505fc6676e4e544621c30862966e37dddb68e92efbe5d6b",
+		},
+		{
+			name:        "post-x-www-form-urlencoded",
+			method:      http.MethodPost,
+			contentType: "application/x-www-form-urlencoded",
+			body:        "Param1=value1",
+			want:        "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=content-type;host;x-amz-date, Signature=ff11897932ad3f4e8b18135d722051e5ac45fc38421b1da7b9d196a0fe09473a",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, "https://example.amazonaws.com/", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+
+			signAWSRequest(req, []byte(tt.body), credentials, "us-east-1", "service", signed)
+			if got := req.Header.Get("Authorization"); got != tt.want {
+				t.Errorf("signAWSRequest() Authorization = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAWSDefaultCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/" && r.Method == http.MethodPost:
+			if err := r.ParseForm(); err != nil || r.Form.Get("Action") != "AssumeRoleWithWebIdentity" ||
+				r.Form.Get("RoleArn") != "arn:aws:iam::123456789012:role/router" || r.Form.Get("WebIdentityToken") != "web-token" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			_, _ = w.Write([]byte(`<AssumeRoleWithWebIdentityResponse><AssumeRoleWithWebIdentityResult><Credentials>` +
+				`<AccessKeyId>IRSA</AccessKeyId><SecretAccessKey>secret</SecretAccessKey><SessionToken>session</SessionToken>` +
+				`</Credentials></AssumeRoleWithWebIdentityResult></AssumeRoleWithWebIdentityResponse>`))
+		case r.URL.Path == "/v2/credentials/task":
+			if r.Header.Get("Authorization") != "" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			_, _ = w.Write([]byte(`{"AccessKeyId":"ECS","SecretAccessKey":"secret","Token":"session"}`))
+		case r.URL.Path == "/latest/api/token" && r.Method == http.MethodPut:
+			_, _ = w.Write([]byte("imds-token"))
+		case strings.HasPrefix(r.URL.Path, "/latest/meta-data/iam/security-credentials/"):
+			if r.Header.Get("X-aws-ec2-metadata-token") != "imds-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if r.URL.Path == "/latest/meta-data/iam/security-credentials/" {
+				_, _ = w.Write([]byte("router-role\n"))
+				return
+			}
+			_, _ = w.Write([]byte(`{"AccessKeyId":"EC2","SecretAccessKey":"secret","Token":"session"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	defaultSTSEndpoint, defaultContainerHost, defaultMetadataURL := awsSTSEndpoint, awsContainerCredentialsHost, awsInstanceMetadataURL
+	defer func() {
+		awsSTSEndpoint, awsContainerCredentialsHost, awsInstanceMetadataURL = defaultSTSEndpoint, defaultContainerHost, defaultMetadataURL
+	}()
+	awsSTSEndpoint = func(string) string { return server.URL }
+	awsContainerCredentialsHost, awsInstanceMetadataURL = server.URL, server.URL
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("web-token\n"), 0600); err != nil {
+		t.Fatalf("failed to write the token file: %v", err)
+	}
+
+	for _, env := range []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AWS_WEB_IDENTITY_TOKEN_FILE", "AWS_ROLE_ARN",
+		"AWS_CONTAINER_CREDENTIALS_RELATIVE_URI", "AWS_CONTAINER_CREDENTIALS_FULL_URI", "AWS_CONTAINER_AUTHORIZATION_TOKEN",
+		"AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE", "AWS_EC2_METADATA_DISABLED"} {
+		t.Setenv(env, "")
+	}
+
+	// Each source is used when the ones before it aren't configured
+	tests := []struct {
+		name string
+		env  map[string]string
+		want awsCredentials
+	}{
+		{"instance role", nil, awsCredentials{"EC2", "secret", "session"}},
+		{"container", map[string]string{"AWS_CONTAINER_CREDENTIALS_RELATIVE_URI": "/v2/credentials/task"}, awsCredentials{"ECS", "secret", "session"}},
+		{"web identity", map[string]string{"AWS_WEB_IDENTITY_TOKEN_FILE": tokenFile, "AWS_ROLE_ARN": "arn:aws:iam::123456789012:role/router"}, awsCredentials{"IRSA", "secret", "session"}},
+		{"environment", map[string]string{"AWS_ACCESS_KEY_ID": "AKID", "AWS_SECRET_ACCESS_KEY": "secret", "AWS_SESSION_TOKEN": "session"}, awsCredentials{"AKID", "secret", "session"}},
+	}
+	for _, tt := range tests {
+		for env, value := range tt.env {
+			t.Setenv(env, value)
+		}
+		if got, err := awsDefaultCredentials("us-east-1"); err != nil || got != tt.want {
+			t.Errorf("%s: awsDefaultCredentials() = %+v, %v, want %+v", tt.name, got, err, tt.want)
+		}
+	}
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_WEB_IDENTITY_TOKEN_FILE", "")
+	t.Setenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI", "")
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+	if _, err := awsDefaultCredentials("us-east-1"); err == nil {
+		t.Errorf("awsDefaultCredentials() expected an error without credentials")
+	}
+}
+
+func TestResolveGCP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			if r.Header.Get("Metadata-Flavor") != "Google" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			_, _ = w.Write([]byte(`{"access_token":"test-token"}`))
+		case "/v1/projects/my-project/secrets/jira-token/versions/latest:access":
+			if r.Header.Get("Authorization") != "Bearer test-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			// "jira-token", base64 encoded
+			_, _ = w.Write([]byte(`{"payload":{"data":"amlyYS10b2tlbg=="}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	defaultSecretManagerURL, defaultTokenURL := gcpSecretManagerURL, gcpTokenURL
+	defer func() { gcpSecretManagerURL, gcpTokenURL = defaultSecretManagerURL, defaultTokenURL }()
+	gcpSecretManagerURL, gcpTokenURL = server.URL, server.URL+"/token"
+
+	if got, err := Resolve("gcpsm://my-project/jira-token"); err != nil || got != "jira-token" {
+		t.Errorf("Resolve() = %v, %v, want jira-token", got, err)
+	}
+	if _, err := Resolve("gcpsm://my-project/other-token"); err == nil {
+		t.Errorf("Resolve() expected an error for an unknown secret")
+	}
+	if _, err := Resolve("gcpsm://jira-token"); err == nil {
+		t.Errorf("Resolve() expected an error for a reference without a project")
+	}
+}
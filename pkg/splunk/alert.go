@@ -15,8 +15,14 @@
 package splunk
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
+
+	"golang.org/x/exp/slices"
 )
 
 // AlertDetails is a structured Splunk alert details
@@ -31,6 +37,24 @@ type AlertDetails struct {
 	ElevatedSummaryText string
 	Reasons             []string
 	ReasonsText         string
+
+	// Enrichment is the context added to the alert by the enrichers, by field name
+	Enrichment map[string]string
+	// RiskScore is the score of the riskiest elevated command, 0 when no risk rules match
+	RiskScore int
+	// Extra are the fields of the search result not mapped to the fields above, eg. custom search fields
+	Extra map[string]string
+
+	// SearchID is the search ID of the alert the event was received in, empty until the alert is processed
+	SearchID string
+}
+
+// Enrich adds a field of context to the alert
+func (a *AlertDetails) Enrich(field, value string) {
+	if a.Enrichment == nil {
+		a.Enrichment = map[string]string{}
+	}
+	a.Enrichment[field] = value
 }
 
 // AlertDetails.Valid checks whether an alert has all the necessary fields for a compliance ticket
@@ -53,22 +77,131 @@ func (a AlertDetails) Body() string {
 	s.WriteString("\n\n")
 	s.WriteString(a.ReasonsText)
 
+	if a.RiskScore > 0 {
+		s.WriteString("\n\nRisk score: " + strconv.Itoa(a.RiskScore))
+	}
+
+	if len(a.Enrichment) > 0 {
+		s.WriteString("\n\n")
+		writeFields(&s, a.Enrichment)
+	}
+
+	if len(a.Extra) > 0 {
+		s.WriteString("\n\nRaw fields:\n")
+		writeFields(&s, a.Extra)
+	}
+
 	return s.String()
 }
 
+// ContentHash identifies the session of the compliance event by its content: the user, the clusters and the
+// elevated commands, regardless of their order and of the event's timestamp. Events of overlapping searches
+// for the same session have the same hash.
+func (a AlertDetails) ContentHash() string {
+	clusters := append([]string{}, a.ClusterIDs...)
+	sort.Strings(clusters)
+	commands := append([]string{}, a.ElevatedSummary...)
+	sort.Strings(commands)
+
+	sum := sha256.New()
+	for _, part := range [][]string{{strings.ToLower(a.User)}, clusters, commands} {
+		for _, value := range part {
+			sum.Write([]byte(value))
+			sum.Write([]byte{0})
+		}
+		// Separates the parts, so values can't move from one part to the next
+		sum.Write([]byte{1})
+	}
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// writeFields writes the fields as "name: value" lines, sorted by name
+func writeFields(s *strings.Builder, values map[string]string) {
+	fields := make([]string, 0, len(values))
+	for field := range values {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		s.WriteString(field + ": " + values[field] + "\n")
+	}
+}
+
+// MergeAlertDetails combines the compliance events of one session, eg. a user's elevations on a cluster,
+// into a single event with the earliest timestamp. Lists are combined without duplicates, and texts are joined.
+func MergeAlertDetails(details []AlertDetails) AlertDetails {
+	var merged AlertDetails
+	for i, d := range details {
+		if i == 0 {
+			merged.AlertName, merged.User, merged.Group, merged.ClusterText = d.AlertName, d.User, d.Group, d.ClusterText
+			merged.SearchID = d.SearchID
+		}
+		if merged.Timestamp.IsZero() || (!d.Timestamp.IsZero() && d.Timestamp.Before(merged.Timestamp)) {
+			merged.Timestamp = d.Timestamp
+		}
+
+		merged.ClusterIDs = appendUnique(merged.ClusterIDs, d.ClusterIDs...)
+		merged.ElevatedSummary = appendUnique(merged.ElevatedSummary, d.ElevatedSummary...)
+		merged.Reasons = appendUnique(merged.Reasons, d.Reasons...)
+		merged.ElevatedSummaryText = joinText(merged.ElevatedSummaryText, d.ElevatedSummaryText)
+		merged.ReasonsText = joinText(merged.ReasonsText, d.ReasonsText)
+
+		for field, value := range d.Extra {
+			if merged.Extra == nil {
+				merged.Extra = map[string]string{}
+			}
+			merged.Extra[field] = joinText(merged.Extra[field], value)
+		}
+
+		for field, value := range d.Enrichment {
+			merged.Enrich(field, value)
+		}
+	}
+	return merged
+}
+
+func appendUnique(values []string, more ...string) []string {
+	for _, v := range more {
+		if !slices.Contains(values, v) {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// joinText appends the text on a new line, unless it is empty or already included
+func joinText(text, more string) string {
+	switch {
+	case more == "" || strings.Contains(text, more):
+		return text
+	case text == "":
+		return more
+	default:
+		return text + "\n" + more
+	}
+}
+
+// mappedFields are the search result fields mapped to AlertDetails fields, or otherwise used by the router
+var mappedFields = []string{
+	"alertname", "username", "group", "timestamp", "_time", "clusterid", "cluster_text",
+	"elevated_summary", "elevated_summary_text", "reason", "reason_text", "tenant",
+}
+
 // NewAlertDetails creates a new AlertDetails from a SearchResult
 func NewAlertDetails(result SearchResult) AlertDetails {
 	return AlertDetails{
 		AlertName:           result.string("alertname"),
 		User:                result.string("username"),
 		Group:               result.string("group"),
-		Timestamp:           result.time("timestamp"),
+		Timestamp:           result.time("timestamp", "_time"),
 		ClusterIDs:          result.slice("clusterid"),
 		ClusterText:         result.string("cluster_text"),
 		ElevatedSummary:     result.slice("elevated_summary"),
 		ElevatedSummaryText: result.string("elevated_summary_text"),
 		Reasons:             result.slice("reason"),
 		ReasonsText:         result.string("reason_text"),
+		Extra:               result.extra(mappedFields),
 	}
 }
 
@@ -224,3 +224,95 @@ func TestAlertDetails_Valid(t *testing.T) {
 		})
 	}
 }
+
+func TestMergeAlertDetails(t *testing.T) {
+	first, _ := time.Parse(time.RFC3339, "2021-01-01T00:05:00Z")
+	earlier, _ := time.Parse(time.RFC3339, "2021-01-01T00:01:00Z")
+
+	got := MergeAlertDetails([]AlertDetails{
+		{
+			AlertName:           "elevation",
+			User:                "sre",
+			Group:               "sre-group",
+			Timestamp:           first,
+			ClusterIDs:          []string{"abc"},
+			ClusterText:         "abc",
+			ElevatedSummary:     []string{"get pods"},
+			ElevatedSummaryText: "get pods",
+			Reasons:             []string{"OHSS-1"},
+			ReasonsText:         "OHSS-1",
+		},
+		{
+			AlertName:           "elevation",
+			User:                "sre",
+			Group:               "sre-group",
+			Timestamp:           earlier,
+			ClusterIDs:          []string{"abc"},
+			ClusterText:         "abc",
+			ElevatedSummary:     []string{"delete pod"},
+			ElevatedSummaryText: "delete pod",
+			Reasons:             []string{"OHSS-1"},
+			ReasonsText:         "OHSS-1",
+		},
+	})
+
+	want := AlertDetails{
+		AlertName:           "elevation",
+		User:                "sre",
+		Group:               "sre-group",
+		Timestamp:           earlier,
+		ClusterIDs:          []string{"abc"},
+		ClusterText:         "abc",
+		ElevatedSummary:     []string{"get pods", "delete pod"},
+		ElevatedSummaryText: "get pods\ndelete pod",
+		Reasons:             []string{"OHSS-1"},
+		ReasonsText:         "OHSS-1",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("MergeAlertDetails() = %+v, want %+v", got, want)
+	}
+}
+
+func TestAlertDetails_ContentHash(t *testing.T) {
+	event := AlertDetails{
+		User:            "sre",
+		Timestamp:       time.Date(2021, 1, 1, 0, 5, 0, 0, time.UTC),
+		ClusterIDs:      []string{"abc", "def"},
+		ElevatedSummary: []string{"get pods", "delete pod"},
+	}
+
+	tests := []struct {
+		name  string
+		event AlertDetails
+		want  bool
+	}{
+		{"Same session found again", AlertDetails{User: "SRE", Timestamp: event.Timestamp.Add(time.Hour), ClusterIDs: []string{"def", "abc"}, ElevatedSummary: []string{"delete pod", "get pods"}}, true},
+		{"Other user", AlertDetails{User: "other", ClusterIDs: event.ClusterIDs, ElevatedSummary: event.ElevatedSummary}, false},
+		{"Other clusters", AlertDetails{User: "sre", ClusterIDs: []string{"abc"}, ElevatedSummary: event.ElevatedSummary}, false},
+		{"Other commands", AlertDetails{User: "sre", ClusterIDs: event.ClusterIDs, ElevatedSummary: []string{"get pods"}}, false},
+		{"Command moved to the clusters", AlertDetails{User: "sre", ClusterIDs: []string{"abc", "def", "get pods"}, ElevatedSummary: []string{"delete pod"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.event.ContentHash() == event.ContentHash(); got != tt.want {
+				t.Errorf("ContentHash() equal = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAlertDetails_Body(t *testing.T) {
+	a := AlertDetails{
+		AlertName:           "elevation",
+		User:                "sre",
+		ClusterText:         "abc",
+		ElevatedSummaryText: "get pods",
+		ReasonsText:         "OHSS-1",
+		Extra:               map[string]string{"namespace": "openshift-etcd", "count": "3"},
+	}
+
+	want := "sre - elevation\n\nabc\n\nget pods\n\nOHSS-1\n\nRaw fields:\ncount: 3\nnamespace: openshift-etcd\n"
+	if got := a.Body(); got != want {
+		t.Errorf("AlertDetails.Body() = %q, want %q", got, want)
+	}
+}
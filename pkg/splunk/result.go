@@ -17,11 +17,23 @@ package splunk
 import (
 	"fmt"
 	"log"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"golang.org/x/exp/slices"
 )
 
+// SplunkTimeFormat is the layout of timestamps in search results when no time formats are configured
 const SplunkTimeFormat = "2006-01-02T15:04:05.GMT"
 
+// unixTimeFormat parses timestamps as seconds, or milliseconds, since the epoch, with an optional fraction
+const unixTimeFormat = "unix"
+
+// maxEpochSeconds is the largest epoch timestamp taken as seconds; larger ones are taken as milliseconds
+const maxEpochSeconds = 1e11
+
 func (a SearchResult) string(field string) string {
 	if i, ok := a[field]; !ok {
 		log.Printf("No such field: %s", field)
@@ -52,9 +64,56 @@ func (a SearchResult) slice(field string) []string {
 	}
 }
 
-func (a SearchResult) time(field string) time.Time {
+// extra returns the fields of the result other than the mapped fields and Splunk's internal fields, whose
+// names start with an underscore, eg. _raw, or nil when there are none. Lists are joined with commas.
+func (a SearchResult) extra(mapped []string) map[string]string {
+	var fields map[string]string
+	for field, value := range a {
+		if strings.HasPrefix(field, "_") || slices.Contains(mapped, field) {
+			continue
+		}
+		if fields == nil {
+			fields = map[string]string{}
+		}
+		switch v := value.(type) {
+		case []string:
+			fields[field] = strings.Join(v, ", ")
+		case []interface{}:
+			values := make([]string, 0, len(v))
+			for _, e := range v {
+				values = append(values, fmt.Sprint(e))
+			}
+			fields[field] = strings.Join(values, ", ")
+		default:
+			fields[field] = fmt.Sprint(v)
+		}
+	}
+	return fields
+}
+
+// time parses the timestamp in the first of the fields the result has. Numbers are taken as epoch
+// timestamps; strings are parsed with the configured time formats, falling back to RFC 3339,
+// as in Splunk's _time field, and epoch timestamps.
+func (a SearchResult) time(fields ...string) time.Time {
+	field := fields[0]
+	for _, f := range fields {
+		if _, ok := a[f]; ok {
+			field = f
+			break
+		}
+	}
+
+	switch v := a[field].(type) {
+	case float64:
+		return epochTime(v)
+	case int:
+		return epochTime(float64(v))
+	case int64:
+		return epochTime(float64(v))
+	}
+
 	if s := a.string(field); s != "" {
-		if t, err := time.Parse(SplunkTimeFormat, s); err == nil {
+		if t, err := parseTime(s, timeFormats()); err == nil {
 			return t
 		} else {
 			log.Printf("Error parsing timestamp: %v", err)
@@ -62,3 +121,39 @@ func (a SearchResult) time(field string) time.Time {
 	}
 	return time.Time{}
 }
+
+// timeFormats returns the configured layouts of timestamps in search results, or the default layout
+func timeFormats() []string {
+	if formats := config.AppConfig().SplunkConfig.TimeFormats; len(formats) > 0 {
+		return formats
+	}
+	return []string{SplunkTimeFormat}
+}
+
+// parseTime parses the timestamp with the first of the layouts that matches it, then as RFC 3339
+// and finally as an epoch timestamp
+func parseTime(s string, layouts []string) (time.Time, error) {
+	candidates := append(append([]string{}, layouts...), time.RFC3339, unixTimeFormat)
+	for _, layout := range candidates {
+		if layout == unixTimeFormat {
+			if epoch, err := strconv.ParseFloat(s, 64); err == nil {
+				return epochTime(epoch), nil
+			}
+			continue
+		}
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q does not match any of the time formats %s", s, strings.Join(layouts, ", "))
+}
+
+// epochTime returns the time of an epoch timestamp in seconds, or in milliseconds when too large
+// to be seconds
+func epochTime(epoch float64) time.Time {
+	unit := float64(time.Second)
+	if epoch > maxEpochSeconds || epoch < -maxEpochSeconds {
+		unit = float64(time.Millisecond)
+	}
+	return time.Unix(0, int64(epoch*unit)).UTC()
+}
@@ -308,7 +308,7 @@ func TestSearchResult_time(t *testing.T) {
 				field: "alertname",
 			},
 			wantTime: time.Time{},
-			wantLog:  "Error parsing timestamp: parsing time \"testAlertname\" as \"2006-01-02T15:04:05.GMT\": cannot parse \"testAlertname\" as \"2006\"\n",
+			wantLog:  "Error parsing timestamp: \"testAlertname\" does not match any of the time formats 2006-01-02T15:04:05.GMT\n",
 		},
 	}
 	for _, tt := range tests {
@@ -330,3 +330,73 @@ func TestSearchResult_time(t *testing.T) {
 		})
 	}
 }
+
+func TestParseTime(t *testing.T) {
+	layouts := []string{"2006-01-02 15:04", "unix", SplunkTimeFormat}
+
+	tests := []struct {
+		name    string
+		s       string
+		want    time.Time
+		wantErr bool
+	}{
+		{"First layout", "2021-01-01 00:00", time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), false},
+		{"Unix seconds", "1609459200.5", time.Date(2021, 1, 1, 0, 0, 0, 500000000, time.UTC), false},
+		{"Unix milliseconds", "1609459200500", time.Date(2021, 1, 1, 0, 0, 0, 500000000, time.UTC), false},
+		{"Fallback layout", "2021-01-01T00:00:00.GMT", time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), false},
+		{"Splunk _time", "2021-01-01T01:00:00.000+01:00", time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), false},
+		{"No matching layout", "yesterday", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTime(tt.s, layouts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTime() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("parseTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchResult_timeEpoch(t *testing.T) {
+	want := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		a    SearchResult
+	}{
+		{"Epoch seconds", SearchResult{"timestamp": float64(1609459200)}},
+		{"Epoch milliseconds", SearchResult{"timestamp": float64(1609459200000)}},
+		{"Epoch seconds string", SearchResult{"timestamp": "1609459200"}},
+		{"Splunk _time", SearchResult{"_time": "1609459200.000"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.a.time("timestamp", "_time"); !got.Equal(want) {
+				t.Errorf("SearchResult.time() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestSearchResult_extra(t *testing.T) {
+	a := SearchResult{
+		"alertname":  "testAlertname",
+		"username":   "testUsername",
+		"namespace":  "openshift-etcd",
+		"ticket_ids": []interface{}{"OHSS-1", float64(2)},
+		"count":      float64(3),
+		"_raw":       "raw event",
+	}
+
+	want := map[string]string{"namespace": "openshift-etcd", "ticket_ids": "OHSS-1, 2", "count": "3"}
+	if got := a.extra(mappedFields); !reflect.DeepEqual(got, want) {
+		t.Errorf("SearchResult.extra() = %v, want %v", got, want)
+	}
+
+	if got := (SearchResult{"alertname": "testAlertname", "_time": "1609459200"}).extra(mappedFields); got != nil {
+		t.Errorf("SearchResult.extra() = %v without unmapped fields, want nil", got)
+	}
+}
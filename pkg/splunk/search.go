@@ -0,0 +1,139 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package splunk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/helpers"
+	"github.com/openshift/compliance-audit-router/pkg/logging"
+)
+
+// searchJob is the JSON structure of a created Splunk search job
+type searchJob struct {
+	Sid string `json:"sid"`
+}
+
+// Search runs the search over the events from earliest, inclusive, to latest, exclusive, and calls fn with
+// each page of at most pageSize results in order, or with all the results when pageSize is 0. It stops at the
+// first error of fn. The search is cancelled with the context.
+func (s Server) Search(ctx context.Context, search string, earliest, latest time.Time, pageSize int, fn func(Alert) error) error {
+	sid, err := s.createSearchJob(ctx, search, earliest, latest)
+	if err != nil {
+		return err
+	}
+	log.Printf("created Splunk search job %s for %s to %s", sid, earliest.Format(time.RFC3339), latest.Format(time.RFC3339))
+
+	for offset := 0; ; offset += pageSize {
+		page, err := s.searchResults(ctx, sid, offset, pageSize)
+		if err != nil {
+			return err
+		}
+		if len(page.SearchResults.Results) == 0 {
+			return nil
+		}
+		if err := fn(page); err != nil {
+			return err
+		}
+		if pageSize == 0 || len(page.SearchResults.Results) < pageSize {
+			return nil
+		}
+	}
+}
+
+// createSearchJob creates a search job over the time range, waiting for the search to complete,
+// and returns its search ID
+func (s Server) createSearchJob(ctx context.Context, search string, earliest, latest time.Time) (string, error) {
+	// Searches not starting with a command are run with the search command, like in the Splunk UI
+	search = strings.TrimSpace(search)
+	if !strings.HasPrefix(search, "search ") && !strings.HasPrefix(search, "|") {
+		search = "search " + search
+	}
+
+	form := url.Values{
+		"search":        {search},
+		"earliest_time": {strconv.FormatInt(earliest.Unix(), 10)},
+		"latest_time":   {strconv.FormatInt(latest.Unix(), 10)},
+		"exec_mode":     {"blocking"},
+		"output_mode":   {"json"},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/services/search/v2/jobs", s.Host), strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", s.Token))
+
+	logging.Debugf(logging.Splunk, "splunk.createSearchJob(): httpRequest: %s", helpers.RedactRequest(req, config.AppConfig().RedactFields))
+
+	resp, err := s.httpClient().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error creating search job in Splunk: %s", resp.Status)
+	}
+
+	var job searchJob
+	if err := helpers.DecodeJSONResponseBody(resp, &job); err != nil {
+		return "", err
+	}
+	if job.Sid == "" {
+		return "", errors.New("search job created in Splunk has no sid")
+	}
+	return job.Sid, nil
+}
+
+// searchResults retrieves a page of the results of the search job, from the offset
+func (s Server) searchResults(ctx context.Context, sid string, offset, count int) (Alert, error) {
+	alert := Alert{SearchID: sid}
+
+	query := url.Values{
+		"output_mode": {"json"},
+		"offset":      {strconv.Itoa(offset)},
+		"count":       {strconv.Itoa(count)},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/services/search/v2/jobs/%s/results?%s", s.Host, sid, query.Encode()), http.NoBody)
+	if err != nil {
+		return alert, err
+	}
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", s.Token))
+
+	logging.Debugf(logging.Splunk, "splunk.searchResults(): httpRequest: %s", helpers.RedactRequest(req, config.AppConfig().RedactFields))
+
+	resp, err := s.httpClient().Do(req)
+	if err != nil {
+		return alert, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return alert, fmt.Errorf("error retrieving search results from Splunk: %s", resp.Status)
+	}
+
+	alert.SearchResults, err = decodeSearchResults(resp.Body, s.MaxResultsSize)
+	return alert, err
+}
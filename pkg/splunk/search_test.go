@@ -0,0 +1,102 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package splunk
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+)
+
+func TestSplunkServer_Search(t *testing.T) {
+	var results []SearchResult
+	for i := 0; i < 5; i++ {
+		results = append(results, SearchResult{"alertname": "TestAlert", "username": "user" + strconv.Itoa(i)})
+	}
+	earliest := time.Date(2024, 1, 30, 0, 0, 0, 0, time.UTC)
+	latest := earliest.Add(24 * time.Hour)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method + " " + r.URL.Path {
+		case "POST /services/search/v2/jobs":
+			if r.FormValue("search") != "search index=audit" || r.FormValue("earliest_time") != strconv.FormatInt(earliest.Unix(), 10) ||
+				r.FormValue("latest_time") != strconv.FormatInt(latest.Unix(), 10) || r.FormValue("exec_mode") != "blocking" {
+				t.Errorf("Search() created search job with %v", r.Form)
+			}
+			w.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(w).Encode(searchJob{Sid: "backfill-1"})
+		case "GET /services/search/v2/jobs/backfill-1/results":
+			offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+			count, _ := strconv.Atoi(r.URL.Query().Get("count"))
+			end := offset + count
+			if count == 0 || end > len(results) {
+				end = len(results)
+			}
+			if offset > len(results) {
+				offset = len(results)
+			}
+			_ = json.NewEncoder(w).Encode(SearchResults{InitOffset: offset, Results: results[offset:end]})
+		default:
+			t.Errorf("Search() made unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	splunkServer := Server(config.SplunkConfig{Token: "test", Host: server.URL})
+
+	tests := []struct {
+		name     string
+		pageSize int
+		want     []int
+	}{
+		{"Paginated results", 2, []int{2, 2, 1}},
+		{"Page size dividing the results", 5, []int{5}},
+		{"All results at once", 0, []int{5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var pages []int
+			var got []SearchResult
+			err := splunkServer.Search(context.Background(), "index=audit", earliest, latest, tt.pageSize, func(page Alert) error {
+				if page.SearchID != "backfill-1" {
+					t.Errorf("Search() page SearchID = %v, want backfill-1", page.SearchID)
+				}
+				pages = append(pages, len(page.SearchResults.Results))
+				got = append(got, page.SearchResults.Results...)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Search() unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(pages, tt.want) {
+				t.Errorf("Search() pages = %v, want %v", pages, tt.want)
+			}
+			if !reflect.DeepEqual(got, results) {
+				t.Errorf("Search() results = %v, want %v", got, results)
+			}
+		})
+	}
+}
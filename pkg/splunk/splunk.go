@@ -17,13 +17,14 @@ limitations under the License.
 package splunk
 
 import (
-	"crypto/tls"
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 
 	"github.com/openshift/compliance-audit-router/pkg/config"
 	"github.com/openshift/compliance-audit-router/pkg/helpers"
+	"github.com/openshift/compliance-audit-router/pkg/logging"
 )
 
 // Webhook is the JSON structure for a Splunk webhook
@@ -59,16 +60,10 @@ type Server config.SplunkConfig
 // NOTE: The webhook itself contains the search result. So this may not be necessary
 
 // RetrieveSearchFromAlert parses the received webhook, and looks up the data for the alert in Splunk,
-// and returns the information in an Alert struct
-func (s Server) RetrieveSearchFromAlert(sid string) (Alert, error) {
-
-	splunkHttpClient := &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: s.AllowInsecure,
-			},
-		},
-	}
+// and returns the information in an Alert struct. The request is cancelled with the context.
+func (s Server) RetrieveSearchFromAlert(ctx context.Context, sid string) (Alert, error) {
+
+	splunkHttpClient := s.httpClient()
 
 	url := fmt.Sprintf("%s/services/search/v2/jobs/%s/results?output_mode=json", s.Host, sid)
 
@@ -78,38 +73,33 @@ func (s Server) RetrieveSearchFromAlert(sid string) (Alert, error) {
 	}
 
 	// Create a new HTTP client; don't modify the default client
-	req, err := http.NewRequest(http.MethodGet, url, http.NoBody)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
 	if err != nil {
 		return alert, err
 	}
 
-	if config.AppConfig.Verbose {
-		log.Printf("splunk.RetrieveSearchFromAlert(): splunkHttpClient: %+v", splunkHttpClient)
-		log.Printf("splunk.RetrieveSearchFromAlert(): url: %+v", url)
-		log.Printf("splunk.RetrieveSearchFromAlert(): httpRequest: %+v", req)
-	}
+	logging.Debugf(logging.Splunk, "splunk.RetrieveSearchFromAlert(): splunkHttpClient: %+v", splunkHttpClient)
+	logging.Debugf(logging.Splunk, "splunk.RetrieveSearchFromAlert(): url: %+v", url)
+	logging.Debugf(logging.Splunk, "splunk.RetrieveSearchFromAlert(): httpRequest: %s", helpers.RedactRequest(req, config.AppConfig().RedactFields))
 
 	bearerToken := fmt.Sprintf("Bearer %s", s.Token)
 	req.Header.Add("Authorization", bearerToken)
 
-	if config.AppConfig.Verbose {
-		log.Print("splunk.RetrieveSearchFromAlert(): using bearer token authorization: TOKEN REDACTED")
-	}
+	logging.Debugf(logging.Splunk, "splunk.RetrieveSearchFromAlert(): using bearer token authorization: TOKEN REDACTED")
 
 	resp, err := splunkHttpClient.Do(req)
 	if err != nil {
 		return alert, err
 	}
+	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
 		return alert, fmt.Errorf("error retrieving search results from Splunk: %s", resp.Status)
 	}
 
-	if config.AppConfig.Verbose {
-		log.Printf("splunk.RetrieveSearchFromAlert(): response from Splunk server: %+v", resp)
-	}
+	logging.Debugf(logging.Splunk, "splunk.RetrieveSearchFromAlert(): response from Splunk server: %s", helpers.RedactResponse(resp, config.AppConfig().RedactFields))
 
 	// Process the response
-	err = helpers.DecodeJSONResponseBody(resp, &alert.SearchResults)
+	alert.SearchResults, err = decodeSearchResults(resp.Body, s.MaxResultsSize)
 	if err != nil {
 		return alert, err
 	}
@@ -118,3 +108,33 @@ func (s Server) RetrieveSearchFromAlert(sid string) (Alert, error) {
 	return alert, err
 
 }
+
+// CheckConnection lists a search job, checking that the Splunk API is reachable and accepts the token
+func (s Server) CheckConnection() error {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/services/search/v2/jobs?count=1&output_mode=json", s.Host), http.NoBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", s.Token))
+
+	resp, err := s.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error listing search jobs: %s", resp.Status)
+	}
+	return nil
+}
+
+// httpClient returns an HTTP client for the Splunk API, recording the requests' metrics. Its requests
+// reuse the pooled connections of the shared transport; the default client is not modified.
+func (s Server) httpClient() *http.Client {
+	return &http.Client{
+		Transport: &metricsTransport{
+			transport: helpers.SharedTransport(s.AllowInsecure),
+		},
+	}
+}
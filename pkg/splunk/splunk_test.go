@@ -17,6 +17,8 @@ limitations under the License.
 package splunk
 
 import (
+	"context"
+	"errors"
 	"io"
 	"log"
 	"net/http"
@@ -27,6 +29,8 @@ import (
 	"time"
 
 	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 const TEST_SEARCH_API_RESPONSE string = `{"preview":false,"init_offset":0,"messages":[],"fields":[{"name":"_time"},{"name":"alertname","type":"str"},{"name":"clusterid","type":"str"},{"name":"group","type":"str"},{"name":"timestamp","type":"str"},{"name":"username","type":"str"}],"results":[{"_time":"2023-08-27T02:25:00.000+10:00","alertname":"TestAlert","clusterid":"testcluster","group":"testgroup","timestamp":"2023-08-27T02:25:01.GMT","username":"testuser"}], "highlighted":{}}`
@@ -86,7 +90,7 @@ func TestSplunkServer_RetrieveSearchFromAlert(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			defer server.Close()
-			got, err := tt.splunkserver.RetrieveSearchFromAlert(tt.args.sid)
+			got, err := tt.splunkserver.RetrieveSearchFromAlert(context.Background(), tt.args.sid)
 			log.Println(got.Details())
 			if (err != nil) != tt.wantErr {
 				t.Errorf("%s\n\tgot:\n%+v\n\twant error:\n%+v", tt.name, err, tt.wantErr)
@@ -98,3 +102,55 @@ func TestSplunkServer_RetrieveSearchFromAlert(t *testing.T) {
 		})
 	}
 }
+
+func TestSplunkServer_RetrieveSearchFromAlertDeadline(t *testing.T) {
+	// The server never responds, like a Splunk search head that is stuck
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := Server(config.SplunkConfig{Host: server.URL, Token: "test"}).RetrieveSearchFromAlert(ctx, "test")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("RetrieveSearchFromAlert() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestSplunkServer_CheckConnection(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/services/search/v2/jobs" || r.Header.Get("Authorization") != "Bearer test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"entry":[]}`))
+	}))
+	defer server.Close()
+
+	if err := Server(config.SplunkConfig{Host: server.URL, Token: "test"}).CheckConnection(); err != nil {
+		t.Errorf("CheckConnection() unexpected error: %v", err)
+	}
+	if err := Server(config.SplunkConfig{Host: server.URL, Token: "wrong"}).CheckConnection(); err == nil {
+		t.Errorf("CheckConnection() expected an error for a rejected token")
+	}
+}
+
+func TestSplunkServer_RequestMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	unavailable := metrics.MetricSplunkRequests.With(map[string]string{"status_class": "5xx"})
+	before := testutil.ToFloat64(unavailable)
+
+	if err := Server(config.SplunkConfig{Host: server.URL, Token: "test"}).CheckConnection(); err == nil {
+		t.Errorf("CheckConnection() expected an error for an unavailable server")
+	}
+	if got := testutil.ToFloat64(unavailable) - before; got != 1 {
+		t.Errorf("requests counted with status class 5xx = %v, want 1", got)
+	}
+}
@@ -0,0 +1,153 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package splunk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// errResultsTooLarge is returned when a search results response is larger than splunkconfig.maxresultssize
+var errResultsTooLarge = errors.New("search results response too large")
+
+// cappedReader reads up to max bytes of the reader, failing with errResultsTooLarge when there are more
+type cappedReader struct {
+	r         io.Reader
+	max       int64
+	remaining int64
+}
+
+func (c *cappedReader) Read(p []byte) (int, error) {
+	if c.remaining <= 0 {
+		// Reading exactly max bytes is fine, as long as nothing follows
+		var b [1]byte
+		if n, err := c.r.Read(b[:]); n == 0 && err != nil {
+			return 0, err
+		}
+		return 0, fmt.Errorf("%w: more than %d bytes", errResultsTooLarge, c.max)
+	}
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.r.Read(p)
+	c.remaining -= int64(n)
+	return n, err
+}
+
+// decodeSearchResults decodes the response of a Splunk API */results call as it is read, one result at a time,
+// so only the decoded results are held in memory rather than also the whole response. Reading more than maxSize
+// bytes fails, unless maxSize is 0.
+func decodeSearchResults(r io.Reader, maxSize int64) (SearchResults, error) {
+	var results SearchResults
+
+	if maxSize > 0 {
+		r = &cappedReader{r: r, max: maxSize, remaining: maxSize}
+	}
+	dec := json.NewDecoder(r)
+
+	if err := expectDelim(dec, '{'); err != nil {
+		return results, err
+	}
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return results, decodeError(err)
+		}
+
+		var field interface{}
+		switch token {
+		case "results":
+			if err := decodeResults(dec, &results); err != nil {
+				return results, err
+			}
+			continue
+		case "init_offset":
+			field = &results.InitOffset
+		case "messages":
+			field = &results.Messages
+		case "preview":
+			field = &results.Preview
+		case "highlighted":
+			field = &results.Highlighted
+		default:
+			field = &json.RawMessage{}
+		}
+		if err := dec.Decode(field); err != nil {
+			return results, decodeError(err)
+		}
+	}
+	if err := expectDelim(dec, '}'); err != nil {
+		return results, err
+	}
+
+	if _, err := dec.Token(); err != io.EOF {
+		if errors.Is(err, errResultsTooLarge) {
+			return results, err
+		}
+		return results, errors.New("response body must only contain a single JSON object")
+	}
+	return results, nil
+}
+
+// decodeResults decodes the results array one result at a time
+func decodeResults(dec *json.Decoder, results *SearchResults) error {
+	token, err := dec.Token()
+	if err != nil {
+		return decodeError(err)
+	}
+	if token == nil {
+		return nil
+	}
+	if token != json.Delim('[') {
+		return fmt.Errorf("search results must be an array, not %v", token)
+	}
+
+	for dec.More() {
+		var result SearchResult
+		if err := dec.Decode(&result); err != nil {
+			return decodeError(err)
+		}
+		results.Results = append(results.Results, result)
+	}
+	return expectDelim(dec, ']')
+}
+
+// expectDelim reads the next token, failing unless it is the delimiter
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return decodeError(err)
+	}
+	if token != delim {
+		return fmt.Errorf("response body contains badly-formed JSON: expected %v, found %v", delim, token)
+	}
+	return nil
+}
+
+// decodeError describes a failure to decode the response like helpers.DecodeJSONResponseBody does
+func decodeError(err error) error {
+	switch {
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return errors.New("response body contains badly-formed JSON")
+	case errors.Is(err, io.EOF):
+		return errors.New("response body must not be empty")
+	default:
+		return err
+	}
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package splunk
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDecodeSearchResults(t *testing.T) {
+	body := `{"preview":false,"init_offset":0,"messages":[],"fields":[{"name":"user"}],"results":[{"user":"alice"},{"user":"bob","clusterid":["abc"]}],"highlighted":{}}`
+
+	results, err := decodeSearchResults(strings.NewReader(body), 0)
+	if err != nil {
+		t.Fatalf("decodeSearchResults() error = %v", err)
+	}
+	if len(results.Results) != 2 || results.Results[0]["user"] != "alice" || results.Results[1]["user"] != "bob" {
+		t.Errorf("decodeSearchResults() = %+v, want the results of alice and bob", results)
+	}
+
+	// A response of exactly the maximum size is read
+	if _, err := decodeSearchResults(strings.NewReader(body), int64(len(body))); err != nil {
+		t.Errorf("decodeSearchResults() of the maximum size error = %v", err)
+	}
+	if _, err := decodeSearchResults(strings.NewReader(body), int64(len(body)-1)); !errors.Is(err, errResultsTooLarge) {
+		t.Errorf("decodeSearchResults() of a larger response error = %v, want %v", err, errResultsTooLarge)
+	}
+}
+
+func TestDecodeSearchResultsInvalid(t *testing.T) {
+	tests := map[string]string{
+		"empty":     "",
+		"truncated": `{"results":[{"user":"alice"}`,
+		"not JSON":  `<html></html>`,
+		"trailing":  `{"results":[]}{}`,
+		"no array":  `{"results":{"user":"alice"}}`,
+	}
+	for name, body := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := decodeSearchResults(strings.NewReader(body), 0); err == nil {
+				t.Errorf("decodeSearchResults(%q) error = nil", body)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package splunk
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/openshift/compliance-audit-router/pkg/health"
+	"github.com/openshift/compliance-audit-router/pkg/metrics"
+)
+
+// metricsTransport records the duration and the status class of the requests sent to the Splunk API
+// through the wrapped transport, to tell Splunk's slowness apart from the router's
+type metricsTransport struct {
+	transport http.RoundTripper
+}
+
+// RoundTrip implements the http.RoundTripper interface
+func (t *metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.transport.RoundTrip(req)
+	metrics.MetricSplunkRequestDuration.Observe(time.Since(start).Seconds())
+
+	metrics.MetricSplunkRequests.With(map[string]string{"status_class": statusClass(resp, err)}).Inc()
+
+	// Requests cancelled by the caller tell nothing of Splunk's health; client errors mean it is reachable
+	switch {
+	case err != nil && req.Context().Err() == nil:
+		health.Record(health.Splunk, err)
+	case err == nil && resp.StatusCode >= http.StatusInternalServerError:
+		health.Record(health.Splunk, fmt.Errorf("request to %s failed: %s", req.URL.Host, resp.Status))
+	case err == nil:
+		health.Record(health.Splunk, nil)
+	}
+	return resp, err
+}
+
+// statusClass returns the class of the response's status code, eg. "2xx", or "error" when no response was received
+func statusClass(resp *http.Response, err error) string {
+	if err != nil || resp == nil {
+		return "error"
+	}
+	return fmt.Sprintf("%dxx", resp.StatusCode/100)
+}
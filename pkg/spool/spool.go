@@ -0,0 +1,168 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package spool persists the alerts accepted while Jira is unavailable to a directory, one file per alert,
+// so they are processed once Jira recovers, even after the router restarts, rather than lost.
+package spool
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/helpers"
+	"github.com/openshift/compliance-audit-router/pkg/metrics"
+	"github.com/openshift/compliance-audit-router/pkg/splunk"
+)
+
+// entryExt is the extension of the spooled alert files; files being written have another
+const entryExt = ".json"
+
+// Entry is a spooled alert: its search results and the tenant it is processed for
+type Entry struct {
+	Spooled time.Time    `json:"spooled"`
+	Tenant  string       `json:"tenant,omitempty"`
+	Alert   splunk.Alert `json:"alert"`
+	// Events are the compliance events of the alert left to process, when some of its events were processed
+	// before it was spooled; otherwise the whole alert is processed
+	Events []splunk.AlertDetails `json:"events,omitempty"`
+}
+
+// Spool is a directory of spooled alerts, processed in the order they were spooled
+type Spool struct {
+	dir string
+}
+
+// Open opens the spool directory, creating it if needed
+func Open(dir string) (*Spool, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+	s := &Spool{dir: dir}
+	names, err := s.Names()
+	if err != nil {
+		return nil, err
+	}
+	metrics.MetricSpoolDepth.Set(float64(len(names)))
+	return s, nil
+}
+
+// Add spools the entry. The file is written atomically, so a crash doesn't leave a partial entry.
+func (s *Spool) Add(entry Entry) error {
+	// Names sort in the order the alerts were spooled, and the random suffix keeps alerts spooled
+	// at the same time apart
+	name := fmt.Sprintf("%020d-%08x%s", entry.Spooled.UnixNano(), rand.Uint32(), entryExt)
+	if err := s.write(name, entry); err != nil {
+		return err
+	}
+	metrics.MetricSpoolDepth.Inc()
+	return nil
+}
+
+// Replace replaces the named entry, eg. with the events of the alert left to process once some were processed,
+// keeping its place in the spool
+func (s *Spool) Replace(name string, entry Entry) error {
+	return s.write(name, entry)
+}
+
+// write writes the entry atomically to the named file of the spool
+func (s *Spool) write(name string, entry Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if err := helpers.WriteFileAtomic(filepath.Join(s.dir, name), data); err != nil {
+		return fmt.Errorf("failed to spool alert: %w", err)
+	}
+	return nil
+}
+
+// Names returns the names of the spooled entries, in the order they were spooled
+func (s *Spool) Names() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spool directory: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") && filepath.Ext(entry.Name()) == entryExt {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// SpooledAt returns when the named entry was spooled, from its name, so it is known even when the entry can't be read
+func SpooledAt(name string) (time.Time, bool) {
+	prefix, _, found := strings.Cut(name, "-")
+	if !found {
+		return time.Time{}, false
+	}
+	nanos, err := strconv.ParseInt(prefix, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, nanos).UTC(), true
+}
+
+// Read reads the named entry
+func (s *Spool) Read(name string) (Entry, error) {
+	var entry Entry
+	data, err := os.ReadFile(filepath.Join(s.dir, name))
+	if err != nil {
+		return entry, err
+	}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return entry, fmt.Errorf("spooled alert %s is not valid JSON: %w", name, err)
+	}
+	return entry, nil
+}
+
+// Remove removes the named entry once it has been processed
+func (s *Spool) Remove(name string) error {
+	if err := os.Remove(filepath.Join(s.dir, name)); err != nil {
+		return err
+	}
+	metrics.MetricSpoolDepth.Dec()
+	return nil
+}
+
+var (
+	defaultSpoolOnce sync.Once
+	defaultSpool     *Spool
+	defaultSpoolErr  error
+)
+
+// Default returns the spool of spoolconfig.dir, or nil when spooling is disabled
+func Default() (*Spool, error) {
+	defaultSpoolOnce.Do(func() {
+		if dir := config.AppConfig().SpoolConfig.Dir; dir != "" {
+			defaultSpool, defaultSpoolErr = Open(dir)
+		}
+	})
+	return defaultSpool, defaultSpoolErr
+}
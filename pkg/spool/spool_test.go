@@ -0,0 +1,130 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spool
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/openshift/compliance-audit-router/pkg/splunk"
+)
+
+func TestSpool(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "spool")
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	spooled := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i, sid := range []string{"second", "first"} {
+		// The first alert is spooled earlier, though added later
+		entry := Entry{Spooled: spooled.Add(-time.Duration(i) * time.Minute), Tenant: "security", Alert: splunk.Alert{SearchID: sid}}
+		if err := s.Add(entry); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+	// Files being written are not entries
+	if err := os.WriteFile(filepath.Join(dir, ".spool-partial"), []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The entries survive reopening the spool, eg. after a restart
+	s, err = Open(dir)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	names, err := s.Names()
+	if err != nil || len(names) != 2 {
+		t.Fatalf("Names() = %v, %v, want 2 entries", names, err)
+	}
+
+	for _, want := range []string{"first", "second"} {
+		entry, err := s.Read(names[0])
+		if err != nil {
+			t.Fatalf("Read() error = %v", err)
+		}
+		if entry.Alert.SearchID != want || entry.Tenant != "security" {
+			t.Errorf("Read() = %+v, want alert %v of tenant security", entry, want)
+		}
+		if err := s.Remove(names[0]); err != nil {
+			t.Fatalf("Remove() error = %v", err)
+		}
+		if names, err = s.Names(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(names) != 0 {
+		t.Errorf("Names() = %v after removing all entries", names)
+	}
+}
+
+func TestSpooledAt(t *testing.T) {
+	s, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	spooled := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	if err := s.Add(Entry{Spooled: spooled}); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	names, err := s.Names()
+	if err != nil || len(names) != 1 {
+		t.Fatalf("Names() = %v, %v, want 1 entry", names, err)
+	}
+
+	if got, ok := SpooledAt(names[0]); !ok || !got.Equal(spooled) {
+		t.Errorf("SpooledAt(%v) = %v, %v, want %v", names[0], got, ok, spooled)
+	}
+	if got, ok := SpooledAt("operator-notes.json"); ok {
+		t.Errorf("SpooledAt() = %v for a name without a spool time", got)
+	}
+}
+
+func TestSpoolReplace(t *testing.T) {
+	s, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	entry := Entry{Spooled: time.Now().UTC(), Alert: splunk.Alert{SearchID: "partial"}}
+	if err := s.Add(entry); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	names, err := s.Names()
+	if err != nil || len(names) != 1 {
+		t.Fatalf("Names() = %v, %v, want 1 entry", names, err)
+	}
+
+	// Once some of the events are processed, only the others are left in the entry, keeping its place
+	entry.Events = []splunk.AlertDetails{{User: "left"}}
+	if err := s.Replace(names[0], entry); err != nil {
+		t.Fatalf("Replace() error = %v", err)
+	}
+	replaced, err := s.Names()
+	if err != nil || len(replaced) != 1 || replaced[0] != names[0] {
+		t.Fatalf("Names() = %v, %v, want %v", replaced, err, names)
+	}
+	read, err := s.Read(names[0])
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if len(read.Events) != 1 || read.Events[0].User != "left" {
+		t.Errorf("Read() events = %+v, want the event left to process", read.Events)
+	}
+}
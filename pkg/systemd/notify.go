@@ -0,0 +1,99 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package systemd notifies systemd of the router's readiness and sends watchdog keepalives, for deployments
+// run as a Type=notify service. It does nothing when the router isn't started by systemd.
+package systemd
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Notification states, see sd_notify(3)
+const (
+	Ready    = "READY=1"
+	Stopping = "STOPPING=1"
+	Watchdog = "WATCHDOG=1"
+)
+
+// Notify sends the state to the systemd notification socket. It reports whether the state was sent,
+// which is false without an error when the process isn't run by a Type=notify service.
+func Notify(state string) (bool, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return false, nil
+	}
+
+	// Go maps a leading @ to the abstract socket namespace systemd uses
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return false, fmt.Errorf("failed connecting to the notification socket: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, fmt.Errorf("failed sending %q to the notification socket: %w", state, err)
+	}
+	return true, nil
+}
+
+// WatchdogInterval returns the interval systemd expects watchdog keepalives within, from WATCHDOG_USEC,
+// or 0 when the service has no watchdog or the watchdog is meant for another process
+func WatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// StartWatchdog sends a watchdog keepalive every half of the watchdog interval, until stop is closed, as long as
+// alive reports the router is responsive. systemd restarts the router when it misses the keepalives, eg. because it
+// hangs. It does nothing when the service has no watchdog.
+func StartWatchdog(alive func() bool, stop <-chan struct{}) {
+	interval := WatchdogInterval()
+	if interval == 0 {
+		return
+	}
+
+	log.Printf("sending systemd watchdog keepalives every %v", interval/2)
+	ticker := time.NewTicker(interval / 2)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if !alive() {
+					log.Printf("WARNING: skipping the systemd watchdog keepalive: the router isn't responding")
+					continue
+				}
+				if _, err := Notify(Watchdog); err != nil {
+					log.Printf("failed sending the systemd watchdog keepalive: %s", err.Error())
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testing
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/openshift/compliance-audit-router/pkg/identity"
+)
+
+// Identities is an in-memory identity provider, resolving the users added to it. Install it as the router's
+// identity provider with identity.SetDefault.
+type Identities struct {
+	mu     sync.Mutex
+	users  map[string]identity.Identity
+	groups map[string][]string
+}
+
+// NewIdentities returns an identity provider without users
+func NewIdentities() *Identities {
+	return &Identities{
+		users:  map[string]identity.Identity{},
+		groups: map[string][]string{},
+	}
+}
+
+// AddUser adds the user, with their manager, or none when empty, and the groups they are a member of
+func (i *Identities) AddUser(username, manager string, groups ...string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.users[username] = identity.Identity{Username: username, Manager: manager}
+	i.groups[username] = groups
+}
+
+// ResolveUser implements identity.Provider, failing for users not added
+func (i *Identities) ResolveUser(ctx context.Context, username string) (identity.Identity, error) {
+	if err := ctx.Err(); err != nil {
+		return identity.Identity{}, err
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	user, ok := i.users[username]
+	if !ok {
+		return identity.Identity{}, fmt.Errorf("user %s not found", username)
+	}
+	return user, nil
+}
+
+// IsGroupMember implements identity.GroupChecker. Users not added are members of no group.
+func (i *Identities) IsGroupMember(ctx context.Context, username, group string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return slices.Contains(i.groups[username], group), nil
+}
+
+// CheckHealth implements identity.HealthChecker; the provider is always healthy
+func (i *Identities) CheckHealth() error {
+	return nil
+}
@@ -0,0 +1,621 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testing
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/go-chi/chi/v5"
+	"github.com/openshift/compliance-audit-router/pkg/config"
+)
+
+const (
+	// JiraToken is the personal access token the fake Jira API accepts
+	JiraToken = "jira-token"
+	// JiraProject is the project of the fake Jira API's Config
+	JiraProject = "OHSS"
+	// JiraIssueType is the issue type of the fake Jira API's projects
+	JiraIssueType = "Task"
+	// JiraReporter is the username of the fake Jira API's authenticated user
+	JiraReporter = "compliance-audit-router"
+
+	// jiraTimeFormat is the format of the times of Jira issues
+	jiraTimeFormat = "2006-01-02T15:04:05.000-0700"
+)
+
+// JiraStatuses is the default workflow of the fake Jira API's issues: the status of new issues, followed by
+// the default transitions of the router. Every status can be transitioned to from any other.
+var JiraStatuses = []string{"Open", "In Progress", "Pending Approval", "Done"}
+
+// Jira is a fake of the Jira REST API, keeping the issues created through it in memory. It serves the
+// issue, comment, transition, search, user and project endpoints used by the router, with the version 2
+// and 3 paths alike.
+type Jira struct {
+	*httptest.Server
+
+	// AnyUser makes user searches find an account for any username, rather than only the users added
+	AnyUser bool
+
+	mu       sync.Mutex
+	issues   []*fakeIssue
+	users    []jira.User
+	statuses []string
+}
+
+// fakeIssue is an issue of the fake Jira API. Its fields are kept as sent by the router.
+type fakeIssue struct {
+	id       string
+	key      string
+	fields   map[string]interface{}
+	status   string
+	history  []string
+	comments []fakeComment
+	created  time.Time
+	updated  time.Time
+}
+
+// fakeComment is a comment of an issue, whose body is a string or, with the version 3 API, a document
+type fakeComment struct {
+	ID   string          `json:"id"`
+	Body json.RawMessage `json:"body"`
+}
+
+// NewJira starts a fake Jira API, with JiraReporter as its authenticated user. Close it when done.
+func NewJira() *Jira {
+	j := &Jira{statuses: slices.Clone(JiraStatuses)}
+	j.AddUser(JiraReporter)
+
+	router := chi.NewRouter()
+	router.Use(requireToken("Bearer " + JiraToken))
+	router.Route("/rest/api/{version}", func(r chi.Router) {
+		r.Get("/myself", j.myself)
+		r.Get("/mypermissions", j.myPermissions)
+		r.Get("/user/search", j.findUsers)
+		r.Get("/project/{key}", j.project)
+		r.Get("/project/{key}/statuses", j.projectStatuses)
+		r.Get("/search", j.search)
+		r.Post("/issue", j.createIssue)
+		r.Post("/issue/bulk", j.bulkCreateIssues)
+		r.Post("/issueLink", j.accepted)
+		r.Get("/issue/{id}", j.getIssue)
+		r.Put("/issue/{id}", j.updateIssue)
+		r.Get("/issue/{id}/transitions", j.transitions)
+		r.Post("/issue/{id}/transitions", j.doTransition)
+		r.Post("/issue/{id}/comment", j.addComment)
+	})
+
+	j.Server = httptest.NewServer(router)
+	return j
+}
+
+// Config returns a JiraConfig of the fake Jira API, creating the issues in JiraProject
+func (j *Jira) Config() config.JiraConfig {
+	return config.JiraConfig{
+		Host:      j.URL,
+		Token:     JiraToken,
+		Key:       JiraProject,
+		IssueType: JiraIssueType,
+		Transitions: map[string]string{
+			"initial": JiraStatuses[1],
+			"sre":     JiraStatuses[2],
+			"manager": JiraStatuses[3],
+		},
+	}
+}
+
+// SetStatuses replaces the workflow of the issues: the status of new issues, followed by the statuses
+// they can be transitioned to. The last status is in the Done category.
+func (j *Jira) SetStatuses(statuses ...string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.statuses = statuses
+}
+
+// AddUser adds a Jira account for the username, found by its username or email address
+func (j *Jira) AddUser(username string) jira.User {
+	user := newJiraUser(username)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.users = append(j.users, user)
+	return user
+}
+
+func newJiraUser(username string) jira.User {
+	return jira.User{
+		Name:         username,
+		Key:          username,
+		AccountID:    "account-" + username,
+		DisplayName:  username,
+		EmailAddress: username + "@example.com",
+		Active:       true,
+	}
+}
+
+// Issues returns the issues created through the fake Jira API, in creation order
+func (j *Jira) Issues() []jira.Issue {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	issues := make([]jira.Issue, 0, len(j.issues))
+	for _, issue := range j.issues {
+		issues = append(issues, issue.decode())
+	}
+	return issues
+}
+
+// Comments returns the bodies of the comments of the issue with the ID or key, in the order they were
+// added. The bodies of version 3 API comments are their JSON documents.
+func (j *Jira) Comments(idOrKey string) []string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	issue := j.issue(idOrKey)
+	if issue == nil {
+		return nil
+	}
+	bodies := make([]string, 0, len(issue.comments))
+	for _, comment := range issue.comments {
+		bodies = append(bodies, jsonString(comment.Body))
+	}
+	return bodies
+}
+
+// Transitions returns the statuses the issue with the ID or key was transitioned to, in order
+func (j *Jira) Transitions(idOrKey string) []string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if issue := j.issue(idOrKey); issue != nil {
+		return slices.Clone(issue.history)
+	}
+	return nil
+}
+
+// issue returns the issue with the ID or key, or nil. The caller must hold the lock.
+func (j *Jira) issue(idOrKey string) *fakeIssue {
+	for _, issue := range j.issues {
+		if issue.id == idOrKey || issue.key == idOrKey {
+			return issue
+		}
+	}
+	return nil
+}
+
+// create adds an issue with the fields. The caller must hold the lock.
+func (j *Jira) create(fields map[string]interface{}) (*fakeIssue, error) {
+	project, _ := fields["project"].(map[string]interface{})
+	key, _ := project["key"].(string)
+	if key == "" {
+		return nil, fmt.Errorf("the project is required")
+	}
+
+	now := time.Now()
+	issue := &fakeIssue{
+		id:      strconv.Itoa(10001 + len(j.issues)),
+		key:     fmt.Sprintf("%s-%d", key, len(j.issues)+1),
+		fields:  fields,
+		status:  j.statuses[0],
+		created: now,
+		updated: now,
+	}
+	j.issues = append(j.issues, issue)
+	return issue, nil
+}
+
+// render returns the issue as served by the Jira API
+func (i *fakeIssue) render(host string) map[string]interface{} {
+	fields := make(map[string]interface{}, len(i.fields)+4)
+	for name, value := range i.fields {
+		fields[name] = value
+	}
+	fields["status"] = map[string]interface{}{"name": i.status}
+	fields["created"] = i.created.Format(jiraTimeFormat)
+	fields["updated"] = i.updated.Format(jiraTimeFormat)
+	fields["comment"] = map[string]interface{}{"comments": i.comments, "total": len(i.comments), "maxResults": len(i.comments)}
+
+	return map[string]interface{}{
+		"id":     i.id,
+		"key":    i.key,
+		"self":   host + "/rest/api/2/issue/" + i.id,
+		"fields": fields,
+	}
+}
+
+// decode returns the issue as decoded by the Jira client. The documents of version 3 API descriptions
+// and comments are decoded as their JSON.
+func (i *fakeIssue) decode() jira.Issue {
+	rendered := i.render("")
+	fields := rendered["fields"].(map[string]interface{})
+	if description, ok := fields["description"]; ok {
+		fields["description"] = jsonString(description)
+	}
+	comments := make([]map[string]string, 0, len(i.comments))
+	for _, comment := range i.comments {
+		comments = append(comments, map[string]string{"id": comment.ID, "body": jsonString(comment.Body)})
+	}
+	fields["comment"] = map[string]interface{}{"comments": comments, "total": len(comments), "maxResults": len(comments)}
+
+	var issue jira.Issue
+	if b, err := json.Marshal(rendered); err == nil {
+		_ = json.Unmarshal(b, &issue)
+	}
+	return issue
+}
+
+// jsonString returns the value if it is a string, or else its JSON
+func jsonString(value interface{}) string {
+	if raw, ok := value.(json.RawMessage); ok {
+		var str string
+		if err := json.Unmarshal(raw, &str); err == nil {
+			return str
+		}
+		return string(raw)
+	}
+	if str, ok := value.(string); ok {
+		return str
+	}
+	b, _ := json.Marshal(value)
+	return string(b)
+}
+
+// labels returns the labels of the issue
+func (i *fakeIssue) labels() []string {
+	var labels []string
+	values, _ := i.fields["labels"].([]interface{})
+	for _, value := range values {
+		if label, ok := value.(string); ok {
+			labels = append(labels, label)
+		}
+	}
+	return labels
+}
+
+func (j *Jira) myself(w http.ResponseWriter, r *http.Request) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	writeJSON(w, http.StatusOK, j.users[0])
+}
+
+// myPermissions grants every permission asked for
+func (j *Jira) myPermissions(w http.ResponseWriter, r *http.Request) {
+	permissions := map[string]interface{}{}
+	for _, name := range strings.Split(r.URL.Query().Get("permissions"), ",") {
+		permissions[name] = map[string]interface{}{"key": name, "havePermission": true}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"permissions": permissions})
+}
+
+// findUsers returns the users whose username or email address is the query
+func (j *Jira) findUsers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("query")
+	if query == "" {
+		query = r.URL.Query().Get("username")
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	found := []jira.User{}
+	for _, user := range j.users {
+		if strings.EqualFold(user.Name, query) || strings.EqualFold(user.EmailAddress, query) {
+			found = append(found, user)
+		}
+	}
+	if len(found) == 0 && j.AnyUser && query != "" {
+		found = append(found, newJiraUser(query))
+	}
+	writeJSON(w, http.StatusOK, found)
+}
+
+func (j *Jira) project(w http.ResponseWriter, r *http.Request) {
+	key := chi.URLParam(r, "key")
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"id":         "10000",
+		"key":        key,
+		"name":       key,
+		"issueTypes": []map[string]string{{"id": "1", "name": JiraIssueType}},
+	})
+}
+
+func (j *Jira) projectStatuses(w http.ResponseWriter, r *http.Request) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	statuses := make([]map[string]string, 0, len(j.statuses))
+	for _, status := range j.statuses {
+		statuses = append(statuses, map[string]string{"name": status})
+	}
+	writeJSON(w, http.StatusOK, []map[string]interface{}{{"name": JiraIssueType, "statuses": statuses}})
+}
+
+// jqlClause matches the clauses of a JQL query, eg. labels = "compliance-audit-router/managed"
+var jqlClause = regexp.MustCompile(`(\w+)\s*(!=|=|~)\s*("(?:[^"\\]|\\.)*"|[\w-]+)`)
+
+// search returns the issues matching the project, issuetype, labels, summary and statusCategory clauses
+// of the JQL query, most recent first. Other clauses, and OR, are not supported and are ignored.
+func (j *Jira) search(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	startAt, _ := strconv.Atoi(query.Get("startAt"))
+	maxResults, _ := strconv.Atoi(query.Get("maxResults"))
+	if maxResults <= 0 {
+		maxResults = 50
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var matching []map[string]interface{}
+	for n := len(j.issues) - 1; n >= 0; n-- {
+		if j.issues[n].matches(query.Get("jql"), j.statuses[len(j.statuses)-1]) {
+			matching = append(matching, j.issues[n].render(j.URL))
+		}
+	}
+
+	startAt = min(max(startAt, 0), len(matching))
+	end := min(startAt+maxResults, len(matching))
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"startAt":    startAt,
+		"maxResults": maxResults,
+		"total":      len(matching),
+		"issues":     append([]map[string]interface{}{}, matching[startAt:end]...),
+	})
+}
+
+// matches reports whether the issue matches the supported clauses of the JQL query, with the done status
+// being the only status in the Done category
+func (i *fakeIssue) matches(jql string, done string) bool {
+	for _, clause := range jqlClause.FindAllStringSubmatch(jql, -1) {
+		field, operator, value := strings.ToLower(clause[1]), clause[2], clause[3]
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		}
+
+		var match bool
+		switch field {
+		case "project":
+			project, _ := i.fields["project"].(map[string]interface{})
+			match = project["key"] == value
+		case "issuetype":
+			issueType, _ := i.fields["issuetype"].(map[string]interface{})
+			match = issueType["name"] == value
+		case "labels":
+			match = slices.Contains(i.labels(), value)
+		case "summary":
+			summary, _ := i.fields["summary"].(string)
+			match = strings.Contains(summary, strings.Trim(value, `"`))
+		case "statuscategory":
+			match = (i.status == done) == (value == "Done")
+		default:
+			continue
+		}
+
+		if match == (operator == "!=") {
+			return false
+		}
+	}
+	return true
+}
+
+func (j *Jira) createIssue(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Fields map[string]interface{} `json:"fields"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJiraError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	issue, err := j.create(body.Fields)
+	if err != nil {
+		writeJiraError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]string{"id": issue.id, "key": issue.key, "self": j.URL + "/rest/api/2/issue/" + issue.id})
+}
+
+func (j *Jira) bulkCreateIssues(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		IssueUpdates []struct {
+			Fields map[string]interface{} `json:"fields"`
+		} `json:"issueUpdates"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJiraError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	created := []map[string]string{}
+	failed := []map[string]interface{}{}
+	for n, update := range body.IssueUpdates {
+		issue, err := j.create(update.Fields)
+		if err != nil {
+			failed = append(failed, map[string]interface{}{
+				"status":              http.StatusBadRequest,
+				"failedElementNumber": n,
+				"elementErrors":       map[string]interface{}{"errorMessages": []string{err.Error()}},
+			})
+			continue
+		}
+		created = append(created, map[string]string{"id": issue.id, "key": issue.key, "self": j.URL + "/rest/api/2/issue/" + issue.id})
+	}
+
+	code := http.StatusCreated
+	if len(failed) > 0 {
+		code = http.StatusBadRequest
+	}
+	writeJSON(w, code, map[string]interface{}{"issues": created, "errors": failed})
+}
+
+func (j *Jira) getIssue(w http.ResponseWriter, r *http.Request) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	issue := j.issue(chi.URLParam(r, "id"))
+	if issue == nil {
+		writeJiraError(w, http.StatusNotFound, "Issue does not exist")
+		return
+	}
+	writeJSON(w, http.StatusOK, issue.render(j.URL))
+}
+
+// updateIssue sets the fields of the issue, and adds and removes its labels
+func (j *Jira) updateIssue(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Fields map[string]interface{} `json:"fields"`
+		Update struct {
+			Labels []map[string]string `json:"labels"`
+		} `json:"update"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJiraError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	issue := j.issue(chi.URLParam(r, "id"))
+	if issue == nil {
+		writeJiraError(w, http.StatusNotFound, "Issue does not exist")
+		return
+	}
+
+	for name, value := range body.Fields {
+		issue.fields[name] = value
+	}
+	labels := issue.labels()
+	for _, update := range body.Update.Labels {
+		if add, ok := update["add"]; ok && !slices.Contains(labels, add) {
+			labels = append(labels, add)
+		}
+		if remove, ok := update["remove"]; ok {
+			labels = slices.DeleteFunc(labels, func(label string) bool { return label == remove })
+		}
+	}
+	if len(body.Update.Labels) > 0 {
+		values := make([]interface{}, 0, len(labels))
+		for _, label := range labels {
+			values = append(values, label)
+		}
+		issue.fields["labels"] = values
+	}
+	issue.updated = time.Now()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// transitions returns a transition to each status of the workflow, named like the status
+func (j *Jira) transitions(w http.ResponseWriter, r *http.Request) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.issue(chi.URLParam(r, "id")) == nil {
+		writeJiraError(w, http.StatusNotFound, "Issue does not exist")
+		return
+	}
+
+	transitions := make([]map[string]interface{}, 0, len(j.statuses))
+	for n, status := range j.statuses {
+		transitions = append(transitions, map[string]interface{}{
+			"id":   strconv.Itoa(n + 1),
+			"name": status,
+			"to":   map[string]string{"name": status},
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"transitions": transitions})
+}
+
+func (j *Jira) doTransition(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Transition struct {
+			ID string `json:"id"`
+		} `json:"transition"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJiraError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	issue := j.issue(chi.URLParam(r, "id"))
+	if issue == nil {
+		writeJiraError(w, http.StatusNotFound, "Issue does not exist")
+		return
+	}
+	n, err := strconv.Atoi(body.Transition.ID)
+	if err != nil || n < 1 || n > len(j.statuses) {
+		writeJiraError(w, http.StatusBadRequest, fmt.Sprintf("Transition id '%s' is not valid for this issue.", body.Transition.ID))
+		return
+	}
+	issue.status = j.statuses[n-1]
+	issue.history = append(issue.history, issue.status)
+	issue.updated = time.Now()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (j *Jira) addComment(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Body json.RawMessage `json:"body"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || len(body.Body) == 0 {
+		writeJiraError(w, http.StatusBadRequest, "Comment body can not be empty!")
+		return
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	issue := j.issue(chi.URLParam(r, "id"))
+	if issue == nil {
+		writeJiraError(w, http.StatusNotFound, "Issue does not exist")
+		return
+	}
+	comment := fakeComment{ID: strconv.Itoa(len(issue.comments) + 1), Body: body.Body}
+	issue.comments = append(issue.comments, comment)
+	issue.updated = time.Now()
+
+	writeJSON(w, http.StatusCreated, comment)
+}
+
+func (j *Jira) accepted(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusCreated)
+}
+
+// writeJiraError replies with a Jira error response
+func writeJiraError(w http.ResponseWriter, code int, message string) {
+	writeJSON(w, code, map[string]interface{}{"errorMessages": []string{message}, "errors": map[string]string{}})
+}
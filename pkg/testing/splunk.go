@@ -0,0 +1,151 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package testing provides fakes of the services the router depends on: httptest servers standing in for
+// the Splunk and Jira APIs, and an in-memory identity provider, so integration tests and local development
+// can run the full pipeline offline
+package testing
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/splunk"
+)
+
+// SplunkToken is the token the fake Splunk API accepts
+const SplunkToken = "splunk-token"
+
+// Splunk is a fake of the Splunk search jobs API, serving the results added to it
+type Splunk struct {
+	*httptest.Server
+
+	mu sync.Mutex
+	// results are the search results of each search job, by search ID
+	results map[string][]splunk.SearchResult
+	// searches are the results of the search jobs created with each search
+	searches map[string][]splunk.SearchResult
+	jobs     int
+}
+
+// NewSplunk starts a fake Splunk API. Close it when done.
+func NewSplunk() *Splunk {
+	s := &Splunk{
+		results:  map[string][]splunk.SearchResult{},
+		searches: map[string][]splunk.SearchResult{},
+	}
+
+	router := chi.NewRouter()
+	router.Use(requireToken("Bearer " + SplunkToken))
+	router.Get("/services/search/v2/jobs", s.listJobs)
+	router.Post("/services/search/v2/jobs", s.createJob)
+	router.Get("/services/search/v2/jobs/{sid}/results", s.jobResults)
+
+	s.Server = httptest.NewServer(router)
+	return s
+}
+
+// Config returns the SplunkConfig of the fake Splunk API
+func (s *Splunk) Config() config.SplunkConfig {
+	return config.SplunkConfig{Host: s.URL, Token: SplunkToken}
+}
+
+// AddResults sets the search results of the alert with the search ID, as retrieved from its webhook
+func (s *Splunk) AddResults(sid string, results ...splunk.SearchResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results[sid] = results
+}
+
+// AddSearch sets the results of the search jobs created with the search, eg. by the backfill.
+// The search is matched as sent, including the search command prepended to it.
+func (s *Splunk) AddSearch(search string, results ...splunk.SearchResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.searches[search] = results
+}
+
+func (s *Splunk) listJobs(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"entry": []interface{}{}})
+}
+
+// createJob creates a search job with the results of the search, which completes right away
+func (s *Splunk) createJob(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	results, ok := s.searches[r.PostForm.Get("search")]
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown search %q", r.PostForm.Get("search")), http.StatusBadRequest)
+		return
+	}
+	s.jobs++
+	sid := fmt.Sprintf("fake_search_%d", s.jobs)
+	s.results[sid] = results
+
+	writeJSON(w, http.StatusCreated, map[string]string{"sid": sid})
+}
+
+// jobResults serves a page of the results of a search job, from the offset, or all of them without a count
+func (s *Splunk) jobResults(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	results, ok := s.results[chi.URLParam(r, "sid")]
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, "Unknown sid", http.StatusNotFound)
+		return
+	}
+
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	count, _ := strconv.Atoi(r.URL.Query().Get("count"))
+	offset = min(max(offset, 0), len(results))
+	end := len(results)
+	if count > 0 {
+		end = min(offset+count, end)
+	}
+
+	writeJSON(w, http.StatusOK, splunk.SearchResults{InitOffset: offset, Results: results[offset:end]})
+}
+
+// requireToken rejects the requests without the Authorization header
+func requireToken(authorization string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != authorization {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
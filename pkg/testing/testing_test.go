@@ -0,0 +1,145 @@
+/*
+Copyright 2021-2024 Red Hat, Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testing_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	gojira "github.com/andygrunwald/go-jira"
+	"github.com/openshift/compliance-audit-router/pkg/config"
+	"github.com/openshift/compliance-audit-router/pkg/identity"
+	"github.com/openshift/compliance-audit-router/pkg/jira"
+	"github.com/openshift/compliance-audit-router/pkg/listeners"
+	"github.com/openshift/compliance-audit-router/pkg/splunk"
+	cartesting "github.com/openshift/compliance-audit-router/pkg/testing"
+)
+
+func TestPipeline(t *testing.T) {
+	splunkServer := cartesting.NewSplunk()
+	defer splunkServer.Close()
+	jiraServer := cartesting.NewJira()
+	defer jiraServer.Close()
+
+	identities := cartesting.NewIdentities()
+	identities.AddUser("sre", "manager", "sre-platform")
+	identities.AddUser("manager", "")
+	identity.SetDefault(identities)
+	defer identity.SetDefault(nil)
+
+	jiraServer.AddUser("sre")
+	jiraServer.AddUser("manager")
+
+	config.SetAppConfig(config.Config{
+		SplunkConfig:    splunkServer.Config(),
+		JiraConfig:      jiraServer.Config(),
+		PipelineConfig:  config.PipelineConfig{JiraParallelism: 1},
+		MessageTemplate: "{{.Username}} please justify",
+	})
+	defer func() { config.SetAppConfig(config.Config{}) }()
+
+	splunkServer.AddResults("scheduler_1", splunk.SearchResult{
+		"alertname":        "elevated access",
+		"username":         "sre",
+		"group":            "sre-platform",
+		"clusterid":        "abc",
+		"elevated_summary": "oc get secrets",
+	})
+
+	alert, err := splunk.Server(config.AppConfig().SplunkConfig).RetrieveSearchFromAlert(context.Background(), "scheduler_1")
+	if err != nil {
+		t.Fatalf("RetrieveSearchFromAlert() unexpected error: %v", err)
+	}
+	if err := listeners.ProcessAlert(context.Background(), alert); err != nil {
+		t.Fatalf("ProcessAlert() unexpected error: %v", err)
+	}
+
+	issues := jiraServer.Issues()
+	if len(issues) != 1 {
+		t.Fatalf("the pipeline created %v issues, want 1", len(issues))
+	}
+	issue := issues[0]
+	if issue.Key != "OHSS-1" || issue.Fields.Status.Name != "In Progress" || !strings.Contains(issue.Fields.Description, "elevated access") {
+		t.Errorf("the pipeline created %v in %v with %q", issue.Key, issue.Fields.Status.Name, issue.Fields.Description)
+	}
+	if issue.Fields.Assignee == nil || issue.Fields.Assignee.AccountID != "account-sre" {
+		t.Errorf("the pipeline assigned the issue to %+v, want account-sre", issue.Fields.Assignee)
+	}
+	if comments := jiraServer.Comments(issue.Key); len(comments) != 1 || !strings.Contains(comments[0], "please justify") {
+		t.Errorf("the pipeline commented %q, want the message template", comments)
+	}
+
+	if _, err := splunk.Server(config.AppConfig().SplunkConfig).RetrieveSearchFromAlert(context.Background(), "unknown"); err == nil {
+		t.Errorf("RetrieveSearchFromAlert() expected an error for an unknown sid")
+	}
+}
+
+func TestSplunkSearch(t *testing.T) {
+	splunkServer := cartesting.NewSplunk()
+	defer splunkServer.Close()
+	splunkServer.AddSearch("search index=audit", splunk.SearchResult{"username": "sre"}, splunk.SearchResult{"username": "admin"})
+
+	var users []interface{}
+	err := splunk.Server(splunkServer.Config()).Search(context.Background(), "index=audit", time.Now().Add(-time.Hour), time.Now(), 1, func(page splunk.Alert) error {
+		for _, result := range page.SearchResults.Results {
+			users = append(users, result["username"])
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Search() unexpected error: %v", err)
+	}
+	if len(users) != 2 || users[0] != "sre" || users[1] != "admin" {
+		t.Errorf("Search() returned %v, want the results of the search a page at a time", users)
+	}
+}
+
+func TestJiraSearch(t *testing.T) {
+	jiraServer := cartesting.NewJira()
+	defer jiraServer.Close()
+
+	client, err := jira.NewClient(jiraServer.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, summary := range []string{"first", "second"} {
+		if _, _, err := client.Issue.Create(&gojira.Issue{Fields: &gojira.IssueFields{
+			Project: gojira.Project{Key: cartesting.JiraProject},
+			Summary: summary,
+		}}); err != nil {
+			t.Fatalf("Create() unexpected error: %v", err)
+		}
+	}
+	if _, err := client.Issue.UpdateIssue("10002", map[string]interface{}{
+		"update": map[string]interface{}{"labels": []map[string]string{{"add": "flagged"}}},
+	}); err != nil {
+		t.Fatalf("UpdateIssue() unexpected error: %v", err)
+	}
+
+	issues, _, err := client.Issue.Search(`project = "OHSS" AND labels = "flagged" AND statusCategory != Done`, nil)
+	if err != nil {
+		t.Fatalf("Search() unexpected error: %v", err)
+	}
+	if len(issues) != 1 || issues[0].Key != "OHSS-2" {
+		t.Errorf("Search() returned %+v, want OHSS-2", issues)
+	}
+	if issues, _, _ := client.Issue.Search(`project = "OTHER"`, nil); len(issues) != 0 {
+		t.Errorf("Search() returned %+v for another project, want none", issues)
+	}
+}